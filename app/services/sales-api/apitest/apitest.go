@@ -0,0 +1,316 @@
+// Package apitest boots the sales-api in process so handler tests can run
+// against the real router and middleware without docker. Users live in the
+// memory store and tokens are signed with a key generated for the test.
+package apitest
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap/zaptest"
+	"net/http"
+	"os"
+	"service/app/services/sales-api/handlers"
+	"service/domain/core/apikey"
+	"service/domain/core/inventory"
+	"service/domain/core/lockout"
+	"service/domain/core/mfa"
+	"service/domain/core/reset"
+	apikeyMemory "service/domain/data/store/apikey/memory"
+	auditMemory "service/domain/data/store/audit/memory"
+	categoryMemory "service/domain/data/store/category/memory"
+	lockoutMemory "service/domain/data/store/lockout/memory"
+	mfaMemory "service/domain/data/store/mfa/memory"
+	orderMemory "service/domain/data/store/order/memory"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	promotionMemory "service/domain/data/store/promotion/memory"
+	purchaseMemory "service/domain/data/store/purchase/memory"
+	refundMemory "service/domain/data/store/refund/memory"
+	resetMemory "service/domain/data/store/reset/memory"
+	roleMemory "service/domain/data/store/role/memory"
+	stocktakeMemory "service/domain/data/store/stocktake/memory"
+	supplierMemory "service/domain/data/store/supplier/memory"
+	transferMemory "service/domain/data/store/transfer/memory"
+	"service/domain/data/store/user"
+	"service/domain/data/store/user/memory"
+	warehouseMemory "service/domain/data/store/warehouse/memory"
+	"service/domain/sys/auth"
+	"service/domain/sys/validate"
+	"service/foundation/keystore"
+	"service/foundation/money"
+	"service/foundation/notification"
+	"testing"
+	"time"
+)
+
+// Marks used in the test output.
+const (
+	Succeeded = "\u2713"
+	Failed    = "\u2717"
+)
+
+// keyID is the kid of the key generated for every harness.
+const keyID = "apitest"
+
+// ResetURL is the page password reset emails link to.
+const ResetURL = "http://localhost/reset-password"
+
+// LockoutThreshold is the number of failed logins that locks an account.
+// Delays are turned off so tests do not wait.
+const LockoutThreshold = 3
+
+// RotationOverlap is how long a rotated API key keeps working.
+const RotationOverlap = time.Hour
+
+// LowStockEmail receives the low stock alerts.
+const LowStockEmail = "stock@sales.example.com"
+
+// Harness is a running sales-api and the stores behind it. Requests are
+// built on the harness and report to the test given to Do, so a harness
+// can be shared by subtests.
+type Harness struct {
+	App        http.Handler
+	Auth       *auth.Auth
+	Users      *memory.Store
+	Resets     *resetMemory.Store
+	Lockouts   *lockoutMemory.Store
+	Audit      *auditMemory.Store
+	MFA        *mfaMemory.Store
+	Roles      *roleMemory.Store
+	APIKeys    *apikeyMemory.Store
+	Products   *productMemory.Store
+	Categories *categoryMemory.Store
+	Orders     *orderMemory.Store
+	Refunds    *refundMemory.Store
+	Warehouses *warehouseMemory.Store
+	Transfers  *transferMemory.Store
+	Stocktakes *stocktakeMemory.Store
+	Suppliers  *supplierMemory.Store
+	Purchases  *purchaseMemory.Store
+	Promotions *promotionMemory.Store
+	LowStock   *inventory.Checker
+	Mail       *notification.Memory
+	Shutdown   chan os.Signal
+	t          *testing.T
+}
+
+// New constructs the API mux against empty memory stores. Logs go through
+// the test so they only show up for failing tests or with -v.
+func New(t *testing.T) *Harness {
+	t.Helper()
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	a, err := auth.New(keyID, keystore.NewMap(map[string]*rsa.PrivateKey{keyID: privateKey}))
+	if err != nil {
+		t.Fatalf("constructing auth: %v", err)
+	}
+
+	users := memory.NewStore()
+	resets := resetMemory.NewStore()
+	lockouts := lockoutMemory.NewStore()
+	auditor := auditMemory.NewStore()
+	mfas := mfaMemory.NewStore()
+	roles := roleMemory.NewStore()
+	apikeys := apikeyMemory.NewStore()
+	products := productMemory.NewStore()
+	categories := categoryMemory.NewStore()
+	promotions := promotionMemory.NewStore()
+	orders := orderMemory.NewStore(products, promotions)
+	refunds := refundMemory.NewStore(orders, products)
+	warehouses := warehouseMemory.NewStore(products)
+	transfers := transferMemory.NewStore(products)
+	stocktakes := stocktakeMemory.NewStore(products)
+	suppliers := supplierMemory.NewStore()
+	purchases := purchaseMemory.NewStore(products)
+	mail := notification.NewMemory()
+	shutdown := make(chan os.Signal, 1)
+	log := zaptest.NewLogger(t).Sugar()
+
+	// The checker only works off the sales, sweeps are left to tests that
+	// call Check themselves. It is stopped before the test ends so it never
+	// logs to a finished test.
+	checker := inventory.NewChecker(log, products, inventory.NewMailNotifier(mail, LowStockEmail), inventory.CheckerConfig{
+		Queue: 100,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		checker.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	app := handlers.AppAPIMux(handlers.APIMuxConfig{
+		Build:     "test",
+		Shutdown:  shutdown,
+		Log:       log,
+		Auth:      a,
+		UserStore: users,
+		Mailer:    mail,
+		Reset: reset.Config{
+			TTL: 30 * time.Minute,
+			URL: ResetURL,
+		},
+		ResetStore: resets,
+		Lockout: lockout.Config{
+			Threshold:   LockoutThreshold,
+			IPThreshold: 100,
+			Window:      15 * time.Minute,
+			LockFor:     15 * time.Minute,
+		},
+		LockoutStore: lockouts,
+		AuditStore:   auditor,
+		MFA: mfa.Config{
+			Issuer:          "sales-api",
+			RequireForAdmin: false,
+			ChallengeTTL:    5 * time.Minute,
+		},
+		MFAStore:  mfas,
+		RoleStore: roles,
+		APIKey: apikey.Config{
+			RotationOverlap: RotationOverlap,
+		},
+		APIKeyStore:    apikeys,
+		OrderStore:     orders,
+		ProductStore:   products,
+		CategoryStore:  categories,
+		RefundStore:    refunds,
+		WarehouseStore: warehouses,
+		TransferStore:  transfers,
+		StocktakeStore: stocktakes,
+		SupplierStore:  suppliers,
+		PurchaseStore:  purchases,
+		PromotionStore: promotions,
+		LowStock:       checker,
+	})
+
+	h := Harness{
+		App:        app,
+		Auth:       a,
+		Users:      users,
+		Resets:     resets,
+		Lockouts:   lockouts,
+		Audit:      auditor,
+		MFA:        mfas,
+		Roles:      roles,
+		APIKeys:    apikeys,
+		Products:   products,
+		Categories: categories,
+		Orders:     orders,
+		Refunds:    refunds,
+		Warehouses: warehouses,
+		Transfers:  transfers,
+		Stocktakes: stocktakes,
+		Suppliers:  suppliers,
+		Purchases:  purchases,
+		Promotions: promotions,
+		LowStock:   checker,
+		Mail:       mail,
+		Shutdown:   shutdown,
+		t:          t,
+	}
+	return &h
+}
+
+// Token mints a token for the subject with the given roles and the
+// permissions they grant in the role store. The subject does not have to
+// exist in the user store.
+func (h *Harness) Token(subject string, roles ...string) string {
+	h.t.Helper()
+
+	token, err := h.token(subject, roles...)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return token
+}
+
+func (h *Harness) token(subject string, roles ...string) (string, error) {
+	perms, err := h.Roles.PermissionsFor(context.Background(), roles)
+	if err != nil {
+		return "", fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject: subject,
+		},
+		Roles:       roles,
+		Permissions: perms,
+	}
+
+	token, err := h.Auth.GenerateToken(claims)
+	if err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return token, nil
+}
+
+// CreateUser adds a user straight to the store.
+func (h *Harness) CreateUser(name, email, password string, roles ...string) user.User {
+	h.t.Helper()
+
+	nu := user.NewUser{
+		Name:            name,
+		Email:           email,
+		Roles:           roles,
+		Password:        password,
+		PasswordConfirm: password,
+	}
+
+	usr, err := h.Users.Create(context.Background(), nu, time.Now())
+	if err != nil {
+		h.t.Fatalf("creating user %s: %v", email, err)
+	}
+	return usr
+}
+
+// CreateAPIKey issues a key with the scopes through the API on behalf of the
+// admin and returns its id along with the key to send in X-API-Key.
+func (h *Harness) CreateAPIKey(adminID string, name string, scopes ...string) (string, string) {
+	h.t.Helper()
+
+	var issued struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	h.Post("/v1/apikeys").
+		As(adminID, auth.RoleAdmin).
+		JSON(map[string]any{"name": name, "scopes": scopes}).
+		Do(h.t).
+		Status(http.StatusCreated).
+		Decode(&issued)
+
+	return issued.ID, issued.Key
+}
+
+// CreateProduct adds a product straight to the store.
+func (h *Harness) CreateProduct(name string, cost int, quantity int) product.Product {
+	h.t.Helper()
+
+	now := time.Now()
+	p := product.Product{
+		ID:          validate.GenerateUID(),
+		Name:        name,
+		Cost:        cost,
+		Currency:    money.USD,
+		Quantity:    quantity,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := h.Products.Create(context.Background(), p); err != nil {
+		h.t.Fatalf("creating product %s: %v", name, err)
+	}
+	return p
+}
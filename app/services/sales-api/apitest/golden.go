@@ -0,0 +1,92 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+)
+
+// update rewrites the golden files with what the API answers today.
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// masked replaces the values of masked fields in golden files.
+const masked = "<masked>"
+
+// Golden compares the body with testdata/<name>.golden. Values of the
+// fields listed in mask, at any depth, are replaced before comparing so
+// ids and timestamps do not break the file. Run the tests with -update to
+// write the files.
+func (r *Response) Golden(name string, mask ...string) *Response {
+	r.t.Helper()
+
+	got, err := normalize(r.Body, mask)
+	if err != nil {
+		r.t.Fatalf("\t%s\t%s should respond with json: %v: %s", Failed, r.name, err, r.Body)
+	}
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			r.t.Fatalf("creating testdata: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			r.t.Fatalf("writing golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		r.t.Fatalf("\t%s\treading golden file, run with -update to create it: %v", Failed, err)
+	}
+
+	if !bytes.Equal(got, want) {
+		r.t.Fatalf("\t%s\t%s should match %s\nwant\n%s\ngot\n%s", Failed, r.name, path, want, got)
+	}
+	r.t.Logf("\t%s\t%s should match %s", Succeeded, r.name, path)
+	return r
+}
+
+// normalize re-encodes the document indented with sorted keys and the
+// masked fields replaced.
+func normalize(data []byte, mask []string) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool, len(mask))
+	for _, f := range mask {
+		fields[f] = true
+	}
+	doc = maskFields(doc, fields)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func maskFields(v any, fields map[string]bool) any {
+	switch doc := v.(type) {
+	case map[string]any:
+		for k, val := range doc {
+			if fields[k] {
+				doc[k] = masked
+				continue
+			}
+			doc[k] = maskFields(val, fields)
+		}
+	case []any:
+		for i := range doc {
+			doc[i] = maskFields(doc[i], fields)
+		}
+	}
+	return v
+}
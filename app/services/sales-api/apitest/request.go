@@ -0,0 +1,194 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Request is built fluently and sent with Do.
+type Request struct {
+	h      *Harness
+	method string
+	path   string
+	body   io.Reader
+	header http.Header
+	user   string
+	pass   string
+	basic  bool
+	err    error
+}
+
+// Request starts a request against the harness.
+func (h *Harness) Request(method string, path string) *Request {
+	return &Request{
+		h:      h,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+	}
+}
+
+// Get starts a GET request.
+func (h *Harness) Get(path string) *Request {
+	return h.Request(http.MethodGet, path)
+}
+
+// Post starts a POST request.
+func (h *Harness) Post(path string) *Request {
+	return h.Request(http.MethodPost, path)
+}
+
+// Put starts a PUT request.
+func (h *Harness) Put(path string) *Request {
+	return h.Request(http.MethodPut, path)
+}
+
+// Patch starts a PATCH request.
+func (h *Harness) Patch(path string) *Request {
+	return h.Request(http.MethodPatch, path)
+}
+
+// Delete starts a DELETE request.
+func (h *Harness) Delete(path string) *Request {
+	return h.Request(http.MethodDelete, path)
+}
+
+// Token sends the token as a bearer token.
+func (r *Request) Token(token string) *Request {
+	r.header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+// As mints a token for the subject and roles and sends it.
+func (r *Request) As(subject string, roles ...string) *Request {
+	token, err := r.h.token(subject, roles...)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	return r.Token(token)
+}
+
+// BasicAuth sends the credentials with basic auth.
+func (r *Request) BasicAuth(user string, pass string) *Request {
+	r.user, r.pass, r.basic = user, pass, true
+	return r
+}
+
+// Header sets a request header.
+func (r *Request) Header(key string, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// JSON marshals v as the request body. Strings and byte slices are sent
+// as they are, which allows sending malformed documents.
+func (r *Request) JSON(v any) *Request {
+	switch b := v.(type) {
+	case string:
+		r.body = bytes.NewBufferString(b)
+	case []byte:
+		r.body = bytes.NewBuffer(b)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			r.err = fmt.Errorf("marshaling request body: %w", err)
+			return r
+		}
+		r.body = bytes.NewBuffer(data)
+	}
+
+	r.header.Set("Content-Type", "application/json")
+	return r
+}
+
+// Do sends the request through the API, the response reports to t. A
+// handler asking the service to shut down fails the test, a real server
+// would have gone away.
+func (r *Request) Do(t *testing.T) *Response {
+	t.Helper()
+
+	if r.err != nil {
+		t.Fatalf("\t%s\tbuilding %s %s: %v", Failed, r.method, r.path, r.err)
+	}
+
+	req := httptest.NewRequest(r.method, r.path, r.body)
+	req.Header = r.header
+	if r.basic {
+		req.SetBasicAuth(r.user, r.pass)
+	}
+
+	w := httptest.NewRecorder()
+	r.h.App.ServeHTTP(w, req)
+
+	select {
+	case sig := <-r.h.Shutdown:
+		t.Fatalf("\t%s\t%s %s asked the service to shut down: %v", Failed, r.method, r.path, sig)
+	default:
+	}
+
+	resp := Response{
+		Code:   w.Code,
+		Header: w.Header(),
+		Body:   w.Body.Bytes(),
+		t:      t,
+		name:   r.method + " " + r.path,
+	}
+	return &resp
+}
+
+// Response is the recorded answer of the API.
+type Response struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+	t      *testing.T
+	name   string
+}
+
+// Status fails the test when the status code is not the expected one.
+func (r *Response) Status(want int) *Response {
+	r.t.Helper()
+
+	if r.Code != want {
+		r.t.Fatalf("\t%s\t%s should respond %d, got %d: %s", Failed, r.name, want, r.Code, r.Body)
+	}
+	r.t.Logf("\t%s\t%s should respond %d", Succeeded, r.name, want)
+	return r
+}
+
+// Decode unmarshals the body into v.
+func (r *Response) Decode(v any) *Response {
+	r.t.Helper()
+
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		r.t.Fatalf("\t%s\t%s should respond with json: %v: %s", Failed, r.name, err, r.Body)
+	}
+	return r
+}
+
+// JSONEq fails the test when the body is not the same document as want,
+// key order and formatting do not matter.
+func (r *Response) JSONEq(want string) *Response {
+	r.t.Helper()
+
+	got, err := normalize(r.Body, nil)
+	if err != nil {
+		r.t.Fatalf("\t%s\t%s should respond with json: %v: %s", Failed, r.name, err, r.Body)
+	}
+
+	exp, err := normalize([]byte(want), nil)
+	if err != nil {
+		r.t.Fatalf("\t%s\texpected document is not json: %v", Failed, err)
+	}
+
+	if !bytes.Equal(got, exp) {
+		r.t.Fatalf("\t%s\t%s should respond with\n%s\ngot\n%s", Failed, r.name, exp, got)
+	}
+	return r
+}
@@ -2,45 +2,47 @@ package checkgrp
 
 import (
 	"context"
-	"github.com/jmoiron/sqlx"
+	"encoding/json"
 	"go.uber.org/zap"
 	"net/http"
 	"os"
-	"service/domain/sys/database"
+	"runtime"
+	"service/foundation/health"
 	"service/tooling"
 	"time"
 )
 
 type Handlers struct {
-	Build string
-	Log   *zap.SugaredLogger
-	DB    *sqlx.DB
+	Build  string
+	Log    *zap.SugaredLogger
+	Health *health.Registry
 }
 
+// Readiness runs the registered dependency checks and reports each of them,
+// a failing critical check makes the service not ready.
 func (h Handlers) Readiness(w http.ResponseWriter, r *http.Request) {
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	data := struct {
-		Status string `json:"status"`
-	}{
-		Status: "ok",
-	}
+	report := h.Health.Run(ctx)
 
-	var statusCode = http.StatusOK
-	if err := database.StatusCheck(ctx, h.DB); err != nil {
-		data.Status = "not ready"
-		statusCode = http.StatusInternalServerError
+	statusCode := http.StatusOK
+	if !report.Ready() {
+		statusCode = http.StatusServiceUnavailable
 	}
 
-	err := response(w, statusCode, data)
-	if err != nil {
+	if err := response(w, statusCode, report); err != nil {
 		h.Log.Errorw("readiness", "error", err)
 	}
-	h.Log.Infow("readiness", "statuscode", statusCode)
+
+	if report.Status != health.StatusOK {
+		h.Log.Infow("readiness", "statuscode", statusCode, "status", report.Status, "checks", report.Checks)
+	}
 }
 
+// Liveness reports the build and where the service is running. It never
+// touches a dependency, a live process is enough.
 func (h Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
 
 	host, err := os.Hostname()
@@ -49,32 +51,44 @@ func (h Handlers) Liveness(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := struct {
-		Status    string `json:"status,omitempty"`
-		Build     string `json:"build,omitempty"`
-		Host      string `json:"host,omitempty"`
-		Pod       string `json:"pod,omitempty"`
-		PodIP     string `json:"pod_ip,omitempty"`
-		Node      string `json:"node,omitempty"`
-		Namespace string `json:"namespace,omitempty"`
+		Status     string `json:"status,omitempty"`
+		Build      string `json:"build,omitempty"`
+		Host       string `json:"host,omitempty"`
+		Pod        string `json:"pod,omitempty"`
+		PodIP      string `json:"pod_ip,omitempty"`
+		Node       string `json:"node,omitempty"`
+		Namespace  string `json:"namespace,omitempty"`
+		GOMAXPROCS int    `json:"gomaxprocs,omitempty"`
 	}{
-		Status:    "up",
-		Build:     h.Build,
-		Host:      host,
-		Pod:       os.Getenv("KUBERNETES_POD_NAME"),
-		PodIP:     os.Getenv("KUBERNETES_NAME_SPACE_POD_IP"),
-		Node:      os.Getenv("KUBERNETES_NODENAME"),
-		Namespace: os.Getenv("KUBERNETES_NAMESPACE"),
+		Status:     "up",
+		Build:      h.Build,
+		Host:       host,
+		Pod:        os.Getenv("KUBERNETES_POD_NAME"),
+		PodIP:      os.Getenv("KUBERNETES_POD_IP"),
+		Node:       os.Getenv("KUBERNETES_NODENAME"),
+		Namespace:  os.Getenv("KUBERNETES_NAMESPACE"),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
 	}
 
 	statusCode := http.StatusOK
-	err = response(w, statusCode, data)
-	if err != nil {
-		h.Log.Errorw("readiness", "error", err)
+	if err := response(w, statusCode, data); err != nil {
+		h.Log.Errorw("liveness", "error", err)
 	}
 
 	h.Log.Infow(tooling.GetCallerName(), "status_code", statusCode)
 }
 
 func response(w http.ResponseWriter, statusCode int, data any) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(jsonData); err != nil {
+		return err
+	}
 	return nil
 }
@@ -9,11 +9,61 @@ import (
 	"net/http/pprof"
 	"os"
 	"service/app/services/sales-api/handlers/debug/checkgrp"
+	"service/app/services/sales-api/handlers/v1/apikeygrp"
+	"service/app/services/sales-api/handlers/v1/categorygrp"
+	"service/app/services/sales-api/handlers/v1/inventorygrp"
+	"service/app/services/sales-api/handlers/v1/mfagrp"
+	"service/app/services/sales-api/handlers/v1/ordergrp"
+	"service/app/services/sales-api/handlers/v1/productgrp"
+	"service/app/services/sales-api/handlers/v1/promotiongrp"
+	"service/app/services/sales-api/handlers/v1/purchasegrp"
+	"service/app/services/sales-api/handlers/v1/refundgrp"
+	"service/app/services/sales-api/handlers/v1/resetgrp"
+	"service/app/services/sales-api/handlers/v1/rolegrp"
+	"service/app/services/sales-api/handlers/v1/stocktakegrp"
+	"service/app/services/sales-api/handlers/v1/suppliergrp"
 	"service/app/services/sales-api/handlers/v1/testgrp"
+	"service/app/services/sales-api/handlers/v1/transfergrp"
 	v1UserGrp "service/app/services/sales-api/handlers/v1/usergrp"
+	"service/app/services/sales-api/handlers/v1/warehousegrp"
+	"service/domain/core/apikey"
+	"service/domain/core/category"
+	"service/domain/core/inventory"
+	"service/domain/core/lockout"
+	"service/domain/core/mfa"
+	"service/domain/core/order"
+	"service/domain/core/product"
+	"service/domain/core/promotion"
+	"service/domain/core/purchase"
+	"service/domain/core/refund"
+	"service/domain/core/reset"
+	"service/domain/core/role"
+	"service/domain/core/stocktake"
+	"service/domain/core/supplier"
+	"service/domain/core/transfer"
 	"service/domain/core/user"
+	"service/domain/core/warehouse"
+	apikeyStore "service/domain/data/store/apikey"
+	auditStore "service/domain/data/store/audit"
+	categoryStore "service/domain/data/store/category"
+	lockoutStore "service/domain/data/store/lockout"
+	mfaStore "service/domain/data/store/mfa"
+	orderStore "service/domain/data/store/order"
+	productStore "service/domain/data/store/product"
+	promotionStore "service/domain/data/store/promotion"
+	purchaseStore "service/domain/data/store/purchase"
+	refundStore "service/domain/data/store/refund"
+	resetStore "service/domain/data/store/reset"
+	roleStore "service/domain/data/store/role"
+	stocktakeStore "service/domain/data/store/stocktake"
+	supplierStore "service/domain/data/store/supplier"
+	transferStore "service/domain/data/store/transfer"
+	userStore "service/domain/data/store/user"
+	warehouseStore "service/domain/data/store/warehouse"
 	"service/domain/sys/auth"
 	"service/domain/web/mid"
+	"service/foundation/health"
+	"service/foundation/notification"
 	"service/foundation/web"
 )
 
@@ -22,26 +72,28 @@ import (
 func DebugStandardLibraryMux() *http.ServeMux {
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("debug/pprof", pprof.Index)
-	mux.HandleFunc("debug/cmdline", pprof.Cmdline)
-	mux.HandleFunc("debug/profile", pprof.Profile)
-	mux.HandleFunc("debug/symbol", pprof.Symbol)
-	mux.HandleFunc("debug/trace", pprof.Trace)
-	mux.Handle("debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
 
 	return mux
 }
 
-func DebugMux(build string, log *zap.SugaredLogger, db *sqlx.DB) http.Handler {
+// DebugMux registers the profiling routes along with the liveness and
+// readiness probes, readiness reports every check registered in hr.
+func DebugMux(build string, log *zap.SugaredLogger, hr *health.Registry) http.Handler {
 	cgh := checkgrp.Handlers{
-		Build: build,
-		Log:   log,
-		DB:    db,
+		Build:  build,
+		Log:    log,
+		Health: hr,
 	}
 
 	mux := DebugStandardLibraryMux()
-	mux.HandleFunc("debug/liveness", cgh.Liveness)
-	mux.HandleFunc("debug/readiness", cgh.Readiness)
+	mux.HandleFunc("/debug/liveness", cgh.Liveness)
+	mux.HandleFunc("/debug/readiness", cgh.Readiness)
 	return mux
 }
 
@@ -51,6 +103,75 @@ type APIMuxConfig struct {
 	Log      *zap.SugaredLogger
 	Auth     *auth.Auth
 	DB       *sqlx.DB
+
+	// UserStore replaces the postgres user store when set, tests use it to
+	// run the API against the memory store.
+	UserStore user.Storer
+
+	// Mailer delivers the password reset emails, Reset configures the
+	// tokens they carry.
+	Mailer notification.Mailer
+	Reset  reset.Config
+
+	// ResetStore replaces the postgres reset token store when set.
+	ResetStore reset.Storer
+
+	// Proxies are the proxies in front of the API, trusted to tell the
+	// address of the client through X-Forwarded-For.
+	Proxies web.Proxies
+
+	// Lockout is the login throttling policy. LockoutStore and AuditStore
+	// replace the postgres stores when set.
+	Lockout      lockout.Config
+	LockoutStore lockout.Storer
+	AuditStore   lockout.Auditor
+
+	// MFA is the second factor policy. MFAStore replaces the postgres
+	// store when set.
+	MFA      mfa.Config
+	MFAStore mfa.Storer
+
+	// RoleStore replaces the postgres role store when set.
+	RoleStore role.Storer
+
+	// APIKey is the API key policy. APIKeyStore replaces the postgres
+	// store when set.
+	APIKey      apikey.Config
+	APIKeyStore apikey.Storer
+
+	// OrderStore, ProductStore and CategoryStore replace the postgres
+	// stores when set. The order store takes the stock, so it must share
+	// the products.
+	OrderStore    order.Storer
+	ProductStore  product.Storer
+	CategoryStore category.Storer
+
+	// RefundStore replaces the postgres refund store when set, it must
+	// share the orders and products of the stores above.
+	RefundStore refund.Storer
+
+	// WarehouseStore and TransferStore replace the postgres stores when
+	// set. Transfers move stock, so they must share the products.
+	WarehouseStore warehouse.Storer
+	TransferStore  transfer.Storer
+
+	// StocktakeStore replaces the postgres stocktake store when set, it
+	// posts variances so it must share the products.
+	StocktakeStore stocktake.Storer
+
+	// SupplierStore and PurchaseStore replace the postgres stores when
+	// set. Receiving goods moves stock, so they must share the products.
+	SupplierStore supplier.Storer
+	PurchaseStore purchase.Storer
+
+	// PromotionStore replaces the postgres promotion store when set. Orders
+	// redeem coupons, so the order store must share it.
+	PromotionStore promotion.Storer
+
+	// LowStock is told about every sale to look for the products running
+	// low, nothing watches the stock when it is nil. Whoever sets it runs
+	// it.
+	LowStock *inventory.Checker
 }
 
 func APIMux(cfg APIMuxConfig) *httptreemux.ContextMux {
@@ -78,18 +199,280 @@ func v1(app *web.App, cfg APIMuxConfig) {
 		Log: cfg.Log,
 	}
 	app.Handle(http.MethodGet, version, "/test", thg.Test)
-	app.Handle(http.MethodGet, version, "/test/auth", thg.TestAuth, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
+
+	userStorer := cfg.UserStore
+	if userStorer == nil {
+		userStorer = userStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	lockoutStorer := cfg.LockoutStore
+	if lockoutStorer == nil {
+		lockoutStorer = lockoutStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	auditor := cfg.AuditStore
+	if auditor == nil {
+		auditor = auditStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	mfaStorer := cfg.MFAStore
+	if mfaStorer == nil {
+		mfaStorer = mfaStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	roleStorer := cfg.RoleStore
+	if roleStorer == nil {
+		roleStorer = roleStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	apikeyStorer := cfg.APIKeyStore
+	if apikeyStorer == nil {
+		apikeyStorer = apikeyStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	userCore := user.NewCore(cfg.Log, userStorer)
+	lockoutCore := lockout.NewCore(cfg.Log, lockoutStorer, auditor, cfg.Lockout)
+	mfaCore := mfa.NewCore(cfg.Log, mfaStorer, cfg.MFA)
+	roleCore := role.NewCore(cfg.Log, roleStorer)
+	apikeyCore := apikey.NewCore(cfg.Log, apikeyStorer, roleCore, cfg.APIKey)
+
+	// authen accepts bearer tokens and API keys alike.
+	authen := mid.Authenticate(cfg.Auth, apikeyCore)
+
+	app.Handle(http.MethodGet, version, "/test/auth", thg.TestAuth, authen, mid.Authorize(auth.RoleAdmin))
 
 	ugh := v1UserGrp.Handlers{
-		Core: user.NewCore(cfg.Log, cfg.DB),
-		Auth: cfg.Auth,
+		Core:    userCore,
+		Lockout: lockoutCore,
+		MFA:     mfaCore,
+		Roles:   roleCore,
+		Auth:    cfg.Auth,
+		Proxies: cfg.Proxies,
+	}
+
+	app.Handle(http.MethodGet, version, "/users/token", ugh.Token)
+
+	resetStorer := cfg.ResetStore
+	if resetStorer == nil {
+		resetStorer = resetStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	rgh := resetgrp.Handlers{
+		Core: reset.NewCore(cfg.Log, resetStorer, userStorer, cfg.Mailer, cfg.Reset),
+	}
+
+	app.Handle(http.MethodPost, version, "/users/password/forgot", rgh.Forgot)
+	app.Handle(http.MethodPost, version, "/users/password/reset", rgh.Reset)
+
+	mgh := mfagrp.Handlers{
+		MFA:     mfaCore,
+		Users:   userCore,
+		Lockout: lockoutCore,
+		Auth:    cfg.Auth,
+		Proxies: cfg.Proxies,
+	}
+
+	app.Handle(http.MethodPost, version, "/users/token/mfa", mgh.Token)
+	app.Handle(http.MethodPost, version, "/users/me/mfa/enroll", mgh.Enroll, mid.AuthenticatePurpose(cfg.Auth, auth.PurposeMFAEnroll))
+	app.Handle(http.MethodPost, version, "/users/me/mfa/confirm", mgh.Confirm, mid.AuthenticatePurpose(cfg.Auth, auth.PurposeMFAEnroll))
+	app.Handle(http.MethodDelete, version, "/users/me/mfa", mgh.Disable, authen)
+
+	app.Handle(http.MethodGet, version, "/users/me", ugh.QueryMe, authen)
+	app.Handle(http.MethodPatch, version, "/users/me", ugh.UpdateMe, authen)
+	app.Handle(http.MethodPost, version, "/users/me/password", ugh.ChangePassword, authen)
+	app.Handle(http.MethodGet, version, "/users/:page/:rows", ugh.Query, authen, mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodGet, version, "/users/:id", ugh.QueryByID, authen)
+	app.Handle(http.MethodPost, version, "/users", ugh.Create, authen, mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPut, version, "/users/:id", ugh.Update, authen, mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, version, "/users/:id", ugh.Delete, authen, mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, version, "/users/:id/unlock", ugh.Unlock, authen, mid.Authorize(auth.RoleAdmin))
+
+	rlgh := rolegrp.Handlers{
+		Core: roleCore,
+	}
+
+	app.Handle(http.MethodGet, version, "/permissions", rlgh.QueryPermissions, authen, mid.RequirePermission(auth.PermRolesRead))
+	app.Handle(http.MethodGet, version, "/roles", rlgh.Query, authen, mid.RequirePermission(auth.PermRolesRead))
+	app.Handle(http.MethodGet, version, "/roles/:name", rlgh.QueryByName, authen, mid.RequirePermission(auth.PermRolesRead))
+	app.Handle(http.MethodPost, version, "/roles", rlgh.Create, authen, mid.RequirePermission(auth.PermRolesWrite))
+	app.Handle(http.MethodPut, version, "/roles/:name", rlgh.Update, authen, mid.RequirePermission(auth.PermRolesWrite))
+	app.Handle(http.MethodDelete, version, "/roles/:name", rlgh.Delete, authen, mid.RequirePermission(auth.PermRolesWrite))
+
+	akgh := apikeygrp.Handlers{
+		Core: apikeyCore,
+	}
+
+	app.Handle(http.MethodGet, version, "/apikeys", akgh.Query, authen, mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, version, "/apikeys", akgh.Create, authen, mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodPost, version, "/apikeys/:id/rotate", akgh.Rotate, authen, mid.Authorize(auth.RoleAdmin))
+	app.Handle(http.MethodDelete, version, "/apikeys/:id", akgh.Revoke, authen, mid.Authorize(auth.RoleAdmin))
+
+	orderStorer := cfg.OrderStore
+	if orderStorer == nil {
+		orderStorer = orderStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	productStorer := cfg.ProductStore
+	if productStorer == nil {
+		productStorer = productStore.NewStore(cfg.Log, cfg.DB)
 	}
 
-	app.Handle(http.MethodGet, version, "", ugh.Token)
-	app.Handle(http.MethodGet, version, "users/:page/:rows", ugh.Query, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodGet, version, "users/:id", ugh.QueryByID, mid.Authenticate(cfg.Auth))
-	app.Handle(http.MethodPost, version, "users", ugh.Create, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodPut, version, "users/:id", ugh.Update, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
-	app.Handle(http.MethodDelete, version, "users/:id", ugh.Delete, mid.Authenticate(cfg.Auth), mid.Authorize(auth.RoleAdmin))
+	categoryStorer := cfg.CategoryStore
+	if categoryStorer == nil {
+		categoryStorer = categoryStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	cgh := categorygrp.Handlers{
+		Core: category.NewCore(cfg.Log, categoryStorer, productStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/categories", cgh.Query, authen, mid.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodGet, version, "/categories/:id", cgh.QueryByID, authen, mid.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodPost, version, "/categories", cgh.Create, authen, mid.RequirePermission(auth.PermProductsWrite))
+	app.Handle(http.MethodPut, version, "/categories/:id", cgh.Update, authen, mid.RequirePermission(auth.PermProductsWrite))
+	app.Handle(http.MethodDelete, version, "/categories/:id", cgh.Delete, authen, mid.RequirePermission(auth.PermProductsWrite))
+
+	pgh := productgrp.Handlers{
+		Core: product.NewCore(cfg.Log, productStorer, categoryStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/products", pgh.Query, authen, mid.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodGet, version, "/products/facets", pgh.Facets, authen, mid.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodGet, version, "/products/search", pgh.Search, authen, mid.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodGet, version, "/products/:id", pgh.QueryByID, authen, mid.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodPut, version, "/products/:id/category", pgh.UpdateCategory, authen, mid.RequirePermission(auth.PermProductsWrite))
+	app.Handle(http.MethodPut, version, "/products/:id/tags", pgh.UpdateTags, authen, mid.RequirePermission(auth.PermProductsWrite))
+	app.Handle(http.MethodPut, version, "/products/:id/reorder-point", pgh.UpdateReorderPoint, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodGet, version, "/products/:id/variants", pgh.Variants, authen, mid.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodPost, version, "/products/:id/variants", pgh.CreateVariant, authen, mid.RequirePermission(auth.PermProductsWrite))
+	app.Handle(http.MethodGet, version, "/products/:id/stock", pgh.Stock, authen, mid.RequirePermission(auth.PermProductsRead))
+	app.Handle(http.MethodPut, version, "/variants/:id", pgh.UpdateVariant, authen, mid.RequirePermission(auth.PermProductsWrite))
+	app.Handle(http.MethodGet, version, "/tags", pgh.Tags, authen, mid.RequirePermission(auth.PermProductsRead))
+
+	warehouseStorer := cfg.WarehouseStore
+	if warehouseStorer == nil {
+		warehouseStorer = warehouseStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	whgh := warehousegrp.Handlers{
+		Core: warehouse.NewCore(cfg.Log, warehouseStorer, productStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/warehouses", whgh.Query, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodGet, version, "/warehouses/:id", whgh.QueryByID, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodPost, version, "/warehouses", whgh.Create, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPut, version, "/warehouses/:id", whgh.Update, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPut, version, "/warehouses/:id/stock", whgh.SetStock, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/warehouses/:id/adjustments", whgh.Adjust, authen, mid.RequirePermission(auth.PermInventoryWrite))
+
+	transferStorer := cfg.TransferStore
+	if transferStorer == nil {
+		transferStorer = transferStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	tgh := transfergrp.Handlers{
+		Core: transfer.NewCore(cfg.Log, transferStorer, warehouseStorer, productStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/transfers/:page/:rows", tgh.Query, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodGet, version, "/transfers/:id", tgh.QueryByID, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodPost, version, "/transfers", tgh.Create, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/transfers/:id/receive", tgh.Receive, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/transfers/:id/cancel", tgh.Cancel, authen, mid.RequirePermission(auth.PermInventoryWrite))
+
+	stocktakeStorer := cfg.StocktakeStore
+	if stocktakeStorer == nil {
+		stocktakeStorer = stocktakeStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	stgh := stocktakegrp.Handlers{
+		Core: stocktake.NewCore(cfg.Log, stocktakeStorer, warehouseStorer, productStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/stocktakes/:page/:rows", stgh.Query, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodGet, version, "/stocktakes/:id", stgh.QueryByID, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodPost, version, "/stocktakes", stgh.Create, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPut, version, "/stocktakes/:id/counts", stgh.Count, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/stocktakes/:id/post", stgh.Post, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/stocktakes/:id/cancel", stgh.Cancel, authen, mid.RequirePermission(auth.PermInventoryWrite))
+
+	supplierStorer := cfg.SupplierStore
+	if supplierStorer == nil {
+		supplierStorer = supplierStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	sgh := suppliergrp.Handlers{
+		Core: supplier.NewCore(cfg.Log, supplierStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/suppliers", sgh.Query, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodGet, version, "/suppliers/:id", sgh.QueryByID, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodPost, version, "/suppliers", sgh.Create, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPut, version, "/suppliers/:id", sgh.Update, authen, mid.RequirePermission(auth.PermInventoryWrite))
+
+	purchaseStorer := cfg.PurchaseStore
+	if purchaseStorer == nil {
+		purchaseStorer = purchaseStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	pogh := purchasegrp.Handlers{
+		Core: purchase.NewCore(cfg.Log, purchaseStorer, supplierStorer, warehouseStorer, productStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/purchase-orders/:page/:rows", pogh.Query, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodGet, version, "/purchase-orders/:id", pogh.QueryByID, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodPost, version, "/purchase-orders", pogh.Create, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/purchase-orders/:id/submit", pogh.Submit, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/purchase-orders/:id/receive", pogh.Receive, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/purchase-orders/:id/close", pogh.Close, authen, mid.RequirePermission(auth.PermInventoryWrite))
+	app.Handle(http.MethodPost, version, "/purchase-orders/:id/cancel", pogh.Cancel, authen, mid.RequirePermission(auth.PermInventoryWrite))
+
+	igh := inventorygrp.Handlers{
+		Core: inventory.NewCore(cfg.Log, productStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/inventory/movements/:page/:rows", igh.Movements, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodGet, version, "/inventory/verify", igh.Verify, authen, mid.RequirePermission(auth.PermInventoryRead))
+	app.Handle(http.MethodGet, version, "/inventory/low-stock", igh.LowStock, authen, mid.RequirePermission(auth.PermInventoryRead))
+
+	promotionStorer := cfg.PromotionStore
+	if promotionStorer == nil {
+		promotionStorer = promotionStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	prmgh := promotiongrp.Handlers{
+		Core: promotion.NewCore(cfg.Log, promotionStorer, productStorer, categoryStorer),
+	}
+
+	app.Handle(http.MethodGet, version, "/coupons/:page/:rows", prmgh.QueryCoupons, authen, mid.RequirePermission(auth.PermPromotionsRead))
+	app.Handle(http.MethodPost, version, "/coupons", prmgh.CreateCoupon, authen, mid.RequirePermission(auth.PermPromotionsWrite))
+	app.Handle(http.MethodGet, version, "/promotions/:page/:rows", prmgh.QueryPromotions, authen, mid.RequirePermission(auth.PermPromotionsRead))
+	app.Handle(http.MethodPost, version, "/promotions", prmgh.CreatePromotion, authen, mid.RequirePermission(auth.PermPromotionsWrite))
+
+	var watcher order.StockWatcher
+	if cfg.LowStock != nil {
+		watcher = cfg.LowStock
+	}
+
+	ogh := ordergrp.Handlers{
+		Core: order.NewCore(cfg.Log, orderStorer, productStorer, warehouseStorer, prmgh.Core, watcher),
+	}
+
+	// Orders are placed for the caller, API keys have no user to buy for.
+	app.Handle(http.MethodPost, version, "/orders", ogh.Create, authen, mid.Authorize(auth.RoleUser))
+	app.Handle(http.MethodGet, version, "/orders/:id", ogh.QueryByID, authen)
+	app.Handle(http.MethodGet, version, "/users/:id/orders/:page/:rows", ogh.QueryByCustomer, authen)
+	app.Handle(http.MethodGet, version, "/reports/margins", ogh.Margins, authen, mid.RequirePermission(auth.PermReportsRead))
+
+	refundStorer := cfg.RefundStore
+	if refundStorer == nil {
+		refundStorer = refundStore.NewStore(cfg.Log, cfg.DB)
+	}
+
+	rfgh := refundgrp.Handlers{
+		Core: refund.NewCore(cfg.Log, refundStorer, orderStorer, auditor),
+	}
 
+	app.Handle(http.MethodPost, version, "/orders/:id/refunds", rfgh.Create, authen, mid.RequirePermission(auth.PermSalesRefund))
+	app.Handle(http.MethodGet, version, "/orders/:id/refunds", rfgh.QueryByOrder, authen)
 }
@@ -0,0 +1,110 @@
+package apikeygrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"service/domain/core/apikey"
+	apikeyStore "service/domain/data/store/apikey"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+)
+
+type Handlers struct {
+	Core apikey.Core
+}
+
+// Query returns every key, the secrets are never part of it.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	keys, err := h.Core.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query api keys: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, keys)
+}
+
+// Create issues a key. The response is the only time the key is shown.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var nk apikeyStore.NewKey
+	if err := web.Decode(r, &nk); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	iss, err := h.Core.Create(ctx, claims, nk, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case apikey.ErrUnknownScope, apikey.ErrInvalidExpiration:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Key[%+v] %w", &nk, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, iss)
+}
+
+// Rotate issues a replacement for a key, the old one keeps working for
+// the configured overlap.
+func (h Handlers) Rotate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	id := web.Param(r, "id")
+	iss, err := h.Core.Rotate(ctx, claims, id, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case apikey.ErrExpired:
+			return validate.NewRequestError(apikey.ErrExpired, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, iss)
+}
+
+// Revoke stops a key from working right away.
+func (h Handlers) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	id := web.Param(r, "id")
+	if err := h.Core.Revoke(ctx, id, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,117 @@
+package categorygrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"service/domain/core/category"
+	categoryStore "service/domain/data/store/category"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+)
+
+type Handlers struct {
+	Core category.Core
+}
+
+// Query returns the tree of categories.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	tree, err := h.Core.Tree(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query categories: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, tree)
+}
+
+// QueryByID returns a single category.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	cat, err := h.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, cat)
+}
+
+// Create adds a category.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var nc categoryStore.NewCategory
+	if err := web.Decode(r, &nc); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	cat, err := h.Core.Create(ctx, nc, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case category.ErrInvalidSlug, category.ErrUnknownParent:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case categoryStore.ErrUniqueSlug:
+			return validate.NewRequestError(categoryStore.ErrUniqueSlug, http.StatusConflict)
+		default:
+			return fmt.Errorf("Category[%+v] %w", &nc, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, cat)
+}
+
+// Update renames a category or moves it below another parent.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var uc categoryStore.UpdateCategory
+	if err := web.Decode(r, &uc); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	cat, err := h.Core.Update(ctx, id, uc, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, category.ErrInvalidSlug, category.ErrUnknownParent, category.ErrCycle:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case categoryStore.ErrUniqueSlug:
+			return validate.NewRequestError(categoryStore.ErrUniqueSlug, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Category[%+v] %w", id, &uc, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, cat)
+}
+
+// Delete removes an empty category.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	if err := h.Core.Delete(ctx, id); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case category.ErrNotEmpty:
+			return validate.NewRequestError(category.ErrNotEmpty, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
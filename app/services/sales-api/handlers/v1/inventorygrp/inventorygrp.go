@@ -0,0 +1,65 @@
+package inventorygrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"service/domain/core/inventory"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+	"strconv"
+)
+
+type Handlers struct {
+	Core inventory.Core
+}
+
+// Movements returns a page of the inventory ledger, latest first, filtered
+// by ?warehouse_id= and ?product_id= when they are given.
+func (h Handlers) Movements(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pageNum, err := strconv.Atoi(web.Param(r, "page"))
+	if err != nil || pageNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid page format [%s]", web.Param(r, "page")), http.StatusBadRequest)
+	}
+
+	rowNum, err := strconv.Atoi(web.Param(r, "rows"))
+	if err != nil || rowNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", web.Param(r, "rows")), http.StatusBadRequest)
+	}
+
+	f := product.MovementFilter{
+		WarehouseID: r.URL.Query().Get("warehouse_id"),
+		ProductID:   r.URL.Query().Get("product_id"),
+	}
+
+	movements, err := h.Core.Movements(ctx, f, pageNum, rowNum)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Filter[%+v] %w", f, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, movements)
+}
+
+// Verify returns the levels that do not match the ledger.
+func (h Handlers) Verify(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ds, err := h.Core.Verify(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to verify the ledger: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, ds)
+}
+
+// LowStock returns the products holding no more than their reorder point.
+func (h Handlers) LowStock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	lows, err := h.Core.LowStock(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query low stock: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, lows)
+}
@@ -0,0 +1,217 @@
+package mfagrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"service/domain/core/lockout"
+	"service/domain/core/mfa"
+	userCore "service/domain/core/user"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+	"strconv"
+)
+
+// errChallenge is the answer to any challenge that can not be used.
+var errChallenge = errors.New("invalid or expired challenge")
+
+type Handlers struct {
+	MFA     mfa.Core
+	Users   userCore.Core
+	Lockout lockout.Core
+	Auth    *auth.Auth
+
+	// Proxies are trusted to tell the address logins come from.
+	Proxies web.Proxies
+}
+
+// Enroll starts setting up an authenticator app for the calling user.
+func (h Handlers) Enroll(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	usr, err := h.Users.QueryMe(ctx, claims)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+
+	enr, err := h.MFA.Enroll(ctx, usr.ID, usr.Email, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case mfa.ErrAlreadyEnabled:
+			return validate.NewRequestError(mfa.ErrAlreadyEnabled, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, enr)
+}
+
+// Confirm enables the second factor and hands out the recovery codes.
+func (h Handlers) Confirm(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var code mfa.Code
+	if err := web.Decode(r, &code); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	codes, err := h.MFA.Confirm(ctx, claims.Subject, code.Code, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case mfa.ErrInvalidCode:
+			return validate.NewRequestError(mfa.ErrInvalidCode, http.StatusBadRequest)
+		case mfa.ErrNotEnrolled:
+			return validate.NewRequestError(mfa.ErrNotEnrolled, http.StatusConflict)
+		case mfa.ErrAlreadyEnabled:
+			return validate.NewRequestError(mfa.ErrAlreadyEnabled, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+
+	resp := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	}
+	return web.Respond(ctx, w, http.StatusOK, resp)
+}
+
+// Disable removes the second factor of the calling user.
+func (h Handlers) Disable(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var code mfa.Code
+	if err := web.Decode(r, &code); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	if err := h.MFA.Disable(ctx, claims, code.Code, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case mfa.ErrRequired:
+			return validate.NewRequestError(mfa.ErrRequired, http.StatusForbidden)
+		case mfa.ErrInvalidCode:
+			return validate.NewRequestError(mfa.ErrInvalidCode, http.StatusForbidden)
+		case mfa.ErrNotEnrolled:
+			return validate.NewRequestError(mfa.ErrNotEnrolled, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
+
+// Token exchanges the challenge handed out for a password and a code from
+// the second factor for a token. Wrong codes count as failed logins.
+func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var req struct {
+		Challenge string `json:"challenge" validate:"required"`
+		Code      string `json:"code" validate:"required"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	if err := validate.Check(req); err != nil {
+		return err
+	}
+
+	claims, err := h.Auth.ValidatePurpose(req.Challenge, auth.PurposeMFA)
+	if err != nil {
+		return validate.NewRequestError(errChallenge, http.StatusUnauthorized)
+	}
+
+	usr, err := h.Users.QueryMe(ctx, claims)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(errChallenge, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+
+	ip := h.Proxies.ClientIP(r)
+
+	retry, err := h.Lockout.Check(ctx, usr.Email, ip, v.Now)
+	if err != nil {
+		if errors.Is(err, lockout.ErrLocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			return validate.NewRequestError(err, http.StatusTooManyRequests)
+		}
+		return fmt.Errorf("checking lockout: %w", err)
+	}
+
+	if err := h.MFA.Verify(ctx, usr.ID, req.Code, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case mfa.ErrInvalidCode, mfa.ErrNotEnrolled:
+			if err := h.Lockout.Failed(ctx, usr.Email, ip, v.Now); err != nil {
+				return fmt.Errorf("recording failed login: %w", err)
+			}
+			return validate.NewRequestError(mfa.ErrInvalidCode, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+
+	if err := h.Lockout.Succeeded(ctx, usr.Email); err != nil {
+		return fmt.Errorf("clearing failed logins: %w", err)
+	}
+
+	// The challenge expires sooner than a token, auth sets the regular
+	// lifetime when the expiry is left empty.
+	claims.Purpose = ""
+	claims.IssuedAt = v.Now.Unix()
+	claims.ExpiresAt = 0
+
+	var tkn struct {
+		Token string `json:"token"`
+	}
+	tkn.Token, err = h.Auth.GenerateToken(claims)
+	if err != nil {
+		return fmt.Errorf("generating token  %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, tkn)
+}
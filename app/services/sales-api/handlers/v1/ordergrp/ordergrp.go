@@ -0,0 +1,162 @@
+package ordergrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"service/domain/core/order"
+	"service/domain/core/promotion"
+	orderStore "service/domain/data/store/order"
+	"service/domain/data/store/product"
+	promotionStore "service/domain/data/store/promotion"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"service/foundation/web"
+	"strconv"
+	"time"
+)
+
+type Handlers struct {
+	Core order.Core
+}
+
+// Create places an order for the calling user.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var no orderStore.NewOrder
+	if err := web.Decode(r, &no); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	o, err := h.Core.Create(ctx, claims, no, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case order.ErrUnknownProduct, order.ErrUnknownVariant, order.ErrNeedsVariant, order.ErrUnknownWarehouse, promotion.ErrUnknownCoupon,
+			money.ErrMismatch, money.ErrOverflow:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrInsufficientStock, promotionStore.ErrCouponUnavailable:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("Order[%+v] %w", &no, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, o)
+}
+
+// QueryByID returns an order along with its items.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	id := web.Param(r, "id")
+	o, err := h.Core.QueryByID(ctx, claims, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(database.ErrForbidden, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, o)
+}
+
+// QueryByCustomer returns a page of the orders of a customer, latest
+// first.
+func (h Handlers) QueryByCustomer(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	pageNum, err := strconv.Atoi(web.Param(r, "page"))
+	if err != nil || pageNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid page format [%s]", web.Param(r, "page")), http.StatusBadRequest)
+	}
+
+	rowNum, err := strconv.Atoi(web.Param(r, "rows"))
+	if err != nil || rowNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", web.Param(r, "rows")), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	orders, err := h.Core.QueryByCustomer(ctx, claims, id, pageNum, rowNum)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrForbidden:
+			return validate.NewRequestError(database.ErrForbidden, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, orders)
+}
+
+// Margins reports what every product sold for against what it cost us,
+// over the orders placed from ?from= up to ?to=, both dates as 2006-01-02
+// and either left out to leave the period open.
+func (h Handlers) Margins(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	from, err := queryDate(r, "from")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	to, err := queryDate(r, "to")
+	if err != nil {
+		return validate.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	f := orderStore.MarginFilter{
+		From: from,
+		To:   to,
+	}
+
+	margins, err := h.Core.Margins(ctx, f)
+	if err != nil {
+		switch validate.Cause(err) {
+		case order.ErrInvalidPeriod:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Filter[%+v] %w", f, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, margins)
+}
+
+// queryDate parses the date of the query parameter, nil when it is not
+// given.
+func queryDate(r *http.Request, name string) (*time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s format [%s]", name, v)
+	}
+	return &t, nil
+}
@@ -0,0 +1,332 @@
+package productgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"service/domain/core/product"
+	productStore "service/domain/data/store/product"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+	"strconv"
+)
+
+// Paging used when the query string leaves it out.
+const (
+	defaultRows = 50
+	maxRows     = 500
+)
+
+// Number of matches a search returns when the query string leaves it out.
+const (
+	defaultMatches = 20
+	maxMatches     = 100
+)
+
+type Handlers struct {
+	Core product.Core
+}
+
+// Query returns a page of the catalog. The category and tag query
+// parameters filter it, tag can be given several times.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pageNum, rowNum, err := paging(r)
+	if err != nil {
+		return err
+	}
+
+	qf := filter(r)
+	prds, err := h.Core.Query(ctx, qf, pageNum, rowNum)
+	if err != nil {
+		switch validate.Cause(err) {
+		case product.ErrUnknownCategory, product.ErrInvalidTag:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Filter[%+v] %w", qf, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, prds)
+}
+
+// Facets counts the products matching the same filters as Query under
+// every category.
+func (h Handlers) Facets(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	qf := filter(r)
+	facets, err := h.Core.Facets(ctx, qf)
+	if err != nil {
+		switch validate.Cause(err) {
+		case product.ErrUnknownCategory, product.ErrInvalidTag:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Filter[%+v] %w", qf, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, facets)
+}
+
+// Search finds products by the start of the words of their name, as typed
+// in q. The limit query parameter caps the number of matches.
+func (h Handlers) Search(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+
+	limit := defaultMatches
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxMatches {
+			return validate.NewRequestError(fmt.Errorf("invalid limit format [%s]", s), http.StatusBadRequest)
+		}
+		limit = n
+	}
+
+	query := q.Get("q")
+	matches, err := h.Core.Search(ctx, query, limit)
+	if err != nil {
+		switch validate.Cause(err) {
+		case product.ErrEmptySearch:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Query[%s] %w", query, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, matches)
+}
+
+// QueryByID returns a single product.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	p, err := h.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, p)
+}
+
+// Tags returns every tag in use with the number of products carrying it.
+func (h Handlers) Tags(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	tags, err := h.Core.Tags(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query tags: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, tags)
+}
+
+// UpdateCategory files a product under a category.
+func (h Handlers) UpdateCategory(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var uc productStore.UpdateCategory
+	if err := web.Decode(r, &uc); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	p, err := h.Core.UpdateCategory(ctx, id, uc, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, product.ErrUnknownCategory:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] Category[%+v] %w", id, &uc, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, p)
+}
+
+// UpdateReorderPoint sets the stock a product is reordered at.
+func (h Handlers) UpdateReorderPoint(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var ur productStore.UpdateReorderPoint
+	if err := web.Decode(r, &ur); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	p, err := h.Core.UpdateReorderPoint(ctx, id, ur, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] ReorderPoint[%+v] %w", id, &ur, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, p)
+}
+
+// UpdateTags replaces the tags of a product.
+func (h Handlers) UpdateTags(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var ut productStore.UpdateTags
+	if err := web.Decode(r, &ut); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	p, err := h.Core.UpdateTags(ctx, id, ut, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, product.ErrInvalidTag:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] Tags[%+v] %w", id, &ut, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, p)
+}
+
+// filter reads the category and tags to filter by from the query string.
+func filter(r *http.Request) product.QueryFilter {
+	q := r.URL.Query()
+	return product.QueryFilter{
+		Category: q.Get("category"),
+		Tags:     q["tag"],
+	}
+}
+
+// paging reads the page and rows from the query string, the first page of
+// defaultRows products when left out.
+func paging(r *http.Request) (int, int, error) {
+	q := r.URL.Query()
+
+	pageNum := 1
+	if s := q.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, 0, validate.NewRequestError(fmt.Errorf("invalid page format [%s]", s), http.StatusBadRequest)
+		}
+		pageNum = n
+	}
+
+	rowNum := defaultRows
+	if s := q.Get("rows"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxRows {
+			return 0, 0, validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", s), http.StatusBadRequest)
+		}
+		rowNum = n
+	}
+
+	return pageNum, rowNum, nil
+}
+
+// Variants returns the variants of a product.
+func (h Handlers) Variants(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	vars, err := h.Core.Variants(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, vars)
+}
+
+// CreateVariant adds a variant to a product.
+func (h Handlers) CreateVariant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var nv productStore.NewVariant
+	if err := web.Decode(r, &nv); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	vrt, err := h.Core.CreateVariant(ctx, id, nv, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, product.ErrInvalidSKU:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case productStore.ErrUniqueSKU:
+			return validate.NewRequestError(productStore.ErrUniqueSKU, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Variant[%+v] %w", id, &nv, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, vrt)
+}
+
+// UpdateVariant changes a variant.
+func (h Handlers) UpdateVariant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var uv productStore.UpdateVariant
+	if err := web.Decode(r, &uv); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	vrt, err := h.Core.UpdateVariant(ctx, id, uv, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, product.ErrInvalidSKU:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case productStore.ErrUniqueSKU:
+			return validate.NewRequestError(productStore.ErrUniqueSKU, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Variant[%+v] %w", id, &uv, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, vrt)
+}
+
+// Stock reports the stock of a product, per variant and in total.
+func (h Handlers) Stock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	stock, err := h.Core.Stock(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, stock)
+}
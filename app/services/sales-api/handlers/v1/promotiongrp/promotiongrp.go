@@ -0,0 +1,111 @@
+package promotiongrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"service/domain/core/promotion"
+	promotionStore "service/domain/data/store/promotion"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+	"strconv"
+)
+
+type Handlers struct {
+	Core promotion.Core
+}
+
+// CreateCoupon adds a coupon customers can give when placing an order.
+func (h Handlers) CreateCoupon(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var nc promotionStore.NewCoupon
+	if err := web.Decode(r, &nc); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	cp, err := h.Core.CreateCoupon(ctx, nc, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case promotion.ErrInvalidDiscount:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case promotionStore.ErrUniqueCode:
+			return validate.NewRequestError(promotionStore.ErrUniqueCode, http.StatusConflict)
+		default:
+			return fmt.Errorf("Coupon[%+v] %w", &nc, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, cp)
+}
+
+// QueryCoupons returns a page of the coupons.
+func (h Handlers) QueryCoupons(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pageNum, rowNum, err := paging(r)
+	if err != nil {
+		return err
+	}
+
+	coupons, err := h.Core.QueryCoupons(ctx, pageNum, rowNum)
+	if err != nil {
+		return fmt.Errorf("unable to query coupons: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, coupons)
+}
+
+// CreatePromotion adds a promotion on a product or a category.
+func (h Handlers) CreatePromotion(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var np promotionStore.NewPromotion
+	if err := web.Decode(r, &np); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	p, err := h.Core.CreatePromotion(ctx, np, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case promotion.ErrInvalidDiscount, promotion.ErrInvalidTarget, promotion.ErrInvalidPeriod,
+			promotion.ErrUnknownProduct, promotion.ErrUnknownCategory:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Promotion[%+v] %w", &np, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, p)
+}
+
+// QueryPromotions returns a page of the promotions.
+func (h Handlers) QueryPromotions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pageNum, rowNum, err := paging(r)
+	if err != nil {
+		return err
+	}
+
+	promos, err := h.Core.QueryPromotions(ctx, pageNum, rowNum)
+	if err != nil {
+		return fmt.Errorf("unable to query promotions: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, promos)
+}
+
+// paging reads the page and rows from the path.
+func paging(r *http.Request) (int, int, error) {
+	pageNum, err := strconv.Atoi(web.Param(r, "page"))
+	if err != nil || pageNum < 1 {
+		return 0, 0, validate.NewRequestError(fmt.Errorf("invalid page format [%s]", web.Param(r, "page")), http.StatusBadRequest)
+	}
+
+	rowNum, err := strconv.Atoi(web.Param(r, "rows"))
+	if err != nil || rowNum < 1 {
+		return 0, 0, validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", web.Param(r, "rows")), http.StatusBadRequest)
+	}
+	return pageNum, rowNum, nil
+}
@@ -0,0 +1,176 @@
+package purchasegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"service/domain/core/purchase"
+	purchaseStore "service/domain/data/store/purchase"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"service/foundation/web"
+	"strconv"
+	"time"
+)
+
+type Handlers struct {
+	Core purchase.Core
+}
+
+// Create raises a draft purchase order with a supplier.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var no purchaseStore.NewOrder
+	if err := web.Decode(r, &no); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	o, err := h.Core.Create(ctx, claims, no, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case purchase.ErrUnknownSupplier, purchase.ErrUnknownWarehouse, purchase.ErrUnknownProduct, purchase.ErrUnknownVariant,
+			purchase.ErrNeedsVariant, purchase.ErrDuplicateLine, money.ErrMismatch, money.ErrOverflow:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("PurchaseOrder[%+v] %w", &no, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, o)
+}
+
+// Submit sends a draft purchase order to its supplier.
+func (h Handlers) Submit(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.transition(ctx, w, r, h.Core.Submit)
+}
+
+// Cancel calls off a purchase order nothing came in for.
+func (h Handlers) Cancel(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.transition(ctx, w, r, h.Core.Cancel)
+}
+
+// Close stops waiting for the rest of a partially received purchase order.
+func (h Handlers) Close(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.transition(ctx, w, r, h.Core.Close)
+}
+
+// transition moves a purchase order to another status through the core
+// method.
+func (h Handlers) transition(ctx context.Context, w http.ResponseWriter, r *http.Request, move func(context.Context, string, time.Time) (purchaseStore.Order, error)) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	id := web.Param(r, "id")
+	o, err := move(ctx, id, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case purchase.ErrInvalidTransition, purchaseStore.ErrStatusChanged:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, o)
+}
+
+// Receive takes goods of a purchase order into stock, some of them or
+// everything outstanding.
+func (h Handlers) Receive(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var nr purchaseStore.NewReceipt
+	if err := web.Decode(r, &nr); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	o, err := h.Core.Receive(ctx, claims, id, nr, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, purchase.ErrUnknownLine, purchase.ErrDuplicateLine:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case purchase.ErrInvalidTransition, purchase.ErrNothingToReceive, purchaseStore.ErrNotReceivable, purchaseStore.ErrExceedsOrdered:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Receipt[%+v] %w", id, &nr, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, o)
+}
+
+// QueryByID returns a purchase order along with its lines.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	o, err := h.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, o)
+}
+
+// Query returns a page of the purchase orders, latest first, filtered by
+// ?status= and ?supplier_id= when they are given.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pageNum, err := strconv.Atoi(web.Param(r, "page"))
+	if err != nil || pageNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid page format [%s]", web.Param(r, "page")), http.StatusBadRequest)
+	}
+
+	rowNum, err := strconv.Atoi(web.Param(r, "rows"))
+	if err != nil || rowNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", web.Param(r, "rows")), http.StatusBadRequest)
+	}
+
+	f := purchaseStore.Filter{
+		Status:     r.URL.Query().Get("status"),
+		SupplierID: r.URL.Query().Get("supplier_id"),
+	}
+
+	orders, err := h.Core.Query(ctx, f, pageNum, rowNum)
+	if err != nil {
+		switch validate.Cause(err) {
+		case purchase.ErrInvalidStatus, database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Filter[%+v] %w", f, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, orders)
+}
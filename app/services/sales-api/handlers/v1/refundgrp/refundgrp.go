@@ -0,0 +1,81 @@
+package refundgrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"service/domain/core/refund"
+	refundStore "service/domain/data/store/refund"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+)
+
+type Handlers struct {
+	Core refund.Core
+}
+
+// Create refunds lines of an order, the caller is recorded as approving
+// it.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var nr refundStore.NewRefund
+	if err := web.Decode(r, &nr); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	ref, err := h.Core.Create(ctx, claims, id, nr, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case refund.ErrUnknownLine, refund.ErrDuplicateLine, refund.ErrNothingToRefund, refund.ErrRestock:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case refundStore.ErrExceedsPaid:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Refund[%+v] %w", id, &nr, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, ref)
+}
+
+// QueryByOrder returns the refunds of an order.
+func (h Handlers) QueryByOrder(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	id := web.Param(r, "id")
+	refunds, err := h.Core.QueryByOrder(ctx, claims, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(database.ErrForbidden, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, refunds)
+}
@@ -0,0 +1,65 @@
+package resetgrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"service/domain/core/reset"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+)
+
+type Handlers struct {
+	Core reset.Core
+}
+
+// Forgot mails a reset token. It answers 202 whether the email is known
+// or not.
+func (h Handlers) Forgot(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var fp reset.ForgotPassword
+	if err := web.Decode(r, &fp); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	if err := h.Core.Forgot(ctx, fp, v.Now); err != nil {
+		return fmt.Errorf("forgot password: %w", err)
+	}
+
+	resp := struct {
+		Status string `json:"status"`
+	}{
+		Status: "if the email belongs to an account a reset link is on its way",
+	}
+	return web.Respond(ctx, w, http.StatusAccepted, resp)
+}
+
+// Reset sets a new password with a mailed token.
+func (h Handlers) Reset(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var rp reset.ResetPassword
+	if err := web.Decode(r, &rp); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	if err := h.Core.Reset(ctx, rp, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case reset.ErrInvalidToken:
+			return validate.NewRequestError(reset.ErrInvalidToken, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("reset password: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
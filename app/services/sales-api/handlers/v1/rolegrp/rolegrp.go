@@ -0,0 +1,120 @@
+package rolegrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"service/domain/core/role"
+	roleStore "service/domain/data/store/role"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+)
+
+type Handlers struct {
+	Core role.Core
+}
+
+// Query returns every role with the permissions it grants.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	roles, err := h.Core.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query roles: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, roles)
+}
+
+// QueryByName returns a single role.
+func (h Handlers) QueryByName(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+	rl, err := h.Core.QueryByName(ctx, name)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("Name[%s] %w", name, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, rl)
+}
+
+// Create adds a role.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var nr roleStore.NewRole
+	if err := web.Decode(r, &nr); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	rl, err := h.Core.Create(ctx, nr, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case role.ErrUnknownPermission:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case roleStore.ErrUniqueName:
+			return validate.NewRequestError(roleStore.ErrUniqueName, http.StatusConflict)
+		default:
+			return fmt.Errorf("Role[%+v] %w", &nr, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, rl)
+}
+
+// Update changes the description of a role or replaces its permissions.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var ur roleStore.UpdateRole
+	if err := web.Decode(r, &ur); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	name := web.Param(r, "name")
+	rl, err := h.Core.Update(ctx, name, ur, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case role.ErrUnknownPermission:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("Name[%s] Role[%+v] %w", name, &ur, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, rl)
+}
+
+// Delete removes a role.
+func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	name := web.Param(r, "name")
+	if err := h.Core.Delete(ctx, name); err != nil {
+		switch validate.Cause(err) {
+		case role.ErrBuiltIn:
+			return validate.NewRequestError(role.ErrBuiltIn, http.StatusConflict)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("Name[%s] %w", name, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
+
+// QueryPermissions returns every permission a role can grant.
+func (h Handlers) QueryPermissions(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	perms, err := h.Core.QueryPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query permissions: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, perms)
+}
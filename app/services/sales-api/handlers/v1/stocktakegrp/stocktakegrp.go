@@ -0,0 +1,159 @@
+package stocktakegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"service/domain/core/stocktake"
+	"service/domain/data/store/product"
+	stocktakeStore "service/domain/data/store/stocktake"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+	"strconv"
+	"time"
+)
+
+type Handlers struct {
+	Core stocktake.Core
+}
+
+// Create opens a stocktake of a warehouse.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var ns stocktakeStore.NewStocktake
+	if err := web.Decode(r, &ns); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	st, err := h.Core.Create(ctx, claims, ns, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case stocktake.ErrUnknownWarehouse:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Stocktake[%+v] %w", &ns, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, st)
+}
+
+// Count records counts taken during an open stocktake.
+func (h Handlers) Count(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var nc stocktakeStore.NewCounts
+	if err := web.Decode(r, &nc); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	st, err := h.Core.Count(ctx, id, nc, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, stocktake.ErrUnknownProduct, stocktake.ErrUnknownVariant, stocktake.ErrNeedsVariant:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case stocktakeStore.ErrNotOpen:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Counts[%+v] %w", id, &nc, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, st)
+}
+
+// Post posts the variances of a stocktake to the inventory ledger.
+func (h Handlers) Post(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.close(ctx, w, r, h.Core.Post)
+}
+
+// Cancel closes a stocktake without posting anything.
+func (h Handlers) Cancel(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.close(ctx, w, r, h.Core.Cancel)
+}
+
+// close closes an open stocktake through the core method.
+func (h Handlers) close(ctx context.Context, w http.ResponseWriter, r *http.Request, close func(context.Context, string, time.Time) (stocktakeStore.Stocktake, error)) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	id := web.Param(r, "id")
+	st, err := close(ctx, id, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case stocktakeStore.ErrNotOpen, product.ErrInsufficientStock:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, st)
+}
+
+// QueryByID returns a stocktake along with its lines.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	st, err := h.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, st)
+}
+
+// Query returns a page of the stocktakes, latest first, filtered by
+// ?status= when it is given.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pageNum, err := strconv.Atoi(web.Param(r, "page"))
+	if err != nil || pageNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid page format [%s]", web.Param(r, "page")), http.StatusBadRequest)
+	}
+
+	rowNum, err := strconv.Atoi(web.Param(r, "rows"))
+	if err != nil || rowNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", web.Param(r, "rows")), http.StatusBadRequest)
+	}
+
+	status := r.URL.Query().Get("status")
+	stocktakes, err := h.Core.Query(ctx, status, pageNum, rowNum)
+	if err != nil {
+		switch validate.Cause(err) {
+		case stocktake.ErrInvalidStatus:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Status[%s] %w", status, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, stocktakes)
+}
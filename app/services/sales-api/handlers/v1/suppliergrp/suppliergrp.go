@@ -0,0 +1,97 @@
+package suppliergrp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"service/domain/core/supplier"
+	supplierStore "service/domain/data/store/supplier"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+)
+
+type Handlers struct {
+	Core supplier.Core
+}
+
+// Query returns every supplier.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	sps, err := h.Core.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query suppliers: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, sps)
+}
+
+// QueryByID returns a single supplier.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	sp, err := h.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, sp)
+}
+
+// Create adds a supplier.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var ns supplierStore.NewSupplier
+	if err := web.Decode(r, &ns); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	sp, err := h.Core.Create(ctx, ns, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case supplierStore.ErrUniqueName:
+			return validate.NewRequestError(supplierStore.ErrUniqueName, http.StatusConflict)
+		default:
+			return fmt.Errorf("Supplier[%+v] %w", &ns, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, sp)
+}
+
+// Update changes the name or contact details of a supplier.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var us supplierStore.UpdateSupplier
+	if err := web.Decode(r, &us); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	sp, err := h.Core.Update(ctx, id, us, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case supplierStore.ErrUniqueName:
+			return validate.NewRequestError(supplierStore.ErrUniqueName, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Supplier[%+v] %w", id, &us, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, sp)
+}
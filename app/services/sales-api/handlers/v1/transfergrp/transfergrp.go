@@ -0,0 +1,131 @@
+package transfergrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"service/domain/core/transfer"
+	"service/domain/data/store/product"
+	transferStore "service/domain/data/store/transfer"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+	"strconv"
+	"time"
+)
+
+type Handlers struct {
+	Core transfer.Core
+}
+
+// Create ships stock from one warehouse to another.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var nt transferStore.NewTransfer
+	if err := web.Decode(r, &nt); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	t, err := h.Core.Create(ctx, claims, nt, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case transfer.ErrUnknownWarehouse, transfer.ErrUnknownProduct, transfer.ErrUnknownVariant, transfer.ErrNeedsVariant:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrInsufficientStock:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("Transfer[%+v] %w", &nt, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, t)
+}
+
+// Receive puts the stock of a transfer into its destination.
+func (h Handlers) Receive(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.close(ctx, w, r, h.Core.Receive)
+}
+
+// Cancel puts the stock of a transfer back into its source.
+func (h Handlers) Cancel(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.close(ctx, w, r, h.Core.Cancel)
+}
+
+// close takes a transfer out of transit through the core method.
+func (h Handlers) close(ctx context.Context, w http.ResponseWriter, r *http.Request, close func(context.Context, string, time.Time) (transferStore.Transfer, error)) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	id := web.Param(r, "id")
+	t, err := close(ctx, id, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case transferStore.ErrNotInTransit:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, t)
+}
+
+// QueryByID returns a transfer along with its items.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	t, err := h.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, t)
+}
+
+// Query returns a page of the transfers, latest first, filtered by
+// ?status= when it is given.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	pageNum, err := strconv.Atoi(web.Param(r, "page"))
+	if err != nil || pageNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid page format [%s]", web.Param(r, "page")), http.StatusBadRequest)
+	}
+
+	rowNum, err := strconv.Atoi(web.Param(r, "rows"))
+	if err != nil || rowNum < 1 {
+		return validate.NewRequestError(fmt.Errorf("invalid rows format [%s]", web.Param(r, "rows")), http.StatusBadRequest)
+	}
+
+	status := r.URL.Query().Get("status")
+	transfers, err := h.Core.Query(ctx, status, pageNum, rowNum)
+	if err != nil {
+		switch validate.Cause(err) {
+		case transfer.ErrInvalidStatus:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("Status[%s] %w", status, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, transfers)
+}
@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"service/domain/core/lockout"
+	"service/domain/core/mfa"
+	"service/domain/core/role"
 	userCore "service/domain/core/user"
 	"service/domain/data/store/user"
 	"service/domain/sys/auth"
@@ -12,11 +16,22 @@ import (
 	"service/domain/sys/validate"
 	"service/foundation/web"
 	"strconv"
+	"time"
 )
 
+// errBadCredentials is the one answer to a failed login, whether the email
+// is unknown, the password wrong or the account disabled.
+var errBadCredentials = errors.New("invalid email or password")
+
 type Handlers struct {
-	Core userCore.Core
-	Auth *auth.Auth
+	Core    userCore.Core
+	Lockout lockout.Core
+	MFA     mfa.Core
+	Roles   role.Core
+	Auth    *auth.Auth
+
+	// Proxies are trusted to tell the address logins come from.
+	Proxies web.Proxies
 }
 
 func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -73,12 +88,15 @@ func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	var nu user.NewUser
-	if err := web.Decode(r, nu); err != nil {
+	if err := web.Decode(r, &nu); err != nil {
 		return fmt.Errorf("unable to decode payload  %w", err)
 	}
 
 	usr, err := h.Core.Create(ctx, nu, v.Now)
 	if err != nil {
+		if errors.Is(err, user.ErrUniqueEmail) {
+			return validate.NewRequestError(err, http.StatusConflict)
+		}
 		return fmt.Errorf("user %+v %w", &usr, err)
 	}
 	return web.Respond(ctx, w, http.StatusCreated, usr)
@@ -97,7 +115,7 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 	}
 
 	var upd user.UpdateUser
-	if err := web.Decode(r, upd); err != nil {
+	if err := web.Decode(r, &upd); err != nil {
 		return fmt.Errorf("unable to decode payload  %w", err)
 	}
 
@@ -111,6 +129,8 @@ func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Req
 			return validate.NewRequestError(err, http.StatusNotFound)
 		case database.ErrForbidden:
 			return validate.NewRequestError(err, http.StatusForbidden)
+		case user.ErrUniqueEmail:
+			return validate.NewRequestError(err, http.StatusConflict)
 		default:
 			return fmt.Errorf("ID[%s] User[%+v] %w", id, &upd, err)
 		}
@@ -137,7 +157,7 @@ func (h Handlers) Delete(ctx context.Context, w http.ResponseWriter, r *http.Req
 		case database.ErrForbidden:
 			return validate.NewRequestError(err, http.StatusForbidden)
 		default:
-			return fmt.Errorf("ID[%s] User[%+v] %w", id, err)
+			return fmt.Errorf("ID[%s] %w", id, err)
 		}
 	}
 	return web.Respond(ctx, w, http.StatusOK, nil)
@@ -156,24 +176,239 @@ func (h Handlers) Token(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return validate.NewRequestError(err, http.StatusUnauthorized)
 	}
 
+	ip := h.Proxies.ClientIP(r)
+
+	retry, err := h.Lockout.Check(ctx, email, ip, v.Now)
+	if err != nil {
+		if errors.Is(err, lockout.ErrLocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			return validate.NewRequestError(err, http.StatusTooManyRequests)
+		}
+		return fmt.Errorf("checking lockout: %w", err)
+	}
+
 	claims, err := h.Core.Authenticate(ctx, v.Now, email, pass)
 	if err != nil {
 		switch validate.Cause(err) {
-		case database.ErrNotFound:
-			return validate.NewRequestError(err, http.StatusNotFound)
-		case database.ErrAuthenticationFailure:
-			return validate.NewRequestError(err, http.StatusUnauthorized)
+		case database.ErrNotFound, database.ErrAuthenticationFailure:
+			if err := h.Lockout.Failed(ctx, email, ip, v.Now); err != nil {
+				return fmt.Errorf("recording failed login: %w", err)
+			}
+			return validate.NewRequestError(errBadCredentials, http.StatusUnauthorized)
 		default:
 			return fmt.Errorf("authenticating ... %w", err)
 		}
 	}
 
+	claims.Permissions, err = h.Roles.Permissions(ctx, claims.Roles)
+	if err != nil {
+		return fmt.Errorf("resolving permissions: %w", err)
+	}
+
+	enabled, err := h.MFA.Enabled(ctx, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("checking mfa: %w", err)
+	}
+
 	var tkn struct {
-		Token string `json:"token"`
+		Token              string `json:"token,omitempty"`
+		MFARequired        bool   `json:"mfa_required,omitempty"`
+		Challenge          string `json:"challenge,omitempty"`
+		EnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+		EnrollmentToken    string `json:"enrollment_token,omitempty"`
+	}
+
+	switch {
+	case enabled:
+
+		// Failed logins are only cleared once the second factor was shown
+		// as well, or logging in again would reset the count of guesses.
+		tkn.MFARequired = true
+		tkn.Challenge, err = h.purposeToken(claims, auth.PurposeMFA, v.Now)
+		if err != nil {
+			return fmt.Errorf("generating challenge  %w", err)
+		}
+		return web.Respond(ctx, w, http.StatusOK, tkn)
+
+	case h.MFA.Required(claims):
+		tkn.EnrollmentRequired = true
+		tkn.EnrollmentToken, err = h.purposeToken(claims, auth.PurposeMFAEnroll, v.Now)
+		if err != nil {
+			return fmt.Errorf("generating enrollment token  %w", err)
+		}
+		return web.Respond(ctx, w, http.StatusOK, tkn)
 	}
+
+	if err := h.Lockout.Succeeded(ctx, email); err != nil {
+		return fmt.Errorf("clearing failed logins: %w", err)
+	}
+
 	tkn.Token, err = h.Auth.GenerateToken(claims)
 	if err != nil {
 		return fmt.Errorf("generating token  %w", err)
 	}
 	return web.Respond(ctx, w, http.StatusOK, tkn)
 }
+
+// purposeToken issues a short lived token that only proves a login step.
+func (h Handlers) purposeToken(claims auth.Claims, purpose string, now time.Time) (string, error) {
+	claims.Purpose = purpose
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(h.MFA.ChallengeTTL()).Unix()
+	return h.Auth.GenerateToken(claims)
+}
+
+// Unlock lifts a lockout on the user's account.
+func (h Handlers) Unlock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	id := web.Param(r, "id")
+	usr, err := h.Core.QueryByID(ctx, claims, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case database.ErrForbidden:
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+
+	if err := h.Lockout.Unlock(ctx, claims.Subject, usr.Email, v.Now); err != nil {
+		return fmt.Errorf("ID[%s] %w", id, err)
+	}
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
+
+// QueryMe returns the calling user.
+func (h Handlers) QueryMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	usr, err := h.Core.QueryMe(ctx, claims)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, usr)
+}
+
+// UpdateMe lets the calling user change their name and email.
+func (h Handlers) UpdateMe(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var up user.UpdateProfile
+	if err := web.Decode(r, &up); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	usr, err := h.Core.UpdateMe(ctx, claims, up, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case user.ErrUniqueEmail:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, usr)
+}
+
+// ChangePassword replaces the password of the calling user.
+func (h Handlers) ChangePassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var cp user.ChangePassword
+	if err := web.Decode(r, &cp); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	usr, err := h.Core.QueryMe(ctx, claims)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+
+	// Wrong current passwords count as failed logins, a stolen token must
+	// not allow guessing the password without a limit.
+	ip := h.Proxies.ClientIP(r)
+
+	retry, err := h.Lockout.Check(ctx, usr.Email, ip, v.Now)
+	if err != nil {
+		if errors.Is(err, lockout.ErrLocked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			return validate.NewRequestError(err, http.StatusTooManyRequests)
+		}
+		return fmt.Errorf("checking lockout: %w", err)
+	}
+
+	if err := h.Core.ChangePassword(ctx, claims, cp, v.Now); err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(err, http.StatusNotFound)
+		case userCore.ErrInvalidPassword:
+			if err := h.Lockout.Failed(ctx, usr.Email, ip, v.Now); err != nil {
+				return fmt.Errorf("recording failed password: %w", err)
+			}
+			return validate.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("ID[%s] %w", claims.Subject, err)
+		}
+	}
+
+	if err := h.Lockout.Succeeded(ctx, usr.Email); err != nil {
+		return fmt.Errorf("clearing failed logins: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,170 @@
+package warehousegrp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"service/domain/core/warehouse"
+	"service/domain/data/store/product"
+	warehouseStore "service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/web"
+)
+
+type Handlers struct {
+	Core warehouse.Core
+}
+
+// Query returns every warehouse.
+func (h Handlers) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	whs, err := h.Core.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query warehouses: %w", err)
+	}
+	return web.Respond(ctx, w, http.StatusOK, whs)
+}
+
+// QueryByID returns a single warehouse.
+func (h Handlers) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	id := web.Param(r, "id")
+	wh, err := h.Core.QueryByID(ctx, id)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID:
+			return validate.NewRequestError(database.ErrInvalidID, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, wh)
+}
+
+// Create opens a warehouse.
+func (h Handlers) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var nw warehouseStore.NewWarehouse
+	if err := web.Decode(r, &nw); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	wh, err := h.Core.Create(ctx, nw, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case warehouse.ErrInvalidCode:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case warehouseStore.ErrUniqueCode:
+			return validate.NewRequestError(warehouseStore.ErrUniqueCode, http.StatusConflict)
+		default:
+			return fmt.Errorf("Warehouse[%+v] %w", &nw, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, wh)
+}
+
+// Update renames a warehouse or makes it the default.
+func (h Handlers) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	var uw warehouseStore.UpdateWarehouse
+	if err := web.Decode(r, &uw); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	wh, err := h.Core.Update(ctx, id, uw, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, warehouse.ErrInvalidCode, warehouse.ErrDefaultNeeded:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case warehouseStore.ErrUniqueCode:
+			return validate.NewRequestError(warehouseStore.ErrUniqueCode, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Warehouse[%+v] %w", id, &uw, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, wh)
+}
+
+// SetStock records the stock of a product counted in a warehouse.
+func (h Handlers) SetStock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var nl product.NewLevel
+	if err := web.Decode(r, &nl); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	l, err := h.Core.SetStock(ctx, claims, id, nl, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, warehouse.ErrUnknownProduct, warehouse.ErrUnknownVariant, warehouse.ErrNeedsVariant:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("ID[%s] Level[%+v] %w", id, &nl, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusOK, l)
+}
+
+// Adjust adds stock to a warehouse or takes it out, for a reason.
+func (h Handlers) Adjust(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return errors.New("claims are missing from context ")
+	}
+
+	var na product.NewAdjustment
+	if err := web.Decode(r, &na); err != nil {
+		return validate.NewRequestError(fmt.Errorf("unable to decode payload: %w", err), http.StatusBadRequest)
+	}
+
+	id := web.Param(r, "id")
+	l, err := h.Core.Adjust(ctx, claims, id, na, v.Now)
+	if err != nil {
+		switch validate.Cause(err) {
+		case database.ErrInvalidID, warehouse.ErrUnknownProduct, warehouse.ErrUnknownVariant, warehouse.ErrNeedsVariant:
+			return validate.NewRequestError(err, http.StatusBadRequest)
+		case database.ErrNotFound:
+			return validate.NewRequestError(database.ErrNotFound, http.StatusNotFound)
+		case product.ErrInsufficientStock:
+			return validate.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("ID[%s] Adjustment[%+v] %w", id, &na, err)
+		}
+	}
+	return web.Respond(ctx, w, http.StatusCreated, l)
+}
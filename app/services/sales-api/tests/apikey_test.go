@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"strings"
+	"testing"
+	"time"
+)
+
+type APIKeysTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+	key   issuedKey
+}
+
+// issuedKey is the answer to issuing or rotating a key.
+type issuedKey struct {
+	ID     string   `json:"id"`
+	Key    string   `json:"key"`
+	Scopes []string `json:"scopes"`
+}
+
+func TestAPIKeys(t *testing.T) {
+	h := apitest.New(t)
+
+	kt := APIKeysTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+	}
+
+	t.Run("create", kt.create)
+	t.Run("authenticate", kt.authenticate)
+	t.Run("rotate", kt.rotate)
+}
+
+func (kt *APIKeysTest) create(t *testing.T) {
+	t.Log("Given the need for admins to issue keys to services")
+	{
+		body := `{"name": "warehouse", "scopes": ["roles:read"]}`
+
+		kt.h.Post("/v1/apikeys").
+			As(kt.user.ID, auth.RoleUser).
+			JSON(body).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		kt.h.Post("/v1/apikeys").
+			As(kt.admin.ID, auth.RoleAdmin).
+			JSON(`{"name": "warehouse", "scopes": ["everything"]}`).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		kt.h.Post("/v1/apikeys").
+			As(kt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"name": "warehouse", "scopes": []string{"roles:read"}, "date_expires": time.Now().Add(-time.Hour)}).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		kt.h.Post("/v1/apikeys").
+			As(kt.admin.ID, auth.RoleAdmin).
+			JSON(body).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&kt.key)
+
+		if !strings.HasPrefix(kt.key.Key, "sk_") {
+			t.Fatalf("\t%s\tShould receive the key, got %q", apitest.Failed, kt.key.Key)
+		}
+		t.Logf("\t%s\tShould receive the key", apitest.Succeeded)
+
+		resp := kt.h.Get("/v1/apikeys").
+			As(kt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK)
+
+		secret := kt.key.Key[strings.Index(kt.key.Key, ".")+1:]
+		if strings.Contains(string(resp.Body), secret) || strings.Contains(string(resp.Body), `"key"`) {
+			t.Fatalf("\t%s\tShould never list the secret:\n%s", apitest.Failed, resp.Body)
+		}
+		t.Logf("\t%s\tShould never list the secret", apitest.Succeeded)
+	}
+}
+
+func (kt *APIKeysTest) authenticate(t *testing.T) {
+	t.Log("Given the need for services to call the API with a key")
+	{
+		kt.h.Get("/v1/roles").
+			Header("X-API-Key", kt.key.Key).
+			Do(t).
+			Status(http.StatusOK)
+		t.Logf("\t%s\tShould be allowed what the scopes permit", apitest.Succeeded)
+
+		kt.h.Post("/v1/roles").
+			Header("X-API-Key", kt.key.Key).
+			JSON(`{"name": "SUPPORT", "permissions": ["roles:read"]}`).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		kt.h.Get("/v1/users/1/10").
+			Header("X-API-Key", kt.key.Key).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould be denied anything else", apitest.Succeeded)
+
+		kt.h.Get("/v1/roles").
+			Header("X-API-Key", kt.key.Key+"x").
+			Do(t).
+			Status(http.StatusUnauthorized)
+
+		kt.h.Get("/v1/roles").
+			Header("X-API-Key", "not-a-key").
+			Do(t).
+			Status(http.StatusUnauthorized)
+
+		k, err := kt.h.APIKeys.QueryByID(context.Background(), kt.key.ID)
+		if err != nil || k.DateLastUsed == nil {
+			t.Fatalf("\t%s\tShould record the last use, got %+v %v", apitest.Failed, k, err)
+		}
+		t.Logf("\t%s\tShould record the last use", apitest.Succeeded)
+	}
+}
+
+func (kt *APIKeysTest) rotate(t *testing.T) {
+	t.Log("Given the need to rotate keys without downtime")
+	{
+		var next issuedKey
+		kt.h.Post("/v1/apikeys/"+kt.key.ID+"/rotate").
+			As(kt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&next)
+
+		if next.Key == "" || next.Key == kt.key.Key || len(next.Scopes) != 1 || next.Scopes[0] != auth.PermRolesRead {
+			t.Fatalf("\t%s\tShould issue a new key with the same scopes, got %+v", apitest.Failed, next)
+		}
+		t.Logf("\t%s\tShould issue a new key with the same scopes", apitest.Succeeded)
+
+		for _, key := range []string{kt.key.Key, next.Key} {
+			kt.h.Get("/v1/roles").
+				Header("X-API-Key", key).
+				Do(t).
+				Status(http.StatusOK)
+		}
+		t.Logf("\t%s\tShould accept both keys during the overlap", apitest.Succeeded)
+
+		old, err := kt.h.APIKeys.QueryByID(context.Background(), kt.key.ID)
+		if err != nil || old.DateExpires == nil || old.DateExpires.After(time.Now().Add(apitest.RotationOverlap)) {
+			t.Fatalf("\t%s\tShould expire the old key after the overlap, got %+v %v", apitest.Failed, old, err)
+		}
+		t.Logf("\t%s\tShould expire the old key after the overlap", apitest.Succeeded)
+
+		kt.h.Delete("/v1/apikeys/"+next.ID).
+			As(kt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusNoContent)
+
+		kt.h.Get("/v1/roles").
+			Header("X-API-Key", next.Key).
+			Do(t).
+			Status(http.StatusUnauthorized)
+
+		kt.h.Post("/v1/apikeys/"+next.ID+"/rotate").
+			As(kt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusConflict)
+	}
+}
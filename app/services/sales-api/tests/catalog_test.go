@@ -0,0 +1,321 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/core/category"
+	"service/domain/core/product"
+	categoryStore "service/domain/data/store/category"
+	productStore "service/domain/data/store/product"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type CatalogTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+	games categoryStore.Category
+	board categoryStore.Category
+	chess categoryStore.Category
+	video categoryStore.Category
+	knock productStore.Product
+	queen productStore.Product
+	pong  productStore.Product
+}
+
+func TestCatalog(t *testing.T) {
+	h := apitest.New(t)
+
+	ct := CatalogTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		knock: h.CreateProduct("Knight", 500, 10),
+		queen: h.CreateProduct("Queen", 900, 10),
+		pong:  h.CreateProduct("Pong", 2000, 10),
+	}
+
+	t.Run("categories", ct.categories)
+	t.Run("file", ct.file)
+	t.Run("browse", ct.browse)
+	t.Run("search", ct.search)
+	t.Run("change", ct.change)
+}
+
+func (ct *CatalogTest) category(t *testing.T, name string, parent *categoryStore.Category) categoryStore.Category {
+	t.Helper()
+
+	nc := map[string]any{"name": name}
+	if parent != nil {
+		nc["parent_id"] = parent.ID
+	}
+
+	var c categoryStore.Category
+	ct.h.Post("/v1/categories").
+		As(ct.admin.ID, auth.RoleAdmin).
+		JSON(nc).
+		Do(t).
+		Status(http.StatusCreated).
+		Decode(&c)
+	return c
+}
+
+func (ct *CatalogTest) categories(t *testing.T) {
+	t.Log("Given the need to manage a tree of categories")
+	{
+		ct.games = ct.category(t, "Games", nil)
+		ct.board = ct.category(t, "Board Games", &ct.games)
+		ct.chess = ct.category(t, "Chess", &ct.board)
+		ct.video = ct.category(t, "Video Games", &ct.games)
+
+		if ct.board.Slug != "board-games" || ct.board.ParentID == nil || *ct.board.ParentID != ct.games.ID {
+			t.Fatalf("\t%s\tShould make the slug from the name, got %+v", apitest.Failed, ct.board)
+		}
+		t.Logf("\t%s\tShould make the slug from the name", apitest.Succeeded)
+
+		ct.h.Post("/v1/categories").
+			As(ct.user.ID, auth.RoleUser).
+			JSON(map[string]any{"name": "Puzzles"}).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff manage categories", apitest.Succeeded)
+
+		ct.h.Post("/v1/categories").
+			As(ct.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"name": "Chess Sets", "slug": "chess"}).
+			Do(t).
+			Status(http.StatusConflict)
+
+		ct.h.Post("/v1/categories").
+			As(ct.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"name": "Puzzles", "slug": "Puzzles!"}).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		ct.h.Post("/v1/categories").
+			As(ct.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"name": "Puzzles", "parent_id": ct.knock.ID}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a slug in use, a malformed slug and an unknown parent", apitest.Succeeded)
+
+		var tree []category.Node
+		ct.h.Get("/v1/categories").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&tree)
+
+		if len(tree) != 1 || len(tree[0].Children) != 2 || tree[0].Children[0].ID != ct.board.ID || len(tree[0].Children[0].Children) != 1 {
+			t.Fatalf("\t%s\tShould return the tree of categories, got %+v", apitest.Failed, tree)
+		}
+		t.Logf("\t%s\tShould return the tree of categories", apitest.Succeeded)
+	}
+}
+
+func (ct *CatalogTest) file(t *testing.T) {
+	t.Log("Given the need to file products under categories and tag them")
+	{
+		for _, f := range []struct {
+			p    *productStore.Product
+			c    categoryStore.Category
+			tags []string
+		}{
+			{&ct.knock, ct.chess, []string{"Wood", "classic"}},
+			{&ct.queen, ct.chess, []string{"plastic", "Classic", "classic"}},
+			{&ct.pong, ct.video, []string{"Classic", "Two Players"}},
+		} {
+			ct.h.Put("/v1/products/"+f.p.ID+"/category").
+				As(ct.admin.ID, auth.RoleAdmin).
+				JSON(map[string]any{"category_id": f.c.ID}).
+				Do(t).
+				Status(http.StatusOK)
+
+			ct.h.Put("/v1/products/"+f.p.ID+"/tags").
+				As(ct.admin.ID, auth.RoleAdmin).
+				JSON(map[string]any{"tags": f.tags}).
+				Do(t).
+				Status(http.StatusOK).
+				Decode(f.p)
+		}
+
+		if len(ct.queen.Tags) != 2 || ct.queen.Tags[0] != "classic" || ct.queen.CategoryID == nil || *ct.queen.CategoryID != ct.chess.ID {
+			t.Fatalf("\t%s\tShould store the tags in their normal form, got %+v", apitest.Failed, ct.queen)
+		}
+		t.Logf("\t%s\tShould store the tags in their normal form", apitest.Succeeded)
+
+		ct.h.Put("/v1/products/"+ct.pong.ID+"/tags").
+			As(ct.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"tags": []string{"!!"}}).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		ct.h.Put("/v1/products/"+ct.pong.ID+"/category").
+			As(ct.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"category_id": ct.pong.ID}).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		ct.h.Put("/v1/products/"+ct.pong.ID+"/category").
+			As(ct.user.ID, auth.RoleUser).
+			JSON(map[string]any{"category_id": ct.board.ID}).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould refuse empty tags, unknown categories and customers", apitest.Succeeded)
+	}
+}
+
+func (ct *CatalogTest) browse(t *testing.T) {
+	t.Log("Given the need for customers to browse the catalog")
+	{
+		var prds []productStore.Product
+		ct.h.Get("/v1/products?category=games").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&prds)
+
+		if len(prds) != 3 {
+			t.Fatalf("\t%s\tShould include the products of every category below, got %+v", apitest.Failed, prds)
+		}
+		t.Logf("\t%s\tShould include the products of every category below", apitest.Succeeded)
+
+		ct.h.Get("/v1/products?category="+ct.games.ID+"&tag=classic&tag=wood").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&prds)
+
+		if len(prds) != 1 || prds[0].ID != ct.knock.ID {
+			t.Fatalf("\t%s\tShould keep the products carrying every tag, got %+v", apitest.Failed, prds)
+		}
+		t.Logf("\t%s\tShould keep the products carrying every tag", apitest.Succeeded)
+
+		ct.h.Get("/v1/products?tag=classic&page=2&rows=2").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&prds)
+
+		if len(prds) != 1 || prds[0].ID != ct.queen.ID {
+			t.Fatalf("\t%s\tShould page through the products by name, got %+v", apitest.Failed, prds)
+		}
+		t.Logf("\t%s\tShould page through the products by name", apitest.Succeeded)
+
+		ct.h.Get("/v1/products?category=nope").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		ct.h.Get("/v1/products?page=0").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown category and a bad page", apitest.Succeeded)
+
+		var facets []product.Facet
+		ct.h.Get("/v1/products/facets?tag=classic").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&facets)
+
+		counts := make(map[string]int)
+		for _, f := range facets {
+			counts[f.Slug] = f.Count
+		}
+		if len(facets) != 4 || counts["games"] != 3 || counts["board-games"] != 2 || counts["chess"] != 2 || counts["video-games"] != 1 {
+			t.Fatalf("\t%s\tShould count the products below every category, got %+v", apitest.Failed, facets)
+		}
+		t.Logf("\t%s\tShould count the products below every category", apitest.Succeeded)
+
+		var tags []productStore.TagCount
+		ct.h.Get("/v1/tags").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&tags)
+
+		if len(tags) != 4 || tags[0].Tag != "classic" || tags[0].Count != 3 {
+			t.Fatalf("\t%s\tShould list the tags in use, got %+v", apitest.Failed, tags)
+		}
+		t.Logf("\t%s\tShould list the tags in use", apitest.Succeeded)
+	}
+}
+
+func (ct *CatalogTest) search(t *testing.T) {
+	t.Log("Given the need for staff to find products by typing their name")
+	{
+		var matches []productStore.Match
+		ct.h.Get("/v1/products/search?q=kni").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&matches)
+
+		if len(matches) != 1 || matches[0].ID != ct.knock.ID || matches[0].Snippet != "<b>Knight</b>" || len(matches[0].Tags) != 2 {
+			t.Fatalf("\t%s\tShould find the product by the start of its name, got %+v", apitest.Failed, matches)
+		}
+		t.Logf("\t%s\tShould find the product by the start of its name", apitest.Succeeded)
+
+		ct.h.Get("/v1/products/search?q=%3F%3F").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		ct.h.Get("/v1/products/search?q=pong&limit=0").
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a search without words and a bad limit", apitest.Succeeded)
+	}
+}
+
+func (ct *CatalogTest) change(t *testing.T) {
+	t.Log("Given the need to reorganize the categories")
+	{
+		ct.h.Put("/v1/categories/"+ct.games.ID).
+			As(ct.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"parent_id": ct.chess.ID}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse to move a category below itself", apitest.Succeeded)
+
+		var moved categoryStore.Category
+		ct.h.Put("/v1/categories/"+ct.chess.ID).
+			As(ct.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"parent_id": "", "name": "Chess Sets", "slug": "chess-sets"}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&moved)
+
+		if moved.ParentID != nil || moved.Slug != "chess-sets" {
+			t.Fatalf("\t%s\tShould move the category to the top, got %+v", apitest.Failed, moved)
+		}
+		t.Logf("\t%s\tShould move the category to the top", apitest.Succeeded)
+
+		ct.h.Delete("/v1/categories/"+ct.chess.ID).
+			As(ct.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusConflict)
+
+		ct.h.Delete("/v1/categories/"+ct.games.ID).
+			As(ct.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse to delete a category with products or categories", apitest.Succeeded)
+
+		ct.h.Delete("/v1/categories/"+ct.board.ID).
+			As(ct.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusNoContent)
+
+		ct.h.Get("/v1/categories/"+ct.board.ID).
+			As(ct.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusNotFound)
+		t.Logf("\t%s\tShould delete an empty category", apitest.Succeeded)
+	}
+}
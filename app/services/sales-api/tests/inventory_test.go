@@ -0,0 +1,317 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	productCore "service/domain/core/product"
+	"service/domain/data/store/order"
+	"service/domain/data/store/product"
+	"service/domain/data/store/transfer"
+	"service/domain/data/store/user"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type InventoryTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+	books product.Product
+	north warehouse.Warehouse
+}
+
+func TestInventory(t *testing.T) {
+	h := apitest.New(t)
+
+	it := InventoryTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		books: h.CreateProduct("Comic Books", 50, 10),
+	}
+
+	t.Run("warehouses", it.warehouses)
+	t.Run("stock", it.stock)
+	t.Run("sell", it.sell)
+	t.Run("transfer", it.transfer)
+	t.Run("default", it.defaultWarehouse)
+}
+
+// levels returns how many books the main and the north warehouses hold.
+func (it *InventoryTest) levels(t *testing.T) (int, int) {
+	t.Helper()
+
+	var stock productCore.Stock
+	it.h.Get("/v1/products/"+it.books.ID+"/stock").
+		As(it.user.ID, auth.RoleUser).
+		Do(t).
+		Status(http.StatusOK).
+		Decode(&stock)
+
+	var main, north int
+	for _, l := range stock.Levels {
+		switch l.WarehouseID {
+		case warehouse.MainID:
+			main = l.Quantity
+		case it.north.ID:
+			north = l.Quantity
+		}
+	}
+	return main, north
+}
+
+func (it *InventoryTest) warehouses(t *testing.T) {
+	t.Log("Given the need to hold stock in more than one place")
+	{
+		it.h.Post("/v1/warehouses").
+			As(it.user.ID, auth.RoleUser).
+			JSON(map[string]any{"code": "NORTH", "name": "North"}).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff open warehouses", apitest.Succeeded)
+
+		it.h.Post("/v1/warehouses").
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"code": " north ", "name": "North"}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&it.north)
+
+		if it.north.Code != "NORTH" || it.north.IsDefault {
+			t.Fatalf("\t%s\tShould store the code upper case, got %+v", apitest.Failed, it.north)
+		}
+		t.Logf("\t%s\tShould store the code upper case", apitest.Succeeded)
+
+		it.h.Post("/v1/warehouses").
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"code": "north", "name": "Another North"}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse a code in use", apitest.Succeeded)
+
+		it.h.Put("/v1/warehouses/"+warehouse.MainID).
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"is_default": false}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould always keep a default warehouse", apitest.Succeeded)
+
+		var whs []warehouse.Warehouse
+		it.h.Get("/v1/warehouses").
+			As(it.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&whs)
+
+		if len(whs) != 2 || whs[0].ID != warehouse.MainID || !whs[0].IsDefault || whs[1].ID != it.north.ID {
+			t.Fatalf("\t%s\tShould list the warehouses by code, got %+v", apitest.Failed, whs)
+		}
+		t.Logf("\t%s\tShould list the warehouses by code", apitest.Succeeded)
+	}
+}
+
+func (it *InventoryTest) stock(t *testing.T) {
+	t.Log("Given the need to record the stock counted in a warehouse")
+	{
+		var l product.Level
+		it.h.Put("/v1/warehouses/"+it.north.ID+"/stock").
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"product_id": it.books.ID, "quantity": 4}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&l)
+
+		if main, north := it.levels(t); main != 10 || north != 4 || l.Quantity != 4 {
+			t.Fatalf("\t%s\tShould set the level of the warehouse, got %d %d", apitest.Failed, main, north)
+		}
+		t.Logf("\t%s\tShould set the level of the warehouse", apitest.Succeeded)
+
+		var p product.Product
+		it.h.Get("/v1/products/"+it.books.ID).
+			As(it.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&p)
+
+		if p.Quantity != 14 {
+			t.Fatalf("\t%s\tShould add the levels up into the product, got %d", apitest.Failed, p.Quantity)
+		}
+		t.Logf("\t%s\tShould add the levels up into the product", apitest.Succeeded)
+
+		it.h.Put("/v1/warehouses/"+it.north.ID+"/stock").
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"product_id": it.north.ID, "quantity": 4}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown product", apitest.Succeeded)
+	}
+}
+
+func (it *InventoryTest) sell(t *testing.T) {
+	t.Log("Given the need to pick orders from a warehouse")
+	{
+		it.h.Post("/v1/orders").
+			As(it.user.ID, auth.RoleUser).
+			JSON(map[string]any{"warehouse_id": it.north.ID, "items": []any{map[string]any{"product_id": it.books.ID, "quantity": 5}}}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse more than the warehouse holds", apitest.Succeeded)
+
+		var o order.Order
+		it.h.Post("/v1/orders").
+			As(it.user.ID, auth.RoleUser).
+			JSON(map[string]any{"warehouse_id": it.north.ID, "items": []any{map[string]any{"product_id": it.books.ID, "quantity": 3}}}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&o)
+
+		if main, north := it.levels(t); main != 10 || north != 1 || o.WarehouseID != it.north.ID {
+			t.Fatalf("\t%s\tShould take the stock from the chosen warehouse, got %d %d", apitest.Failed, main, north)
+		}
+		t.Logf("\t%s\tShould take the stock from the chosen warehouse", apitest.Succeeded)
+
+		it.h.Post("/v1/orders").
+			As(it.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": it.books.ID, "quantity": 2}}}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&o)
+
+		if main, north := it.levels(t); main != 8 || north != 1 || o.WarehouseID != warehouse.MainID {
+			t.Fatalf("\t%s\tShould take the stock from the default warehouse, got %d %d", apitest.Failed, main, north)
+		}
+		t.Logf("\t%s\tShould take the stock from the default warehouse", apitest.Succeeded)
+
+		it.h.Post("/v1/orders").
+			As(it.user.ID, auth.RoleUser).
+			JSON(map[string]any{"warehouse_id": it.books.ID, "items": []any{map[string]any{"product_id": it.books.ID, "quantity": 1}}}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown warehouse", apitest.Succeeded)
+	}
+}
+
+func (it *InventoryTest) transfer(t *testing.T) {
+	t.Log("Given the need to move stock between warehouses")
+	{
+		body := map[string]any{
+			"from_warehouse_id": warehouse.MainID,
+			"to_warehouse_id":   it.north.ID,
+			"items":             []any{map[string]any{"product_id": it.books.ID, "quantity": 5}},
+		}
+
+		var shipped transfer.Transfer
+		it.h.Post("/v1/transfers").
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(body).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&shipped)
+
+		if main, north := it.levels(t); main != 3 || north != 1 || shipped.Status != transfer.StatusInTransit {
+			t.Fatalf("\t%s\tShould hold the stock in transit, got %d %d %+v", apitest.Failed, main, north, shipped)
+		}
+		t.Logf("\t%s\tShould hold the stock in transit", apitest.Succeeded)
+
+		it.h.Post("/v1/transfers").
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(body).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse to ship more than the source holds", apitest.Succeeded)
+
+		it.h.Post("/v1/transfers").
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"from_warehouse_id": it.north.ID, "to_warehouse_id": it.north.ID, "items": body["items"]}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse to ship to the source", apitest.Succeeded)
+
+		var got transfer.Transfer
+		it.h.Post("/v1/transfers/"+shipped.ID+"/receive").
+			As(it.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if main, north := it.levels(t); main != 3 || north != 6 || got.Status != transfer.StatusReceived {
+			t.Fatalf("\t%s\tShould put the stock into the destination, got %d %d %+v", apitest.Failed, main, north, got)
+		}
+		t.Logf("\t%s\tShould put the stock into the destination", apitest.Succeeded)
+
+		it.h.Post("/v1/transfers/"+shipped.ID+"/cancel").
+			As(it.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse to close a transfer twice", apitest.Succeeded)
+
+		body["items"] = []any{map[string]any{"product_id": it.books.ID, "quantity": 2}}
+		it.h.Post("/v1/transfers").
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(body).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&shipped)
+
+		it.h.Post("/v1/transfers/"+shipped.ID+"/cancel").
+			As(it.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK)
+
+		if main, north := it.levels(t); main != 3 || north != 6 {
+			t.Fatalf("\t%s\tShould put the stock back into the source, got %d %d", apitest.Failed, main, north)
+		}
+		t.Logf("\t%s\tShould put the stock back into the source", apitest.Succeeded)
+
+		var cancelled []transfer.Transfer
+		it.h.Get("/v1/transfers/1/10?status=cancelled").
+			As(it.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&cancelled)
+
+		if len(cancelled) != 1 || cancelled[0].ID != shipped.ID {
+			t.Fatalf("\t%s\tShould list the transfers by status, got %+v", apitest.Failed, cancelled)
+		}
+		t.Logf("\t%s\tShould list the transfers by status", apitest.Succeeded)
+	}
+}
+
+func (it *InventoryTest) defaultWarehouse(t *testing.T) {
+	t.Log("Given the need to change the default warehouse")
+	{
+		var got warehouse.Warehouse
+		it.h.Put("/v1/warehouses/"+it.north.ID).
+			As(it.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"is_default": true}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		var main warehouse.Warehouse
+		it.h.Get("/v1/warehouses/"+warehouse.MainID).
+			As(it.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&main)
+
+		if !got.IsDefault || main.IsDefault {
+			t.Fatalf("\t%s\tShould move the default, got %+v %+v", apitest.Failed, got, main)
+		}
+		t.Logf("\t%s\tShould move the default", apitest.Succeeded)
+
+		var o order.Order
+		it.h.Post("/v1/orders").
+			As(it.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": it.books.ID, "quantity": 1}}}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&o)
+
+		if o.WarehouseID != it.north.ID {
+			t.Fatalf("\t%s\tShould pick orders from the new default, got %s", apitest.Failed, o.WarehouseID)
+		}
+		t.Logf("\t%s\tShould pick orders from the new default", apitest.Succeeded)
+	}
+}
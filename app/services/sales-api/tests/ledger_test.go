@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/product"
+	"service/domain/data/store/stocktake"
+	"service/domain/data/store/user"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type LedgerTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+	books product.Product
+}
+
+func TestLedger(t *testing.T) {
+	h := apitest.New(t)
+
+	lt := LedgerTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		books: h.CreateProduct("Comic Books", 50, 10),
+	}
+
+	t.Run("adjust", lt.adjust)
+	t.Run("movements", lt.movements)
+	t.Run("stocktake", lt.stocktake)
+}
+
+// verify fails the test when a level does not match the ledger.
+func (lt *LedgerTest) verify(t *testing.T) {
+	t.Helper()
+
+	var ds []product.Discrepancy
+	lt.h.Get("/v1/inventory/verify").
+		As(lt.admin.ID, auth.RoleAdmin).
+		Do(t).
+		Status(http.StatusOK).
+		Decode(&ds)
+
+	if len(ds) != 0 {
+		t.Fatalf("\t%s\tShould derive every level from the ledger, got %+v", apitest.Failed, ds)
+	}
+	t.Logf("\t%s\tShould derive every level from the ledger", apitest.Succeeded)
+}
+
+func (lt *LedgerTest) adjust(t *testing.T) {
+	t.Log("Given the need to adjust stock by hand")
+	{
+		url := "/v1/warehouses/" + warehouse.MainID + "/adjustments"
+
+		lt.h.Post(url).
+			As(lt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"product_id": lt.books.ID, "quantity": -3, "reason": product.ReasonDamaged}).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff adjust stock", apitest.Succeeded)
+
+		lt.h.Post(url).
+			As(lt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"product_id": lt.books.ID, "quantity": -3, "reason": "gremlins"}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown reason", apitest.Succeeded)
+
+		lt.h.Post(url).
+			As(lt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"product_id": lt.books.ID, "quantity": -11, "reason": product.ReasonLost}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse to take more than the warehouse holds", apitest.Succeeded)
+
+		var l product.Level
+		lt.h.Post(url).
+			As(lt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"product_id": lt.books.ID, "quantity": -3, "reason": product.ReasonDamaged, "note": "water damage"}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&l)
+
+		if l.Quantity != 7 || l.WarehouseID != warehouse.MainID {
+			t.Fatalf("\t%s\tShould take the adjustment out of the warehouse, got %+v", apitest.Failed, l)
+		}
+		t.Logf("\t%s\tShould take the adjustment out of the warehouse", apitest.Succeeded)
+
+		lt.h.Post("/v1/orders").
+			As(lt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": lt.books.ID, "quantity": 2}}}).
+			Do(t).
+			Status(http.StatusCreated)
+
+		lt.verify(t)
+	}
+}
+
+func (lt *LedgerTest) movements(t *testing.T) {
+	t.Log("Given the need to see why stock moved")
+	{
+		lt.h.Get("/v1/inventory/movements/1/10?product_id="+lt.books.ID).
+			As(lt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff read the ledger", apitest.Succeeded)
+
+		lt.h.Get("/v1/inventory/movements/1/10?warehouse_id=main").
+			As(lt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a malformed filter", apitest.Succeeded)
+
+		var mvs []product.Movement
+		lt.h.Get("/v1/inventory/movements/1/10?product_id="+lt.books.ID).
+			As(lt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&mvs)
+
+		if len(mvs) != 3 || mvs[0].Kind != product.KindSale || mvs[0].Quantity != -2 || mvs[0].ReferenceID == nil ||
+			mvs[1].Kind != product.KindAdjustment || mvs[1].Reason != product.ReasonDamaged || mvs[1].Note != "water damage" ||
+			mvs[1].CreatedBy == nil || *mvs[1].CreatedBy != lt.admin.ID || mvs[2].Kind != product.KindReceipt || mvs[2].Quantity != 10 {
+			t.Fatalf("\t%s\tShould list every movement latest first, got %+v", apitest.Failed, mvs)
+		}
+		t.Logf("\t%s\tShould list every movement latest first", apitest.Succeeded)
+	}
+}
+
+func (lt *LedgerTest) stocktake(t *testing.T) {
+	t.Log("Given the need to reconcile a count with the ledger")
+	{
+		var st stocktake.Stocktake
+		lt.h.Post("/v1/stocktakes").
+			As(lt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"warehouse_id": warehouse.MainID, "note": "year end"}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&st)
+
+		if st.Status != stocktake.StatusOpen || st.CreatedBy != lt.admin.ID {
+			t.Fatalf("\t%s\tShould open a stocktake, got %+v", apitest.Failed, st)
+		}
+		t.Logf("\t%s\tShould open a stocktake", apitest.Succeeded)
+
+		lt.h.Put("/v1/stocktakes/"+st.ID+"/counts").
+			As(lt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"counts": []any{map[string]any{"product_id": lt.admin.ID, "counted": 1}}}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse to count an unknown product", apitest.Succeeded)
+
+		lt.h.Put("/v1/stocktakes/"+st.ID+"/counts").
+			As(lt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"counts": []any{map[string]any{"product_id": lt.books.ID, "counted": 4}}}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&st)
+
+		if len(st.Lines) != 1 || st.Lines[0].Counted != 4 || st.Lines[0].Variance != nil {
+			t.Fatalf("\t%s\tShould record the count, got %+v", apitest.Failed, st)
+		}
+		t.Logf("\t%s\tShould record the count", apitest.Succeeded)
+
+		lt.h.Post("/v1/stocktakes/"+st.ID+"/post").
+			As(lt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&st)
+
+		if st.Status != stocktake.StatusPosted || st.Lines[0].Expected == nil || *st.Lines[0].Expected != 5 || *st.Lines[0].Variance != -1 {
+			t.Fatalf("\t%s\tShould post the variance with the ledger, got %+v", apitest.Failed, st)
+		}
+		t.Logf("\t%s\tShould post the variance with the ledger", apitest.Succeeded)
+
+		var p product.Product
+		lt.h.Get("/v1/products/"+lt.books.ID).
+			As(lt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&p)
+
+		if p.Quantity != 4 {
+			t.Fatalf("\t%s\tShould move the stock to the count, got %d", apitest.Failed, p.Quantity)
+		}
+		t.Logf("\t%s\tShould move the stock to the count", apitest.Succeeded)
+
+		lt.h.Post("/v1/stocktakes/"+st.ID+"/post").
+			As(lt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse to post a stocktake twice", apitest.Succeeded)
+
+		var posted []stocktake.Stocktake
+		lt.h.Get("/v1/stocktakes/1/10?status="+stocktake.StatusPosted).
+			As(lt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&posted)
+
+		if len(posted) != 1 || posted[0].ID != st.ID {
+			t.Fatalf("\t%s\tShould list the posted stocktakes, got %+v", apitest.Failed, posted)
+		}
+		t.Logf("\t%s\tShould list the posted stocktakes", apitest.Succeeded)
+
+		lt.verify(t)
+	}
+}
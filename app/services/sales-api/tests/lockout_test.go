@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/core/lockout"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type LockoutTest struct {
+	h      *apitest.Harness
+	admin  user.User
+	user   user.User
+	holder user.User
+}
+
+func TestLockout(t *testing.T) {
+	h := apitest.New(t)
+
+	lt := LockoutTest{
+		h:      h,
+		admin:  h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin),
+		user:   h.CreateUser("User Gopher", "locked@example.com", "gophers", auth.RoleUser),
+		holder: h.CreateUser("Token Holder", "holder@example.com", "gophers", auth.RoleUser),
+	}
+
+	t.Run("lock", lt.lock)
+	t.Run("unlock", lt.unlock)
+	t.Run("password", lt.password)
+}
+
+func (lt *LockoutTest) lock(t *testing.T) {
+	t.Log("Given the need to stop password guessing")
+	{
+		for i := 0; i < apitest.LockoutThreshold; i++ {
+			lt.h.Get("/v1/users/token").
+				BasicAuth(lt.user.Email, "wrong").
+				Do(t).
+				Status(http.StatusUnauthorized)
+		}
+
+		resp := lt.h.Get("/v1/users/token").
+			BasicAuth(lt.user.Email, "gophers").
+			Do(t).
+			Status(http.StatusTooManyRequests).
+			Golden("locked")
+
+		if resp.Header.Get("Retry-After") == "" {
+			t.Fatalf("\t%s\tShould tell when to retry", apitest.Failed)
+		}
+		t.Logf("\t%s\tShould tell when to retry", apitest.Succeeded)
+
+		events, err := lt.h.Audit.QueryBySubject(context.Background(), "account:"+lt.user.Email)
+		if err != nil || len(events) != 1 || events[0].Action != lockout.ActionLocked {
+			t.Fatalf("\t%s\tShould audit the lockout, got %+v %v", apitest.Failed, events, err)
+		}
+		t.Logf("\t%s\tShould audit the lockout", apitest.Succeeded)
+
+		lt.h.Get("/v1/users/token").
+			BasicAuth(lt.admin.Email, "gophers").
+			Do(t).
+			Status(http.StatusOK)
+	}
+}
+
+func (lt *LockoutTest) unlock(t *testing.T) {
+	t.Log("Given the need for admins to lift a lockout")
+	{
+		lt.h.Post("/v1/users/"+lt.user.ID+"/unlock").
+			As(lt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		lt.h.Post("/v1/users/"+lt.user.ID+"/unlock").
+			As(lt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusNoContent)
+
+		lt.h.Get("/v1/users/token").
+			BasicAuth(lt.user.Email, "gophers").
+			Do(t).
+			Status(http.StatusOK)
+
+		events, err := lt.h.Audit.QueryBySubject(context.Background(), "account:"+lt.user.Email)
+		if err != nil || len(events) != 2 || events[0].Actor != lt.admin.ID {
+			t.Fatalf("\t%s\tShould audit the unlock by the admin, got %+v %v", apitest.Failed, events, err)
+		}
+		t.Logf("\t%s\tShould audit the unlock by the admin", apitest.Succeeded)
+	}
+}
+
+func (lt *LockoutTest) password(t *testing.T) {
+	t.Log("Given the need to stop guessing the password behind a token")
+	{
+		body := `{"current_password": "wrong", "password": "new-gophers", "password_confirm": "new-gophers"}`
+		for i := 0; i < apitest.LockoutThreshold; i++ {
+			lt.h.Post("/v1/users/me/password").
+				As(lt.holder.ID, auth.RoleUser).
+				JSON(body).
+				Do(t).
+				Status(http.StatusForbidden)
+		}
+
+		resp := lt.h.Post("/v1/users/me/password").
+			As(lt.holder.ID, auth.RoleUser).
+			JSON(`{"current_password": "gophers", "password": "new-gophers", "password_confirm": "new-gophers"}`).
+			Do(t).
+			Status(http.StatusTooManyRequests)
+
+		if resp.Header.Get("Retry-After") == "" {
+			t.Fatalf("\t%s\tShould tell when to retry", apitest.Failed)
+		}
+		t.Logf("\t%s\tShould lock the account after too many wrong current passwords", apitest.Succeeded)
+
+		lt.h.Get("/v1/users/token").
+			BasicAuth(lt.holder.Email, "gophers").
+			Do(t).
+			Status(http.StatusTooManyRequests)
+		t.Logf("\t%s\tShould refuse logins to the locked account", apitest.Succeeded)
+	}
+}
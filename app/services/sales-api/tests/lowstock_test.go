@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/product"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"service/foundation/notification"
+	"strings"
+	"testing"
+	"time"
+)
+
+type LowStockTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+	books product.Product
+}
+
+func TestLowStock(t *testing.T) {
+	h := apitest.New(t)
+
+	lt := LowStockTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		books: h.CreateProduct("Comic Books", 50, 10),
+	}
+
+	t.Run("reorderPoint", lt.reorderPoint)
+	t.Run("alert", lt.alert)
+	t.Run("report", lt.report)
+}
+
+// buy places an order for n of the books.
+func (lt *LowStockTest) buy(t *testing.T, n int) {
+	t.Helper()
+
+	lt.h.Post("/v1/orders").
+		As(lt.user.ID, auth.RoleUser).
+		JSON(map[string]any{"items": []any{map[string]any{"product_id": lt.books.ID, "quantity": n}}}).
+		Do(t).
+		Status(http.StatusCreated)
+}
+
+// waitForAlerts waits for the checker to mail n alerts, it runs in the
+// background so the order has already been answered.
+func (lt *LowStockTest) waitForAlerts(t *testing.T, n int) []notification.Message {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		msgs := lt.h.Mail.Messages(apitest.LowStockEmail)
+		if len(msgs) >= n || time.Now().After(deadline) {
+			return msgs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (lt *LowStockTest) reorderPoint(t *testing.T) {
+	t.Log("Given the need to set when a product is reordered")
+	{
+		url := "/v1/products/" + lt.books.ID + "/reorder-point"
+
+		lt.h.Put(url).
+			As(lt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"reorder_point": 5}).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff set reorder points", apitest.Succeeded)
+
+		lt.h.Put(url).
+			As(lt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"reorder_point": -1}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a negative reorder point", apitest.Succeeded)
+
+		var p product.Product
+		lt.h.Put(url).
+			As(lt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"reorder_point": 5}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&p)
+
+		if p.ReorderPoint != 5 {
+			t.Fatalf("\t%s\tShould set the reorder point, got %+v", apitest.Failed, p)
+		}
+		t.Logf("\t%s\tShould set the reorder point", apitest.Succeeded)
+	}
+}
+
+func (lt *LowStockTest) alert(t *testing.T) {
+	t.Log("Given the need to hear about products running low")
+	{
+		lt.buy(t, 4)
+		if err := lt.h.LowStock.Check(context.Background(), nil, time.Now()); err != nil {
+			t.Fatalf("\t%s\tShould be able to sweep the products: %v", apitest.Failed, err)
+		}
+		if n := len(lt.h.Mail.Messages(apitest.LowStockEmail)); n != 0 {
+			t.Fatalf("\t%s\tShould stay quiet above the reorder point, got %d alerts", apitest.Failed, n)
+		}
+		t.Logf("\t%s\tShould stay quiet above the reorder point", apitest.Succeeded)
+
+		lt.buy(t, 2)
+		msgs := lt.waitForAlerts(t, 1)
+		if len(msgs) != 1 || !strings.Contains(msgs[0].Subject, lt.books.Name) || !strings.Contains(msgs[0].Body, "down to 4 units") {
+			t.Fatalf("\t%s\tShould alert once the sale crosses the reorder point, got %+v", apitest.Failed, msgs)
+		}
+		t.Logf("\t%s\tShould alert once the sale crosses the reorder point", apitest.Succeeded)
+
+		lt.buy(t, 1)
+		if err := lt.h.LowStock.Check(context.Background(), nil, time.Now()); err != nil {
+			t.Fatalf("\t%s\tShould be able to sweep the products: %v", apitest.Failed, err)
+		}
+		if n := len(lt.h.Mail.Messages(apitest.LowStockEmail)); n != 1 {
+			t.Fatalf("\t%s\tShould alert only once per crossing, got %d alerts", apitest.Failed, n)
+		}
+		t.Logf("\t%s\tShould alert only once per crossing", apitest.Succeeded)
+	}
+}
+
+func (lt *LowStockTest) report(t *testing.T) {
+	t.Log("Given the need to list the products to reorder")
+	{
+		lt.h.Get("/v1/inventory/low-stock").
+			As(lt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff list low stock", apitest.Succeeded)
+
+		var lows []product.LowStock
+		lt.h.Get("/v1/inventory/low-stock").
+			As(lt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&lows)
+
+		if len(lows) != 1 || lows[0].ProductID != lt.books.ID || lows[0].Quantity != 3 || lows[0].ReorderPoint != 5 || lows[0].DateAlerted == nil {
+			t.Fatalf("\t%s\tShould list the product with its alert, got %+v", apitest.Failed, lows)
+		}
+		t.Logf("\t%s\tShould list the product with its alert", apitest.Succeeded)
+	}
+}
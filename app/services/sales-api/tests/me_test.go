@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type MeTest struct {
+	h    *apitest.Harness
+	user user.User
+}
+
+func TestMe(t *testing.T) {
+	h := apitest.New(t)
+
+	mt := MeTest{
+		h:    h,
+		user: h.CreateUser("User Gopher", "me@example.com", "gophers", auth.RoleUser),
+	}
+
+	t.Run("queryMe", mt.queryMe)
+	t.Run("updateMe", mt.updateMe)
+	t.Run("updateMeRoles", mt.updateMeRoles)
+	t.Run("changePassword", mt.changePassword)
+}
+
+func (mt *MeTest) queryMe(t *testing.T) {
+	t.Log("Given the need for users to fetch themselves without their id")
+	{
+		mt.h.Get("/v1/users/me").
+			Do(t).
+			Status(http.StatusUnauthorized)
+
+		mt.h.Get("/v1/users/me").
+			As(mt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Golden("me", dynamic...)
+	}
+}
+
+func (mt *MeTest) updateMe(t *testing.T) {
+	t.Log("Given the need for users to change their own profile")
+	{
+		var got user.User
+		mt.h.Patch("/v1/users/me").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(`{"name": "Renamed Gopher"}`).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if got.Name != "Renamed Gopher" || got.Email != mt.user.Email {
+			t.Fatalf("\t%s\tShould only change the name, got %+v", apitest.Failed, got)
+		}
+		t.Logf("\t%s\tShould only change the name", apitest.Succeeded)
+
+		mt.h.Patch("/v1/users/me").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(`{"email": "not-an-email"}`).
+			Do(t).
+			Status(http.StatusBadRequest)
+	}
+}
+
+func (mt *MeTest) updateMeRoles(t *testing.T) {
+	t.Log("Given the need to stop users from escalating their roles")
+	{
+		mt.h.Patch("/v1/users/me").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(`{"roles": ["ADMIN"]}`).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		var got user.User
+		mt.h.Get("/v1/users/me").
+			As(mt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if len(got.Roles) != 1 || got.Roles[0] != auth.RoleUser {
+			t.Fatalf("\t%s\tShould keep the roles, got %v", apitest.Failed, got.Roles)
+		}
+		t.Logf("\t%s\tShould keep the roles", apitest.Succeeded)
+	}
+}
+
+func (mt *MeTest) changePassword(t *testing.T) {
+	t.Log("Given the need for users to change their own password")
+	{
+		mt.h.Post("/v1/users/me/password").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(`{"current_password": "wrong", "password": "new-gophers", "password_confirm": "new-gophers"}`).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		mt.h.Post("/v1/users/me/password").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(`{"current_password": "gophers", "password": "new-gophers", "password_confirm": "typo"}`).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		mt.h.Post("/v1/users/me/password").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(`{"current_password": "gophers", "password": "new-gophers", "password_confirm": "new-gophers"}`).
+			Do(t).
+			Status(http.StatusNoContent)
+
+		mt.h.Get("/v1/users/token").
+			BasicAuth(mt.user.Email, "new-gophers").
+			Do(t).
+			Status(http.StatusOK)
+
+		mt.h.Get("/v1/users/token").
+			BasicAuth(mt.user.Email, "gophers").
+			Do(t).
+			Status(http.StatusUnauthorized)
+	}
+}
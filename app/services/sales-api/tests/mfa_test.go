@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"service/foundation/totp"
+	"testing"
+	"time"
+)
+
+type MFATest struct {
+	h      *apitest.Harness
+	user   user.User
+	secret string
+	step   int64
+	codes  []string
+}
+
+func TestMFA(t *testing.T) {
+	h := apitest.New(t)
+
+	mt := MFATest{
+		h:    h,
+		user: h.CreateUser("User Gopher", "mfa@example.com", "gophers", auth.RoleUser),
+	}
+
+	t.Run("enroll", mt.enroll)
+	t.Run("challenge", mt.challenge)
+	t.Run("recoveryCode", mt.recoveryCode)
+	t.Run("disable", mt.disable)
+}
+
+func (mt *MFATest) enroll(t *testing.T) {
+	t.Log("Given the need for users to set up an authenticator app")
+	{
+		var enr struct {
+			Secret string `json:"secret"`
+			URI    string `json:"uri"`
+		}
+		mt.h.Post("/v1/users/me/mfa/enroll").
+			As(mt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&enr)
+
+		if enr.Secret == "" || enr.URI == "" {
+			t.Fatalf("\t%s\tShould receive a secret, got %+v", apitest.Failed, enr)
+		}
+		t.Logf("\t%s\tShould receive a secret", apitest.Succeeded)
+
+		mt.h.Post("/v1/users/me/mfa/confirm").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(`{"code": "000000"}`).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		mt.secret = enr.Secret
+		mt.step = totp.Step(time.Now())
+
+		var got struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		mt.h.Post("/v1/users/me/mfa/confirm").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(map[string]string{"code": code(t, mt.secret, mt.step)}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if len(got.RecoveryCodes) != 10 {
+			t.Fatalf("\t%s\tShould receive recovery codes, got %v", apitest.Failed, got.RecoveryCodes)
+		}
+		t.Logf("\t%s\tShould receive recovery codes", apitest.Succeeded)
+		mt.codes = got.RecoveryCodes
+
+		mt.h.Post("/v1/users/me/mfa/enroll").
+			As(mt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusConflict)
+	}
+}
+
+func (mt *MFATest) challenge(t *testing.T) {
+	t.Log("Given the need to ask for the second factor on login")
+	{
+		challenge := mt.login(t)
+
+		mt.h.Get("/v1/users/me").
+			Token(challenge).
+			Do(t).
+			Status(http.StatusUnauthorized)
+		t.Logf("\t%s\tShould refuse the challenge as a token", apitest.Succeeded)
+
+		// The code used to confirm was spent, the next one is accepted
+		// within the allowed skew.
+		next := code(t, mt.secret, mt.step+1)
+
+		var got struct {
+			Token string `json:"token"`
+		}
+		mt.h.Post("/v1/users/token/mfa").
+			JSON(map[string]string{"challenge": challenge, "code": next}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if _, err := mt.h.Auth.ValidateToken(got.Token); err != nil {
+			t.Fatalf("\t%s\tShould receive a valid token: %v", apitest.Failed, err)
+		}
+		t.Logf("\t%s\tShould receive a valid token", apitest.Succeeded)
+
+		mt.h.Post("/v1/users/token/mfa").
+			JSON(map[string]string{"challenge": challenge, "code": next}).
+			Do(t).
+			Status(http.StatusUnauthorized)
+		t.Logf("\t%s\tShould refuse a replayed code", apitest.Succeeded)
+
+		mt.h.Post("/v1/users/token/mfa").
+			JSON(map[string]string{"challenge": mt.h.Token(mt.user.ID, auth.RoleUser), "code": next}).
+			Do(t).
+			Status(http.StatusUnauthorized)
+		t.Logf("\t%s\tShould refuse a regular token as the challenge", apitest.Succeeded)
+	}
+}
+
+func (mt *MFATest) recoveryCode(t *testing.T) {
+	t.Log("Given the need to log in without the authenticator app")
+	{
+		challenge := mt.login(t)
+		body := map[string]string{"challenge": challenge, "code": mt.codes[0]}
+
+		mt.h.Post("/v1/users/token/mfa").
+			JSON(body).
+			Do(t).
+			Status(http.StatusOK)
+
+		mt.h.Post("/v1/users/token/mfa").
+			JSON(body).
+			Do(t).
+			Status(http.StatusUnauthorized)
+		t.Logf("\t%s\tShould accept a recovery code once", apitest.Succeeded)
+	}
+}
+
+func (mt *MFATest) disable(t *testing.T) {
+	t.Log("Given the need for users to remove their second factor")
+	{
+		mt.h.Delete("/v1/users/me/mfa").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(`{"code": "not-a-code"}`).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		mt.h.Delete("/v1/users/me/mfa").
+			As(mt.user.ID, auth.RoleUser).
+			JSON(map[string]string{"code": mt.codes[1]}).
+			Do(t).
+			Status(http.StatusNoContent)
+
+		var got struct {
+			Token string `json:"token"`
+		}
+		mt.h.Get("/v1/users/token").
+			BasicAuth(mt.user.Email, "gophers").
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if got.Token == "" {
+			t.Fatalf("\t%s\tShould receive a token for the password alone", apitest.Failed)
+		}
+		t.Logf("\t%s\tShould receive a token for the password alone", apitest.Succeeded)
+	}
+}
+
+// login logs the user in with the password and returns the challenge.
+func (mt *MFATest) login(t *testing.T) string {
+	t.Helper()
+
+	var got struct {
+		Token       string `json:"token"`
+		MFARequired bool   `json:"mfa_required"`
+		Challenge   string `json:"challenge"`
+	}
+	mt.h.Get("/v1/users/token").
+		BasicAuth(mt.user.Email, "gophers").
+		Do(t).
+		Status(http.StatusOK).
+		Decode(&got)
+
+	if !got.MFARequired || got.Challenge == "" || got.Token != "" {
+		t.Fatalf("\t%s\tShould ask for the second factor, got %+v", apitest.Failed, got)
+	}
+	t.Logf("\t%s\tShould ask for the second factor", apitest.Succeeded)
+	return got.Challenge
+}
+
+// code returns the one time password of the step.
+func code(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	c, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to compute a code: %v", apitest.Failed, err)
+	}
+	return c
+}
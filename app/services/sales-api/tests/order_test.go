@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/order"
+	"service/domain/data/store/product"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+type OrderTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+	other user.User
+	books product.Product
+	toys  product.Product
+	order order.Order
+}
+
+func TestOrders(t *testing.T) {
+	h := apitest.New(t)
+
+	ot := OrderTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		other: h.CreateUser("Other Gopher", "other@example.com", "gophers", auth.RoleUser),
+		books: h.CreateProduct("Comic Books", 50, 10),
+		toys:  h.CreateProduct("McDonalds Toys", 75, 2),
+	}
+
+	t.Run("create", ot.create)
+	t.Run("outOfStock", ot.outOfStock)
+	t.Run("currency", ot.currency)
+	t.Run("query", ot.query)
+	t.Run("apiKey", ot.apiKey)
+}
+
+func (ot *OrderTest) create(t *testing.T) {
+	t.Log("Given the need for customers to buy baskets")
+	{
+		ot.h.Post("/v1/orders").
+			As(ot.user.ID, auth.RoleUser).
+			JSON(`{"items": []}`).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		ot.h.Post("/v1/orders").
+			As(ot.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": validate.GenerateUID(), "quantity": 1}}}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown product", apitest.Succeeded)
+
+		body := map[string]any{
+			"items": []any{
+				map[string]any{"product_id": ot.books.ID, "quantity": 2},
+				map[string]any{"product_id": ot.toys.ID, "quantity": 1},
+				map[string]any{"product_id": ot.books.ID, "quantity": 1},
+			},
+		}
+
+		ot.h.Post("/v1/orders").
+			As(ot.user.ID, auth.RoleUser).
+			JSON(body).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&ot.order)
+
+		if ot.order.Total != 3*50+75 || len(ot.order.Items) != 2 || ot.order.CustomerID != ot.user.ID {
+			t.Fatalf("\t%s\tShould price every line from the products, got %+v", apitest.Failed, ot.order)
+		}
+		t.Logf("\t%s\tShould price every line from the products", apitest.Succeeded)
+
+		books, err := ot.h.Products.QueryByID(context.Background(), ot.books.ID)
+		if err != nil || books.Quantity != 7 {
+			t.Fatalf("\t%s\tShould take the items out of stock, got %d %v", apitest.Failed, books.Quantity, err)
+		}
+		t.Logf("\t%s\tShould take the items out of stock", apitest.Succeeded)
+	}
+}
+
+func (ot *OrderTest) outOfStock(t *testing.T) {
+	t.Log("Given the need to only sell what is in stock")
+	{
+		body := map[string]any{
+			"items": []any{
+				map[string]any{"product_id": ot.books.ID, "quantity": 1},
+				map[string]any{"product_id": ot.toys.ID, "quantity": 2},
+			},
+		}
+
+		ot.h.Post("/v1/orders").
+			As(ot.other.ID, auth.RoleUser).
+			JSON(body).
+			Do(t).
+			Status(http.StatusConflict)
+
+		books, err := ot.h.Products.QueryByID(context.Background(), ot.books.ID)
+		if err != nil || books.Quantity != 7 {
+			t.Fatalf("\t%s\tShould not take any item out of stock, got %d %v", apitest.Failed, books.Quantity, err)
+		}
+		t.Logf("\t%s\tShould not take any item out of stock", apitest.Succeeded)
+	}
+}
+
+func (ot *OrderTest) currency(t *testing.T) {
+	t.Log("Given the need to never add up amounts of different currencies")
+	{
+		now := time.Now()
+		wine := product.Product{
+			ID:          validate.GenerateUID(),
+			Name:        "Bordeaux",
+			Cost:        1200,
+			Currency:    money.EUR,
+			Quantity:    10,
+			DateCreated: now,
+			DateUpdated: now,
+		}
+		if err := ot.h.Products.Create(context.Background(), wine); err != nil {
+			t.Fatalf("\t%s\tShould be able to create a product: %v", apitest.Failed, err)
+		}
+
+		ot.h.Post("/v1/orders").
+			As(ot.other.ID, auth.RoleUser).
+			JSON(map[string]any{
+				"items": []any{
+					map[string]any{"product_id": ot.books.ID, "quantity": 1},
+					map[string]any{"product_id": wine.ID, "quantity": 1},
+				},
+			}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse products priced in different currencies", apitest.Succeeded)
+
+		ot.h.Post("/v1/orders").
+			As(ot.other.ID, auth.RoleUser).
+			JSON(map[string]any{
+				"currency": "USD",
+				"items":    []any{map[string]any{"product_id": wine.ID, "quantity": 1}},
+			}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse products not priced in the currency asked for", apitest.Succeeded)
+
+		ot.h.Post("/v1/orders").
+			As(ot.other.ID, auth.RoleUser).
+			JSON(map[string]any{
+				"currency": "euro",
+				"items":    []any{map[string]any{"product_id": wine.ID, "quantity": 1}},
+			}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown currency", apitest.Succeeded)
+
+		var got order.Order
+		ot.h.Post("/v1/orders").
+			As(ot.other.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": wine.ID, "quantity": 2}}}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&got)
+
+		if got.Currency != money.EUR || got.Total != 2400 {
+			t.Fatalf("\t%s\tShould charge in the currency of the products, got %+v", apitest.Failed, got)
+		}
+		t.Logf("\t%s\tShould charge in the currency of the products", apitest.Succeeded)
+
+		books, err := ot.h.Products.QueryByID(context.Background(), ot.books.ID)
+		if err != nil || books.Quantity != 7 {
+			t.Fatalf("\t%s\tShould not take any item out of stock, got %d %v", apitest.Failed, books.Quantity, err)
+		}
+	}
+}
+
+func (ot *OrderTest) query(t *testing.T) {
+	t.Log("Given the need for customers to see their orders")
+	{
+		var got order.Order
+		ot.h.Get("/v1/orders/"+ot.order.ID).
+			As(ot.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if got.ID != ot.order.ID || len(got.Items) != 2 {
+			t.Fatalf("\t%s\tShould return the order with its items, got %+v", apitest.Failed, got)
+		}
+		t.Logf("\t%s\tShould return the order with its items", apitest.Succeeded)
+
+		ot.h.Get("/v1/orders/"+ot.order.ID).
+			As(ot.other.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		ot.h.Get("/v1/orders/"+ot.order.ID).
+			As(ot.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK)
+		t.Logf("\t%s\tShould only show orders to their customer and admins", apitest.Succeeded)
+
+		ot.h.Get("/v1/orders/"+validate.GenerateUID()).
+			As(ot.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusNotFound)
+
+		var orders []order.Order
+		ot.h.Get("/v1/users/"+ot.user.ID+"/orders/1/10").
+			As(ot.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&orders)
+
+		if len(orders) != 1 || orders[0].ID != ot.order.ID {
+			t.Fatalf("\t%s\tShould list the orders of the customer, got %+v", apitest.Failed, orders)
+		}
+		t.Logf("\t%s\tShould list the orders of the customer", apitest.Succeeded)
+
+		ot.h.Get("/v1/users/"+ot.user.ID+"/orders/1/10").
+			As(ot.other.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		ot.h.Get("/v1/users/"+ot.user.ID+"/orders/0/10").
+			As(ot.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusBadRequest)
+	}
+}
+
+func (ot *OrderTest) apiKey(t *testing.T) {
+	t.Log("Given the need to only place orders for users")
+	{
+		_, key := ot.h.CreateAPIKey(ot.admin.ID, "shop", auth.PermSalesWrite)
+
+		ot.h.Post("/v1/orders").
+			Header("X-API-Key", key).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": ot.books.ID, "quantity": 1}}}).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould refuse orders from an API key", apitest.Succeeded)
+	}
+}
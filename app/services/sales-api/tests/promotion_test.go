@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/order"
+	"service/domain/data/store/product"
+	promotionStore "service/domain/data/store/promotion"
+	refundStore "service/domain/data/store/refund"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+	"time"
+)
+
+type PromotionTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+	books product.Product
+	toys  product.Product
+	order order.Order
+}
+
+func TestPromotions(t *testing.T) {
+	h := apitest.New(t)
+
+	pt := PromotionTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		books: h.CreateProduct("Comic Books", 50, 10),
+		toys:  h.CreateProduct("McDonalds Toys", 75, 10),
+	}
+
+	t.Run("create", pt.create)
+	t.Run("order", pt.placeOrder)
+	t.Run("coupon", pt.coupon)
+	t.Run("refund", pt.refund)
+}
+
+func (pt *PromotionTest) create(t *testing.T) {
+	t.Log("Given the need to run promotions")
+	{
+		coupon := map[string]any{"code": "fiveoff", "kind": promotionStore.KindFixed, "value": 100, "currency": "USD", "usage_limit": 1}
+
+		pt.h.Post("/v1/coupons").
+			As(pt.user.ID, auth.RoleUser).
+			JSON(coupon).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff who manage promotions add coupons", apitest.Succeeded)
+
+		var cp promotionStore.Coupon
+		pt.h.Post("/v1/coupons").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(coupon).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&cp)
+
+		if cp.Code != "FIVEOFF" || cp.UsageLimit == nil || *cp.UsageLimit != 1 || cp.Used != 0 {
+			t.Fatalf("\t%s\tShould add the coupon under its code, got %+v", apitest.Failed, cp)
+		}
+		t.Logf("\t%s\tShould add the coupon under its code", apitest.Succeeded)
+
+		pt.h.Post("/v1/coupons").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(coupon).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse a code already given", apitest.Succeeded)
+
+		now := time.Now()
+		promo := map[string]any{
+			"name":       "Comic week",
+			"kind":       promotionStore.KindPercent,
+			"value":      120,
+			"product_id": pt.books.ID,
+			"starts_at":  now.Add(-time.Hour),
+			"ends_at":    now.Add(24 * time.Hour),
+		}
+
+		pt.h.Post("/v1/promotions").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(promo).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a discount of more than the price", apitest.Succeeded)
+
+		promo["value"] = 20
+		var p promotionStore.Promotion
+		pt.h.Post("/v1/promotions").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(promo).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&p)
+
+		var promos []promotionStore.Promotion
+		pt.h.Get("/v1/promotions/1/10").
+			As(pt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&promos)
+
+		if len(promos) != 1 || promos[0].ID != p.ID {
+			t.Fatalf("\t%s\tShould list the promotion, got %+v", apitest.Failed, promos)
+		}
+		t.Logf("\t%s\tShould list the promotion", apitest.Succeeded)
+	}
+}
+
+func (pt *PromotionTest) placeOrder(t *testing.T) {
+	t.Log("Given the need to discount orders")
+	{
+		pt.h.Post("/v1/orders").
+			As(pt.user.ID, auth.RoleUser).
+			JSON(map[string]any{
+				"coupon_code": "NOSUCHCODE",
+				"items":       []any{map[string]any{"product_id": pt.toys.ID, "quantity": 1}},
+			}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown coupon", apitest.Succeeded)
+
+		pt.h.Post("/v1/orders").
+			As(pt.user.ID, auth.RoleUser).
+			JSON(map[string]any{
+				"coupon_code": "fiveoff",
+				"items": []any{
+					map[string]any{"product_id": pt.books.ID, "quantity": 3},
+					map[string]any{"product_id": pt.toys.ID, "quantity": 2},
+				},
+			}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&pt.order)
+
+		// Books are 150 less 30 of promotion and toys 150, the coupon takes
+		// 100 off the 270 left and its odd cent goes to the first line.
+		if pt.order.Discount != 130 || pt.order.Total != 170 || pt.order.CouponID == nil || len(pt.order.Items) != 2 {
+			t.Fatalf("\t%s\tShould take the promotion then the coupon off, got %+v", apitest.Failed, pt.order)
+		}
+		t.Logf("\t%s\tShould take the promotion then the coupon off", apitest.Succeeded)
+
+		var got order.Order
+		pt.h.Get("/v1/orders/"+pt.order.ID).
+			As(pt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		books, toys := got.Items[0], got.Items[1]
+		if books.Total != 75 || len(books.Discounts) != 2 || books.Discounts[0].PromotionID == nil || books.Discounts[0].Amount != 30 || books.Discounts[1].Amount != 45 {
+			t.Fatalf("\t%s\tShould keep every discount of the line, got %+v", apitest.Failed, books)
+		}
+		if toys.Total != 95 || len(toys.Discounts) != 1 || toys.Discounts[0].CouponID == nil || toys.Discounts[0].Amount != 55 {
+			t.Fatalf("\t%s\tShould keep every discount of the line, got %+v", apitest.Failed, toys)
+		}
+		t.Logf("\t%s\tShould keep every discount of the line", apitest.Succeeded)
+	}
+}
+
+func (pt *PromotionTest) coupon(t *testing.T) {
+	t.Log("Given the need to limit how often a coupon is used")
+	{
+		pt.h.Post("/v1/orders").
+			As(pt.user.ID, auth.RoleUser).
+			JSON(map[string]any{
+				"coupon_code": "FIVEOFF",
+				"items":       []any{map[string]any{"product_id": pt.toys.ID, "quantity": 1}},
+			}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse a coupon used up", apitest.Succeeded)
+
+		toys, err := pt.h.Products.QueryByID(context.Background(), pt.toys.ID)
+		if err != nil || toys.Quantity != 8 {
+			t.Fatalf("\t%s\tShould not take any item out of stock, got %d %v", apitest.Failed, toys.Quantity, err)
+		}
+		t.Logf("\t%s\tShould not take any item out of stock", apitest.Succeeded)
+	}
+}
+
+func (pt *PromotionTest) refund(t *testing.T) {
+	t.Log("Given the need to refund discounted orders")
+	{
+		var got refundStore.Refund
+		pt.h.Post("/v1/orders/"+pt.order.ID+"/refunds").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"reason": refundStore.ReasonChangedMind,
+				"items":  []any{map[string]any{"line": 1, "quantity": 3}},
+			}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&got)
+
+		if got.Amount != 75 {
+			t.Fatalf("\t%s\tShould refund what was paid for the goods, got %+v", apitest.Failed, got)
+		}
+		t.Logf("\t%s\tShould refund what was paid for the goods", apitest.Succeeded)
+	}
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/order"
+	"service/domain/data/store/product"
+	"service/domain/data/store/purchase"
+	"service/domain/data/store/supplier"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type PurchaseTest struct {
+	h        *apitest.Harness
+	admin    user.User
+	user     user.User
+	books    product.Product
+	supplier supplier.Supplier
+	po       purchase.Order
+}
+
+func TestPurchase(t *testing.T) {
+	h := apitest.New(t)
+
+	pt := PurchaseTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		books: h.CreateProduct("Comic Books", 50, 0),
+	}
+
+	t.Run("supplier", pt.createSupplier)
+	t.Run("order", pt.order)
+	t.Run("receive", pt.receive)
+	t.Run("margins", pt.margins)
+}
+
+func (pt *PurchaseTest) createSupplier(t *testing.T) {
+	t.Log("Given the need to keep track of who we buy from")
+	{
+		body := map[string]any{"name": "Gopher Wholesale", "email": "orders@wholesale.example.com", "currency": "USD"}
+
+		pt.h.Post("/v1/suppliers").
+			As(pt.user.ID, auth.RoleUser).
+			JSON(body).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff add suppliers", apitest.Succeeded)
+
+		pt.h.Post("/v1/suppliers").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(body).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&pt.supplier)
+
+		if pt.supplier.ID == "" || pt.supplier.Name != "Gopher Wholesale" {
+			t.Fatalf("\t%s\tShould be able to add a supplier, got %+v", apitest.Failed, pt.supplier)
+		}
+		t.Logf("\t%s\tShould be able to add a supplier", apitest.Succeeded)
+
+		pt.h.Post("/v1/suppliers").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(body).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse a second supplier with the same name", apitest.Succeeded)
+	}
+}
+
+func (pt *PurchaseTest) order(t *testing.T) {
+	t.Log("Given the need to order stock from a supplier")
+	{
+		pt.h.Post("/v1/purchase-orders").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"supplier_id": pt.supplier.ID,
+				"lines":       []any{map[string]any{"product_id": pt.books.ID, "quantity": 10, "unit_cost": 20}},
+			}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&pt.po)
+
+		if pt.po.Status != purchase.StatusDraft || pt.po.Total != 200 || len(pt.po.Lines) != 1 {
+			t.Fatalf("\t%s\tShould raise a draft order, got %+v", apitest.Failed, pt.po)
+		}
+		t.Logf("\t%s\tShould raise a draft order", apitest.Succeeded)
+
+		pt.h.Post("/v1/purchase-orders/"+pt.po.ID+"/receive").
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse goods for an order not yet sent", apitest.Succeeded)
+
+		pt.h.Post("/v1/purchase-orders/"+pt.po.ID+"/submit").
+			As(pt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&pt.po)
+
+		if pt.po.Status != purchase.StatusOrdered {
+			t.Fatalf("\t%s\tShould send the order to the supplier, got %+v", apitest.Failed, pt.po)
+		}
+		t.Logf("\t%s\tShould send the order to the supplier", apitest.Succeeded)
+	}
+}
+
+func (pt *PurchaseTest) receive(t *testing.T) {
+	t.Log("Given the need to receive goods from a supplier")
+	{
+		url := "/v1/purchase-orders/" + pt.po.ID + "/receive"
+
+		pt.h.Post(url).
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"lines": []any{map[string]any{"line": 1, "quantity": 11}}}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse more than was ordered", apitest.Succeeded)
+
+		pt.h.Post(url).
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"lines": []any{map[string]any{"line": 1, "quantity": 4}}}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&pt.po)
+
+		if pt.po.Status != purchase.StatusPartiallyReceived || pt.po.Lines[0].Received != 4 {
+			t.Fatalf("\t%s\tShould receive part of the order, got %+v", apitest.Failed, pt.po)
+		}
+		t.Logf("\t%s\tShould receive part of the order", apitest.Succeeded)
+
+		pt.h.Post(url).
+			As(pt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&pt.po)
+
+		if pt.po.Status != purchase.StatusReceived || pt.po.Lines[0].Received != 10 {
+			t.Fatalf("\t%s\tShould receive the rest of the order, got %+v", apitest.Failed, pt.po)
+		}
+		t.Logf("\t%s\tShould receive the rest of the order", apitest.Succeeded)
+
+		pt.h.Post("/v1/purchase-orders/"+pt.po.ID+"/cancel").
+			As(pt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse to cancel a received order", apitest.Succeeded)
+
+		var p product.Product
+		pt.h.Get("/v1/products/"+pt.books.ID).
+			As(pt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&p)
+
+		if p.Quantity != 10 || p.PurchaseCost != 20 {
+			t.Fatalf("\t%s\tShould stock the goods at what they cost, got %+v", apitest.Failed, p)
+		}
+		t.Logf("\t%s\tShould stock the goods at what they cost", apitest.Succeeded)
+
+		var mvs []product.Movement
+		pt.h.Get("/v1/inventory/movements/1/10?product_id="+pt.books.ID).
+			As(pt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&mvs)
+
+		if len(mvs) != 2 || mvs[0].Kind != product.KindReceipt || mvs[0].ReferenceID == nil || *mvs[0].ReferenceID != pt.po.ID {
+			t.Fatalf("\t%s\tShould record the receipts against the order, got %+v", apitest.Failed, mvs)
+		}
+		t.Logf("\t%s\tShould record the receipts against the order", apitest.Succeeded)
+	}
+}
+
+func (pt *PurchaseTest) margins(t *testing.T) {
+	t.Log("Given the need to know what we make on what we sell")
+	{
+		pt.h.Post("/v1/orders").
+			As(pt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": pt.books.ID, "quantity": 3}}}).
+			Do(t).
+			Status(http.StatusCreated)
+
+		pt.h.Get("/v1/reports/margins").
+			As(pt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff see margins", apitest.Succeeded)
+
+		pt.h.Get("/v1/reports/margins?from=2023-13-01").
+			As(pt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a date it cannot read", apitest.Succeeded)
+
+		var ms []order.Margin
+		pt.h.Get("/v1/reports/margins").
+			As(pt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&ms)
+
+		if len(ms) != 1 || ms[0].ProductID != pt.books.ID || ms[0].Units != 3 || ms[0].Revenue != 150 || ms[0].Cost != 60 || ms[0].Margin != 90 {
+			t.Fatalf("\t%s\tShould work margins out from the purchase cost, got %+v", apitest.Failed, ms)
+		}
+		t.Logf("\t%s\tShould work margins out from the purchase cost", apitest.Succeeded)
+	}
+}
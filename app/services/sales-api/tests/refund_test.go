@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/core/refund"
+	"service/domain/data/store/order"
+	"service/domain/data/store/product"
+	promotionStore "service/domain/data/store/promotion"
+	refundStore "service/domain/data/store/refund"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+	"time"
+)
+
+type RefundTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+	other user.User
+	books product.Product
+	order order.Order
+}
+
+func TestRefunds(t *testing.T) {
+	h := apitest.New(t)
+
+	rt := RefundTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		other: h.CreateUser("Other Gopher", "other@example.com", "gophers", auth.RoleUser),
+		books: h.CreateProduct("Comic Books", 50, 10),
+	}
+
+	h.Post("/v1/orders").
+		As(rt.user.ID, auth.RoleUser).
+		JSON(map[string]any{"items": []any{map[string]any{"product_id": rt.books.ID, "quantity": 3}}}).
+		Do(t).
+		Status(http.StatusCreated).
+		Decode(&rt.order)
+
+	t.Run("create", rt.create)
+	t.Run("exceeds", rt.exceeds)
+	t.Run("query", rt.query)
+	t.Run("free", rt.free)
+}
+
+func (rt *RefundTest) create(t *testing.T) {
+	t.Log("Given the need to refund part of an order")
+	{
+		body := map[string]any{
+			"reason": refundStore.ReasonDamaged,
+			"items":  []any{map[string]any{"line": 1, "quantity": 1, "restock": true}},
+		}
+
+		rt.h.Post("/v1/orders/"+rt.order.ID+"/refunds").
+			As(rt.user.ID, auth.RoleUser).
+			JSON(body).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff who can refund do so", apitest.Succeeded)
+
+		var got refundStore.Refund
+		rt.h.Post("/v1/orders/"+rt.order.ID+"/refunds").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(body).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&got)
+
+		if got.Amount != 50 || got.ApprovedBy != rt.admin.ID || len(got.Items) != 1 || !got.Items[0].Restocked {
+			t.Fatalf("\t%s\tShould refund the price of the goods returned, got %+v", apitest.Failed, got)
+		}
+		t.Logf("\t%s\tShould refund the price of the goods returned", apitest.Succeeded)
+
+		books, err := rt.h.Products.QueryByID(context.Background(), rt.books.ID)
+		if err != nil || books.Quantity != 8 {
+			t.Fatalf("\t%s\tShould put the goods back in stock, got %d %v", apitest.Failed, books.Quantity, err)
+		}
+		t.Logf("\t%s\tShould put the goods back in stock", apitest.Succeeded)
+
+		events, err := rt.h.Audit.QueryBySubject(context.Background(), rt.order.ID)
+		if err != nil || len(events) != 1 || events[0].Action != refund.ActionRefunded || events[0].Actor != rt.admin.ID {
+			t.Fatalf("\t%s\tShould record who approved the refund, got %+v %v", apitest.Failed, events, err)
+		}
+		t.Logf("\t%s\tShould record who approved the refund", apitest.Succeeded)
+	}
+}
+
+func (rt *RefundTest) exceeds(t *testing.T) {
+	t.Log("Given the need to never refund more than was paid")
+	{
+		rt.h.Post("/v1/orders/"+rt.order.ID+"/refunds").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"reason": refundStore.ReasonChangedMind,
+				"items":  []any{map[string]any{"line": 1, "quantity": 3}},
+			}).
+			Do(t).
+			Status(http.StatusConflict)
+
+		rt.h.Post("/v1/orders/"+rt.order.ID+"/refunds").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"reason": refundStore.ReasonOther,
+				"items":  []any{map[string]any{"line": 1, "amount": 101}},
+			}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse more than was paid", apitest.Succeeded)
+
+		rt.h.Post("/v1/orders/"+rt.order.ID+"/refunds").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"reason": refundStore.ReasonOther,
+				"items":  []any{map[string]any{"line": 2, "quantity": 1}},
+			}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown line", apitest.Succeeded)
+
+		rt.h.Post("/v1/orders/"+rt.order.ID+"/refunds").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"reason": "bored",
+				"items":  []any{map[string]any{"line": 1, "quantity": 1}},
+			}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an unknown reason", apitest.Succeeded)
+	}
+}
+
+func (rt *RefundTest) query(t *testing.T) {
+	t.Log("Given the need for customers to see their refunds")
+	{
+		var got []refundStore.Refund
+		rt.h.Get("/v1/orders/"+rt.order.ID+"/refunds").
+			As(rt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if len(got) != 1 || got[0].OrderID != rt.order.ID {
+			t.Fatalf("\t%s\tShould list the refunds of the order, got %+v", apitest.Failed, got)
+		}
+		t.Logf("\t%s\tShould list the refunds of the order", apitest.Succeeded)
+
+		rt.h.Get("/v1/orders/"+rt.order.ID+"/refunds").
+			As(rt.other.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould not show refunds to other customers", apitest.Succeeded)
+	}
+}
+
+func (rt *RefundTest) free(t *testing.T) {
+	t.Log("Given the need to take back goods given away for free")
+	{
+		stickers := rt.h.CreateProduct("Stickers", 20, 5)
+
+		rt.h.Post("/v1/promotions").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"name":       "Free stickers",
+				"kind":       promotionStore.KindPercent,
+				"value":      100,
+				"product_id": stickers.ID,
+				"starts_at":  time.Now().Add(-time.Hour),
+				"ends_at":    time.Now().Add(time.Hour),
+			}).
+			Do(t).
+			Status(http.StatusCreated)
+
+		var o order.Order
+		rt.h.Post("/v1/orders").
+			As(rt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": stickers.ID, "quantity": 2}}}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&o)
+
+		rt.h.Post("/v1/orders/"+o.ID+"/refunds").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"reason": refundStore.ReasonOther,
+				"items":  []any{map[string]any{"line": 1, "quantity": 0}},
+			}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a refund returning neither goods nor money", apitest.Succeeded)
+
+		var got refundStore.Refund
+		rt.h.Post("/v1/orders/"+o.ID+"/refunds").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{
+				"reason": refundStore.ReasonChangedMind,
+				"items":  []any{map[string]any{"line": 1, "quantity": 2, "restock": true}},
+			}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&got)
+
+		if got.Amount != 0 || len(got.Items) != 1 || got.Items[0].Quantity != 2 {
+			t.Fatalf("\t%s\tShould take the goods back for nothing, got %+v", apitest.Failed, got)
+		}
+		t.Logf("\t%s\tShould take the goods back for nothing", apitest.Succeeded)
+
+		p, err := rt.h.Products.QueryByID(context.Background(), stickers.ID)
+		if err != nil || p.Quantity != 5 {
+			t.Fatalf("\t%s\tShould put the goods back in stock, got %d %v", apitest.Failed, p.Quantity, err)
+		}
+		t.Logf("\t%s\tShould put the goods back in stock", apitest.Succeeded)
+	}
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+var resetLink = regexp.MustCompile(regexp.QuoteMeta(apitest.ResetURL) + `\S+`)
+
+type ResetTest struct {
+	h    *apitest.Harness
+	user user.User
+}
+
+func TestPasswordReset(t *testing.T) {
+	h := apitest.New(t)
+
+	rt := ResetTest{
+		h:    h,
+		user: h.CreateUser("User Gopher", "forgetful@example.com", "gophers", auth.RoleUser),
+	}
+
+	t.Run("forgotUnknown", rt.forgotUnknown)
+	t.Run("reset", rt.reset)
+	t.Run("resetInvalid", rt.resetInvalid)
+}
+
+func (rt *ResetTest) forgotUnknown(t *testing.T) {
+	t.Log("Given the need to not reveal which emails have an account")
+	{
+		known := rt.h.Post("/v1/users/password/forgot").
+			JSON(`{"email": "forgetful@example.com"}`).
+			Do(t).
+			Status(http.StatusAccepted)
+
+		unknown := rt.h.Post("/v1/users/password/forgot").
+			JSON(`{"email": "nobody@example.com"}`).
+			Do(t).
+			Status(http.StatusAccepted).
+			Golden("forgot")
+
+		if string(known.Body) != string(unknown.Body) {
+			t.Fatalf("\t%s\tShould answer the same for unknown emails:\n%s\n%s", apitest.Failed, known.Body, unknown.Body)
+		}
+		t.Logf("\t%s\tShould answer the same for unknown emails", apitest.Succeeded)
+
+		if n := len(rt.h.Mail.Messages("nobody@example.com")); n != 0 {
+			t.Fatalf("\t%s\tShould not mail unknown emails, sent %d", apitest.Failed, n)
+		}
+		t.Logf("\t%s\tShould not mail unknown emails", apitest.Succeeded)
+	}
+}
+
+func (rt *ResetTest) reset(t *testing.T) {
+	t.Log("Given the need for users to reset a forgotten password")
+	{
+		rt.h.Post("/v1/users/password/forgot").
+			JSON(`{"email": "forgetful@example.com"}`).
+			Do(t).
+			Status(http.StatusAccepted)
+
+		token := rt.lastToken(t)
+
+		body := map[string]string{
+			"token":            token,
+			"password":         "new-gophers",
+			"password_confirm": "new-gophers",
+		}
+
+		rt.h.Post("/v1/users/password/reset").
+			JSON(body).
+			Do(t).
+			Status(http.StatusNoContent)
+
+		rt.h.Get("/v1/users/token").
+			BasicAuth(rt.user.Email, "new-gophers").
+			Do(t).
+			Status(http.StatusOK)
+
+		t.Log("\tWhen using the token a second time")
+		rt.h.Post("/v1/users/password/reset").
+			JSON(body).
+			Do(t).
+			Status(http.StatusBadRequest)
+	}
+}
+
+func (rt *ResetTest) resetInvalid(t *testing.T) {
+	t.Log("Given the need to reject made up tokens")
+	{
+		rt.h.Post("/v1/users/password/reset").
+			JSON(`{"token": "made-up", "password": "gophers", "password_confirm": "gophers"}`).
+			Do(t).
+			Status(http.StatusBadRequest).
+			Golden("resetInvalid")
+	}
+}
+
+// lastToken pulls the token out of the last reset email the user got.
+func (rt *ResetTest) lastToken(t *testing.T) string {
+	t.Helper()
+
+	msgs := rt.h.Mail.Messages(rt.user.Email)
+	if len(msgs) == 0 {
+		t.Fatalf("\t%s\tShould have mailed a reset link", apitest.Failed)
+	}
+
+	link := resetLink.FindString(msgs[len(msgs)-1].Body)
+	u, err := url.Parse(link)
+	if err != nil || u.Query().Get("token") == "" {
+		t.Fatalf("\t%s\tShould find the token in the email:\n%s", apitest.Failed, msgs[len(msgs)-1].Body)
+	}
+	return u.Query().Get("token")
+}
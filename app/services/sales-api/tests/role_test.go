@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type RolesTest struct {
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
+}
+
+func TestRoles(t *testing.T) {
+	h := apitest.New(t)
+
+	rt := RolesTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+	}
+
+	t.Run("query", rt.query)
+	t.Run("create", rt.create)
+	t.Run("token", rt.token)
+	t.Run("update", rt.update)
+	t.Run("delete", rt.delete)
+}
+
+func (rt *RolesTest) query(t *testing.T) {
+	t.Log("Given the need for admins to review roles")
+	{
+		rt.h.Get("/v1/roles").
+			As(rt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		rt.h.Get("/v1/roles").
+			As(rt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Golden("roles")
+
+		rt.h.Get("/v1/permissions").
+			As(rt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Golden("permissions")
+	}
+}
+
+func (rt *RolesTest) create(t *testing.T) {
+	t.Log("Given the need for admins to add roles")
+	{
+		rt.h.Post("/v1/roles").
+			As(rt.user.ID, auth.RoleUser).
+			JSON(`{"name": "SUPPORT", "permissions": ["sales:refund"]}`).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		rt.h.Post("/v1/roles").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(`{"name": "SUPPORT", "permissions": ["sales:everything"]}`).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		rt.h.Post("/v1/roles").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(`{"name": "support", "permissions": ["sales:refund"]}`).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		rt.h.Post("/v1/roles").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(`{"name": "SUPPORT", "description": "Support agents", "permissions": ["sales:refund", "sales:read", "sales:refund"]}`).
+			Do(t).
+			Status(http.StatusCreated).
+			Golden("createRole", "date_created", "date_updated")
+
+		rt.h.Post("/v1/roles").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(`{"name": "SUPPORT", "permissions": []}`).
+			Do(t).
+			Status(http.StatusConflict)
+	}
+}
+
+func (rt *RolesTest) token(t *testing.T) {
+	t.Log("Given the need for tokens to carry the permissions of the roles")
+	{
+		rt.h.Put("/v1/users/"+rt.user.ID).
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(`{"roles": ["USER", "SUPPORT"]}`).
+			Do(t).
+			Status(http.StatusOK)
+
+		var got struct {
+			Token string `json:"token"`
+		}
+		rt.h.Get("/v1/users/token").
+			BasicAuth(rt.user.Email, "gophers").
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		claims, err := rt.h.Auth.ValidateToken(got.Token)
+		if err != nil {
+			t.Fatalf("\t%s\tShould receive a valid token: %v", apitest.Failed, err)
+		}
+		if !claims.Permitted(auth.PermProductsRead, auth.PermSalesRefund) || claims.Permitted(auth.PermRolesRead) {
+			t.Fatalf("\t%s\tShould carry the permissions of both roles, got %v", apitest.Failed, claims.Permissions)
+		}
+		t.Logf("\t%s\tShould carry the permissions of both roles", apitest.Succeeded)
+	}
+}
+
+func (rt *RolesTest) update(t *testing.T) {
+	t.Log("Given the need for admins to change what a role grants")
+	{
+		rt.h.Put("/v1/roles/SUPPORT").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(`{"permissions": ["roles:read"]}`).
+			Do(t).
+			Status(http.StatusOK)
+
+		rt.h.Get("/v1/roles").
+			As(rt.user.ID, auth.RoleUser, "SUPPORT").
+			Do(t).
+			Status(http.StatusOK)
+
+		rt.h.Put("/v1/roles/NOBODY").
+			As(rt.admin.ID, auth.RoleAdmin).
+			JSON(`{"description": "Nobody"}`).
+			Do(t).
+			Status(http.StatusNotFound)
+	}
+}
+
+func (rt *RolesTest) delete(t *testing.T) {
+	t.Log("Given the need for admins to remove roles")
+	{
+		rt.h.Delete("/v1/roles/ADMIN").
+			As(rt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusConflict)
+
+		rt.h.Delete("/v1/roles/SUPPORT").
+			As(rt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusNoContent)
+
+		rt.h.Get("/v1/roles/SUPPORT").
+			As(rt.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusNotFound)
+	}
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/product"
+	"service/domain/data/store/purchase"
+	"service/domain/data/store/stocktake"
+	"service/domain/data/store/supplier"
+	"service/domain/data/store/transfer"
+	"service/domain/data/store/user"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type StockKeyTest struct {
+	h        *apitest.Harness
+	admin    user.User
+	books    product.Product
+	north    warehouse.Warehouse
+	supplier supplier.Supplier
+	keyID    string
+	key      string
+}
+
+func TestStockKey(t *testing.T) {
+	h := apitest.New(t)
+
+	kt := StockKeyTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		books: h.CreateProduct("Comic Books", 50, 10),
+	}
+	kt.keyID, kt.key = h.CreateAPIKey(kt.admin.ID, "warehouse", auth.PermInventoryRead, auth.PermInventoryWrite)
+
+	h.Post("/v1/warehouses").
+		As(kt.admin.ID, auth.RoleAdmin).
+		JSON(map[string]any{"code": "NORTH", "name": "North"}).
+		Do(t).
+		Status(http.StatusCreated).
+		Decode(&kt.north)
+
+	h.Post("/v1/suppliers").
+		As(kt.admin.ID, auth.RoleAdmin).
+		JSON(map[string]any{"name": "Gopher Wholesale", "email": "orders@wholesale.example.com", "currency": "USD"}).
+		Do(t).
+		Status(http.StatusCreated).
+		Decode(&kt.supplier)
+
+	t.Run("warehouse", kt.warehouse)
+	t.Run("transfer", kt.transfer)
+	t.Run("stocktake", kt.stocktake)
+	t.Run("purchase", kt.purchase)
+	t.Run("movements", kt.movements)
+}
+
+func (kt *StockKeyTest) warehouse(t *testing.T) {
+	t.Log("Given the need for services to set and adjust stock with an API key")
+	{
+		kt.h.Put("/v1/warehouses/"+kt.north.ID+"/stock").
+			Header("X-API-Key", kt.key).
+			JSON(map[string]any{"product_id": kt.books.ID, "quantity": 4}).
+			Do(t).
+			Status(http.StatusOK)
+		t.Logf("\t%s\tShould let a key set the level of a warehouse", apitest.Succeeded)
+
+		kt.h.Post("/v1/warehouses/"+warehouse.MainID+"/adjustments").
+			Header("X-API-Key", kt.key).
+			JSON(map[string]any{"product_id": kt.books.ID, "quantity": -1, "reason": product.ReasonDamaged}).
+			Do(t).
+			Status(http.StatusCreated)
+		t.Logf("\t%s\tShould let a key adjust stock", apitest.Succeeded)
+	}
+}
+
+func (kt *StockKeyTest) transfer(t *testing.T) {
+	t.Log("Given the need for services to move stock with an API key")
+	{
+		var tr transfer.Transfer
+		kt.h.Post("/v1/transfers").
+			Header("X-API-Key", kt.key).
+			JSON(map[string]any{
+				"from_warehouse_id": warehouse.MainID,
+				"to_warehouse_id":   kt.north.ID,
+				"items":             []any{map[string]any{"product_id": kt.books.ID, "quantity": 2}},
+			}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&tr)
+
+		if tr.CreatedBy != kt.keyID {
+			t.Fatalf("\t%s\tShould record the key as the sender, got %+v", apitest.Failed, tr)
+		}
+		t.Logf("\t%s\tShould record the key as the sender", apitest.Succeeded)
+
+		kt.h.Post("/v1/transfers/"+tr.ID+"/receive").
+			Header("X-API-Key", kt.key).
+			Do(t).
+			Status(http.StatusOK)
+		t.Logf("\t%s\tShould let a key receive a transfer", apitest.Succeeded)
+	}
+}
+
+func (kt *StockKeyTest) stocktake(t *testing.T) {
+	t.Log("Given the need for services to count stock with an API key")
+	{
+		var st stocktake.Stocktake
+		kt.h.Post("/v1/stocktakes").
+			Header("X-API-Key", kt.key).
+			JSON(map[string]any{"warehouse_id": kt.north.ID}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&st)
+
+		if st.CreatedBy != kt.keyID {
+			t.Fatalf("\t%s\tShould record the key as the counter, got %+v", apitest.Failed, st)
+		}
+		t.Logf("\t%s\tShould record the key as the counter", apitest.Succeeded)
+
+		kt.h.Put("/v1/stocktakes/"+st.ID+"/counts").
+			Header("X-API-Key", kt.key).
+			JSON(map[string]any{"counts": []any{map[string]any{"product_id": kt.books.ID, "counted": 5}}}).
+			Do(t).
+			Status(http.StatusOK)
+
+		kt.h.Post("/v1/stocktakes/"+st.ID+"/post").
+			Header("X-API-Key", kt.key).
+			Do(t).
+			Status(http.StatusOK)
+		t.Logf("\t%s\tShould let a key post a stocktake", apitest.Succeeded)
+	}
+}
+
+func (kt *StockKeyTest) purchase(t *testing.T) {
+	t.Log("Given the need for services to buy stock with an API key")
+	{
+		var po purchase.Order
+		kt.h.Post("/v1/purchase-orders").
+			Header("X-API-Key", kt.key).
+			JSON(map[string]any{
+				"supplier_id": kt.supplier.ID,
+				"lines":       []any{map[string]any{"product_id": kt.books.ID, "quantity": 3, "unit_cost": 20}},
+			}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&po)
+
+		if po.CreatedBy != kt.keyID {
+			t.Fatalf("\t%s\tShould record the key as the buyer, got %+v", apitest.Failed, po)
+		}
+		t.Logf("\t%s\tShould record the key as the buyer", apitest.Succeeded)
+
+		kt.h.Post("/v1/purchase-orders/"+po.ID+"/submit").
+			Header("X-API-Key", kt.key).
+			Do(t).
+			Status(http.StatusOK)
+
+		kt.h.Post("/v1/purchase-orders/"+po.ID+"/receive").
+			Header("X-API-Key", kt.key).
+			JSON(map[string]any{}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&po)
+
+		if po.Status != purchase.StatusReceived {
+			t.Fatalf("\t%s\tShould let a key receive the goods, got %+v", apitest.Failed, po)
+		}
+		t.Logf("\t%s\tShould let a key receive the goods", apitest.Succeeded)
+	}
+}
+
+func (kt *StockKeyTest) movements(t *testing.T) {
+	t.Log("Given the need to see which key moved stock")
+	{
+		var mvs []product.Movement
+		kt.h.Get("/v1/inventory/movements/1/20?product_id="+kt.books.ID).
+			Header("X-API-Key", kt.key).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&mvs)
+
+		var byKey int
+		for _, mv := range mvs {
+			if mv.CreatedBy == nil {
+				continue
+			}
+			if *mv.CreatedBy != kt.keyID {
+				t.Fatalf("\t%s\tShould record the key on the movements it made, got %+v", apitest.Failed, mv)
+			}
+			byKey++
+		}
+
+		if byKey == 0 || len(mvs) < 5 {
+			t.Fatalf("\t%s\tShould record the key on the movements it made, got %+v", apitest.Failed, mvs)
+		}
+		t.Logf("\t%s\tShould record the key on the movements it made", apitest.Succeeded)
+	}
+}
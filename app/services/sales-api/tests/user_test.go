@@ -1,92 +1,244 @@
 package tests
 
 import (
-	"encoding/json"
 	"net/http"
-	"net/http/httptest"
-	"os"
-	"service/app/services/sales-api/handlers"
-	"service/domain/data/tests"
+	"service/app/services/sales-api/apitest"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"service/domain/sys/validate"
 	"testing"
 )
 
+// dynamic are the fields that change between runs.
+var dynamic = []string{"id", "date_created", "date_updated"}
+
 type UsersTest struct {
-	app        http.Handler
-	userToken  string
-	adminToken string
+	h     *apitest.Harness
+	admin user.User
+	user  user.User
 }
 
 func TestUsers(t *testing.T) {
-	intTest := tests.NewIntegration(
-		t,
-		tests.DBContainer{
-			Image: "postgres:14-alpine",
-			Port:  "5432",
-			Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
-		},
-	)
-	t.Cleanup(intTest.Teardown)
-
-	shutdown := make(chan os.Signal, 1)
-	userTests := UsersTest{
-		app: handlers.AppAPIMux(handlers.APIMuxConfig{
-			Shutdown: shutdown,
-			Log:      intTest.Log,
-			Auth:     intTest.Auth,
-			DB:       intTest.DB,
-		}),
-		userToken:  intTest.Token("user@admin.com", "secret"),
-		adminToken: intTest.Token("admin@admin.com", "secret"),
+	h := apitest.New(t)
+
+	ut := UsersTest{
+		h:     h,
+		admin: h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:  h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
 	}
-	t.Run("genToken200", userTests.genToken200)
-	t.Run("genToken404", userTests.genToken200)
+
+	t.Run("token200", ut.token200)
+	t.Run("token401", ut.token401)
+	t.Run("tokenUnknown", ut.tokenUnknown)
+	t.Run("query200", ut.query200)
+	t.Run("query403", ut.query403)
+	t.Run("queryByID", ut.queryByID)
+	t.Run("create201", ut.create201)
+	t.Run("create400", ut.create400)
+	t.Run("create409", ut.create409)
+	t.Run("update", ut.update)
+	t.Run("delete", ut.delete)
 }
 
-func (ut *UsersTest) genToken200(t *testing.T) {
-	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
-	w := httptest.NewRecorder()
+func (ut *UsersTest) token200(t *testing.T) {
+	t.Log("Given the need to issue tokens to known users")
+	{
+		var got struct {
+			Token string `json:"token"`
+		}
 
-	r.SetBasicAuth("admin@example.com", "secret")
-	ut.app.ServeHTTP(w, r)
+		ut.h.Get("/v1/users/token").
+			BasicAuth("admin@example.com", "gophers").
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
 
-	t.Log("Given the need to issue tokens to known users")
+		claims, err := ut.h.Auth.ValidateToken(got.Token)
+		if err != nil {
+			t.Fatalf("\t%s\tShould receive a valid token: %v", apitest.Failed, err)
+		}
+		if claims.Subject != ut.admin.ID {
+			t.Fatalf("\t%s\tShould receive a token for the admin, got subject %q", apitest.Failed, claims.Subject)
+		}
+		t.Logf("\t%s\tShould receive a token for the admin", apitest.Succeeded)
+	}
+}
+
+func (ut *UsersTest) token401(t *testing.T) {
+	t.Log("Given the need to deny tokens without credentials")
+	{
+		ut.h.Get("/v1/users/token").
+			Do(t).
+			Status(http.StatusUnauthorized).
+			Golden("token401")
+	}
+}
+
+func (ut *UsersTest) tokenUnknown(t *testing.T) {
+	t.Log("Given the need to deny tokens to unknown users without saying so")
+	{
+		unknown := ut.h.Get("/v1/users/token").
+			BasicAuth("nobody@example.com", "gophers").
+			Do(t).
+			Status(http.StatusUnauthorized).
+			Golden("tokenUnknown")
+
+		wrong := ut.h.Get("/v1/users/token").
+			BasicAuth(ut.user.Email, "wrong").
+			Do(t).
+			Status(http.StatusUnauthorized)
+
+		if string(unknown.Body) != string(wrong.Body) {
+			t.Fatalf("\t%s\tShould answer the same as for a wrong password:\n%s\n%s", apitest.Failed, unknown.Body, wrong.Body)
+		}
+		t.Logf("\t%s\tShould answer the same as for a wrong password", apitest.Succeeded)
+	}
+}
+
+func (ut *UsersTest) query200(t *testing.T) {
+	t.Log("Given the need for admins to page through users")
+	{
+		var got []user.User
+		ut.h.Get("/v1/users/1/10").
+			As(ut.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if len(got) != 2 {
+			t.Fatalf("\t%s\tShould get back both users, got %d", apitest.Failed, len(got))
+		}
+		t.Logf("\t%s\tShould get back both users", apitest.Succeeded)
+	}
+}
+
+func (ut *UsersTest) query403(t *testing.T) {
+	t.Log("Given the need to keep the user list to admins")
 	{
-		testID := 0
-		t.Log("\tTest %d\t when fething a token with valid credentials", testID)
-		{
-			if w.Code != http.StatusOK {
-				t.Fatalf("\t%s\t Test %d \t Should recieve a status code of 200 for response %v", tests.Failed, testID, w.Code)
-			}
-			t.Logf("\t%s\t Test %d \t Should recieve a status code of 200 for response ", tests.Succeeded, testID)
-
-			var got struct {
-				Token string `json:"token"`
-			}
-
-			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
-				t.Fatalf("\t%s\tTest %d\tShould be able to unmarshal the response %v", tests.Failed, testID, err)
-			}
-			t.Logf("\t%s\tTest %d\tShould be able to unmarshal the response", tests.Succeeded, testID)
+		ut.h.Get("/v1/users/1/10").
+			Do(t).
+			Status(http.StatusUnauthorized)
+
+		ut.h.Get("/v1/users/1/10").
+			As(ut.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+	}
+}
+
+func (ut *UsersTest) queryByID(t *testing.T) {
+	t.Log("Given the need for users to see their own record only")
+	{
+		var got user.User
+		ut.h.Get("/v1/users/"+ut.user.ID).
+			As(ut.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if got.Email != ut.user.Email {
+			t.Fatalf("\t%s\tShould get back the user, got %q", apitest.Failed, got.Email)
 		}
+		t.Logf("\t%s\tShould get back the user", apitest.Succeeded)
+
+		ut.h.Get("/v1/users/"+ut.admin.ID).
+			As(ut.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusForbidden)
+
+		ut.h.Get("/v1/users/not-a-uuid").
+			As(ut.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusBadRequest)
+
+		ut.h.Get("/v1/users/"+validate.GenerateUID()).
+			As(ut.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusNotFound)
 	}
 }
 
-func (ut *UsersTest) genToken404(t *testing.T) {
-	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
-	w := httptest.NewRecorder()
+func (ut *UsersTest) create201(t *testing.T) {
+	t.Log("Given the need for admins to add users")
+	{
+		nu := user.NewUser{
+			Name:            "New Gopher",
+			Email:           "new@example.com",
+			Roles:           []string{auth.RoleUser},
+			Password:        "gophers",
+			PasswordConfirm: "gophers",
+		}
 
-	r.SetBasicAuth("blahbalh@email.com", "random")
-	ut.app.ServeHTTP(w, r)
+		ut.h.Post("/v1/users").
+			As(ut.admin.ID, auth.RoleAdmin).
+			JSON(nu).
+			Do(t).
+			Status(http.StatusCreated).
+			Golden("create201", dynamic...)
+	}
+}
 
-	t.Log("Given the need to deny tokens to unknown users")
+func (ut *UsersTest) create400(t *testing.T) {
+	t.Log("Given the need to validate new users")
 	{
-		testID := 0
-		t.Log("\tTest %d\t when fething a token with an unrecognized email", testID)
-		{
-			if w.Code != http.StatusNotFound {
-				t.Fatalf("\t%s\t Test %d \t Should recieve a status code of 404 for response %v", tests.Failed, testID, w.Code)
-			}
-			t.Logf("\t%s\t Test %d \t Should recieve a status code of 404 for response ", tests.Succeeded, testID)
+		ut.h.Post("/v1/users").
+			As(ut.admin.ID, auth.RoleAdmin).
+			JSON(`{"name": "Bad Gopher", "email": "not-an-email"}`).
+			Do(t).
+			Status(http.StatusBadRequest).
+			Golden("create400")
+	}
+}
+
+func (ut *UsersTest) create409(t *testing.T) {
+	t.Log("Given the need to keep emails unique")
+	{
+		nu := user.NewUser{
+			Name:            "Twin Gopher",
+			Email:           ut.user.Email,
+			Roles:           []string{auth.RoleUser},
+			Password:        "gophers",
+			PasswordConfirm: "gophers",
 		}
+
+		ut.h.Post("/v1/users").
+			As(ut.admin.ID, auth.RoleAdmin).
+			JSON(nu).
+			Do(t).
+			Status(http.StatusConflict)
+	}
+}
+
+func (ut *UsersTest) update(t *testing.T) {
+	t.Log("Given the need for admins to update users")
+	{
+		ut.h.Put("/v1/users/"+ut.user.ID).
+			As(ut.admin.ID, auth.RoleAdmin).
+			JSON(`{"name": "Renamed Gopher"}`).
+			Do(t).
+			Status(http.StatusOK)
+
+		ut.h.Get("/v1/users/"+ut.user.ID).
+			As(ut.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Golden("update", dynamic...)
+	}
+}
+
+func (ut *UsersTest) delete(t *testing.T) {
+	t.Log("Given the need for admins to remove users")
+	{
+		usr := ut.h.CreateUser("Doomed Gopher", "doomed@example.com", "gophers", auth.RoleUser)
+
+		ut.h.Delete("/v1/users/"+usr.ID).
+			As(ut.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusOK)
+
+		ut.h.Get("/v1/users/"+usr.ID).
+			As(ut.admin.ID, auth.RoleAdmin).
+			Do(t).
+			Status(http.StatusNotFound)
 	}
 }
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"net/http"
+	"service/app/services/sales-api/apitest"
+	productCore "service/domain/core/product"
+	"service/domain/data/store/order"
+	"service/domain/data/store/product"
+	"service/domain/data/store/refund"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"testing"
+)
+
+type VariantTest struct {
+	h      *apitest.Harness
+	admin  user.User
+	user   user.User
+	shirts product.Product
+	medium product.Variant
+	large  product.Variant
+	order  order.Order
+}
+
+func TestVariants(t *testing.T) {
+	h := apitest.New(t)
+
+	vt := VariantTest{
+		h:      h,
+		admin:  h.CreateUser("Admin Gopher", "admin@example.com", "gophers", auth.RoleAdmin, auth.RoleUser),
+		user:   h.CreateUser("User Gopher", "user@example.com", "gophers", auth.RoleUser),
+		shirts: h.CreateProduct("Gopher Shirts", 2000, 0),
+	}
+
+	t.Run("create", vt.create)
+	t.Run("update", vt.update)
+	t.Run("sell", vt.sell)
+	t.Run("stock", vt.stock)
+}
+
+func (vt *VariantTest) create(t *testing.T) {
+	t.Log("Given the need to sell a product in sizes")
+	{
+		vt.h.Post("/v1/products/"+vt.shirts.ID+"/variants").
+			As(vt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"sku": " gs-m ", "attributes": map[string]string{"size": "M"}, "quantity": 2}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&vt.medium)
+
+		if vt.medium.SKU != "GS-M" || vt.medium.Attributes["size"] != "M" || vt.medium.ProductID != vt.shirts.ID {
+			t.Fatalf("\t%s\tShould store the sku upper case, got %+v", apitest.Failed, vt.medium)
+		}
+		t.Logf("\t%s\tShould store the sku upper case", apitest.Succeeded)
+
+		vt.h.Post("/v1/products/"+vt.shirts.ID+"/variants").
+			As(vt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"sku": "GS-L", "attributes": map[string]string{"size": "L"}, "price": 2200, "quantity": 3}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&vt.large)
+
+		vt.h.Post("/v1/products/"+vt.shirts.ID+"/variants").
+			As(vt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"sku": "gs-l", "quantity": 1}).
+			Do(t).
+			Status(http.StatusConflict)
+
+		vt.h.Post("/v1/products/"+vt.shirts.ID+"/variants").
+			As(vt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"sku": "GS XL", "quantity": 1}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a sku in use or malformed", apitest.Succeeded)
+
+		vt.h.Post("/v1/products/"+vt.shirts.ID+"/variants").
+			As(vt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"sku": "GS-S", "quantity": 1}).
+			Do(t).
+			Status(http.StatusForbidden)
+		t.Logf("\t%s\tShould only let staff add variants", apitest.Succeeded)
+	}
+}
+
+func (vt *VariantTest) update(t *testing.T) {
+	t.Log("Given the need to change a variant")
+	{
+		var got product.Variant
+		vt.h.Put("/v1/variants/"+vt.medium.ID).
+			As(vt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"attributes": map[string]string{"size": "M", "fit": "slim"}}).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&got)
+
+		if got.Quantity != 2 || got.SKU != "GS-M" || got.Attributes["fit"] != "slim" {
+			t.Fatalf("\t%s\tShould only change what is set, got %+v", apitest.Failed, got)
+		}
+		t.Logf("\t%s\tShould only change what is set", apitest.Succeeded)
+
+		vt.h.Put("/v1/variants/"+vt.medium.ID).
+			As(vt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"sku": "GS-L"}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse to take a sku in use", apitest.Succeeded)
+
+		var vars []product.Variant
+		vt.h.Get("/v1/products/"+vt.shirts.ID+"/variants").
+			As(vt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&vars)
+
+		if len(vars) != 2 || vars[0].ID != vt.large.ID || vars[1].Quantity != 2 {
+			t.Fatalf("\t%s\tShould list the variants by sku, got %+v", apitest.Failed, vars)
+		}
+		t.Logf("\t%s\tShould list the variants by sku", apitest.Succeeded)
+	}
+}
+
+func (vt *VariantTest) sell(t *testing.T) {
+	t.Log("Given the need to sell variants")
+	{
+		vt.h.Post("/v1/orders").
+			As(vt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": vt.shirts.ID, "quantity": 1}}}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse a product sold by variant without one", apitest.Succeeded)
+
+		body := map[string]any{
+			"items": []any{
+				map[string]any{"product_id": vt.shirts.ID, "variant_id": vt.medium.ID, "quantity": 1},
+				map[string]any{"product_id": vt.shirts.ID, "variant_id": vt.large.ID, "quantity": 2},
+				map[string]any{"product_id": vt.shirts.ID, "variant_id": vt.medium.ID, "quantity": 1},
+			},
+		}
+
+		vt.h.Post("/v1/orders").
+			As(vt.user.ID, auth.RoleUser).
+			JSON(body).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&vt.order)
+
+		if vt.order.Total != 2*2000+2*2200 || len(vt.order.Items) != 2 || vt.order.Items[1].UnitPrice != 2200 || *vt.order.Items[0].VariantID != vt.medium.ID {
+			t.Fatalf("\t%s\tShould price every line from its variant, got %+v", apitest.Failed, vt.order)
+		}
+		t.Logf("\t%s\tShould price every line from its variant", apitest.Succeeded)
+
+		vt.h.Post("/v1/orders").
+			As(vt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": vt.shirts.ID, "variant_id": vt.medium.ID, "quantity": 1}}}).
+			Do(t).
+			Status(http.StatusConflict)
+		t.Logf("\t%s\tShould refuse a variant out of stock", apitest.Succeeded)
+
+		other := vt.h.CreateProduct("Gopher Mugs", 900, 5)
+		vt.h.Post("/v1/orders").
+			As(vt.user.ID, auth.RoleUser).
+			JSON(map[string]any{"items": []any{map[string]any{"product_id": other.ID, "variant_id": vt.large.ID, "quantity": 1}}}).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse the variant of another product", apitest.Succeeded)
+
+		var r refund.Refund
+		vt.h.Post("/v1/orders/"+vt.order.ID+"/refunds").
+			As(vt.admin.ID, auth.RoleAdmin).
+			JSON(map[string]any{"reason": "changed_mind", "items": []any{map[string]any{"line": 2, "quantity": 1, "restock": true}}}).
+			Do(t).
+			Status(http.StatusCreated).
+			Decode(&r)
+
+		if len(r.Items) != 1 || r.Items[0].VariantID == nil || *r.Items[0].VariantID != vt.large.ID {
+			t.Fatalf("\t%s\tShould refund the variant of the line, got %+v", apitest.Failed, r)
+		}
+		t.Logf("\t%s\tShould refund the variant of the line", apitest.Succeeded)
+	}
+}
+
+func (vt *VariantTest) stock(t *testing.T) {
+	t.Log("Given the need to know what is left in stock")
+	{
+		var stock productCore.Stock
+		vt.h.Get("/v1/products/"+vt.shirts.ID+"/stock").
+			As(vt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusOK).
+			Decode(&stock)
+
+		if stock.Quantity != 2 || len(stock.Variants) != 2 || stock.Variants[0].SKU != "GS-L" || stock.Variants[0].Quantity != 2 || stock.Variants[1].Quantity != 0 {
+			t.Fatalf("\t%s\tShould report the stock per variant and in total, got %+v", apitest.Failed, stock)
+		}
+		t.Logf("\t%s\tShould report the stock per variant and in total", apitest.Succeeded)
+
+		vt.h.Get("/v1/products/not-an-id/stock").
+			As(vt.user.ID, auth.RoleUser).
+			Do(t).
+			Status(http.StatusBadRequest)
+		t.Logf("\t%s\tShould refuse an invalid id", apitest.Succeeded)
+	}
+}
@@ -0,0 +1,284 @@
+// Package apikey provides the core business API for the API keys service
+// clients authenticate with instead of a user's password.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/apikey"
+	"service/domain/data/store/role"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"strings"
+	"time"
+)
+
+// Set of error variables for API keys.
+var (
+	ErrUnknownScope      = errors.New("unknown scope")
+	ErrExpired           = errors.New("api key is expired or revoked")
+	ErrInvalidExpiration = errors.New("expiry must be in the future")
+)
+
+// keyPrefix starts every key so they are easy to spot, in logs or by
+// secret scanners.
+const keyPrefix = "sk_"
+
+// touchEvery limits how often the last use of a key is written, busy
+// clients would otherwise cause a write per request.
+const touchEvery = time.Minute
+
+// Storer interface declares the behavior this package needs to persist
+// and retrieve API keys.
+type Storer interface {
+	Create(ctx context.Context, k apikey.Key) error
+	Query(ctx context.Context) ([]apikey.Key, error)
+	QueryByID(ctx context.Context, keyID string) (apikey.Key, error)
+	QueryByPrefix(ctx context.Context, prefix string) (apikey.Key, error)
+	Expire(ctx context.Context, keyID string, at time.Time) error
+	Revoke(ctx context.Context, keyID string, now time.Time) error
+	Touch(ctx context.Context, keyID string, now time.Time) error
+}
+
+// Permissioner lists the permissions a key can be scoped to.
+type Permissioner interface {
+	QueryPermissions(ctx context.Context) ([]role.Permission, error)
+}
+
+// Config holds the API key policy.
+type Config struct {
+	// RotationOverlap is how long a rotated key keeps working next to
+	// its replacement, so clients can be switched over without downtime.
+	RotationOverlap time.Duration
+}
+
+// Issued is a key along with its plain text form. The plain text is only
+// ever returned when the key is issued.
+type Issued struct {
+	apikey.Key
+	Secret string `json:"key"`
+}
+
+type Core struct {
+	logger *zap.SugaredLogger
+	store  Storer
+	perms  Permissioner
+	cfg    Config
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, perms Permissioner, cfg Config) Core {
+	return Core{
+		logger: log,
+		store:  store,
+		perms:  perms,
+		cfg:    cfg,
+	}
+}
+
+// Create issues a key on behalf of the admin in claims.
+func (c Core) Create(ctx context.Context, claims auth.Claims, nk apikey.NewKey, now time.Time) (Issued, error) {
+	if err := validate.Check(nk); err != nil {
+		return Issued{}, fmt.Errorf("Create: %w", err)
+	}
+
+	if nk.DateExpires != nil && !nk.DateExpires.After(now) {
+		return Issued{}, fmt.Errorf("Create: %w", ErrInvalidExpiration)
+	}
+
+	if err := c.checkScopes(ctx, nk.Scopes); err != nil {
+		return Issued{}, fmt.Errorf("Create: %w", err)
+	}
+
+	k := apikey.Key{
+		ID:          validate.GenerateUID(),
+		Name:        nk.Name,
+		Scopes:      nk.Scopes,
+		CreatedBy:   claims.Subject,
+		DateExpires: nk.DateExpires,
+		DateCreated: now,
+	}
+
+	iss, err := c.issue(ctx, k)
+	if err != nil {
+		return Issued{}, fmt.Errorf("Create: %w", err)
+	}
+	return iss, nil
+}
+
+// Rotate issues a replacement for the key with the same name, scopes and
+// expiry. The old key keeps working for the rotation overlap.
+func (c Core) Rotate(ctx context.Context, claims auth.Claims, keyID string, now time.Time) (Issued, error) {
+	if err := validate.CheckID(keyID); err != nil {
+		return Issued{}, fmt.Errorf("Rotate: %w", database.ErrInvalidID)
+	}
+
+	old, err := c.store.QueryByID(ctx, keyID)
+	if err != nil {
+		return Issued{}, fmt.Errorf("Rotate: %w", err)
+	}
+
+	if !old.Valid(now) {
+		return Issued{}, fmt.Errorf("Rotate: %w", ErrExpired)
+	}
+
+	k := apikey.Key{
+		ID:          validate.GenerateUID(),
+		Name:        old.Name,
+		Scopes:      old.Scopes,
+		CreatedBy:   claims.Subject,
+		DateExpires: old.DateExpires,
+		DateCreated: now,
+	}
+
+	iss, err := c.issue(ctx, k)
+	if err != nil {
+		return Issued{}, fmt.Errorf("Rotate: %w", err)
+	}
+
+	if err := c.store.Expire(ctx, old.ID, now.Add(c.cfg.RotationOverlap)); err != nil {
+		return Issued{}, fmt.Errorf("Rotate: %w", err)
+	}
+	return iss, nil
+}
+
+// Revoke stops the key from working right away.
+func (c Core) Revoke(ctx context.Context, keyID string, now time.Time) error {
+	if err := validate.CheckID(keyID); err != nil {
+		return fmt.Errorf("Revoke: %w", database.ErrInvalidID)
+	}
+
+	if _, err := c.store.QueryByID(ctx, keyID); err != nil {
+		return fmt.Errorf("Revoke: %w", err)
+	}
+
+	if err := c.store.Revoke(ctx, keyID, now); err != nil {
+		return fmt.Errorf("Revoke: %w", err)
+	}
+	return nil
+}
+
+// Query returns every key.
+func (c Core) Query(ctx context.Context) ([]apikey.Key, error) {
+	keys, err := c.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	return keys, nil
+}
+
+// Authenticate returns the claims of the client holding the key. The
+// subject is the id of the key and the permissions are its scopes, keys
+// hold no roles. Bad keys of any kind return auth.ErrInvalidKey.
+func (c Core) Authenticate(ctx context.Context, key string, now time.Time) (auth.Claims, error) {
+	prefix, secret, ok := parseKey(key)
+	if !ok {
+		return auth.Claims{}, auth.ErrInvalidKey
+	}
+
+	k, err := c.store.QueryByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return auth.Claims{}, auth.ErrInvalidKey
+		}
+		return auth.Claims{}, fmt.Errorf("Authenticate: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(k.Hash)) != 1 {
+		return auth.Claims{}, auth.ErrInvalidKey
+	}
+
+	if !k.Valid(now) {
+		return auth.Claims{}, auth.ErrInvalidKey
+	}
+
+	if k.DateLastUsed == nil || now.Sub(*k.DateLastUsed) >= touchEvery {
+		if err := c.store.Touch(ctx, k.ID, now); err != nil {
+			c.logger.Errorw("apikey", "status", "recording last use", "keyID", k.ID, "ERROR", err)
+		}
+	}
+
+	claims := auth.Claims{
+		Permissions: k.Scopes,
+	}
+	claims.Subject = k.ID
+	claims.IssuedAt = k.DateCreated.Unix()
+	if k.DateExpires != nil {
+		claims.ExpiresAt = k.DateExpires.Unix()
+	}
+	return claims, nil
+}
+
+// issue generates the secret of the key and stores it.
+func (c Core) issue(ctx context.Context, k apikey.Key) (Issued, error) {
+	prefix := make([]byte, 6)
+	if _, err := rand.Read(prefix); err != nil {
+		return Issued{}, fmt.Errorf("generating prefix: %w", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Issued{}, fmt.Errorf("generating secret: %w", err)
+	}
+
+	k.Prefix = hex.EncodeToString(prefix)
+	plain := base64.RawURLEncoding.EncodeToString(secret)
+	k.Hash = hashSecret(plain)
+
+	if err := c.store.Create(ctx, k); err != nil {
+		return Issued{}, err
+	}
+
+	iss := Issued{
+		Key:    k,
+		Secret: keyPrefix + k.Prefix + "." + plain,
+	}
+	return iss, nil
+}
+
+// checkScopes makes sure every scope names a known permission.
+func (c Core) checkScopes(ctx context.Context, scopes []string) error {
+	known, err := c.perms.QueryPermissions(ctx)
+	if err != nil {
+		return err
+	}
+
+	valid := make(map[string]bool, len(known))
+	for _, p := range known {
+		valid[p.Name] = true
+	}
+
+	for _, s := range scopes {
+		if !valid[s] {
+			return fmt.Errorf("%w %q", ErrUnknownScope, s)
+		}
+	}
+	return nil
+}
+
+// parseKey splits a key into the prefix it is looked up by and the secret.
+func parseKey(key string) (string, string, bool) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return "", "", false
+	}
+
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(key, keyPrefix), ".")
+	if !ok || prefix == "" || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+// hashSecret hashes the secret of a key. Secrets carry 256 random bits, a
+// fast hash is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
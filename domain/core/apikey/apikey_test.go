@@ -0,0 +1,88 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	apikeyStore "service/domain/data/store/apikey"
+	"service/domain/data/store/apikey/memory"
+	roleMemory "service/domain/data/store/role/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/auth"
+	"testing"
+	"time"
+)
+
+func TestAPIKey(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	store := memory.NewStore()
+	core := NewCore(zap.NewNop().Sugar(), store, roleMemory.NewStore(), Config{RotationOverlap: time.Hour})
+
+	admin := auth.Claims{Roles: []string{auth.RoleAdmin}}
+	admin.Subject = "admin"
+
+	t.Log("Given the need to authenticate services with keys")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When a key expires", testID)
+		{
+			expires := now.Add(24 * time.Hour)
+			iss, err := core.Create(ctx, admin, apikeyStore.NewKey{
+				Name:        "warehouse",
+				Scopes:      []string{auth.PermProductsRead},
+				DateExpires: &expires,
+			}, now)
+			if err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to create a key: %v", tests.Failed, testID, err)
+			}
+
+			claims, err := core.Authenticate(ctx, iss.Secret, now)
+			if err != nil || claims.Subject != iss.ID || !claims.Permitted(auth.PermProductsRead) {
+				t.Fatalf("\t%s\t Test %d Should authenticate with the key, got %+v %v", tests.Failed, testID, claims, err)
+			}
+			t.Logf("\t%s\t Test %d Should authenticate with the key", tests.Succeeded, testID)
+
+			if _, err := core.Authenticate(ctx, iss.Secret, expires); !errors.Is(err, auth.ErrInvalidKey) {
+				t.Fatalf("\t%s\t Test %d Should refuse an expired key, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse an expired key", tests.Succeeded, testID)
+
+			testID++
+			t.Logf("\t Test %d \t When recording the last use", testID)
+
+			if _, err := core.Authenticate(ctx, iss.Secret, now.Add(30*time.Second)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should authenticate with the key: %v", tests.Failed, testID, err)
+			}
+
+			k, err := store.QueryByID(ctx, iss.ID)
+			if err != nil || k.DateLastUsed == nil || !k.DateLastUsed.Equal(now) {
+				t.Fatalf("\t%s\t Test %d Should only record the last use once a minute, got %v %v", tests.Failed, testID, k.DateLastUsed, err)
+			}
+			t.Logf("\t%s\t Test %d Should only record the last use once a minute", tests.Succeeded, testID)
+
+			testID++
+			t.Logf("\t Test %d \t When rotating a key", testID)
+
+			next, err := core.Rotate(ctx, admin, iss.ID, now)
+			if err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to rotate the key: %v", tests.Failed, testID, err)
+			}
+
+			later := now.Add(time.Hour)
+			if _, err := core.Authenticate(ctx, iss.Secret, later); !errors.Is(err, auth.ErrInvalidKey) {
+				t.Fatalf("\t%s\t Test %d Should refuse the old key after the overlap, got %v", tests.Failed, testID, err)
+			}
+			if _, err := core.Authenticate(ctx, next.Secret, later); err != nil {
+				t.Fatalf("\t%s\t Test %d Should accept the new key after the overlap: %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should only accept the new key after the overlap", tests.Succeeded, testID)
+
+			if next.DateExpires == nil || !next.DateExpires.Equal(expires) {
+				t.Fatalf("\t%s\t Test %d Should keep the expiry of the old key, got %v", tests.Failed, testID, next.DateExpires)
+			}
+			t.Logf("\t%s\t Test %d Should keep the expiry of the old key", tests.Succeeded, testID)
+		}
+	}
+}
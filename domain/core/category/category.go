@@ -0,0 +1,251 @@
+// Package category provides the core business API for the tree of
+// categories products are browsed by.
+package category
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/category"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"time"
+)
+
+// Set of error variables for managing categories.
+var (
+	ErrInvalidSlug   = errors.New("slug must be lower case letters and digits separated by dashes")
+	ErrUnknownParent = errors.New("unknown parent category")
+	ErrCycle         = category.ErrCycle
+	ErrNotEmpty      = errors.New("category still has categories or products under it")
+)
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve categories.
+type Storer interface {
+	Create(ctx context.Context, c category.Category) error
+	Update(ctx context.Context, c category.Category) error
+	Delete(ctx context.Context, categoryID string) error
+	Query(ctx context.Context) ([]category.Category, error)
+	QueryByID(ctx context.Context, categoryID string) (category.Category, error)
+	QueryBySlug(ctx context.Context, slug string) (category.Category, error)
+	QueryDescendantIDs(ctx context.Context, categoryID string) ([]string, error)
+}
+
+// ProductCounter counts the products filed under categories, a category
+// can only be deleted once it has none.
+type ProductCounter interface {
+	CountByCategory(ctx context.Context, f product.Filter) (map[string]int, error)
+}
+
+// Node is a category along with the categories right below it.
+type Node struct {
+	category.Category
+	Children []Node `json:"children"`
+}
+
+type Core struct {
+	logger   *zap.SugaredLogger
+	store    Storer
+	products ProductCounter
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, products ProductCounter) Core {
+	return Core{
+		logger:   log,
+		store:    store,
+		products: products,
+	}
+}
+
+// Create adds a category below its parent, or at the top level when it
+// has none.
+func (c Core) Create(ctx context.Context, nc category.NewCategory, now time.Time) (category.Category, error) {
+	if err := validate.Check(nc); err != nil {
+		return category.Category{}, fmt.Errorf("Create: %w", err)
+	}
+
+	slug, err := checkSlug(nc.Name, nc.Slug)
+	if err != nil {
+		return category.Category{}, fmt.Errorf("Create: %w", err)
+	}
+
+	cat := category.Category{
+		ID:          validate.GenerateUID(),
+		Name:        nc.Name,
+		Slug:        slug,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if nc.ParentID != nil && *nc.ParentID != "" {
+		if _, err := c.store.QueryByID(ctx, *nc.ParentID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return category.Category{}, fmt.Errorf("Create: parent %s: %w", *nc.ParentID, ErrUnknownParent)
+			}
+			return category.Category{}, fmt.Errorf("Create: %w", err)
+		}
+		cat.ParentID = nc.ParentID
+	}
+
+	if err := c.store.Create(ctx, cat); err != nil {
+		return category.Category{}, fmt.Errorf("Create: %w", err)
+	}
+	return cat, nil
+}
+
+// Update renames the category or moves it below another parent. It can
+// not be moved below itself or any category under it.
+func (c Core) Update(ctx context.Context, categoryID string, uc category.UpdateCategory, now time.Time) (category.Category, error) {
+	if err := validate.CheckID(categoryID); err != nil {
+		return category.Category{}, fmt.Errorf("Update: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(uc); err != nil {
+		return category.Category{}, fmt.Errorf("Update: %w", err)
+	}
+
+	cat, err := c.store.QueryByID(ctx, categoryID)
+	if err != nil {
+		return category.Category{}, fmt.Errorf("Update: %w", err)
+	}
+
+	if uc.Name != nil {
+		cat.Name = *uc.Name
+	}
+
+	if uc.Slug != nil {
+		slug, err := checkSlug(cat.Name, *uc.Slug)
+		if err != nil {
+			return category.Category{}, fmt.Errorf("Update: %w", err)
+		}
+		cat.Slug = slug
+	}
+
+	if uc.ParentID != nil {
+		cat.ParentID = nil
+
+		if parentID := *uc.ParentID; parentID != "" {
+			below, err := c.store.QueryDescendantIDs(ctx, categoryID)
+			if err != nil {
+				return category.Category{}, fmt.Errorf("Update: %w", err)
+			}
+
+			for _, id := range below {
+				if id == parentID {
+					return category.Category{}, fmt.Errorf("Update: parent %s: %w", parentID, ErrCycle)
+				}
+			}
+
+			if _, err := c.store.QueryByID(ctx, parentID); err != nil {
+				if errors.Is(err, database.ErrNotFound) {
+					return category.Category{}, fmt.Errorf("Update: parent %s: %w", parentID, ErrUnknownParent)
+				}
+				return category.Category{}, fmt.Errorf("Update: %w", err)
+			}
+			cat.ParentID = &parentID
+		}
+	}
+
+	cat.DateUpdated = now
+
+	if err := c.store.Update(ctx, cat); err != nil {
+		return category.Category{}, fmt.Errorf("Update: %w", err)
+	}
+	return cat, nil
+}
+
+// Delete removes a category that has no categories or products under it.
+func (c Core) Delete(ctx context.Context, categoryID string) error {
+	if err := validate.CheckID(categoryID); err != nil {
+		return fmt.Errorf("Delete: %w", database.ErrInvalidID)
+	}
+
+	below, err := c.store.QueryDescendantIDs(ctx, categoryID)
+	if err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+
+	if len(below) > 1 {
+		return fmt.Errorf("Delete: %w", ErrNotEmpty)
+	}
+
+	counts, err := c.products.CountByCategory(ctx, product.Filter{CategoryIDs: below})
+	if err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+
+	if counts[categoryID] > 0 {
+		return fmt.Errorf("Delete: %w", ErrNotEmpty)
+	}
+
+	if err := c.store.Delete(ctx, categoryID); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+	return nil
+}
+
+// QueryByID returns the category with the id.
+func (c Core) QueryByID(ctx context.Context, categoryID string) (category.Category, error) {
+	if err := validate.CheckID(categoryID); err != nil {
+		return category.Category{}, fmt.Errorf("QueryByID: %w", database.ErrInvalidID)
+	}
+
+	cat, err := c.store.QueryByID(ctx, categoryID)
+	if err != nil {
+		return category.Category{}, fmt.Errorf("QueryByID: %w", err)
+	}
+	return cat, nil
+}
+
+// Tree returns the top level categories with the categories below them,
+// every level ordered by name.
+func (c Core) Tree(ctx context.Context) ([]Node, error) {
+	cats, err := c.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Tree: %w", err)
+	}
+
+	children := make(map[string][]category.Category)
+	var top []category.Category
+	for _, cat := range cats {
+		if cat.ParentID == nil {
+			top = append(top, cat)
+			continue
+		}
+		children[*cat.ParentID] = append(children[*cat.ParentID], cat)
+	}
+
+	var build func(cats []category.Category) []Node
+	build = func(cats []category.Category) []Node {
+		nodes := make([]Node, len(cats))
+		for i, cat := range cats {
+			nodes[i] = Node{
+				Category: cat,
+				Children: build(children[cat.ID]),
+			}
+		}
+		return nodes
+	}
+
+	return build(top), nil
+}
+
+// checkSlug returns the slug to use, made from the name when none is
+// given. A given slug must already be in its normal form.
+func checkSlug(name string, slug string) (string, error) {
+	if slug == "" {
+		slug = category.Slugify(name)
+		if slug == "" {
+			return "", ErrInvalidSlug
+		}
+		return slug, nil
+	}
+
+	if category.Slugify(slug) != slug {
+		return "", ErrInvalidSlug
+	}
+	return slug, nil
+}
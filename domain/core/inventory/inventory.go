@@ -0,0 +1,72 @@
+// Package inventory provides the core business API for looking into the
+// inventory ledger, the movements of stock every level is derived from,
+// and for watching the products that run low.
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+)
+
+// Storer interface declares the behavior this package needs to read the
+// ledger and the products holding no more than their reorder point.
+type Storer interface {
+	QueryMovements(ctx context.Context, f product.MovementFilter, pageNumber int, rowsPerPage int) ([]product.Movement, error)
+	QueryDiscrepancies(ctx context.Context) ([]product.Discrepancy, error)
+	QueryLowStock(ctx context.Context) ([]product.LowStock, error)
+}
+
+type Core struct {
+	logger *zap.SugaredLogger
+	store  Storer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer) Core {
+	return Core{
+		logger: log,
+		store:  store,
+	}
+}
+
+// Movements returns a page of the ledger, latest first, narrowed down to
+// the warehouse or the product of the filter when they are set.
+func (c Core) Movements(ctx context.Context, f product.MovementFilter, pageNumber int, rowsPerPage int) ([]product.Movement, error) {
+	for _, id := range []string{f.WarehouseID, f.ProductID} {
+		if id == "" {
+			continue
+		}
+		if err := validate.CheckID(id); err != nil {
+			return nil, fmt.Errorf("Movements: %w", database.ErrInvalidID)
+		}
+	}
+
+	movements, err := c.store.QueryMovements(ctx, f, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("Movements: %w", err)
+	}
+	return movements, nil
+}
+
+// Verify derives every level from the ledger and returns those that do
+// not hold what their movements add up to, none when they all agree.
+func (c Core) Verify(ctx context.Context) ([]product.Discrepancy, error) {
+	ds, err := c.store.QueryDiscrepancies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Verify: %w", err)
+	}
+	return ds, nil
+}
+
+// LowStock returns every product holding no more than its reorder point,
+// the lowest against its reorder point first.
+func (c Core) LowStock(ctx context.Context) ([]product.LowStock, error) {
+	lows, err := c.store.QueryLowStock(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("LowStock: %w", err)
+	}
+	return lows, nil
+}
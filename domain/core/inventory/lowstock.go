@@ -0,0 +1,168 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/foundation/notification"
+	"time"
+)
+
+// LowStockStorer claims the products that crossed their reorder point.
+// ClaimLowStock must return a crossing once, however many checkers look
+// for it at the same time, until ReleaseLowStock gives the claim up.
+type LowStockStorer interface {
+	ClaimLowStock(ctx context.Context, productIDs []string, now time.Time) ([]product.LowStock, error)
+	ReleaseLowStock(ctx context.Context, productID string, claimed time.Time) error
+}
+
+// Notifier is where the LowStock events go, it tells whoever restocks
+// that a product runs low.
+type Notifier interface {
+	LowStock(ctx context.Context, low product.LowStock) error
+}
+
+// CheckerConfig holds how often the checker looks at every product.
+type CheckerConfig struct {
+	// Interval between two sweeps of every product, they catch what was
+	// not sold through an order, adjustments and stocktakes, and sales
+	// dropped when the queue was full. Zero turns the sweeps off.
+	Interval time.Duration
+
+	// Queue is how many sales can wait to be checked.
+	Queue int
+}
+
+// Checker looks for products crossing below their reorder point in the
+// background, after every sale and on every sweep, and emits a LowStock
+// event through the notifier for each of them.
+type Checker struct {
+	logger   *zap.SugaredLogger
+	store    LowStockStorer
+	notifier Notifier
+	cfg      CheckerConfig
+	sold     chan []string
+}
+
+func NewChecker(log *zap.SugaredLogger, store LowStockStorer, notifier Notifier, cfg CheckerConfig) *Checker {
+	return &Checker{
+		logger:   log,
+		store:    store,
+		notifier: notifier,
+		cfg:      cfg,
+		sold:     make(chan []string, cfg.Queue),
+	}
+}
+
+// Sold queues the products of a sale to be checked, it never blocks the
+// sale. A sale that does not fit in the queue is left to the next sweep.
+func (c *Checker) Sold(productIDs []string) {
+	select {
+	case c.sold <- productIDs:
+	default:
+		c.logger.Infow("lowstock", "status", "queue full, left to the sweep", "products", len(productIDs))
+	}
+}
+
+// Run checks the products sold and sweeps every product until ctx is
+// done.
+func (c *Checker) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if c.cfg.Interval > 0 {
+		ticker := time.NewTicker(c.cfg.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case ids := <-c.sold:
+			if err := c.Check(ctx, ids, time.Now()); err != nil {
+				c.logger.Errorw("lowstock", "status", "checking sold products", "ERROR", err)
+			}
+
+		case <-tick:
+			if err := c.Check(ctx, nil, time.Now()); err != nil {
+				c.logger.Errorw("lowstock", "status", "sweeping products", "ERROR", err)
+			}
+		}
+	}
+}
+
+// Check emits a LowStock event for every product with the ids that
+// crossed its reorder point since it was last above it, for every product
+// when there are no ids. The claim on a product the notifier fails on is
+// released so the next check tries again, the failure is logged and
+// returned after the others are notified.
+func (c *Checker) Check(ctx context.Context, productIDs []string, now time.Time) error {
+	lows, err := c.store.ClaimLowStock(ctx, productIDs, now)
+	if err != nil {
+		return fmt.Errorf("Check: %w", err)
+	}
+
+	var first error
+	for _, low := range lows {
+		c.logger.Infow("lowstock", "status", "product below reorder point", "product", low.ProductID, "quantity", low.Quantity, "reorder_point", low.ReorderPoint)
+
+		if err := c.notifier.LowStock(ctx, low); err != nil {
+			c.logger.Errorw("lowstock", "status", "notifying", "product", low.ProductID, "ERROR", err)
+			if first == nil {
+				first = fmt.Errorf("Check: product %s: %w", low.ProductID, err)
+			}
+
+			if err := c.store.ReleaseLowStock(ctx, low.ProductID, *low.DateAlerted); err != nil {
+				c.logger.Errorw("lowstock", "status", "releasing claim", "product", low.ProductID, "ERROR", err)
+			}
+		}
+	}
+	return first
+}
+
+// =============================================================================
+
+// MailNotifier mails the LowStock events to a single address, usually
+// whoever buys the stock.
+type MailNotifier struct {
+	mailer notification.Mailer
+	to     string
+}
+
+func NewMailNotifier(mailer notification.Mailer, to string) MailNotifier {
+	return MailNotifier{
+		mailer: mailer,
+		to:     to,
+	}
+}
+
+// LowStock mails the event.
+func (n MailNotifier) LowStock(ctx context.Context, low product.LowStock) error {
+	msg := notification.Message{
+		To:      n.to,
+		Subject: fmt.Sprintf("Low stock: %s", low.Name),
+		Body: fmt.Sprintf("%s is down to %d units, its reorder point is %d.\n\nProduct: %s\n",
+			low.Name, low.Quantity, low.ReorderPoint, low.ProductID),
+	}
+	return n.mailer.Send(ctx, msg)
+}
+
+// LogNotifier writes the LowStock events to the log, for environments
+// with nobody to mail.
+type LogNotifier struct {
+	logger *zap.SugaredLogger
+}
+
+func NewLogNotifier(log *zap.SugaredLogger) LogNotifier {
+	return LogNotifier{
+		logger: log,
+	}
+}
+
+// LowStock logs the event.
+func (n LogNotifier) LowStock(ctx context.Context, low product.LowStock) error {
+	n.logger.Warnw("lowstock", "product", low.ProductID, "name", low.Name, "quantity", low.Quantity, "reorder_point", low.ReorderPoint)
+	return nil
+}
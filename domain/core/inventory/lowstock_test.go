@@ -0,0 +1,81 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/data/store/product/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+// flakyNotifier fails the events until it is fixed.
+type flakyNotifier struct {
+	broken bool
+	sent   []product.LowStock
+}
+
+func (n *flakyNotifier) LowStock(ctx context.Context, low product.LowStock) error {
+	if n.broken {
+		return errors.New("mail server down")
+	}
+	n.sent = append(n.sent, low)
+	return nil
+}
+
+func TestCheckerRetry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	store := memory.NewStore()
+	p := product.Product{
+		ID:           validate.GenerateUID(),
+		Name:         "Dice",
+		Cost:         100,
+		Currency:     money.USD,
+		Quantity:     1,
+		ReorderPoint: 2,
+		DateCreated:  now,
+		DateUpdated:  now,
+	}
+	if err := store.Create(ctx, p); err != nil {
+		t.Fatalf("Should be able to create the product: %v", err)
+	}
+
+	notifier := flakyNotifier{broken: true}
+	checker := NewChecker(zap.NewNop().Sugar(), store, &notifier, CheckerConfig{})
+
+	t.Log("Given the need to hear about a product running low when the notifier fails")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When the notifier fails", testID)
+		{
+			if err := checker.Check(ctx, nil, now); err == nil {
+				t.Fatalf("\t%s\t Test %d Should report the failure", tests.Failed, testID)
+			}
+			t.Logf("\t%s\t Test %d Should report the failure", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When the notifier works again", testID)
+		{
+			notifier.broken = false
+			if err := checker.Check(ctx, nil, now.Add(time.Minute)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to sweep the products: %v", tests.Failed, testID, err)
+			}
+			if len(notifier.sent) != 1 || notifier.sent[0].ProductID != p.ID {
+				t.Fatalf("\t%s\t Test %d Should notify the product on the next sweep, got %+v", tests.Failed, testID, notifier.sent)
+			}
+			t.Logf("\t%s\t Test %d Should notify the product on the next sweep", tests.Succeeded, testID)
+
+			if err := checker.Check(ctx, nil, now.Add(2*time.Minute)); err != nil || len(notifier.sent) != 1 {
+				t.Fatalf("\t%s\t Test %d Should notify a crossing once, got %+v %v", tests.Failed, testID, notifier.sent, err)
+			}
+			t.Logf("\t%s\t Test %d Should notify a crossing once", tests.Succeeded, testID)
+		}
+	}
+}
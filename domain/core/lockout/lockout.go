@@ -0,0 +1,215 @@
+// Package lockout provides the core business API to throttle logins. Failed
+// logins are counted per account and per address, every failure slows the
+// next attempt down and too many of them lock the key for a while.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/audit"
+	"service/domain/data/store/lockout"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned while an account or address is locked.
+var ErrLocked = errors.New("too many failed logins, try again later")
+
+// Set of audit actions recorded by this package.
+const (
+	ActionLocked   = "login.locked"
+	ActionUnlocked = "login.unlocked"
+)
+
+// Storer interface declares the behavior this package needs to count
+// failed logins.
+type Storer interface {
+	QueryByKey(ctx context.Context, key string) (lockout.Failure, error)
+	Record(ctx context.Context, key string, now time.Time, since time.Time) (lockout.Failure, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Delete(ctx context.Context, key string) error
+	DeleteExpired(ctx context.Context, since time.Time, now time.Time) error
+}
+
+// Auditor interface declares the behavior this package needs to leave an
+// audit trail.
+type Auditor interface {
+	Create(ctx context.Context, e audit.Event) error
+}
+
+// Config holds the throttling policy. A zero Threshold disables locking
+// for that kind of key, a zero BaseDelay disables the delays.
+type Config struct {
+	// Threshold is the number of failures that locks an account,
+	// IPThreshold the number that locks an address. Addresses get a
+	// higher threshold as many users may share one.
+	Threshold   int
+	IPThreshold int
+
+	// Window is how long failures are remembered, LockFor how long a
+	// locked key stays locked.
+	Window  time.Duration
+	LockFor time.Duration
+
+	// BaseDelay is the delay after the first failure, it doubles with
+	// every failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+type Core struct {
+	logger *zap.SugaredLogger
+	store  Storer
+	audit  Auditor
+	cfg    Config
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, auditor Auditor, cfg Config) Core {
+	return Core{
+		logger: log,
+		store:  store,
+		audit:  auditor,
+		cfg:    cfg,
+	}
+}
+
+// Check returns ErrLocked along with the time left when logins for the
+// email or from the address are refused. Otherwise it holds the caller for
+// the delay earned by the previous failures.
+func (c Core) Check(ctx context.Context, email string, ip string, now time.Time) (time.Duration, error) {
+	var failures int
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		f, err := c.store.QueryByKey(ctx, key)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				continue
+			}
+			return 0, fmt.Errorf("Check: %w", err)
+		}
+
+		if f.Locked(now) {
+			return f.LockedUntil.Sub(now), ErrLocked
+		}
+
+		if f.LastFailure.After(now.Add(-c.cfg.Window)) && f.Failures > failures {
+			failures = f.Failures
+		}
+	}
+
+	if d := c.delay(failures); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	return 0, nil
+}
+
+// Failed records a failed login for the email and the address and locks
+// whichever reached its threshold.
+func (c Core) Failed(ctx context.Context, email string, ip string, now time.Time) error {
+
+	// Any email can be tried, so every failure may add a key. Forgetting
+	// the keys out of the window and not locked here keeps the table from
+	// growing without bound.
+	if err := c.store.DeleteExpired(ctx, now.Add(-c.cfg.Window), now); err != nil {
+		c.logger.Errorw("lockout", "status", "deleting expired failures", "ERROR", err)
+	}
+
+	keys := []struct {
+		key       string
+		threshold int
+	}{
+		{accountKey(email), c.cfg.Threshold},
+		{ipKey(ip), c.cfg.IPThreshold},
+	}
+
+	for _, k := range keys {
+		f, err := c.store.Record(ctx, k.key, now, now.Add(-c.cfg.Window))
+		if err != nil {
+			return fmt.Errorf("Failed: %w", err)
+		}
+
+		if k.threshold == 0 || f.Failures < k.threshold || f.Locked(now) {
+			continue
+		}
+
+		until := now.Add(c.cfg.LockFor)
+		if err := c.store.Lock(ctx, k.key, until); err != nil {
+			return fmt.Errorf("Failed: %w", err)
+		}
+
+		detail := fmt.Sprintf("%d failed logins, locked until %s", f.Failures, until.UTC().Format(time.RFC3339))
+		c.record(ctx, ActionLocked, "", k.key, detail, now)
+	}
+	return nil
+}
+
+// Succeeded forgets the failures of the account. The address keeps its
+// count, one valid login must not clear an address guessing passwords.
+func (c Core) Succeeded(ctx context.Context, email string) error {
+	if err := c.store.Delete(ctx, accountKey(email)); err != nil {
+		return fmt.Errorf("Succeeded: %w", err)
+	}
+	return nil
+}
+
+// Unlock lifts the lock on the account on behalf of actor.
+func (c Core) Unlock(ctx context.Context, actor string, email string, now time.Time) error {
+	if err := c.store.Delete(ctx, accountKey(email)); err != nil {
+		return fmt.Errorf("Unlock: %w", err)
+	}
+
+	c.record(ctx, ActionUnlocked, actor, accountKey(email), "unlocked by an admin", now)
+	return nil
+}
+
+// delay returns how long to hold a login after that many failures.
+func (c Core) delay(failures int) time.Duration {
+	if failures == 0 || c.cfg.BaseDelay <= 0 {
+		return 0
+	}
+
+	d := c.cfg.BaseDelay
+	for i := 1; i < failures; i++ {
+		d *= 2
+		if c.cfg.MaxDelay > 0 && d >= c.cfg.MaxDelay {
+			return c.cfg.MaxDelay
+		}
+	}
+	return d
+}
+
+// record writes an audit event. Failing to do so must not change the
+// outcome of a login, it is logged instead.
+func (c Core) record(ctx context.Context, action string, actor string, subject string, detail string, now time.Time) {
+	e := audit.Event{
+		ID:          validate.GenerateUID(),
+		Action:      action,
+		Actor:       actor,
+		Subject:     subject,
+		Detail:      detail,
+		DateCreated: now,
+	}
+
+	c.logger.Infow("audit", "action", action, "actor", actor, "subject", subject, "detail", detail)
+	if err := c.audit.Create(ctx, e); err != nil {
+		c.logger.Errorw("audit", "status", "recording event", "action", action, "subject", subject, "ERROR", err)
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,88 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	auditMemory "service/domain/data/store/audit/memory"
+	"service/domain/data/store/lockout/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/database"
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	cfg := Config{
+		Threshold:   3,
+		IPThreshold: 5,
+		Window:      10 * time.Minute,
+		LockFor:     time.Minute,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}
+	core := NewCore(zap.NewNop().Sugar(), memory.NewStore(), auditMemory.NewStore(), cfg)
+
+	t.Log("Given the need to throttle failed logins")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When computing delays", testID)
+		{
+			for failures, exp := range []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+				if got := core.delay(failures); got != exp {
+					t.Fatalf("\t%s\t Test %d Should delay %v after %d failures, got %v", tests.Failed, testID, exp, failures, got)
+				}
+			}
+			t.Logf("\t%s\t Test %d Should double the delay up to the max", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When failures reach the threshold", testID)
+		{
+			for i := 0; i < cfg.Threshold; i++ {
+				if err := core.Failed(ctx, "Gopher@example.com", "10.0.0.1", now); err != nil {
+					t.Fatalf("\t%s\t Test %d Should record the failure: %v", tests.Failed, testID, err)
+				}
+			}
+
+			retry, err := core.Check(ctx, "gopher@example.com", "10.0.0.2", now)
+			if !errors.Is(err, ErrLocked) || retry != cfg.LockFor {
+				t.Fatalf("\t%s\t Test %d Should lock the account for %v, got %v %v", tests.Failed, testID, cfg.LockFor, retry, err)
+			}
+			t.Logf("\t%s\t Test %d Should lock the account whatever the case of the email", tests.Succeeded, testID)
+
+			if _, err := core.Check(ctx, "other@example.com", "10.0.0.1", now); err != nil {
+				t.Fatalf("\t%s\t Test %d Should not lock the address yet: %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should not lock the address yet", tests.Succeeded, testID)
+
+			if _, err := core.Check(ctx, "gopher@example.com", "10.0.0.2", now.Add(cfg.LockFor)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should unlock once the lock ran out: %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should unlock once the lock ran out", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When failures are older than the window", testID)
+		{
+			later := now.Add(cfg.Window + time.Minute)
+			if err := core.Failed(ctx, "gopher@example.com", "10.0.0.3", later); err != nil {
+				t.Fatalf("\t%s\t Test %d Should record the failure: %v", tests.Failed, testID, err)
+			}
+
+			f, err := core.store.QueryByKey(ctx, accountKey("gopher@example.com"))
+			if err != nil || f.Failures != 1 {
+				t.Fatalf("\t%s\t Test %d Should start counting over, got %d %v", tests.Failed, testID, f.Failures, err)
+			}
+			t.Logf("\t%s\t Test %d Should start counting over", tests.Succeeded, testID)
+
+			if _, err := core.store.QueryByKey(ctx, ipKey("10.0.0.1")); !errors.Is(err, database.ErrNotFound) {
+				t.Fatalf("\t%s\t Test %d Should forget the keys out of the window, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should forget the keys out of the window", tests.Succeeded, testID)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package mfa provides the core business API for the second factor of a
+// login: TOTP authenticator apps with recovery codes as a fallback.
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/mfa"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/foundation/totp"
+	"strings"
+	"time"
+)
+
+// Set of error variables for the second factor.
+var (
+	ErrInvalidCode    = errors.New("invalid mfa code")
+	ErrNotEnrolled    = errors.New("mfa is not enrolled")
+	ErrAlreadyEnabled = errors.New("mfa is already enabled")
+	ErrRequired       = errors.New("mfa is required for this account")
+)
+
+// recoveryCodes is how many recovery codes a user gets.
+const recoveryCodes = 10
+
+// skew is how many time steps around now are accepted.
+const skew = 1
+
+// Storer interface declares the behavior this package needs to persist
+// second factors.
+type Storer interface {
+	QueryByUserID(ctx context.Context, userID string) (mfa.MFA, error)
+	Save(ctx context.Context, m mfa.MFA) error
+	Delete(ctx context.Context, userID string) error
+	UseStep(ctx context.Context, userID string, step int64, now time.Time) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codes []mfa.RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, userID string, hash string, now time.Time) error
+}
+
+// Config holds the mfa policy.
+type Config struct {
+	// Issuer names the service in authenticator apps.
+	Issuer string
+
+	// RequireForAdmin refuses tokens to admins without a second factor.
+	RequireForAdmin bool
+
+	// ChallengeTTL is how long a user has to present the second factor
+	// once the password was accepted.
+	ChallengeTTL time.Duration
+}
+
+// Enrollment is what a user needs to set up an authenticator app.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Code is a one time password or a recovery code.
+type Code struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type Core struct {
+	logger *zap.SugaredLogger
+	store  Storer
+	cfg    Config
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, cfg Config) Core {
+	return Core{
+		logger: log,
+		store:  store,
+		cfg:    cfg,
+	}
+}
+
+// Required reports whether the policy demands a second factor for the
+// claims.
+func (c Core) Required(claims auth.Claims) bool {
+	return c.cfg.RequireForAdmin && claims.Authorized(auth.RoleAdmin)
+}
+
+// ChallengeTTL returns how long the tokens proving a login step are valid.
+func (c Core) ChallengeTTL() time.Duration {
+	return c.cfg.ChallengeTTL
+}
+
+// Enabled reports whether the user has a confirmed second factor.
+func (c Core) Enabled(ctx context.Context, userID string) (bool, error) {
+	m, err := c.store.QueryByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("Enabled: %w", err)
+	}
+	return m.Enabled, nil
+}
+
+// Enroll generates a new secret for the user. It is not enforced until
+// confirmed, enrolling again before that replaces the secret.
+func (c Core) Enroll(ctx context.Context, userID string, account string, now time.Time) (Enrollment, error) {
+	enabled, err := c.Enabled(ctx, userID)
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("Enroll: %w", err)
+	}
+	if enabled {
+		return Enrollment{}, fmt.Errorf("Enroll: %w", ErrAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return Enrollment{}, fmt.Errorf("Enroll: %w", err)
+	}
+
+	m := mfa.MFA{
+		UserID:      userID,
+		Secret:      secret,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+	if err := c.store.Save(ctx, m); err != nil {
+		return Enrollment{}, fmt.Errorf("Enroll: %w", err)
+	}
+
+	enr := Enrollment{
+		Secret: secret,
+		URI:    totp.URI(c.cfg.Issuer, account, secret),
+	}
+	return enr, nil
+}
+
+// Confirm enables the second factor once the user shows a valid code, and
+// returns the recovery codes. They are only ever shown this once.
+func (c Core) Confirm(ctx context.Context, userID string, code string, now time.Time) ([]string, error) {
+	m, err := c.store.QueryByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("Confirm: %w", ErrNotEnrolled)
+		}
+		return nil, fmt.Errorf("Confirm: %w", err)
+	}
+
+	if m.Enabled {
+		return nil, fmt.Errorf("Confirm: %w", ErrAlreadyEnabled)
+	}
+
+	step, ok := totp.Validate(m.Secret, code, now, skew)
+	if !ok {
+		return nil, fmt.Errorf("Confirm: %w", ErrInvalidCode)
+	}
+
+	plain, codes, err := newRecoveryCodes(userID, now)
+	if err != nil {
+		return nil, fmt.Errorf("Confirm: %w", err)
+	}
+
+	if err := c.store.ReplaceRecoveryCodes(ctx, userID, codes); err != nil {
+		return nil, fmt.Errorf("Confirm: %w", err)
+	}
+
+	m.Enabled = true
+	m.LastStep = step
+	m.DateUpdated = now
+	if err := c.store.Save(ctx, m); err != nil {
+		return nil, fmt.Errorf("Confirm: %w", err)
+	}
+
+	return plain, nil
+}
+
+// Verify checks a code from the authenticator app or a recovery code. A
+// code is accepted once only.
+func (c Core) Verify(ctx context.Context, userID string, code string, now time.Time) error {
+	m, err := c.store.QueryByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("Verify: %w", ErrNotEnrolled)
+		}
+		return fmt.Errorf("Verify: %w", err)
+	}
+
+	if !m.Enabled {
+		return fmt.Errorf("Verify: %w", ErrNotEnrolled)
+	}
+
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(m.Secret, code, now, skew)
+		if !ok {
+			return fmt.Errorf("Verify: %w", ErrInvalidCode)
+		}
+
+		if err := c.store.UseStep(ctx, userID, step, now); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return fmt.Errorf("Verify: %w", ErrInvalidCode)
+			}
+			return fmt.Errorf("Verify: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.store.UseRecoveryCode(ctx, userID, hashCode(code), now); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("Verify: %w", ErrInvalidCode)
+		}
+		return fmt.Errorf("Verify: %w", err)
+	}
+
+	c.logger.Infow("mfa", "status", "recovery code used", "userID", userID)
+	return nil
+}
+
+// Disable removes the second factor after checking a code. Accounts the
+// policy requires a second factor for can not disable it.
+func (c Core) Disable(ctx context.Context, claims auth.Claims, code string, now time.Time) error {
+	if c.Required(claims) {
+		return fmt.Errorf("Disable: %w", ErrRequired)
+	}
+
+	if err := c.Verify(ctx, claims.Subject, code, now); err != nil {
+		return fmt.Errorf("Disable: %w", err)
+	}
+
+	if err := c.store.Delete(ctx, claims.Subject); err != nil {
+		return fmt.Errorf("Disable: %w", err)
+	}
+	return nil
+}
+
+// newRecoveryCodes returns the codes to show the user and the hashed
+// records to store.
+func newRecoveryCodes(userID string, now time.Time) ([]string, []mfa.RecoveryCode, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	plain := make([]string, recoveryCodes)
+	codes := make([]mfa.RecoveryCode, recoveryCodes)
+	for i := range plain {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+
+		s := strings.ToLower(enc.EncodeToString(b))
+		plain[i] = s[:8] + "-" + s[8:]
+		codes[i] = mfa.RecoveryCode{
+			UserID:      userID,
+			Hash:        hashCode(plain[i]),
+			DateCreated: now,
+		}
+	}
+	return plain, codes, nil
+}
+
+// hashCode hashes a recovery code. They carry eighty random bits, a fast
+// hash is enough.
+func hashCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,116 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"service/domain/data/store/mfa/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/auth"
+	"service/domain/sys/validate"
+	"service/foundation/totp"
+	"testing"
+	"time"
+)
+
+func TestMFA(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	cfg := Config{
+		Issuer:          "sales-api",
+		RequireForAdmin: true,
+		ChallengeTTL:    5 * time.Minute,
+	}
+	core := NewCore(zap.NewNop().Sugar(), memory.NewStore(), cfg)
+
+	admin := auth.Claims{
+		StandardClaims: jwt.StandardClaims{Subject: validate.GenerateUID()},
+		Roles:          []string{auth.RoleAdmin},
+	}
+
+	t.Log("Given the need to protect admins with a second factor")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When enrolling", testID)
+		{
+			if !core.Required(admin) {
+				t.Fatalf("\t%s\t Test %d Should require a second factor for admins", tests.Failed, testID)
+			}
+			t.Logf("\t%s\t Test %d Should require a second factor for admins", tests.Succeeded, testID)
+
+			if err := core.Verify(ctx, admin.Subject, "123456", now); !errors.Is(err, ErrNotEnrolled) {
+				t.Fatalf("\t%s\t Test %d Should not verify before enrolling, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should not verify before enrolling", tests.Succeeded, testID)
+
+			enr, err := core.Enroll(ctx, admin.Subject, "admin@example.com", now)
+			if err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to enroll: %v", tests.Failed, testID, err)
+			}
+
+			if err := core.Verify(ctx, admin.Subject, code(t, enr.Secret, now), now); !errors.Is(err, ErrNotEnrolled) {
+				t.Fatalf("\t%s\t Test %d Should not verify before confirming, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should not verify before confirming", tests.Succeeded, testID)
+
+			codes, err := core.Confirm(ctx, admin.Subject, code(t, enr.Secret, now), now)
+			if err != nil || len(codes) != recoveryCodes {
+				t.Fatalf("\t%s\t Test %d Should confirm with a valid code, got %v %v", tests.Failed, testID, codes, err)
+			}
+			t.Logf("\t%s\t Test %d Should confirm with a valid code", tests.Succeeded, testID)
+
+			testID++
+			t.Logf("\t Test %d \t When verifying codes", testID)
+
+			if err := core.Verify(ctx, admin.Subject, code(t, enr.Secret, now), now); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("\t%s\t Test %d Should refuse the code used to confirm, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse the code used to confirm", tests.Succeeded, testID)
+
+			later := now.Add(totp.Period)
+			if err := core.Verify(ctx, admin.Subject, code(t, enr.Secret, later), later); err != nil {
+				t.Fatalf("\t%s\t Test %d Should accept the next code: %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should accept the next code", tests.Succeeded, testID)
+
+			if err := core.Verify(ctx, admin.Subject, code(t, enr.Secret, now), later); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("\t%s\t Test %d Should refuse an older code, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse an older code", tests.Succeeded, testID)
+
+			if err := core.Verify(ctx, admin.Subject, " "+codes[0]+" ", now); err != nil {
+				t.Fatalf("\t%s\t Test %d Should accept a recovery code: %v", tests.Failed, testID, err)
+			}
+			if err := core.Verify(ctx, admin.Subject, codes[0], now); !errors.Is(err, ErrInvalidCode) {
+				t.Fatalf("\t%s\t Test %d Should accept a recovery code once, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should accept a recovery code once", tests.Succeeded, testID)
+
+			testID++
+			t.Logf("\t Test %d \t When disabling", testID)
+
+			if err := core.Disable(ctx, admin, codes[1], now); !errors.Is(err, ErrRequired) {
+				t.Fatalf("\t%s\t Test %d Should not let admins disable it, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should not let admins disable it", tests.Succeeded, testID)
+
+			if _, err := core.Enroll(ctx, admin.Subject, "admin@example.com", now); !errors.Is(err, ErrAlreadyEnabled) {
+				t.Fatalf("\t%s\t Test %d Should not enroll twice, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should not enroll twice", tests.Succeeded, testID)
+		}
+	}
+}
+
+// code returns the one time password at t.
+func code(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	c, err := totp.Code(secret, totp.Step(at))
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to compute a code: %v", tests.Failed, err)
+	}
+	return c
+}
@@ -0,0 +1,326 @@
+// Package order provides the core business API for orders. Orders are
+// priced from the cost of their products and the promotions running when
+// placed, and their stock is taken from a warehouse by the store in the
+// same step.
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	promotionCore "service/domain/core/promotion"
+	"service/domain/data/store/order"
+	"service/domain/data/store/product"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"time"
+)
+
+// Set of error variables for placing orders.
+var (
+	ErrUnknownProduct   = errors.New("unknown product")
+	ErrUnknownVariant   = errors.New("unknown variant of the product")
+	ErrNeedsVariant     = errors.New("product is sold by variant")
+	ErrUnknownWarehouse = errors.New("unknown warehouse")
+	ErrInvalidPeriod    = errors.New("period must end after it starts")
+)
+
+// Storer interface declares the behavior this package needs to persist
+// and retrieve orders. Create must take the stock of every item or fail
+// with product.ErrInsufficientStock without taking any, and redeem the
+// coupon of the order or fail with promotion.ErrCouponUnavailable.
+type Storer interface {
+	Create(ctx context.Context, o order.Order) error
+	QueryByID(ctx context.Context, orderID string) (order.Order, error)
+	QueryByCustomer(ctx context.Context, customerID string, pageNumber int, rowsPerPage int) ([]order.Order, error)
+	QueryMargins(ctx context.Context, f order.MarginFilter) ([]order.Margin, error)
+}
+
+// ProductStorer looks up the products being ordered and their variants.
+type ProductStorer interface {
+	QueryByIDs(ctx context.Context, productIDs []string) ([]product.Product, error)
+	QueryVariantsByProducts(ctx context.Context, productIDs []string) ([]product.Variant, error)
+}
+
+// WarehouseStorer looks up the warehouse an order is picked from.
+type WarehouseStorer interface {
+	QueryByID(ctx context.Context, warehouseID string) (warehouse.Warehouse, error)
+	QueryDefault(ctx context.Context) (warehouse.Warehouse, error)
+}
+
+// Pricer works out the discounts of the lines of an order from the
+// promotions running at now and the coupon of the code.
+type Pricer interface {
+	Price(ctx context.Context, lines []promotionCore.Line, code string, now time.Time) (promotionCore.Pricing, error)
+}
+
+// StockWatcher is told which products every order sold, to look for the
+// ones running low. It must not block the order.
+type StockWatcher interface {
+	Sold(productIDs []string)
+}
+
+type Core struct {
+	logger     *zap.SugaredLogger
+	store      Storer
+	products   ProductStorer
+	warehouses WarehouseStorer
+	pricer     Pricer
+	watcher    StockWatcher
+}
+
+// NewCore constructs the core, watcher may be nil when nothing watches
+// the stock.
+func NewCore(log *zap.SugaredLogger, store Storer, products ProductStorer, warehouses WarehouseStorer, pricer Pricer, watcher StockWatcher) Core {
+	return Core{
+		logger:     log,
+		store:      store,
+		products:   products,
+		warehouses: warehouses,
+		pricer:     pricer,
+		watcher:    watcher,
+	}
+}
+
+// Create places the order for the user in claims. Items naming the same
+// product and variant are merged into the line of the first one. A product
+// with variants must be bought by variant, which is priced by its own
+// price when it has one. Every product must be priced in the currency of
+// the order, money.ErrMismatch is returned otherwise. The items are then
+// discounted by the promotions running at now and the coupon of the
+// order, if it gives one. The stock is taken from the warehouse of the
+// order, the default one when it names none.
+func (c Core) Create(ctx context.Context, claims auth.Claims, no order.NewOrder, now time.Time) (order.Order, error) {
+	if err := validate.Check(no); err != nil {
+		return order.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	wh, err := c.warehouse(ctx, no.WarehouseID)
+	if err != nil {
+		return order.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	type key struct {
+		productID string
+		variantID string
+	}
+
+	var keys []key
+	var ids []string
+	quantities := make(map[key]int)
+	for _, ni := range no.Items {
+		k := key{productID: ni.ProductID}
+		if ni.VariantID != nil {
+			k.variantID = *ni.VariantID
+		}
+
+		if _, ok := quantities[k]; !ok {
+			keys = append(keys, k)
+			ids = append(ids, k.productID)
+		}
+		quantities[k] += ni.Quantity
+	}
+
+	prds, err := c.products.QueryByIDs(ctx, ids)
+	if err != nil {
+		return order.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	vars, err := c.products.QueryVariantsByProducts(ctx, ids)
+	if err != nil {
+		return order.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	prices := make(map[key]money.Money, len(prds)+len(vars))
+	costs := make(map[string]int, len(prds))
+	categories := make(map[string]*string, len(prds))
+	for _, p := range prds {
+		prices[key{productID: p.ID}] = p.Price()
+		costs[p.ID] = p.PurchaseCost
+		categories[p.ID] = p.CategoryID
+	}
+
+	hasVariants := make(map[string]bool)
+	for _, v := range vars {
+		hasVariants[v.ProductID] = true
+
+		price, ok := prices[key{productID: v.ProductID}]
+		if !ok {
+			continue
+		}
+		if v.Price != nil {
+			price = money.New(int64(*v.Price), price.Currency())
+		}
+		prices[key{productID: v.ProductID, variantID: v.ID}] = price
+	}
+
+	o := order.Order{
+		ID:          validate.GenerateUID(),
+		CustomerID:  claims.Subject,
+		WarehouseID: wh.ID,
+		Currency:    no.Currency,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	lines := make([]promotionCore.Line, len(keys))
+	for i, k := range keys {
+		if _, ok := prices[key{productID: k.productID}]; !ok {
+			return order.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, ErrUnknownProduct)
+		}
+
+		if k.variantID == "" && hasVariants[k.productID] {
+			return order.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, ErrNeedsVariant)
+		}
+
+		price, ok := prices[k]
+		if !ok {
+			return order.Order{}, fmt.Errorf("Create: product %s variant %s: %w", k.productID, k.variantID, ErrUnknownVariant)
+		}
+
+		if i == 0 && o.Currency == "" {
+			o.Currency = price.Currency()
+		}
+		if price.Currency() != o.Currency {
+			return order.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, money.ErrMismatch)
+		}
+
+		lines[i] = promotionCore.Line{
+			ProductID:  k.productID,
+			CategoryID: categories[k.productID],
+			Quantity:   quantities[k],
+			UnitPrice:  price,
+		}
+	}
+
+	pr, err := c.pricer.Price(ctx, lines, no.CouponCode, now)
+	if err != nil {
+		return order.Order{}, fmt.Errorf("Create: %w", err)
+	}
+	if pr.Coupon != nil {
+		couponID := pr.Coupon.ID
+		o.CouponID = &couponID
+	}
+
+	total := money.Zero(o.Currency)
+	for i, k := range keys {
+		line, err := lines[i].UnitPrice.Mul(int64(lines[i].Quantity))
+		if err != nil {
+			return order.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, err)
+		}
+
+		item := order.Item{
+			OrderID:   o.ID,
+			Line:      i + 1,
+			ProductID: k.productID,
+			Quantity:  lines[i].Quantity,
+			UnitPrice: int(lines[i].UnitPrice.Amount()),
+			UnitCost:  costs[k.productID],
+		}
+		if k.variantID != "" {
+			variantID := k.variantID
+			item.VariantID = &variantID
+		}
+
+		for _, d := range pr.Discounts[i] {
+			if line, err = line.Sub(d.Amount); err != nil {
+				return order.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, err)
+			}
+			item.Discount += int(d.Amount.Amount())
+			item.Discounts = append(item.Discounts, order.Discount{
+				OrderID:     o.ID,
+				Line:        item.Line,
+				PromotionID: d.PromotionID,
+				CouponID:    d.CouponID,
+				Amount:      int(d.Amount.Amount()),
+			})
+		}
+		item.Total = int(line.Amount())
+
+		if total, err = total.Add(line); err != nil {
+			return order.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, err)
+		}
+		o.Discount += item.Discount
+		o.Items = append(o.Items, item)
+	}
+	o.Total = int(total.Amount())
+
+	if err := c.store.Create(ctx, o); err != nil {
+		return order.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	if c.watcher != nil {
+		c.watcher.Sold(ids)
+	}
+	return o, nil
+}
+
+// QueryByID returns the order, only admins can see the orders of others.
+func (c Core) QueryByID(ctx context.Context, claims auth.Claims, orderID string) (order.Order, error) {
+	if err := validate.CheckID(orderID); err != nil {
+		return order.Order{}, fmt.Errorf("QueryByID: %w", database.ErrInvalidID)
+	}
+
+	o, err := c.store.QueryByID(ctx, orderID)
+	if err != nil {
+		return order.Order{}, fmt.Errorf("QueryByID: %w", err)
+	}
+
+	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != o.CustomerID {
+		return order.Order{}, fmt.Errorf("QueryByID: %w", database.ErrForbidden)
+	}
+	return o, nil
+}
+
+// QueryByCustomer returns a page of the orders of the customer, only
+// admins can list the orders of others.
+func (c Core) QueryByCustomer(ctx context.Context, claims auth.Claims, customerID string, pageNumber int, rowsPerPage int) ([]order.Order, error) {
+	if err := validate.CheckID(customerID); err != nil {
+		return nil, fmt.Errorf("QueryByCustomer: %w", database.ErrInvalidID)
+	}
+
+	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != customerID {
+		return nil, fmt.Errorf("QueryByCustomer: %w", database.ErrForbidden)
+	}
+
+	orders, err := c.store.QueryByCustomer(ctx, customerID, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("QueryByCustomer: %w", err)
+	}
+	return orders, nil
+}
+
+// Margins returns what every product sold in the orders of the filter
+// made over its purchase cost, the highest margin first.
+func (c Core) Margins(ctx context.Context, f order.MarginFilter) ([]order.Margin, error) {
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return nil, fmt.Errorf("Margins: %w", ErrInvalidPeriod)
+	}
+
+	margins, err := c.store.QueryMargins(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("Margins: %w", err)
+	}
+	return margins, nil
+}
+
+// warehouse returns the warehouse with the id, or the default warehouse
+// when there is none.
+func (c Core) warehouse(ctx context.Context, warehouseID *string) (warehouse.Warehouse, error) {
+	if warehouseID == nil {
+		return c.warehouses.QueryDefault(ctx)
+	}
+
+	wh, err := c.warehouses.QueryByID(ctx, *warehouseID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return warehouse.Warehouse{}, fmt.Errorf("warehouse %s: %w", *warehouseID, ErrUnknownWarehouse)
+		}
+		return warehouse.Warehouse{}, err
+	}
+	return wh, nil
+}
@@ -0,0 +1,337 @@
+// Package product provides the core business API for browsing the
+// catalog. Products are filed under a tree of categories and carry tags,
+// both of which customers can filter by. Products can come in variants
+// with a sku and a stock of their own.
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/category"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"sort"
+	"time"
+)
+
+// Set of error variables for browsing the catalog.
+var (
+	ErrUnknownCategory = errors.New("unknown category")
+	ErrInvalidTag      = errors.New("tags must contain letters or digits")
+	ErrEmptySearch     = errors.New("search must contain letters or digits")
+)
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve products and their variants. QueryByIDs and
+// QueryVariantsByProducts let the same store price orders, SetLevel and
+// Adjust let warehouses record the stock they count or adjust, and the
+// movements, discrepancies and low stock let inventory look into the
+// ledger and the products running low.
+type Storer interface {
+	UpdateCategory(ctx context.Context, p product.Product) error
+	ReplaceTags(ctx context.Context, p product.Product) error
+	Query(ctx context.Context, f product.Filter, pageNumber int, rowsPerPage int) ([]product.Product, error)
+	QueryByID(ctx context.Context, productID string) (product.Product, error)
+	QueryByIDs(ctx context.Context, productIDs []string) ([]product.Product, error)
+	CountByCategory(ctx context.Context, f product.Filter) (map[string]int, error)
+	QueryTags(ctx context.Context) ([]product.TagCount, error)
+	Search(ctx context.Context, query string, limit int) ([]product.Match, error)
+	CreateVariant(ctx context.Context, v product.Variant) error
+	UpdateVariant(ctx context.Context, v product.Variant) error
+	QueryVariantByID(ctx context.Context, variantID string) (product.Variant, error)
+	QueryVariantsByProducts(ctx context.Context, productIDs []string) ([]product.Variant, error)
+	QueryLevels(ctx context.Context, productID string) ([]product.Level, error)
+	SetLevel(ctx context.Context, src product.Source, l product.Level) error
+	Adjust(ctx context.Context, src product.Source, m product.StockMove, now time.Time) error
+	QueryMovements(ctx context.Context, f product.MovementFilter, pageNumber int, rowsPerPage int) ([]product.Movement, error)
+	QueryDiscrepancies(ctx context.Context) ([]product.Discrepancy, error)
+	UpdateReorderPoint(ctx context.Context, p product.Product) error
+	QueryLowStock(ctx context.Context) ([]product.LowStock, error)
+}
+
+// CategoryStorer looks up the categories products are filed under.
+type CategoryStorer interface {
+	Query(ctx context.Context) ([]category.Category, error)
+	QueryByID(ctx context.Context, categoryID string) (category.Category, error)
+	QueryBySlug(ctx context.Context, slug string) (category.Category, error)
+	QueryDescendantIDs(ctx context.Context, categoryID string) ([]string, error)
+}
+
+// QueryFilter is what customers browse the catalog by. Category is the id
+// or the slug of a category, products filed under the categories below it
+// are included. Products must carry every tag.
+type QueryFilter struct {
+	Category string
+	Tags     []string
+}
+
+// Facet is a category along with how many products matching a filter are
+// filed under it or any category below it.
+type Facet struct {
+	CategoryID string  `json:"category_id"`
+	ParentID   *string `json:"parent_id"`
+	Name       string  `json:"name"`
+	Slug       string  `json:"slug"`
+	Count      int     `json:"count"`
+}
+
+type Core struct {
+	logger     *zap.SugaredLogger
+	store      Storer
+	categories CategoryStorer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, categories CategoryStorer) Core {
+	return Core{
+		logger:     log,
+		store:      store,
+		categories: categories,
+	}
+}
+
+// Query returns a page of the products matching the filter.
+func (c Core) Query(ctx context.Context, qf QueryFilter, pageNumber int, rowsPerPage int) ([]product.Product, error) {
+	f, err := c.filter(ctx, qf)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+
+	prds, err := c.store.Query(ctx, f, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	return prds, nil
+}
+
+// QueryByID returns the product with the id.
+func (c Core) QueryByID(ctx context.Context, productID string) (product.Product, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return product.Product{}, fmt.Errorf("QueryByID: %w", database.ErrInvalidID)
+	}
+
+	p, err := c.store.QueryByID(ctx, productID)
+	if err != nil {
+		return product.Product{}, fmt.Errorf("QueryByID: %w", err)
+	}
+	return p, nil
+}
+
+// Facets counts the products matching the filter under every category,
+// a category counts the products of the categories below it too. The
+// facets are ordered by name like the categories.
+func (c Core) Facets(ctx context.Context, qf QueryFilter) ([]Facet, error) {
+	f, err := c.filter(ctx, qf)
+	if err != nil {
+		return nil, fmt.Errorf("Facets: %w", err)
+	}
+
+	counts, err := c.store.CountByCategory(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("Facets: %w", err)
+	}
+
+	cats, err := c.categories.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Facets: %w", err)
+	}
+
+	parents := make(map[string]*string, len(cats))
+	for _, cat := range cats {
+		parents[cat.ID] = cat.ParentID
+	}
+
+	// Every product counts for its category and all the categories above
+	// it, the depth bound guards against a broken tree.
+	totals := make(map[string]int, len(cats))
+	for id, n := range counts {
+		next := &id
+		for depth := 0; next != nil && depth <= len(cats); depth++ {
+			totals[*next] += n
+			next = parents[*next]
+		}
+	}
+
+	facets := make([]Facet, len(cats))
+	for i, cat := range cats {
+		facets[i] = Facet{
+			CategoryID: cat.ID,
+			ParentID:   cat.ParentID,
+			Name:       cat.Name,
+			Slug:       cat.Slug,
+			Count:      totals[cat.ID],
+		}
+	}
+	return facets, nil
+}
+
+// Search returns up to limit products whose name has a word starting with
+// every word of the query, best matches first.
+func (c Core) Search(ctx context.Context, query string, limit int) ([]product.Match, error) {
+	if len(product.SearchTerms(query)) == 0 {
+		return nil, fmt.Errorf("Search: %w", ErrEmptySearch)
+	}
+
+	matches, err := c.store.Search(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("Search: %w", err)
+	}
+	return matches, nil
+}
+
+// Tags returns every tag in use along with how many products carry it.
+func (c Core) Tags(ctx context.Context) ([]product.TagCount, error) {
+	tags, err := c.store.QueryTags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Tags: %w", err)
+	}
+	return tags, nil
+}
+
+// UpdateCategory files the product under the category, or under none.
+func (c Core) UpdateCategory(ctx context.Context, productID string, uc product.UpdateCategory, now time.Time) (product.Product, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateCategory: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(uc); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateCategory: %w", err)
+	}
+
+	p, err := c.store.QueryByID(ctx, productID)
+	if err != nil {
+		return product.Product{}, fmt.Errorf("UpdateCategory: %w", err)
+	}
+
+	p.CategoryID = nil
+	if uc.CategoryID != nil {
+		cat, err := c.categories.QueryByID(ctx, *uc.CategoryID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return product.Product{}, fmt.Errorf("UpdateCategory: category %s: %w", *uc.CategoryID, ErrUnknownCategory)
+			}
+			return product.Product{}, fmt.Errorf("UpdateCategory: %w", err)
+		}
+		p.CategoryID = &cat.ID
+	}
+
+	p.DateUpdated = now
+
+	if err := c.store.UpdateCategory(ctx, p); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateCategory: %w", err)
+	}
+	return p, nil
+}
+
+// UpdateReorderPoint sets the stock the product is reordered at, a product
+// at or below it is reported low on stock.
+func (c Core) UpdateReorderPoint(ctx context.Context, productID string, ur product.UpdateReorderPoint, now time.Time) (product.Product, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateReorderPoint: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(ur); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateReorderPoint: %w", err)
+	}
+
+	p, err := c.store.QueryByID(ctx, productID)
+	if err != nil {
+		return product.Product{}, fmt.Errorf("UpdateReorderPoint: %w", err)
+	}
+
+	p.ReorderPoint = ur.ReorderPoint
+	p.DateUpdated = now
+
+	if err := c.store.UpdateReorderPoint(ctx, p); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateReorderPoint: %w", err)
+	}
+	return p, nil
+}
+
+// UpdateTags replaces the tags of the product. Tags are stored in the same
+// form as category slugs, "Board Games" becomes "board-games".
+func (c Core) UpdateTags(ctx context.Context, productID string, ut product.UpdateTags, now time.Time) (product.Product, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateTags: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(ut); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateTags: %w", err)
+	}
+
+	tags, err := normalizeTags(ut.Tags)
+	if err != nil {
+		return product.Product{}, fmt.Errorf("UpdateTags: %w", err)
+	}
+
+	p, err := c.store.QueryByID(ctx, productID)
+	if err != nil {
+		return product.Product{}, fmt.Errorf("UpdateTags: %w", err)
+	}
+
+	p.Tags = tags
+	p.DateUpdated = now
+
+	if err := c.store.ReplaceTags(ctx, p); err != nil {
+		return product.Product{}, fmt.Errorf("UpdateTags: %w", err)
+	}
+	return p, nil
+}
+
+// filter resolves the category of the filter to the ids of the categories
+// under it, and brings the tags to their stored form.
+func (c Core) filter(ctx context.Context, qf QueryFilter) (product.Filter, error) {
+	tags, err := normalizeTags(qf.Tags)
+	if err != nil {
+		return product.Filter{}, err
+	}
+
+	f := product.Filter{
+		Tags: tags,
+	}
+
+	if qf.Category == "" {
+		return f, nil
+	}
+
+	var cat category.Category
+	if validate.CheckID(qf.Category) == nil {
+		cat, err = c.categories.QueryByID(ctx, qf.Category)
+	} else {
+		cat, err = c.categories.QueryBySlug(ctx, qf.Category)
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return product.Filter{}, fmt.Errorf("category %s: %w", qf.Category, ErrUnknownCategory)
+		}
+		return product.Filter{}, err
+	}
+
+	if f.CategoryIDs, err = c.categories.QueryDescendantIDs(ctx, cat.ID); err != nil {
+		return product.Filter{}, err
+	}
+	return f, nil
+}
+
+// normalizeTags returns the tags in their stored form, sorted and without
+// duplicates.
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	out := []string{}
+	for _, tag := range tags {
+		t := category.Slugify(tag)
+		if t == "" {
+			return nil, fmt.Errorf("tag %q: %w", tag, ErrInvalidTag)
+		}
+
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+
+	sort.Strings(out)
+	return out, nil
+}
@@ -0,0 +1,185 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSKU is returned for a sku that is not made of letters and
+// digits, optionally separated by single dashes, dots or underscores.
+var ErrInvalidSKU = errors.New("sku must be letters and digits separated by - . or _")
+
+// skuPattern is the stored form of a sku, always upper case.
+var skuPattern = regexp.MustCompile(`^[A-Z0-9]+([-._][A-Z0-9]+)*$`)
+
+// Stock is how many units of a product are in stock. The quantity of a
+// product with variants is the sum of the quantities of its variants.
+// Levels tell which warehouse holds them, stock in transit between two
+// warehouses is in none.
+type Stock struct {
+	ProductID string          `json:"product_id"`
+	Quantity  int             `json:"quantity"`
+	Variants  []VariantStock  `json:"variants"`
+	Levels    []product.Level `json:"levels"`
+}
+
+// VariantStock is how many units of a variant are in stock.
+type VariantStock struct {
+	VariantID  string             `json:"variant_id"`
+	SKU        string             `json:"sku"`
+	Attributes product.Attributes `json:"attributes"`
+	Quantity   int                `json:"quantity"`
+}
+
+// Variants returns the variants of the product, ordered by sku.
+func (c Core) Variants(ctx context.Context, productID string) ([]product.Variant, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return nil, fmt.Errorf("Variants: %w", database.ErrInvalidID)
+	}
+
+	if _, err := c.store.QueryByID(ctx, productID); err != nil {
+		return nil, fmt.Errorf("Variants: %w", err)
+	}
+
+	vars, err := c.store.QueryVariantsByProducts(ctx, []string{productID})
+	if err != nil {
+		return nil, fmt.Errorf("Variants: %w", err)
+	}
+	return vars, nil
+}
+
+// CreateVariant adds a variant to the product. The sku is stored upper
+// case and must not be used by any other variant.
+func (c Core) CreateVariant(ctx context.Context, productID string, nv product.NewVariant, now time.Time) (product.Variant, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return product.Variant{}, fmt.Errorf("CreateVariant: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(nv); err != nil {
+		return product.Variant{}, fmt.Errorf("CreateVariant: %w", err)
+	}
+
+	sku, err := normalizeSKU(nv.SKU)
+	if err != nil {
+		return product.Variant{}, fmt.Errorf("CreateVariant: %w", err)
+	}
+
+	if _, err := c.store.QueryByID(ctx, productID); err != nil {
+		return product.Variant{}, fmt.Errorf("CreateVariant: %w", err)
+	}
+
+	attrs := nv.Attributes
+	if attrs == nil {
+		attrs = product.Attributes{}
+	}
+
+	v := product.Variant{
+		ID:          validate.GenerateUID(),
+		ProductID:   productID,
+		SKU:         sku,
+		Attributes:  attrs,
+		Price:       nv.Price,
+		Quantity:    nv.Quantity,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.CreateVariant(ctx, v); err != nil {
+		return product.Variant{}, fmt.Errorf("CreateVariant: %w", err)
+	}
+	return v, nil
+}
+
+// UpdateVariant changes the fields of the variant that are set.
+func (c Core) UpdateVariant(ctx context.Context, variantID string, uv product.UpdateVariant, now time.Time) (product.Variant, error) {
+	if err := validate.CheckID(variantID); err != nil {
+		return product.Variant{}, fmt.Errorf("UpdateVariant: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(uv); err != nil {
+		return product.Variant{}, fmt.Errorf("UpdateVariant: %w", err)
+	}
+
+	v, err := c.store.QueryVariantByID(ctx, variantID)
+	if err != nil {
+		return product.Variant{}, fmt.Errorf("UpdateVariant: %w", err)
+	}
+
+	if uv.SKU != nil {
+		if v.SKU, err = normalizeSKU(*uv.SKU); err != nil {
+			return product.Variant{}, fmt.Errorf("UpdateVariant: %w", err)
+		}
+	}
+	if uv.Attributes != nil {
+		v.Attributes = uv.Attributes
+	}
+	if uv.Price != nil {
+		v.Price = uv.Price
+	}
+	v.DateUpdated = now
+
+	if err := c.store.UpdateVariant(ctx, v); err != nil {
+		return product.Variant{}, fmt.Errorf("UpdateVariant: %w", err)
+	}
+	return v, nil
+}
+
+// Stock reports the stock of the product and of every one of its
+// variants, in total and per warehouse.
+func (c Core) Stock(ctx context.Context, productID string) (Stock, error) {
+	if err := validate.CheckID(productID); err != nil {
+		return Stock{}, fmt.Errorf("Stock: %w", database.ErrInvalidID)
+	}
+
+	p, err := c.store.QueryByID(ctx, productID)
+	if err != nil {
+		return Stock{}, fmt.Errorf("Stock: %w", err)
+	}
+
+	vars, err := c.store.QueryVariantsByProducts(ctx, []string{productID})
+	if err != nil {
+		return Stock{}, fmt.Errorf("Stock: %w", err)
+	}
+
+	levels, err := c.store.QueryLevels(ctx, productID)
+	if err != nil {
+		return Stock{}, fmt.Errorf("Stock: %w", err)
+	}
+
+	stock := Stock{
+		ProductID: p.ID,
+		Quantity:  p.Quantity,
+		Variants:  make([]VariantStock, len(vars)),
+		Levels:    levels,
+	}
+
+	if len(vars) > 0 {
+		stock.Quantity = 0
+	}
+	for i, v := range vars {
+		stock.Variants[i] = VariantStock{
+			VariantID:  v.ID,
+			SKU:        v.SKU,
+			Attributes: v.Attributes,
+			Quantity:   v.Quantity,
+		}
+		stock.Quantity += v.Quantity
+	}
+	return stock, nil
+}
+
+// normalizeSKU returns the sku in its stored form.
+func normalizeSKU(sku string) (string, error) {
+	s := strings.ToUpper(strings.TrimSpace(sku))
+	if !skuPattern.MatchString(s) {
+		return "", fmt.Errorf("sku %q: %w", sku, ErrInvalidSKU)
+	}
+	return s, nil
+}
@@ -0,0 +1,241 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"service/domain/data/store/promotion"
+	"service/domain/sys/database"
+	"service/foundation/money"
+	"time"
+)
+
+// Line is a line of an order to price, quantity units of a product at
+// its unit price. CategoryID is the category the product is filed under.
+type Line struct {
+	ProductID  string
+	CategoryID *string
+	Quantity   int
+	UnitPrice  money.Money
+}
+
+// Discount is what a promotion or a coupon takes off a line, only one of
+// the ids is set.
+type Discount struct {
+	PromotionID *string
+	CouponID    *string
+	Amount      money.Money
+}
+
+// Pricing holds the discounts of every line, in the order of the lines,
+// and the coupon they were priced with when it took something off them.
+type Pricing struct {
+	Coupon    *promotion.Coupon
+	Discounts [][]Discount
+}
+
+// Price works out the discounts of the lines at now, with the coupon of
+// the code when it is not empty. The same lines, promotions and coupon
+// always give the same discounts. Every line gets the promotion running on
+// its product or its category that takes the most off it, the one with the
+// lowest id among equals, promotions do not add up. Fixed promotions only
+// apply to lines priced in their currency. The coupon is then taken off
+// what is left of the order and spread over the lines by what is left of
+// each, the minor units that do not divide evenly go to the first lines.
+//
+// An unknown code fails with ErrUnknownCoupon, a coupon that expired or
+// was used up with promotion.ErrCouponUnavailable and a fixed coupon in
+// another currency than the lines with money.ErrMismatch.
+func (c Core) Price(ctx context.Context, lines []Line, code string, now time.Time) (Pricing, error) {
+	promos, err := c.store.QueryActive(ctx, now)
+	if err != nil {
+		return Pricing{}, fmt.Errorf("Price: %w", err)
+	}
+
+	rules := make([]rule, 0, len(promos))
+	for _, p := range promos {
+		r := rule{promo: p}
+		if p.CategoryID != nil {
+			ids, err := c.categories.QueryDescendantIDs(ctx, *p.CategoryID)
+			if err != nil {
+				if errors.Is(err, database.ErrNotFound) {
+					continue
+				}
+				return Pricing{}, fmt.Errorf("Price: %w", err)
+			}
+
+			r.categories = make(map[string]bool, len(ids))
+			for _, id := range ids {
+				r.categories[id] = true
+			}
+		}
+		rules = append(rules, r)
+	}
+
+	var coupon *promotion.Coupon
+	if code != "" {
+		cp, err := c.store.QueryCouponByCode(ctx, promotion.NormalizeCode(code))
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return Pricing{}, fmt.Errorf("Price: coupon %s: %w", code, ErrUnknownCoupon)
+			}
+			return Pricing{}, fmt.Errorf("Price: %w", err)
+		}
+
+		if !cp.Redeemable(now) {
+			return Pricing{}, fmt.Errorf("Price: coupon %s: %w", code, promotion.ErrCouponUnavailable)
+		}
+		coupon = &cp
+	}
+
+	pr, err := apply(lines, rules, coupon)
+	if err != nil {
+		return Pricing{}, fmt.Errorf("Price: %w", err)
+	}
+	return pr, nil
+}
+
+// rule is an active promotion along with every category it covers.
+type rule struct {
+	promo      promotion.Promotion
+	categories map[string]bool
+}
+
+// covers reports whether the promotion runs on the product of the line.
+func (r rule) covers(l Line) bool {
+	if r.promo.ProductID != nil {
+		return *r.promo.ProductID == l.ProductID
+	}
+	return l.CategoryID != nil && r.categories[*l.CategoryID]
+}
+
+// discount returns what the promotion takes off the line, nothing when a
+// fixed promotion is in another currency.
+func (r rule) discount(l Line) (money.Money, error) {
+	cur := l.UnitPrice.Currency()
+
+	switch r.promo.Kind {
+	case promotion.KindPercent:
+		total, err := l.UnitPrice.Mul(int64(l.Quantity))
+		if err != nil {
+			return money.Money{}, err
+		}
+		return total.MulRat(int64(r.promo.Value), 100)
+
+	case promotion.KindFixed:
+		if r.promo.Currency == nil || *r.promo.Currency != cur {
+			return money.Zero(cur), nil
+		}
+		off := int64(r.promo.Value)
+		if off > l.UnitPrice.Amount() {
+			off = l.UnitPrice.Amount()
+		}
+		return money.New(off, cur).Mul(int64(l.Quantity))
+
+	case promotion.KindBuyXGetY:
+		free := l.Quantity / (r.promo.BuyQuantity + r.promo.GetQuantity) * r.promo.GetQuantity
+		return l.UnitPrice.Mul(int64(free))
+	}
+
+	return money.Zero(cur), nil
+}
+
+// apply prices the lines with the rules, ordered by the id of their
+// promotion, and the coupon.
+func apply(lines []Line, rules []rule, coupon *promotion.Coupon) (Pricing, error) {
+	pr := Pricing{
+		Discounts: make([][]Discount, len(lines)),
+	}
+
+	left := make([]money.Money, len(lines))
+	for i, l := range lines {
+		total, err := l.UnitPrice.Mul(int64(l.Quantity))
+		if err != nil {
+			return Pricing{}, fmt.Errorf("product %s: %w", l.ProductID, err)
+		}
+
+		var best *Discount
+		for _, r := range rules {
+			if !r.covers(l) {
+				continue
+			}
+
+			off, err := r.discount(l)
+			if err != nil {
+				return Pricing{}, fmt.Errorf("product %s promotion %s: %w", l.ProductID, r.promo.ID, err)
+			}
+
+			if best == nil || off.Amount() > best.Amount.Amount() {
+				promotionID := r.promo.ID
+				best = &Discount{PromotionID: &promotionID, Amount: off}
+			}
+		}
+
+		if best != nil && best.Amount.Amount() > 0 {
+			pr.Discounts[i] = append(pr.Discounts[i], *best)
+			if total, err = total.Sub(best.Amount); err != nil {
+				return Pricing{}, fmt.Errorf("product %s: %w", l.ProductID, err)
+			}
+		}
+		left[i] = total
+	}
+
+	if coupon == nil || len(lines) == 0 {
+		return pr, nil
+	}
+
+	cur := lines[0].UnitPrice.Currency()
+	rest := money.Zero(cur)
+	ratios := make([]int, len(left))
+	for i, m := range left {
+		var err error
+		if rest, err = rest.Add(m); err != nil {
+			return Pricing{}, err
+		}
+		ratios[i] = int(m.Amount())
+	}
+
+	if rest.Amount() <= 0 {
+		return pr, nil
+	}
+
+	var off money.Money
+	switch coupon.Kind {
+	case promotion.KindPercent:
+		var err error
+		if off, err = rest.MulRat(int64(coupon.Value), 100); err != nil {
+			return Pricing{}, err
+		}
+
+	case promotion.KindFixed:
+		if coupon.Currency == nil || *coupon.Currency != cur {
+			return Pricing{}, fmt.Errorf("coupon %s: %w", coupon.Code, money.ErrMismatch)
+		}
+		off = money.New(int64(coupon.Value), cur)
+		if off.Amount() > rest.Amount() {
+			off = rest
+		}
+
+	default:
+		return pr, nil
+	}
+
+	if off.Amount() <= 0 {
+		return pr, nil
+	}
+
+	parts, err := off.Allocate(ratios...)
+	if err != nil {
+		return Pricing{}, err
+	}
+
+	for i, part := range parts {
+		if part.Amount() == 0 {
+			continue
+		}
+		couponID := coupon.ID
+		pr.Discounts[i] = append(pr.Discounts[i], Discount{CouponID: &couponID, Amount: part})
+		pr.Coupon = coupon
+	}
+	return pr, nil
+}
@@ -0,0 +1,189 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"service/domain/data/store/category"
+	categoryMemory "service/domain/data/store/category/memory"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	"service/domain/data/store/promotion"
+	promotionMemory "service/domain/data/store/promotion/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+func TestPrice(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	products := productMemory.NewStore()
+	categories := categoryMemory.NewStore()
+	core := NewCore(zap.NewNop().Sugar(), promotionMemory.NewStore(), products, categories)
+
+	media := category.Category{ID: validate.GenerateUID(), Name: "Media", Slug: "media", DateCreated: now, DateUpdated: now}
+	comics := category.Category{ID: validate.GenerateUID(), ParentID: &media.ID, Name: "Comics", Slug: "comics", DateCreated: now, DateUpdated: now}
+	for _, c := range []category.Category{media, comics} {
+		if err := categories.Create(ctx, c); err != nil {
+			t.Fatalf("\t%s\tShould be able to create a category: %v", tests.Failed, err)
+		}
+	}
+
+	books := product.Product{ID: validate.GenerateUID(), Name: "Books", Cost: 1000, Currency: money.USD, CategoryID: &comics.ID, DateCreated: now, DateUpdated: now}
+	toys := product.Product{ID: validate.GenerateUID(), Name: "Toys", Cost: 333, Currency: money.USD, DateCreated: now, DateUpdated: now}
+	stickers := product.Product{ID: validate.GenerateUID(), Name: "Stickers", Cost: 50, Currency: money.USD, DateCreated: now, DateUpdated: now}
+	for _, p := range []product.Product{books, toys, stickers} {
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatalf("\t%s\tShould be able to create a product: %v", tests.Failed, err)
+		}
+	}
+
+	line := func(p product.Product, qty int) Line {
+		return Line{ProductID: p.ID, CategoryID: p.CategoryID, Quantity: qty, UnitPrice: p.Price()}
+	}
+
+	amounts := func(ds []Discount) []int64 {
+		var got []int64
+		for _, d := range ds {
+			got = append(got, d.Amount.Amount())
+		}
+		return got
+	}
+
+	usd := money.USD
+	week := now.Add(7 * 24 * time.Hour)
+
+	t.Log("Given the need to price orders with promotions and coupons")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When adding promotions", testID)
+		{
+			bad := []promotion.NewPromotion{
+				{Name: "Too much", Kind: promotion.KindPercent, Value: 101, ProductID: &books.ID, StartsAt: now, EndsAt: week},
+				{Name: "No currency", Kind: promotion.KindFixed, Value: 100, ProductID: &books.ID, StartsAt: now, EndsAt: week},
+				{Name: "Nothing free", Kind: promotion.KindBuyXGetY, BuyQuantity: 2, ProductID: &books.ID, StartsAt: now, EndsAt: week},
+			}
+			for _, np := range bad {
+				if _, err := core.CreatePromotion(ctx, np, now); !errors.Is(err, ErrInvalidDiscount) {
+					t.Fatalf("\t%s\tTest %d Should refuse the %q promotion, got %v", tests.Failed, testID, np.Name, err)
+				}
+			}
+			t.Logf("\t%s\tTest %d Should refuse discounts that do not fit their kind", tests.Succeeded, testID)
+
+			np := promotion.NewPromotion{Name: "Both", Kind: promotion.KindPercent, Value: 10, ProductID: &books.ID, CategoryID: &media.ID, StartsAt: now, EndsAt: week}
+			if _, err := core.CreatePromotion(ctx, np, now); !errors.Is(err, ErrInvalidTarget) {
+				t.Fatalf("\t%s\tTest %d Should refuse a promotion on a product and a category, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d Should refuse a promotion on a product and a category", tests.Succeeded, testID)
+
+			np = promotion.NewPromotion{Name: "Backwards", Kind: promotion.KindPercent, Value: 10, ProductID: &books.ID, StartsAt: week, EndsAt: now}
+			if _, err := core.CreatePromotion(ctx, np, now); !errors.Is(err, ErrInvalidPeriod) {
+				t.Fatalf("\t%s\tTest %d Should refuse a promotion ending before it starts, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d Should refuse a promotion ending before it starts", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When promotions run", testID)
+		{
+			for _, np := range []promotion.NewPromotion{
+				{Name: "Media week", Kind: promotion.KindPercent, Value: 10, CategoryID: &media.ID, StartsAt: now, EndsAt: week},
+				{Name: "Books off", Kind: promotion.KindFixed, Value: 50, Currency: &usd, ProductID: &books.ID, StartsAt: now, EndsAt: week},
+				{Name: "Three for two", Kind: promotion.KindBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductID: &toys.ID, StartsAt: now, EndsAt: week},
+				{Name: "Next week", Kind: promotion.KindPercent, Value: 90, ProductID: &toys.ID, StartsAt: week, EndsAt: week.Add(time.Hour)},
+				{Name: "Free stickers", Kind: promotion.KindPercent, Value: 100, ProductID: &stickers.ID, StartsAt: now, EndsAt: week},
+			} {
+				if _, err := core.CreatePromotion(ctx, np, now); err != nil {
+					t.Fatalf("\t%s\tTest %d Should be able to add the %q promotion: %v", tests.Failed, testID, np.Name, err)
+				}
+			}
+
+			pr, err := core.Price(ctx, []Line{line(books, 1), line(toys, 7)}, "", now)
+			if err != nil || pr.Coupon != nil || len(pr.Discounts) != 2 {
+				t.Fatalf("\t%s\tTest %d Should price the lines, got %+v %v", tests.Failed, testID, pr, err)
+			}
+
+			if got := amounts(pr.Discounts[0]); len(got) != 1 || got[0] != 100 {
+				t.Fatalf("\t%s\tTest %d Should give the best promotion of the parent category, got %v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d Should give the best promotion of the parent category", tests.Succeeded, testID)
+
+			if got := amounts(pr.Discounts[1]); len(got) != 1 || got[0] != 2*333 {
+				t.Fatalf("\t%s\tTest %d Should give a unit away for every two bought, got %v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d Should give a unit away for every two bought", tests.Succeeded, testID)
+
+			pr, err = core.Price(ctx, []Line{line(books, 3), line(toys, 2)}, "", now)
+			if err != nil || len(amounts(pr.Discounts[1])) != 0 {
+				t.Fatalf("\t%s\tTest %d Should not discount too few units, got %+v %v", tests.Failed, testID, pr, err)
+			}
+
+			if got := amounts(pr.Discounts[0]); len(got) != 1 || got[0] != 300 {
+				t.Fatalf("\t%s\tTest %d Should take the fixed amount off every unit, got %v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\tTest %d Should only give the promotions that apply", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When a coupon is given", testID)
+		{
+			limit := 1
+			yesterday := now.Add(-24 * time.Hour)
+			for _, nc := range []promotion.NewCoupon{
+				{Code: "tenoff", Kind: promotion.KindPercent, Value: 10},
+				{Code: "FIVE", Kind: promotion.KindFixed, Value: 500, Currency: &usd, UsageLimit: &limit},
+				{Code: "EUROS", Kind: promotion.KindFixed, Value: 500, Currency: &[]money.Currency{money.EUR}[0]},
+				{Code: "OLD", Kind: promotion.KindPercent, Value: 10, ExpiresAt: &yesterday},
+			} {
+				if _, err := core.CreateCoupon(ctx, nc, now); err != nil {
+					t.Fatalf("\t%s\tTest %d Should be able to add the %s coupon: %v", tests.Failed, testID, nc.Code, err)
+				}
+			}
+
+			if _, err := core.CreateCoupon(ctx, promotion.NewCoupon{Code: "TenOff", Kind: promotion.KindPercent, Value: 5}, now); !errors.Is(err, promotion.ErrUniqueCode) {
+				t.Fatalf("\t%s\tTest %d Should refuse a code in another case, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d Should refuse a code in another case", tests.Succeeded, testID)
+
+			// Books are left at 1000 less 100, toys at 3 * 333 less 333, the
+			// coupon takes 157 off the 1566 and its odd cent goes to the first line.
+			pr, err := core.Price(ctx, []Line{line(books, 1), line(toys, 3)}, "TenOff", now)
+			if err != nil || pr.Coupon == nil || pr.Coupon.Code != "TENOFF" {
+				t.Fatalf("\t%s\tTest %d Should price the lines with the coupon, got %+v %v", tests.Failed, testID, pr, err)
+			}
+
+			onBooks, onToys := amounts(pr.Discounts[0]), amounts(pr.Discounts[1])
+			if len(onBooks) != 2 || onBooks[1] != 91 || len(onToys) != 2 || onToys[1] != 66 || *pr.Discounts[0][1].CouponID != pr.Coupon.ID {
+				t.Fatalf("\t%s\tTest %d Should spread the coupon over what is left of the lines, got %v %v", tests.Failed, testID, onBooks, onToys)
+			}
+			t.Logf("\t%s\tTest %d Should spread the coupon over what is left of the lines", tests.Succeeded, testID)
+
+			if _, err := core.Price(ctx, []Line{line(toys, 1)}, "NOPE", now); !errors.Is(err, ErrUnknownCoupon) {
+				t.Fatalf("\t%s\tTest %d Should refuse an unknown code, got %v", tests.Failed, testID, err)
+			}
+			if _, err := core.Price(ctx, []Line{line(toys, 1)}, "OLD", now); !errors.Is(err, promotion.ErrCouponUnavailable) {
+				t.Fatalf("\t%s\tTest %d Should refuse an expired coupon, got %v", tests.Failed, testID, err)
+			}
+			if _, err := core.Price(ctx, []Line{line(toys, 1)}, "EUROS", now); !errors.Is(err, money.ErrMismatch) {
+				t.Fatalf("\t%s\tTest %d Should refuse a coupon in another currency, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\tTest %d Should refuse coupons that can not be used", tests.Succeeded, testID)
+
+			pr, err = core.Price(ctx, []Line{line(toys, 1)}, "FIVE", now)
+			if got := amounts(pr.Discounts[0]); err != nil || len(got) != 1 || got[0] != 333 {
+				t.Fatalf("\t%s\tTest %d Should not take off more than is left, got %v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\tTest %d Should not take off more than is left", tests.Succeeded, testID)
+
+			pr, err = core.Price(ctx, []Line{line(stickers, 2)}, "TENOFF", now)
+			if got := amounts(pr.Discounts[0]); err != nil || pr.Coupon != nil || len(got) != 1 || got[0] != 100 {
+				t.Fatalf("\t%s\tTest %d Should not use a coupon with nothing left to take off, got %+v %v", tests.Failed, testID, pr, err)
+			}
+			t.Logf("\t%s\tTest %d Should not use a coupon with nothing left to take off", tests.Succeeded, testID)
+		}
+	}
+}
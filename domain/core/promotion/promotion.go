@@ -0,0 +1,191 @@
+// Package promotion provides the core business API for coupons and the
+// promotions running on products and categories, and prices orders with
+// them.
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/category"
+	"service/domain/data/store/product"
+	"service/domain/data/store/promotion"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"time"
+)
+
+// Set of error variables for coupons and promotions.
+var (
+	ErrInvalidDiscount = errors.New("discount does not fit its kind")
+	ErrInvalidTarget   = errors.New("promotion must name either a product or a category")
+	ErrInvalidPeriod   = errors.New("period must end after it starts")
+	ErrUnknownProduct  = errors.New("unknown product")
+	ErrUnknownCategory = errors.New("unknown category")
+	ErrUnknownCoupon   = errors.New("unknown coupon")
+)
+
+// Storer interface declares the behavior this package needs to persist
+// and retrieve coupons and promotions. CreateCoupon must fail with
+// promotion.ErrUniqueCode when another coupon holds the code.
+type Storer interface {
+	CreateCoupon(ctx context.Context, c promotion.Coupon) error
+	QueryCoupons(ctx context.Context, pageNumber int, rowsPerPage int) ([]promotion.Coupon, error)
+	QueryCouponByCode(ctx context.Context, code string) (promotion.Coupon, error)
+	CreatePromotion(ctx context.Context, p promotion.Promotion) error
+	QueryPromotions(ctx context.Context, pageNumber int, rowsPerPage int) ([]promotion.Promotion, error)
+	QueryActive(ctx context.Context, now time.Time) ([]promotion.Promotion, error)
+}
+
+// ProductStorer looks up the product a promotion runs on.
+type ProductStorer interface {
+	QueryByID(ctx context.Context, productID string) (product.Product, error)
+}
+
+// CategoryStorer looks up the category a promotion runs on and the
+// categories below it.
+type CategoryStorer interface {
+	QueryByID(ctx context.Context, categoryID string) (category.Category, error)
+	QueryDescendantIDs(ctx context.Context, categoryID string) ([]string, error)
+}
+
+type Core struct {
+	logger     *zap.SugaredLogger
+	store      Storer
+	products   ProductStorer
+	categories CategoryStorer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, products ProductStorer, categories CategoryStorer) Core {
+	return Core{
+		logger:     log,
+		store:      store,
+		products:   products,
+		categories: categories,
+	}
+}
+
+// CreateCoupon adds a coupon. Its code is stored in upper case.
+func (c Core) CreateCoupon(ctx context.Context, nc promotion.NewCoupon, now time.Time) (promotion.Coupon, error) {
+	if err := validate.Check(nc); err != nil {
+		return promotion.Coupon{}, fmt.Errorf("CreateCoupon: %w", err)
+	}
+
+	if err := checkDiscount(nc.Kind, nc.Value, nc.Currency != nil, 0, 0); err != nil {
+		return promotion.Coupon{}, fmt.Errorf("CreateCoupon: %w", err)
+	}
+
+	cp := promotion.Coupon{
+		ID:          validate.GenerateUID(),
+		Code:        promotion.NormalizeCode(nc.Code),
+		Kind:        nc.Kind,
+		Value:       nc.Value,
+		Currency:    nc.Currency,
+		UsageLimit:  nc.UsageLimit,
+		ExpiresAt:   nc.ExpiresAt,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.CreateCoupon(ctx, cp); err != nil {
+		return promotion.Coupon{}, fmt.Errorf("CreateCoupon: %w", err)
+	}
+	return cp, nil
+}
+
+// QueryCoupons returns a page of the coupons, latest first.
+func (c Core) QueryCoupons(ctx context.Context, pageNumber int, rowsPerPage int) ([]promotion.Coupon, error) {
+	coupons, err := c.store.QueryCoupons(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("QueryCoupons: %w", err)
+	}
+	return coupons, nil
+}
+
+// CreatePromotion adds a promotion on a product or a category.
+func (c Core) CreatePromotion(ctx context.Context, np promotion.NewPromotion, now time.Time) (promotion.Promotion, error) {
+	if err := validate.Check(np); err != nil {
+		return promotion.Promotion{}, fmt.Errorf("CreatePromotion: %w", err)
+	}
+
+	if err := checkDiscount(np.Kind, np.Value, np.Currency != nil, np.BuyQuantity, np.GetQuantity); err != nil {
+		return promotion.Promotion{}, fmt.Errorf("CreatePromotion: %w", err)
+	}
+
+	if !np.StartsAt.Before(np.EndsAt) {
+		return promotion.Promotion{}, fmt.Errorf("CreatePromotion: %w", ErrInvalidPeriod)
+	}
+
+	switch {
+	case (np.ProductID == nil) == (np.CategoryID == nil):
+		return promotion.Promotion{}, fmt.Errorf("CreatePromotion: %w", ErrInvalidTarget)
+
+	case np.ProductID != nil:
+		if _, err := c.products.QueryByID(ctx, *np.ProductID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return promotion.Promotion{}, fmt.Errorf("CreatePromotion: product %s: %w", *np.ProductID, ErrUnknownProduct)
+			}
+			return promotion.Promotion{}, fmt.Errorf("CreatePromotion: %w", err)
+		}
+
+	default:
+		if _, err := c.categories.QueryByID(ctx, *np.CategoryID); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return promotion.Promotion{}, fmt.Errorf("CreatePromotion: category %s: %w", *np.CategoryID, ErrUnknownCategory)
+			}
+			return promotion.Promotion{}, fmt.Errorf("CreatePromotion: %w", err)
+		}
+	}
+
+	p := promotion.Promotion{
+		ID:          validate.GenerateUID(),
+		Name:        np.Name,
+		Kind:        np.Kind,
+		Value:       np.Value,
+		Currency:    np.Currency,
+		BuyQuantity: np.BuyQuantity,
+		GetQuantity: np.GetQuantity,
+		ProductID:   np.ProductID,
+		CategoryID:  np.CategoryID,
+		StartsAt:    np.StartsAt,
+		EndsAt:      np.EndsAt,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.CreatePromotion(ctx, p); err != nil {
+		return promotion.Promotion{}, fmt.Errorf("CreatePromotion: %w", err)
+	}
+	return p, nil
+}
+
+// QueryPromotions returns a page of the promotions, the latest to start
+// first.
+func (c Core) QueryPromotions(ctx context.Context, pageNumber int, rowsPerPage int) ([]promotion.Promotion, error) {
+	promos, err := c.store.QueryPromotions(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("QueryPromotions: %w", err)
+	}
+	return promos, nil
+}
+
+// checkDiscount makes sure the discount fits its kind. Percentages run
+// from 1 to 100, fixed amounts need a currency and only buy_x_get_y
+// discounts give units away, without a value.
+func checkDiscount(kind string, value int, hasCurrency bool, buy int, get int) error {
+	var ok bool
+	switch kind {
+	case promotion.KindPercent:
+		ok = value >= 1 && value <= 100 && !hasCurrency && buy == 0 && get == 0
+	case promotion.KindFixed:
+		ok = value >= 1 && hasCurrency && buy == 0 && get == 0
+	case promotion.KindBuyXGetY:
+		ok = value == 0 && !hasCurrency && buy >= 1 && get >= 1
+	}
+
+	if !ok {
+		return ErrInvalidDiscount
+	}
+	return nil
+}
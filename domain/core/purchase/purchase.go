@@ -0,0 +1,426 @@
+// Package purchase provides the core business API for buying stock from
+// suppliers. A purchase order moves through its statuses by the rules of
+// a small state machine, and the goods received against it go into stock
+// at the cost they were bought for.
+package purchase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/data/store/purchase"
+	"service/domain/data/store/supplier"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"time"
+)
+
+// Set of error variables for purchase orders.
+var (
+	ErrUnknownSupplier   = errors.New("unknown supplier")
+	ErrUnknownWarehouse  = errors.New("unknown warehouse")
+	ErrUnknownProduct    = errors.New("unknown product")
+	ErrUnknownVariant    = errors.New("unknown variant of the product")
+	ErrNeedsVariant      = errors.New("product is stocked by variant")
+	ErrDuplicateLine     = errors.New("product is ordered on two lines")
+	ErrUnknownLine       = errors.New("purchase order has no such line")
+	ErrNothingToReceive  = errors.New("purchase order has nothing outstanding")
+	ErrInvalidTransition = errors.New("purchase order can not move to that status")
+	ErrInvalidStatus     = errors.New("status must be draft, ordered, partially_received, received, closed or cancelled")
+)
+
+// transitions is the state machine of purchase orders, the statuses each
+// status can move to. Received and partially received are only reached by
+// receiving goods, a partially received order stays so until the last of
+// them comes in.
+var transitions = map[string][]string{
+	purchase.StatusDraft:             {purchase.StatusOrdered, purchase.StatusCancelled},
+	purchase.StatusOrdered:           {purchase.StatusPartiallyReceived, purchase.StatusReceived, purchase.StatusCancelled},
+	purchase.StatusPartiallyReceived: {purchase.StatusPartiallyReceived, purchase.StatusReceived, purchase.StatusClosed},
+}
+
+// CanTransition reports whether a purchase order can move from one status
+// to the other.
+func CanTransition(from string, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Storer interface declares the behavior this package needs to persist
+// and retrieve purchase orders. UpdateStatus must fail with
+// purchase.ErrStatusChanged when the order left the status it is moved
+// from. Receive must fail with purchase.ErrNotReceivable or
+// purchase.ErrExceedsOrdered, moving nothing, when the order stopped
+// expecting the goods.
+type Storer interface {
+	Create(ctx context.Context, o purchase.Order) error
+	UpdateStatus(ctx context.Context, o purchase.Order, from string) error
+	Receive(ctx context.Context, r purchase.Receipt) error
+	QueryByID(ctx context.Context, orderID string) (purchase.Order, error)
+	Query(ctx context.Context, f purchase.Filter, pageNumber int, rowsPerPage int) ([]purchase.Order, error)
+}
+
+// SupplierStorer looks up the supplier stock is bought from.
+type SupplierStorer interface {
+	QueryByID(ctx context.Context, supplierID string) (supplier.Supplier, error)
+}
+
+// WarehouseStorer looks up the warehouse goods are delivered to.
+type WarehouseStorer interface {
+	QueryByID(ctx context.Context, warehouseID string) (warehouse.Warehouse, error)
+	QueryDefault(ctx context.Context) (warehouse.Warehouse, error)
+}
+
+// ProductStorer looks up the products being bought and their variants.
+type ProductStorer interface {
+	QueryByIDs(ctx context.Context, productIDs []string) ([]product.Product, error)
+	QueryVariantsByProducts(ctx context.Context, productIDs []string) ([]product.Variant, error)
+}
+
+type Core struct {
+	logger     *zap.SugaredLogger
+	store      Storer
+	suppliers  SupplierStorer
+	warehouses WarehouseStorer
+	products   ProductStorer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, suppliers SupplierStorer, warehouses WarehouseStorer, products ProductStorer) Core {
+	return Core{
+		logger:     log,
+		store:      store,
+		suppliers:  suppliers,
+		warehouses: warehouses,
+		products:   products,
+	}
+}
+
+// Create raises a draft purchase order on behalf of the user in claims,
+// priced in the currency of the supplier. Every product must be sold in
+// that currency so what it cost can be held against what it sells for. A
+// product with variants is bought by variant, each on a single line.
+func (c Core) Create(ctx context.Context, claims auth.Claims, no purchase.NewOrder, now time.Time) (purchase.Order, error) {
+	if err := validate.Check(no); err != nil {
+		return purchase.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	sp, err := c.suppliers.QueryByID(ctx, no.SupplierID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return purchase.Order{}, fmt.Errorf("Create: supplier %s: %w", no.SupplierID, ErrUnknownSupplier)
+		}
+		return purchase.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	var wh warehouse.Warehouse
+	switch {
+	case no.WarehouseID != nil:
+		wh, err = c.warehouses.QueryByID(ctx, *no.WarehouseID)
+	default:
+		wh, err = c.warehouses.QueryDefault(ctx)
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return purchase.Order{}, fmt.Errorf("Create: %w", ErrUnknownWarehouse)
+		}
+		return purchase.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	ids := make([]string, len(no.Lines))
+	for i, nl := range no.Lines {
+		ids[i] = nl.ProductID
+	}
+
+	prds, err := c.products.QueryByIDs(ctx, ids)
+	if err != nil {
+		return purchase.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	vars, err := c.products.QueryVariantsByProducts(ctx, ids)
+	if err != nil {
+		return purchase.Order{}, fmt.Errorf("Create: %w", err)
+	}
+
+	currencies := make(map[string]money.Currency, len(prds))
+	for _, p := range prds {
+		currencies[p.ID] = p.Currency
+	}
+
+	type key struct {
+		productID string
+		variantID string
+	}
+
+	hasVariants := make(map[string]bool)
+	known := make(map[key]bool, len(vars))
+	for _, v := range vars {
+		hasVariants[v.ProductID] = true
+		known[key{productID: v.ProductID, variantID: v.ID}] = true
+	}
+
+	o := purchase.Order{
+		ID:           validate.GenerateUID(),
+		SupplierID:   sp.ID,
+		WarehouseID:  wh.ID,
+		Status:       purchase.StatusDraft,
+		Currency:     sp.Currency,
+		ExpectedDate: no.ExpectedDate,
+		Note:         no.Note,
+		CreatedBy:    claims.Subject,
+		DateCreated:  now,
+		DateUpdated:  now,
+	}
+
+	total := money.Zero(sp.Currency)
+	seen := make(map[key]bool, len(no.Lines))
+	for i, nl := range no.Lines {
+		k := key{productID: nl.ProductID}
+		if nl.VariantID != nil {
+			k.variantID = *nl.VariantID
+		}
+
+		currency, ok := currencies[k.productID]
+		if !ok {
+			return purchase.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, ErrUnknownProduct)
+		}
+
+		if k.variantID == "" && hasVariants[k.productID] {
+			return purchase.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, ErrNeedsVariant)
+		}
+
+		if k.variantID != "" && !known[k] {
+			return purchase.Order{}, fmt.Errorf("Create: product %s variant %s: %w", k.productID, k.variantID, ErrUnknownVariant)
+		}
+
+		if seen[k] {
+			return purchase.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, ErrDuplicateLine)
+		}
+		seen[k] = true
+
+		if currency != sp.Currency {
+			return purchase.Order{}, fmt.Errorf("Create: product %s is sold in %s: %w", k.productID, currency, money.ErrMismatch)
+		}
+
+		line, err := money.New(int64(nl.UnitCost), sp.Currency).Mul(int64(nl.Quantity))
+		if err != nil {
+			return purchase.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, err)
+		}
+
+		if total, err = total.Add(line); err != nil {
+			return purchase.Order{}, fmt.Errorf("Create: product %s: %w", k.productID, err)
+		}
+
+		o.Lines = append(o.Lines, purchase.Line{
+			OrderID:      o.ID,
+			Line:         i + 1,
+			ProductID:    nl.ProductID,
+			VariantID:    nl.VariantID,
+			Quantity:     nl.Quantity,
+			UnitCost:     nl.UnitCost,
+			Total:        int(line.Amount()),
+			ExpectedDate: nl.ExpectedDate,
+		})
+	}
+	o.Total = int(total.Amount())
+
+	if err := c.store.Create(ctx, o); err != nil {
+		return purchase.Order{}, fmt.Errorf("Create: %w", err)
+	}
+	return o, nil
+}
+
+// Submit sends a draft purchase order to the supplier.
+func (c Core) Submit(ctx context.Context, orderID string, now time.Time) (purchase.Order, error) {
+	o, err := c.transition(ctx, orderID, purchase.StatusOrdered, now)
+	if err != nil {
+		return purchase.Order{}, fmt.Errorf("Submit: %w", err)
+	}
+	return o, nil
+}
+
+// Cancel calls off a purchase order no goods came in for yet.
+func (c Core) Cancel(ctx context.Context, orderID string, now time.Time) (purchase.Order, error) {
+	o, err := c.transition(ctx, orderID, purchase.StatusCancelled, now)
+	if err != nil {
+		return purchase.Order{}, fmt.Errorf("Cancel: %w", err)
+	}
+	return o, nil
+}
+
+// Close stops waiting for the rest of a partially received purchase
+// order.
+func (c Core) Close(ctx context.Context, orderID string, now time.Time) (purchase.Order, error) {
+	o, err := c.transition(ctx, orderID, purchase.StatusClosed, now)
+	if err != nil {
+		return purchase.Order{}, fmt.Errorf("Close: %w", err)
+	}
+	return o, nil
+}
+
+// transition moves the purchase order to the status when the state
+// machine allows it.
+func (c Core) transition(ctx context.Context, orderID string, status string, now time.Time) (purchase.Order, error) {
+	if err := validate.CheckID(orderID); err != nil {
+		return purchase.Order{}, database.ErrInvalidID
+	}
+
+	o, err := c.store.QueryByID(ctx, orderID)
+	if err != nil {
+		return purchase.Order{}, err
+	}
+
+	if !CanTransition(o.Status, status) {
+		return purchase.Order{}, fmt.Errorf("purchase order %s is %s: %w", o.ID, o.Status, ErrInvalidTransition)
+	}
+
+	from := o.Status
+	o.Status = status
+	o.DateUpdated = now
+
+	if err := c.store.UpdateStatus(ctx, o, from); err != nil {
+		return purchase.Order{}, err
+	}
+	return o, nil
+}
+
+// Receive takes goods of the purchase order into its warehouse on behalf
+// of the user in claims, every line given at most what is outstanding on
+// it, everything outstanding when no line is given. The units received
+// are averaged into the purchase cost of their products. It returns the
+// order as received.
+func (c Core) Receive(ctx context.Context, claims auth.Claims, orderID string, nr purchase.NewReceipt, now time.Time) (purchase.Order, error) {
+	if err := validate.CheckID(orderID); err != nil {
+		return purchase.Order{}, fmt.Errorf("Receive: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(nr); err != nil {
+		return purchase.Order{}, fmt.Errorf("Receive: %w", err)
+	}
+
+	o, err := c.store.QueryByID(ctx, orderID)
+	if err != nil {
+		return purchase.Order{}, fmt.Errorf("Receive: %w", err)
+	}
+
+	lines := make(map[int]purchase.Line, len(o.Lines))
+	for _, l := range o.Lines {
+		lines[l.Line] = l
+	}
+
+	received := nr.Lines
+	if len(received) == 0 {
+		for _, l := range o.Lines {
+			if l.Outstanding() > 0 {
+				received = append(received, purchase.NewReceived{Line: l.Line, Quantity: l.Outstanding()})
+			}
+		}
+	}
+
+	if len(received) == 0 {
+		return purchase.Order{}, fmt.Errorf("Receive: purchase order %s: %w", o.ID, ErrNothingToReceive)
+	}
+
+	r := purchase.Receipt{
+		OrderID:      o.ID,
+		WarehouseID:  o.WarehouseID,
+		ReceivedBy:   claims.Subject,
+		DateReceived: now,
+	}
+
+	outstanding := 0
+	for _, l := range o.Lines {
+		outstanding += l.Outstanding()
+	}
+
+	seen := make(map[int]bool, len(received))
+	for _, nrl := range received {
+		l, ok := lines[nrl.Line]
+		if !ok {
+			return purchase.Order{}, fmt.Errorf("Receive: line %d: %w", nrl.Line, ErrUnknownLine)
+		}
+
+		if seen[nrl.Line] {
+			return purchase.Order{}, fmt.Errorf("Receive: line %d: %w", nrl.Line, ErrDuplicateLine)
+		}
+		seen[nrl.Line] = true
+
+		// The store checks again in the same step it updates the line,
+		// this only gives a clear answer in the common case.
+		if nrl.Quantity > l.Outstanding() {
+			return purchase.Order{}, fmt.Errorf("Receive: line %d: %w", nrl.Line, purchase.ErrExceedsOrdered)
+		}
+		outstanding -= nrl.Quantity
+
+		r.Lines = append(r.Lines, purchase.Received{
+			Line:      l.Line,
+			ProductID: l.ProductID,
+			VariantID: l.VariantID,
+			Quantity:  nrl.Quantity,
+			UnitCost:  l.UnitCost,
+		})
+	}
+
+	status := purchase.StatusPartiallyReceived
+	if outstanding == 0 {
+		status = purchase.StatusReceived
+	}
+
+	if !CanTransition(o.Status, status) {
+		return purchase.Order{}, fmt.Errorf("Receive: purchase order %s is %s: %w", o.ID, o.Status, ErrInvalidTransition)
+	}
+
+	if err := c.store.Receive(ctx, r); err != nil {
+		return purchase.Order{}, fmt.Errorf("Receive: %w", err)
+	}
+
+	o, err = c.store.QueryByID(ctx, orderID)
+	if err != nil {
+		return purchase.Order{}, fmt.Errorf("Receive: %w", err)
+	}
+	return o, nil
+}
+
+// QueryByID returns the purchase order with the id.
+func (c Core) QueryByID(ctx context.Context, orderID string) (purchase.Order, error) {
+	if err := validate.CheckID(orderID); err != nil {
+		return purchase.Order{}, fmt.Errorf("QueryByID: %w", database.ErrInvalidID)
+	}
+
+	o, err := c.store.QueryByID(ctx, orderID)
+	if err != nil {
+		return purchase.Order{}, fmt.Errorf("QueryByID: %w", err)
+	}
+	return o, nil
+}
+
+// Query returns a page of the purchase orders, latest first, narrowed
+// down to the status and the supplier of the filter when they are set.
+func (c Core) Query(ctx context.Context, f purchase.Filter, pageNumber int, rowsPerPage int) ([]purchase.Order, error) {
+	switch f.Status {
+	case "", purchase.StatusDraft, purchase.StatusOrdered, purchase.StatusPartiallyReceived,
+		purchase.StatusReceived, purchase.StatusClosed, purchase.StatusCancelled:
+	default:
+		return nil, fmt.Errorf("Query: %w", ErrInvalidStatus)
+	}
+
+	if f.SupplierID != "" {
+		if err := validate.CheckID(f.SupplierID); err != nil {
+			return nil, fmt.Errorf("Query: %w", database.ErrInvalidID)
+		}
+	}
+
+	orders, err := c.store.Query(ctx, f, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	return orders, nil
+}
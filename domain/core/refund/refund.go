@@ -0,0 +1,200 @@
+// Package refund provides the core business API for refunding orders,
+// fully or in part, line by line.
+package refund
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/audit"
+	"service/domain/data/store/order"
+	"service/domain/data/store/refund"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"time"
+)
+
+// Set of error variables for refunds.
+var (
+	ErrUnknownLine     = errors.New("order has no such line")
+	ErrDuplicateLine   = errors.New("line is refunded twice")
+	ErrNothingToRefund = errors.New("refund must return goods or money")
+	ErrRestock         = errors.New("only returned goods can be restocked")
+)
+
+// ActionRefunded is the audit action recorded for every refund.
+const ActionRefunded = "order.refunded"
+
+// Storer interface declares the behavior this package needs to persist
+// and retrieve refunds. Create must fail with refund.ErrExceedsPaid,
+// changing nothing, when a line would be refunded more than was paid.
+type Storer interface {
+	Create(ctx context.Context, r refund.Refund) error
+	QueryByOrder(ctx context.Context, orderID string) ([]refund.Refund, error)
+}
+
+// OrderStorer looks up the order being refunded.
+type OrderStorer interface {
+	QueryByID(ctx context.Context, orderID string) (order.Order, error)
+}
+
+// Auditor interface declares the behavior this package needs to leave an
+// audit trail.
+type Auditor interface {
+	Create(ctx context.Context, e audit.Event) error
+}
+
+type Core struct {
+	logger *zap.SugaredLogger
+	store  Storer
+	orders OrderStorer
+	audit  Auditor
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, orders OrderStorer, auditor Auditor) Core {
+	return Core{
+		logger: log,
+		store:  store,
+		orders: orders,
+		audit:  auditor,
+	}
+}
+
+// Create refunds lines of the order on behalf of the user in claims, who
+// is recorded as having approved it.
+func (c Core) Create(ctx context.Context, claims auth.Claims, orderID string, nr refund.NewRefund, now time.Time) (refund.Refund, error) {
+	if err := validate.CheckID(orderID); err != nil {
+		return refund.Refund{}, fmt.Errorf("Create: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(nr); err != nil {
+		return refund.Refund{}, fmt.Errorf("Create: %w", err)
+	}
+
+	o, err := c.orders.QueryByID(ctx, orderID)
+	if err != nil {
+		return refund.Refund{}, fmt.Errorf("Create: %w", err)
+	}
+
+	lines := make(map[int]order.Item, len(o.Items))
+	for _, item := range o.Items {
+		lines[item.Line] = item
+	}
+
+	r := refund.Refund{
+		ID:          validate.GenerateUID(),
+		OrderID:     o.ID,
+		Reason:      nr.Reason,
+		Note:        nr.Note,
+		Currency:    o.Currency,
+		ApprovedBy:  claims.Subject,
+		DateCreated: now,
+	}
+
+	seen := make(map[int]bool)
+	for _, ni := range nr.Items {
+		item, ok := lines[ni.Line]
+		if !ok {
+			return refund.Refund{}, fmt.Errorf("Create: line %d: %w", ni.Line, ErrUnknownLine)
+		}
+
+		if seen[ni.Line] {
+			return refund.Refund{}, fmt.Errorf("Create: line %d: %w", ni.Line, ErrDuplicateLine)
+		}
+		seen[ni.Line] = true
+
+		if ni.Restock && ni.Quantity == 0 {
+			return refund.Refund{}, fmt.Errorf("Create: line %d: %w", ni.Line, ErrRestock)
+		}
+
+		amount := paid(item, ni.Quantity)
+		if ni.Amount != nil {
+			amount = *ni.Amount
+		}
+
+		// Goods of a line given away for free are returned for nothing.
+		if ni.Quantity == 0 && amount == 0 {
+			return refund.Refund{}, fmt.Errorf("Create: line %d: %w", ni.Line, ErrNothingToRefund)
+		}
+
+		// The store checks again in the same step it updates the line,
+		// this only gives a clear answer in the common case.
+		if item.RefundedQuantity+ni.Quantity > item.Quantity || item.RefundedAmount+amount > item.Total {
+			return refund.Refund{}, fmt.Errorf("Create: line %d: %w", ni.Line, refund.ErrExceedsPaid)
+		}
+
+		r.Items = append(r.Items, refund.Item{
+			RefundID:  r.ID,
+			Line:      ni.Line,
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  ni.Quantity,
+			Amount:    amount,
+			Restocked: ni.Restock,
+		})
+		r.Amount += amount
+	}
+
+	if err := c.store.Create(ctx, r); err != nil {
+		return refund.Refund{}, fmt.Errorf("Create: %w", err)
+	}
+
+	c.record(ctx, r)
+	return r, nil
+}
+
+// QueryByOrder returns the refunds of the order. Customers see the refunds
+// of their own orders, admins and whoever can refund see all of them.
+func (c Core) QueryByOrder(ctx context.Context, claims auth.Claims, orderID string) ([]refund.Refund, error) {
+	if err := validate.CheckID(orderID); err != nil {
+		return nil, fmt.Errorf("QueryByOrder: %w", database.ErrInvalidID)
+	}
+
+	o, err := c.orders.QueryByID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("QueryByOrder: %w", err)
+	}
+
+	if !claims.Authorized(auth.RoleAdmin) && !claims.Permitted(auth.PermSalesRefund) && claims.Subject != o.CustomerID {
+		return nil, fmt.Errorf("QueryByOrder: %w", database.ErrForbidden)
+	}
+
+	refunds, err := c.store.QueryByOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("QueryByOrder: %w", err)
+	}
+	return refunds, nil
+}
+
+// record leaves the refund in the audit trail. The refund is already
+// given, a failure to record it is logged rather than returned.
+func (c Core) record(ctx context.Context, r refund.Refund) {
+	detail := fmt.Sprintf("refund %s of %s for %s", r.ID, money.New(int64(r.Amount), r.Currency), r.Reason)
+
+	e := audit.Event{
+		ID:          validate.GenerateUID(),
+		Action:      ActionRefunded,
+		Actor:       r.ApprovedBy,
+		Subject:     r.OrderID,
+		Detail:      detail,
+		DateCreated: r.DateCreated,
+	}
+
+	c.logger.Infow("audit", "action", e.Action, "actor", e.Actor, "subject", e.Subject, "detail", detail)
+	if err := c.audit.Create(ctx, e); err != nil {
+		c.logger.Errorw("audit", "status", "recording event", "action", e.Action, "subject", e.Subject, "ERROR", err)
+	}
+}
+
+// paid returns what the customer paid for n more units of the item, the
+// total of the line spread evenly over its units. Refunding every unit
+// gives back the total to the minor unit.
+func paid(item order.Item, n int) int {
+	upTo := func(units int) int {
+		return item.Total * units / item.Quantity
+	}
+	return upTo(item.RefundedQuantity+n) - upTo(item.RefundedQuantity)
+}
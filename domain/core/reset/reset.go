@@ -0,0 +1,195 @@
+// Package reset provides the core business API for users resetting a
+// forgotten password with a token mailed to them.
+package reset
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/url"
+	userCore "service/domain/core/user"
+	"service/domain/data/store/reset"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/notification"
+	"time"
+)
+
+// ErrInvalidToken is returned for unknown, used and expired tokens alike.
+var ErrInvalidToken = errors.New("reset token is invalid or expired")
+
+// Storer interface declares the behavior this package needs to persist and
+// consume reset tokens.
+type Storer interface {
+	Create(ctx context.Context, tkn reset.Token) error
+	Consume(ctx context.Context, hash string, now time.Time) (string, error)
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// Config holds how tokens are issued.
+type Config struct {
+	// TTL is how long a token can be used after it was mailed.
+	TTL time.Duration
+
+	// URL is the page users open to pick a new password, the token is
+	// added as the token query parameter.
+	URL string
+}
+
+// ForgotPassword asks for a reset token to be mailed to the email.
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPassword sets a new password with a mailed token.
+type ResetPassword struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+type Core struct {
+	logger *zap.SugaredLogger
+	resets Storer
+	users  userCore.Storer
+	mailer notification.Mailer
+	cfg    Config
+}
+
+func NewCore(log *zap.SugaredLogger, resets Storer, users userCore.Storer, mailer notification.Mailer, cfg Config) Core {
+	return Core{
+		logger: log,
+		resets: resets,
+		users:  users,
+		mailer: mailer,
+		cfg:    cfg,
+	}
+}
+
+// systemClaims lets the core act on users nobody is logged in as.
+var systemClaims = auth.Claims{
+	Roles: []string{auth.RoleAdmin},
+}
+
+// Forgot mails a reset token when the email belongs to an enabled user.
+// Callers get the same answer whether the email is known or not so the
+// endpoint can not be used to find out who has an account. For the same
+// reason unknown emails go through the same pruning and token generation,
+// failures to deliver the email are only logged, and the mailer is
+// expected to send in the background rather than keep the caller waiting
+// on the mail server.
+func (c Core) Forgot(ctx context.Context, fp ForgotPassword, now time.Time) error {
+	if err := validate.Check(fp); err != nil {
+		return fmt.Errorf("Forgot: %w", err)
+	}
+
+	// Tokens are only ever looked up by hash, pruning the dead ones here
+	// keeps the table small without a background job.
+	if err := c.resets.DeleteExpired(ctx, now); err != nil {
+		c.logger.Errorw("reset", "status", "deleting expired tokens", "ERROR", err)
+	}
+
+	token, hash, err := newToken()
+	if err != nil {
+		return fmt.Errorf("Forgot: %w", err)
+	}
+
+	usr, err := c.users.QueryByEmail(ctx, systemClaims, fp.Email)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("Forgot: %w", err)
+	}
+
+	if !usr.Enabled {
+		return nil
+	}
+
+	tkn := reset.Token{
+		Hash:        hash,
+		UserID:      usr.ID,
+		DateExpires: now.Add(c.cfg.TTL),
+		DateCreated: now,
+	}
+	if err := c.resets.Create(ctx, tkn); err != nil {
+		return fmt.Errorf("Forgot: %w", err)
+	}
+
+	if err := c.mailer.Send(ctx, c.message(usr, token)); err != nil {
+		c.logger.Errorw("reset", "status", "sending reset email", "userID", usr.ID, "ERROR", err)
+	}
+	return nil
+}
+
+// Reset consumes the token and sets the new password on its user.
+func (c Core) Reset(ctx context.Context, rp ResetPassword, now time.Time) error {
+	if err := validate.Check(rp); err != nil {
+		return fmt.Errorf("Reset: %w", err)
+	}
+
+	userID, err := c.resets.Consume(ctx, hashToken(rp.Token), now)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("Reset: %w", ErrInvalidToken)
+		}
+		return fmt.Errorf("Reset: %w", err)
+	}
+
+	uu := user.UpdateUser{
+		Password: &rp.Password,
+	}
+	if err := c.users.Update(ctx, systemClaims, userID, uu, now); err != nil {
+		return fmt.Errorf("Reset: %w", err)
+	}
+	return nil
+}
+
+func (c Core) message(usr user.User, token string) notification.Message {
+	link := c.cfg.URL + "?token=" + token
+	if u, err := url.Parse(c.cfg.URL); err == nil {
+		q := u.Query()
+		q.Set("token", token)
+		u.RawQuery = q.Encode()
+		link = u.String()
+	}
+
+	body := fmt.Sprintf(`Hello %s,
+
+Someone asked to reset the password of your account. Open the link below
+to pick a new one, it expires in %s.
+
+%s
+
+If it was not you, you can ignore this email.
+`, usr.Name, c.cfg.TTL, link)
+
+	return notification.Message{
+		To:      usr.Email,
+		Subject: "Reset your password",
+		Body:    body,
+	}
+}
+
+// newToken returns a random token and the hash stored for it.
+func newToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generating token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+// Package role provides the core business API for managing roles and the
+// permissions they grant.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/role"
+	"service/domain/sys/auth"
+	"service/domain/sys/validate"
+	"sort"
+	"time"
+)
+
+// Set of error variables for managing roles.
+var (
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrBuiltIn           = errors.New("built in roles can not be deleted")
+)
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve roles.
+type Storer interface {
+	Create(ctx context.Context, r role.Role) error
+	Update(ctx context.Context, r role.Role) error
+	Delete(ctx context.Context, name string) error
+	Query(ctx context.Context) ([]role.Role, error)
+	QueryByName(ctx context.Context, name string) (role.Role, error)
+	QueryPermissions(ctx context.Context) ([]role.Permission, error)
+	PermissionsFor(ctx context.Context, roles []string) ([]string, error)
+}
+
+type Core struct {
+	logger *zap.SugaredLogger
+	store  Storer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer) Core {
+	return Core{
+		logger: log,
+		store:  store,
+	}
+}
+
+// Create adds a role granting the permissions.
+func (c Core) Create(ctx context.Context, nr role.NewRole, now time.Time) (role.Role, error) {
+	if err := validate.Check(nr); err != nil {
+		return role.Role{}, err
+	}
+
+	perms, err := c.checkPermissions(ctx, nr.Permissions)
+	if err != nil {
+		return role.Role{}, fmt.Errorf("Create: %w", err)
+	}
+
+	r := role.Role{
+		Name:        nr.Name,
+		Description: nr.Description,
+		Permissions: perms,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+	if err := c.store.Create(ctx, r); err != nil {
+		return role.Role{}, fmt.Errorf("Create: %w", err)
+	}
+	return r, nil
+}
+
+// Update changes the description of the role or replaces its permissions.
+// Tokens issued before keep the permissions they carry until they expire.
+func (c Core) Update(ctx context.Context, name string, ur role.UpdateRole, now time.Time) (role.Role, error) {
+	if err := validate.Check(ur); err != nil {
+		return role.Role{}, err
+	}
+
+	r, err := c.store.QueryByName(ctx, name)
+	if err != nil {
+		return role.Role{}, fmt.Errorf("Update: %w", err)
+	}
+
+	if ur.Description != nil {
+		r.Description = *ur.Description
+	}
+
+	if ur.Permissions != nil {
+		perms, err := c.checkPermissions(ctx, ur.Permissions)
+		if err != nil {
+			return role.Role{}, fmt.Errorf("Update: %w", err)
+		}
+		r.Permissions = perms
+	}
+	r.DateUpdated = now
+
+	if err := c.store.Update(ctx, r); err != nil {
+		return role.Role{}, fmt.Errorf("Update: %w", err)
+	}
+	return r, nil
+}
+
+// Delete removes the role. Users holding it keep the name in their roles
+// but it grants nothing any more.
+func (c Core) Delete(ctx context.Context, name string) error {
+	if name == auth.RoleAdmin || name == auth.RoleUser {
+		return fmt.Errorf("Delete: %w", ErrBuiltIn)
+	}
+
+	if _, err := c.store.QueryByName(ctx, name); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+
+	if err := c.store.Delete(ctx, name); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+	return nil
+}
+
+// Query returns every role.
+func (c Core) Query(ctx context.Context) ([]role.Role, error) {
+	roles, err := c.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	return roles, nil
+}
+
+// QueryByName returns the role with the name.
+func (c Core) QueryByName(ctx context.Context, name string) (role.Role, error) {
+	r, err := c.store.QueryByName(ctx, name)
+	if err != nil {
+		return role.Role{}, fmt.Errorf("QueryByName: %w", err)
+	}
+	return r, nil
+}
+
+// QueryPermissions returns every permission a role can grant.
+func (c Core) QueryPermissions(ctx context.Context) ([]role.Permission, error) {
+	perms, err := c.store.QueryPermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("QueryPermissions: %w", err)
+	}
+	return perms, nil
+}
+
+// Permissions resolves the permissions granted by the roles, they are put
+// in the claims when a token is issued.
+func (c Core) Permissions(ctx context.Context, roles []string) ([]string, error) {
+	perms, err := c.store.PermissionsFor(ctx, roles)
+	if err != nil {
+		return nil, fmt.Errorf("Permissions: %w", err)
+	}
+	return perms, nil
+}
+
+// checkPermissions makes sure every permission is known and returns them
+// sorted without duplicates.
+func (c Core) checkPermissions(ctx context.Context, perms []string) ([]string, error) {
+	known, err := c.store.QueryPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := make(map[string]bool, len(known))
+	for _, p := range known {
+		valid[p.Name] = true
+	}
+
+	set := make(map[string]bool, len(perms))
+	out := []string{}
+	for _, p := range perms {
+		if !valid[p] {
+			return nil, fmt.Errorf("%w %q", ErrUnknownPermission, p)
+		}
+		if !set[p] {
+			set[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
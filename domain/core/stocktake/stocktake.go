@@ -0,0 +1,282 @@
+// Package stocktake provides the core business API for counting the stock
+// of a warehouse. Counts are taken while a stocktake is open, posting it
+// compares them with the inventory ledger and records the variances.
+package stocktake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/data/store/stocktake"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"time"
+)
+
+// Set of error variables for stocktakes.
+var (
+	ErrUnknownWarehouse = errors.New("unknown warehouse")
+	ErrUnknownProduct   = errors.New("unknown product")
+	ErrUnknownVariant   = errors.New("unknown variant of the product")
+	ErrNeedsVariant     = errors.New("product is stocked by variant")
+	ErrInvalidStatus    = errors.New("status must be open, posted or cancelled")
+)
+
+// Storer interface declares the behavior this package needs to persist
+// and retrieve stocktakes. Count, Post and Cancel must fail with
+// stocktake.ErrNotOpen, changing nothing, when the stocktake is no longer
+// open. Post must record every variance in the ledger or none.
+type Storer interface {
+	Create(ctx context.Context, st stocktake.Stocktake) error
+	Count(ctx context.Context, st stocktake.Stocktake) error
+	Post(ctx context.Context, st stocktake.Stocktake) error
+	Cancel(ctx context.Context, st stocktake.Stocktake) error
+	QueryByID(ctx context.Context, stocktakeID string) (stocktake.Stocktake, error)
+	Query(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]stocktake.Stocktake, error)
+}
+
+// WarehouseStorer looks up the warehouses being counted.
+type WarehouseStorer interface {
+	QueryByID(ctx context.Context, warehouseID string) (warehouse.Warehouse, error)
+}
+
+// ProductStorer looks up the products being counted and their variants.
+type ProductStorer interface {
+	QueryByIDs(ctx context.Context, productIDs []string) ([]product.Product, error)
+	QueryVariantsByProducts(ctx context.Context, productIDs []string) ([]product.Variant, error)
+}
+
+type Core struct {
+	logger     *zap.SugaredLogger
+	store      Storer
+	warehouses WarehouseStorer
+	products   ProductStorer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, warehouses WarehouseStorer, products ProductStorer) Core {
+	return Core{
+		logger:     log,
+		store:      store,
+		warehouses: warehouses,
+		products:   products,
+	}
+}
+
+// Create opens a stocktake of the warehouse on behalf of the user in
+// claims.
+func (c Core) Create(ctx context.Context, claims auth.Claims, ns stocktake.NewStocktake, now time.Time) (stocktake.Stocktake, error) {
+	if err := validate.Check(ns); err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Create: %w", err)
+	}
+
+	if _, err := c.warehouses.QueryByID(ctx, ns.WarehouseID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return stocktake.Stocktake{}, fmt.Errorf("Create: warehouse %s: %w", ns.WarehouseID, ErrUnknownWarehouse)
+		}
+		return stocktake.Stocktake{}, fmt.Errorf("Create: %w", err)
+	}
+
+	st := stocktake.Stocktake{
+		ID:          validate.GenerateUID(),
+		WarehouseID: ns.WarehouseID,
+		Status:      stocktake.StatusOpen,
+		Note:        ns.Note,
+		CreatedBy:   claims.Subject,
+		Lines:       []stocktake.Line{},
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.Create(ctx, st); err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Create: %w", err)
+	}
+	return st, nil
+}
+
+// Count records the counts in the open stocktake and returns it with
+// every line counted so far. A product with variants is counted by
+// variant, the last count of the same product and variant wins.
+func (c Core) Count(ctx context.Context, stocktakeID string, nc stocktake.NewCounts, now time.Time) (stocktake.Stocktake, error) {
+	if err := validate.CheckID(stocktakeID); err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Count: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(nc); err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Count: %w", err)
+	}
+
+	st, err := c.store.QueryByID(ctx, stocktakeID)
+	if err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Count: %w", err)
+	}
+
+	if st.Status != stocktake.StatusOpen {
+		return stocktake.Stocktake{}, fmt.Errorf("Count: stocktake %s is %s: %w", st.ID, st.Status, stocktake.ErrNotOpen)
+	}
+
+	lines, err := c.lines(ctx, nc.Counts)
+	if err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Count: %w", err)
+	}
+
+	st.Lines = lines
+	st.DateUpdated = now
+
+	if err := c.store.Count(ctx, st); err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Count: %w", err)
+	}
+
+	st, err = c.store.QueryByID(ctx, stocktakeID)
+	if err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Count: %w", err)
+	}
+	return st, nil
+}
+
+// Post posts the variances of the stocktake to the ledger and returns it
+// with what was expected and posted for every line.
+func (c Core) Post(ctx context.Context, stocktakeID string, now time.Time) (stocktake.Stocktake, error) {
+	st, err := c.close(ctx, stocktakeID, now, c.store.Post)
+	if err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Post: %w", err)
+	}
+	return st, nil
+}
+
+// Cancel closes the stocktake without posting anything.
+func (c Core) Cancel(ctx context.Context, stocktakeID string, now time.Time) (stocktake.Stocktake, error) {
+	st, err := c.close(ctx, stocktakeID, now, c.store.Cancel)
+	if err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("Cancel: %w", err)
+	}
+	return st, nil
+}
+
+// close closes the open stocktake through the store method and reads it
+// back.
+func (c Core) close(ctx context.Context, stocktakeID string, now time.Time, store func(context.Context, stocktake.Stocktake) error) (stocktake.Stocktake, error) {
+	if err := validate.CheckID(stocktakeID); err != nil {
+		return stocktake.Stocktake{}, database.ErrInvalidID
+	}
+
+	st, err := c.store.QueryByID(ctx, stocktakeID)
+	if err != nil {
+		return stocktake.Stocktake{}, err
+	}
+
+	if st.Status != stocktake.StatusOpen {
+		return stocktake.Stocktake{}, fmt.Errorf("stocktake %s is %s: %w", st.ID, st.Status, stocktake.ErrNotOpen)
+	}
+	st.DateUpdated = now
+
+	if err := store(ctx, st); err != nil {
+		return stocktake.Stocktake{}, err
+	}
+
+	return c.store.QueryByID(ctx, stocktakeID)
+}
+
+// QueryByID returns the stocktake with the id.
+func (c Core) QueryByID(ctx context.Context, stocktakeID string) (stocktake.Stocktake, error) {
+	if err := validate.CheckID(stocktakeID); err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("QueryByID: %w", database.ErrInvalidID)
+	}
+
+	st, err := c.store.QueryByID(ctx, stocktakeID)
+	if err != nil {
+		return stocktake.Stocktake{}, fmt.Errorf("QueryByID: %w", err)
+	}
+	return st, nil
+}
+
+// Query returns a page of the stocktakes, latest first, only those in the
+// status when it is set.
+func (c Core) Query(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]stocktake.Stocktake, error) {
+	switch status {
+	case "", stocktake.StatusOpen, stocktake.StatusPosted, stocktake.StatusCancelled:
+	default:
+		return nil, fmt.Errorf("Query: %w", ErrInvalidStatus)
+	}
+
+	stocktakes, err := c.store.Query(ctx, status, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	return stocktakes, nil
+}
+
+// lines checks the counted products and variants exist and turns the
+// counts into lines, one per product and variant.
+func (c Core) lines(ctx context.Context, counts []stocktake.NewCount) ([]stocktake.Line, error) {
+	type key struct {
+		productID string
+		variantID string
+	}
+
+	var keys []key
+	var ids []string
+	counted := make(map[key]int)
+	for _, nc := range counts {
+		k := key{productID: nc.ProductID}
+		if nc.VariantID != nil {
+			k.variantID = *nc.VariantID
+		}
+
+		if _, ok := counted[k]; !ok {
+			keys = append(keys, k)
+			ids = append(ids, k.productID)
+		}
+		counted[k] = nc.Counted
+	}
+
+	prds, err := c.products.QueryByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := c.products.QueryVariantsByProducts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[key]bool, len(prds)+len(vars))
+	for _, p := range prds {
+		known[key{productID: p.ID}] = true
+	}
+
+	hasVariants := make(map[string]bool)
+	for _, v := range vars {
+		hasVariants[v.ProductID] = true
+		known[key{productID: v.ProductID, variantID: v.ID}] = true
+	}
+
+	lines := make([]stocktake.Line, 0, len(keys))
+	for _, k := range keys {
+		if !known[key{productID: k.productID}] {
+			return nil, fmt.Errorf("product %s: %w", k.productID, ErrUnknownProduct)
+		}
+
+		if k.variantID == "" && hasVariants[k.productID] {
+			return nil, fmt.Errorf("product %s: %w", k.productID, ErrNeedsVariant)
+		}
+
+		if !known[k] {
+			return nil, fmt.Errorf("product %s variant %s: %w", k.productID, k.variantID, ErrUnknownVariant)
+		}
+
+		line := stocktake.Line{
+			ProductID: k.productID,
+			Counted:   counted[k],
+		}
+		if k.variantID != "" {
+			variantID := k.variantID
+			line.VariantID = &variantID
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
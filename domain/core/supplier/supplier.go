@@ -0,0 +1,111 @@
+// Package supplier provides the core business API for the suppliers stock
+// is bought from.
+package supplier
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/supplier"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"time"
+)
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve suppliers. Create and Update must fail with
+// supplier.ErrUniqueName when another supplier holds the name.
+type Storer interface {
+	Create(ctx context.Context, sp supplier.Supplier) error
+	Update(ctx context.Context, sp supplier.Supplier) error
+	Query(ctx context.Context) ([]supplier.Supplier, error)
+	QueryByID(ctx context.Context, supplierID string) (supplier.Supplier, error)
+}
+
+type Core struct {
+	logger *zap.SugaredLogger
+	store  Storer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer) Core {
+	return Core{
+		logger: log,
+		store:  store,
+	}
+}
+
+// Create adds a supplier.
+func (c Core) Create(ctx context.Context, ns supplier.NewSupplier, now time.Time) (supplier.Supplier, error) {
+	if err := validate.Check(ns); err != nil {
+		return supplier.Supplier{}, fmt.Errorf("Create: %w", err)
+	}
+
+	sp := supplier.Supplier{
+		ID:          validate.GenerateUID(),
+		Name:        ns.Name,
+		Email:       ns.Email,
+		Phone:       ns.Phone,
+		Currency:    ns.Currency,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.Create(ctx, sp); err != nil {
+		return supplier.Supplier{}, fmt.Errorf("Create: %w", err)
+	}
+	return sp, nil
+}
+
+// Update changes the fields of the supplier that are set.
+func (c Core) Update(ctx context.Context, supplierID string, us supplier.UpdateSupplier, now time.Time) (supplier.Supplier, error) {
+	if err := validate.CheckID(supplierID); err != nil {
+		return supplier.Supplier{}, fmt.Errorf("Update: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(us); err != nil {
+		return supplier.Supplier{}, fmt.Errorf("Update: %w", err)
+	}
+
+	sp, err := c.store.QueryByID(ctx, supplierID)
+	if err != nil {
+		return supplier.Supplier{}, fmt.Errorf("Update: %w", err)
+	}
+
+	if us.Name != nil {
+		sp.Name = *us.Name
+	}
+	if us.Email != nil {
+		sp.Email = *us.Email
+	}
+	if us.Phone != nil {
+		sp.Phone = *us.Phone
+	}
+	sp.DateUpdated = now
+
+	if err := c.store.Update(ctx, sp); err != nil {
+		return supplier.Supplier{}, fmt.Errorf("Update: %w", err)
+	}
+	return sp, nil
+}
+
+// Query returns every supplier ordered by name.
+func (c Core) Query(ctx context.Context) ([]supplier.Supplier, error) {
+	sps, err := c.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	return sps, nil
+}
+
+// QueryByID returns the supplier with the id.
+func (c Core) QueryByID(ctx context.Context, supplierID string) (supplier.Supplier, error) {
+	if err := validate.CheckID(supplierID); err != nil {
+		return supplier.Supplier{}, fmt.Errorf("QueryByID: %w", database.ErrInvalidID)
+	}
+
+	sp, err := c.store.QueryByID(ctx, supplierID)
+	if err != nil {
+		return supplier.Supplier{}, fmt.Errorf("QueryByID: %w", err)
+	}
+	return sp, nil
+}
@@ -0,0 +1,240 @@
+// Package transfer provides the core business API for moving stock
+// between warehouses. Stock leaves the source when a transfer is shipped
+// and is in transit, held by neither warehouse, until it is received.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/data/store/transfer"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"time"
+)
+
+// Set of error variables for transfers.
+var (
+	ErrUnknownWarehouse = errors.New("unknown warehouse")
+	ErrUnknownProduct   = errors.New("unknown product")
+	ErrUnknownVariant   = errors.New("unknown variant of the product")
+	ErrNeedsVariant     = errors.New("product is stocked by variant")
+	ErrInvalidStatus    = errors.New("status must be in_transit, received or cancelled")
+)
+
+// Storer interface declares the behavior this package needs to persist
+// and retrieve transfers. Create must take the stock of every item out of
+// the source warehouse or fail with product.ErrInsufficientStock without
+// taking any. Receive and Cancel must fail with transfer.ErrNotInTransit,
+// moving nothing, when the transfer is no longer in transit.
+type Storer interface {
+	Create(ctx context.Context, t transfer.Transfer) error
+	Receive(ctx context.Context, t transfer.Transfer) error
+	Cancel(ctx context.Context, t transfer.Transfer) error
+	QueryByID(ctx context.Context, transferID string) (transfer.Transfer, error)
+	Query(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]transfer.Transfer, error)
+}
+
+// WarehouseStorer looks up the warehouses stock moves between.
+type WarehouseStorer interface {
+	QueryByID(ctx context.Context, warehouseID string) (warehouse.Warehouse, error)
+}
+
+// ProductStorer looks up the products being moved and their variants.
+type ProductStorer interface {
+	QueryByIDs(ctx context.Context, productIDs []string) ([]product.Product, error)
+	QueryVariantsByProducts(ctx context.Context, productIDs []string) ([]product.Variant, error)
+}
+
+type Core struct {
+	logger     *zap.SugaredLogger
+	store      Storer
+	warehouses WarehouseStorer
+	products   ProductStorer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, warehouses WarehouseStorer, products ProductStorer) Core {
+	return Core{
+		logger:     log,
+		store:      store,
+		warehouses: warehouses,
+		products:   products,
+	}
+}
+
+// Create ships the transfer on behalf of the user in claims. Items naming
+// the same product and variant are merged into the line of the first one,
+// a product with variants is moved by variant.
+func (c Core) Create(ctx context.Context, claims auth.Claims, nt transfer.NewTransfer, now time.Time) (transfer.Transfer, error) {
+	if err := validate.Check(nt); err != nil {
+		return transfer.Transfer{}, fmt.Errorf("Create: %w", err)
+	}
+
+	for _, id := range []string{nt.FromWarehouseID, nt.ToWarehouseID} {
+		if _, err := c.warehouses.QueryByID(ctx, id); err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				return transfer.Transfer{}, fmt.Errorf("Create: warehouse %s: %w", id, ErrUnknownWarehouse)
+			}
+			return transfer.Transfer{}, fmt.Errorf("Create: %w", err)
+		}
+	}
+
+	type key struct {
+		productID string
+		variantID string
+	}
+
+	var keys []key
+	var ids []string
+	quantities := make(map[key]int)
+	for _, ni := range nt.Items {
+		k := key{productID: ni.ProductID}
+		if ni.VariantID != nil {
+			k.variantID = *ni.VariantID
+		}
+
+		if _, ok := quantities[k]; !ok {
+			keys = append(keys, k)
+			ids = append(ids, k.productID)
+		}
+		quantities[k] += ni.Quantity
+	}
+
+	prds, err := c.products.QueryByIDs(ctx, ids)
+	if err != nil {
+		return transfer.Transfer{}, fmt.Errorf("Create: %w", err)
+	}
+
+	vars, err := c.products.QueryVariantsByProducts(ctx, ids)
+	if err != nil {
+		return transfer.Transfer{}, fmt.Errorf("Create: %w", err)
+	}
+
+	known := make(map[key]bool, len(prds)+len(vars))
+	for _, p := range prds {
+		known[key{productID: p.ID}] = true
+	}
+
+	hasVariants := make(map[string]bool)
+	for _, v := range vars {
+		hasVariants[v.ProductID] = true
+		known[key{productID: v.ProductID, variantID: v.ID}] = true
+	}
+
+	t := transfer.Transfer{
+		ID:              validate.GenerateUID(),
+		FromWarehouseID: nt.FromWarehouseID,
+		ToWarehouseID:   nt.ToWarehouseID,
+		Status:          transfer.StatusInTransit,
+		Note:            nt.Note,
+		CreatedBy:       claims.Subject,
+		DateCreated:     now,
+		DateUpdated:     now,
+	}
+
+	for i, k := range keys {
+		if !known[key{productID: k.productID}] {
+			return transfer.Transfer{}, fmt.Errorf("Create: product %s: %w", k.productID, ErrUnknownProduct)
+		}
+
+		if k.variantID == "" && hasVariants[k.productID] {
+			return transfer.Transfer{}, fmt.Errorf("Create: product %s: %w", k.productID, ErrNeedsVariant)
+		}
+
+		if !known[k] {
+			return transfer.Transfer{}, fmt.Errorf("Create: product %s variant %s: %w", k.productID, k.variantID, ErrUnknownVariant)
+		}
+
+		item := transfer.Item{
+			TransferID: t.ID,
+			Line:       i + 1,
+			ProductID:  k.productID,
+			Quantity:   quantities[k],
+		}
+		if k.variantID != "" {
+			variantID := k.variantID
+			item.VariantID = &variantID
+		}
+		t.Items = append(t.Items, item)
+	}
+
+	if err := c.store.Create(ctx, t); err != nil {
+		return transfer.Transfer{}, fmt.Errorf("Create: %w", err)
+	}
+	return t, nil
+}
+
+// Receive puts the stock of the transfer into the destination warehouse.
+func (c Core) Receive(ctx context.Context, transferID string, now time.Time) (transfer.Transfer, error) {
+	t, err := c.close(ctx, transferID, transfer.StatusReceived, now, c.store.Receive)
+	if err != nil {
+		return transfer.Transfer{}, fmt.Errorf("Receive: %w", err)
+	}
+	return t, nil
+}
+
+// Cancel puts the stock of the transfer back into the source warehouse.
+func (c Core) Cancel(ctx context.Context, transferID string, now time.Time) (transfer.Transfer, error) {
+	t, err := c.close(ctx, transferID, transfer.StatusCancelled, now, c.store.Cancel)
+	if err != nil {
+		return transfer.Transfer{}, fmt.Errorf("Cancel: %w", err)
+	}
+	return t, nil
+}
+
+// close takes the transfer out of transit through the store method.
+func (c Core) close(ctx context.Context, transferID string, status string, now time.Time, store func(context.Context, transfer.Transfer) error) (transfer.Transfer, error) {
+	if err := validate.CheckID(transferID); err != nil {
+		return transfer.Transfer{}, database.ErrInvalidID
+	}
+
+	t, err := c.store.QueryByID(ctx, transferID)
+	if err != nil {
+		return transfer.Transfer{}, err
+	}
+
+	if t.Status != transfer.StatusInTransit {
+		return transfer.Transfer{}, fmt.Errorf("transfer %s is %s: %w", t.ID, t.Status, transfer.ErrNotInTransit)
+	}
+	t.DateUpdated = now
+
+	if err := store(ctx, t); err != nil {
+		return transfer.Transfer{}, err
+	}
+
+	t.Status = status
+	return t, nil
+}
+
+// QueryByID returns the transfer with the id.
+func (c Core) QueryByID(ctx context.Context, transferID string) (transfer.Transfer, error) {
+	if err := validate.CheckID(transferID); err != nil {
+		return transfer.Transfer{}, fmt.Errorf("QueryByID: %w", database.ErrInvalidID)
+	}
+
+	t, err := c.store.QueryByID(ctx, transferID)
+	if err != nil {
+		return transfer.Transfer{}, fmt.Errorf("QueryByID: %w", err)
+	}
+	return t, nil
+}
+
+// Query returns a page of the transfers, latest first, only those in the
+// status when it is set.
+func (c Core) Query(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]transfer.Transfer, error) {
+	switch status {
+	case "", transfer.StatusInTransit, transfer.StatusReceived, transfer.StatusCancelled:
+	default:
+		return nil, fmt.Errorf("Query: %w", ErrInvalidStatus)
+	}
+
+	transfers, err := c.store.Query(ctx, status, pageNumber, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	return transfers, nil
+}
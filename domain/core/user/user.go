@@ -2,28 +2,46 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/jmoiron/sqlx"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 	"service/domain/data/store/user"
 	"service/domain/sys/auth"
+	"service/domain/sys/validate"
 	"time"
 )
 
+// ErrInvalidPassword is returned when the current password given to change
+// it does not match.
+var ErrInvalidPassword = errors.New("current password is not valid")
+
 // Role represents a role in the system.
 type Role struct {
 	name string
 }
 
+// Storer interface declares the behavior this package needs to persist and
+// retrieve users. The postgres store and the memory store both satisfy it.
+type Storer interface {
+	Create(ctx context.Context, nu user.NewUser, now time.Time) (user.User, error)
+	Update(ctx context.Context, claims auth.Claims, userID string, uu user.UpdateUser, now time.Time) error
+	Delete(ctx context.Context, claims auth.Claims, userID string) error
+	Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]user.User, error)
+	QueryByID(ctx context.Context, claims auth.Claims, userID string) (user.User, error)
+	QueryByEmail(ctx context.Context, claims auth.Claims, email string) (user.User, error)
+	Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error)
+}
+
 type Core struct {
 	logger *zap.SugaredLogger
-	user   user.Store
+	user   Storer
 }
 
-func NewCore(log *zap.SugaredLogger, db *sqlx.DB) Core {
+func NewCore(log *zap.SugaredLogger, storer Storer) Core {
 	return Core{
 		logger: log,
-		user:   user.NewStore(log, db),
+		user:   storer,
 	}
 }
 
@@ -83,3 +101,57 @@ func (c Core) Authenticate(ctx context.Context, now time.Time, email, password s
 	}
 	return claims, nil
 }
+
+// QueryMe returns the user the claims were issued for.
+func (c Core) QueryMe(ctx context.Context, claims auth.Claims) (user.User, error) {
+	usr, err := c.user.QueryByID(ctx, claims, claims.Subject)
+	if err != nil {
+		return user.User{}, fmt.Errorf("QueryMe: %w", err)
+	}
+	return usr, nil
+}
+
+// UpdateMe applies the profile changes to the user the claims were issued
+// for and returns the updated user.
+func (c Core) UpdateMe(ctx context.Context, claims auth.Claims, up user.UpdateProfile, now time.Time) (user.User, error) {
+	if err := validate.Check(up); err != nil {
+		return user.User{}, fmt.Errorf("UpdateMe: %w", err)
+	}
+
+	uu := user.UpdateUser{
+		Name:  up.Name,
+		Email: up.Email,
+	}
+
+	if err := c.user.Update(ctx, claims, claims.Subject, uu, now); err != nil {
+		return user.User{}, fmt.Errorf("UpdateMe: %w", err)
+	}
+
+	return c.QueryMe(ctx, claims)
+}
+
+// ChangePassword sets a new password for the user the claims were issued
+// for once the current one is confirmed.
+func (c Core) ChangePassword(ctx context.Context, claims auth.Claims, cp user.ChangePassword, now time.Time) error {
+	if err := validate.Check(cp); err != nil {
+		return fmt.Errorf("ChangePassword: %w", err)
+	}
+
+	usr, err := c.user.QueryByID(ctx, claims, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("ChangePassword: %w", err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(cp.Current)); err != nil {
+		return fmt.Errorf("ChangePassword: %w", ErrInvalidPassword)
+	}
+
+	uu := user.UpdateUser{
+		Password: &cp.Password,
+	}
+
+	if err := c.user.Update(ctx, claims, claims.Subject, uu, now); err != nil {
+		return fmt.Errorf("ChangePassword: %w", err)
+	}
+	return nil
+}
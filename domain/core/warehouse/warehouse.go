@@ -0,0 +1,307 @@
+// Package warehouse provides the core business API for the warehouses
+// stock is held in and for counting the stock they hold.
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"regexp"
+	"service/domain/data/store/product"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"strings"
+	"time"
+)
+
+// Set of error variables for managing warehouses.
+var (
+	ErrInvalidCode    = errors.New("code must be letters and digits separated by dashes")
+	ErrDefaultNeeded  = errors.New("make another warehouse the default instead")
+	ErrUnknownProduct = errors.New("unknown product")
+	ErrUnknownVariant = errors.New("unknown variant of the product")
+	ErrNeedsVariant   = errors.New("product is stocked by variant")
+)
+
+// codePattern is the stored form of a code, always upper case.
+var codePattern = regexp.MustCompile(`^[A-Z0-9]+(-[A-Z0-9]+)*$`)
+
+// Storer interface declares the behavior this package needs to persist and
+// retrieve warehouses. SetDefault must clear the previous default in the
+// same step. QueryDefault lets the same store pick the warehouse of orders.
+type Storer interface {
+	Create(ctx context.Context, w warehouse.Warehouse) error
+	Update(ctx context.Context, w warehouse.Warehouse) error
+	SetDefault(ctx context.Context, warehouseID string, now time.Time) error
+	Query(ctx context.Context) ([]warehouse.Warehouse, error)
+	QueryByID(ctx context.Context, warehouseID string) (warehouse.Warehouse, error)
+	QueryDefault(ctx context.Context) (warehouse.Warehouse, error)
+}
+
+// ProductStorer looks up the products being counted or adjusted and moves
+// their levels, recording every change in the ledger.
+type ProductStorer interface {
+	QueryByID(ctx context.Context, productID string) (product.Product, error)
+	QueryVariantsByProducts(ctx context.Context, productIDs []string) ([]product.Variant, error)
+	QueryLevels(ctx context.Context, productID string) ([]product.Level, error)
+	SetLevel(ctx context.Context, src product.Source, l product.Level) error
+	Adjust(ctx context.Context, src product.Source, m product.StockMove, now time.Time) error
+}
+
+type Core struct {
+	logger   *zap.SugaredLogger
+	store    Storer
+	products ProductStorer
+}
+
+func NewCore(log *zap.SugaredLogger, store Storer, products ProductStorer) Core {
+	return Core{
+		logger:   log,
+		store:    store,
+		products: products,
+	}
+}
+
+// Create opens a warehouse. The code is stored upper case and must not be
+// used by any other warehouse. When it is made the default it takes over
+// from the current default.
+func (c Core) Create(ctx context.Context, nw warehouse.NewWarehouse, now time.Time) (warehouse.Warehouse, error) {
+	if err := validate.Check(nw); err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("Create: %w", err)
+	}
+
+	code, err := normalizeCode(nw.Code)
+	if err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("Create: %w", err)
+	}
+
+	w := warehouse.Warehouse{
+		ID:          validate.GenerateUID(),
+		Code:        code,
+		Name:        nw.Name,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	if err := c.store.Create(ctx, w); err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("Create: %w", err)
+	}
+
+	if nw.IsDefault {
+		if err := c.store.SetDefault(ctx, w.ID, now); err != nil {
+			return warehouse.Warehouse{}, fmt.Errorf("Create: %w", err)
+		}
+		w.IsDefault = true
+	}
+	return w, nil
+}
+
+// Update changes the fields of the warehouse that are set. There is always
+// a default warehouse, the default can only be moved to another one.
+func (c Core) Update(ctx context.Context, warehouseID string, uw warehouse.UpdateWarehouse, now time.Time) (warehouse.Warehouse, error) {
+	if err := validate.CheckID(warehouseID); err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("Update: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(uw); err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("Update: %w", err)
+	}
+
+	w, err := c.store.QueryByID(ctx, warehouseID)
+	if err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("Update: %w", err)
+	}
+
+	if uw.IsDefault != nil && !*uw.IsDefault && w.IsDefault {
+		return warehouse.Warehouse{}, fmt.Errorf("Update: %w", ErrDefaultNeeded)
+	}
+
+	if uw.Code != nil {
+		if w.Code, err = normalizeCode(*uw.Code); err != nil {
+			return warehouse.Warehouse{}, fmt.Errorf("Update: %w", err)
+		}
+	}
+	if uw.Name != nil {
+		w.Name = *uw.Name
+	}
+	w.DateUpdated = now
+
+	if err := c.store.Update(ctx, w); err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("Update: %w", err)
+	}
+
+	if uw.IsDefault != nil && *uw.IsDefault && !w.IsDefault {
+		if err := c.store.SetDefault(ctx, w.ID, now); err != nil {
+			return warehouse.Warehouse{}, fmt.Errorf("Update: %w", err)
+		}
+		w.IsDefault = true
+	}
+	return w, nil
+}
+
+// Query returns every warehouse ordered by code.
+func (c Core) Query(ctx context.Context) ([]warehouse.Warehouse, error) {
+	whs, err := c.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Query: %w", err)
+	}
+	return whs, nil
+}
+
+// QueryByID returns the warehouse with the id.
+func (c Core) QueryByID(ctx context.Context, warehouseID string) (warehouse.Warehouse, error) {
+	if err := validate.CheckID(warehouseID); err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("QueryByID: %w", database.ErrInvalidID)
+	}
+
+	w, err := c.store.QueryByID(ctx, warehouseID)
+	if err != nil {
+		return warehouse.Warehouse{}, fmt.Errorf("QueryByID: %w", err)
+	}
+	return w, nil
+}
+
+// SetStock records how many units of a product, or of one of its variants,
+// were counted in the warehouse. A product with variants is counted by
+// variant. The difference with what the level held goes in the ledger as
+// a count adjustment.
+func (c Core) SetStock(ctx context.Context, claims auth.Claims, warehouseID string, nl product.NewLevel, now time.Time) (product.Level, error) {
+	if err := validate.CheckID(warehouseID); err != nil {
+		return product.Level{}, fmt.Errorf("SetStock: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(nl); err != nil {
+		return product.Level{}, fmt.Errorf("SetStock: %w", err)
+	}
+
+	if err := c.checkStock(ctx, warehouseID, nl.ProductID, nl.VariantID); err != nil {
+		return product.Level{}, fmt.Errorf("SetStock: %w", err)
+	}
+
+	l := product.Level{
+		WarehouseID: warehouseID,
+		ProductID:   nl.ProductID,
+		VariantID:   nl.VariantID,
+		Quantity:    nl.Quantity,
+		DateUpdated: now,
+	}
+
+	src := product.Source{
+		Kind:      product.KindAdjustment,
+		Reason:    product.ReasonCount,
+		CreatedBy: claims.Subject,
+	}
+
+	if err := c.products.SetLevel(ctx, src, l); err != nil {
+		return product.Level{}, fmt.Errorf("SetStock: %w", err)
+	}
+	return l, nil
+}
+
+// Adjust adds units of a product, or of one of its variants, to the stock
+// of the warehouse or takes them out of it, for the reason given. Taking
+// more than the warehouse holds fails with product.ErrInsufficientStock.
+// It returns the level as adjusted.
+func (c Core) Adjust(ctx context.Context, claims auth.Claims, warehouseID string, na product.NewAdjustment, now time.Time) (product.Level, error) {
+	if err := validate.CheckID(warehouseID); err != nil {
+		return product.Level{}, fmt.Errorf("Adjust: %w", database.ErrInvalidID)
+	}
+
+	if err := validate.Check(na); err != nil {
+		return product.Level{}, fmt.Errorf("Adjust: %w", err)
+	}
+
+	if err := c.checkStock(ctx, warehouseID, na.ProductID, na.VariantID); err != nil {
+		return product.Level{}, fmt.Errorf("Adjust: %w", err)
+	}
+
+	m := product.StockMove{
+		WarehouseID: warehouseID,
+		ProductID:   na.ProductID,
+		VariantID:   na.VariantID,
+		Quantity:    na.Quantity,
+	}
+
+	src := product.Source{
+		Kind:      product.KindAdjustment,
+		Reason:    na.Reason,
+		Note:      na.Note,
+		CreatedBy: claims.Subject,
+	}
+
+	if err := c.products.Adjust(ctx, src, m, now); err != nil {
+		return product.Level{}, fmt.Errorf("Adjust: %w", err)
+	}
+
+	levels, err := c.products.QueryLevels(ctx, na.ProductID)
+	if err != nil {
+		return product.Level{}, fmt.Errorf("Adjust: %w", err)
+	}
+
+	for _, l := range levels {
+		if l.WarehouseID == warehouseID && sameVariant(l.VariantID, na.VariantID) {
+			return l, nil
+		}
+	}
+	return product.Level{WarehouseID: warehouseID, ProductID: na.ProductID, VariantID: na.VariantID, DateUpdated: now}, nil
+}
+
+// checkStock makes sure the warehouse and the product exist, and that the
+// product is named by variant when it has variants.
+func (c Core) checkStock(ctx context.Context, warehouseID string, productID string, variantID *string) error {
+	if _, err := c.store.QueryByID(ctx, warehouseID); err != nil {
+		return err
+	}
+
+	if _, err := c.products.QueryByID(ctx, productID); err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return fmt.Errorf("product %s: %w", productID, ErrUnknownProduct)
+		}
+		return err
+	}
+
+	vars, err := c.products.QueryVariantsByProducts(ctx, []string{productID})
+	if err != nil {
+		return err
+	}
+
+	return checkVariant(productID, variantID, vars)
+}
+
+// sameVariant reports whether both name the same variant, or none.
+func sameVariant(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// checkVariant makes sure a product with variants is named by one of
+// them, and a product without is not.
+func checkVariant(productID string, variantID *string, vars []product.Variant) error {
+	if variantID == nil {
+		if len(vars) > 0 {
+			return fmt.Errorf("product %s: %w", productID, ErrNeedsVariant)
+		}
+		return nil
+	}
+
+	for _, v := range vars {
+		if v.ID == *variantID {
+			return nil
+		}
+	}
+	return fmt.Errorf("product %s variant %s: %w", productID, *variantID, ErrUnknownVariant)
+}
+
+// normalizeCode returns the code in its stored form.
+func normalizeCode(code string) (string, error) {
+	s := strings.ToUpper(strings.TrimSpace(code))
+	if !codePattern.MatchString(s) {
+		return "", fmt.Errorf("code %q: %w", code, ErrInvalidCode)
+	}
+	return s, nil
+}
@@ -0,0 +1,253 @@
+// Package doctor compares the tables in the database with the db tags of
+// the store models that read and write them, so drift between the two is
+// found before a query fails at runtime.
+package doctor
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Set of drift kinds that can be reported.
+const (
+	KindMissingColumn = "missing column"
+	KindExtraColumn   = "extra column"
+	KindMissingTable  = "missing table"
+	KindTypeMismatch  = "type mismatch"
+)
+
+// Model ties a table to the store model mapped onto its rows.
+type Model struct {
+	Table string
+	Value any
+}
+
+// Column describes a column either as found in information_schema or as
+// declared by a model field.
+type Column struct {
+	Name     string
+	DataType string
+	GoType   reflect.Type
+}
+
+// Drift is a single difference between a model and its table.
+type Drift struct {
+	Table  string
+	Column string
+	Kind   string
+	Detail string
+}
+
+func (d Drift) String() string {
+	if d.Column == "" {
+		return fmt.Sprintf("%s: %s %s", d.Table, d.Kind, d.Detail)
+	}
+	return fmt.Sprintf("%s.%s: %s %s", d.Table, d.Column, d.Kind, d.Detail)
+}
+
+// Check introspects information_schema for every model's table and reports
+// the differences, sorted by table and column.
+func Check(ctx context.Context, db *sqlx.DB, models []Model) ([]Drift, error) {
+	var drifts []Drift
+	for _, m := range models {
+		dbCols, err := tableColumns(ctx, db, m.Table)
+		if err != nil {
+			return nil, fmt.Errorf("reading columns for %s: %w", m.Table, err)
+		}
+
+		if len(dbCols) == 0 {
+			drifts = append(drifts, Drift{Table: m.Table, Kind: KindMissingTable})
+			continue
+		}
+
+		drifts = append(drifts, Compare(m.Table, ModelColumns(m.Value), dbCols)...)
+	}
+
+	return drifts, nil
+}
+
+// Compare reports the differences between the columns declared by a model
+// and the columns of its table.
+func Compare(table string, modelCols []Column, dbCols []Column) []Drift {
+	byName := make(map[string]Column, len(dbCols))
+	for _, c := range dbCols {
+		byName[c.Name] = c
+	}
+
+	var drifts []Drift
+	for _, mc := range modelCols {
+		dc, ok := byName[mc.Name]
+		if !ok {
+			drifts = append(drifts, Drift{
+				Table:  table,
+				Column: mc.Name,
+				Kind:   KindMissingColumn,
+				Detail: fmt.Sprintf("model field of type %s has no column", mc.GoType),
+			})
+			continue
+		}
+		delete(byName, mc.Name)
+
+		if !compatible(mc.GoType, dc.DataType) {
+			drifts = append(drifts, Drift{
+				Table:  table,
+				Column: mc.Name,
+				Kind:   KindTypeMismatch,
+				Detail: fmt.Sprintf("model has %s, column is %s", mc.GoType, dc.DataType),
+			})
+		}
+	}
+
+	// Our queries select *, sqlx refuses to scan a column the model does
+	// not have a field for.
+	for _, dc := range byName {
+		drifts = append(drifts, Drift{
+			Table:  table,
+			Column: dc.Name,
+			Kind:   KindExtraColumn,
+			Detail: fmt.Sprintf("column of type %s has no model field", dc.DataType),
+		})
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Table != drifts[j].Table {
+			return drifts[i].Table < drifts[j].Table
+		}
+		return drifts[i].Column < drifts[j].Column
+	})
+
+	return drifts
+}
+
+// ModelColumns returns the columns declared through db tags on v, embedded
+// structs are walked the same way sqlx walks them.
+func ModelColumns(v any) []Column {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var cols []Column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := strings.SplitN(f.Tag.Get("db"), ",", 2)[0]
+		if tag == "-" {
+			continue
+		}
+
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			cols = append(cols, ModelColumns(reflect.Zero(f.Type).Interface())...)
+			continue
+		}
+
+		if tag == "" {
+			continue
+		}
+
+		cols = append(cols, Column{Name: tag, GoType: f.Type})
+	}
+	return cols
+}
+
+func tableColumns(ctx context.Context, db *sqlx.DB, table string) ([]Column, error) {
+	const q = `
+	SELECT
+		column_name, data_type, udt_name
+	FROM
+		information_schema.columns
+	WHERE
+		table_schema = current_schema() AND table_name = $1
+	ORDER BY
+		ordinal_position`
+
+	rows, err := db.QueryContext(ctx, q, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []Column
+	for rows.Next() {
+		var name, dataType, udtName string
+		if err := rows.Scan(&name, &dataType, &udtName); err != nil {
+			return nil, err
+		}
+
+		// Arrays are reported as ARRAY, the element type lives in udt_name.
+		if dataType == "ARRAY" {
+			dataType = strings.TrimPrefix(udtName, "_") + "[]"
+		}
+
+		cols = append(cols, Column{Name: name, DataType: dataType})
+	}
+	return cols, rows.Err()
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// compatible reports whether values of the Go type can be scanned from and
+// written to a column of the postgres data type.
+func compatible(t reflect.Type, dataType string) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return oneOf(dataType, "timestamp without time zone", "timestamp with time zone", "date")
+	case bytesType:
+		return oneOf(dataType, "bytea", "text", "character varying")
+	}
+
+	// Types with their own conversion can not be judged from the outside,
+	// unless they are string arrays like pq.StringArray.
+	if reflect.PointerTo(t).Implements(scannerType) || t.Implements(valuerType) {
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String {
+			return oneOf(dataType, "text[]", "varchar[]")
+		}
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return oneOf(dataType, "text", "character varying", "character", "uuid", "jsonb", "json", "tsvector")
+	case reflect.Bool:
+		return dataType == "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return oneOf(dataType, "smallint", "integer", "bigint")
+	case reflect.Float32, reflect.Float64:
+		return oneOf(dataType, "real", "double precision", "numeric")
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return oneOf(dataType, "text[]", "varchar[]")
+		}
+	}
+
+	return false
+}
+
+func oneOf(s string, set ...string) bool {
+	for _, v := range set {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
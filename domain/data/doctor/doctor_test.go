@@ -0,0 +1,72 @@
+package doctor
+
+import (
+	"service/domain/data/store/user"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+func TestCompare(t *testing.T) {
+
+	t.Log("Given the need to find drift between models and tables")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When the users table drifted from the model", testID)
+		{
+			dbCols := []Column{
+				{Name: "user_id", DataType: "uuid"},
+				{Name: "name", DataType: "text"},
+				{Name: "email", DataType: "text"},
+				{Name: "roles", DataType: "text[]"},
+				{Name: "password_hash", DataType: "text"},
+				{Name: "enabled", DataType: "integer"},
+				{Name: "date_created", DataType: "timestamp without time zone"},
+				{Name: "date_update", DataType: "timestamp without time zone"},
+			}
+
+			drifts := Compare("users", ModelColumns(user.User{}), dbCols)
+
+			exp := []Drift{
+				{Table: "users", Column: "date_update", Kind: KindExtraColumn},
+				{Table: "users", Column: "date_updated", Kind: KindMissingColumn},
+				{Table: "users", Column: "enabled", Kind: KindTypeMismatch},
+			}
+
+			if len(drifts) != len(exp) {
+				t.Fatalf("\t %s \t Test %d \t Should report %d drifts, got %v", failure, testID, len(exp), drifts)
+			}
+			t.Logf("\t %s \t Test %d \t Should report %d drifts", success, testID, len(exp))
+
+			for i := range exp {
+				if drifts[i].Column != exp[i].Column || drifts[i].Kind != exp[i].Kind {
+					t.Fatalf("\t %s \t Test %d \t Should report %s on %s, got %s", failure, testID, exp[i].Kind, exp[i].Column, drifts[i])
+				}
+			}
+			t.Logf("\t %s \t Test %d \t Should report the expected drifts", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When the users table matches the model", testID)
+		{
+			dbCols := []Column{
+				{Name: "user_id", DataType: "uuid"},
+				{Name: "name", DataType: "text"},
+				{Name: "email", DataType: "text"},
+				{Name: "roles", DataType: "text[]"},
+				{Name: "password_hash", DataType: "text"},
+				{Name: "enabled", DataType: "boolean"},
+				{Name: "date_created", DataType: "timestamp without time zone"},
+				{Name: "date_updated", DataType: "timestamp without time zone"},
+			}
+
+			if drifts := Compare("users", ModelColumns(user.User{}), dbCols); len(drifts) != 0 {
+				t.Fatalf("\t %s \t Test %d \t Should report no drift, got %v", failure, testID, drifts)
+			}
+			t.Logf("\t %s \t Test %d \t Should report no drift", success, testID)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package doctor
+
+import (
+	"service/domain/data/store/apikey"
+	"service/domain/data/store/audit"
+	"service/domain/data/store/category"
+	"service/domain/data/store/lockout"
+	"service/domain/data/store/mfa"
+	"service/domain/data/store/order"
+	"service/domain/data/store/product"
+	"service/domain/data/store/promotion"
+	"service/domain/data/store/purchase"
+	"service/domain/data/store/refund"
+	"service/domain/data/store/reset"
+	"service/domain/data/store/role"
+	"service/domain/data/store/stocktake"
+	"service/domain/data/store/supplier"
+	"service/domain/data/store/transfer"
+	"service/domain/data/store/user"
+	"service/domain/data/store/warehouse"
+)
+
+// Models lists every store model together with the table it maps. New
+// store models must be added here so drift checks cover them. role.Role
+// is left out, its permissions are aggregated from role_permissions.
+var Models = []Model{
+	{Table: "users", Value: user.User{}},
+	{Table: "password_resets", Value: reset.Token{}},
+	{Table: "login_failures", Value: lockout.Failure{}},
+	{Table: "audit_events", Value: audit.Event{}},
+	{Table: "user_mfa", Value: mfa.MFA{}},
+	{Table: "mfa_recovery_codes", Value: mfa.RecoveryCode{}},
+	{Table: "permissions", Value: role.Permission{}},
+	{Table: "api_keys", Value: apikey.Key{}},
+	{Table: "categories", Value: category.Category{}},
+	{Table: "products", Value: product.Product{}},
+	{Table: "product_variants", Value: product.Variant{}},
+	{Table: "warehouses", Value: warehouse.Warehouse{}},
+	{Table: "stock_levels", Value: product.Level{}},
+	{Table: "transfers", Value: transfer.Transfer{}},
+	{Table: "transfer_items", Value: transfer.Item{}},
+	{Table: "stock_movements", Value: product.Movement{}},
+	{Table: "stocktakes", Value: stocktake.Stocktake{}},
+	{Table: "stocktake_lines", Value: stocktake.Line{}},
+	{Table: "suppliers", Value: supplier.Supplier{}},
+	{Table: "purchase_orders", Value: purchase.Order{}},
+	{Table: "purchase_order_lines", Value: purchase.Line{}},
+	{Table: "coupons", Value: promotion.Coupon{}},
+	{Table: "promotions", Value: promotion.Promotion{}},
+	{Table: "orders", Value: order.Order{}},
+	{Table: "order_items", Value: order.Item{}},
+	{Table: "order_item_discounts", Value: order.Discount{}},
+	{Table: "refunds", Value: refund.Refund{}},
+	{Table: "refund_items", Value: refund.Item{}},
+}
@@ -0,0 +1,311 @@
+package schema
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/ardanlabs/darwin"
+	"github.com/jmoiron/sqlx"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	//go:embed sql/schema_down.sql
+	schemaDownDoc string
+)
+
+// Set of statuses a migration can be reported with.
+const (
+	StatusApplied  = "applied"
+	StatusPending  = "pending"
+	StatusModified = "modified"
+	StatusRemoved  = "removed"
+)
+
+// ErrMigrationModified is returned when a migration that was already applied
+// no longer matches the script that was run against the database.
+var ErrMigrationModified = errors.New("applied migration was modified")
+
+// Migration is a versioned schema change together with the script that
+// undoes it.
+type Migration struct {
+	Version     float64
+	Description string
+	Up          string
+	Down        string
+}
+
+// Checksum returns the checksum darwin records for the up script.
+func (m Migration) Checksum() string {
+	return darwin.Migration{Script: m.Up}.Checksum()
+}
+
+// MigrationStatus describes how a migration relates to the database.
+type MigrationStatus struct {
+	Version         float64
+	Description     string
+	Status          string
+	Checksum        string
+	AppliedChecksum string
+	AppliedAt       time.Time
+	HasDown         bool
+}
+
+// Migrations returns the known migrations ordered by version, each paired
+// with its down script.
+func Migrations() ([]Migration, error) {
+	ups := darwin.ParseMigrations(schemaDoc)
+	if ups == nil {
+		return nil, errors.New("parsing up migrations")
+	}
+
+	downs := darwin.ParseMigrations(schemaDownDoc)
+	if downs == nil {
+		return nil, errors.New("parsing down migrations")
+	}
+
+	downByVersion := make(map[float64]string, len(downs))
+	for _, d := range downs {
+		downByVersion[d.Version] = d.Script
+	}
+
+	migs := make([]Migration, len(ups))
+	for i, up := range ups {
+		migs[i] = Migration{
+			Version:     up.Version,
+			Description: up.Description,
+			Up:          up.Script,
+			Down:        downByVersion[up.Version],
+		}
+		delete(downByVersion, up.Version)
+	}
+
+	for version := range downByVersion {
+		return nil, fmt.Errorf("down migration %v has no up migration", version)
+	}
+
+	sort.Slice(migs, func(i, j int) bool {
+		return migs[i].Version < migs[j].Version
+	})
+
+	return migs, nil
+}
+
+// Status reports every known migration and whether it was applied, along
+// with applied versions that are no longer known to us.
+func Status(ctx context.Context, db *sqlx.DB) ([]MigrationStatus, error) {
+	migs, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := appliedRecords(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[float64]darwin.MigrationRecord, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migs {
+		ms := MigrationStatus{
+			Version:     m.Version,
+			Description: m.Description,
+			Status:      StatusPending,
+			Checksum:    m.Checksum(),
+			HasDown:     strings.TrimSpace(m.Down) != "",
+		}
+
+		if r, ok := applied[m.Version]; ok {
+			ms.Status = StatusApplied
+			ms.AppliedChecksum = r.Checksum
+			ms.AppliedAt = r.AppliedAt
+			if r.Checksum != ms.Checksum {
+				ms.Status = StatusModified
+			}
+			delete(applied, m.Version)
+		}
+
+		statuses = append(statuses, ms)
+	}
+
+	for _, r := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Version:         r.Version,
+			Description:     r.Description,
+			Status:          StatusRemoved,
+			AppliedChecksum: r.Checksum,
+			AppliedAt:       r.AppliedAt,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// Verify makes sure no applied migration was edited or removed since it
+// was applied.
+func Verify(ctx context.Context, db *sqlx.DB) error {
+	statuses, err := Status(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	var bad []string
+	for _, s := range statuses {
+		if s.Status == StatusModified || s.Status == StatusRemoved {
+			bad = append(bad, fmt.Sprintf("%v (%s)", s.Version, s.Status))
+		}
+	}
+
+	if len(bad) > 0 {
+		return fmt.Errorf("%w: %s", ErrMigrationModified, strings.Join(bad, ", "))
+	}
+	return nil
+}
+
+// Pending returns the migrations Migrate would apply, it does not touch the
+// database beyond reading the applied versions.
+func Pending(ctx context.Context, db *sqlx.DB) ([]Migration, error) {
+	migs, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := appliedRecords(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	// Darwin applies everything above the last applied version.
+	var last float64
+	for _, r := range records {
+		if r.Version > last {
+			last = r.Version
+		}
+	}
+
+	var pending []Migration
+	for _, m := range migs {
+		if len(records) == 0 || m.Version > last {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// RollbackPlan returns the migrations Rollback would undo for the given
+// number of steps, latest first.
+func RollbackPlan(ctx context.Context, db *sqlx.DB, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, errors.New("steps must be at least one")
+	}
+
+	migs, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[float64]Migration, len(migs))
+	for _, m := range migs {
+		byVersion[m.Version] = m
+	}
+
+	records, err := appliedRecords(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Version > records[j].Version
+	})
+
+	if steps > len(records) {
+		return nil, fmt.Errorf("asked to roll back %d migrations, only %d applied", steps, len(records))
+	}
+
+	plan := make([]Migration, 0, steps)
+	for _, r := range records[:steps] {
+		m, ok := byVersion[r.Version]
+		if !ok {
+			return nil, fmt.Errorf("applied migration %v is unknown", r.Version)
+		}
+
+		if r.Checksum != m.Checksum() {
+			return nil, fmt.Errorf("%w: %v", ErrMigrationModified, r.Version)
+		}
+
+		if strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %v has no down migration", r.Version)
+		}
+
+		plan = append(plan, m)
+	}
+
+	return plan, nil
+}
+
+// Rollback undoes the last applied migrations. Each down script runs in a
+// transaction together with the removal of its darwin record, so a failure
+// leaves the database at the last successfully undone version.
+func Rollback(ctx context.Context, db *sqlx.DB, steps int) ([]Migration, error) {
+	plan, err := RollbackPlan(ctx, db, steps)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `DELETE FROM darwin_migrations WHERE version = $1::real`
+
+	for i, m := range plan {
+		tx, err := db.BeginTxx(ctx, nil)
+		if err != nil {
+			return plan[:i], err
+		}
+
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			tx.Rollback()
+			return plan[:i], fmt.Errorf("rolling back %v: %w", m.Version, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, q, m.Version); err != nil {
+			tx.Rollback()
+			return plan[:i], fmt.Errorf("removing record %v: %w", m.Version, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return plan[:i], fmt.Errorf("commit %v: %w", m.Version, err)
+		}
+	}
+
+	return plan, nil
+}
+
+// appliedRecords reads the darwin bookkeeping table without creating it,
+// a database that was never migrated has no applied records.
+func appliedRecords(ctx context.Context, db *sqlx.DB) ([]darwin.MigrationRecord, error) {
+	var exists bool
+	const q = `SELECT to_regclass('darwin_migrations') IS NOT NULL`
+	if err := db.QueryRowContext(ctx, q).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("checking migrations table: %w", err)
+	}
+
+	if !exists {
+		return nil, nil
+	}
+
+	driver, err := darwin.NewGenericDriver(db.DB, darwin.PostgresDialect{})
+	if err != nil {
+		return nil, fmt.Errorf("construct darwin driver %w", err)
+	}
+
+	return driver.All()
+}
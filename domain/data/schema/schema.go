@@ -26,6 +26,10 @@ func Migrate(ctx context.Context, db *sqlx.DB) error {
 		return fmt.Errorf("status chech database %w", err)
 	}
 
+	if err := Verify(ctx, db); err != nil {
+		return err
+	}
+
 	driver, err := darwin.NewGenericDriver(db.DB, darwin.PostgresDialect{})
 	if err != nil {
 		return fmt.Errorf("construct darwin driver %w", err)
@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+func TestMigrationsArePaired(t *testing.T) {
+
+	t.Log("Given the need to roll back any release")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When parsing the embedded migrations", testID)
+		{
+			migs, err := Migrations()
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to parse the migrations: %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should be able to parse the migrations", success, testID)
+
+			for i, m := range migs {
+				if strings.TrimSpace(m.Down) == "" {
+					t.Errorf("\t %s \t Test %d \t Should have a down migration for %v", failure, testID, m.Version)
+				}
+
+				if i > 0 && migs[i-1].Version >= m.Version {
+					t.Errorf("\t %s \t Test %d \t Should have increasing versions, %v follows %v", failure, testID, m.Version, migs[i-1].Version)
+				}
+			}
+			t.Logf("\t %s \t Test %d \t Should have a down migration for every version", success, testID)
+		}
+	}
+}
+
+// checksums pins the checksum of every migration that has shipped. Append a
+// line for each new migration, never edit an existing one.
+var checksums = map[float64]string{
+	1.1: "0a35664e3251686de55acf94aae02573",
+	1.2: "751d52c508c76ac38ca83066f4544175",
+	1.3: "19e5e5a7b6ed58f0993ae207c5ea1c6d",
+	1.4: "879d0fa2f1d7e0b8c0769877f5f25c7b",
+	1.5: "fab81eb8094937b7efba0094a3fcfd51",
+	1.6: "ad125dd121e5e411a6bf4e3baed912e9",
+	1.7: "93af93a494fa7e37cf57a3f65229466a",
+	1.8: "898e770f58217fafcbb08494cc743305",
+	1.9: "1096f6b08dc0ebbd8e3b8036303ea463",
+	2.0: "b3471ff8b084778750b8e3567180d855",
+	2.1: "8149d50bc1e0db8b4fd63e4a4e9be31b",
+	2.2: "70c710be7801f607f515e90bde139f5e",
+	2.3: "34a340077a6ab43208048e2cea5c4492",
+	2.4: "5be342f3a0ffbd163ed26033bb2bcaa4",
+	2.5: "7f6d6e0ced55a62bdadfb3f71fc40569",
+	2.6: "ce68fc7e20c8d8520632d85c4a32f569",
+	2.7: "3a65cef1d375fee1d8f4ac5ff5f36c34",
+	2.8: "494d4a88b09581fff66c0b92a112595a",
+	2.9: "f33e0b65966d396c0d9aac295b2e1e75",
+	3.0: "e16ba85f995b10a5652eed64427d3e69",
+	3.1: "c71da75a5b3f9f2b34b4097bf75bbcc0",
+	3.2: "86c27cb90f5cd7801e6c294d521bbc60",
+	3.3: "fcea7eceb950f8a9a4abb2a39cc75e74",
+	3.4: "9e1b8b9687df631013d4acd37a8eb4bc",
+}
+
+func TestMigrationsUnchanged(t *testing.T) {
+
+	t.Log("Given the need to never edit an applied migration")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When comparing migrations with their pinned checksums", testID)
+		{
+			migs, err := Migrations()
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to parse the migrations: %v", failure, testID, err)
+			}
+
+			for _, m := range migs {
+				exp, ok := checksums[m.Version]
+				if !ok {
+					t.Errorf("\t %s \t Test %d \t Should have a pinned checksum for %v, got %s", failure, testID, m.Version, m.Checksum())
+					continue
+				}
+
+				if got := m.Checksum(); got != exp {
+					t.Errorf("\t %s \t Test %d \t Should not have modified %v: exp %s got %s", failure, testID, m.Version, exp, got)
+				}
+			}
+			t.Logf("\t %s \t Test %d \t Should match every pinned checksum", success, testID)
+		}
+	}
+}
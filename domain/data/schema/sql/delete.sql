@@ -1,3 +1,24 @@
-DELETE FROM sales;
+DELETE FROM purchase_order_lines;
+DELETE FROM purchase_orders;
+DELETE FROM suppliers;
+DELETE FROM stocktake_lines;
+DELETE FROM stocktakes;
+DELETE FROM transfer_items;
+DELETE FROM transfers;
+DELETE FROM refund_items;
+DELETE FROM refunds;
+DELETE FROM order_item_discounts;
+DELETE FROM order_items;
+DELETE FROM orders;
+DELETE FROM promotions;
+DELETE FROM coupons;
+DELETE FROM stock_movements;
+DELETE FROM stock_levels;
+DELETE FROM warehouses WHERE warehouse_id <> '0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6';
+UPDATE warehouses SET is_default = TRUE;
+DELETE FROM low_stock_alerts;
+DELETE FROM product_tags;
+DELETE FROM product_variants;
 DELETE FROM products;
+DELETE FROM categories;
 DELETE FROM users;
\ No newline at end of file
@@ -1,3 +1,7 @@
+-- Migrations are append only. Start a new migration on the line right after
+-- the last one, any line in between (blank or not) becomes part of the
+-- previous script and changes the checksum darwin recorded for it.
+
 -- Version: 1.1
 -- Description: Create table users
 CREATE TABLE users (
@@ -41,4 +45,517 @@ CREATE TABLE sales(
     PRIMARY KEY(sale_id),
     FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
     FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE CASCADE
-);
\ No newline at end of file
+);
+-- Version: 1.4
+-- Description: Add enabled flag to users
+ALTER TABLE users ADD COLUMN enabled BOOLEAN NOT NULL DEFAULT TRUE;
+-- Version: 1.5
+-- Description: Rename date_update to date_updated to match the models
+ALTER TABLE users RENAME COLUMN date_update TO date_updated;
+ALTER TABLE products RENAME COLUMN date_update TO date_updated;
+ALTER TABLE sales RENAME COLUMN date_update TO date_updated;
+-- Version: 1.6
+-- Description: Create table password_resets
+CREATE TABLE password_resets(
+    token_hash   TEXT,
+    user_id      UUID NOT NULL,
+    date_expires TIMESTAMP NOT NULL,
+    date_used    TIMESTAMP,
+    date_created TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(token_hash),
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+CREATE INDEX password_resets_user_id_idx ON password_resets(user_id);
+-- Version: 1.7
+-- Description: Create tables login_failures and audit_events
+CREATE TABLE login_failures(
+    key          TEXT,
+    failures     INT NOT NULL,
+    last_failure TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+
+    PRIMARY KEY(key)
+);
+CREATE TABLE audit_events(
+    event_id     UUID,
+    action       TEXT NOT NULL,
+    actor        TEXT NOT NULL,
+    subject      TEXT NOT NULL,
+    detail       TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(event_id)
+);
+CREATE INDEX audit_events_subject_idx ON audit_events(subject, date_created);
+-- Version: 1.8
+-- Description: Create tables user_mfa and mfa_recovery_codes
+CREATE TABLE user_mfa(
+    user_id      UUID,
+    secret       TEXT NOT NULL,
+    enabled      BOOLEAN NOT NULL DEFAULT FALSE,
+    last_step    BIGINT NOT NULL DEFAULT 0,
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(user_id),
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+CREATE TABLE mfa_recovery_codes(
+    user_id      UUID,
+    code_hash    TEXT,
+    date_used    TIMESTAMP,
+    date_created TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(user_id, code_hash),
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+-- Version: 1.9
+-- Description: Create tables permissions, roles and role_permissions
+CREATE TABLE permissions(
+    name        TEXT,
+    description TEXT NOT NULL,
+
+    PRIMARY KEY(name)
+);
+CREATE TABLE roles(
+    name         TEXT,
+    description  TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(name)
+);
+CREATE TABLE role_permissions(
+    role       TEXT,
+    permission TEXT,
+
+    PRIMARY KEY(role, permission),
+    FOREIGN KEY(role) REFERENCES roles(name) ON DELETE CASCADE,
+    FOREIGN KEY(permission) REFERENCES permissions(name) ON DELETE CASCADE
+);
+INSERT INTO permissions (name, description) VALUES
+('users:read', 'View users'),
+('users:write', 'Create, update and delete users'),
+('roles:read', 'View roles and permissions'),
+('roles:write', 'Manage roles and their permissions'),
+('products:read', 'View products'),
+('products:write', 'Create, update and delete products'),
+('sales:read', 'View sales'),
+('sales:write', 'Record sales'),
+('sales:refund', 'Refund sales'),
+('reports:read', 'View reports');
+INSERT INTO roles (name, description, date_created, date_updated) VALUES
+('ADMIN', 'Administrators', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+('USER', 'Regular users', '2019-03-24 00:00:00', '2019-03-24 00:00:00');
+INSERT INTO role_permissions (role, permission)
+SELECT 'ADMIN', name FROM permissions;
+INSERT INTO role_permissions (role, permission) VALUES
+('USER', 'products:read'),
+('USER', 'sales:read');
+-- Version: 2.0
+-- Description: Create table api_keys
+CREATE TABLE api_keys(
+    key_id         UUID,
+    name           TEXT NOT NULL,
+    prefix         TEXT NOT NULL UNIQUE,
+    secret_hash    TEXT NOT NULL,
+    scopes         TEXT[] NOT NULL,
+    created_by     TEXT NOT NULL,
+    date_expires   TIMESTAMP,
+    date_last_used TIMESTAMP,
+    date_revoked   TIMESTAMP,
+    date_created   TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(key_id)
+);
+-- Version: 2.1
+-- Description: Create tables orders and order_items, sales become one line orders
+CREATE TABLE orders(
+    order_id     UUID,
+    customer_id  UUID,
+    total        INT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(order_id),
+    FOREIGN KEY(customer_id) REFERENCES users(user_id) ON DELETE SET NULL
+);
+CREATE INDEX orders_customer_id_idx ON orders(customer_id, date_created DESC);
+CREATE TABLE order_items(
+    order_id   UUID,
+    line       INT,
+    product_id UUID NOT NULL,
+    quantity   INT NOT NULL CHECK (quantity > 0),
+    unit_price INT NOT NULL,
+    total      INT NOT NULL,
+
+    PRIMARY KEY(order_id, line),
+    FOREIGN KEY(order_id) REFERENCES orders(order_id) ON DELETE CASCADE,
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE RESTRICT
+);
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM sales WHERE product_id IS NULL OR quantity IS NULL OR quantity <= 0) THEN
+        RAISE EXCEPTION 'sales without a product or a positive quantity can not become orders, fix or remove them first';
+    END IF;
+END $$;
+INSERT INTO orders (order_id, customer_id, total, date_created, date_updated)
+SELECT sale_id, user_id, COALESCE(paid, 0), COALESCE(date_created, now()), COALESCE(date_updated, date_created, now())
+FROM sales;
+-- The unit price is what was paid divided by the quantity rounded down, the
+-- total stays what was paid and is what refunds and margins work from.
+INSERT INTO order_items (order_id, line, product_id, quantity, unit_price, total)
+SELECT sale_id, 1, product_id, quantity, COALESCE(paid, 0) / quantity, COALESCE(paid, 0)
+FROM sales;
+DROP TABLE sales;
+-- Version: 2.2
+-- Description: Create tables refunds and refund_items, keep refunded totals on order_items
+ALTER TABLE order_items ADD COLUMN refunded_quantity INT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN refunded_amount INT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD CONSTRAINT order_items_refunded_check
+    CHECK (refunded_quantity BETWEEN 0 AND quantity AND refunded_amount BETWEEN 0 AND total);
+CREATE TABLE refunds(
+    refund_id    UUID,
+    order_id     UUID NOT NULL,
+    reason       TEXT NOT NULL,
+    note         TEXT NOT NULL,
+    amount       INT NOT NULL,
+    approved_by  TEXT NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(refund_id),
+    FOREIGN KEY(order_id) REFERENCES orders(order_id) ON DELETE CASCADE
+);
+CREATE INDEX refunds_order_id_idx ON refunds(order_id);
+CREATE TABLE refund_items(
+    refund_id  UUID,
+    line       INT,
+    product_id UUID NOT NULL,
+    quantity   INT NOT NULL CHECK (quantity >= 0),
+    amount     INT NOT NULL CHECK (amount > 0),
+    restocked  BOOLEAN NOT NULL,
+
+    PRIMARY KEY(refund_id, line),
+    FOREIGN KEY(refund_id) REFERENCES refunds(refund_id) ON DELETE CASCADE
+);
+-- Version: 2.3
+-- Description: Add the currency of amounts to products, orders and refunds
+ALTER TABLE products ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE products ADD CONSTRAINT products_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE orders ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE orders ADD CONSTRAINT orders_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE refunds ADD COLUMN currency CHAR(3);
+UPDATE refunds AS r SET currency = o.currency FROM orders AS o WHERE o.order_id = r.order_id;
+ALTER TABLE refunds ALTER COLUMN currency SET NOT NULL;
+ALTER TABLE refunds ADD CONSTRAINT refunds_currency_check CHECK (currency ~ '^[A-Z]{3}$');
+-- Version: 2.4
+-- Description: Create tables categories and product_tags, file products under categories
+CREATE TABLE categories(
+    category_id  UUID,
+    parent_id    UUID NULL,
+    name         TEXT NOT NULL,
+    slug         TEXT NOT NULL UNIQUE,
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(category_id),
+    FOREIGN KEY(parent_id) REFERENCES categories(category_id),
+    CHECK (parent_id <> category_id)
+);
+CREATE INDEX categories_parent_id_idx ON categories(parent_id);
+ALTER TABLE products ADD COLUMN category_id UUID NULL REFERENCES categories(category_id) ON DELETE RESTRICT;
+CREATE INDEX products_category_id_idx ON products(category_id);
+CREATE TABLE product_tags(
+    product_id UUID,
+    tag        TEXT,
+
+    PRIMARY KEY(product_id, tag),
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+CREATE INDEX product_tags_tag_idx ON product_tags(tag);
+-- Version: 2.5
+-- Description: Add a text search vector on the names of products
+ALTER TABLE products ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', name)) STORED;
+CREATE INDEX products_search_idx ON products USING GIN(search);
+-- Version: 2.6
+-- Description: Create table product_variants, let order and refund lines name a variant
+CREATE TABLE product_variants(
+    variant_id   UUID,
+    product_id   UUID NOT NULL,
+    sku          TEXT NOT NULL UNIQUE,
+    attributes   JSONB NOT NULL DEFAULT '{}',
+    price        INT NULL CHECK (price >= 0),
+    quantity     INT NOT NULL CHECK (quantity >= 0),
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(variant_id),
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+CREATE INDEX product_variants_product_id_idx ON product_variants(product_id);
+ALTER TABLE order_items ADD COLUMN variant_id UUID NULL REFERENCES product_variants(variant_id) ON DELETE RESTRICT;
+ALTER TABLE refund_items ADD COLUMN variant_id UUID NULL REFERENCES product_variants(variant_id) ON DELETE RESTRICT;
+-- Version: 2.7
+-- Description: Create tables warehouses, stock_levels, transfers and transfer_items, pick orders from a warehouse
+CREATE TABLE warehouses(
+    warehouse_id UUID,
+    code         TEXT NOT NULL UNIQUE,
+    name         TEXT NOT NULL,
+    is_default   BOOLEAN NOT NULL DEFAULT FALSE,
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(warehouse_id)
+);
+CREATE UNIQUE INDEX warehouses_default_idx ON warehouses(is_default) WHERE is_default;
+INSERT INTO warehouses (warehouse_id, code, name, is_default, date_created, date_updated) VALUES
+('0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6', 'MAIN', 'Main Warehouse', TRUE, '2019-03-24 00:00:00', '2019-03-24 00:00:00');
+CREATE TABLE stock_levels(
+    warehouse_id UUID NOT NULL,
+    product_id   UUID NOT NULL,
+    variant_id   UUID NULL,
+    quantity     INT NOT NULL CHECK (quantity >= 0),
+    date_updated TIMESTAMP NOT NULL,
+
+    FOREIGN KEY(warehouse_id) REFERENCES warehouses(warehouse_id) ON DELETE RESTRICT,
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY(variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX stock_levels_level_idx ON stock_levels(warehouse_id, product_id, COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'));
+CREATE INDEX stock_levels_product_id_idx ON stock_levels(product_id);
+INSERT INTO stock_levels (warehouse_id, product_id, variant_id, quantity, date_updated)
+SELECT '0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6', product_id, NULL, quantity, date_updated FROM products WHERE quantity > 0;
+INSERT INTO stock_levels (warehouse_id, product_id, variant_id, quantity, date_updated)
+SELECT '0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6', product_id, variant_id, quantity, date_updated FROM product_variants WHERE quantity > 0;
+CREATE TABLE transfers(
+    transfer_id       UUID,
+    from_warehouse_id UUID NOT NULL,
+    to_warehouse_id   UUID NOT NULL,
+    status            TEXT NOT NULL CHECK (status IN ('in_transit', 'received', 'cancelled')),
+    note              TEXT NOT NULL DEFAULT '',
+    created_by        UUID NOT NULL,
+    date_created      TIMESTAMP NOT NULL,
+    date_updated      TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(transfer_id),
+    FOREIGN KEY(from_warehouse_id) REFERENCES warehouses(warehouse_id) ON DELETE RESTRICT,
+    FOREIGN KEY(to_warehouse_id) REFERENCES warehouses(warehouse_id) ON DELETE RESTRICT,
+    FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE RESTRICT,
+    CHECK (from_warehouse_id <> to_warehouse_id)
+);
+CREATE INDEX transfers_status_idx ON transfers(status);
+CREATE TABLE transfer_items(
+    transfer_id UUID NOT NULL,
+    line        INT NOT NULL,
+    product_id  UUID NOT NULL,
+    variant_id  UUID NULL,
+    quantity    INT NOT NULL CHECK (quantity > 0),
+
+    PRIMARY KEY(transfer_id, line),
+    FOREIGN KEY(transfer_id) REFERENCES transfers(transfer_id) ON DELETE CASCADE,
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE RESTRICT,
+    FOREIGN KEY(variant_id) REFERENCES product_variants(variant_id) ON DELETE RESTRICT
+);
+ALTER TABLE orders ADD COLUMN warehouse_id UUID NULL REFERENCES warehouses(warehouse_id) ON DELETE RESTRICT;
+UPDATE orders SET warehouse_id = '0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6';
+ALTER TABLE orders ALTER COLUMN warehouse_id SET NOT NULL;
+INSERT INTO permissions (name, description) VALUES
+('inventory:read', 'View warehouses and stock transfers'),
+('inventory:write', 'Manage warehouses, count stock and transfer it');
+INSERT INTO role_permissions (role, permission) VALUES
+('ADMIN', 'inventory:read'),
+('ADMIN', 'inventory:write');
+-- Version: 2.8
+-- Description: Create tables stock_movements, stocktakes and stocktake_lines, open the ledger with the stock held
+CREATE TABLE stock_movements(
+    movement_id  BIGSERIAL,
+    warehouse_id UUID NOT NULL,
+    product_id   UUID NOT NULL,
+    variant_id   UUID NULL,
+    quantity     INT NOT NULL CHECK (quantity <> 0),
+    kind         TEXT NOT NULL CHECK (kind IN ('sale', 'refund', 'adjustment', 'transfer', 'receipt')),
+    reason       TEXT NOT NULL DEFAULT '',
+    reference_id UUID NULL,
+    note         TEXT NOT NULL DEFAULT '',
+    created_by   UUID NULL,
+    date_created TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(movement_id),
+    FOREIGN KEY(warehouse_id) REFERENCES warehouses(warehouse_id) ON DELETE RESTRICT,
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY(variant_id) REFERENCES product_variants(variant_id) ON DELETE CASCADE,
+    FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+CREATE INDEX stock_movements_level_idx ON stock_movements(warehouse_id, product_id, variant_id);
+CREATE INDEX stock_movements_product_id_idx ON stock_movements(product_id);
+INSERT INTO stock_movements (warehouse_id, product_id, variant_id, quantity, kind, reason, date_created)
+SELECT warehouse_id, product_id, variant_id, quantity, 'adjustment', 'opening', date_updated FROM stock_levels WHERE quantity > 0;
+CREATE TABLE stocktakes(
+    stocktake_id UUID,
+    warehouse_id UUID NOT NULL,
+    status       TEXT NOT NULL CHECK (status IN ('open', 'posted', 'cancelled')),
+    note         TEXT NOT NULL DEFAULT '',
+    created_by   UUID NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(stocktake_id),
+    FOREIGN KEY(warehouse_id) REFERENCES warehouses(warehouse_id) ON DELETE RESTRICT,
+    FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE RESTRICT
+);
+CREATE INDEX stocktakes_status_idx ON stocktakes(status);
+CREATE TABLE stocktake_lines(
+    stocktake_id UUID NOT NULL,
+    product_id   UUID NOT NULL,
+    variant_id   UUID NULL,
+    counted      INT NOT NULL CHECK (counted >= 0),
+    expected     INT NULL,
+    variance     INT NULL,
+
+    FOREIGN KEY(stocktake_id) REFERENCES stocktakes(stocktake_id) ON DELETE CASCADE,
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE RESTRICT,
+    FOREIGN KEY(variant_id) REFERENCES product_variants(variant_id) ON DELETE RESTRICT
+);
+CREATE UNIQUE INDEX stocktake_lines_line_idx ON stocktake_lines(stocktake_id, product_id, COALESCE(variant_id, '00000000-0000-0000-0000-000000000000'));
+-- Version: 2.9
+-- Description: Add reorder points to products and create table low_stock_alerts
+ALTER TABLE products ADD COLUMN reorder_point INT NOT NULL DEFAULT 0 CHECK (reorder_point >= 0);
+CREATE TABLE low_stock_alerts(
+    product_id   UUID,
+    date_created TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(product_id),
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+-- Version: 3.0
+-- Description: Create tables suppliers, purchase_orders and purchase_order_lines, track the purchase cost of products and of the items sold
+CREATE TABLE suppliers(
+    supplier_id  UUID,
+    name         TEXT NOT NULL UNIQUE,
+    email        TEXT NOT NULL DEFAULT '',
+    phone        TEXT NOT NULL DEFAULT '',
+    currency     CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(supplier_id)
+);
+CREATE TABLE purchase_orders(
+    purchase_order_id UUID,
+    supplier_id       UUID NOT NULL,
+    warehouse_id      UUID NOT NULL,
+    status            TEXT NOT NULL CHECK (status IN ('draft', 'ordered', 'partially_received', 'received', 'closed', 'cancelled')),
+    total             INT NOT NULL CHECK (total >= 0),
+    currency          CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    expected_date     TIMESTAMP NULL,
+    note              TEXT NOT NULL DEFAULT '',
+    created_by        UUID NOT NULL,
+    date_created      TIMESTAMP NOT NULL,
+    date_updated      TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(purchase_order_id),
+    FOREIGN KEY(supplier_id) REFERENCES suppliers(supplier_id) ON DELETE RESTRICT,
+    FOREIGN KEY(warehouse_id) REFERENCES warehouses(warehouse_id) ON DELETE RESTRICT,
+    FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE RESTRICT
+);
+CREATE INDEX purchase_orders_status_idx ON purchase_orders(status);
+CREATE INDEX purchase_orders_supplier_id_idx ON purchase_orders(supplier_id);
+CREATE TABLE purchase_order_lines(
+    purchase_order_id UUID NOT NULL,
+    line              INT NOT NULL,
+    product_id        UUID NOT NULL,
+    variant_id        UUID NULL,
+    quantity          INT NOT NULL CHECK (quantity > 0),
+    received          INT NOT NULL DEFAULT 0 CHECK (received >= 0 AND received <= quantity),
+    unit_cost         INT NOT NULL CHECK (unit_cost >= 0),
+    total             INT NOT NULL CHECK (total >= 0),
+    expected_date     TIMESTAMP NULL,
+
+    PRIMARY KEY(purchase_order_id, line),
+    FOREIGN KEY(purchase_order_id) REFERENCES purchase_orders(purchase_order_id) ON DELETE CASCADE,
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE RESTRICT,
+    FOREIGN KEY(variant_id) REFERENCES product_variants(variant_id) ON DELETE RESTRICT
+);
+ALTER TABLE products ADD COLUMN purchase_cost INT NOT NULL DEFAULT 0 CHECK (purchase_cost >= 0);
+ALTER TABLE order_items ADD COLUMN unit_cost INT NOT NULL DEFAULT 0 CHECK (unit_cost >= 0);
+-- Version: 3.1
+-- Description: Create tables coupons, promotions and order_item_discounts, record the discounts of orders and their items
+CREATE TABLE coupons(
+    coupon_id    UUID,
+    code         TEXT NOT NULL UNIQUE,
+    kind         TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    value        INT NOT NULL CHECK (value > 0),
+    currency     CHAR(3) NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    usage_limit  INT NULL CHECK (usage_limit > 0),
+    used         INT NOT NULL DEFAULT 0 CHECK (used >= 0 AND (usage_limit IS NULL OR used <= usage_limit)),
+    expires_at   TIMESTAMP NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(coupon_id)
+);
+CREATE TABLE promotions(
+    promotion_id UUID,
+    name         TEXT NOT NULL,
+    kind         TEXT NOT NULL CHECK (kind IN ('percent', 'fixed', 'buy_x_get_y')),
+    value        INT NOT NULL CHECK (value >= 0),
+    currency     CHAR(3) NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    buy_quantity INT NOT NULL DEFAULT 0 CHECK (buy_quantity >= 0),
+    get_quantity INT NOT NULL DEFAULT 0 CHECK (get_quantity >= 0),
+    product_id   UUID NULL,
+    category_id  UUID NULL,
+    starts_at    TIMESTAMP NOT NULL,
+    ends_at      TIMESTAMP NOT NULL,
+    date_created TIMESTAMP NOT NULL,
+    date_updated TIMESTAMP NOT NULL,
+
+    PRIMARY KEY(promotion_id),
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY(category_id) REFERENCES categories(category_id) ON DELETE CASCADE,
+    CHECK ((product_id IS NULL) <> (category_id IS NULL)),
+    CHECK (ends_at > starts_at)
+);
+CREATE INDEX promotions_period_idx ON promotions(starts_at, ends_at);
+ALTER TABLE orders ADD COLUMN discount INT NOT NULL DEFAULT 0 CHECK (discount >= 0);
+ALTER TABLE orders ADD COLUMN coupon_id UUID NULL REFERENCES coupons(coupon_id) ON DELETE RESTRICT;
+ALTER TABLE order_items ADD COLUMN discount INT NOT NULL DEFAULT 0 CHECK (discount >= 0);
+CREATE TABLE order_item_discounts(
+    order_id     UUID NOT NULL,
+    line         INT NOT NULL,
+    promotion_id UUID NULL,
+    coupon_id    UUID NULL,
+    amount       INT NOT NULL CHECK (amount > 0),
+
+    UNIQUE(order_id, line, promotion_id),
+    UNIQUE(order_id, line, coupon_id),
+    FOREIGN KEY(order_id, line) REFERENCES order_items(order_id, line) ON DELETE CASCADE,
+    FOREIGN KEY(promotion_id) REFERENCES promotions(promotion_id) ON DELETE RESTRICT,
+    FOREIGN KEY(coupon_id) REFERENCES coupons(coupon_id) ON DELETE RESTRICT,
+    CHECK ((promotion_id IS NULL) <> (coupon_id IS NULL))
+);
+CREATE INDEX order_item_discounts_promotion_id_idx ON order_item_discounts(promotion_id);
+CREATE INDEX order_item_discounts_coupon_id_idx ON order_item_discounts(coupon_id);
+INSERT INTO permissions (name, description) VALUES
+('promotions:read', 'View coupons and promotions'),
+('promotions:write', 'Manage coupons and promotions');
+INSERT INTO role_permissions (role, permission) VALUES
+('ADMIN', 'promotions:read'),
+('ADMIN', 'promotions:write');
+-- Version: 3.2
+-- Description: Index login_failures by last failure to prune them
+CREATE INDEX login_failures_last_failure_idx ON login_failures(last_failure);
+-- Version: 3.3
+-- Description: Allow refund items returning goods for nothing
+ALTER TABLE refund_items DROP CONSTRAINT refund_items_amount_check;
+ALTER TABLE refund_items ADD CONSTRAINT refund_items_amount_check CHECK (amount >= 0 AND (amount > 0 OR quantity > 0));
+-- Version: 3.4
+-- Description: Record who created stock documents without tying them to users, api keys create them too
+ALTER TABLE transfers DROP CONSTRAINT transfers_created_by_fkey;
+ALTER TABLE transfers ALTER COLUMN created_by TYPE TEXT;
+ALTER TABLE stock_movements DROP CONSTRAINT stock_movements_created_by_fkey;
+ALTER TABLE stock_movements ALTER COLUMN created_by TYPE TEXT;
+ALTER TABLE stocktakes DROP CONSTRAINT stocktakes_created_by_fkey;
+ALTER TABLE stocktakes ALTER COLUMN created_by TYPE TEXT;
+ALTER TABLE purchase_orders DROP CONSTRAINT purchase_orders_created_by_fkey;
+ALTER TABLE purchase_orders ALTER COLUMN created_by TYPE TEXT;
@@ -0,0 +1,160 @@
+-- Version: 1.1
+-- Description: Drop table users
+DROP TABLE IF EXISTS users;
+
+-- Version: 1.2
+-- Description: Drop table products
+DROP TABLE IF EXISTS products;
+
+-- Version: 1.3
+-- Description: Drop table sales
+DROP TABLE IF EXISTS sales;
+
+-- Version: 1.4
+-- Description: Remove enabled flag from users
+ALTER TABLE users DROP COLUMN IF EXISTS enabled;
+
+-- Version: 1.5
+-- Description: Rename date_updated back to date_update
+ALTER TABLE users RENAME COLUMN date_updated TO date_update;
+ALTER TABLE products RENAME COLUMN date_updated TO date_update;
+ALTER TABLE sales RENAME COLUMN date_updated TO date_update;
+
+-- Version: 1.6
+-- Description: Drop table password_resets
+DROP TABLE IF EXISTS password_resets;
+
+-- Version: 1.7
+-- Description: Drop tables login_failures and audit_events
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS login_failures;
+
+-- Version: 1.8
+-- Description: Drop tables user_mfa and mfa_recovery_codes
+DROP TABLE IF EXISTS mfa_recovery_codes;
+DROP TABLE IF EXISTS user_mfa;
+
+-- Version: 1.9
+-- Description: Drop tables permissions, roles and role_permissions
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS permissions;
+
+-- Version: 2.0
+-- Description: Drop table api_keys
+DROP TABLE IF EXISTS api_keys;
+
+-- Version: 2.1
+-- Description: Turn one line orders back into sales, drop tables order_items and orders
+CREATE TABLE sales(
+    sale_id      UUID,
+    product_id   UUID,
+    user_id      UUID,
+    paid         INT,
+    quantity     INT,
+    date_created TIMESTAMP,
+    date_updated TIMESTAMP,
+
+    PRIMARY KEY(sale_id),
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY(product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+INSERT INTO sales (sale_id, product_id, user_id, paid, quantity, date_created, date_updated)
+SELECT o.order_id, i.product_id, o.customer_id, o.total, i.quantity, o.date_created, o.date_updated
+FROM orders AS o JOIN order_items AS i ON i.order_id = o.order_id
+WHERE (SELECT COUNT(*) FROM order_items AS c WHERE c.order_id = o.order_id) = 1;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+
+-- Version: 2.2
+-- Description: Drop tables refund_items and refunds, drop refunded totals from order_items
+DROP TABLE IF EXISTS refund_items;
+DROP TABLE IF EXISTS refunds;
+ALTER TABLE order_items DROP CONSTRAINT IF EXISTS order_items_refunded_check;
+ALTER TABLE order_items DROP COLUMN IF EXISTS refunded_amount;
+ALTER TABLE order_items DROP COLUMN IF EXISTS refunded_quantity;
+
+-- Version: 2.3
+-- Description: Drop the currency of amounts from products, orders and refunds
+ALTER TABLE refunds DROP COLUMN IF EXISTS currency;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE products DROP COLUMN IF EXISTS currency;
+
+-- Version: 2.4
+-- Description: Drop tables product_tags and categories, drop the category of products
+DROP TABLE IF EXISTS product_tags;
+DROP INDEX IF EXISTS products_category_id_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS categories;
+
+-- Version: 2.5
+-- Description: Drop the text search vector of products
+DROP INDEX IF EXISTS products_search_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS search;
+
+-- Version: 2.6
+-- Description: Drop table product_variants and the variants of order and refund lines
+ALTER TABLE refund_items DROP COLUMN IF EXISTS variant_id;
+ALTER TABLE order_items DROP COLUMN IF EXISTS variant_id;
+DROP TABLE IF EXISTS product_variants;
+
+-- Version: 2.7
+-- Description: Drop tables transfer_items, transfers, stock_levels and warehouses, and the warehouse of orders
+DELETE FROM role_permissions WHERE permission IN ('inventory:read', 'inventory:write');
+DELETE FROM permissions WHERE name IN ('inventory:read', 'inventory:write');
+ALTER TABLE orders DROP COLUMN IF EXISTS warehouse_id;
+DROP TABLE IF EXISTS transfer_items;
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS stock_levels;
+DROP TABLE IF EXISTS warehouses;
+
+-- Version: 2.8
+-- Description: Drop tables stocktake_lines, stocktakes and stock_movements
+DROP TABLE IF EXISTS stocktake_lines;
+DROP TABLE IF EXISTS stocktakes;
+DROP TABLE IF EXISTS stock_movements;
+
+-- Version: 2.9
+-- Description: Drop table low_stock_alerts and the reorder points of products
+DROP TABLE IF EXISTS low_stock_alerts;
+ALTER TABLE products DROP COLUMN IF EXISTS reorder_point;
+
+-- Version: 3.0
+-- Description: Drop tables purchase_order_lines, purchase_orders and suppliers, and the purchase costs
+ALTER TABLE order_items DROP COLUMN IF EXISTS unit_cost;
+ALTER TABLE products DROP COLUMN IF EXISTS purchase_cost;
+DROP TABLE IF EXISTS purchase_order_lines;
+DROP TABLE IF EXISTS purchase_orders;
+DROP TABLE IF EXISTS suppliers;
+
+-- Version: 3.1
+-- Description: Drop tables order_item_discounts, promotions and coupons, and the discounts of orders
+DELETE FROM role_permissions WHERE permission IN ('promotions:read', 'promotions:write');
+DELETE FROM permissions WHERE name IN ('promotions:read', 'promotions:write');
+DROP TABLE IF EXISTS order_item_discounts;
+ALTER TABLE order_items DROP COLUMN IF EXISTS discount;
+ALTER TABLE orders DROP COLUMN IF EXISTS coupon_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS discount;
+DROP TABLE IF EXISTS promotions;
+DROP TABLE IF EXISTS coupons;
+
+-- Version: 3.2
+-- Description: Drop the last failure index of login_failures
+DROP INDEX IF EXISTS login_failures_last_failure_idx;
+
+-- Version: 3.3
+-- Description: Refuse refund items without an amount again, the ones already given are left alone
+ALTER TABLE refund_items DROP CONSTRAINT IF EXISTS refund_items_amount_check;
+ALTER TABLE refund_items ADD CONSTRAINT refund_items_amount_check CHECK (amount > 0) NOT VALID;
+
+-- Version: 3.4
+-- Description: Tie who created stock documents to users again, the ones created by api keys are left alone
+ALTER TABLE transfers ALTER COLUMN created_by TYPE UUID USING created_by::uuid;
+ALTER TABLE transfers ADD CONSTRAINT transfers_created_by_fkey FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE RESTRICT NOT VALID;
+UPDATE stock_movements SET created_by = NULL WHERE created_by NOT IN (SELECT user_id::text FROM users);
+ALTER TABLE stock_movements ALTER COLUMN created_by TYPE UUID USING created_by::uuid;
+ALTER TABLE stock_movements ADD CONSTRAINT stock_movements_created_by_fkey FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE SET NULL;
+ALTER TABLE stocktakes ALTER COLUMN created_by TYPE UUID USING created_by::uuid;
+ALTER TABLE stocktakes ADD CONSTRAINT stocktakes_created_by_fkey FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE RESTRICT NOT VALID;
+ALTER TABLE purchase_orders ALTER COLUMN created_by TYPE UUID USING created_by::uuid;
+ALTER TABLE purchase_orders ADD CONSTRAINT purchase_orders_created_by_fkey FOREIGN KEY(created_by) REFERENCES users(user_id) ON DELETE RESTRICT NOT VALID;
@@ -1,16 +1,56 @@
-INSERT INTO users (user_id, name, email, roles, password_hash, department, enabled, date_created, date_updated) VALUES
-('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', NULL, true, '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
-('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', NULL, true, '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+INSERT INTO users (user_id, name, email, roles, password_hash, enabled, date_created, date_updated) VALUES
+('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', true, '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', true, '2019-03-24 00:00:00', '2019-03-24 00:00:00')
 ON CONFLICT DO NOTHING;
 -- ON CONFLICT DO NOTHING -> if data exists do nothing
 
-INSERT INTO products (product_id, user_id, name, cost, quantity, date_created, date_updated) VALUES
-('52af2580-428f-11ee-be56-0242ac120002', '5cf37266-3473-4006-984f-9325122678b7', 'Comic Books', 50, 42, '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
-('52af2968-428f-11ee-be56-0242ac120002', '45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'McDonalds Toys', 75, 120, '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+INSERT INTO categories (category_id, parent_id, name, slug, date_created, date_updated) VALUES
+('6f1c2b0e-7a3d-4c1e-9b1a-2d4e5f607181', NULL, 'Collectibles', 'collectibles', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+('6f1c2b0e-7a3d-4c1e-9b1a-2d4e5f607182', '6f1c2b0e-7a3d-4c1e-9b1a-2d4e5f607181', 'Comics', 'comics', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+('6f1c2b0e-7a3d-4c1e-9b1a-2d4e5f607183', '6f1c2b0e-7a3d-4c1e-9b1a-2d4e5f607181', 'Toys', 'toys', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
 ON CONFLICT DO NOTHING;
 
-INSERT INTO sales (sale_id, product_id, quantity, paid, date_created) VALUES
-('52af2a8a-428f-11ee-be56-0242ac120002', '52af2580-428f-11ee-be56-0242ac120002', 2, 100, '2019-03-24 00:00:00'),
-('52af2b7a-428f-11ee-be56-0242ac120002', '52af2968-428f-11ee-be56-0242ac120002', 5, 250, '2019-03-24 00:00:00'),
-('52af2c6a-428f-11ee-be56-0242ac120002', '52af2968-428f-11ee-be56-0242ac120002', 3, 225, '2019-03-24 00:00:00')
-ON CONFLICT DO NOTHING;
\ No newline at end of file
+INSERT INTO products (product_id, user_id, name, cost, purchase_cost, quantity, reorder_point, category_id, date_created, date_updated) VALUES
+('52af2580-428f-11ee-be56-0242ac120002', '5cf37266-3473-4006-984f-9325122678b7', 'Comic Books', 50, 30, 42, 10, '6f1c2b0e-7a3d-4c1e-9b1a-2d4e5f607182', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+('52af2968-428f-11ee-be56-0242ac120002', '45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'McDonalds Toys', 75, 40, 120, 25, '6f1c2b0e-7a3d-4c1e-9b1a-2d4e5f607183', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO stock_levels (warehouse_id, product_id, variant_id, quantity, date_updated) VALUES
+('0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6', '52af2580-428f-11ee-be56-0242ac120002', NULL, 42, '2019-03-24 00:00:00'),
+('0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6', '52af2968-428f-11ee-be56-0242ac120002', NULL, 120, '2019-03-24 00:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO stock_movements (warehouse_id, product_id, variant_id, quantity, kind, reason, date_created)
+SELECT l.warehouse_id, l.product_id, l.variant_id, l.quantity, 'adjustment', 'opening', l.date_updated
+FROM stock_levels AS l
+WHERE l.quantity > 0 AND NOT EXISTS (
+    SELECT 1 FROM stock_movements AS m
+    WHERE m.warehouse_id = l.warehouse_id AND m.product_id = l.product_id AND
+    COALESCE(m.variant_id, '00000000-0000-0000-0000-000000000000') = COALESCE(l.variant_id, '00000000-0000-0000-0000-000000000000')
+);
+
+INSERT INTO product_tags (product_id, tag) VALUES
+('52af2580-428f-11ee-be56-0242ac120002', 'paper'),
+('52af2968-428f-11ee-be56-0242ac120002', 'plastic'),
+('52af2968-428f-11ee-be56-0242ac120002', 'kids')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO orders (order_id, customer_id, warehouse_id, total, date_created, date_updated) VALUES
+('52af2a8a-428f-11ee-be56-0242ac120002', '45b5fbd3-755f-4379-8f07-a58d4a30fa2f', '0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6', 100, '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+('52af2b7a-428f-11ee-be56-0242ac120002', '45b5fbd3-755f-4379-8f07-a58d4a30fa2f', '0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6', 375, '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+('52af2c6a-428f-11ee-be56-0242ac120002', '5cf37266-3473-4006-984f-9325122678b7', '0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6', 225, '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO order_items (order_id, line, product_id, quantity, unit_price, unit_cost, total) VALUES
+('52af2a8a-428f-11ee-be56-0242ac120002', 1, '52af2580-428f-11ee-be56-0242ac120002', 2, 50, 30, 100),
+('52af2b7a-428f-11ee-be56-0242ac120002', 1, '52af2968-428f-11ee-be56-0242ac120002', 5, 75, 40, 375),
+('52af2c6a-428f-11ee-be56-0242ac120002', 1, '52af2968-428f-11ee-be56-0242ac120002', 3, 75, 40, 225)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO suppliers (supplier_id, name, email, phone, currency, date_created, date_updated) VALUES
+('7d3f1c2a-5b8e-4f60-9a1d-3e2c4b5a6f70', 'Gopher Wholesale', 'orders@gopher-wholesale.example.com', '', 'USD', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO coupons (coupon_id, code, kind, value, currency, usage_limit, used, expires_at, date_created, date_updated) VALUES
+('3a9e6c1d-2f4b-4d8a-b7e5-9c0f1a2b3c4d', 'WELCOME10', 'percent', 10, NULL, NULL, 0, NULL, '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+ON CONFLICT DO NOTHING;
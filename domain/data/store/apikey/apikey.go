@@ -0,0 +1,38 @@
+package apikey
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// Key is an API key issued to a client that has no user to log in as.
+// Clients send the prefix along with the secret, only the hash of the
+// secret is stored.
+type Key struct {
+	ID           string         `db:"key_id" json:"id"`
+	Name         string         `db:"name" json:"name"`
+	Prefix       string         `db:"prefix" json:"prefix"`
+	Hash         string         `db:"secret_hash" json:"-"`
+	Scopes       pq.StringArray `db:"scopes" json:"scopes"`
+	CreatedBy    string         `db:"created_by" json:"created_by"`
+	DateExpires  *time.Time     `db:"date_expires" json:"date_expires"`
+	DateLastUsed *time.Time     `db:"date_last_used" json:"date_last_used"`
+	DateRevoked  *time.Time     `db:"date_revoked" json:"date_revoked"`
+	DateCreated  time.Time      `db:"date_created" json:"date_created"`
+}
+
+// Valid reports whether the key can be used at now.
+func (k Key) Valid(now time.Time) bool {
+	if k.DateRevoked != nil {
+		return false
+	}
+	return k.DateExpires == nil || k.DateExpires.After(now)
+}
+
+// NewKey is what we require from admins when issuing a key. Scopes are
+// permission names, a key without an expiry is valid until revoked.
+type NewKey struct {
+	Name        string     `json:"name" validate:"required"`
+	Scopes      []string   `json:"scopes" validate:"required"`
+	DateExpires *time.Time `json:"date_expires"`
+}
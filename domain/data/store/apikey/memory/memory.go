@@ -0,0 +1,137 @@
+// Package memory provides a thread safe in memory implementation of the
+// API key store with the same semantics as the postgres store.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/apikey"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu   sync.Mutex
+	keys map[string]apikey.Key
+}
+
+func NewStore() *Store {
+	return &Store{
+		keys: make(map[string]apikey.Key),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, k apikey.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.keys {
+		if other.ID == k.ID || other.Prefix == k.Prefix {
+			return database.ErrDuplicatedEntry
+		}
+	}
+
+	s.keys[k.ID] = clone(k)
+	return nil
+}
+
+func (s *Store) Query(ctx context.Context) ([]apikey.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]apikey.Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, clone(k))
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].DateCreated.After(keys[j].DateCreated)
+	})
+	return keys, nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, keyID string) (apikey.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[keyID]
+	if !ok {
+		return apikey.Key{}, database.ErrNotFound
+	}
+	return clone(k), nil
+}
+
+func (s *Store) QueryByPrefix(ctx context.Context, prefix string) (apikey.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.keys {
+		if k.Prefix == prefix {
+			return clone(k), nil
+		}
+	}
+	return apikey.Key{}, database.ErrNotFound
+}
+
+func (s *Store) Expire(ctx context.Context, keyID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[keyID]
+	if !ok {
+		return nil
+	}
+
+	if k.DateExpires == nil || k.DateExpires.After(at) {
+		k.DateExpires = &at
+		s.keys[keyID] = k
+	}
+	return nil
+}
+
+func (s *Store) Revoke(ctx context.Context, keyID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[keyID]
+	if !ok || k.DateRevoked != nil {
+		return nil
+	}
+
+	k.DateRevoked = &now
+	s.keys[keyID] = k
+	return nil
+}
+
+func (s *Store) Touch(ctx context.Context, keyID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[keyID]
+	if !ok {
+		return nil
+	}
+
+	k.DateLastUsed = &now
+	s.keys[keyID] = k
+	return nil
+}
+
+// clone makes sure callers never share the scopes or the dates with the
+// stored key.
+func clone(k apikey.Key) apikey.Key {
+	k.Scopes = append([]string(nil), k.Scopes...)
+	k.DateExpires = cloneTime(k.DateExpires)
+	k.DateLastUsed = cloneTime(k.DateLastUsed)
+	k.DateRevoked = cloneTime(k.DateRevoked)
+	return k
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
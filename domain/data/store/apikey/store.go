@@ -0,0 +1,148 @@
+// Package apikey persists the API keys issued to service clients.
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+	"time"
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create stores the key.
+func (s Store) Create(ctx context.Context, k Key) error {
+	q := `INSERT INTO api_keys
+	(key_id, name, prefix, secret_hash, scopes, created_by, date_expires, date_last_used, date_revoked, date_created)
+	VALUES
+	(:key_id, :name, :prefix, :secret_hash, :scopes, :created_by, :date_expires, :date_last_used, :date_revoked, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, k); err != nil {
+		return fmt.Errorf("inserting api key %w", err)
+	}
+	return nil
+}
+
+// Query returns every key, revoked ones included, latest first.
+func (s Store) Query(ctx context.Context) ([]Key, error) {
+	q := `SELECT * FROM api_keys ORDER BY date_created DESC`
+
+	var keys []Key
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &keys); err != nil {
+		return nil, fmt.Errorf("selecting api keys %w", err)
+	}
+	return keys, nil
+}
+
+// QueryByID returns the key with the id.
+func (s Store) QueryByID(ctx context.Context, keyID string) (Key, error) {
+	data := struct {
+		KeyID string `db:"key_id"`
+	}{
+		KeyID: keyID,
+	}
+
+	q := `SELECT * FROM api_keys WHERE key_id = :key_id`
+
+	var k Key
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &k); err != nil {
+		if err == database.ErrNotFound {
+			return Key{}, database.ErrNotFound
+		}
+		return Key{}, fmt.Errorf("selecting api key %s %w", keyID, err)
+	}
+	return k, nil
+}
+
+// QueryByPrefix returns the key with the prefix.
+func (s Store) QueryByPrefix(ctx context.Context, prefix string) (Key, error) {
+	data := struct {
+		Prefix string `db:"prefix"`
+	}{
+		Prefix: prefix,
+	}
+
+	q := `SELECT * FROM api_keys WHERE prefix = :prefix`
+
+	var k Key
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &k); err != nil {
+		if err == database.ErrNotFound {
+			return Key{}, database.ErrNotFound
+		}
+		return Key{}, fmt.Errorf("selecting api key by prefix %s %w", prefix, err)
+	}
+	return k, nil
+}
+
+// Expire makes the key stop working at the time, unless it expires
+// sooner already.
+func (s Store) Expire(ctx context.Context, keyID string, at time.Time) error {
+	data := struct {
+		KeyID string    `db:"key_id"`
+		At    time.Time `db:"at"`
+	}{
+		KeyID: keyID,
+		At:    at,
+	}
+
+	q := `
+	UPDATE api_keys
+	SET date_expires = :at
+	WHERE key_id = :key_id AND (date_expires IS NULL OR date_expires > :at)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("expiring api key %s %w", keyID, err)
+	}
+	return nil
+}
+
+// Revoke stops the key from working right away.
+func (s Store) Revoke(ctx context.Context, keyID string, now time.Time) error {
+	data := struct {
+		KeyID string    `db:"key_id"`
+		Now   time.Time `db:"now"`
+	}{
+		KeyID: keyID,
+		Now:   now,
+	}
+
+	q := `
+	UPDATE api_keys
+	SET date_revoked = :now
+	WHERE key_id = :key_id AND date_revoked IS NULL`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("revoking api key %s %w", keyID, err)
+	}
+	return nil
+}
+
+// Touch records that the key was used.
+func (s Store) Touch(ctx context.Context, keyID string, now time.Time) error {
+	data := struct {
+		KeyID string    `db:"key_id"`
+		Now   time.Time `db:"now"`
+	}{
+		KeyID: keyID,
+		Now:   now,
+	}
+
+	q := `UPDATE api_keys SET date_last_used = :now WHERE key_id = :key_id`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("touching api key %s %w", keyID, err)
+	}
+	return nil
+}
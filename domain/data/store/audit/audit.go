@@ -0,0 +1,16 @@
+package audit
+
+import (
+	"time"
+)
+
+// Event records a security relevant action. Actor is who did it, empty
+// for the service itself, and Subject what it was done to.
+type Event struct {
+	ID          string    `db:"event_id" json:"id"`
+	Action      string    `db:"action" json:"action"`
+	Actor       string    `db:"actor" json:"actor"`
+	Subject     string    `db:"subject" json:"subject"`
+	Detail      string    `db:"detail" json:"detail"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
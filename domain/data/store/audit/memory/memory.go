@@ -0,0 +1,44 @@
+// Package memory provides a thread safe in memory implementation of the
+// audit store with the same semantics as the postgres store.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/audit"
+	"sort"
+	"sync"
+)
+
+type Store struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+func (s *Store) Create(ctx context.Context, e audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *Store) QueryBySubject(ctx context.Context, subject string) ([]audit.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []audit.Event
+	for _, e := range s.events {
+		if e.Subject == subject {
+			events = append(events, e)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].DateCreated.After(events[j].DateCreated)
+	})
+	return events, nil
+}
@@ -0,0 +1,59 @@
+// Package audit persists the audit trail of security relevant actions.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create appends the event to the trail.
+func (s Store) Create(ctx context.Context, e Event) error {
+	q := `INSERT INTO audit_events
+	(event_id, action, actor, subject, detail, date_created)
+	VALUES
+	(:event_id, :action, :actor, :subject, :detail, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, e); err != nil {
+		return fmt.Errorf("inserting audit event %w", err)
+	}
+	return nil
+}
+
+// QueryBySubject returns the events about the subject, latest first.
+func (s Store) QueryBySubject(ctx context.Context, subject string) ([]Event, error) {
+	data := struct {
+		Subject string `db:"subject"`
+	}{
+		Subject: subject,
+	}
+
+	q := `
+	SELECT *
+	FROM
+		audit_events
+	WHERE
+		subject = :subject
+	ORDER BY
+		date_created DESC`
+
+	var events []Event
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &events); err != nil {
+		return nil, fmt.Errorf("selecting audit events %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,54 @@
+package category
+
+import (
+	"strings"
+	"time"
+)
+
+// Category groups products for browsing. Categories form a tree, top
+// level categories have no parent. The slug names the category in urls.
+type Category struct {
+	ID          string    `db:"category_id" json:"id"`
+	ParentID    *string   `db:"parent_id" json:"parent_id"`
+	Name        string    `db:"name" json:"name"`
+	Slug        string    `db:"slug" json:"slug"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewCategory is what we require from admins when adding a category. The
+// slug is made from the name when left out.
+type NewCategory struct {
+	Name     string  `json:"name" validate:"required,max=100"`
+	Slug     string  `json:"slug" validate:"max=100"`
+	ParentID *string `json:"parent_id" validate:"omitempty,uuid"`
+}
+
+// UpdateCategory contains the fields of a category that can change. An
+// empty parent id moves the category to the top level.
+type UpdateCategory struct {
+	Name     *string `json:"name" validate:"omitempty,max=100"`
+	Slug     *string `json:"slug" validate:"omitempty,max=100"`
+	ParentID *string `json:"parent_id" validate:"omitempty,len=0|uuid"`
+}
+
+// Slugify turns a name into lower case words of letters and digits joined
+// by dashes, "Comic Books!" becomes "comic-books". Product tags follow the
+// same rule.
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,137 @@
+package category_test
+
+import (
+	"context"
+	"errors"
+	"service/domain/core/category"
+	categoryStore "service/domain/data/store/category"
+	"service/domain/data/store/category/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+func TestMemory(t *testing.T) {
+	categories(t, memory.NewStore())
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	categories(t, categoryStore.NewStore(logger, db))
+}
+
+func categories(t *testing.T, store category.Storer) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	// The slugs are random so the test does not clash with the seed.
+	newCategory := func(name string, parent *categoryStore.Category) categoryStore.Category {
+		t.Helper()
+
+		c := categoryStore.Category{
+			ID:          validate.GenerateUID(),
+			Name:        name,
+			Slug:        categoryStore.Slugify(name + " " + validate.GenerateUID()[:8]),
+			DateCreated: now,
+			DateUpdated: now,
+		}
+		if parent != nil {
+			c.ParentID = &parent.ID
+		}
+
+		if err := store.Create(ctx, c); err != nil {
+			t.Fatalf("\t%s\t Should be able to create category %s: %v", tests.Failed, name, err)
+		}
+		return c
+	}
+
+	t.Log("Given the need to file products under a tree of categories")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When walking the tree", testID)
+		{
+			games := newCategory("Games", nil)
+			board := newCategory("Board Games", &games)
+			chess := newCategory("Chess", &board)
+			video := newCategory("Video Games", &games)
+
+			ids, err := store.QueryDescendantIDs(ctx, games.ID)
+			if err != nil || len(ids) != 4 || ids[0] != games.ID || ids[3] != chess.ID {
+				t.Fatalf("\t%s\t Test %d Should list the category and every category below it, got %v %v", tests.Failed, testID, ids, err)
+			}
+			t.Logf("\t%s\t Test %d Should list the category and every category below it", tests.Succeeded, testID)
+
+			ids, err = store.QueryDescendantIDs(ctx, video.ID)
+			if err != nil || len(ids) != 1 {
+				t.Fatalf("\t%s\t Test %d Should list a leaf on its own, got %v %v", tests.Failed, testID, ids, err)
+			}
+
+			if _, err := store.QueryDescendantIDs(ctx, validate.GenerateUID()); !errors.Is(err, database.ErrNotFound) {
+				t.Fatalf("\t%s\t Test %d Should not find an unknown category, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should not find an unknown category", tests.Succeeded, testID)
+
+			got, err := store.QueryBySlug(ctx, board.Slug)
+			if err != nil || got.ID != board.ID || got.ParentID == nil || *got.ParentID != games.ID {
+				t.Fatalf("\t%s\t Test %d Should find a category by slug, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should find a category by slug", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When changing categories", testID)
+		{
+			toys := newCategory("Toys", nil)
+			lego := newCategory("Lego", nil)
+
+			dup := categoryStore.Category{ID: validate.GenerateUID(), Name: "Toys", Slug: toys.Slug, DateCreated: now, DateUpdated: now}
+			if err := store.Create(ctx, dup); !errors.Is(err, categoryStore.ErrUniqueSlug) {
+				t.Fatalf("\t%s\t Test %d Should refuse a slug in use, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse a slug in use", tests.Succeeded, testID)
+
+			lego.ParentID = &toys.ID
+			lego.Name = "Bricks"
+			if err := store.Update(ctx, lego); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to move a category: %v", tests.Failed, testID, err)
+			}
+
+			ids, err := store.QueryDescendantIDs(ctx, toys.ID)
+			if err != nil || len(ids) != 2 || ids[1] != lego.ID {
+				t.Fatalf("\t%s\t Test %d Should move the category below its new parent, got %v %v", tests.Failed, testID, ids, err)
+			}
+			t.Logf("\t%s\t Test %d Should move the category below its new parent", tests.Succeeded, testID)
+
+			toys.ParentID = &lego.ID
+			if err := store.Update(ctx, toys); !errors.Is(err, categoryStore.ErrCycle) {
+				t.Fatalf("\t%s\t Test %d Should refuse to move a category below its own child, got %v", tests.Failed, testID, err)
+			}
+
+			toys.ParentID = &toys.ID
+			if err := store.Update(ctx, toys); !errors.Is(err, categoryStore.ErrCycle) {
+				t.Fatalf("\t%s\t Test %d Should refuse to move a category below itself, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse to move a category below itself", tests.Succeeded, testID)
+			toys.ParentID = nil
+
+			if err := store.Delete(ctx, lego.ID); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to delete a category: %v", tests.Failed, testID, err)
+			}
+
+			if _, err := store.QueryByID(ctx, lego.ID); !errors.Is(err, database.ErrNotFound) {
+				t.Fatalf("\t%s\t Test %d Should delete the category, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should delete the category", tests.Succeeded, testID)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package memory provides a thread safe in memory implementation of the
+// category store with the same semantics as the postgres store.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/category"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+)
+
+type Store struct {
+	mu         sync.RWMutex
+	categories map[string]category.Category
+}
+
+func NewStore() *Store {
+	return &Store{
+		categories: make(map[string]category.Category),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, c category.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.categories[c.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+
+	if s.slugTaken(c) {
+		return category.ErrUniqueSlug
+	}
+
+	s.categories[c.ID] = clone(c)
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, c category.Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.categories[c.ID]; !ok {
+		return nil
+	}
+
+	if s.slugTaken(c) {
+		return category.ErrUniqueSlug
+	}
+
+	for id := c.ParentID; id != nil; id = s.categories[*id].ParentID {
+		if *id == c.ID {
+			return category.ErrCycle
+		}
+		if _, ok := s.categories[*id]; !ok {
+			break
+		}
+	}
+
+	s.categories[c.ID] = clone(c)
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, categoryID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.categories, categoryID)
+	return nil
+}
+
+func (s *Store) Query(ctx context.Context) ([]category.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cats := make([]category.Category, 0, len(s.categories))
+	for _, c := range s.categories {
+		cats = append(cats, clone(c))
+	}
+
+	sort.Slice(cats, func(i, j int) bool {
+		if cats[i].Name != cats[j].Name {
+			return cats[i].Name < cats[j].Name
+		}
+		return cats[i].ID < cats[j].ID
+	})
+	return cats, nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, categoryID string) (category.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.categories[categoryID]
+	if !ok {
+		return category.Category{}, database.ErrNotFound
+	}
+	return clone(c), nil
+}
+
+func (s *Store) QueryBySlug(ctx context.Context, slug string) (category.Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, c := range s.categories {
+		if c.Slug == slug {
+			return clone(c), nil
+		}
+	}
+	return category.Category{}, database.ErrNotFound
+}
+
+// QueryDescendantIDs walks the tree a level at a time, like the recursive
+// query of the postgres store.
+func (s *Store) QueryDescendantIDs(ctx context.Context, categoryID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.categories[categoryID]; !ok {
+		return nil, database.ErrNotFound
+	}
+
+	ids := []string{categoryID}
+	level := []string{categoryID}
+	for len(level) > 0 {
+		var next []string
+		for _, c := range s.categories {
+			if c.ParentID == nil {
+				continue
+			}
+			for _, id := range level {
+				if *c.ParentID == id {
+					next = append(next, c.ID)
+					break
+				}
+			}
+		}
+		sort.Strings(next)
+		ids = append(ids, next...)
+		level = next
+	}
+	return ids, nil
+}
+
+// slugTaken reports whether another category holds the slug of c.
+func (s *Store) slugTaken(c category.Category) bool {
+	for _, o := range s.categories {
+		if o.ID != c.ID && o.Slug == c.Slug {
+			return true
+		}
+	}
+	return false
+}
+
+// clone makes sure callers never share the parent id with the stored
+// category.
+func clone(c category.Category) category.Category {
+	if c.ParentID != nil {
+		id := *c.ParentID
+		c.ParentID = &id
+	}
+	return c
+}
@@ -0,0 +1,223 @@
+// Package category persists the tree of categories products are filed
+// under.
+package category
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+)
+
+// Set of error variables for the category store.
+var (
+	// ErrUniqueSlug is returned when a category is given a slug another
+	// category already holds.
+	ErrUniqueSlug = errors.New("category slug is not unique")
+
+	// ErrCycle is returned when a category is moved below itself or any
+	// category under it.
+	ErrCycle = errors.New("category can not be moved below itself")
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create stores the category.
+func (s Store) Create(ctx context.Context, c Category) error {
+	q := `INSERT INTO categories
+	(category_id, parent_id, name, slug, date_created, date_updated)
+	VALUES
+	(:category_id, :parent_id, :name, :slug, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, c); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueSlug
+		}
+		return fmt.Errorf("inserting category %w", err)
+	}
+	return nil
+}
+
+// Update replaces the parent, name and slug of the category. The parent
+// is checked in the same statement that sets it, with the categories
+// locked against other changes, so concurrent moves can not put a category
+// below itself. It fails with ErrCycle when the parent is the category or
+// any category under it.
+func (s Store) Update(ctx context.Context, c Category) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return fmt.Errorf("locking categories %w", err)
+	}
+
+	q := `
+	UPDATE categories
+	SET parent_id = :parent_id, name = :name, slug = :slug, date_updated = :date_updated
+	WHERE
+		category_id = :category_id AND
+		NOT EXISTS (
+			WITH RECURSIVE tree AS (
+				SELECT category_id FROM categories WHERE category_id = :category_id
+				UNION
+				SELECT c.category_id FROM categories AS c JOIN tree AS t ON c.parent_id = t.category_id
+			)
+			SELECT 1 FROM tree WHERE category_id = :parent_id
+		)`
+
+	res, err := tx.NamedExecContext(ctx, q, c)
+	if err != nil {
+		if database.IsDuplicated(err) {
+			return ErrUniqueSlug
+		}
+		return fmt.Errorf("updating category %s %w", c.ID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("updating category %s %w", c.ID, err)
+	}
+
+	// Nothing is updated when the parent is below the category, or when
+	// the category is gone, which is left to the next read to find out.
+	if n == 0 && c.ParentID != nil {
+		var exists bool
+		if err := tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM categories WHERE category_id = $1)`, c.ID); err != nil {
+			return fmt.Errorf("selecting category %s %w", c.ID, err)
+		}
+		if exists {
+			return fmt.Errorf("category %s parent %s: %w", c.ID, *c.ParentID, ErrCycle)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// Delete removes the category, its products are left without one.
+func (s Store) Delete(ctx context.Context, categoryID string) error {
+	data := struct {
+		CategoryID string `db:"category_id"`
+	}{
+		CategoryID: categoryID,
+	}
+
+	q := `DELETE FROM categories WHERE category_id = :category_id`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting category %s %w", categoryID, err)
+	}
+	return nil
+}
+
+// Query returns every category ordered by name.
+func (s Store) Query(ctx context.Context) ([]Category, error) {
+	q := `SELECT * FROM categories ORDER BY name, category_id`
+
+	var cats []Category
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &cats); err != nil {
+		return nil, fmt.Errorf("selecting categories %w", err)
+	}
+	return cats, nil
+}
+
+// QueryByID returns the category with the id.
+func (s Store) QueryByID(ctx context.Context, categoryID string) (Category, error) {
+	data := struct {
+		CategoryID string `db:"category_id"`
+	}{
+		CategoryID: categoryID,
+	}
+
+	q := `SELECT * FROM categories WHERE category_id = :category_id`
+
+	var c Category
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &c); err != nil {
+		if err == database.ErrNotFound {
+			return Category{}, database.ErrNotFound
+		}
+		return Category{}, fmt.Errorf("selecting category %s %w", categoryID, err)
+	}
+	return c, nil
+}
+
+// QueryBySlug returns the category with the slug.
+func (s Store) QueryBySlug(ctx context.Context, slug string) (Category, error) {
+	data := struct {
+		Slug string `db:"slug"`
+	}{
+		Slug: slug,
+	}
+
+	q := `SELECT * FROM categories WHERE slug = :slug`
+
+	var c Category
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &c); err != nil {
+		if err == database.ErrNotFound {
+			return Category{}, database.ErrNotFound
+		}
+		return Category{}, fmt.Errorf("selecting category %s %w", slug, err)
+	}
+	return c, nil
+}
+
+// QueryDescendantIDs returns the id of the category followed by the ids of
+// every category below it, however deep. A category met again on its own
+// path is not followed, so a cycle left in the data can not hang the
+// query.
+func (s Store) QueryDescendantIDs(ctx context.Context, categoryID string) ([]string, error) {
+	data := struct {
+		CategoryID string `db:"category_id"`
+	}{
+		CategoryID: categoryID,
+	}
+
+	q := `
+	WITH RECURSIVE tree AS (
+		SELECT category_id, 0 AS depth, ARRAY[category_id] AS path FROM categories WHERE category_id = :category_id
+		UNION ALL
+		SELECT c.category_id, t.depth + 1, t.path || c.category_id
+		FROM categories AS c JOIN tree AS t ON c.parent_id = t.category_id
+		WHERE c.category_id <> ALL(t.path)
+	)
+	SELECT
+		category_id
+	FROM
+		tree
+	ORDER BY
+		depth, category_id`
+
+	var rows []struct {
+		CategoryID string `db:"category_id"`
+	}
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &rows); err != nil {
+		return nil, fmt.Errorf("selecting descendants of %s %w", categoryID, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, database.ErrNotFound
+	}
+
+	ids := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i] = r.CategoryID
+	}
+	return ids, nil
+}
@@ -0,0 +1,19 @@
+package lockout
+
+import (
+	"time"
+)
+
+// Failure counts the failed logins for a key, either an account or an
+// address the logins came from.
+type Failure struct {
+	Key         string     `db:"key"`
+	Failures    int        `db:"failures"`
+	LastFailure time.Time  `db:"last_failure"`
+	LockedUntil *time.Time `db:"locked_until"`
+}
+
+// Locked reports whether logins for the key are refused at now.
+func (f Failure) Locked(now time.Time) bool {
+	return f.LockedUntil != nil && f.LockedUntil.After(now)
+}
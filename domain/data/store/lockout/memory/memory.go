@@ -0,0 +1,94 @@
+// Package memory provides a thread safe in memory implementation of the
+// login failure store with the same semantics as the postgres store.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/lockout"
+	"service/domain/sys/database"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu       sync.Mutex
+	failures map[string]lockout.Failure
+}
+
+func NewStore() *Store {
+	return &Store{
+		failures: make(map[string]lockout.Failure),
+	}
+}
+
+func (s *Store) QueryByKey(ctx context.Context, key string) (lockout.Failure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[key]
+	if !ok {
+		return lockout.Failure{}, database.ErrNotFound
+	}
+	return clone(f), nil
+}
+
+func (s *Store) Record(ctx context.Context, key string, now time.Time, since time.Time) (lockout.Failure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[key]
+	switch {
+	case !ok:
+		f = lockout.Failure{Key: key, Failures: 1}
+	case f.LastFailure.Before(since):
+		f.Failures = 1
+	default:
+		f.Failures++
+	}
+	f.LastFailure = now
+
+	s.failures[key] = f
+	return clone(f), nil
+}
+
+func (s *Store) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[key]
+	if !ok {
+		return nil
+	}
+	f.LockedUntil = &until
+
+	s.failures[key] = f
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+	return nil
+}
+
+func (s *Store) DeleteExpired(ctx context.Context, since time.Time, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, f := range s.failures {
+		if f.LastFailure.Before(since) && !f.Locked(now) {
+			delete(s.failures, key)
+		}
+	}
+	return nil
+}
+
+func clone(f lockout.Failure) lockout.Failure {
+	if f.LockedUntil != nil {
+		until := *f.LockedUntil
+		f.LockedUntil = &until
+	}
+	return f
+}
@@ -0,0 +1,131 @@
+// Package lockout persists failed login attempts so accounts and addresses
+// can be throttled and locked.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+	"time"
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// QueryByKey returns the failures recorded for the key.
+func (s Store) QueryByKey(ctx context.Context, key string) (Failure, error) {
+	data := struct {
+		Key string `db:"key"`
+	}{
+		Key: key,
+	}
+
+	q := `SELECT * FROM login_failures WHERE key = :key`
+
+	var f Failure
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &f); err != nil {
+		if err == database.ErrNotFound {
+			return Failure{}, database.ErrNotFound
+		}
+		return Failure{}, fmt.Errorf("selecting login failures %w", err)
+	}
+	return f, nil
+}
+
+// Record counts one more failure for the key. Counting starts over when
+// the previous failure happened before since.
+func (s Store) Record(ctx context.Context, key string, now time.Time, since time.Time) (Failure, error) {
+	data := struct {
+		Key   string    `db:"key"`
+		Now   time.Time `db:"now"`
+		Since time.Time `db:"since"`
+	}{
+		Key:   key,
+		Now:   now,
+		Since: since,
+	}
+
+	q := `
+	INSERT INTO login_failures
+		(key, failures, last_failure)
+	VALUES
+		(:key, 1, :now)
+	ON CONFLICT (key) DO UPDATE SET
+		failures = CASE WHEN login_failures.last_failure < :since THEN 1 ELSE login_failures.failures + 1 END,
+		last_failure = :now
+	RETURNING *`
+
+	var f Failure
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &f); err != nil {
+		return Failure{}, fmt.Errorf("recording login failure %w", err)
+	}
+	return f, nil
+}
+
+// Lock refuses logins for the key until the given time.
+func (s Store) Lock(ctx context.Context, key string, until time.Time) error {
+	data := struct {
+		Key   string    `db:"key"`
+		Until time.Time `db:"until"`
+	}{
+		Key:   key,
+		Until: until,
+	}
+
+	q := `UPDATE login_failures SET locked_until = :until WHERE key = :key`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("locking %s %w", key, err)
+	}
+	return nil
+}
+
+// Delete forgets the failures of the key, unlocking it.
+func (s Store) Delete(ctx context.Context, key string) error {
+	data := struct {
+		Key string `db:"key"`
+	}{
+		Key: key,
+	}
+
+	q := `DELETE FROM login_failures WHERE key = :key`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting login failures %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired forgets the keys whose last failure happened before since
+// and that are not locked at now.
+func (s Store) DeleteExpired(ctx context.Context, since time.Time, now time.Time) error {
+	data := struct {
+		Since time.Time `db:"since"`
+		Now   time.Time `db:"now"`
+	}{
+		Since: since,
+		Now:   now,
+	}
+
+	q := `
+	DELETE FROM login_failures
+	WHERE
+		last_failure < :since AND
+		(locked_until IS NULL OR locked_until <= :now)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting expired login failures %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Package memory provides a thread safe in memory implementation of the
+// mfa store with the same semantics as the postgres store.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/mfa"
+	"service/domain/sys/database"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu    sync.Mutex
+	mfas  map[string]mfa.MFA
+	codes map[string][]mfa.RecoveryCode
+}
+
+func NewStore() *Store {
+	return &Store{
+		mfas:  make(map[string]mfa.MFA),
+		codes: make(map[string][]mfa.RecoveryCode),
+	}
+}
+
+func (s *Store) QueryByUserID(ctx context.Context, userID string) (mfa.MFA, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mfas[userID]
+	if !ok {
+		return mfa.MFA{}, database.ErrNotFound
+	}
+	return m, nil
+}
+
+func (s *Store) Save(ctx context.Context, m mfa.MFA) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if old, ok := s.mfas[m.UserID]; ok {
+		m.DateCreated = old.DateCreated
+	}
+	s.mfas[m.UserID] = m
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.mfas, userID)
+	delete(s.codes, userID)
+	return nil
+}
+
+func (s *Store) UseStep(ctx context.Context, userID string, step int64, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.mfas[userID]
+	if !ok || m.LastStep >= step {
+		return database.ErrNotFound
+	}
+	m.LastStep = step
+	m.DateUpdated = now
+
+	s.mfas[userID] = m
+	return nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []mfa.RecoveryCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.codes[userID] = append([]mfa.RecoveryCode(nil), codes...)
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, userID string, hash string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	codes := s.codes[userID]
+	for i := range codes {
+		if codes[i].Hash == hash && codes[i].DateUsed == nil {
+			used := now
+			codes[i].DateUsed = &used
+			return nil
+		}
+	}
+	return database.ErrNotFound
+}
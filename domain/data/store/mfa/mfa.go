@@ -0,0 +1,25 @@
+package mfa
+
+import (
+	"time"
+)
+
+// MFA is the second factor of a user. It is only enforced once Enabled,
+// which happens when the user proves the authenticator app was set up.
+type MFA struct {
+	UserID      string    `db:"user_id"`
+	Secret      string    `db:"secret"`
+	Enabled     bool      `db:"enabled"`
+	LastStep    int64     `db:"last_step"`
+	DateCreated time.Time `db:"date_created"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+// RecoveryCode lets a user in without the authenticator app, once. Only
+// its hash is stored.
+type RecoveryCode struct {
+	UserID      string     `db:"user_id"`
+	Hash        string     `db:"code_hash"`
+	DateUsed    *time.Time `db:"date_used"`
+	DateCreated time.Time  `db:"date_created"`
+}
@@ -0,0 +1,183 @@
+// Package mfa persists the second factors of users and their recovery
+// codes.
+package mfa
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+	"time"
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// QueryByUserID returns the second factor of the user.
+func (s Store) QueryByUserID(ctx context.Context, userID string) (MFA, error) {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	q := `SELECT * FROM user_mfa WHERE user_id = :user_id`
+
+	var m MFA
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &m); err != nil {
+		if err == database.ErrNotFound {
+			return MFA{}, database.ErrNotFound
+		}
+		return MFA{}, fmt.Errorf("selecting mfa %s %w", userID, err)
+	}
+	return m, nil
+}
+
+// Save creates or replaces the second factor of the user.
+func (s Store) Save(ctx context.Context, m MFA) error {
+	q := `
+	INSERT INTO user_mfa
+		(user_id, secret, enabled, last_step, date_created, date_updated)
+	VALUES
+		(:user_id, :secret, :enabled, :last_step, :date_created, :date_updated)
+	ON CONFLICT (user_id) DO UPDATE SET
+		secret = :secret,
+		enabled = :enabled,
+		last_step = :last_step,
+		date_updated = :date_updated`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, m); err != nil {
+		return fmt.Errorf("saving mfa %s %w", m.UserID, err)
+	}
+	return nil
+}
+
+// Delete removes the second factor of the user along with the recovery
+// codes.
+func (s Store) Delete(ctx context.Context, userID string) error {
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	q := `
+	WITH codes AS (
+		DELETE FROM mfa_recovery_codes WHERE user_id = :user_id
+	)
+	DELETE FROM user_mfa WHERE user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting mfa %s %w", userID, err)
+	}
+	return nil
+}
+
+// UseStep records that the code of the time step was used. It returns
+// database.ErrNotFound when that step or a later one was used already, a
+// code must never be accepted twice.
+func (s Store) UseStep(ctx context.Context, userID string, step int64, now time.Time) error {
+	data := struct {
+		UserID string    `db:"user_id"`
+		Step   int64     `db:"step"`
+		Now    time.Time `db:"now"`
+	}{
+		UserID: userID,
+		Step:   step,
+		Now:    now,
+	}
+
+	q := `
+	UPDATE user_mfa
+	SET last_step = :step, date_updated = :now
+	WHERE user_id = :user_id AND last_step < :step
+	RETURNING user_id`
+
+	var row struct {
+		UserID string `db:"user_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &row); err != nil {
+		if err == database.ErrNotFound {
+			return database.ErrNotFound
+		}
+		return fmt.Errorf("using step %s %w", userID, err)
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes drops every recovery code of the user and stores
+// the new ones.
+func (s Store) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []RecoveryCode) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	data := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	if _, err := tx.NamedExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = :user_id`, data); err != nil {
+		return fmt.Errorf("deleting recovery codes %s %w", userID, err)
+	}
+
+	q := `INSERT INTO mfa_recovery_codes
+	(user_id, code_hash, date_used, date_created)
+	VALUES
+	(:user_id, :code_hash, :date_used, :date_created)`
+
+	for _, c := range codes {
+		if _, err := tx.NamedExecContext(ctx, q, c); err != nil {
+			return fmt.Errorf("inserting recovery code %s %w", userID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the code as used. It returns database.ErrNotFound
+// for unknown and used codes.
+func (s Store) UseRecoveryCode(ctx context.Context, userID string, hash string, now time.Time) error {
+	data := struct {
+		UserID string    `db:"user_id"`
+		Hash   string    `db:"code_hash"`
+		Now    time.Time `db:"now"`
+	}{
+		UserID: userID,
+		Hash:   hash,
+		Now:    now,
+	}
+
+	q := `
+	UPDATE mfa_recovery_codes
+	SET date_used = :now
+	WHERE user_id = :user_id AND code_hash = :code_hash AND date_used IS NULL
+	RETURNING user_id`
+
+	var row struct {
+		UserID string `db:"user_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &row); err != nil {
+		if err == database.ErrNotFound {
+			return database.ErrNotFound
+		}
+		return fmt.Errorf("using recovery code %s %w", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+// Package memory provides a thread safe in memory implementation of the
+// order store with the same semantics as the postgres store. Stock is
+// taken from the memory product store it is given and coupons are redeemed
+// in the memory promotion store.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"service/domain/data/store/order"
+	productMemory "service/domain/data/store/product/memory"
+	promotionMemory "service/domain/data/store/promotion/memory"
+	"service/domain/data/store/refund"
+	"service/domain/sys/database"
+	"service/foundation/money"
+	"sort"
+	"sync"
+)
+
+type Store struct {
+	mu         sync.Mutex
+	products   *productMemory.Store
+	promotions *promotionMemory.Store
+	orders     map[string]order.Order
+}
+
+func NewStore(products *productMemory.Store, promotions *promotionMemory.Store) *Store {
+	return &Store{
+		products:   products,
+		promotions: promotions,
+		orders:     make(map[string]order.Order),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, o order.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[o.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+
+	take := func() error {
+		return s.products.Take(ctx, o.Source(), o.Moves(), o.DateCreated)
+	}
+
+	var err error
+	if o.CouponID != nil {
+		err = s.promotions.Redeem(ctx, *o.CouponID, o.DateCreated, take)
+	} else {
+		err = take()
+	}
+	if err != nil {
+		return err
+	}
+
+	s.orders[o.ID] = clone(o)
+	return nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, orderID string) (order.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[orderID]
+	if !ok {
+		return order.Order{}, database.ErrNotFound
+	}
+	return clone(o), nil
+}
+
+func (s *Store) QueryByCustomer(ctx context.Context, customerID string, pageNumber int, rowsPerPage int) ([]order.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []order.Order
+	for _, o := range s.orders {
+		if o.CustomerID == customerID {
+			orders = append(orders, clone(o))
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.After(orders[j].DateCreated)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	start := (pageNumber - 1) * rowsPerPage
+	if start >= len(orders) {
+		return []order.Order{}, nil
+	}
+
+	end := start + rowsPerPage
+	if end > len(orders) {
+		end = len(orders)
+	}
+	return orders[start:end], nil
+}
+
+func (s *Store) QueryMargins(ctx context.Context, f order.MarginFilter) ([]order.Margin, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type key struct {
+		productID string
+		currency  money.Currency
+	}
+
+	var keys []key
+	var ids []string
+	margins := make(map[key]order.Margin)
+	for _, o := range s.orders {
+		if (f.From != nil && o.DateCreated.Before(*f.From)) || (f.To != nil && !o.DateCreated.Before(*f.To)) {
+			continue
+		}
+
+		for _, item := range o.Items {
+			k := key{productID: item.ProductID, currency: o.Currency}
+			m, ok := margins[k]
+			if !ok {
+				keys = append(keys, k)
+				ids = append(ids, item.ProductID)
+				m = order.Margin{ProductID: item.ProductID, Currency: o.Currency}
+			}
+
+			units := item.Quantity - item.RefundedQuantity
+			m.Units += units
+			m.Revenue += item.Total - item.RefundedAmount
+			m.Cost += units * item.UnitCost
+			margins[k] = m
+		}
+	}
+
+	prds, err := s.products.QueryByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(prds))
+	for _, p := range prds {
+		names[p.ID] = p.Name
+	}
+
+	result := make([]order.Margin, 0, len(keys))
+	for _, k := range keys {
+		m := margins[k]
+		m.Name = names[k.productID]
+		m.Margin = m.Revenue - m.Cost
+		result = append(result, m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Margin != result[j].Margin {
+			return result[i].Margin > result[j].Margin
+		}
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].ProductID < result[j].ProductID
+	})
+	return result, nil
+}
+
+// Refund adds the items to the refunded totals of their lines. Either
+// every line stays within what was paid and all are updated, or none is.
+// The memory refund store uses it the way the postgres refund store
+// updates order_items in its transaction.
+func (s *Store) Refund(ctx context.Context, orderID string, items []refund.Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[orderID]
+	if !ok {
+		return database.ErrNotFound
+	}
+
+	o = clone(o)
+	for _, ri := range items {
+		found := false
+		for i := range o.Items {
+			item := &o.Items[i]
+			if item.Line != ri.Line {
+				continue
+			}
+			found = true
+
+			if item.RefundedQuantity+ri.Quantity > item.Quantity || item.RefundedAmount+ri.Amount > item.Total {
+				return fmt.Errorf("line %d: %w", ri.Line, refund.ErrExceedsPaid)
+			}
+			item.RefundedQuantity += ri.Quantity
+			item.RefundedAmount += ri.Amount
+		}
+
+		if !found {
+			return fmt.Errorf("line %d: %w", ri.Line, refund.ErrExceedsPaid)
+		}
+	}
+
+	s.orders[orderID] = o
+	return nil
+}
+
+// clone makes sure callers never share the items with the stored order.
+func clone(o order.Order) order.Order {
+	items := make([]order.Item, len(o.Items))
+	for i, item := range o.Items {
+		item.OrderID = o.ID
+		if item.VariantID != nil {
+			id := *item.VariantID
+			item.VariantID = &id
+		}
+		var discounts []order.Discount
+		for _, d := range item.Discounts {
+			d.OrderID = o.ID
+			d.Line = item.Line
+			discounts = append(discounts, d)
+		}
+		item.Discounts = discounts
+		items[i] = item
+	}
+	o.Items = items
+	return o
+}
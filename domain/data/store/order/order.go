@@ -0,0 +1,122 @@
+package order
+
+import (
+	"service/domain/data/store/product"
+	"service/foundation/money"
+	"time"
+)
+
+// Order is a basket bought by a customer. Every item is priced when the
+// order is placed, later changes to the products and promotions do not
+// touch it. The stock of the items was taken from the warehouse. Discount
+// adds up the discounts of the items, Total is what is left to pay.
+// CouponID is the coupon the order redeemed, if any.
+type Order struct {
+	ID          string         `db:"order_id" json:"id"`
+	CustomerID  string         `db:"customer_id" json:"customer_id"`
+	WarehouseID string         `db:"warehouse_id" json:"warehouse_id"`
+	Discount    int            `db:"discount" json:"discount"`
+	Total       int            `db:"total" json:"total"`
+	Currency    money.Currency `db:"currency" json:"currency"`
+	CouponID    *string        `db:"coupon_id" json:"coupon_id"`
+	Items       []Item         `db:"-" json:"items"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+// Moves returns the stock the items of the order take out of its
+// warehouse.
+func (o Order) Moves() []product.StockMove {
+	moves := make([]product.StockMove, len(o.Items))
+	for i, item := range o.Items {
+		moves[i] = product.StockMove{
+			WarehouseID: o.WarehouseID,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			Quantity:    item.Quantity,
+		}
+	}
+	return moves
+}
+
+// Source returns how the stock taken for the order is recorded in the
+// ledger.
+func (o Order) Source() product.Source {
+	return product.Source{Kind: product.KindSale, ReferenceID: o.ID, CreatedBy: o.CustomerID}
+}
+
+// Item is a line of an order, Total is Quantity times UnitPrice less the
+// Discount, which adds up the Discounts given on the line. Lines turned
+// from sales are priced at what was paid divided by Quantity rounded
+// down, their Total is what was paid, refunds and margins always work
+// from Total. The refunded quantity and amount add up every refund given
+// for the line. VariantID is set when the product is sold by variant, the
+// stock was taken from the variant then. UnitCost is the purchase cost of
+// the product when it was sold, it is kept from customers.
+type Item struct {
+	OrderID          string     `db:"order_id" json:"-"`
+	Line             int        `db:"line" json:"line"`
+	ProductID        string     `db:"product_id" json:"product_id"`
+	VariantID        *string    `db:"variant_id" json:"variant_id"`
+	Quantity         int        `db:"quantity" json:"quantity"`
+	UnitPrice        int        `db:"unit_price" json:"unit_price"`
+	UnitCost         int        `db:"unit_cost" json:"-"`
+	Discount         int        `db:"discount" json:"discount"`
+	Discounts        []Discount `db:"-" json:"discounts"`
+	Total            int        `db:"total" json:"total"`
+	RefundedQuantity int        `db:"refunded_quantity" json:"refunded_quantity"`
+	RefundedAmount   int        `db:"refunded_amount" json:"refunded_amount"`
+}
+
+// Discount is what a promotion or a coupon took off an item, only one of
+// the ids is set. A line gets at most one promotion, applied before the
+// coupon.
+type Discount struct {
+	OrderID     string  `db:"order_id" json:"-"`
+	Line        int     `db:"line" json:"-"`
+	PromotionID *string `db:"promotion_id" json:"promotion_id"`
+	CouponID    *string `db:"coupon_id" json:"coupon_id"`
+	Amount      int     `db:"amount" json:"amount"`
+}
+
+// Margin is what a product sold for against what it cost us, over the
+// orders of a period in one currency. Units and Revenue are net of
+// refunds and Cost is the purchase cost of those units when they were
+// sold.
+type Margin struct {
+	ProductID string         `db:"product_id" json:"product_id"`
+	Name      string         `db:"name" json:"name"`
+	Currency  money.Currency `db:"currency" json:"currency"`
+	Units     int            `db:"units" json:"units"`
+	Revenue   int            `db:"revenue" json:"revenue"`
+	Cost      int            `db:"cost" json:"cost"`
+	Margin    int            `db:"margin" json:"margin"`
+}
+
+// MarginFilter narrows the orders margins are worked out over to those
+// placed from From up to, not including, To. A nil bound does not
+// filter.
+type MarginFilter struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// NewOrder is what we require from customers when placing an order. The
+// currency is the one the customer expects to pay in, every product must
+// be priced in it. Left out, it is the currency of the products. The
+// items are picked from the warehouse, the default one when left out. A
+// coupon code asks for the discount of the coupon.
+type NewOrder struct {
+	Currency    money.Currency `json:"currency" validate:"omitempty,currency"`
+	WarehouseID *string        `json:"warehouse_id" validate:"omitempty,uuid"`
+	CouponCode  string         `json:"coupon_code" validate:"max=30"`
+	Items       []NewItem      `json:"items" validate:"required,min=1,dive"`
+}
+
+// NewItem is a product and how many of it are bought. A product that has
+// variants is bought by naming one of them.
+type NewItem struct {
+	ProductID string  `json:"product_id" validate:"required,uuid"`
+	VariantID *string `json:"variant_id" validate:"omitempty,uuid"`
+	Quantity  int     `json:"quantity" validate:"required,gte=1"`
+}
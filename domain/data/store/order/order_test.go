@@ -0,0 +1,272 @@
+package order_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"service/domain/core/order"
+	orderStore "service/domain/data/store/order"
+	"service/domain/data/store/order/memory"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	"service/domain/data/store/promotion"
+	promotionMemory "service/domain/data/store/promotion/memory"
+	"service/domain/data/store/warehouse"
+	"service/domain/data/tests"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+// The seeded admin owns the products, the seeded user places the orders.
+const (
+	adminID = "5cf37266-3473-4006-984f-9325122678b7"
+	userID  = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+)
+
+type productStorer interface {
+	Create(ctx context.Context, p product.Product) error
+	QueryByID(ctx context.Context, productID string) (product.Product, error)
+	CreateVariant(ctx context.Context, v product.Variant) error
+	QueryVariantByID(ctx context.Context, variantID string) (product.Variant, error)
+}
+
+type couponStorer interface {
+	CreateCoupon(ctx context.Context, c promotion.Coupon) error
+	QueryCouponByCode(ctx context.Context, code string) (promotion.Coupon, error)
+}
+
+func TestMemory(t *testing.T) {
+	products := productMemory.NewStore()
+	promotions := promotionMemory.NewStore()
+	orders(t, memory.NewStore(products, promotions), products, promotions)
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	orders(t, orderStore.NewStore(logger, db), product.NewStore(logger, db), promotion.NewStore(logger, db))
+}
+
+func orders(t *testing.T, store order.Storer, products productStorer, coupons couponStorer) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	books := product.Product{ID: validate.GenerateUID(), Name: "Books", Cost: 50, Currency: money.USD, Quantity: 5, UserID: adminID, DateCreated: now, DateUpdated: now}
+	toys := product.Product{ID: validate.GenerateUID(), Name: "Toys", Cost: 75, Currency: money.USD, Quantity: 2, UserID: adminID, DateCreated: now, DateUpdated: now}
+	for _, p := range []product.Product{books, toys} {
+		if err := products.Create(ctx, p); err != nil {
+			t.Fatalf("\t%s\t Should be able to create a product: %v", tests.Failed, err)
+		}
+	}
+
+	newOrder := func(at time.Time, bookQty int, toyQty int) orderStore.Order {
+		o := orderStore.Order{
+			ID:          validate.GenerateUID(),
+			CustomerID:  userID,
+			WarehouseID: warehouse.MainID,
+			Currency:    money.USD,
+			Total:       bookQty*books.Cost + toyQty*toys.Cost,
+			DateCreated: at,
+			DateUpdated: at,
+		}
+		o.Items = []orderStore.Item{
+			{OrderID: o.ID, Line: 1, ProductID: books.ID, Quantity: bookQty, UnitPrice: books.Cost, Total: bookQty * books.Cost},
+			{OrderID: o.ID, Line: 2, ProductID: toys.ID, Quantity: toyQty, UnitPrice: toys.Cost, Total: toyQty * toys.Cost},
+		}
+		return o
+	}
+
+	stock := func(id string) int {
+		t.Helper()
+
+		p, err := products.QueryByID(ctx, id)
+		if err != nil {
+			t.Fatalf("\t%s\t Should be able to query a product: %v", tests.Failed, err)
+		}
+		return p.Quantity
+	}
+
+	t.Log("Given the need to sell baskets of products")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When placing orders", testID)
+		{
+			first := newOrder(now, 2, 1)
+			if err := store.Create(ctx, first); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to place an order: %v", tests.Failed, testID, err)
+			}
+
+			if stock(books.ID) != 3 || stock(toys.ID) != 1 {
+				t.Fatalf("\t%s\t Test %d Should take the items out of stock, got %d %d", tests.Failed, testID, stock(books.ID), stock(toys.ID))
+			}
+			t.Logf("\t%s\t Test %d Should take the items out of stock", tests.Succeeded, testID)
+
+			tooMany := newOrder(now.Add(time.Minute), 1, 2)
+			if err := store.Create(ctx, tooMany); !errors.Is(err, product.ErrInsufficientStock) {
+				t.Fatalf("\t%s\t Test %d Should refuse an order without enough stock, got %v", tests.Failed, testID, err)
+			}
+
+			if stock(books.ID) != 3 || stock(toys.ID) != 1 {
+				t.Fatalf("\t%s\t Test %d Should leave the stock untouched, got %d %d", tests.Failed, testID, stock(books.ID), stock(toys.ID))
+			}
+
+			if _, err := store.QueryByID(ctx, tooMany.ID); !errors.Is(err, database.ErrNotFound) {
+				t.Fatalf("\t%s\t Test %d Should not store the refused order, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse an order without enough stock", tests.Succeeded, testID)
+
+			second := newOrder(now.Add(time.Hour), 3, 1)
+			if err := store.Create(ctx, second); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to place an order: %v", tests.Failed, testID, err)
+			}
+
+			testID++
+			t.Logf("\t Test %d \t When reading orders", testID)
+
+			got, err := store.QueryByID(ctx, first.ID)
+			if err != nil || got.Total != 175 || len(got.Items) != 2 || !reflect.DeepEqual(got.Items, first.Items) {
+				t.Fatalf("\t%s\t Test %d Should return the order with its items, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should return the order with its items", tests.Succeeded, testID)
+
+			page, err := store.QueryByCustomer(ctx, userID, 1, 1)
+			if err != nil || len(page) != 1 || page[0].ID != second.ID || len(page[0].Items) != 2 {
+				t.Fatalf("\t%s\t Test %d Should list the latest order first, got %+v %v", tests.Failed, testID, page, err)
+			}
+
+			page, err = store.QueryByCustomer(ctx, userID, 2, 1)
+			if err != nil || len(page) != 1 || page[0].ID != first.ID {
+				t.Fatalf("\t%s\t Test %d Should page through the orders, got %+v %v", tests.Failed, testID, page, err)
+			}
+			t.Logf("\t%s\t Test %d Should page through the orders of a customer", tests.Succeeded, testID)
+
+			page, err = store.QueryByCustomer(ctx, validate.GenerateUID(), 1, 10)
+			if err != nil || len(page) != 0 {
+				t.Fatalf("\t%s\t Test %d Should not list the orders of others, got %+v %v", tests.Failed, testID, page, err)
+			}
+			t.Logf("\t%s\t Test %d Should not list the orders of others", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When selling variants", testID)
+		{
+			shirts := product.Product{ID: validate.GenerateUID(), Name: "Shirts", Cost: 20, Currency: money.USD, Quantity: 0, UserID: adminID, DateCreated: now, DateUpdated: now}
+			if err := products.Create(ctx, shirts); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to create a product: %v", tests.Failed, testID, err)
+			}
+
+			medium := product.Variant{ID: validate.GenerateUID(), ProductID: shirts.ID, SKU: "SHIRT-M-" + shirts.ID[:8], Attributes: product.Attributes{"size": "M"}, Quantity: 1, DateCreated: now, DateUpdated: now}
+			large := product.Variant{ID: validate.GenerateUID(), ProductID: shirts.ID, SKU: "SHIRT-L-" + shirts.ID[:8], Attributes: product.Attributes{"size": "L"}, Quantity: 3, DateCreated: now, DateUpdated: now}
+			for _, v := range []product.Variant{medium, large} {
+				if err := products.CreateVariant(ctx, v); err != nil {
+					t.Fatalf("\t%s\t Test %d Should be able to create a variant: %v", tests.Failed, testID, err)
+				}
+			}
+
+			variantStock := func(id string) int {
+				t.Helper()
+
+				v, err := products.QueryVariantByID(ctx, id)
+				if err != nil {
+					t.Fatalf("\t%s\t Test %d Should be able to query a variant: %v", tests.Failed, testID, err)
+				}
+				return v.Quantity
+			}
+
+			o := orderStore.Order{ID: validate.GenerateUID(), CustomerID: userID, WarehouseID: warehouse.MainID, Currency: money.USD, Total: 60, DateCreated: now, DateUpdated: now}
+			o.Items = []orderStore.Item{
+				{OrderID: o.ID, Line: 1, ProductID: shirts.ID, VariantID: &medium.ID, Quantity: 1, UnitPrice: 20, Total: 20},
+				{OrderID: o.ID, Line: 2, ProductID: shirts.ID, VariantID: &large.ID, Quantity: 2, UnitPrice: 20, Total: 40},
+			}
+			if err := store.Create(ctx, o); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to sell variants: %v", tests.Failed, testID, err)
+			}
+
+			if variantStock(medium.ID) != 0 || variantStock(large.ID) != 1 || stock(shirts.ID) != 0 {
+				t.Fatalf("\t%s\t Test %d Should take the items out of the stock of the variants, got %d %d", tests.Failed, testID, variantStock(medium.ID), variantStock(large.ID))
+			}
+
+			got, err := store.QueryByID(ctx, o.ID)
+			if err != nil || len(got.Items) != 2 || got.Items[0].VariantID == nil || *got.Items[0].VariantID != medium.ID {
+				t.Fatalf("\t%s\t Test %d Should keep the variant of every line, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should take the items out of the stock of the variants", tests.Succeeded, testID)
+
+			before := stock(books.ID)
+			o = orderStore.Order{ID: validate.GenerateUID(), CustomerID: userID, WarehouseID: warehouse.MainID, Currency: money.USD, Total: 70, DateCreated: now, DateUpdated: now}
+			o.Items = []orderStore.Item{
+				{OrderID: o.ID, Line: 1, ProductID: books.ID, Quantity: 1, UnitPrice: 50, Total: 50},
+				{OrderID: o.ID, Line: 2, ProductID: shirts.ID, VariantID: &medium.ID, Quantity: 1, UnitPrice: 20, Total: 20},
+			}
+			if err := store.Create(ctx, o); !errors.Is(err, product.ErrInsufficientStock) {
+				t.Fatalf("\t%s\t Test %d Should refuse a variant out of stock, got %v", tests.Failed, testID, err)
+			}
+
+			if stock(books.ID) != before {
+				t.Fatalf("\t%s\t Test %d Should leave the stock of the products untouched, got %d", tests.Failed, testID, stock(books.ID))
+			}
+			t.Logf("\t%s\t Test %d Should refuse a variant out of stock", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When discounting orders", testID)
+		{
+			pens := product.Product{ID: validate.GenerateUID(), Name: "Pens", Cost: 50, Currency: money.USD, Quantity: 5, UserID: adminID, DateCreated: now, DateUpdated: now}
+			if err := products.Create(ctx, pens); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to create a product: %v", tests.Failed, testID, err)
+			}
+
+			limit := 1
+			usd := money.USD
+			coupon := promotion.Coupon{ID: validate.GenerateUID(), Code: "ONCE" + pens.ID[:8], Kind: promotion.KindFixed, Value: 10, Currency: &usd, UsageLimit: &limit, DateCreated: now, DateUpdated: now}
+			if err := coupons.CreateCoupon(ctx, coupon); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to create a coupon: %v", tests.Failed, testID, err)
+			}
+
+			o := orderStore.Order{ID: validate.GenerateUID(), CustomerID: userID, WarehouseID: warehouse.MainID, Currency: money.USD, Discount: 10, Total: 40, CouponID: &coupon.ID, DateCreated: now, DateUpdated: now}
+			o.Items = []orderStore.Item{
+				{OrderID: o.ID, Line: 1, ProductID: pens.ID, Quantity: 1, UnitPrice: 50, Discount: 10, Total: 40, Discounts: []orderStore.Discount{
+					{OrderID: o.ID, Line: 1, CouponID: &coupon.ID, Amount: 10},
+				}},
+			}
+			if err := store.Create(ctx, o); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to place an order with a coupon: %v", tests.Failed, testID, err)
+			}
+
+			got, err := store.QueryByID(ctx, o.ID)
+			if err != nil || got.Discount != 10 || got.CouponID == nil || *got.CouponID != coupon.ID || !reflect.DeepEqual(got.Items, o.Items) {
+				t.Fatalf("\t%s\t Test %d Should keep the discounts of every line, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should keep the discounts of every line", tests.Succeeded, testID)
+
+			cp, err := coupons.QueryCouponByCode(ctx, coupon.Code)
+			if err != nil || cp.Used != 1 {
+				t.Fatalf("\t%s\t Test %d Should count the use of the coupon, got %+v %v", tests.Failed, testID, cp, err)
+			}
+			t.Logf("\t%s\t Test %d Should count the use of the coupon", tests.Succeeded, testID)
+
+			again := orderStore.Order{ID: validate.GenerateUID(), CustomerID: userID, WarehouseID: warehouse.MainID, Currency: money.USD, Total: 50, CouponID: &coupon.ID, DateCreated: now, DateUpdated: now}
+			again.Items = []orderStore.Item{
+				{OrderID: again.ID, Line: 1, ProductID: pens.ID, Quantity: 1, UnitPrice: 50, Total: 50},
+			}
+			if err := store.Create(ctx, again); !errors.Is(err, promotion.ErrCouponUnavailable) {
+				t.Fatalf("\t%s\t Test %d Should refuse a coupon past its usage limit, got %v", tests.Failed, testID, err)
+			}
+
+			if stock(pens.ID) != 4 {
+				t.Fatalf("\t%s\t Test %d Should leave the stock untouched, got %d", tests.Failed, testID, stock(pens.ID))
+			}
+			t.Logf("\t%s\t Test %d Should refuse a coupon past its usage limit", tests.Succeeded, testID)
+		}
+	}
+}
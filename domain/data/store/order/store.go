@@ -0,0 +1,264 @@
+// Package order persists orders and their items.
+package order
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/data/store/promotion"
+	"service/domain/sys/database"
+	"time"
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create takes the items out of the stock of the warehouse of the order,
+// redeems its coupon and stores the order in a single transaction. An
+// item without enough stock, or a coupon that can no longer be redeemed,
+// leaves everything untouched.
+func (s Store) Create(ctx context.Context, o Order) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	if o.CouponID != nil {
+		if err := promotion.RedeemCoupon(ctx, tx, *o.CouponID, o.DateCreated); err != nil {
+			return err
+		}
+	}
+
+	if err := product.TakeStock(ctx, tx, o.Source(), o.Moves(), o.DateCreated); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO orders
+	(order_id, customer_id, warehouse_id, discount, total, currency, coupon_id, date_created, date_updated)
+	VALUES
+	(:order_id, :customer_id, :warehouse_id, :discount, :total, :currency, :coupon_id, :date_created, :date_updated)`
+
+	if _, err := tx.NamedExecContext(ctx, q, o); err != nil {
+		return fmt.Errorf("inserting order %s %w", o.ID, err)
+	}
+
+	q = `INSERT INTO order_items
+	(order_id, line, product_id, variant_id, quantity, unit_price, unit_cost, discount, total)
+	VALUES
+	(:order_id, :line, :product_id, :variant_id, :quantity, :unit_price, :unit_cost, :discount, :total)`
+
+	for _, item := range o.Items {
+		item.OrderID = o.ID
+		if _, err := tx.NamedExecContext(ctx, q, item); err != nil {
+			return fmt.Errorf("inserting order item %s/%d %w", o.ID, item.Line, err)
+		}
+	}
+
+	q = `INSERT INTO order_item_discounts
+	(order_id, line, promotion_id, coupon_id, amount)
+	VALUES
+	(:order_id, :line, :promotion_id, :coupon_id, :amount)`
+
+	for _, item := range o.Items {
+		for _, d := range item.Discounts {
+			d.OrderID = o.ID
+			d.Line = item.Line
+			if _, err := tx.NamedExecContext(ctx, q, d); err != nil {
+				return fmt.Errorf("inserting order item discount %s/%d %w", o.ID, item.Line, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// QueryByID returns the order with the id along with its items.
+func (s Store) QueryByID(ctx context.Context, orderID string) (Order, error) {
+	data := struct {
+		OrderID string `db:"order_id"`
+	}{
+		OrderID: orderID,
+	}
+
+	q := `SELECT * FROM orders WHERE order_id = :order_id`
+
+	var o Order
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &o); err != nil {
+		if err == database.ErrNotFound {
+			return Order{}, database.ErrNotFound
+		}
+		return Order{}, fmt.Errorf("selecting order %s %w", orderID, err)
+	}
+
+	orders := []Order{o}
+	if err := s.items(ctx, orders); err != nil {
+		return Order{}, err
+	}
+	return orders[0], nil
+}
+
+// QueryByCustomer returns a page of the orders of the customer along with
+// their items, latest first.
+func (s Store) QueryByCustomer(ctx context.Context, customerID string, pageNumber int, rowsPerPage int) ([]Order, error) {
+	data := struct {
+		CustomerID  string `db:"customer_id"`
+		Offset      int    `db:"offset"`
+		RowsPerPage int    `db:"rows_per_page"`
+	}{
+		CustomerID:  customerID,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		orders
+	WHERE
+		customer_id = :customer_id
+	ORDER BY
+		date_created DESC, order_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var orders []Order
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &orders); err != nil {
+		return nil, fmt.Errorf("selecting orders of %s %w", customerID, err)
+	}
+
+	if err := s.items(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// QueryMargins returns the margin of every product sold in the orders of
+// the filter, per currency, the highest margin first.
+func (s Store) QueryMargins(ctx context.Context, f MarginFilter) ([]Margin, error) {
+	data := struct {
+		From *time.Time `db:"from"`
+		To   *time.Time `db:"to"`
+	}{
+		From: f.From,
+		To:   f.To,
+	}
+
+	q := `
+	SELECT
+		m.*, m.revenue - m.cost AS margin
+	FROM (
+		SELECT
+			i.product_id,
+			p.name,
+			o.currency,
+			SUM(i.quantity - i.refunded_quantity) AS units,
+			SUM(i.total - i.refunded_amount) AS revenue,
+			SUM((i.quantity - i.refunded_quantity) * i.unit_cost) AS cost
+		FROM
+			order_items AS i
+		JOIN
+			orders AS o ON o.order_id = i.order_id
+		JOIN
+			products AS p ON p.product_id = i.product_id
+		WHERE
+			(CAST(:from AS TIMESTAMP) IS NULL OR o.date_created >= :from) AND
+			(CAST(:to AS TIMESTAMP) IS NULL OR o.date_created < :to)
+		GROUP BY
+			i.product_id, p.name, o.currency
+	) AS m
+	ORDER BY
+		m.revenue - m.cost DESC, m.name, m.product_id`
+
+	var margins []Margin
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &margins); err != nil {
+		return nil, fmt.Errorf("selecting margins %w", err)
+	}
+	return margins, nil
+}
+
+// items loads the items of every order and their discounts, in a query
+// each.
+func (s Store) items(ctx context.Context, orders []Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make(pq.StringArray, len(orders))
+	for i, o := range orders {
+		ids[i] = o.ID
+	}
+
+	data := struct {
+		OrderIDs pq.StringArray `db:"order_ids"`
+	}{
+		OrderIDs: ids,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		order_items
+	WHERE
+		order_id = ANY(CAST(:order_ids AS UUID[]))
+	ORDER BY
+		order_id, line`
+
+	var items []Item
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &items); err != nil {
+		return fmt.Errorf("selecting order items %w", err)
+	}
+
+	q = `
+	SELECT
+		*
+	FROM
+		order_item_discounts
+	WHERE
+		order_id = ANY(CAST(:order_ids AS UUID[]))
+	ORDER BY
+		order_id, line, coupon_id NULLS FIRST`
+
+	var discounts []Discount
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &discounts); err != nil {
+		return fmt.Errorf("selecting order item discounts %w", err)
+	}
+
+	type line struct {
+		orderID string
+		line    int
+	}
+
+	byLine := make(map[line][]Discount, len(discounts))
+	for _, d := range discounts {
+		k := line{orderID: d.OrderID, line: d.Line}
+		byLine[k] = append(byLine[k], d)
+	}
+
+	byOrder := make(map[string][]Item, len(orders))
+	for _, item := range items {
+		item.Discounts = byLine[line{orderID: item.OrderID, line: item.Line}]
+		byOrder[item.OrderID] = append(byOrder[item.OrderID], item)
+	}
+
+	for i := range orders {
+		orders[i].Items = byOrder[orders[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+)
+
+// AveragePurchaseCost folds quantity units bought at unitCost into the
+// purchase cost of the held units, rounded to the nearest minor unit.
+// Units held below zero count as none.
+func AveragePurchaseCost(held int, purchaseCost int, quantity int, unitCost int) int {
+	if held < 0 {
+		held = 0
+	}
+
+	units := held + quantity
+	if units <= 0 {
+		return purchaseCost
+	}
+
+	total := held*purchaseCost + quantity*unitCost
+	return (2*total + units) / (2 * units)
+}
+
+// AddPurchaseCost averages quantity units bought at unitCost into the
+// purchase cost of the product. It reads what the product holds, so it
+// must run before the units are put in stock, within the transaction that
+// puts them.
+func AddPurchaseCost(ctx context.Context, tx *sqlx.Tx, productID string, quantity int, unitCost int) error {
+	data := struct {
+		ProductID string `db:"product_id"`
+		Quantity  int    `db:"quantity"`
+		UnitCost  int    `db:"unit_cost"`
+	}{
+		ProductID: productID,
+		Quantity:  quantity,
+		UnitCost:  unitCost,
+	}
+
+	const held = `GREATEST(` + onHand + `, 0)`
+
+	q := `
+	UPDATE
+		products AS p
+	SET
+		purchase_cost = ROUND(
+			(` + held + ` * p.purchase_cost + CAST(:quantity AS INT) * CAST(:unit_cost AS INT)) /
+			CAST(` + held + ` + :quantity AS NUMERIC)
+		)
+	WHERE
+		p.product_id = :product_id`
+
+	if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("updating purchase cost of product %s %w", productID, err)
+	}
+	return nil
+}
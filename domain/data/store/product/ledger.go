@@ -0,0 +1,294 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"service/domain/sys/database"
+	"time"
+)
+
+// Set of kinds of stock movements.
+const (
+	KindSale       = "sale"
+	KindRefund     = "refund"
+	KindAdjustment = "adjustment"
+	KindTransfer   = "transfer"
+	KindReceipt    = "receipt"
+)
+
+// Set of reasons stock is adjusted for. Count is a level set by hand,
+// stocktake a variance posted by a stocktake and opening the stock held
+// before the ledger was kept.
+const (
+	ReasonDamaged    = "damaged"
+	ReasonLost       = "lost"
+	ReasonFound      = "found"
+	ReasonCorrection = "correction"
+	ReasonOther      = "other"
+	ReasonCount      = "count"
+	ReasonStocktake  = "stocktake"
+	ReasonOpening    = "opening"
+)
+
+// Movement is an entry of the inventory ledger, a quantity of a product or
+// of one of its variants going in or out of a warehouse. The ledger is
+// only ever appended to, the sum of the movements of a level is what the
+// level holds. ReferenceID is the order, refund, transfer or stocktake
+// that caused the movement, CreatedBy the user or API key behind it.
+type Movement struct {
+	ID          int64     `db:"movement_id" json:"id"`
+	WarehouseID string    `db:"warehouse_id" json:"warehouse_id"`
+	ProductID   string    `db:"product_id" json:"product_id"`
+	VariantID   *string   `db:"variant_id" json:"variant_id"`
+	Quantity    int       `db:"quantity" json:"quantity"`
+	Kind        string    `db:"kind" json:"kind"`
+	Reason      string    `db:"reason" json:"reason"`
+	ReferenceID *string   `db:"reference_id" json:"reference_id"`
+	Note        string    `db:"note" json:"note"`
+	CreatedBy   *string   `db:"created_by" json:"created_by"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// Source tells why stock moves, every movement it causes is recorded in
+// the ledger with it.
+type Source struct {
+	Kind        string
+	Reason      string
+	ReferenceID string
+	Note        string
+	CreatedBy   string
+}
+
+// Movement returns the ledger entry of the move.
+func (src Source) Movement(m StockMove, now time.Time) Movement {
+	mv := Movement{
+		WarehouseID: m.WarehouseID,
+		ProductID:   m.ProductID,
+		VariantID:   m.VariantID,
+		Quantity:    m.Quantity,
+		Kind:        src.Kind,
+		Reason:      src.Reason,
+		Note:        src.Note,
+		DateCreated: now,
+	}
+	if src.ReferenceID != "" {
+		id := src.ReferenceID
+		mv.ReferenceID = &id
+	}
+	if src.CreatedBy != "" {
+		id := src.CreatedBy
+		mv.CreatedBy = &id
+	}
+	return mv
+}
+
+// MovementFilter narrows the ledger down to a warehouse or a product when
+// they are set.
+type MovementFilter struct {
+	WarehouseID string
+	ProductID   string
+}
+
+// Discrepancy is a level that does not hold what its movements add up to.
+type Discrepancy struct {
+	WarehouseID string  `db:"warehouse_id" json:"warehouse_id"`
+	ProductID   string  `db:"product_id" json:"product_id"`
+	VariantID   *string `db:"variant_id" json:"variant_id"`
+	Level       int     `db:"level" json:"level"`
+	Ledger      int     `db:"ledger" json:"ledger"`
+}
+
+// NewAdjustment is what we require to adjust the stock of a warehouse by
+// hand, a positive quantity adds stock and a negative one removes it.
+type NewAdjustment struct {
+	ProductID string  `json:"product_id" validate:"required,uuid"`
+	VariantID *string `json:"variant_id" validate:"omitempty,uuid"`
+	Quantity  int     `json:"quantity" validate:"required,ne=0"`
+	Reason    string  `json:"reason" validate:"required,oneof=damaged lost found correction other"`
+	Note      string  `json:"note" validate:"max=500"`
+}
+
+// record appends the movement of the level to the ledger as part of tx.
+func record(ctx context.Context, tx *sqlx.Tx, src Source, data levelData) error {
+	m := StockMove{WarehouseID: data.WarehouseID, ProductID: data.ProductID, VariantID: data.VariantID, Quantity: data.Quantity}
+
+	q := `INSERT INTO stock_movements
+	(warehouse_id, product_id, variant_id, quantity, kind, reason, reference_id, note, created_by, date_created)
+	VALUES
+	(:warehouse_id, :product_id, :variant_id, :quantity, :kind, :reason, :reference_id, :note, :created_by, :date_created)`
+
+	if _, err := tx.NamedExecContext(ctx, q, src.Movement(m, data.DateUpdated)); err != nil {
+		return fmt.Errorf("recording movement of %s %w", data.ProductID, err)
+	}
+	return nil
+}
+
+// LedgerBalance locks the level of the move as part of tx and returns
+// what its movements add up to. A level that never held stock is created
+// empty first, so stock put there by another transaction waits for tx and
+// can not slip in between the balance and what tx does with it.
+func LedgerBalance(ctx context.Context, tx *sqlx.Tx, m StockMove, now time.Time) (int, error) {
+	data := newLevelData(m, now)
+
+	ql := `
+	INSERT INTO stock_levels
+		(warehouse_id, product_id, variant_id, quantity, date_updated)
+	VALUES
+		(:warehouse_id, :product_id, :variant_id, 0, :date_updated)
+	ON CONFLICT (warehouse_id, product_id, COALESCE(variant_id, '` + noVariant + `')) DO NOTHING`
+
+	if _, err := tx.NamedExecContext(ctx, ql, data); err != nil {
+		return 0, fmt.Errorf("creating level of %s %w", m.ProductID, err)
+	}
+
+	q := `
+	SELECT
+		COALESCE(SUM(m.quantity), 0)
+	FROM
+		stock_movements AS m
+	WHERE
+		m.warehouse_id = :warehouse_id AND m.product_id = :product_id AND
+		COALESCE(m.variant_id, '` + noVariant + `') = :variant_key`
+
+	if _, err := lockLevel(tx, data); err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.NamedQuery(q, data)
+	if err != nil {
+		return 0, fmt.Errorf("selecting ledger of %s %w", m.ProductID, err)
+	}
+	defer rows.Close()
+
+	var balance int
+	for rows.Next() {
+		if err := rows.Scan(&balance); err != nil {
+			return 0, fmt.Errorf("selecting ledger of %s %w", m.ProductID, err)
+		}
+	}
+	return balance, nil
+}
+
+// lockLevel locks the level, if there is one, until tx ends and returns
+// what it holds.
+func lockLevel(tx *sqlx.Tx, data levelData) (int, error) {
+	q := `
+	SELECT
+		quantity
+	FROM
+		stock_levels
+	WHERE
+		warehouse_id = :warehouse_id AND product_id = :product_id AND
+		COALESCE(variant_id, '` + noVariant + `') = :variant_key
+	FOR UPDATE`
+
+	rows, err := tx.NamedQuery(q, data)
+	if err != nil {
+		return 0, fmt.Errorf("locking level of %s %w", data.ProductID, err)
+	}
+	defer rows.Close()
+
+	var quantity int
+	for rows.Next() {
+		if err := rows.Scan(&quantity); err != nil {
+			return 0, fmt.Errorf("locking level of %s %w", data.ProductID, err)
+		}
+	}
+	return quantity, nil
+}
+
+// =============================================================================
+
+// Adjust moves the stock of a level by hand, the quantity of the move is
+// added when positive and taken when negative. Taking more than the level
+// holds fails with ErrInsufficientStock.
+func (s Store) Adjust(ctx context.Context, src Source, m StockMove, now time.Time) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	if m.Quantity > 0 {
+		err = PutStock(ctx, tx, src, []StockMove{m}, now)
+	} else {
+		m.Quantity = -m.Quantity
+		err = TakeStock(ctx, tx, src, []StockMove{m}, now)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// QueryMovements returns a page of the ledger, latest first.
+func (s Store) QueryMovements(ctx context.Context, f MovementFilter, pageNumber int, rowsPerPage int) ([]Movement, error) {
+	data := struct {
+		WarehouseID string `db:"warehouse_id"`
+		ProductID   string `db:"product_id"`
+		Offset      int    `db:"offset"`
+		RowsPerPage int    `db:"rows_per_page"`
+	}{
+		WarehouseID: f.WarehouseID,
+		ProductID:   f.ProductID,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		stock_movements
+	WHERE
+		(CAST(:warehouse_id AS TEXT) = '' OR CAST(warehouse_id AS TEXT) = :warehouse_id) AND
+		(CAST(:product_id AS TEXT) = '' OR CAST(product_id AS TEXT) = :product_id)
+	ORDER BY
+		movement_id DESC
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var movements []Movement
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &movements); err != nil {
+		return nil, fmt.Errorf("selecting movements %w", err)
+	}
+	return movements, nil
+}
+
+// QueryDiscrepancies returns every level that does not hold what its
+// movements add up to, none when the ledger and the levels agree.
+func (s Store) QueryDiscrepancies(ctx context.Context) ([]Discrepancy, error) {
+	q := `
+	SELECT
+		COALESCE(l.warehouse_id, m.warehouse_id) AS warehouse_id,
+		COALESCE(l.product_id, m.product_id) AS product_id,
+		COALESCE(l.variant_id, m.variant_id) AS variant_id,
+		COALESCE(l.quantity, 0) AS level,
+		COALESCE(m.quantity, 0) AS ledger
+	FROM
+		stock_levels AS l
+	FULL OUTER JOIN (
+		SELECT
+			warehouse_id, product_id, variant_id, SUM(quantity) AS quantity
+		FROM
+			stock_movements
+		GROUP BY
+			warehouse_id, product_id, variant_id
+	) AS m ON
+		m.warehouse_id = l.warehouse_id AND m.product_id = l.product_id AND
+		COALESCE(m.variant_id, '` + noVariant + `') = COALESCE(l.variant_id, '` + noVariant + `')
+	WHERE
+		COALESCE(l.quantity, 0) <> COALESCE(m.quantity, 0)
+	ORDER BY
+		1, 2, 3`
+
+	var ds []Discrepancy
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &ds); err != nil {
+		return nil, fmt.Errorf("selecting discrepancies %w", err)
+	}
+	return ds, nil
+}
@@ -0,0 +1,186 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"service/domain/sys/database"
+	"time"
+)
+
+// LowStock is a product holding no more than its reorder point, Quantity
+// adds up the stock of the product and of its variants in every warehouse.
+// DateAlerted is when the product was found crossing its reorder point,
+// nil until then.
+type LowStock struct {
+	ProductID    string     `db:"product_id" json:"product_id"`
+	Name         string     `db:"name" json:"name"`
+	Quantity     int        `db:"quantity" json:"quantity"`
+	ReorderPoint int        `db:"reorder_point" json:"reorder_point"`
+	DateAlerted  *time.Time `db:"date_alerted" json:"date_alerted"`
+}
+
+// UpdateReorderPoint sets the stock a product is reordered at, zero turns
+// the low stock alerts of the product off.
+type UpdateReorderPoint struct {
+	ReorderPoint int `json:"reorder_point" validate:"gte=0"`
+}
+
+// onHand adds up the stock of the product p and of its variants.
+const onHand = `p.quantity + COALESCE((SELECT SUM(v.quantity) FROM product_variants AS v WHERE v.product_id = p.product_id), 0)`
+
+// clearLowStock forgets the alert of the product once it holds more than
+// its reorder point again, so the next time it runs low is a new crossing.
+func clearLowStock(ctx context.Context, tx *sqlx.Tx, productID string) error {
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	q := `
+	DELETE FROM
+		low_stock_alerts AS a
+	USING
+		products AS p
+	WHERE
+		a.product_id = :product_id AND p.product_id = a.product_id AND
+		` + onHand + ` > p.reorder_point`
+
+	if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("clearing low stock of %s %w", productID, err)
+	}
+	return nil
+}
+
+// =============================================================================
+
+// UpdateReorderPoint sets the reorder point of the product.
+func (s Store) UpdateReorderPoint(ctx context.Context, p Product) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	q := `
+	UPDATE products
+	SET reorder_point = :reorder_point, date_updated = :date_updated
+	WHERE product_id = :product_id`
+
+	if _, err := tx.NamedExecContext(ctx, q, p); err != nil {
+		return fmt.Errorf("updating reorder point of product %s %w", p.ID, err)
+	}
+
+	if err := clearLowStock(ctx, tx, p.ID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// ClaimLowStock finds the products that crossed their reorder point and
+// were not alerted on yet, and marks them alerted at now in the same
+// statement so no crossing is claimed twice. Only the products with the
+// ids are looked at, every product when there are none.
+func (s Store) ClaimLowStock(ctx context.Context, productIDs []string, now time.Time) ([]LowStock, error) {
+	data := struct {
+		ProductIDs pq.StringArray `db:"product_ids"`
+		Now        time.Time      `db:"now"`
+	}{
+		ProductIDs: append(pq.StringArray{}, productIDs...),
+		Now:        now,
+	}
+
+	q := `
+	WITH s AS (
+		SELECT
+			p.product_id, p.name, p.reorder_point, ` + onHand + ` AS quantity
+		FROM
+			products AS p
+		WHERE
+			p.reorder_point > 0 AND
+			(CARDINALITY(CAST(:product_ids AS UUID[])) = 0 OR p.product_id = ANY(CAST(:product_ids AS UUID[])))
+	), a AS (
+		INSERT INTO low_stock_alerts
+			(product_id, date_created)
+		SELECT
+			product_id, :now
+		FROM
+			s
+		WHERE
+			quantity <= reorder_point
+		ON CONFLICT DO NOTHING
+		RETURNING product_id, date_created
+	)
+	SELECT
+		s.product_id, s.name, s.quantity, s.reorder_point, a.date_created AS date_alerted
+	FROM
+		s
+	JOIN
+		a ON a.product_id = s.product_id
+	ORDER BY
+		s.name, s.product_id`
+
+	var lows []LowStock
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &lows); err != nil {
+		return nil, fmt.Errorf("claiming low stock %w", err)
+	}
+	return lows, nil
+}
+
+// ReleaseLowStock gives up the claim on the crossing of the product made
+// at claimed, so the next claim finds it again. A claim made at another
+// time is left alone.
+func (s Store) ReleaseLowStock(ctx context.Context, productID string, claimed time.Time) error {
+	data := struct {
+		ProductID string    `db:"product_id"`
+		Claimed   time.Time `db:"claimed"`
+	}{
+		ProductID: productID,
+		Claimed:   claimed,
+	}
+
+	q := `
+	DELETE FROM
+		low_stock_alerts
+	WHERE
+		product_id = :product_id AND date_created = :claimed`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("releasing low stock of %s %w", productID, err)
+	}
+	return nil
+}
+
+// QueryLowStock returns every product holding no more than its reorder
+// point, the lowest against its reorder point first.
+func (s Store) QueryLowStock(ctx context.Context) ([]LowStock, error) {
+	q := `
+	SELECT
+		s.product_id, s.name, s.quantity, s.reorder_point, a.date_created AS date_alerted
+	FROM (
+		SELECT
+			p.product_id, p.name, p.reorder_point, ` + onHand + ` AS quantity
+		FROM
+			products AS p
+		WHERE
+			p.reorder_point > 0
+	) AS s
+	LEFT JOIN
+		low_stock_alerts AS a ON a.product_id = s.product_id
+	WHERE
+		s.quantity <= s.reorder_point
+	ORDER BY
+		s.quantity - s.reorder_point, s.name, s.product_id`
+
+	var lows []LowStock
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &lows); err != nil {
+		return nil, fmt.Errorf("selecting low stock %w", err)
+	}
+	return lows, nil
+}
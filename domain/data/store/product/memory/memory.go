@@ -0,0 +1,824 @@
+// Package memory provides a thread safe in memory implementation of the
+// product store with the same semantics as the postgres store. It keeps
+// the stock levels of the warehouses too, the way the postgres store
+// shares its database with them.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"service/domain/data/store/product"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu               sync.Mutex
+	products         map[string]product.Product
+	variants         map[string]product.Variant
+	levels           map[levelKey]product.Level
+	movements        []product.Movement
+	alerts           map[string]time.Time
+	defaultWarehouse string
+}
+
+// levelKey identifies the level of a product, or of one of its variants,
+// in a warehouse.
+type levelKey struct {
+	warehouseID string
+	productID   string
+	variantID   string
+}
+
+func newLevelKey(warehouseID string, productID string, variantID *string) levelKey {
+	k := levelKey{warehouseID: warehouseID, productID: productID}
+	if variantID != nil {
+		k.variantID = *variantID
+	}
+	return k
+}
+
+// NewStore constructs a store whose initial quantities go into the main
+// warehouse, the default one until SetDefaultWarehouse says otherwise.
+func NewStore() *Store {
+	return &Store{
+		products:         make(map[string]product.Product),
+		variants:         make(map[string]product.Variant),
+		levels:           make(map[levelKey]product.Level),
+		alerts:           make(map[string]time.Time),
+		defaultWarehouse: warehouse.MainID,
+	}
+}
+
+// SetDefaultWarehouse makes the initial quantity of products and variants
+// created from now on go into the warehouse. The memory warehouse store
+// calls it where postgres reads the default warehouse from its table.
+func (s *Store) SetDefaultWarehouse(warehouseID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaultWarehouse = warehouseID
+}
+
+func (s *Store) Create(ctx context.Context, p product.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.products[p.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+
+	s.products[p.ID] = clone(p)
+	if p.Quantity > 0 {
+		src := product.Source{Kind: product.KindReceipt, CreatedBy: p.UserID}
+		s.put(src, product.StockMove{WarehouseID: s.defaultWarehouse, ProductID: p.ID, Quantity: p.Quantity}, p.DateUpdated, false)
+	}
+	return nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, productID string) (product.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.products[productID]
+	if !ok {
+		return product.Product{}, database.ErrNotFound
+	}
+	return clone(p), nil
+}
+
+func (s *Store) QueryByIDs(ctx context.Context, productIDs []string) ([]product.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prds := []product.Product{}
+	seen := make(map[string]bool)
+	for _, id := range productIDs {
+		p, ok := s.products[id]
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		prds = append(prds, clone(p))
+	}
+
+	sort.Slice(prds, func(i, j int) bool {
+		return prds[i].ID < prds[j].ID
+	})
+	return prds, nil
+}
+
+// Take takes the moves out of their warehouses along with the quantity of
+// their products or variants, and records them in the ledger for src.
+// Either every level has enough and all of them are taken, or none is.
+// The memory order and transfer stores use it the way the postgres stores
+// call product.TakeStock in their transaction.
+func (s *Store) Take(ctx context.Context, src product.Source, moves []product.StockMove, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.take(src, moves, now)
+}
+
+// Put puts the moves into their warehouses along with the quantity of
+// their products or variants, like product.PutStock.
+func (s *Store) Put(ctx context.Context, src product.Source, moves []product.StockMove, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, m := range moves {
+		s.put(src, m, now, true)
+	}
+	return nil
+}
+
+// take checks every level has enough before taking any of the moves.
+func (s *Store) take(src product.Source, moves []product.StockMove, now time.Time) error {
+	need := make(map[levelKey]int)
+	for _, m := range moves {
+		need[newLevelKey(m.WarehouseID, m.ProductID, m.VariantID)] += m.Quantity
+	}
+
+	for k, qty := range need {
+		if s.levels[k].Quantity < qty {
+			return fmt.Errorf("product %s in warehouse %s: %w", k.productID, k.warehouseID, product.ErrInsufficientStock)
+		}
+	}
+
+	for _, m := range moves {
+		m.Quantity = -m.Quantity
+		s.put(src, m, now, true)
+	}
+	return nil
+}
+
+// put adds the quantity of the move to its level, and to the quantity of
+// its product or variant when total is set, and records it in the ledger.
+func (s *Store) put(src product.Source, m product.StockMove, now time.Time, total bool) {
+	k := newLevelKey(m.WarehouseID, m.ProductID, m.VariantID)
+
+	l, ok := s.levels[k]
+	if !ok {
+		l = product.Level{WarehouseID: m.WarehouseID, ProductID: m.ProductID}
+		if m.VariantID != nil {
+			id := *m.VariantID
+			l.VariantID = &id
+		}
+	}
+	l.Quantity += m.Quantity
+	l.DateUpdated = now
+	s.levels[k] = l
+
+	mv := cloneMovement(src.Movement(m, now))
+	mv.ID = int64(len(s.movements) + 1)
+	s.movements = append(s.movements, mv)
+
+	if !total {
+		return
+	}
+
+	if m.Quantity > 0 {
+		defer s.clearLowStock(m.ProductID)
+	}
+
+	if m.VariantID != nil {
+		if v, ok := s.variants[*m.VariantID]; ok {
+			v.Quantity += m.Quantity
+			v.DateUpdated = now
+			s.variants[v.ID] = v
+		}
+		return
+	}
+
+	if p, ok := s.products[m.ProductID]; ok {
+		p.Quantity += m.Quantity
+		p.DateUpdated = now
+		s.products[p.ID] = p
+	}
+}
+
+func (s *Store) QueryLevels(ctx context.Context, productID string) ([]product.Level, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	levels := []product.Level{}
+	for _, l := range s.levels {
+		if l.ProductID == productID {
+			levels = append(levels, cloneLevel(l))
+		}
+	}
+
+	sort.Slice(levels, func(i, j int) bool {
+		a := newLevelKey(levels[i].WarehouseID, levels[i].ProductID, levels[i].VariantID)
+		b := newLevelKey(levels[j].WarehouseID, levels[j].ProductID, levels[j].VariantID)
+		if a.warehouseID != b.warehouseID {
+			return a.warehouseID < b.warehouseID
+		}
+		return a.variantID < b.variantID
+	})
+	return levels, nil
+}
+
+func (s *Store) SetLevel(ctx context.Context, src product.Source, l product.Level) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur := s.levels[newLevelKey(l.WarehouseID, l.ProductID, l.VariantID)].Quantity
+	if l.Quantity == cur {
+		return nil
+	}
+
+	s.put(src, product.StockMove{WarehouseID: l.WarehouseID, ProductID: l.ProductID, VariantID: l.VariantID, Quantity: l.Quantity - cur}, l.DateUpdated, true)
+	return nil
+}
+
+func (s *Store) Adjust(ctx context.Context, src product.Source, m product.StockMove, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.Quantity < 0 {
+		m.Quantity = -m.Quantity
+		return s.take(src, []product.StockMove{m}, now)
+	}
+
+	s.put(src, m, now, true)
+	return nil
+}
+
+// LedgerBalance returns what the movements of the level of the move add
+// up to, like product.LedgerBalance.
+func (s *Store) LedgerBalance(ctx context.Context, m product.StockMove) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := newLevelKey(m.WarehouseID, m.ProductID, m.VariantID)
+
+	var balance int
+	for _, mv := range s.movements {
+		if newLevelKey(mv.WarehouseID, mv.ProductID, mv.VariantID) == k {
+			balance += mv.Quantity
+		}
+	}
+	return balance
+}
+
+func (s *Store) QueryMovements(ctx context.Context, f product.MovementFilter, pageNumber int, rowsPerPage int) ([]product.Movement, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var movements []product.Movement
+	for i := len(s.movements) - 1; i >= 0; i-- {
+		mv := s.movements[i]
+		if (f.WarehouseID == "" || mv.WarehouseID == f.WarehouseID) && (f.ProductID == "" || mv.ProductID == f.ProductID) {
+			movements = append(movements, cloneMovement(mv))
+		}
+	}
+
+	start := (pageNumber - 1) * rowsPerPage
+	if start >= len(movements) {
+		return []product.Movement{}, nil
+	}
+
+	end := start + rowsPerPage
+	if end > len(movements) {
+		end = len(movements)
+	}
+	return movements[start:end], nil
+}
+
+func (s *Store) QueryDiscrepancies(ctx context.Context) ([]product.Discrepancy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ledger := make(map[levelKey]int)
+	for _, mv := range s.movements {
+		ledger[newLevelKey(mv.WarehouseID, mv.ProductID, mv.VariantID)] += mv.Quantity
+	}
+
+	keys := make(map[levelKey]product.Discrepancy)
+	for k, l := range s.levels {
+		keys[k] = product.Discrepancy{WarehouseID: l.WarehouseID, ProductID: l.ProductID, VariantID: l.VariantID}
+	}
+	for _, mv := range s.movements {
+		k := newLevelKey(mv.WarehouseID, mv.ProductID, mv.VariantID)
+		if _, ok := keys[k]; !ok {
+			keys[k] = product.Discrepancy{WarehouseID: mv.WarehouseID, ProductID: mv.ProductID, VariantID: mv.VariantID}
+		}
+	}
+
+	ds := []product.Discrepancy{}
+	for k, d := range keys {
+		d.Level = s.levels[k].Quantity
+		d.Ledger = ledger[k]
+		if d.Level != d.Ledger {
+			if d.VariantID != nil {
+				id := *d.VariantID
+				d.VariantID = &id
+			}
+			ds = append(ds, d)
+		}
+	}
+
+	sort.Slice(ds, func(i, j int) bool {
+		a := newLevelKey(ds[i].WarehouseID, ds[i].ProductID, ds[i].VariantID)
+		b := newLevelKey(ds[j].WarehouseID, ds[j].ProductID, ds[j].VariantID)
+		switch {
+		case a.warehouseID != b.warehouseID:
+			return a.warehouseID < b.warehouseID
+		case a.productID != b.productID:
+			return a.productID < b.productID
+		}
+		return a.variantID < b.variantID
+	})
+	return ds, nil
+}
+
+func (s *Store) UpdateCategory(ctx context.Context, p product.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.products[p.ID]
+	if !ok {
+		return nil
+	}
+
+	cur.CategoryID = p.CategoryID
+	cur.DateUpdated = p.DateUpdated
+	s.products[p.ID] = clone(cur)
+	return nil
+}
+
+func (s *Store) ReplaceTags(ctx context.Context, p product.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.products[p.ID]
+	if !ok {
+		return nil
+	}
+
+	cur.Tags = p.Tags
+	cur.DateUpdated = p.DateUpdated
+	s.products[p.ID] = clone(cur)
+	return nil
+}
+
+func (s *Store) CreateVariant(ctx context.Context, v product.Variant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.variants[v.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+	if s.skuTaken(v) {
+		return product.ErrUniqueSKU
+	}
+
+	s.variants[v.ID] = cloneVariant(v)
+	if v.Quantity > 0 {
+		src := product.Source{Kind: product.KindReceipt}
+		s.put(src, product.StockMove{WarehouseID: s.defaultWarehouse, ProductID: v.ProductID, VariantID: &v.ID, Quantity: v.Quantity}, v.DateUpdated, false)
+	}
+	return nil
+}
+
+func (s *Store) UpdateVariant(ctx context.Context, v product.Variant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.variants[v.ID]
+	if !ok {
+		return nil
+	}
+	if s.skuTaken(v) {
+		return product.ErrUniqueSKU
+	}
+
+	v.Quantity = cur.Quantity
+	s.variants[v.ID] = cloneVariant(v)
+	return nil
+}
+
+func (s *Store) QueryVariantByID(ctx context.Context, variantID string) (product.Variant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.variants[variantID]
+	if !ok {
+		return product.Variant{}, database.ErrNotFound
+	}
+	return cloneVariant(v), nil
+}
+
+func (s *Store) QueryVariantsByProducts(ctx context.Context, productIDs []string) ([]product.Variant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make(map[string]bool, len(productIDs))
+	for _, id := range productIDs {
+		ids[id] = true
+	}
+
+	vars := []product.Variant{}
+	for _, v := range s.variants {
+		if ids[v.ProductID] {
+			vars = append(vars, cloneVariant(v))
+		}
+	}
+
+	sort.Slice(vars, func(i, j int) bool {
+		if vars[i].ProductID != vars[j].ProductID {
+			return vars[i].ProductID < vars[j].ProductID
+		}
+		return vars[i].SKU < vars[j].SKU
+	})
+	return vars, nil
+}
+
+// skuTaken reports whether another variant already has the sku of v.
+func (s *Store) skuTaken(v product.Variant) bool {
+	for _, other := range s.variants {
+		if other.ID != v.ID && other.SKU == v.SKU {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) Query(ctx context.Context, f product.Filter, pageNumber int, rowsPerPage int) ([]product.Product, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prds := []product.Product{}
+	for _, p := range s.products {
+		if matches(p, f) {
+			prds = append(prds, clone(p))
+		}
+	}
+
+	sort.Slice(prds, func(i, j int) bool {
+		if prds[i].Name != prds[j].Name {
+			return prds[i].Name < prds[j].Name
+		}
+		return prds[i].ID < prds[j].ID
+	})
+
+	start := (pageNumber - 1) * rowsPerPage
+	if start >= len(prds) {
+		return []product.Product{}, nil
+	}
+
+	end := start + rowsPerPage
+	if end > len(prds) {
+		end = len(prds)
+	}
+	return prds[start:end], nil
+}
+
+func (s *Store) CountByCategory(ctx context.Context, f product.Filter) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, p := range s.products {
+		if p.CategoryID != nil && matches(p, f) {
+			counts[*p.CategoryID]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *Store) QueryTags(ctx context.Context) ([]product.TagCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, p := range s.products {
+		for _, tag := range p.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := []product.TagCount{}
+	for tag, n := range counts {
+		tags = append(tags, product.TagCount{Tag: tag, Count: n})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags, nil
+}
+
+func (s *Store) Search(ctx context.Context, query string, limit int) ([]product.Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	terms := product.SearchTerms(query)
+	if len(terms) == 0 {
+		return []product.Match{}, nil
+	}
+
+	matches := []product.Match{}
+	for _, p := range s.products {
+		if rank, ok := searchRank(p.Name, terms); ok {
+			matches = append(matches, product.Match{
+				Product: clone(p),
+				Rank:    rank,
+				Snippet: product.Highlight(p.Name, terms),
+			})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		switch {
+		case matches[i].Rank != matches[j].Rank:
+			return matches[i].Rank > matches[j].Rank
+		case matches[i].Name != matches[j].Name:
+			return matches[i].Name < matches[j].Name
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// searchRank reports whether every term starts a word of the name, and
+// ranks the name by the share of its words matched. It stands in for
+// ts_rank, which is not worth reproducing exactly.
+func searchRank(name string, terms []string) (float64, bool) {
+	words := product.SearchTerms(name)
+
+	for _, t := range terms {
+		found := false
+		for _, w := range words {
+			if product.MatchesTerms(w, []string{t}) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+
+	var matched int
+	for _, w := range words {
+		if product.MatchesTerms(w, terms) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(words)), true
+}
+
+// matches reports whether the product is filed under one of the categories
+// of the filter and carries all of its tags.
+func matches(p product.Product, f product.Filter) bool {
+	if f.CategoryIDs != nil {
+		if p.CategoryID == nil {
+			return false
+		}
+
+		found := false
+		for _, id := range f.CategoryIDs {
+			if id == *p.CategoryID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, want := range f.Tags {
+		found := false
+		for _, tag := range p.Tags {
+			if tag == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// clone makes sure callers never share the category id or the tags with
+// the stored product, the tags are kept sorted like postgres returns
+// them.
+func clone(p product.Product) product.Product {
+	if p.CategoryID != nil {
+		id := *p.CategoryID
+		p.CategoryID = &id
+	}
+
+	p.Tags = append([]string{}, p.Tags...)
+	sort.Strings(p.Tags)
+	return p
+}
+
+// cloneVariant makes sure callers never share the price or the attributes
+// with the stored variant.
+func cloneVariant(v product.Variant) product.Variant {
+	if v.Price != nil {
+		price := *v.Price
+		v.Price = &price
+	}
+
+	attrs := make(product.Attributes, len(v.Attributes))
+	for k, val := range v.Attributes {
+		attrs[k] = val
+	}
+	v.Attributes = attrs
+	return v
+}
+
+// cloneLevel makes sure callers never share the variant id with the
+// stored level.
+func cloneLevel(l product.Level) product.Level {
+	if l.VariantID != nil {
+		id := *l.VariantID
+		l.VariantID = &id
+	}
+	return l
+}
+
+// cloneMovement makes sure callers never share the pointers of the stored
+// movement.
+func cloneMovement(mv product.Movement) product.Movement {
+	for _, p := range []**string{&mv.VariantID, &mv.ReferenceID, &mv.CreatedBy} {
+		if *p != nil {
+			id := **p
+			*p = &id
+		}
+	}
+	return mv
+}
+
+func (s *Store) UpdateReorderPoint(ctx context.Context, p product.Product) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.products[p.ID]
+	if !ok {
+		return nil
+	}
+
+	cur.ReorderPoint = p.ReorderPoint
+	cur.DateUpdated = p.DateUpdated
+	s.products[p.ID] = clone(cur)
+
+	s.clearLowStock(p.ID)
+	return nil
+}
+
+func (s *Store) ClaimLowStock(ctx context.Context, productIDs []string, now time.Time) ([]product.LowStock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := productIDs
+	if len(ids) == 0 {
+		for id := range s.products {
+			ids = append(ids, id)
+		}
+	}
+
+	lows := []product.LowStock{}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		low, ok := s.lowStock(id)
+		if !ok {
+			continue
+		}
+		if _, alerted := s.alerts[id]; alerted {
+			continue
+		}
+
+		s.alerts[id] = now
+		at := now
+		low.DateAlerted = &at
+		lows = append(lows, low)
+	}
+
+	sort.Slice(lows, func(i, j int) bool {
+		if lows[i].Name != lows[j].Name {
+			return lows[i].Name < lows[j].Name
+		}
+		return lows[i].ProductID < lows[j].ProductID
+	})
+	return lows, nil
+}
+
+func (s *Store) ReleaseLowStock(ctx context.Context, productID string, claimed time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if at, ok := s.alerts[productID]; ok && at.Equal(claimed) {
+		delete(s.alerts, productID)
+	}
+	return nil
+}
+
+func (s *Store) QueryLowStock(ctx context.Context) ([]product.LowStock, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lows := []product.LowStock{}
+	for id := range s.products {
+		low, ok := s.lowStock(id)
+		if !ok {
+			continue
+		}
+		if at, alerted := s.alerts[id]; alerted {
+			low.DateAlerted = &at
+		}
+		lows = append(lows, low)
+	}
+
+	sort.Slice(lows, func(i, j int) bool {
+		a, b := lows[i].Quantity-lows[i].ReorderPoint, lows[j].Quantity-lows[j].ReorderPoint
+		switch {
+		case a != b:
+			return a < b
+		case lows[i].Name != lows[j].Name:
+			return lows[i].Name < lows[j].Name
+		}
+		return lows[i].ProductID < lows[j].ProductID
+	})
+	return lows, nil
+}
+
+// lowStock returns the product when it is watched and holds no more than
+// its reorder point. It must be called with the lock held.
+func (s *Store) lowStock(productID string) (product.LowStock, bool) {
+	p, ok := s.products[productID]
+	if !ok || p.ReorderPoint <= 0 {
+		return product.LowStock{}, false
+	}
+
+	qty := s.onHand(p)
+	if qty > p.ReorderPoint {
+		return product.LowStock{}, false
+	}
+
+	low := product.LowStock{
+		ProductID:    p.ID,
+		Name:         p.Name,
+		Quantity:     qty,
+		ReorderPoint: p.ReorderPoint,
+	}
+	return low, true
+}
+
+// clearLowStock forgets the alert of the product once it holds more than
+// its reorder point again. It must be called with the lock held.
+func (s *Store) clearLowStock(productID string) {
+	p, ok := s.products[productID]
+	if ok && s.onHand(p) <= p.ReorderPoint {
+		return
+	}
+	delete(s.alerts, productID)
+}
+
+// onHand adds up the stock of the product and of its variants. It must be
+// called with the lock held.
+func (s *Store) onHand(p product.Product) int {
+	qty := p.Quantity
+	for _, v := range s.variants {
+		if v.ProductID == p.ID {
+			qty += v.Quantity
+		}
+	}
+	return qty
+}
+
+// AddPurchaseCost averages quantity units bought at unitCost into the
+// purchase cost of the product. Like the postgres store it must be called
+// before the units are put in stock.
+func (s *Store) AddPurchaseCost(ctx context.Context, productID string, quantity int, unitCost int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.products[productID]
+	if !ok {
+		return nil
+	}
+
+	p.PurchaseCost = product.AveragePurchaseCost(s.onHand(p), p.PurchaseCost, quantity, unitCost)
+	s.products[productID] = p
+	return nil
+}
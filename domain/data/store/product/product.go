@@ -0,0 +1,117 @@
+package product
+
+import (
+	"html"
+	"service/foundation/money"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Product is something we sell. Cost is the price of one unit in minor
+// units of Currency and Quantity the units in stock. PurchaseCost is what
+// a unit cost us on average over the goods received, in the same currency.
+// ReorderPoint is the stock the product is reordered at, zero when it is
+// not watched. A product is filed under at most one category and carries
+// any number of tags. Search is the text search vector postgres keeps from
+// the name, it is never set by hand.
+type Product struct {
+	ID           string         `db:"product_id" json:"id"`
+	Name         string         `db:"name" json:"name"`
+	Cost         int            `db:"cost" json:"cost"`
+	Currency     money.Currency `db:"currency" json:"currency"`
+	Quantity     int            `db:"quantity" json:"quantity"`
+	PurchaseCost int            `db:"purchase_cost" json:"purchase_cost"`
+	ReorderPoint int            `db:"reorder_point" json:"reorder_point"`
+	CategoryID   *string        `db:"category_id" json:"category_id"`
+	Tags         []string       `db:"-" json:"tags"`
+	Search       string         `db:"search" json:"-"`
+	UserID       string         `db:"user_id" json:"user_id"`
+	DateCreated  time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+// Price returns the cost of one unit as money.
+func (p Product) Price() money.Money {
+	return money.New(int64(p.Cost), p.Currency)
+}
+
+// Filter narrows the products returned. Products must be filed under one
+// of the categories and carry every tag. A nil field does not filter.
+type Filter struct {
+	CategoryIDs []string
+	Tags        []string
+}
+
+// TagCount is a tag along with how many products carry it.
+type TagCount struct {
+	Tag   string `db:"tag" json:"tag"`
+	Count int    `db:"count" json:"count"`
+}
+
+// UpdateCategory files a product under a category, or under none when
+// the id is null.
+type UpdateCategory struct {
+	CategoryID *string `json:"category_id" validate:"omitempty,uuid"`
+}
+
+// UpdateTags replaces the tags of a product.
+type UpdateTags struct {
+	Tags []string `json:"tags" validate:"max=20,dive,required,max=50"`
+}
+
+// Match is a product found by a search. Rank orders the matches, the
+// higher the better, and Snippet is the HTML escaped name with the words
+// matched wrapped in <b></b>.
+type Match struct {
+	Product
+	Rank    float64 `db:"rank" json:"rank"`
+	Snippet string  `db:"snippet" json:"snippet"`
+}
+
+// SearchTerms splits what was typed into the lowercase words a search
+// looks for, anything but letters and digits separates words. Every term
+// matches the start of a word of the name, so "kni" finds "Knight".
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// Highlight escapes the name for HTML and wraps every word starting with
+// one of the terms in <b></b>.
+func Highlight(name string, terms []string) string {
+	var b strings.Builder
+
+	runes := []rune(name)
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) == isWordRune(runes[i]) {
+			j++
+		}
+
+		part := string(runes[i:j])
+		if isWordRune(runes[i]) && MatchesTerms(part, terms) {
+			b.WriteString("<b>" + html.EscapeString(part) + "</b>")
+		} else {
+			b.WriteString(html.EscapeString(part))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// MatchesTerms reports whether the word starts with one of the terms.
+func MatchesTerms(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, t := range terms {
+		if strings.HasPrefix(word, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
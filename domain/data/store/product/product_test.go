@@ -0,0 +1,378 @@
+package product_test
+
+import (
+	"context"
+	"errors"
+	"service/domain/core/product"
+	categoryStore "service/domain/data/store/category"
+	categoryMemory "service/domain/data/store/category/memory"
+	productStore "service/domain/data/store/product"
+	"service/domain/data/store/product/memory"
+	"service/domain/data/store/warehouse"
+	"service/domain/data/tests"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+// adminID is the seeded admin, who owns the products.
+const adminID = "5cf37266-3473-4006-984f-9325122678b7"
+
+type categoryCreator interface {
+	Create(ctx context.Context, c categoryStore.Category) error
+}
+
+type productCreator interface {
+	product.Storer
+	Create(ctx context.Context, p productStore.Product) error
+	ClaimLowStock(ctx context.Context, productIDs []string, now time.Time) ([]productStore.LowStock, error)
+	ReleaseLowStock(ctx context.Context, productID string, claimed time.Time) error
+}
+
+func TestMemory(t *testing.T) {
+	catalog(t, memory.NewStore(), categoryMemory.NewStore())
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	catalog(t, productStore.NewStore(logger, db), categoryStore.NewStore(logger, db))
+}
+
+func catalog(t *testing.T, store productCreator, categories categoryCreator) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	newCategory := func(name string) categoryStore.Category {
+		t.Helper()
+
+		c := categoryStore.Category{
+			ID:          validate.GenerateUID(),
+			Name:        name,
+			Slug:        categoryStore.Slugify(name + " " + validate.GenerateUID()[:8]),
+			DateCreated: now,
+			DateUpdated: now,
+		}
+		if err := categories.Create(ctx, c); err != nil {
+			t.Fatalf("\t%s\t Should be able to create category %s: %v", tests.Failed, name, err)
+		}
+		return c
+	}
+
+	newProduct := func(name string, c categoryStore.Category, tags ...string) productStore.Product {
+		t.Helper()
+
+		p := productStore.Product{
+			ID:          validate.GenerateUID(),
+			Name:        name,
+			Cost:        100,
+			Currency:    money.USD,
+			Quantity:    1,
+			CategoryID:  &c.ID,
+			Tags:        tags,
+			UserID:      adminID,
+			DateCreated: now,
+			DateUpdated: now,
+		}
+		if err := store.Create(ctx, p); err != nil {
+			t.Fatalf("\t%s\t Should be able to create product %s: %v", tests.Failed, name, err)
+		}
+		return p
+	}
+
+	board := newCategory("Board Games")
+	video := newCategory("Video Games")
+
+	chess := newProduct("Chess", board, "classic", "two-players")
+	goGame := newProduct("Go", board, "classic")
+	pong := newProduct("Pong", video, "classic", "two-players")
+
+	t.Log("Given the need to browse the catalog")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When filtering products", testID)
+		{
+			got, err := store.Query(ctx, productStore.Filter{CategoryIDs: []string{board.ID, video.ID}, Tags: []string{"two-players"}}, 1, 10)
+			if err != nil || len(got) != 2 || got[0].ID != chess.ID || got[1].ID != pong.ID {
+				t.Fatalf("\t%s\t Test %d Should keep the products carrying every tag, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should keep the products carrying every tag", tests.Succeeded, testID)
+
+			got, err = store.Query(ctx, productStore.Filter{CategoryIDs: []string{board.ID}}, 1, 10)
+			if err != nil || len(got) != 2 || got[1].ID != goGame.ID || len(got[0].Tags) != 2 {
+				t.Fatalf("\t%s\t Test %d Should keep the products of the categories with their tags, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should keep the products of the categories with their tags", tests.Succeeded, testID)
+
+			got, err = store.Query(ctx, productStore.Filter{CategoryIDs: []string{board.ID}}, 2, 1)
+			if err != nil || len(got) != 1 || got[0].ID != goGame.ID {
+				t.Fatalf("\t%s\t Test %d Should page through the products, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should page through the products", tests.Succeeded, testID)
+
+			counts, err := store.CountByCategory(ctx, productStore.Filter{Tags: []string{"classic"}})
+			if err != nil || counts[board.ID] != 2 || counts[video.ID] != 1 {
+				t.Fatalf("\t%s\t Test %d Should count the products of every category, got %v %v", tests.Failed, testID, counts, err)
+			}
+			t.Logf("\t%s\t Test %d Should count the products of every category", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When changing tags and categories", testID)
+		{
+			goGame.Tags = []string{"strategy"}
+			if err := store.ReplaceTags(ctx, goGame); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to replace the tags: %v", tests.Failed, testID, err)
+			}
+
+			pong.CategoryID = &board.ID
+			if err := store.UpdateCategory(ctx, pong); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to change the category: %v", tests.Failed, testID, err)
+			}
+
+			got, err := store.QueryByID(ctx, goGame.ID)
+			if err != nil || len(got.Tags) != 1 || got.Tags[0] != "strategy" {
+				t.Fatalf("\t%s\t Test %d Should replace the tags, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should replace the tags", tests.Succeeded, testID)
+
+			counts, err := store.CountByCategory(ctx, productStore.Filter{CategoryIDs: []string{board.ID, video.ID}})
+			if err != nil || counts[board.ID] != 3 || counts[video.ID] != 0 {
+				t.Fatalf("\t%s\t Test %d Should move the product, got %v %v", tests.Failed, testID, counts, err)
+			}
+			t.Logf("\t%s\t Test %d Should move the product", tests.Succeeded, testID)
+
+			tags, err := store.QueryTags(ctx)
+			if err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to list the tags: %v", tests.Failed, testID, err)
+			}
+
+			want := map[string]int{"classic": 2, "strategy": 1, "two-players": 2}
+			for _, tag := range tags {
+				if n, ok := want[tag.Tag]; ok && n != tag.Count {
+					t.Fatalf("\t%s\t Test %d Should count the products of every tag, got %+v", tests.Failed, testID, tags)
+				}
+				delete(want, tag.Tag)
+			}
+			if len(want) != 0 {
+				t.Fatalf("\t%s\t Test %d Should list every tag in use, missing %v", tests.Failed, testID, want)
+			}
+			t.Logf("\t%s\t Test %d Should list every tag in use", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When searching products by name", testID)
+		{
+			knight := newProduct("Knight", board)
+			rider := newProduct("Knight Rider Figure", video)
+			rook := newProduct("Rook & Knight Set", board)
+
+			got, err := store.Search(ctx, "KNI", 10)
+			if err != nil || len(got) != 3 || got[0].ID != knight.ID {
+				t.Fatalf("\t%s\t Test %d Should rank the shortest name first, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should rank the shortest name first", tests.Succeeded, testID)
+
+			got, err = store.Search(ctx, "kn, ri", 10)
+			if err != nil || len(got) != 1 || got[0].ID != rider.ID || got[0].Snippet != "<b>Knight</b> <b>Rider</b> Figure" {
+				t.Fatalf("\t%s\t Test %d Should match the start of every word typed, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should match the start of every word typed", tests.Succeeded, testID)
+
+			got, err = store.Search(ctx, "rook knight", 10)
+			if err != nil || len(got) != 1 || got[0].ID != rook.ID || got[0].Snippet != "<b>Rook</b> &amp; <b>Knight</b> Set" {
+				t.Fatalf("\t%s\t Test %d Should escape the snippet, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should escape the snippet", tests.Succeeded, testID)
+
+			got, err = store.Search(ctx, "knight", 1)
+			if err != nil || len(got) != 1 || got[0].ID != knight.ID {
+				t.Fatalf("\t%s\t Test %d Should stop at the limit, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should stop at the limit", tests.Succeeded, testID)
+
+			got, err = store.Search(ctx, "&!", 10)
+			if err != nil || len(got) != 0 {
+				t.Fatalf("\t%s\t Test %d Should find nothing without a word, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should find nothing without a word", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When adding variants", testID)
+		{
+			shirt := newProduct("Shirt", board)
+			sku := "SHIRT-" + shirt.ID[:8]
+			price := 2500
+
+			red := productStore.Variant{ID: validate.GenerateUID(), ProductID: shirt.ID, SKU: sku + "-RED", Attributes: productStore.Attributes{"colour": "red", "size": "M"}, Price: &price, Quantity: 4, DateCreated: now, DateUpdated: now}
+			blue := productStore.Variant{ID: validate.GenerateUID(), ProductID: shirt.ID, SKU: sku + "-BLUE", Attributes: productStore.Attributes{}, Quantity: 2, DateCreated: now, DateUpdated: now}
+			for _, v := range []productStore.Variant{red, blue} {
+				if err := store.CreateVariant(ctx, v); err != nil {
+					t.Fatalf("\t%s\t Test %d Should be able to create a variant: %v", tests.Failed, testID, err)
+				}
+			}
+
+			dup := blue
+			dup.ID = validate.GenerateUID()
+			if err := store.CreateVariant(ctx, dup); !errors.Is(err, productStore.ErrUniqueSKU) {
+				t.Fatalf("\t%s\t Test %d Should refuse a sku in use, got %v", tests.Failed, testID, err)
+			}
+
+			blue.SKU = red.SKU
+			if err := store.UpdateVariant(ctx, blue); !errors.Is(err, productStore.ErrUniqueSKU) {
+				t.Fatalf("\t%s\t Test %d Should refuse to take a sku in use, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should keep every sku unique", tests.Succeeded, testID)
+
+			red.Quantity = 99
+			red.Attributes["colour"] = "crimson"
+			if err := store.UpdateVariant(ctx, red); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to update a variant: %v", tests.Failed, testID, err)
+			}
+
+			got, err := store.QueryVariantByID(ctx, red.ID)
+			if err != nil || got.Quantity != 4 || got.Attributes["colour"] != "crimson" || got.Price == nil || *got.Price != price {
+				t.Fatalf("\t%s\t Test %d Should read back the variant, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should read back the variant", tests.Succeeded, testID)
+
+			vars, err := store.QueryVariantsByProducts(ctx, []string{shirt.ID, chess.ID})
+			if err != nil || len(vars) != 2 || vars[0].SKU != sku+"-BLUE" || vars[0].Price != nil || len(vars[0].Attributes) != 0 {
+				t.Fatalf("\t%s\t Test %d Should list the variants by sku, got %+v %v", tests.Failed, testID, vars, err)
+			}
+			t.Logf("\t%s\t Test %d Should list the variants by sku", tests.Succeeded, testID)
+
+			levels, err := store.QueryLevels(ctx, shirt.ID)
+			if err != nil || len(levels) != 3 || levels[2].WarehouseID != warehouse.MainID {
+				t.Fatalf("\t%s\t Test %d Should stock the variants in the default warehouse, got %+v %v", tests.Failed, testID, levels, err)
+			}
+			t.Logf("\t%s\t Test %d Should stock the variants in the default warehouse", tests.Succeeded, testID)
+
+			count := productStore.Level{WarehouseID: warehouse.MainID, ProductID: shirt.ID, VariantID: &red.ID, Quantity: 1, DateUpdated: now}
+			src := productStore.Source{Kind: productStore.KindAdjustment, Reason: productStore.ReasonCount, CreatedBy: adminID}
+			if err := store.SetLevel(ctx, src, count); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to count the stock: %v", tests.Failed, testID, err)
+			}
+
+			got, err = store.QueryVariantByID(ctx, red.ID)
+			if err != nil || got.Quantity != 1 {
+				t.Fatalf("\t%s\t Test %d Should move the variant to what was counted, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should move the variant to what was counted", tests.Succeeded, testID)
+
+			lost := productStore.StockMove{WarehouseID: warehouse.MainID, ProductID: shirt.ID, VariantID: &red.ID, Quantity: -2}
+			src = productStore.Source{Kind: productStore.KindAdjustment, Reason: productStore.ReasonLost, CreatedBy: adminID}
+			if err := store.Adjust(ctx, src, lost, now); !errors.Is(err, productStore.ErrInsufficientStock) {
+				t.Fatalf("\t%s\t Test %d Should refuse to take more than the level holds, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse to take more than the level holds", tests.Succeeded, testID)
+
+			found := productStore.StockMove{WarehouseID: warehouse.MainID, ProductID: shirt.ID, VariantID: &red.ID, Quantity: 3}
+			src = productStore.Source{Kind: productStore.KindAdjustment, Reason: productStore.ReasonFound, Note: "behind the shelf", CreatedBy: adminID}
+			if err := store.Adjust(ctx, src, found, now); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to adjust the stock: %v", tests.Failed, testID, err)
+			}
+
+			got, err = store.QueryVariantByID(ctx, red.ID)
+			if err != nil || got.Quantity != 4 {
+				t.Fatalf("\t%s\t Test %d Should add the adjustment to the variant, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should add the adjustment to the variant", tests.Succeeded, testID)
+
+			mvs, err := store.QueryMovements(ctx, productStore.MovementFilter{ProductID: shirt.ID}, 1, 10)
+			if err != nil || len(mvs) != 5 {
+				t.Fatalf("\t%s\t Test %d Should record every movement of the product, got %+v %v", tests.Failed, testID, mvs, err)
+			}
+			if mvs[0].Reason != productStore.ReasonFound || mvs[0].Quantity != 3 || mvs[0].Note != "behind the shelf" ||
+				mvs[1].Reason != productStore.ReasonCount || mvs[1].Quantity != -3 || mvs[4].Kind != productStore.KindReceipt {
+				t.Fatalf("\t%s\t Test %d Should list the movements latest first, got %+v", tests.Failed, testID, mvs)
+			}
+			t.Logf("\t%s\t Test %d Should record every movement of the product", tests.Succeeded, testID)
+
+			ds, err := store.QueryDiscrepancies(ctx)
+			if err != nil || len(ds) != 0 {
+				t.Fatalf("\t%s\t Test %d Should find the levels and the ledger agree, got %+v %v", tests.Failed, testID, ds, err)
+			}
+			t.Logf("\t%s\t Test %d Should find the levels and the ledger agree", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When stock runs below the reorder point", testID)
+		{
+			dice := newProduct("Dice", board)
+
+			lows, err := store.QueryLowStock(ctx)
+			if err != nil || len(lows) != 0 {
+				t.Fatalf("\t%s\t Test %d Should not watch products without a reorder point, got %+v %v", tests.Failed, testID, lows, err)
+			}
+			t.Logf("\t%s\t Test %d Should not watch products without a reorder point", tests.Succeeded, testID)
+
+			dice.ReorderPoint = 2
+			if err := store.UpdateReorderPoint(ctx, dice); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to set the reorder point: %v", tests.Failed, testID, err)
+			}
+
+			lows, err = store.QueryLowStock(ctx)
+			if err != nil || len(lows) != 1 || lows[0].ProductID != dice.ID || lows[0].Quantity != 1 || lows[0].DateAlerted != nil {
+				t.Fatalf("\t%s\t Test %d Should report the product as low, got %+v %v", tests.Failed, testID, lows, err)
+			}
+			t.Logf("\t%s\t Test %d Should report the product as low", tests.Succeeded, testID)
+
+			lows, err = store.ClaimLowStock(ctx, []string{dice.ID, chess.ID}, now)
+			if err != nil || len(lows) != 1 || lows[0].ProductID != dice.ID || lows[0].DateAlerted == nil {
+				t.Fatalf("\t%s\t Test %d Should claim the crossing, got %+v %v", tests.Failed, testID, lows, err)
+			}
+			t.Logf("\t%s\t Test %d Should claim the crossing", tests.Succeeded, testID)
+			claimed := *lows[0].DateAlerted
+
+			lows, err = store.ClaimLowStock(ctx, nil, now)
+			if err != nil || len(lows) != 0 {
+				t.Fatalf("\t%s\t Test %d Should claim a crossing only once, got %+v %v", tests.Failed, testID, lows, err)
+			}
+			t.Logf("\t%s\t Test %d Should claim a crossing only once", tests.Succeeded, testID)
+
+			if err := store.ReleaseLowStock(ctx, dice.ID, claimed.Add(time.Second)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to release a claim: %v", tests.Failed, testID, err)
+			}
+			if lows, err = store.ClaimLowStock(ctx, nil, now); err != nil || len(lows) != 0 {
+				t.Fatalf("\t%s\t Test %d Should keep a claim made at another time, got %+v %v", tests.Failed, testID, lows, err)
+			}
+			t.Logf("\t%s\t Test %d Should keep a claim made at another time", tests.Succeeded, testID)
+
+			if err := store.ReleaseLowStock(ctx, dice.ID, claimed); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to release a claim: %v", tests.Failed, testID, err)
+			}
+			if lows, err = store.ClaimLowStock(ctx, nil, now); err != nil || len(lows) != 1 || lows[0].ProductID != dice.ID {
+				t.Fatalf("\t%s\t Test %d Should claim a released crossing again, got %+v %v", tests.Failed, testID, lows, err)
+			}
+			t.Logf("\t%s\t Test %d Should claim a released crossing again", tests.Succeeded, testID)
+
+			found := productStore.StockMove{WarehouseID: warehouse.MainID, ProductID: dice.ID, Quantity: 4}
+			src := productStore.Source{Kind: productStore.KindAdjustment, Reason: productStore.ReasonFound, CreatedBy: adminID}
+			if err := store.Adjust(ctx, src, found, now); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to adjust the stock: %v", tests.Failed, testID, err)
+			}
+
+			lost := productStore.StockMove{WarehouseID: warehouse.MainID, ProductID: dice.ID, Quantity: -3}
+			src = productStore.Source{Kind: productStore.KindAdjustment, Reason: productStore.ReasonLost, CreatedBy: adminID}
+			if err := store.Adjust(ctx, src, lost, now); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to adjust the stock: %v", tests.Failed, testID, err)
+			}
+
+			lows, err = store.ClaimLowStock(ctx, nil, now)
+			if err != nil || len(lows) != 1 || lows[0].ProductID != dice.ID || lows[0].Quantity != 2 {
+				t.Fatalf("\t%s\t Test %d Should claim the product again once restocked and sold, got %+v %v", tests.Failed, testID, lows, err)
+			}
+			t.Logf("\t%s\t Test %d Should claim the product again once restocked and sold", tests.Succeeded, testID)
+		}
+	}
+}
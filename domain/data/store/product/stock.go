@@ -0,0 +1,270 @@
+package product
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"service/domain/sys/database"
+	"sort"
+	"time"
+)
+
+// Level is how many units of a product, or of one of its variants, a
+// warehouse holds. The quantity of a product or a variant is the sum of
+// its levels in every warehouse.
+type Level struct {
+	WarehouseID string    `db:"warehouse_id" json:"warehouse_id"`
+	ProductID   string    `db:"product_id" json:"product_id"`
+	VariantID   *string   `db:"variant_id" json:"variant_id"`
+	Quantity    int       `db:"quantity" json:"quantity"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewLevel is a count of how many units of a product, or of one of its
+// variants, a warehouse holds.
+type NewLevel struct {
+	ProductID string  `json:"product_id" validate:"required,uuid"`
+	VariantID *string `json:"variant_id" validate:"omitempty,uuid"`
+	Quantity  int     `json:"quantity" validate:"gte=0"`
+}
+
+// StockMove is a quantity of a product, or of one of its variants, going
+// in or out of a warehouse.
+type StockMove struct {
+	WarehouseID string
+	ProductID   string
+	VariantID   *string
+	Quantity    int
+}
+
+// noVariant stands in for the variant of a level that has none, the levels
+// are unique on it.
+const noVariant = "00000000-0000-0000-0000-000000000000"
+
+// levelData binds a move to the statements below.
+type levelData struct {
+	WarehouseID string    `db:"warehouse_id"`
+	ProductID   string    `db:"product_id"`
+	VariantID   *string   `db:"variant_id"`
+	VariantKey  string    `db:"variant_key"`
+	Quantity    int       `db:"quantity"`
+	DateUpdated time.Time `db:"date_updated"`
+}
+
+func newLevelData(m StockMove, now time.Time) levelData {
+	key := noVariant
+	if m.VariantID != nil {
+		key = *m.VariantID
+	}
+
+	return levelData{
+		WarehouseID: m.WarehouseID,
+		ProductID:   m.ProductID,
+		VariantID:   m.VariantID,
+		VariantKey:  key,
+		Quantity:    m.Quantity,
+		DateUpdated: now,
+	}
+}
+
+// TakeStock takes the moves out of their warehouses as part of tx, along
+// with the quantity of their products or variants, and records them in the
+// ledger for src. Either every level has enough or ErrInsufficientStock is
+// returned and tx must be rolled back. Levels are updated in a fixed order
+// so concurrent callers can not deadlock.
+func TakeStock(ctx context.Context, tx *sqlx.Tx, src Source, moves []StockMove, now time.Time) error {
+	q := `
+	UPDATE stock_levels
+	SET quantity = quantity - :quantity, date_updated = :date_updated
+	WHERE
+		warehouse_id = :warehouse_id AND product_id = :product_id AND
+		COALESCE(variant_id, '` + noVariant + `') = :variant_key AND
+		quantity >= :quantity`
+
+	for _, m := range sortMoves(moves) {
+		data := newLevelData(m, now)
+
+		res, err := tx.NamedExecContext(ctx, q, data)
+		if err != nil {
+			return fmt.Errorf("taking stock of %s %w", m.ProductID, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("taking stock of %s %w", m.ProductID, err)
+		}
+		if n == 0 {
+			return fmt.Errorf("product %s in warehouse %s: %w", m.ProductID, m.WarehouseID, ErrInsufficientStock)
+		}
+
+		data.Quantity = -m.Quantity
+		if err := addTotal(ctx, tx, data); err != nil {
+			return err
+		}
+
+		if err := record(ctx, tx, src, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutStock puts the moves into their warehouses as part of tx, along with
+// the quantity of their products or variants, and records them in the
+// ledger for src.
+func PutStock(ctx context.Context, tx *sqlx.Tx, src Source, moves []StockMove, now time.Time) error {
+	q := `
+	INSERT INTO stock_levels
+		(warehouse_id, product_id, variant_id, quantity, date_updated)
+	VALUES
+		(:warehouse_id, :product_id, :variant_id, :quantity, :date_updated)
+	ON CONFLICT (warehouse_id, product_id, COALESCE(variant_id, '` + noVariant + `')) DO UPDATE
+	SET quantity = stock_levels.quantity + EXCLUDED.quantity, date_updated = EXCLUDED.date_updated`
+
+	for _, m := range sortMoves(moves) {
+		data := newLevelData(m, now)
+
+		if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+			return fmt.Errorf("putting stock of %s %w", m.ProductID, err)
+		}
+
+		if err := addTotal(ctx, tx, data); err != nil {
+			return err
+		}
+
+		if err := record(ctx, tx, src, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTotal adds the quantity to the variant of the level, or to its
+// product when it has none. Stock coming in may lift the product back
+// above its reorder point.
+func addTotal(ctx context.Context, tx *sqlx.Tx, data levelData) error {
+	q := `
+	UPDATE products
+	SET quantity = quantity + :quantity, date_updated = :date_updated
+	WHERE product_id = :product_id`
+
+	if data.VariantID != nil {
+		q = `
+		UPDATE product_variants
+		SET quantity = quantity + :quantity, date_updated = :date_updated
+		WHERE variant_id = :variant_id`
+	}
+
+	if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("updating stock of %s %w", data.ProductID, err)
+	}
+
+	if data.Quantity > 0 {
+		return clearLowStock(ctx, tx, data.ProductID)
+	}
+	return nil
+}
+
+// sortMoves merges the moves of the same level and orders them by
+// warehouse, product and variant.
+func sortMoves(moves []StockMove) []StockMove {
+	type key struct {
+		warehouseID string
+		productID   string
+		variantID   string
+	}
+
+	merged := make(map[key]StockMove)
+	for _, m := range moves {
+		k := key{warehouseID: m.WarehouseID, productID: m.ProductID}
+		if m.VariantID != nil {
+			k.variantID = *m.VariantID
+		}
+
+		if cur, ok := merged[k]; ok {
+			m.Quantity += cur.Quantity
+		}
+		merged[k] = m
+	}
+
+	out := make([]StockMove, 0, len(merged))
+	for _, m := range merged {
+		out = append(out, m)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := newLevelData(out[i], time.Time{}), newLevelData(out[j], time.Time{})
+		switch {
+		case a.WarehouseID != b.WarehouseID:
+			return a.WarehouseID < b.WarehouseID
+		case a.ProductID != b.ProductID:
+			return a.ProductID < b.ProductID
+		}
+		return a.VariantKey < b.VariantKey
+	})
+	return out
+}
+
+// =============================================================================
+
+// QueryLevels returns the levels of the product and its variants in every
+// warehouse, ordered by warehouse and variant.
+func (s Store) QueryLevels(ctx context.Context, productID string) ([]Level, error) {
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		stock_levels
+	WHERE
+		product_id = :product_id
+	ORDER BY
+		warehouse_id, COALESCE(variant_id, '` + noVariant + `')`
+
+	var levels []Level
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &levels); err != nil {
+		return nil, fmt.Errorf("selecting levels of %s %w", productID, err)
+	}
+	return levels, nil
+}
+
+// SetLevel sets the quantity a warehouse holds of a product or variant,
+// as found when counting it, and moves the quantity of the product or
+// variant by the difference. The difference is recorded for src.
+func (s Store) SetLevel(ctx context.Context, src Source, l Level) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	data := newLevelData(StockMove{WarehouseID: l.WarehouseID, ProductID: l.ProductID, VariantID: l.VariantID}, l.DateUpdated)
+
+	cur, err := lockLevel(tx, data)
+	if err != nil {
+		return err
+	}
+
+	move := StockMove{WarehouseID: l.WarehouseID, ProductID: l.ProductID, VariantID: l.VariantID}
+	switch {
+	case l.Quantity > cur:
+		move.Quantity = l.Quantity - cur
+		err = PutStock(ctx, tx, src, []StockMove{move}, l.DateUpdated)
+	case l.Quantity < cur:
+		move.Quantity = cur - l.Quantity
+		err = TakeStock(ctx, tx, src, []StockMove{move}, l.DateUpdated)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
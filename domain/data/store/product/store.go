@@ -0,0 +1,504 @@
+// Package product persists the products we sell and their stock.
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+	"strings"
+)
+
+// Set of error variables for products and their variants.
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrUniqueSKU         = errors.New("sku is not unique")
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create stores the product along with its tags, its quantity is put in
+// the default warehouse and recorded in the ledger as a receipt.
+func (s Store) Create(ctx context.Context, p Product) error {
+	data := struct {
+		Product
+		TagList pq.StringArray `db:"tags"`
+	}{
+		Product: p,
+		TagList: append(pq.StringArray{}, p.Tags...),
+	}
+
+	q := `
+	WITH p AS (
+		INSERT INTO products
+			(product_id, name, cost, currency, quantity, reorder_point, category_id, user_id, date_created, date_updated)
+		VALUES
+			(:product_id, :name, :cost, :currency, :quantity, :reorder_point, :category_id, :user_id, :date_created, :date_updated)
+		RETURNING product_id, quantity
+	), l AS (
+		INSERT INTO stock_levels
+			(warehouse_id, product_id, quantity, date_updated)
+		SELECT
+			w.warehouse_id, p.product_id, p.quantity, :date_updated
+		FROM
+			p, warehouses AS w
+		WHERE
+			w.is_default AND p.quantity > 0
+		RETURNING warehouse_id, product_id, quantity
+	), m AS (
+		INSERT INTO stock_movements
+			(warehouse_id, product_id, quantity, kind, reason, note, created_by, date_created)
+		SELECT
+			l.warehouse_id, l.product_id, l.quantity, '` + KindReceipt + `', '', '', :user_id, :date_updated
+		FROM
+			l
+	)
+	INSERT INTO product_tags
+		(product_id, tag)
+	SELECT
+		p.product_id, t.tag
+	FROM
+		p, unnest(CAST(:tags AS TEXT[])) AS t(tag)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("inserting product %w", err)
+	}
+	return nil
+}
+
+// UpdateCategory files the product under its category, or under none.
+func (s Store) UpdateCategory(ctx context.Context, p Product) error {
+	q := `
+	UPDATE products
+	SET category_id = :category_id, date_updated = :date_updated
+	WHERE product_id = :product_id`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, p); err != nil {
+		return fmt.Errorf("updating category of product %s %w", p.ID, err)
+	}
+	return nil
+}
+
+// ReplaceTags replaces the tags of the product with its current ones.
+func (s Store) ReplaceTags(ctx context.Context, p Product) error {
+	data := struct {
+		Product
+		TagList pq.StringArray `db:"tags"`
+	}{
+		Product: p,
+		TagList: append(pq.StringArray{}, p.Tags...),
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.NamedExecContext(ctx, `DELETE FROM product_tags WHERE product_id = :product_id`, data); err != nil {
+		return fmt.Errorf("deleting tags of product %s %w", p.ID, err)
+	}
+
+	q := `
+	INSERT INTO product_tags
+		(product_id, tag)
+	SELECT
+		:product_id, t.tag
+	FROM
+		unnest(CAST(:tags AS TEXT[])) AS t(tag)`
+
+	if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("inserting tags of product %s %w", p.ID, err)
+	}
+
+	q = `UPDATE products SET date_updated = :date_updated WHERE product_id = :product_id`
+
+	if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("updating product %s %w", p.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// QueryByID returns the product with the id.
+func (s Store) QueryByID(ctx context.Context, productID string) (Product, error) {
+	data := struct {
+		ProductID string `db:"product_id"`
+	}{
+		ProductID: productID,
+	}
+
+	q := `SELECT * FROM products WHERE product_id = :product_id`
+
+	var p Product
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &p); err != nil {
+		if err == database.ErrNotFound {
+			return Product{}, database.ErrNotFound
+		}
+		return Product{}, fmt.Errorf("selecting product %s %w", productID, err)
+	}
+
+	prds := []Product{p}
+	if err := s.tags(ctx, prds); err != nil {
+		return Product{}, err
+	}
+	return prds[0], nil
+}
+
+// QueryByIDs returns the products with the ids, unknown ids are skipped.
+func (s Store) QueryByIDs(ctx context.Context, productIDs []string) ([]Product, error) {
+	data := struct {
+		ProductIDs pq.StringArray `db:"product_ids"`
+	}{
+		ProductIDs: productIDs,
+	}
+
+	q := `SELECT * FROM products WHERE product_id = ANY(CAST(:product_ids AS UUID[])) ORDER BY product_id`
+
+	var prds []Product
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &prds); err != nil {
+		return nil, fmt.Errorf("selecting products %v %w", productIDs, err)
+	}
+
+	if err := s.tags(ctx, prds); err != nil {
+		return nil, err
+	}
+	return prds, nil
+}
+
+// CreateVariant stores a variant of a product, its quantity is put in the
+// default warehouse and recorded in the ledger as a receipt.
+func (s Store) CreateVariant(ctx context.Context, v Variant) error {
+	q := `
+	WITH v AS (
+		INSERT INTO product_variants
+			(variant_id, product_id, sku, attributes, price, quantity, date_created, date_updated)
+		VALUES
+			(:variant_id, :product_id, :sku, :attributes, :price, :quantity, :date_created, :date_updated)
+		RETURNING variant_id, product_id, quantity
+	), l AS (
+		INSERT INTO stock_levels
+			(warehouse_id, product_id, variant_id, quantity, date_updated)
+		SELECT
+			w.warehouse_id, v.product_id, v.variant_id, v.quantity, :date_updated
+		FROM
+			v, warehouses AS w
+		WHERE
+			w.is_default AND v.quantity > 0
+		RETURNING warehouse_id, product_id, variant_id, quantity
+	)
+	INSERT INTO stock_movements
+		(warehouse_id, product_id, variant_id, quantity, kind, reason, note, date_created)
+	SELECT
+		l.warehouse_id, l.product_id, l.variant_id, l.quantity, '` + KindReceipt + `', '', '', :date_updated
+	FROM
+		l`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, v); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueSKU
+		}
+		return fmt.Errorf("inserting variant %w", err)
+	}
+	return nil
+}
+
+// UpdateVariant replaces a variant in the database, except for its
+// quantity which only moves with the stock of the warehouses.
+func (s Store) UpdateVariant(ctx context.Context, v Variant) error {
+	q := `
+	UPDATE
+		product_variants
+	SET
+		sku = :sku,
+		attributes = :attributes,
+		price = :price,
+		date_updated = :date_updated
+	WHERE
+		variant_id = :variant_id`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, v); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueSKU
+		}
+		return fmt.Errorf("updating variant %s %w", v.ID, err)
+	}
+	return nil
+}
+
+// QueryVariantByID returns the variant with the id.
+func (s Store) QueryVariantByID(ctx context.Context, variantID string) (Variant, error) {
+	data := struct {
+		VariantID string `db:"variant_id"`
+	}{
+		VariantID: variantID,
+	}
+
+	q := `SELECT * FROM product_variants WHERE variant_id = :variant_id`
+
+	var v Variant
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &v); err != nil {
+		if err == database.ErrNotFound {
+			return Variant{}, database.ErrNotFound
+		}
+		return Variant{}, fmt.Errorf("selecting variant %s %w", variantID, err)
+	}
+	return v, nil
+}
+
+// QueryVariantsByProducts returns the variants of the products, ordered by
+// product and sku.
+func (s Store) QueryVariantsByProducts(ctx context.Context, productIDs []string) ([]Variant, error) {
+	data := struct {
+		ProductIDs pq.StringArray `db:"product_ids"`
+	}{
+		ProductIDs: productIDs,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		product_variants
+	WHERE
+		product_id = ANY(CAST(:product_ids AS UUID[]))
+	ORDER BY
+		product_id, sku`
+
+	var vars []Variant
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &vars); err != nil {
+		return nil, fmt.Errorf("selecting variants of %v %w", productIDs, err)
+	}
+	return vars, nil
+}
+
+// filterData binds a Filter to the where clause of filterProducts, along
+// with the page asked for when there is one.
+type filterData struct {
+	ByCategory  bool           `db:"by_category"`
+	CategoryIDs pq.StringArray `db:"category_ids"`
+	Tags        pq.StringArray `db:"tags"`
+	Offset      int            `db:"offset"`
+	RowsPerPage int            `db:"rows_per_page"`
+}
+
+func newFilterData(f Filter) filterData {
+	return filterData{
+		ByCategory:  f.CategoryIDs != nil,
+		CategoryIDs: append(pq.StringArray{}, f.CategoryIDs...),
+		Tags:        append(pq.StringArray{}, f.Tags...),
+	}
+}
+
+// filterProducts keeps the products of p matching the filter, a product
+// must carry every tag asked for.
+const filterProducts = `
+	WHERE
+		(NOT :by_category OR p.category_id = ANY(CAST(:category_ids AS UUID[])))
+	AND
+		(cardinality(CAST(:tags AS TEXT[])) = 0 OR p.product_id IN (
+			SELECT
+				product_id
+			FROM
+				product_tags
+			WHERE
+				tag = ANY(CAST(:tags AS TEXT[]))
+			GROUP BY
+				product_id
+			HAVING
+				COUNT(*) = cardinality(CAST(:tags AS TEXT[]))
+		))`
+
+// Query returns a page of the products matching the filter, ordered by
+// name.
+func (s Store) Query(ctx context.Context, f Filter, pageNumber int, rowsPerPage int) ([]Product, error) {
+	data := newFilterData(f)
+	data.Offset = (pageNumber - 1) * rowsPerPage
+	data.RowsPerPage = rowsPerPage
+
+	q := `
+	SELECT
+		p.*
+	FROM
+		products AS p` + filterProducts + `
+	ORDER BY
+		p.name, p.product_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var prds []Product
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &prds); err != nil {
+		return nil, fmt.Errorf("selecting products %w", err)
+	}
+
+	if err := s.tags(ctx, prds); err != nil {
+		return nil, err
+	}
+	return prds, nil
+}
+
+// CountByCategory returns how many products matching the filter are filed
+// directly under every category, keyed by category id. Categories without
+// any are left out.
+func (s Store) CountByCategory(ctx context.Context, f Filter) (map[string]int, error) {
+	q := `
+	SELECT
+		p.category_id, COUNT(*) AS count
+	FROM
+		products AS p` + filterProducts + `
+	AND
+		p.category_id IS NOT NULL
+	GROUP BY
+		p.category_id`
+
+	var rows []struct {
+		CategoryID string `db:"category_id"`
+		Count      int    `db:"count"`
+	}
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, newFilterData(f), &rows); err != nil {
+		return nil, fmt.Errorf("counting products by category %w", err)
+	}
+
+	counts := make(map[string]int, len(rows))
+	for _, r := range rows {
+		counts[r.CategoryID] = r.Count
+	}
+	return counts, nil
+}
+
+// QueryTags returns every tag in use along with how many products carry
+// it, ordered by tag.
+func (s Store) QueryTags(ctx context.Context) ([]TagCount, error) {
+	q := `
+	SELECT
+		tag, COUNT(*) AS count
+	FROM
+		product_tags
+	GROUP BY
+		tag
+	ORDER BY
+		tag`
+
+	var tags []TagCount
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &tags); err != nil {
+		return nil, fmt.Errorf("selecting tags %w", err)
+	}
+	return tags, nil
+}
+
+// Search returns up to limit products whose name has a word starting with
+// every term of the query, best ranked first. The rank is divided by the
+// length of the name so "Knight" comes before "Knight Rider Figure". The
+// match is served by the GIN index on the search vector.
+func (s Store) Search(ctx context.Context, query string, limit int) ([]Match, error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return []Match{}, nil
+	}
+
+	// Terms only hold letters and digits, so they can not carry tsquery
+	// operators of their own.
+	prefixes := make([]string, len(terms))
+	for i, t := range terms {
+		prefixes[i] = t + ":*"
+	}
+
+	data := struct {
+		Query string `db:"query"`
+		Limit int    `db:"limit"`
+	}{
+		Query: strings.Join(prefixes, " & "),
+		Limit: limit,
+	}
+
+	q := `
+	SELECT
+		p.*, ts_rank(p.search, q.query, 1) AS rank
+	FROM
+		products AS p, to_tsquery('simple', :query) AS q(query)
+	WHERE
+		p.search @@ q.query
+	ORDER BY
+		rank DESC, p.name, p.product_id
+	LIMIT :limit`
+
+	var matches []Match
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &matches); err != nil {
+		return nil, fmt.Errorf("searching products %q %w", query, err)
+	}
+
+	prds := make([]Product, len(matches))
+	for i := range matches {
+		prds[i] = matches[i].Product
+	}
+	if err := s.tags(ctx, prds); err != nil {
+		return nil, err
+	}
+
+	for i := range matches {
+		matches[i].Product = prds[i]
+		matches[i].Snippet = Highlight(matches[i].Name, terms)
+	}
+	return matches, nil
+}
+
+// tags loads the tags of every product in a single query.
+func (s Store) tags(ctx context.Context, prds []Product) error {
+	if len(prds) == 0 {
+		return nil
+	}
+
+	ids := make(pq.StringArray, len(prds))
+	for i, p := range prds {
+		ids[i] = p.ID
+	}
+
+	data := struct {
+		ProductIDs pq.StringArray `db:"product_ids"`
+	}{
+		ProductIDs: ids,
+	}
+
+	q := `
+	SELECT
+		product_id, tag
+	FROM
+		product_tags
+	WHERE
+		product_id = ANY(CAST(:product_ids AS UUID[]))
+	ORDER BY
+		product_id, tag`
+
+	var rows []struct {
+		ProductID string `db:"product_id"`
+		Tag       string `db:"tag"`
+	}
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &rows); err != nil {
+		return fmt.Errorf("selecting product tags %w", err)
+	}
+
+	byProduct := make(map[string][]string, len(prds))
+	for _, r := range rows {
+		byProduct[r.ProductID] = append(byProduct[r.ProductID], r.Tag)
+	}
+
+	for i := range prds {
+		prds[i].Tags = append([]string{}, byProduct[prds[i].ID]...)
+	}
+	return nil
+}
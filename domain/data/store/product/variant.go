@@ -0,0 +1,73 @@
+package product
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Variant is a way a product comes, like a size and a colour, with a stock
+// of its own. Price overrides the cost of the product when set and is in
+// the same currency. A product with variants is only sold by variant.
+type Variant struct {
+	ID          string     `db:"variant_id" json:"id"`
+	ProductID   string     `db:"product_id" json:"product_id"`
+	SKU         string     `db:"sku" json:"sku"`
+	Attributes  Attributes `db:"attributes" json:"attributes"`
+	Price       *int       `db:"price" json:"price"`
+	Quantity    int        `db:"quantity" json:"quantity"`
+	DateCreated time.Time  `db:"date_created" json:"date_created"`
+	DateUpdated time.Time  `db:"date_updated" json:"date_updated"`
+}
+
+// NewVariant is what we require to add a variant to a product. The
+// quantity goes into the default warehouse.
+type NewVariant struct {
+	SKU        string     `json:"sku" validate:"required,max=64"`
+	Attributes Attributes `json:"attributes" validate:"max=10,dive,keys,required,max=50,endkeys,required,max=100"`
+	Price      *int       `json:"price" validate:"omitempty,gte=0"`
+	Quantity   int        `json:"quantity" validate:"gte=0"`
+}
+
+// UpdateVariant changes the fields that are set. Attributes replace the
+// ones of the variant as a whole. A price override can be changed but not
+// removed. The quantity is changed through the stock of the warehouses.
+type UpdateVariant struct {
+	SKU        *string    `json:"sku" validate:"omitempty,max=64"`
+	Attributes Attributes `json:"attributes" validate:"omitempty,max=10,dive,keys,required,max=50,endkeys,required,max=100"`
+	Price      *int       `json:"price" validate:"omitempty,gte=0"`
+}
+
+// Attributes describe a variant, like {"size": "M", "colour": "red"}.
+type Attributes map[string]string
+
+// Value implements the driver.Valuer interface, attributes are stored as
+// a JSON object.
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan implements the sql.Scanner interface.
+func (a *Attributes) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("can not scan %T into attributes", src)
+	}
+
+	attrs := Attributes{}
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return fmt.Errorf("unmarshal attributes %w", err)
+	}
+
+	*a = attrs
+	return nil
+}
@@ -0,0 +1,163 @@
+// Package memory provides a thread safe in memory implementation of the
+// promotion store with the same semantics as the postgres store.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/promotion"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu         sync.Mutex
+	coupons    map[string]promotion.Coupon
+	promotions map[string]promotion.Promotion
+}
+
+func NewStore() *Store {
+	return &Store{
+		coupons:    make(map[string]promotion.Coupon),
+		promotions: make(map[string]promotion.Promotion),
+	}
+}
+
+func (s *Store) CreateCoupon(ctx context.Context, c promotion.Coupon) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.coupons[c.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+	for _, other := range s.coupons {
+		if other.Code == c.Code {
+			return promotion.ErrUniqueCode
+		}
+	}
+
+	s.coupons[c.ID] = c
+	return nil
+}
+
+func (s *Store) QueryCoupons(ctx context.Context, pageNumber int, rowsPerPage int) ([]promotion.Coupon, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	coupons := make([]promotion.Coupon, 0, len(s.coupons))
+	for _, c := range s.coupons {
+		coupons = append(coupons, c)
+	}
+
+	sort.Slice(coupons, func(i, j int) bool {
+		if !coupons[i].DateCreated.Equal(coupons[j].DateCreated) {
+			return coupons[i].DateCreated.After(coupons[j].DateCreated)
+		}
+		return coupons[i].ID < coupons[j].ID
+	})
+
+	start := (pageNumber - 1) * rowsPerPage
+	if start >= len(coupons) {
+		return []promotion.Coupon{}, nil
+	}
+
+	end := start + rowsPerPage
+	if end > len(coupons) {
+		end = len(coupons)
+	}
+	return coupons[start:end], nil
+}
+
+func (s *Store) QueryCouponByCode(ctx context.Context, code string) (promotion.Coupon, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range s.coupons {
+		if c.Code == code {
+			return c, nil
+		}
+	}
+	return promotion.Coupon{}, database.ErrNotFound
+}
+
+func (s *Store) CreatePromotion(ctx context.Context, p promotion.Promotion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.promotions[p.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+
+	s.promotions[p.ID] = p
+	return nil
+}
+
+func (s *Store) QueryPromotions(ctx context.Context, pageNumber int, rowsPerPage int) ([]promotion.Promotion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	promos := make([]promotion.Promotion, 0, len(s.promotions))
+	for _, p := range s.promotions {
+		promos = append(promos, p)
+	}
+
+	sort.Slice(promos, func(i, j int) bool {
+		if !promos[i].StartsAt.Equal(promos[j].StartsAt) {
+			return promos[i].StartsAt.After(promos[j].StartsAt)
+		}
+		return promos[i].ID < promos[j].ID
+	})
+
+	start := (pageNumber - 1) * rowsPerPage
+	if start >= len(promos) {
+		return []promotion.Promotion{}, nil
+	}
+
+	end := start + rowsPerPage
+	if end > len(promos) {
+		end = len(promos)
+	}
+	return promos[start:end], nil
+}
+
+func (s *Store) QueryActive(ctx context.Context, now time.Time) ([]promotion.Promotion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var promos []promotion.Promotion
+	for _, p := range s.promotions {
+		if p.Active(now) {
+			promos = append(promos, p)
+		}
+	}
+
+	sort.Slice(promos, func(i, j int) bool {
+		return promos[i].ID < promos[j].ID
+	})
+	return promos, nil
+}
+
+// Redeem counts one more use of the coupon when fn succeeds. The coupon is
+// held while fn runs, the way the postgres store redeems it within the
+// transaction of the order. It fails with promotion.ErrCouponUnavailable
+// without calling fn when the coupon has expired at now or reached its
+// usage limit.
+func (s *Store) Redeem(ctx context.Context, couponID string, now time.Time, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.coupons[couponID]
+	if !ok || !c.Redeemable(now) {
+		return promotion.ErrCouponUnavailable
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	c.Used++
+	c.DateUpdated = now
+	s.coupons[couponID] = c
+	return nil
+}
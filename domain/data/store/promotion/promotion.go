@@ -0,0 +1,101 @@
+package promotion
+
+import (
+	"service/foundation/money"
+	"strings"
+	"time"
+)
+
+// Set of kinds of discount. Coupons take a percentage or a fixed amount
+// off, promotions can also give units away.
+const (
+	KindPercent  = "percent"
+	KindFixed    = "fixed"
+	KindBuyXGetY = "buy_x_get_y"
+)
+
+// Coupon is a code customers give when placing an order for a discount on
+// the whole of it. A percent coupon takes Value percent off, a fixed one
+// Value minor units of its currency. Used counts the orders that redeemed
+// the coupon, it never passes UsageLimit when there is one. A coupon can
+// not be redeemed from ExpiresAt on.
+type Coupon struct {
+	ID          string          `db:"coupon_id" json:"id"`
+	Code        string          `db:"code" json:"code"`
+	Kind        string          `db:"kind" json:"kind"`
+	Value       int             `db:"value" json:"value"`
+	Currency    *money.Currency `db:"currency" json:"currency"`
+	UsageLimit  *int            `db:"usage_limit" json:"usage_limit"`
+	Used        int             `db:"used" json:"used"`
+	ExpiresAt   *time.Time      `db:"expires_at" json:"expires_at"`
+	DateCreated time.Time       `db:"date_created" json:"date_created"`
+	DateUpdated time.Time       `db:"date_updated" json:"date_updated"`
+}
+
+// Redeemable reports whether the coupon can still be used at now.
+func (c Coupon) Redeemable(now time.Time) bool {
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return false
+	}
+	return c.UsageLimit == nil || c.Used < *c.UsageLimit
+}
+
+// Promotion is a discount given without a code on a product, or on every
+// product filed under a category or below it, from StartsAt up to, not
+// including, EndsAt. Percent and fixed promotions take Value percent or
+// Value minor units of their currency off every unit. A buy_x_get_y
+// promotion gives GetQuantity units away for every BuyQuantity bought.
+type Promotion struct {
+	ID          string          `db:"promotion_id" json:"id"`
+	Name        string          `db:"name" json:"name"`
+	Kind        string          `db:"kind" json:"kind"`
+	Value       int             `db:"value" json:"value"`
+	Currency    *money.Currency `db:"currency" json:"currency"`
+	BuyQuantity int             `db:"buy_quantity" json:"buy_quantity"`
+	GetQuantity int             `db:"get_quantity" json:"get_quantity"`
+	ProductID   *string         `db:"product_id" json:"product_id"`
+	CategoryID  *string         `db:"category_id" json:"category_id"`
+	StartsAt    time.Time       `db:"starts_at" json:"starts_at"`
+	EndsAt      time.Time       `db:"ends_at" json:"ends_at"`
+	DateCreated time.Time       `db:"date_created" json:"date_created"`
+	DateUpdated time.Time       `db:"date_updated" json:"date_updated"`
+}
+
+// Active reports whether the promotion runs at now.
+func (p Promotion) Active(now time.Time) bool {
+	return !now.Before(p.StartsAt) && now.Before(p.EndsAt)
+}
+
+// NewCoupon is what we require from staff when adding a coupon. Fixed
+// coupons need a currency, percent coupons take none. Codes are matched
+// whatever their case.
+type NewCoupon struct {
+	Code       string          `json:"code" validate:"required,alphanum,max=30"`
+	Kind       string          `json:"kind" validate:"required,oneof=percent fixed"`
+	Value      int             `json:"value" validate:"required,gte=1"`
+	Currency   *money.Currency `json:"currency" validate:"omitempty,currency"`
+	UsageLimit *int            `json:"usage_limit" validate:"omitempty,gte=1"`
+	ExpiresAt  *time.Time      `json:"expires_at"`
+}
+
+// NewPromotion is what we require from staff when adding a promotion. It
+// names either a product or a category. Fixed promotions need a currency,
+// buy_x_get_y promotions the quantities bought and given away.
+type NewPromotion struct {
+	Name        string          `json:"name" validate:"required,max=100"`
+	Kind        string          `json:"kind" validate:"required,oneof=percent fixed buy_x_get_y"`
+	Value       int             `json:"value" validate:"gte=0"`
+	Currency    *money.Currency `json:"currency" validate:"omitempty,currency"`
+	BuyQuantity int             `json:"buy_quantity" validate:"gte=0"`
+	GetQuantity int             `json:"get_quantity" validate:"gte=0"`
+	ProductID   *string         `json:"product_id" validate:"omitempty,uuid"`
+	CategoryID  *string         `json:"category_id" validate:"omitempty,uuid"`
+	StartsAt    time.Time       `json:"starts_at" validate:"required"`
+	EndsAt      time.Time       `json:"ends_at" validate:"required"`
+}
+
+// NormalizeCode returns the code the way coupons are stored, upper case
+// without surrounding spaces.
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,114 @@
+package promotion_test
+
+import (
+	"context"
+	"errors"
+	"service/domain/core/promotion"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	promotionStore "service/domain/data/store/promotion"
+	"service/domain/data/store/promotion/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+// adminID is the seeded admin, who owns the products on promotion.
+const adminID = "5cf37266-3473-4006-984f-9325122678b7"
+
+type productCreator interface {
+	Create(ctx context.Context, p product.Product) error
+}
+
+func TestMemory(t *testing.T) {
+	promotions(t, memory.NewStore(), productMemory.NewStore())
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	promotions(t, promotionStore.NewStore(logger, db), product.NewStore(logger, db))
+}
+
+func promotions(t *testing.T, store promotion.Storer, products productCreator) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need to run coupons and promotions")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When adding coupons", testID)
+		{
+			limit := 2
+			usd := money.USD
+			code := "SAVE" + validate.GenerateUID()[:8]
+			c := promotionStore.Coupon{ID: validate.GenerateUID(), Code: promotionStore.NormalizeCode(code), Kind: promotionStore.KindFixed, Value: 10, Currency: &usd, UsageLimit: &limit, DateCreated: now, DateUpdated: now}
+			if err := store.CreateCoupon(ctx, c); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to add a coupon: %v", tests.Failed, testID, err)
+			}
+
+			got, err := store.QueryCouponByCode(ctx, c.Code)
+			if err != nil || got.ID != c.ID || got.Currency == nil || *got.Currency != money.USD || got.UsageLimit == nil || *got.UsageLimit != 2 || got.ExpiresAt != nil {
+				t.Fatalf("\t%s\t Test %d Should find the coupon by its code, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should find the coupon by its code", tests.Succeeded, testID)
+
+			dup := c
+			dup.ID = validate.GenerateUID()
+			if err := store.CreateCoupon(ctx, dup); !errors.Is(err, promotionStore.ErrUniqueCode) {
+				t.Fatalf("\t%s\t Test %d Should refuse a second coupon with the code, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse a second coupon with the code", tests.Succeeded, testID)
+
+			if _, err := store.QueryCouponByCode(ctx, "NOSUCHCODE"); !errors.Is(err, database.ErrNotFound) {
+				t.Fatalf("\t%s\t Test %d Should not find an unknown code, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should not find an unknown code", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When running promotions", testID)
+		{
+			books := product.Product{ID: validate.GenerateUID(), Name: "Books", Cost: 50, Currency: money.USD, Quantity: 5, UserID: adminID, DateCreated: now, DateUpdated: now}
+			if err := products.Create(ctx, books); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to create a product: %v", tests.Failed, testID, err)
+			}
+
+			newPromotion := func(name string, starts time.Time, ends time.Time) promotionStore.Promotion {
+				t.Helper()
+
+				p := promotionStore.Promotion{ID: validate.GenerateUID(), Name: name, Kind: promotionStore.KindBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductID: &books.ID, StartsAt: starts, EndsAt: ends, DateCreated: now, DateUpdated: now}
+				if err := store.CreatePromotion(ctx, p); err != nil {
+					t.Fatalf("\t%s\t Test %d Should be able to add a promotion: %v", tests.Failed, testID, err)
+				}
+				return p
+			}
+
+			past := newPromotion("Past", now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+			running := newPromotion("Running", now.Add(-time.Hour), now.Add(time.Hour))
+			newPromotion("Upcoming", now.Add(time.Hour), now.Add(2*time.Hour))
+
+			active, err := store.QueryActive(ctx, now)
+			if err != nil || len(active) != 1 || active[0].ID != running.ID || active[0].ProductID == nil || *active[0].ProductID != books.ID {
+				t.Fatalf("\t%s\t Test %d Should only return the promotions running now, got %+v %v", tests.Failed, testID, active, err)
+			}
+			t.Logf("\t%s\t Test %d Should only return the promotions running now", tests.Succeeded, testID)
+
+			active, err = store.QueryActive(ctx, past.EndsAt)
+			if err != nil || len(active) != 0 {
+				t.Fatalf("\t%s\t Test %d Should end promotions at their end, got %+v %v", tests.Failed, testID, active, err)
+			}
+			t.Logf("\t%s\t Test %d Should end promotions at their end", tests.Succeeded, testID)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package promotion persists coupons and the promotions running on
+// products and categories.
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+	"time"
+)
+
+// Set of error variables for coupons.
+var (
+	ErrUniqueCode        = errors.New("coupon code is not unique")
+	ErrCouponUnavailable = errors.New("coupon has expired or reached its usage limit")
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// CreateCoupon stores the coupon.
+func (s Store) CreateCoupon(ctx context.Context, c Coupon) error {
+	q := `INSERT INTO coupons
+	(coupon_id, code, kind, value, currency, usage_limit, used, expires_at, date_created, date_updated)
+	VALUES
+	(:coupon_id, :code, :kind, :value, :currency, :usage_limit, :used, :expires_at, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, c); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueCode
+		}
+		return fmt.Errorf("inserting coupon %w", err)
+	}
+	return nil
+}
+
+// QueryCoupons returns a page of the coupons, latest first.
+func (s Store) QueryCoupons(ctx context.Context, pageNumber int, rowsPerPage int) ([]Coupon, error) {
+	data := struct {
+		Offset      int `db:"offset"`
+		RowsPerPage int `db:"rows_per_page"`
+	}{
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		coupons
+	ORDER BY
+		date_created DESC, coupon_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var coupons []Coupon
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &coupons); err != nil {
+		return nil, fmt.Errorf("selecting coupons %w", err)
+	}
+	return coupons, nil
+}
+
+// QueryCouponByCode returns the coupon with the code, which must be
+// normalized already.
+func (s Store) QueryCouponByCode(ctx context.Context, code string) (Coupon, error) {
+	data := struct {
+		Code string `db:"code"`
+	}{
+		Code: code,
+	}
+
+	q := `SELECT * FROM coupons WHERE code = :code`
+
+	var c Coupon
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &c); err != nil {
+		if err == database.ErrNotFound {
+			return Coupon{}, database.ErrNotFound
+		}
+		return Coupon{}, fmt.Errorf("selecting coupon %s %w", code, err)
+	}
+	return c, nil
+}
+
+// CreatePromotion stores the promotion.
+func (s Store) CreatePromotion(ctx context.Context, p Promotion) error {
+	q := `INSERT INTO promotions
+	(promotion_id, name, kind, value, currency, buy_quantity, get_quantity, product_id, category_id, starts_at, ends_at, date_created, date_updated)
+	VALUES
+	(:promotion_id, :name, :kind, :value, :currency, :buy_quantity, :get_quantity, :product_id, :category_id, :starts_at, :ends_at, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, p); err != nil {
+		return fmt.Errorf("inserting promotion %w", err)
+	}
+	return nil
+}
+
+// QueryPromotions returns a page of the promotions, the latest to start
+// first.
+func (s Store) QueryPromotions(ctx context.Context, pageNumber int, rowsPerPage int) ([]Promotion, error) {
+	data := struct {
+		Offset      int `db:"offset"`
+		RowsPerPage int `db:"rows_per_page"`
+	}{
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		promotions
+	ORDER BY
+		starts_at DESC, promotion_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var promos []Promotion
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &promos); err != nil {
+		return nil, fmt.Errorf("selecting promotions %w", err)
+	}
+	return promos, nil
+}
+
+// QueryActive returns the promotions running at now ordered by id.
+func (s Store) QueryActive(ctx context.Context, now time.Time) ([]Promotion, error) {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		promotions
+	WHERE
+		starts_at <= :now AND ends_at > :now
+	ORDER BY
+		promotion_id`
+
+	var promos []Promotion
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &promos); err != nil {
+		return nil, fmt.Errorf("selecting active promotions %w", err)
+	}
+	return promos, nil
+}
+
+// RedeemCoupon counts one more use of the coupon within the transaction of
+// the order redeeming it. It fails with ErrCouponUnavailable when the
+// coupon has expired at now or reached its usage limit, whatever was read
+// before.
+func RedeemCoupon(ctx context.Context, tx *sqlx.Tx, couponID string, now time.Time) error {
+	data := struct {
+		CouponID string    `db:"coupon_id"`
+		Now      time.Time `db:"now"`
+	}{
+		CouponID: couponID,
+		Now:      now,
+	}
+
+	q := `
+	UPDATE
+		coupons
+	SET
+		used = used + 1,
+		date_updated = :now
+	WHERE
+		coupon_id = :coupon_id AND
+		(usage_limit IS NULL OR used < usage_limit) AND
+		(expires_at IS NULL OR expires_at > :now)`
+
+	res, err := tx.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return fmt.Errorf("redeeming coupon %s %w", couponID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("redeeming coupon %s %w", couponID, err)
+	}
+	if n == 0 {
+		return ErrCouponUnavailable
+	}
+	return nil
+}
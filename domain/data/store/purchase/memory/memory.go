@@ -0,0 +1,169 @@
+// Package memory provides a thread safe in memory implementation of the
+// purchase order store with the same semantics as the postgres store.
+// Goods are received into the memory product store it is given.
+package memory
+
+import (
+	"context"
+	"fmt"
+	productMemory "service/domain/data/store/product/memory"
+	"service/domain/data/store/purchase"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu       sync.Mutex
+	products *productMemory.Store
+	orders   map[string]purchase.Order
+}
+
+func NewStore(products *productMemory.Store) *Store {
+	return &Store{
+		products: products,
+		orders:   make(map[string]purchase.Order),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, o purchase.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[o.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+
+	s.orders[o.ID] = clone(o)
+	return nil
+}
+
+func (s *Store) UpdateStatus(ctx context.Context, o purchase.Order, from string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.orders[o.ID]
+	if !ok || cur.Status != from {
+		return fmt.Errorf("purchase order %s: %w", o.ID, purchase.ErrStatusChanged)
+	}
+
+	cur.Status = o.Status
+	cur.DateUpdated = o.DateUpdated
+	s.orders[o.ID] = cur
+	return nil
+}
+
+func (s *Store) Receive(ctx context.Context, r purchase.Receipt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.orders[r.OrderID]
+	if !ok {
+		return database.ErrNotFound
+	}
+	if cur.Status != purchase.StatusOrdered && cur.Status != purchase.StatusPartiallyReceived {
+		return fmt.Errorf("purchase order %s is %s: %w", r.OrderID, cur.Status, purchase.ErrNotReceivable)
+	}
+
+	// Every line is checked before anything moves, like the rolled back
+	// transaction of the postgres store.
+	o := clone(cur)
+	for _, rl := range r.Lines {
+		i := sort.Search(len(o.Lines), func(i int) bool { return o.Lines[i].Line >= rl.Line })
+		if i == len(o.Lines) || o.Lines[i].Line != rl.Line || o.Lines[i].Received+rl.Quantity > o.Lines[i].Quantity {
+			return fmt.Errorf("purchase order %s line %d: %w", r.OrderID, rl.Line, purchase.ErrExceedsOrdered)
+		}
+		o.Lines[i].Received += rl.Quantity
+	}
+
+	src := r.Source()
+	moves := r.Moves()
+	for i, rl := range r.Lines {
+		if err := s.products.AddPurchaseCost(ctx, rl.ProductID, rl.Quantity, rl.UnitCost); err != nil {
+			return err
+		}
+		if err := s.products.Put(ctx, src, moves[i:i+1], r.DateReceived); err != nil {
+			return err
+		}
+	}
+
+	o.Status = purchase.StatusReceived
+	for _, l := range o.Lines {
+		if l.Outstanding() > 0 {
+			o.Status = purchase.StatusPartiallyReceived
+			break
+		}
+	}
+	o.DateUpdated = r.DateReceived
+
+	s.orders[o.ID] = o
+	return nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, orderID string) (purchase.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[orderID]
+	if !ok {
+		return purchase.Order{}, database.ErrNotFound
+	}
+	return clone(o), nil
+}
+
+func (s *Store) Query(ctx context.Context, f purchase.Filter, pageNumber int, rowsPerPage int) ([]purchase.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var orders []purchase.Order
+	for _, o := range s.orders {
+		if (f.Status == "" || o.Status == f.Status) && (f.SupplierID == "" || o.SupplierID == f.SupplierID) {
+			orders = append(orders, clone(o))
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].DateCreated.Equal(orders[j].DateCreated) {
+			return orders[i].DateCreated.After(orders[j].DateCreated)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	start := (pageNumber - 1) * rowsPerPage
+	if start >= len(orders) {
+		return []purchase.Order{}, nil
+	}
+
+	end := start + rowsPerPage
+	if end > len(orders) {
+		end = len(orders)
+	}
+	return orders[start:end], nil
+}
+
+// clone makes sure callers never share the lines with the stored order.
+func clone(o purchase.Order) purchase.Order {
+	o.ExpectedDate = cloneTime(o.ExpectedDate)
+
+	lines := make([]purchase.Line, len(o.Lines))
+	for i, l := range o.Lines {
+		l.OrderID = o.ID
+		if l.VariantID != nil {
+			id := *l.VariantID
+			l.VariantID = &id
+		}
+		l.ExpectedDate = cloneTime(l.ExpectedDate)
+		lines[i] = l
+	}
+	o.Lines = lines
+	return o
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
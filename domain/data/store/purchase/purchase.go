@@ -0,0 +1,137 @@
+package purchase
+
+import (
+	"service/domain/data/store/product"
+	"service/foundation/money"
+	"time"
+)
+
+// Set of states a purchase order goes through. A draft is sent to the
+// supplier as ordered, and is partially received until every line came in.
+// An order can be cancelled until goods arrive, and closed once some did
+// when the rest is not coming.
+const (
+	StatusDraft             = "draft"
+	StatusOrdered           = "ordered"
+	StatusPartiallyReceived = "partially_received"
+	StatusReceived          = "received"
+	StatusClosed            = "closed"
+	StatusCancelled         = "cancelled"
+)
+
+// Order is stock bought from a supplier, priced in the currency of the
+// supplier. The goods are delivered to the warehouse of the order, on the
+// expected date unless a line expects them on another one. CreatedBy is
+// whoever raised it.
+type Order struct {
+	ID           string         `db:"purchase_order_id" json:"id"`
+	SupplierID   string         `db:"supplier_id" json:"supplier_id"`
+	WarehouseID  string         `db:"warehouse_id" json:"warehouse_id"`
+	Status       string         `db:"status" json:"status"`
+	Total        int            `db:"total" json:"total"`
+	Currency     money.Currency `db:"currency" json:"currency"`
+	ExpectedDate *time.Time     `db:"expected_date" json:"expected_date"`
+	Note         string         `db:"note" json:"note"`
+	CreatedBy    string         `db:"created_by" json:"created_by"`
+	Lines        []Line         `db:"-" json:"lines"`
+	DateCreated  time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+// Line is a quantity of a product, or of one of its variants, bought at
+// UnitCost. Total is Quantity times UnitCost and Received the units that
+// came in so far.
+type Line struct {
+	OrderID      string     `db:"purchase_order_id" json:"-"`
+	Line         int        `db:"line" json:"line"`
+	ProductID    string     `db:"product_id" json:"product_id"`
+	VariantID    *string    `db:"variant_id" json:"variant_id"`
+	Quantity     int        `db:"quantity" json:"quantity"`
+	Received     int        `db:"received" json:"received"`
+	UnitCost     int        `db:"unit_cost" json:"unit_cost"`
+	Total        int        `db:"total" json:"total"`
+	ExpectedDate *time.Time `db:"expected_date" json:"expected_date"`
+}
+
+// Outstanding returns the units of the line still to come.
+func (l Line) Outstanding() int {
+	return l.Quantity - l.Received
+}
+
+// Receipt is goods of a purchase order coming into its warehouse.
+// ReceivedBy is whoever took them in.
+type Receipt struct {
+	OrderID      string
+	WarehouseID  string
+	ReceivedBy   string
+	Lines        []Received
+	DateReceived time.Time
+}
+
+// Received is the units of a line of the order that came in.
+type Received struct {
+	Line      int
+	ProductID string
+	VariantID *string
+	Quantity  int
+	UnitCost  int
+}
+
+// Moves returns the stock the receipt puts into the warehouse.
+func (r Receipt) Moves() []product.StockMove {
+	moves := make([]product.StockMove, len(r.Lines))
+	for i, rl := range r.Lines {
+		moves[i] = product.StockMove{
+			WarehouseID: r.WarehouseID,
+			ProductID:   rl.ProductID,
+			VariantID:   rl.VariantID,
+			Quantity:    rl.Quantity,
+		}
+	}
+	return moves
+}
+
+// Source returns how the stock received is recorded in the ledger, against
+// the purchase order.
+func (r Receipt) Source() product.Source {
+	return product.Source{Kind: product.KindReceipt, ReferenceID: r.OrderID, CreatedBy: r.ReceivedBy}
+}
+
+// Filter narrows the purchase orders returned, an empty field does not
+// filter.
+type Filter struct {
+	Status     string
+	SupplierID string
+}
+
+// NewOrder is what we require to raise a purchase order. The goods are
+// delivered to the default warehouse when it is left out.
+type NewOrder struct {
+	SupplierID   string     `json:"supplier_id" validate:"required,uuid"`
+	WarehouseID  *string    `json:"warehouse_id" validate:"omitempty,uuid"`
+	ExpectedDate *time.Time `json:"expected_date"`
+	Note         string     `json:"note" validate:"max=500"`
+	Lines        []NewLine  `json:"lines" validate:"required,min=1,dive"`
+}
+
+// NewLine is a product, or one of its variants, how many of it are bought
+// and at what cost per unit.
+type NewLine struct {
+	ProductID    string     `json:"product_id" validate:"required,uuid"`
+	VariantID    *string    `json:"variant_id" validate:"omitempty,uuid"`
+	Quantity     int        `json:"quantity" validate:"required,gte=1"`
+	UnitCost     int        `json:"unit_cost" validate:"gte=0"`
+	ExpectedDate *time.Time `json:"expected_date"`
+}
+
+// NewReceipt is the goods of a purchase order that came in. Without lines
+// everything still outstanding came in.
+type NewReceipt struct {
+	Lines []NewReceived `json:"lines" validate:"dive"`
+}
+
+// NewReceived is the units of a line that came in.
+type NewReceived struct {
+	Line     int `json:"line" validate:"required,gte=1"`
+	Quantity int `json:"quantity" validate:"required,gte=1"`
+}
@@ -0,0 +1,173 @@
+package purchase_test
+
+import (
+	"context"
+	"errors"
+	"service/domain/core/purchase"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	purchaseStore "service/domain/data/store/purchase"
+	"service/domain/data/store/purchase/memory"
+	supplierStore "service/domain/data/store/supplier"
+	supplierMemory "service/domain/data/store/supplier/memory"
+	warehouseStore "service/domain/data/store/warehouse"
+	warehouseMemory "service/domain/data/store/warehouse/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+// adminID is the seeded admin, who owns the products and raises the
+// purchase orders.
+const adminID = "5cf37266-3473-4006-984f-9325122678b7"
+
+type productStorer interface {
+	Create(ctx context.Context, p product.Product) error
+	QueryByID(ctx context.Context, productID string) (product.Product, error)
+	QueryMovements(ctx context.Context, f product.MovementFilter, pageNumber int, rowsPerPage int) ([]product.Movement, error)
+}
+
+type supplierCreator interface {
+	Create(ctx context.Context, sp supplierStore.Supplier) error
+}
+
+func TestMemory(t *testing.T) {
+	products := productMemory.NewStore()
+	warehouseMemory.NewStore(products)
+	purchases(t, memory.NewStore(products), supplierMemory.NewStore(), products)
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	purchases(t, purchaseStore.NewStore(logger, db), supplierStore.NewStore(logger, db), product.NewStore(logger, db))
+}
+
+func purchases(t *testing.T, store purchase.Storer, suppliers supplierCreator, products productStorer) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	books := product.Product{ID: validate.GenerateUID(), Name: "Books", Cost: 50, Currency: money.USD, Quantity: 10, PurchaseCost: 20, UserID: adminID, DateCreated: now, DateUpdated: now}
+	if err := products.Create(ctx, books); err != nil {
+		t.Fatalf("\t%s\t Should be able to create a product: %v", tests.Failed, err)
+	}
+
+	sp := supplierStore.Supplier{ID: validate.GenerateUID(), Name: "Paper " + books.ID[:8], Currency: money.USD, DateCreated: now, DateUpdated: now}
+	if err := suppliers.Create(ctx, sp); err != nil {
+		t.Fatalf("\t%s\t Should be able to create a supplier: %v", tests.Failed, err)
+	}
+
+	po := purchaseStore.Order{
+		ID:          validate.GenerateUID(),
+		SupplierID:  sp.ID,
+		WarehouseID: warehouseStore.MainID,
+		Status:      purchaseStore.StatusOrdered,
+		Total:       300,
+		Currency:    money.USD,
+		CreatedBy:   adminID,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+	po.Lines = []purchaseStore.Line{
+		{OrderID: po.ID, Line: 1, ProductID: books.ID, Quantity: 10, UnitCost: 30, Total: 300},
+	}
+
+	receipt := func(qty int) purchaseStore.Receipt {
+		return purchaseStore.Receipt{
+			OrderID:      po.ID,
+			WarehouseID:  warehouseStore.MainID,
+			ReceivedBy:   adminID,
+			Lines:        []purchaseStore.Received{{Line: 1, ProductID: books.ID, Quantity: qty, UnitCost: 30}},
+			DateReceived: now,
+		}
+	}
+
+	t.Log("Given the need to buy stock from suppliers")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When receiving a purchase order", testID)
+		{
+			if err := store.Create(ctx, po); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to create a purchase order: %v", tests.Failed, testID, err)
+			}
+
+			if err := store.Receive(ctx, receipt(4)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to receive part of the order: %v", tests.Failed, testID, err)
+			}
+
+			got, err := store.QueryByID(ctx, po.ID)
+			if err != nil || got.Status != purchaseStore.StatusPartiallyReceived || len(got.Lines) != 1 || got.Lines[0].Received != 4 {
+				t.Fatalf("\t%s\t Test %d Should record what came in, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should record what came in", tests.Succeeded, testID)
+
+			p, err := products.QueryByID(ctx, books.ID)
+			if err != nil || p.Quantity != 14 || p.PurchaseCost != 23 {
+				t.Fatalf("\t%s\t Test %d Should stock the goods at their average cost, got %+v %v", tests.Failed, testID, p, err)
+			}
+			t.Logf("\t%s\t Test %d Should stock the goods at their average cost", tests.Succeeded, testID)
+
+			mvs, err := products.QueryMovements(ctx, product.MovementFilter{ProductID: books.ID}, 1, 10)
+			if err != nil || len(mvs) != 2 || mvs[0].Kind != product.KindReceipt || mvs[0].ReferenceID == nil || *mvs[0].ReferenceID != po.ID {
+				t.Fatalf("\t%s\t Test %d Should record the receipt against the order, got %+v %v", tests.Failed, testID, mvs, err)
+			}
+			t.Logf("\t%s\t Test %d Should record the receipt against the order", tests.Succeeded, testID)
+
+			if err := store.Receive(ctx, receipt(7)); !errors.Is(err, purchaseStore.ErrExceedsOrdered) {
+				t.Fatalf("\t%s\t Test %d Should refuse to receive more than was ordered, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse to receive more than was ordered", tests.Succeeded, testID)
+
+			if err := store.Receive(ctx, receipt(6)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to receive the rest: %v", tests.Failed, testID, err)
+			}
+
+			got, err = store.QueryByID(ctx, po.ID)
+			if err != nil || got.Status != purchaseStore.StatusReceived || got.Lines[0].Received != 10 {
+				t.Fatalf("\t%s\t Test %d Should be received once every line is, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should be received once every line is", tests.Succeeded, testID)
+
+			if err := store.Receive(ctx, receipt(1)); !errors.Is(err, purchaseStore.ErrNotReceivable) {
+				t.Fatalf("\t%s\t Test %d Should refuse goods for a received order, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse goods for a received order", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When moving a purchase order through its statuses", testID)
+		{
+			if err := store.UpdateStatus(ctx, purchaseStore.Order{ID: po.ID, Status: purchaseStore.StatusClosed, DateUpdated: now}, purchaseStore.StatusPartiallyReceived); !errors.Is(err, purchaseStore.ErrStatusChanged) {
+				t.Fatalf("\t%s\t Test %d Should refuse to move from a status the order left, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse to move from a status the order left", tests.Succeeded, testID)
+
+			draft := po
+			draft.ID = validate.GenerateUID()
+			draft.Status = purchaseStore.StatusDraft
+			if err := store.Create(ctx, draft); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to create a purchase order: %v", tests.Failed, testID, err)
+			}
+
+			draft.Status = purchaseStore.StatusCancelled
+			if err := store.UpdateStatus(ctx, draft, purchaseStore.StatusDraft); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to cancel a draft: %v", tests.Failed, testID, err)
+			}
+
+			got, err := store.Query(ctx, purchaseStore.Filter{Status: purchaseStore.StatusCancelled, SupplierID: sp.ID}, 1, 10)
+			if err != nil || len(got) != 1 || got[0].ID != draft.ID || len(got[0].Lines) != 1 {
+				t.Fatalf("\t%s\t Test %d Should filter the orders by status and supplier, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should filter the orders by status and supplier", tests.Succeeded, testID)
+		}
+	}
+}
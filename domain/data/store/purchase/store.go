@@ -0,0 +1,329 @@
+// Package purchase persists the purchase orders raised with suppliers and
+// the goods received against them.
+package purchase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+	"time"
+)
+
+// Set of error variables for purchase orders.
+var (
+	ErrStatusChanged  = errors.New("purchase order status changed")
+	ErrNotReceivable  = errors.New("purchase order is not expecting goods")
+	ErrExceedsOrdered = errors.New("more received than was ordered")
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create stores the purchase order along with its lines.
+func (s Store) Create(ctx context.Context, o Order) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	q := `INSERT INTO purchase_orders
+	(purchase_order_id, supplier_id, warehouse_id, status, total, currency, expected_date, note, created_by, date_created, date_updated)
+	VALUES
+	(:purchase_order_id, :supplier_id, :warehouse_id, :status, :total, :currency, :expected_date, :note, :created_by, :date_created, :date_updated)`
+
+	if _, err := tx.NamedExecContext(ctx, q, o); err != nil {
+		return fmt.Errorf("inserting purchase order %s %w", o.ID, err)
+	}
+
+	q = `INSERT INTO purchase_order_lines
+	(purchase_order_id, line, product_id, variant_id, quantity, received, unit_cost, total, expected_date)
+	VALUES
+	(:purchase_order_id, :line, :product_id, :variant_id, :quantity, :received, :unit_cost, :total, :expected_date)`
+
+	for _, l := range o.Lines {
+		l.OrderID = o.ID
+		if _, err := tx.NamedExecContext(ctx, q, l); err != nil {
+			return fmt.Errorf("inserting purchase order line %s/%d %w", o.ID, l.Line, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// UpdateStatus moves the purchase order to the status it holds, from the
+// status given. It fails with ErrStatusChanged when the order is no longer
+// in that status.
+func (s Store) UpdateStatus(ctx context.Context, o Order, from string) error {
+	data := struct {
+		ID          string    `db:"purchase_order_id"`
+		Status      string    `db:"status"`
+		From        string    `db:"from"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		ID:          o.ID,
+		Status:      o.Status,
+		From:        from,
+		DateUpdated: o.DateUpdated,
+	}
+
+	q := `
+	UPDATE purchase_orders
+	SET status = :status, date_updated = :date_updated
+	WHERE purchase_order_id = :purchase_order_id AND status = :from`
+
+	res, err := s.db.NamedExecContext(ctx, q, data)
+	if err != nil {
+		return fmt.Errorf("updating purchase order %s %w", o.ID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("updating purchase order %s %w", o.ID, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("purchase order %s: %w", o.ID, ErrStatusChanged)
+	}
+	return nil
+}
+
+// Receive adds the units received to the lines of the purchase order,
+// averages their cost into the purchase cost of the products and puts
+// them into the warehouse, all in one transaction. The order is locked
+// while it happens, it must still be expecting goods and no line can
+// receive more than was ordered. The order ends up received once every
+// line is, partially received otherwise.
+func (s Store) Receive(ctx context.Context, r Receipt) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	data := struct {
+		ID          string    `db:"purchase_order_id"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		ID:          r.OrderID,
+		DateUpdated: r.DateReceived,
+	}
+
+	q := `
+	SELECT
+		status
+	FROM
+		purchase_orders
+	WHERE
+		purchase_order_id = :purchase_order_id
+	FOR UPDATE`
+
+	rows, err := tx.NamedQuery(q, data)
+	if err != nil {
+		return fmt.Errorf("locking purchase order %s %w", r.OrderID, err)
+	}
+
+	var status string
+	for rows.Next() {
+		if err := rows.Scan(&status); err != nil {
+			rows.Close()
+			return fmt.Errorf("locking purchase order %s %w", r.OrderID, err)
+		}
+	}
+	rows.Close()
+
+	if status == "" {
+		return database.ErrNotFound
+	}
+	if status != StatusOrdered && status != StatusPartiallyReceived {
+		return fmt.Errorf("purchase order %s is %s: %w", r.OrderID, status, ErrNotReceivable)
+	}
+
+	q = `
+	UPDATE purchase_order_lines
+	SET received = received + :quantity
+	WHERE purchase_order_id = :purchase_order_id AND line = :line AND received + :quantity <= quantity`
+
+	src := r.Source()
+	moves := r.Moves()
+	for i, rl := range r.Lines {
+		line := struct {
+			ID       string `db:"purchase_order_id"`
+			Line     int    `db:"line"`
+			Quantity int    `db:"quantity"`
+		}{
+			ID:       r.OrderID,
+			Line:     rl.Line,
+			Quantity: rl.Quantity,
+		}
+
+		res, err := tx.NamedExecContext(ctx, q, line)
+		if err != nil {
+			return fmt.Errorf("updating purchase order line %s/%d %w", r.OrderID, rl.Line, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("updating purchase order line %s/%d %w", r.OrderID, rl.Line, err)
+		}
+		if n == 0 {
+			return fmt.Errorf("purchase order %s line %d: %w", r.OrderID, rl.Line, ErrExceedsOrdered)
+		}
+
+		// Every line is averaged against the stock held before it comes
+		// in, two variants of a product must not see each other.
+		if err := product.AddPurchaseCost(ctx, tx, rl.ProductID, rl.Quantity, rl.UnitCost); err != nil {
+			return err
+		}
+
+		if err := product.PutStock(ctx, tx, src, moves[i:i+1], r.DateReceived); err != nil {
+			return err
+		}
+	}
+
+	q = `
+	UPDATE
+		purchase_orders AS o
+	SET
+		status = CASE
+			WHEN EXISTS (
+				SELECT 1 FROM purchase_order_lines AS l
+				WHERE l.purchase_order_id = o.purchase_order_id AND l.received < l.quantity
+			) THEN '` + StatusPartiallyReceived + `'
+			ELSE '` + StatusReceived + `'
+		END,
+		date_updated = :date_updated
+	WHERE
+		o.purchase_order_id = :purchase_order_id`
+
+	if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("updating purchase order %s %w", r.OrderID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// QueryByID returns the purchase order with the id along with its lines.
+func (s Store) QueryByID(ctx context.Context, orderID string) (Order, error) {
+	data := struct {
+		ID string `db:"purchase_order_id"`
+	}{
+		ID: orderID,
+	}
+
+	q := `SELECT * FROM purchase_orders WHERE purchase_order_id = :purchase_order_id`
+
+	var o Order
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &o); err != nil {
+		if err == database.ErrNotFound {
+			return Order{}, database.ErrNotFound
+		}
+		return Order{}, fmt.Errorf("selecting purchase order %s %w", orderID, err)
+	}
+
+	orders := []Order{o}
+	if err := s.lines(ctx, orders); err != nil {
+		return Order{}, err
+	}
+	return orders[0], nil
+}
+
+// Query returns a page of the purchase orders along with their lines,
+// latest first, narrowed down by the filter.
+func (s Store) Query(ctx context.Context, f Filter, pageNumber int, rowsPerPage int) ([]Order, error) {
+	data := struct {
+		Status      string `db:"status"`
+		SupplierID  string `db:"supplier_id"`
+		Offset      int    `db:"offset"`
+		RowsPerPage int    `db:"rows_per_page"`
+	}{
+		Status:      f.Status,
+		SupplierID:  f.SupplierID,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		purchase_orders
+	WHERE
+		(CAST(:status AS TEXT) = '' OR status = :status) AND
+		(CAST(:supplier_id AS TEXT) = '' OR CAST(supplier_id AS TEXT) = :supplier_id)
+	ORDER BY
+		date_created DESC, purchase_order_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var orders []Order
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &orders); err != nil {
+		return nil, fmt.Errorf("selecting purchase orders %w", err)
+	}
+
+	if err := s.lines(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// lines loads the lines of every purchase order in a single query.
+func (s Store) lines(ctx context.Context, orders []Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make(pq.StringArray, len(orders))
+	for i, o := range orders {
+		ids[i] = o.ID
+	}
+
+	data := struct {
+		IDs pq.StringArray `db:"purchase_order_ids"`
+	}{
+		IDs: ids,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		purchase_order_lines
+	WHERE
+		purchase_order_id = ANY(CAST(:purchase_order_ids AS UUID[]))
+	ORDER BY
+		purchase_order_id, line`
+
+	var lines []Line
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &lines); err != nil {
+		return fmt.Errorf("selecting purchase order lines %w", err)
+	}
+
+	byOrder := make(map[string][]Line, len(orders))
+	for _, l := range lines {
+		byOrder[l.OrderID] = append(byOrder[l.OrderID], l)
+	}
+
+	for i := range orders {
+		orders[i].Lines = byOrder[orders[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Package memory provides a thread safe in memory implementation of the
+// refund store with the same semantics as the postgres store. Refunded
+// totals are kept on the memory order store and goods are restocked in
+// the memory product store it is given.
+package memory
+
+import (
+	"context"
+	orderMemory "service/domain/data/store/order/memory"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	"service/domain/data/store/refund"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+)
+
+type Store struct {
+	mu       sync.Mutex
+	orders   *orderMemory.Store
+	products *productMemory.Store
+	refunds  map[string]refund.Refund
+}
+
+func NewStore(orders *orderMemory.Store, products *productMemory.Store) *Store {
+	return &Store{
+		orders:   orders,
+		products: products,
+		refunds:  make(map[string]refund.Refund),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, r refund.Refund) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.refunds[r.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+
+	if err := s.orders.Refund(ctx, r.OrderID, r.Items); err != nil {
+		return err
+	}
+
+	o, err := s.orders.QueryByID(ctx, r.OrderID)
+	if err != nil {
+		return err
+	}
+
+	var moves []product.StockMove
+	for _, item := range r.Items {
+		if item.Restocked {
+			moves = append(moves, product.StockMove{
+				WarehouseID: o.WarehouseID,
+				ProductID:   item.ProductID,
+				VariantID:   item.VariantID,
+				Quantity:    item.Quantity,
+			})
+		}
+	}
+
+	if err := s.products.Put(ctx, r.Source(), moves, r.DateCreated); err != nil {
+		return err
+	}
+
+	s.refunds[r.ID] = clone(r)
+	return nil
+}
+
+func (s *Store) QueryByOrder(ctx context.Context, orderID string) ([]refund.Refund, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refunds := []refund.Refund{}
+	for _, r := range s.refunds {
+		if r.OrderID == orderID {
+			refunds = append(refunds, clone(r))
+		}
+	}
+
+	sort.Slice(refunds, func(i, j int) bool {
+		if !refunds[i].DateCreated.Equal(refunds[j].DateCreated) {
+			return refunds[i].DateCreated.Before(refunds[j].DateCreated)
+		}
+		return refunds[i].ID < refunds[j].ID
+	})
+	return refunds, nil
+}
+
+// clone makes sure callers never share the items with the stored refund.
+func clone(r refund.Refund) refund.Refund {
+	items := make([]refund.Item, len(r.Items))
+	for i, item := range r.Items {
+		item.RefundID = r.ID
+		if item.VariantID != nil {
+			id := *item.VariantID
+			item.VariantID = &id
+		}
+		items[i] = item
+	}
+	r.Items = items
+	return r
+}
@@ -0,0 +1,70 @@
+package refund
+
+import (
+	"service/domain/data/store/product"
+	"service/foundation/money"
+	"time"
+)
+
+// Set of reasons a refund can be given for.
+const (
+	ReasonDamaged        = "damaged"
+	ReasonDefective      = "defective"
+	ReasonWrongItem      = "wrong_item"
+	ReasonNotAsDescribed = "not_as_described"
+	ReasonChangedMind    = "changed_mind"
+	ReasonOther          = "other"
+)
+
+// Refund gives back money for lines of an order, and takes back goods
+// when they are returned. It is in the currency of the order, ApprovedBy
+// is whoever granted it.
+type Refund struct {
+	ID          string         `db:"refund_id" json:"id"`
+	OrderID     string         `db:"order_id" json:"order_id"`
+	Reason      string         `db:"reason" json:"reason"`
+	Note        string         `db:"note" json:"note"`
+	Amount      int            `db:"amount" json:"amount"`
+	Currency    money.Currency `db:"currency" json:"currency"`
+	ApprovedBy  string         `db:"approved_by" json:"approved_by"`
+	Items       []Item         `db:"-" json:"items"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+}
+
+// Source returns how the restocked items of the refund are recorded in
+// the ledger.
+func (r Refund) Source() product.Source {
+	return product.Source{Kind: product.KindRefund, ReferenceID: r.ID, CreatedBy: r.ApprovedBy}
+}
+
+// Item is the part of an order line being refunded. Quantity is the units
+// returned, zero when only money is given back. Restocked units go back
+// into the stock of the variant of the line, or of the product when the
+// line has none.
+type Item struct {
+	RefundID  string  `db:"refund_id" json:"-"`
+	Line      int     `db:"line" json:"line"`
+	ProductID string  `db:"product_id" json:"product_id"`
+	VariantID *string `db:"variant_id" json:"variant_id"`
+	Quantity  int     `db:"quantity" json:"quantity"`
+	Amount    int     `db:"amount" json:"amount"`
+	Restocked bool    `db:"restocked" json:"restocked"`
+}
+
+// NewRefund is what we require to refund an order.
+type NewRefund struct {
+	Reason string    `json:"reason" validate:"required,oneof=damaged defective wrong_item not_as_described changed_mind other"`
+	Note   string    `json:"note" validate:"max=500"`
+	Items  []NewItem `json:"items" validate:"required,min=1,dive"`
+}
+
+// NewItem refunds part of an order line. Without an amount the units
+// returned are refunded at the price they were bought for, discounts
+// included, spread evenly over the units of the line. Restock is set when
+// the goods came back in a sellable condition.
+type NewItem struct {
+	Line     int  `json:"line" validate:"required,gte=1"`
+	Quantity int  `json:"quantity" validate:"gte=0"`
+	Amount   *int `json:"amount" validate:"omitempty,gte=1"`
+	Restock  bool `json:"restock"`
+}
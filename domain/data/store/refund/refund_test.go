@@ -0,0 +1,171 @@
+package refund_test
+
+import (
+	"context"
+	"errors"
+	"service/domain/core/order"
+	"service/domain/core/refund"
+	orderStore "service/domain/data/store/order"
+	orderMemory "service/domain/data/store/order/memory"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	promotionMemory "service/domain/data/store/promotion/memory"
+	refundStore "service/domain/data/store/refund"
+	"service/domain/data/store/refund/memory"
+	"service/domain/data/store/warehouse"
+	"service/domain/data/tests"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+// The seeded admin owns the products and approves the refunds, the seeded
+// user places the orders.
+const (
+	adminID = "5cf37266-3473-4006-984f-9325122678b7"
+	userID  = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+)
+
+type productStorer interface {
+	Create(ctx context.Context, p product.Product) error
+	QueryByID(ctx context.Context, productID string) (product.Product, error)
+}
+
+func TestMemory(t *testing.T) {
+	products := productMemory.NewStore()
+	orders := orderMemory.NewStore(products, promotionMemory.NewStore())
+	refunds(t, memory.NewStore(orders, products), orders, products)
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	refunds(t, refundStore.NewStore(logger, db), orderStore.NewStore(logger, db), product.NewStore(logger, db))
+}
+
+func refunds(t *testing.T, store refund.Storer, orders order.Storer, products productStorer) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	books := product.Product{ID: validate.GenerateUID(), Name: "Books", Cost: 50, Currency: money.USD, Quantity: 5, UserID: adminID, DateCreated: now, DateUpdated: now}
+	if err := products.Create(ctx, books); err != nil {
+		t.Fatalf("\t%s\t Should be able to create a product: %v", tests.Failed, err)
+	}
+
+	o := orderStore.Order{
+		ID:          validate.GenerateUID(),
+		CustomerID:  userID,
+		WarehouseID: warehouse.MainID,
+		Currency:    money.USD,
+		Total:       150,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+	o.Items = []orderStore.Item{
+		{OrderID: o.ID, Line: 1, ProductID: books.ID, Quantity: 3, UnitPrice: 50, Total: 150},
+	}
+	if err := orders.Create(ctx, o); err != nil {
+		t.Fatalf("\t%s\t Should be able to place an order: %v", tests.Failed, err)
+	}
+
+	newRefund := func(qty int, amount int, restock bool) refundStore.Refund {
+		r := refundStore.Refund{
+			ID:          validate.GenerateUID(),
+			OrderID:     o.ID,
+			Reason:      refundStore.ReasonDamaged,
+			Amount:      amount,
+			ApprovedBy:  adminID,
+			DateCreated: now,
+		}
+		r.Items = []refundStore.Item{
+			{RefundID: r.ID, Line: 1, ProductID: books.ID, Quantity: qty, Amount: amount, Restocked: restock},
+		}
+		return r
+	}
+
+	line := func() orderStore.Item {
+		t.Helper()
+
+		got, err := orders.QueryByID(ctx, o.ID)
+		if err != nil {
+			t.Fatalf("\t%s\t Should be able to query the order: %v", tests.Failed, err)
+		}
+		return got.Items[0]
+	}
+
+	stock := func() int {
+		t.Helper()
+
+		p, err := products.QueryByID(ctx, books.ID)
+		if err != nil {
+			t.Fatalf("\t%s\t Should be able to query a product: %v", tests.Failed, err)
+		}
+		return p.Quantity
+	}
+
+	t.Log("Given the need to give money back for orders")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When refunding part of a line", testID)
+		{
+			if err := store.Create(ctx, newRefund(1, 50, true)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to refund: %v", tests.Failed, testID, err)
+			}
+
+			if got := line(); got.RefundedQuantity != 1 || got.RefundedAmount != 50 {
+				t.Fatalf("\t%s\t Test %d Should add to the refunded totals, got %+v", tests.Failed, testID, got)
+			}
+			t.Logf("\t%s\t Test %d Should add to the refunded totals", tests.Succeeded, testID)
+
+			if stock() != 3 {
+				t.Fatalf("\t%s\t Test %d Should restock the returned goods, got %d", tests.Failed, testID, stock())
+			}
+			t.Logf("\t%s\t Test %d Should restock the returned goods", tests.Succeeded, testID)
+
+			if err := store.Create(ctx, newRefund(1, 20, false)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to refund: %v", tests.Failed, testID, err)
+			}
+
+			if stock() != 3 {
+				t.Fatalf("\t%s\t Test %d Should not restock damaged goods, got %d", tests.Failed, testID, stock())
+			}
+			t.Logf("\t%s\t Test %d Should not restock damaged goods", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When refunding more than was paid", testID)
+		{
+			if err := store.Create(ctx, newRefund(0, 81, false)); !errors.Is(err, refundStore.ErrExceedsPaid) {
+				t.Fatalf("\t%s\t Test %d Should refuse more money than was paid, got %v", tests.Failed, testID, err)
+			}
+
+			if err := store.Create(ctx, newRefund(2, 10, true)); !errors.Is(err, refundStore.ErrExceedsPaid) {
+				t.Fatalf("\t%s\t Test %d Should refuse more goods than were bought, got %v", tests.Failed, testID, err)
+			}
+
+			if got := line(); got.RefundedQuantity != 2 || got.RefundedAmount != 70 || stock() != 3 {
+				t.Fatalf("\t%s\t Test %d Should leave everything untouched, got %+v %d", tests.Failed, testID, got, stock())
+			}
+			t.Logf("\t%s\t Test %d Should refuse more than was paid", tests.Succeeded, testID)
+
+			if err := store.Create(ctx, newRefund(1, 80, true)); err != nil {
+				t.Fatalf("\t%s\t Test %d Should refund up to what was paid: %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refund up to what was paid", tests.Succeeded, testID)
+
+			got, err := store.QueryByOrder(ctx, o.ID)
+			if err != nil || len(got) != 3 || len(got[0].Items) != 1 {
+				t.Fatalf("\t%s\t Test %d Should list the refunds of the order, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should list the refunds of the order", tests.Succeeded, testID)
+		}
+	}
+}
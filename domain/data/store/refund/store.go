@@ -0,0 +1,204 @@
+// Package refund persists refunds and keeps the refunded totals of the
+// order lines they apply to.
+package refund
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+)
+
+// ErrExceedsPaid is returned when a refund would give back more money, or
+// take back more units, than the order line was paid for.
+var ErrExceedsPaid = errors.New("refund exceeds what was paid")
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create adds the refund to the refunded totals of its order lines,
+// restocks the returned goods in the warehouse of the order and stores
+// the refund in one transaction. The totals are checked against the
+// lines in the same statement that updates them, concurrent refunds can
+// not both slip under the limit.
+func (s Store) Create(ctx context.Context, r Refund) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	q := `
+	UPDATE order_items
+	SET
+		refunded_quantity = refunded_quantity + :quantity,
+		refunded_amount = refunded_amount + :amount
+	WHERE
+		order_id = :order_id AND line = :line AND
+		refunded_quantity + :quantity <= quantity AND
+		refunded_amount + :amount <= total`
+
+	for _, item := range r.Items {
+		data := struct {
+			OrderID  string `db:"order_id"`
+			Line     int    `db:"line"`
+			Quantity int    `db:"quantity"`
+			Amount   int    `db:"amount"`
+		}{
+			OrderID:  r.OrderID,
+			Line:     item.Line,
+			Quantity: item.Quantity,
+			Amount:   item.Amount,
+		}
+
+		res, err := tx.NamedExecContext(ctx, q, data)
+		if err != nil {
+			return fmt.Errorf("refunding line %s/%d %w", r.OrderID, item.Line, err)
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("refunding line %s/%d %w", r.OrderID, item.Line, err)
+		}
+		if n == 0 {
+			return fmt.Errorf("line %d: %w", item.Line, ErrExceedsPaid)
+		}
+	}
+
+	if err := s.restock(ctx, tx, r); err != nil {
+		return err
+	}
+
+	q = `INSERT INTO refunds
+	(refund_id, order_id, reason, note, amount, currency, approved_by, date_created)
+	VALUES
+	(:refund_id, :order_id, :reason, :note, :amount, :currency, :approved_by, :date_created)`
+
+	if _, err := tx.NamedExecContext(ctx, q, r); err != nil {
+		return fmt.Errorf("inserting refund %s %w", r.ID, err)
+	}
+
+	q = `INSERT INTO refund_items
+	(refund_id, line, product_id, variant_id, quantity, amount, restocked)
+	VALUES
+	(:refund_id, :line, :product_id, :variant_id, :quantity, :amount, :restocked)`
+
+	for _, item := range r.Items {
+		item.RefundID = r.ID
+		if _, err := tx.NamedExecContext(ctx, q, item); err != nil {
+			return fmt.Errorf("inserting refund item %s/%d %w", r.ID, item.Line, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// restock puts the restocked items of the refund back into the warehouse
+// their order was picked from.
+func (s Store) restock(ctx context.Context, tx *sqlx.Tx, r Refund) error {
+	var moves []product.StockMove
+	for _, item := range r.Items {
+		if item.Restocked {
+			moves = append(moves, product.StockMove{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Quantity:  item.Quantity,
+			})
+		}
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+
+	data := struct {
+		OrderID string `db:"order_id"`
+	}{
+		OrderID: r.OrderID,
+	}
+
+	q := `SELECT warehouse_id FROM orders WHERE order_id = :order_id`
+
+	rows, err := tx.NamedQuery(q, data)
+	if err != nil {
+		return fmt.Errorf("selecting warehouse of order %s %w", r.OrderID, err)
+	}
+
+	var warehouseID string
+	for rows.Next() {
+		if err := rows.Scan(&warehouseID); err != nil {
+			rows.Close()
+			return fmt.Errorf("selecting warehouse of order %s %w", r.OrderID, err)
+		}
+	}
+	rows.Close()
+
+	for i := range moves {
+		moves[i].WarehouseID = warehouseID
+	}
+	return product.PutStock(ctx, tx, r.Source(), moves, r.DateCreated)
+}
+
+// QueryByOrder returns the refunds of the order along with their items,
+// oldest first.
+func (s Store) QueryByOrder(ctx context.Context, orderID string) ([]Refund, error) {
+	data := struct {
+		OrderID string `db:"order_id"`
+	}{
+		OrderID: orderID,
+	}
+
+	q := `SELECT * FROM refunds WHERE order_id = :order_id ORDER BY date_created, refund_id`
+
+	var refunds []Refund
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &refunds); err != nil {
+		return nil, fmt.Errorf("selecting refunds of %s %w", orderID, err)
+	}
+
+	if len(refunds) == 0 {
+		return refunds, nil
+	}
+
+	ids := make(pq.StringArray, len(refunds))
+	for i, r := range refunds {
+		ids[i] = r.ID
+	}
+
+	itemData := struct {
+		RefundIDs pq.StringArray `db:"refund_ids"`
+	}{
+		RefundIDs: ids,
+	}
+
+	q = `SELECT * FROM refund_items WHERE refund_id = ANY(CAST(:refund_ids AS UUID[])) ORDER BY refund_id, line`
+
+	var items []Item
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, itemData, &items); err != nil {
+		return nil, fmt.Errorf("selecting refund items of %s %w", orderID, err)
+	}
+
+	byRefund := make(map[string][]Item, len(refunds))
+	for _, item := range items {
+		byRefund[item.RefundID] = append(byRefund[item.RefundID], item)
+	}
+
+	for i := range refunds {
+		refunds[i].Items = byRefund[refunds[i].ID]
+	}
+	return refunds, nil
+}
@@ -0,0 +1,61 @@
+// Package memory provides a thread safe in memory implementation of the
+// reset token store with the same semantics as the postgres store.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/reset"
+	"service/domain/sys/database"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu     sync.Mutex
+	tokens map[string]reset.Token
+}
+
+func NewStore() *Store {
+	return &Store{
+		tokens: make(map[string]reset.Token),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, tkn reset.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[tkn.Hash] = tkn
+	return nil
+}
+
+func (s *Store) Consume(ctx context.Context, hash string, now time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tkn, ok := s.tokens[hash]
+	if !ok || tkn.DateUsed != nil || !tkn.DateExpires.After(now) {
+		return "", database.ErrNotFound
+	}
+
+	for h, other := range s.tokens {
+		if other.UserID == tkn.UserID && other.DateUsed == nil {
+			used := now
+			other.DateUsed = &used
+			s.tokens[h] = other
+		}
+	}
+	return tkn.UserID, nil
+}
+
+func (s *Store) DeleteExpired(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for h, tkn := range s.tokens {
+		if !tkn.DateExpires.After(now) || tkn.DateUsed != nil {
+			delete(s.tokens, h)
+		}
+	}
+	return nil
+}
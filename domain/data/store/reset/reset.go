@@ -0,0 +1,15 @@
+package reset
+
+import (
+	"time"
+)
+
+// Token is a password reset token. Only the hash of the token is stored,
+// the token itself is mailed to the user.
+type Token struct {
+	Hash        string     `db:"token_hash"`
+	UserID      string     `db:"user_id"`
+	DateExpires time.Time  `db:"date_expires"`
+	DateUsed    *time.Time `db:"date_used"`
+	DateCreated time.Time  `db:"date_created"`
+}
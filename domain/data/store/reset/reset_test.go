@@ -0,0 +1,87 @@
+package reset_test
+
+import (
+	"context"
+	"errors"
+	"service/domain/core/reset"
+	resetStore "service/domain/data/store/reset"
+	"service/domain/data/store/reset/memory"
+	"service/domain/data/store/user"
+	"service/domain/data/tests"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+func TestMemory(t *testing.T) {
+	consume(t, memory.NewStore(), "45b5fbd3-755f-4379-8f07-a58d4a30fa2f")
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	usr, err := user.NewStore(logger, db).Create(context.Background(), user.NewUser{
+		Name:            "Reset Gopher",
+		Email:           "reset@example.com",
+		Roles:           []string{auth.RoleUser},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to create a user: %v", tests.Failed, err)
+	}
+
+	consume(t, resetStore.NewStore(logger, db), usr.ID)
+}
+
+func consume(t *testing.T, store reset.Storer, userID string) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need for reset tokens to be used once before they expire")
+	{
+		for _, tkn := range []resetStore.Token{
+			{Hash: "first", UserID: userID, DateExpires: now.Add(time.Hour), DateCreated: now},
+			{Hash: "second", UserID: userID, DateExpires: now.Add(time.Hour), DateCreated: now},
+			{Hash: "expired", UserID: userID, DateExpires: now.Add(-time.Minute), DateCreated: now.Add(-time.Hour)},
+		} {
+			if err := store.Create(ctx, tkn); err != nil {
+				t.Fatalf("\t%s\tShould be able to create token %s: %v", tests.Failed, tkn.Hash, err)
+			}
+		}
+
+		if _, err := store.Consume(ctx, "expired", now); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("\t%s\tShould not consume an expired token, got %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould not consume an expired token", tests.Succeeded)
+
+		got, err := store.Consume(ctx, "first", now)
+		if err != nil || got != userID {
+			t.Fatalf("\t%s\tShould consume the token for the user, got %q %v", tests.Failed, got, err)
+		}
+		t.Logf("\t%s\tShould consume the token for the user", tests.Succeeded)
+
+		if _, err := store.Consume(ctx, "first", now); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("\t%s\tShould not consume a token twice, got %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould not consume a token twice", tests.Succeeded)
+
+		if _, err := store.Consume(ctx, "second", now); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("\t%s\tShould use up the other tokens of the user, got %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould use up the other tokens of the user", tests.Succeeded)
+
+		if err := store.DeleteExpired(ctx, now); err != nil {
+			t.Fatalf("\t%s\tShould be able to delete expired tokens: %v", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould be able to delete expired tokens", tests.Succeeded)
+	}
+}
@@ -0,0 +1,91 @@
+// Package reset persists the password reset tokens mailed to users.
+package reset
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+	"time"
+)
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create stores a new token.
+func (s Store) Create(ctx context.Context, tkn Token) error {
+	q := `INSERT INTO password_resets
+	(token_hash, user_id, date_expires, date_used, date_created)
+	VALUES
+	(:token_hash, :user_id, :date_expires, :date_used, :date_created)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, tkn); err != nil {
+		return fmt.Errorf("inserting reset token %w", err)
+	}
+	return nil
+}
+
+// Consume marks the token as used and returns the user it was issued for.
+// Every other outstanding token of that user is used up along with it.
+// Unknown, used and expired tokens all return database.ErrNotFound.
+func (s Store) Consume(ctx context.Context, hash string, now time.Time) (string, error) {
+	data := struct {
+		Hash string    `db:"token_hash"`
+		Now  time.Time `db:"now"`
+	}{
+		Hash: hash,
+		Now:  now,
+	}
+
+	// Both updates run in one statement so two requests racing with the
+	// same token can not both succeed.
+	q := `
+	WITH used AS (
+		UPDATE password_resets
+		SET date_used = :now
+		WHERE token_hash = :token_hash AND date_used IS NULL AND date_expires > :now
+		RETURNING user_id
+	), others AS (
+		UPDATE password_resets
+		SET date_used = :now
+		WHERE user_id IN (SELECT user_id FROM used) AND token_hash <> :token_hash AND date_used IS NULL
+	)
+	SELECT user_id FROM used`
+
+	var row struct {
+		UserID string `db:"user_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &row); err != nil {
+		if err == database.ErrNotFound {
+			return "", database.ErrNotFound
+		}
+		return "", fmt.Errorf("consuming reset token %w", err)
+	}
+	return row.UserID, nil
+}
+
+// DeleteExpired removes tokens that can no longer be used.
+func (s Store) DeleteExpired(ctx context.Context, now time.Time) error {
+	data := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: now,
+	}
+
+	q := `DELETE FROM password_resets WHERE date_expires <= :now OR date_used IS NOT NULL`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting expired reset tokens %w", err)
+	}
+	return nil
+}
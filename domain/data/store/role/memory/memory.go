@@ -0,0 +1,177 @@
+// Package memory provides a thread safe in memory implementation of the
+// role store with the same semantics as the postgres store. A new store
+// holds the permissions and roles seeded by the migrations.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/role"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu          sync.RWMutex
+	permissions map[string]role.Permission
+	roles       map[string]role.Role
+}
+
+func NewStore() *Store {
+	s := Store{
+		permissions: make(map[string]role.Permission),
+		roles:       make(map[string]role.Role),
+	}
+
+	for _, p := range seedPermissions {
+		s.permissions[p.Name] = p
+	}
+
+	var all []string
+	for _, p := range seedPermissions {
+		all = append(all, p.Name)
+	}
+
+	now := time.Date(2019, time.March, 24, 0, 0, 0, 0, time.UTC)
+	s.roles[auth.RoleAdmin] = role.Role{
+		Name:        auth.RoleAdmin,
+		Description: "Administrators",
+		Permissions: all,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+	s.roles[auth.RoleUser] = role.Role{
+		Name:        auth.RoleUser,
+		Description: "Regular users",
+		Permissions: []string{auth.PermProductsRead, auth.PermSalesRead},
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	return &s
+}
+
+func (s *Store) Create(ctx context.Context, r role.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.roles[r.Name]; ok {
+		return role.ErrUniqueName
+	}
+
+	s.roles[r.Name] = clone(r)
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, r role.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.roles[r.Name]
+	if !ok {
+		return nil
+	}
+
+	cur.Description = r.Description
+	cur.Permissions = r.Permissions
+	cur.DateUpdated = r.DateUpdated
+	s.roles[r.Name] = clone(cur)
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.roles, name)
+	return nil
+}
+
+func (s *Store) Query(ctx context.Context) ([]role.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := make([]role.Role, 0, len(s.roles))
+	for _, r := range s.roles {
+		roles = append(roles, clone(r))
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+	return roles, nil
+}
+
+func (s *Store) QueryByName(ctx context.Context, name string) (role.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.roles[name]
+	if !ok {
+		return role.Role{}, database.ErrNotFound
+	}
+	return clone(r), nil
+}
+
+func (s *Store) QueryPermissions(ctx context.Context) ([]role.Permission, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	perms := make([]role.Permission, 0, len(s.permissions))
+	for _, p := range s.permissions {
+		perms = append(perms, p)
+	}
+
+	sort.Slice(perms, func(i, j int) bool {
+		return perms[i].Name < perms[j].Name
+	})
+	return perms, nil
+}
+
+func (s *Store) PermissionsFor(ctx context.Context, roles []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := make(map[string]bool)
+	for _, name := range roles {
+		for _, p := range s.roles[name].Permissions {
+			set[p] = true
+		}
+	}
+
+	perms := make([]string, 0, len(set))
+	for p := range set {
+		perms = append(perms, p)
+	}
+	sort.Strings(perms)
+	return perms, nil
+}
+
+// clone makes sure callers never share slices with the stored role, the
+// permissions are kept sorted like postgres returns them.
+func clone(r role.Role) role.Role {
+	r.Permissions = append([]string{}, r.Permissions...)
+	sort.Strings(r.Permissions)
+	return r
+}
+
+// seedPermissions mirrors the permissions inserted by migrations 1.9, 2.7
+// and 3.1.
+var seedPermissions = []role.Permission{
+	{Name: auth.PermUsersRead, Description: "View users"},
+	{Name: auth.PermUsersWrite, Description: "Create, update and delete users"},
+	{Name: auth.PermRolesRead, Description: "View roles and permissions"},
+	{Name: auth.PermRolesWrite, Description: "Manage roles and their permissions"},
+	{Name: auth.PermProductsRead, Description: "View products"},
+	{Name: auth.PermProductsWrite, Description: "Create, update and delete products"},
+	{Name: auth.PermSalesRead, Description: "View sales"},
+	{Name: auth.PermSalesWrite, Description: "Record sales"},
+	{Name: auth.PermSalesRefund, Description: "Refund sales"},
+	{Name: auth.PermReportsRead, Description: "View reports"},
+	{Name: auth.PermInventoryRead, Description: "View warehouses and stock transfers"},
+	{Name: auth.PermInventoryWrite, Description: "Manage warehouses, count stock and transfer it"},
+	{Name: auth.PermPromotionsRead, Description: "View coupons and promotions"},
+	{Name: auth.PermPromotionsWrite, Description: "Manage coupons and promotions"},
+}
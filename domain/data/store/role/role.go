@@ -0,0 +1,36 @@
+package role
+
+import (
+	"github.com/lib/pq"
+	"time"
+)
+
+// Role is a named set of permissions. Users hold roles, tokens carry the
+// permissions of every role of the user.
+type Role struct {
+	Name        string         `db:"name" json:"name"`
+	Description string         `db:"description" json:"description"`
+	Permissions pq.StringArray `db:"permissions" json:"permissions"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+// NewRole is what we require from admins when adding a role.
+type NewRole struct {
+	Name        string   `json:"name" validate:"required,uppercase,excludesall= "`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" validate:"required"`
+}
+
+// UpdateRole contains the fields of a role that can change. Permissions
+// replace the current ones when not nil.
+type UpdateRole struct {
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// Permission is an action a role can be granted, like products:write.
+type Permission struct {
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+}
@@ -0,0 +1,116 @@
+package role_test
+
+import (
+	"context"
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"service/domain/core/role"
+	roleStore "service/domain/data/store/role"
+	"service/domain/data/store/role/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+func TestMemory(t *testing.T) {
+	roles(t, memory.NewStore())
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	roles(t, roleStore.NewStore(logger, db))
+}
+
+func roles(t *testing.T, store role.Storer) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need to keep roles and their permissions")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When reading the seeded roles", testID)
+		{
+			perms, err := store.QueryPermissions(ctx)
+			if err != nil || len(perms) != 14 {
+				t.Fatalf("\t%s\t Test %d Should find the seeded permissions, got %d %v", tests.Failed, testID, len(perms), err)
+			}
+			t.Logf("\t%s\t Test %d Should find the seeded permissions", tests.Succeeded, testID)
+
+			admin, err := store.QueryByName(ctx, auth.RoleAdmin)
+			if err != nil || len(admin.Permissions) != len(perms) {
+				t.Fatalf("\t%s\t Test %d Should grant admins every permission, got %v %v", tests.Failed, testID, admin.Permissions, err)
+			}
+			t.Logf("\t%s\t Test %d Should grant admins every permission", tests.Succeeded, testID)
+
+			got, err := store.PermissionsFor(ctx, []string{auth.RoleUser, "UNKNOWN"})
+			exp := []string{auth.PermProductsRead, auth.PermSalesRead}
+			if err != nil || !cmp.Equal(got, exp) {
+				t.Fatalf("\t%s\t Test %d Should resolve the permissions of users, got %v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should resolve the permissions of users", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When managing roles", testID)
+		{
+			r := roleStore.Role{
+				Name:        "SUPPORT",
+				Description: "Support agents",
+				Permissions: []string{auth.PermSalesRead, auth.PermSalesRefund},
+				DateCreated: now,
+				DateUpdated: now,
+			}
+
+			if err := store.Create(ctx, r); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to create a role: %v", tests.Failed, testID, err)
+			}
+
+			if err := store.Create(ctx, r); !errors.Is(err, roleStore.ErrUniqueName) {
+				t.Fatalf("\t%s\t Test %d Should reject a duplicated name, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should reject a duplicated name", tests.Succeeded, testID)
+
+			got, err := store.PermissionsFor(ctx, []string{auth.RoleUser, "SUPPORT"})
+			exp := []string{auth.PermProductsRead, auth.PermSalesRead, auth.PermSalesRefund}
+			if err != nil || !cmp.Equal(got, exp) {
+				t.Fatalf("\t%s\t Test %d Should merge the permissions of every role, got %v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should merge the permissions of every role", tests.Succeeded, testID)
+
+			r.Description = "Support"
+			r.Permissions = []string{auth.PermSalesRead}
+			r.DateUpdated = now.Add(time.Hour)
+			if err := store.Update(ctx, r); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to update the role: %v", tests.Failed, testID, err)
+			}
+
+			saved, err := store.QueryByName(ctx, "SUPPORT")
+			if err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to retrieve the role: %v", tests.Failed, testID, err)
+			}
+			if diff := cmp.Diff(r, saved); diff != "" {
+				t.Fatalf("\t%s\t Test %d Should replace the permissions:\n%s", tests.Failed, testID, diff)
+			}
+			t.Logf("\t%s\t Test %d Should replace the permissions", tests.Succeeded, testID)
+
+			if err := store.Delete(ctx, "SUPPORT"); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to delete the role: %v", tests.Failed, testID, err)
+			}
+
+			if _, err := store.QueryByName(ctx, "SUPPORT"); !errors.Is(err, database.ErrNotFound) {
+				t.Fatalf("\t%s\t Test %d Should not find a deleted role, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should not find a deleted role", tests.Succeeded, testID)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package role persists roles, the permissions known to the service and
+// which permissions every role grants.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+)
+
+// ErrUniqueName is returned when a role is created with a name another
+// role already holds.
+var ErrUniqueName = errors.New("role name is not unique")
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// selectRoles aggregates the permissions of every role into one row.
+const selectRoles = `
+	SELECT
+		r.name, r.description, r.date_created, r.date_updated,
+		COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}') AS permissions
+	FROM
+		roles AS r
+	LEFT JOIN
+		role_permissions AS rp ON rp.role = r.name`
+
+// Create adds the role along with its permissions.
+func (s Store) Create(ctx context.Context, r Role) error {
+	q := `
+	WITH r AS (
+		INSERT INTO roles
+			(name, description, date_created, date_updated)
+		VALUES
+			(:name, :description, :date_created, :date_updated)
+		RETURNING name
+	)
+	INSERT INTO role_permissions
+		(role, permission)
+	SELECT
+		r.name, p.permission
+	FROM
+		r, unnest(CAST(:permissions AS TEXT[])) AS p(permission)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, r); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueName
+		}
+		return fmt.Errorf("inserting role %s %w", r.Name, err)
+	}
+	return nil
+}
+
+// Update replaces the description and the permissions of the role.
+func (s Store) Update(ctx context.Context, r Role) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	q := `
+	UPDATE roles
+	SET description = :description, date_updated = :date_updated
+	WHERE name = :name`
+
+	if _, err := tx.NamedExecContext(ctx, q, r); err != nil {
+		return fmt.Errorf("updating role %s %w", r.Name, err)
+	}
+
+	if _, err := tx.NamedExecContext(ctx, `DELETE FROM role_permissions WHERE role = :name`, r); err != nil {
+		return fmt.Errorf("deleting role permissions %s %w", r.Name, err)
+	}
+
+	if err := insertPermissions(ctx, tx, r.Name, r.Permissions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// Delete removes the role, its permissions go with it.
+func (s Store) Delete(ctx context.Context, name string) error {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: name,
+	}
+
+	q := `DELETE FROM roles WHERE name = :name`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, data); err != nil {
+		return fmt.Errorf("deleting role %s %w", name, err)
+	}
+	return nil
+}
+
+// Query returns every role ordered by name.
+func (s Store) Query(ctx context.Context) ([]Role, error) {
+	q := selectRoles + `
+	GROUP BY
+		r.name
+	ORDER BY
+		r.name`
+
+	var roles []Role
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &roles); err != nil {
+		return nil, fmt.Errorf("selecting roles %w", err)
+	}
+	return roles, nil
+}
+
+// QueryByName returns the role with the name.
+func (s Store) QueryByName(ctx context.Context, name string) (Role, error) {
+	data := struct {
+		Name string `db:"name"`
+	}{
+		Name: name,
+	}
+
+	q := selectRoles + `
+	WHERE
+		r.name = :name
+	GROUP BY
+		r.name`
+
+	var r Role
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &r); err != nil {
+		if err == database.ErrNotFound {
+			return Role{}, database.ErrNotFound
+		}
+		return Role{}, fmt.Errorf("selecting role %s %w", name, err)
+	}
+	return r, nil
+}
+
+// QueryPermissions returns every permission known to the service.
+func (s Store) QueryPermissions(ctx context.Context) ([]Permission, error) {
+	q := `SELECT * FROM permissions ORDER BY name`
+
+	var perms []Permission
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &perms); err != nil {
+		return nil, fmt.Errorf("selecting permissions %w", err)
+	}
+	return perms, nil
+}
+
+// PermissionsFor returns the permissions granted by any of the roles,
+// sorted and without duplicates. Unknown roles grant nothing.
+func (s Store) PermissionsFor(ctx context.Context, roles []string) ([]string, error) {
+	data := struct {
+		Roles pq.StringArray `db:"roles"`
+	}{
+		Roles: roles,
+	}
+
+	q := `
+	SELECT DISTINCT
+		permission
+	FROM
+		role_permissions
+	WHERE
+		role = ANY(:roles)
+	ORDER BY
+		permission`
+
+	var rows []struct {
+		Permission string `db:"permission"`
+	}
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &rows); err != nil {
+		return nil, fmt.Errorf("selecting permissions for %v %w", roles, err)
+	}
+
+	perms := make([]string, len(rows))
+	for i, row := range rows {
+		perms[i] = row.Permission
+	}
+	return perms, nil
+}
+
+func insertPermissions(ctx context.Context, tx *sqlx.Tx, role string, perms []string) error {
+	q := `INSERT INTO role_permissions (role, permission) VALUES (:role, :permission)`
+
+	for _, perm := range perms {
+		data := struct {
+			Role       string `db:"role"`
+			Permission string `db:"permission"`
+		}{
+			Role:       role,
+			Permission: perm,
+		}
+
+		if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+			return fmt.Errorf("inserting role permission %s %s %w", role, perm, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+// Package memory provides a thread safe in memory implementation of the
+// stocktake store with the same semantics as the postgres store. Variances
+// are posted to the memory product store it is given.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	"service/domain/data/store/stocktake"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+)
+
+type Store struct {
+	mu         sync.Mutex
+	products   *productMemory.Store
+	stocktakes map[string]stocktake.Stocktake
+}
+
+func NewStore(products *productMemory.Store) *Store {
+	return &Store{
+		products:   products,
+		stocktakes: make(map[string]stocktake.Stocktake),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, st stocktake.Stocktake) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.stocktakes[st.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+
+	st.Lines = nil
+	s.stocktakes[st.ID] = clone(st)
+	return nil
+}
+
+func (s *Store) Count(ctx context.Context, st stocktake.Stocktake) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.stocktakes[st.ID]
+	if !ok || cur.Status != stocktake.StatusOpen {
+		return fmt.Errorf("stocktake %s: %w", st.ID, stocktake.ErrNotOpen)
+	}
+
+	for _, line := range st.Lines {
+		i := find(cur.Lines, line)
+		if i < 0 {
+			cur.Lines = append(cur.Lines, line)
+			continue
+		}
+		cur.Lines[i].Counted = line.Counted
+	}
+	sortLines(cur.Lines)
+
+	cur.DateUpdated = st.DateUpdated
+	s.stocktakes[st.ID] = clone(cur)
+	return nil
+}
+
+func (s *Store) Post(ctx context.Context, st stocktake.Stocktake) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.stocktakes[st.ID]
+	if !ok || cur.Status != stocktake.StatusOpen {
+		return fmt.Errorf("stocktake %s: %w", st.ID, stocktake.ErrNotOpen)
+	}
+
+	moves := make([]product.StockMove, len(cur.Lines))
+	for i, line := range cur.Lines {
+		m := product.StockMove{WarehouseID: cur.WarehouseID, ProductID: line.ProductID, VariantID: line.VariantID}
+
+		expected := s.products.LedgerBalance(ctx, m)
+		variance := line.Counted - expected
+		cur.Lines[i].Expected = &expected
+		cur.Lines[i].Variance = &variance
+
+		m.Quantity = variance
+		moves[i] = m
+	}
+
+	// Every variance taken out of a level is checked before any is posted,
+	// so a failing stocktake posts nothing like the postgres store.
+	for _, m := range moves {
+		if m.Quantity >= 0 {
+			continue
+		}
+		if held := s.level(ctx, m); held < -m.Quantity {
+			return fmt.Errorf("product %s in warehouse %s: %w", m.ProductID, m.WarehouseID, product.ErrInsufficientStock)
+		}
+	}
+
+	for _, m := range moves {
+		if m.Quantity == 0 {
+			continue
+		}
+		if err := s.products.Adjust(ctx, cur.Source(), m, st.DateUpdated); err != nil {
+			return err
+		}
+	}
+
+	cur.Status = stocktake.StatusPosted
+	cur.DateUpdated = st.DateUpdated
+	s.stocktakes[st.ID] = clone(cur)
+	return nil
+}
+
+func (s *Store) Cancel(ctx context.Context, st stocktake.Stocktake) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.stocktakes[st.ID]
+	if !ok || cur.Status != stocktake.StatusOpen {
+		return fmt.Errorf("stocktake %s: %w", st.ID, stocktake.ErrNotOpen)
+	}
+
+	cur.Status = stocktake.StatusCancelled
+	cur.DateUpdated = st.DateUpdated
+	s.stocktakes[st.ID] = cur
+	return nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, stocktakeID string) (stocktake.Stocktake, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.stocktakes[stocktakeID]
+	if !ok {
+		return stocktake.Stocktake{}, database.ErrNotFound
+	}
+	return clone(st), nil
+}
+
+func (s *Store) Query(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]stocktake.Stocktake, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stocktakes []stocktake.Stocktake
+	for _, st := range s.stocktakes {
+		if status == "" || st.Status == status {
+			stocktakes = append(stocktakes, clone(st))
+		}
+	}
+
+	sort.Slice(stocktakes, func(i, j int) bool {
+		if !stocktakes[i].DateCreated.Equal(stocktakes[j].DateCreated) {
+			return stocktakes[i].DateCreated.After(stocktakes[j].DateCreated)
+		}
+		return stocktakes[i].ID < stocktakes[j].ID
+	})
+
+	start := (pageNumber - 1) * rowsPerPage
+	if start >= len(stocktakes) {
+		return []stocktake.Stocktake{}, nil
+	}
+
+	end := start + rowsPerPage
+	if end > len(stocktakes) {
+		end = len(stocktakes)
+	}
+	return stocktakes[start:end], nil
+}
+
+// level returns how many units the level of the move holds.
+func (s *Store) level(ctx context.Context, m product.StockMove) int {
+	levels, _ := s.products.QueryLevels(ctx, m.ProductID)
+	for _, l := range levels {
+		if l.WarehouseID == m.WarehouseID && variantKey(l.VariantID) == variantKey(m.VariantID) {
+			return l.Quantity
+		}
+	}
+	return 0
+}
+
+// find returns the index of the line counting the same product and
+// variant as line, -1 when there is none.
+func find(lines []stocktake.Line, line stocktake.Line) int {
+	for i, l := range lines {
+		if l.ProductID == line.ProductID && variantKey(l.VariantID) == variantKey(line.VariantID) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortLines orders the lines by product and variant.
+func sortLines(lines []stocktake.Line) {
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].ProductID != lines[j].ProductID {
+			return lines[i].ProductID < lines[j].ProductID
+		}
+		return variantKey(lines[i].VariantID) < variantKey(lines[j].VariantID)
+	})
+}
+
+func variantKey(variantID *string) string {
+	if variantID == nil {
+		return ""
+	}
+	return *variantID
+}
+
+// clone makes sure callers never share the lines with the stored
+// stocktake.
+func clone(st stocktake.Stocktake) stocktake.Stocktake {
+	lines := make([]stocktake.Line, len(st.Lines))
+	for i, line := range st.Lines {
+		line.StocktakeID = st.ID
+		if line.VariantID != nil {
+			id := *line.VariantID
+			line.VariantID = &id
+		}
+		for _, p := range []**int{&line.Expected, &line.Variance} {
+			if *p != nil {
+				n := **p
+				*p = &n
+			}
+		}
+		lines[i] = line
+	}
+	st.Lines = lines
+	return st
+}
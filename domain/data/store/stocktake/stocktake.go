@@ -0,0 +1,65 @@
+package stocktake
+
+import (
+	"service/domain/data/store/product"
+	"time"
+)
+
+// Set of states a stocktake goes through. Counts are taken while it is
+// open, posting it moves every counted level to what was counted.
+const (
+	StatusOpen      = "open"
+	StatusPosted    = "posted"
+	StatusCancelled = "cancelled"
+)
+
+// Stocktake is a count of the stock held by a warehouse. CreatedBy is
+// whoever opened it, the variances are posted on their behalf.
+type Stocktake struct {
+	ID          string    `db:"stocktake_id" json:"id"`
+	WarehouseID string    `db:"warehouse_id" json:"warehouse_id"`
+	Status      string    `db:"status" json:"status"`
+	Note        string    `db:"note" json:"note"`
+	CreatedBy   string    `db:"created_by" json:"created_by"`
+	Lines       []Line    `db:"-" json:"lines"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// Source returns how the variances of the stocktake are recorded in the
+// ledger.
+func (st Stocktake) Source() product.Source {
+	return product.Source{Kind: product.KindAdjustment, Reason: product.ReasonStocktake, ReferenceID: st.ID, CreatedBy: st.CreatedBy}
+}
+
+// Line is how many units of a product, or of one of its variants, were
+// counted. Expected is what the ledger held and Variance what was posted
+// to match the count, both are set once the stocktake is posted.
+type Line struct {
+	StocktakeID string  `db:"stocktake_id" json:"-"`
+	ProductID   string  `db:"product_id" json:"product_id"`
+	VariantID   *string `db:"variant_id" json:"variant_id"`
+	Counted     int     `db:"counted" json:"counted"`
+	Expected    *int    `db:"expected" json:"expected"`
+	Variance    *int    `db:"variance" json:"variance"`
+}
+
+// NewStocktake is what we require to start counting a warehouse.
+type NewStocktake struct {
+	WarehouseID string `json:"warehouse_id" validate:"required,uuid"`
+	Note        string `json:"note" validate:"max=500"`
+}
+
+// NewCounts are counts taken during a stocktake. Counting a product, or a
+// variant, again replaces what was counted before.
+type NewCounts struct {
+	Counts []NewCount `json:"counts" validate:"required,min=1,dive"`
+}
+
+// NewCount is a product, or one of its variants, and how many of it were
+// counted.
+type NewCount struct {
+	ProductID string  `json:"product_id" validate:"required,uuid"`
+	VariantID *string `json:"variant_id" validate:"omitempty,uuid"`
+	Counted   int     `json:"counted" validate:"gte=0"`
+}
@@ -0,0 +1,181 @@
+package stocktake_test
+
+import (
+	"context"
+	"errors"
+	"service/domain/core/stocktake"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	stocktakeStore "service/domain/data/store/stocktake"
+	"service/domain/data/store/stocktake/memory"
+	"service/domain/data/store/warehouse"
+	"service/domain/data/tests"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+// adminID is the seeded admin, who owns the products and counts them.
+const adminID = "5cf37266-3473-4006-984f-9325122678b7"
+
+type productStorer interface {
+	Create(ctx context.Context, p product.Product) error
+	QueryByID(ctx context.Context, productID string) (product.Product, error)
+	QueryMovements(ctx context.Context, f product.MovementFilter, pageNumber int, rowsPerPage int) ([]product.Movement, error)
+}
+
+func TestMemory(t *testing.T) {
+	products := productMemory.NewStore()
+	stocktakes(t, memory.NewStore(products), products)
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	stocktakes(t, stocktakeStore.NewStore(logger, db), product.NewStore(logger, db))
+}
+
+func stocktakes(t *testing.T, store stocktake.Storer, products productStorer) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	books := product.Product{ID: validate.GenerateUID(), Name: "Books", Cost: 50, Currency: money.USD, Quantity: 5, UserID: adminID, DateCreated: now, DateUpdated: now}
+	if err := products.Create(ctx, books); err != nil {
+		t.Fatalf("\t%s\t Should be able to create a product: %v", tests.Failed, err)
+	}
+
+	newStocktake := func(at time.Time) stocktakeStore.Stocktake {
+		t.Helper()
+
+		st := stocktakeStore.Stocktake{
+			ID:          validate.GenerateUID(),
+			WarehouseID: warehouse.MainID,
+			Status:      stocktakeStore.StatusOpen,
+			CreatedBy:   adminID,
+			DateCreated: at,
+			DateUpdated: at,
+		}
+		if err := store.Create(ctx, st); err != nil {
+			t.Fatalf("\t%s\t Should be able to open a stocktake: %v", tests.Failed, err)
+		}
+		return st
+	}
+
+	count := func(st stocktakeStore.Stocktake, counted int) error {
+		st.Lines = []stocktakeStore.Line{{ProductID: books.ID, Counted: counted}}
+		return store.Count(ctx, st)
+	}
+
+	stock := func() int {
+		t.Helper()
+
+		p, err := products.QueryByID(ctx, books.ID)
+		if err != nil {
+			t.Fatalf("\t%s\t Should be able to query a product: %v", tests.Failed, err)
+		}
+		return p.Quantity
+	}
+
+	t.Log("Given the need to count the stock of a warehouse")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When posting a stocktake short of the ledger", testID)
+		{
+			st := newStocktake(now)
+			if err := count(st, 3); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to count: %v", tests.Failed, testID, err)
+			}
+			if err := count(st, 4); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to count again: %v", tests.Failed, testID, err)
+			}
+
+			got, err := store.QueryByID(ctx, st.ID)
+			if err != nil || len(got.Lines) != 1 || got.Lines[0].Counted != 4 || got.Lines[0].Expected != nil {
+				t.Fatalf("\t%s\t Test %d Should keep the last count, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should keep the last count", tests.Succeeded, testID)
+
+			st.DateUpdated = now.Add(time.Hour)
+			if err := store.Post(ctx, st); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to post: %v", tests.Failed, testID, err)
+			}
+
+			got, err = store.QueryByID(ctx, st.ID)
+			if err != nil || got.Status != stocktakeStore.StatusPosted || got.Lines[0].Expected == nil || *got.Lines[0].Expected != 5 || *got.Lines[0].Variance != -1 {
+				t.Fatalf("\t%s\t Test %d Should record what was expected and posted, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should record what was expected and posted", tests.Succeeded, testID)
+
+			if stock() != 4 {
+				t.Fatalf("\t%s\t Test %d Should move the stock to the count, got %d", tests.Failed, testID, stock())
+			}
+			t.Logf("\t%s\t Test %d Should move the stock to the count", tests.Succeeded, testID)
+
+			if err := store.Post(ctx, st); !errors.Is(err, stocktakeStore.ErrNotOpen) {
+				t.Fatalf("\t%s\t Test %d Should refuse to post a stocktake twice, got %v", tests.Failed, testID, err)
+			}
+			if err := count(st, 9); !errors.Is(err, stocktakeStore.ErrNotOpen) {
+				t.Fatalf("\t%s\t Test %d Should refuse to count a posted stocktake, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should lock a posted stocktake", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When posting a stocktake over the ledger", testID)
+		{
+			st := newStocktake(now.Add(time.Minute))
+			if err := count(st, 6); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to count: %v", tests.Failed, testID, err)
+			}
+
+			st.DateUpdated = now.Add(2 * time.Hour)
+			if err := store.Post(ctx, st); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to post: %v", tests.Failed, testID, err)
+			}
+
+			if stock() != 6 {
+				t.Fatalf("\t%s\t Test %d Should move the stock to the count, got %d", tests.Failed, testID, stock())
+			}
+			t.Logf("\t%s\t Test %d Should move the stock to the count", tests.Succeeded, testID)
+
+			mvs, err := products.QueryMovements(ctx, product.MovementFilter{ProductID: books.ID}, 1, 10)
+			if err != nil || len(mvs) != 3 || mvs[0].Quantity != 2 || mvs[0].Reason != product.ReasonStocktake ||
+				mvs[0].ReferenceID == nil || *mvs[0].ReferenceID != st.ID || mvs[1].Quantity != -1 {
+				t.Fatalf("\t%s\t Test %d Should record the variances in the ledger, got %+v %v", tests.Failed, testID, mvs, err)
+			}
+			t.Logf("\t%s\t Test %d Should record the variances in the ledger", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When cancelling a stocktake", testID)
+		{
+			st := newStocktake(now.Add(2 * time.Minute))
+			if err := count(st, 0); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to count: %v", tests.Failed, testID, err)
+			}
+
+			if err := store.Cancel(ctx, st); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to cancel: %v", tests.Failed, testID, err)
+			}
+
+			if stock() != 6 {
+				t.Fatalf("\t%s\t Test %d Should leave the stock alone, got %d", tests.Failed, testID, stock())
+			}
+			t.Logf("\t%s\t Test %d Should leave the stock alone", tests.Succeeded, testID)
+
+			posted, err := store.Query(ctx, stocktakeStore.StatusPosted, 1, 10)
+			if err != nil || len(posted) != 2 || posted[0].DateCreated.Before(posted[1].DateCreated) {
+				t.Fatalf("\t%s\t Test %d Should list the posted stocktakes latest first, got %+v %v", tests.Failed, testID, posted, err)
+			}
+			t.Logf("\t%s\t Test %d Should list the posted stocktakes latest first", tests.Succeeded, testID)
+		}
+	}
+}
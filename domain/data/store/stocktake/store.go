@@ -0,0 +1,319 @@
+// Package stocktake persists the stocktakes of warehouses and posts their
+// variances to the inventory ledger.
+package stocktake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+)
+
+// ErrNotOpen is returned when counting, posting or cancelling a stocktake
+// that was already posted or cancelled.
+var ErrNotOpen = errors.New("stocktake is not open")
+
+// noVariant stands in for the variant of a line that has none, the lines
+// of a stocktake are unique on it like the stock levels.
+const noVariant = "00000000-0000-0000-0000-000000000000"
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create opens the stocktake.
+func (s Store) Create(ctx context.Context, st Stocktake) error {
+	q := `INSERT INTO stocktakes
+	(stocktake_id, warehouse_id, status, note, created_by, date_created, date_updated)
+	VALUES
+	(:stocktake_id, :warehouse_id, :status, :note, :created_by, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, st); err != nil {
+		return fmt.Errorf("inserting stocktake %s %w", st.ID, err)
+	}
+	return nil
+}
+
+// Count stores the lines of the stocktake, replacing what was counted
+// before for the same product and variant. The stocktake must be open.
+func (s Store) Count(ctx context.Context, st Stocktake) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touch(ctx, tx, st, StatusOpen); err != nil {
+		return err
+	}
+
+	q := `
+	INSERT INTO stocktake_lines
+		(stocktake_id, product_id, variant_id, counted)
+	VALUES
+		(:stocktake_id, :product_id, :variant_id, :counted)
+	ON CONFLICT (stocktake_id, product_id, COALESCE(variant_id, '` + noVariant + `')) DO UPDATE
+	SET counted = EXCLUDED.counted`
+
+	for _, line := range st.Lines {
+		line.StocktakeID = st.ID
+		if _, err := tx.NamedExecContext(ctx, q, line); err != nil {
+			return fmt.Errorf("inserting stocktake line %s/%s %w", st.ID, line.ProductID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// Post compares every line of the stocktake with what the ledger holds for
+// it in the warehouse, and posts the variance so the level matches the
+// count, all in one transaction. Every counted level is locked before its
+// ledger is read, so stock moved by a sale or a transfer meanwhile is not
+// lost from the variance. Levels that were not counted are left as they
+// are. Taking a variance out of a level that holds less than the ledger
+// fails with product.ErrInsufficientStock and posts nothing.
+func (s Store) Post(ctx context.Context, st Stocktake) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touch(ctx, tx, st, StatusPosted); err != nil {
+		return err
+	}
+
+	data := struct {
+		StocktakeID string `db:"stocktake_id"`
+	}{
+		StocktakeID: st.ID,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		stocktake_lines
+	WHERE
+		stocktake_id = :stocktake_id
+	ORDER BY
+		product_id, COALESCE(variant_id, '` + noVariant + `')`
+
+	rows, err := tx.NamedQuery(q, data)
+	if err != nil {
+		return fmt.Errorf("selecting stocktake lines %s %w", st.ID, err)
+	}
+
+	var lines []Line
+	for rows.Next() {
+		var line Line
+		if err := rows.StructScan(&line); err != nil {
+			rows.Close()
+			return fmt.Errorf("selecting stocktake lines %s %w", st.ID, err)
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+
+	q = `
+	UPDATE stocktake_lines
+	SET expected = :expected, variance = :variance
+	WHERE
+		stocktake_id = :stocktake_id AND product_id = :product_id AND
+		COALESCE(variant_id, '` + noVariant + `') = COALESCE(CAST(:variant_id AS UUID), '` + noVariant + `')`
+
+	for _, line := range lines {
+		m := product.StockMove{WarehouseID: st.WarehouseID, ProductID: line.ProductID, VariantID: line.VariantID}
+
+		expected, err := product.LedgerBalance(ctx, tx, m, st.DateUpdated)
+		if err != nil {
+			return err
+		}
+
+		variance := line.Counted - expected
+		switch {
+		case variance > 0:
+			m.Quantity = variance
+			err = product.PutStock(ctx, tx, st.Source(), []product.StockMove{m}, st.DateUpdated)
+		case variance < 0:
+			m.Quantity = -variance
+			err = product.TakeStock(ctx, tx, st.Source(), []product.StockMove{m}, st.DateUpdated)
+		}
+		if err != nil {
+			return err
+		}
+
+		line.Expected = &expected
+		line.Variance = &variance
+		if _, err := tx.NamedExecContext(ctx, q, line); err != nil {
+			return fmt.Errorf("updating stocktake line %s/%s %w", st.ID, line.ProductID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// Cancel closes the stocktake without posting anything.
+func (s Store) Cancel(ctx context.Context, st Stocktake) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touch(ctx, tx, st, StatusCancelled); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// touch moves the open stocktake to the status as part of tx. The status
+// is checked in the statement that changes it, so a stocktake that is no
+// longer open stays locked for good.
+func touch(ctx context.Context, tx *sqlx.Tx, st Stocktake, status string) error {
+	st.Status = status
+
+	q := `
+	UPDATE stocktakes
+	SET status = :status, date_updated = :date_updated
+	WHERE stocktake_id = :stocktake_id AND status = '` + StatusOpen + `'`
+
+	res, err := tx.NamedExecContext(ctx, q, st)
+	if err != nil {
+		return fmt.Errorf("updating stocktake %s %w", st.ID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("updating stocktake %s %w", st.ID, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("stocktake %s: %w", st.ID, ErrNotOpen)
+	}
+	return nil
+}
+
+// QueryByID returns the stocktake with the id along with its lines.
+func (s Store) QueryByID(ctx context.Context, stocktakeID string) (Stocktake, error) {
+	data := struct {
+		StocktakeID string `db:"stocktake_id"`
+	}{
+		StocktakeID: stocktakeID,
+	}
+
+	q := `SELECT * FROM stocktakes WHERE stocktake_id = :stocktake_id`
+
+	var st Stocktake
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &st); err != nil {
+		if err == database.ErrNotFound {
+			return Stocktake{}, database.ErrNotFound
+		}
+		return Stocktake{}, fmt.Errorf("selecting stocktake %s %w", stocktakeID, err)
+	}
+
+	stocktakes := []Stocktake{st}
+	if err := s.lines(ctx, stocktakes); err != nil {
+		return Stocktake{}, err
+	}
+	return stocktakes[0], nil
+}
+
+// Query returns a page of the stocktakes along with their lines, latest
+// first. Only the stocktakes in the status are returned when it is set.
+func (s Store) Query(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]Stocktake, error) {
+	data := struct {
+		Status      string `db:"status"`
+		Offset      int    `db:"offset"`
+		RowsPerPage int    `db:"rows_per_page"`
+	}{
+		Status:      status,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		stocktakes
+	WHERE
+		CAST(:status AS TEXT) = '' OR status = :status
+	ORDER BY
+		date_created DESC, stocktake_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var stocktakes []Stocktake
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &stocktakes); err != nil {
+		return nil, fmt.Errorf("selecting stocktakes %w", err)
+	}
+
+	if err := s.lines(ctx, stocktakes); err != nil {
+		return nil, err
+	}
+	return stocktakes, nil
+}
+
+// lines loads the lines of every stocktake in a single query.
+func (s Store) lines(ctx context.Context, stocktakes []Stocktake) error {
+	if len(stocktakes) == 0 {
+		return nil
+	}
+
+	ids := make(pq.StringArray, len(stocktakes))
+	for i, st := range stocktakes {
+		ids[i] = st.ID
+	}
+
+	data := struct {
+		StocktakeIDs pq.StringArray `db:"stocktake_ids"`
+	}{
+		StocktakeIDs: ids,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		stocktake_lines
+	WHERE
+		stocktake_id = ANY(CAST(:stocktake_ids AS UUID[]))
+	ORDER BY
+		stocktake_id, product_id, COALESCE(variant_id, '` + noVariant + `')`
+
+	var lines []Line
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &lines); err != nil {
+		return fmt.Errorf("selecting stocktake lines %w", err)
+	}
+
+	byStocktake := make(map[string][]Line, len(stocktakes))
+	for _, line := range lines {
+		byStocktake[line.StocktakeID] = append(byStocktake[line.StocktakeID], line)
+	}
+
+	for i := range stocktakes {
+		stocktakes[i].Lines = byStocktake[stocktakes[i].ID]
+	}
+	return nil
+}
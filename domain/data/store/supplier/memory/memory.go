@@ -0,0 +1,93 @@
+// Package memory provides a thread safe in memory implementation of the
+// supplier store with the same semantics as the postgres store.
+package memory
+
+import (
+	"context"
+	"service/domain/data/store/supplier"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+)
+
+type Store struct {
+	mu        sync.Mutex
+	suppliers map[string]supplier.Supplier
+}
+
+func NewStore() *Store {
+	return &Store{
+		suppliers: make(map[string]supplier.Supplier),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, sp supplier.Supplier) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.suppliers[sp.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+	if s.nameTaken(sp) {
+		return supplier.ErrUniqueName
+	}
+
+	s.suppliers[sp.ID] = sp
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, sp supplier.Supplier) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.suppliers[sp.ID]
+	if !ok {
+		return nil
+	}
+	if s.nameTaken(sp) {
+		return supplier.ErrUniqueName
+	}
+
+	cur.Name = sp.Name
+	cur.Email = sp.Email
+	cur.Phone = sp.Phone
+	cur.DateUpdated = sp.DateUpdated
+	s.suppliers[sp.ID] = cur
+	return nil
+}
+
+func (s *Store) Query(ctx context.Context) ([]supplier.Supplier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sps := make([]supplier.Supplier, 0, len(s.suppliers))
+	for _, sp := range s.suppliers {
+		sps = append(sps, sp)
+	}
+
+	sort.Slice(sps, func(i, j int) bool {
+		return sps[i].Name < sps[j].Name
+	})
+	return sps, nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, supplierID string) (supplier.Supplier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sp, ok := s.suppliers[supplierID]
+	if !ok {
+		return supplier.Supplier{}, database.ErrNotFound
+	}
+	return sp, nil
+}
+
+// nameTaken reports whether another supplier already has the name of sp.
+func (s *Store) nameTaken(sp supplier.Supplier) bool {
+	for _, other := range s.suppliers {
+		if other.ID != sp.ID && other.Name == sp.Name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+// Package supplier persists the suppliers stock is bought from.
+package supplier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+)
+
+// ErrUniqueName is returned when a supplier is given a name another
+// supplier already holds.
+var ErrUniqueName = errors.New("supplier name is not unique")
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create stores the supplier.
+func (s Store) Create(ctx context.Context, sp Supplier) error {
+	q := `INSERT INTO suppliers
+	(supplier_id, name, email, phone, currency, date_created, date_updated)
+	VALUES
+	(:supplier_id, :name, :email, :phone, :currency, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, sp); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueName
+		}
+		return fmt.Errorf("inserting supplier %w", err)
+	}
+	return nil
+}
+
+// Update replaces the name and contact details of the supplier.
+func (s Store) Update(ctx context.Context, sp Supplier) error {
+	q := `
+	UPDATE suppliers
+	SET name = :name, email = :email, phone = :phone, date_updated = :date_updated
+	WHERE supplier_id = :supplier_id`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, sp); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueName
+		}
+		return fmt.Errorf("updating supplier %s %w", sp.ID, err)
+	}
+	return nil
+}
+
+// Query returns every supplier ordered by name.
+func (s Store) Query(ctx context.Context) ([]Supplier, error) {
+	q := `SELECT * FROM suppliers ORDER BY name`
+
+	var sps []Supplier
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &sps); err != nil {
+		return nil, fmt.Errorf("selecting suppliers %w", err)
+	}
+	return sps, nil
+}
+
+// QueryByID returns the supplier with the id.
+func (s Store) QueryByID(ctx context.Context, supplierID string) (Supplier, error) {
+	data := struct {
+		SupplierID string `db:"supplier_id"`
+	}{
+		SupplierID: supplierID,
+	}
+
+	q := `SELECT * FROM suppliers WHERE supplier_id = :supplier_id`
+
+	var sp Supplier
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &sp); err != nil {
+		if err == database.ErrNotFound {
+			return Supplier{}, database.ErrNotFound
+		}
+		return Supplier{}, fmt.Errorf("selecting supplier %s %w", supplierID, err)
+	}
+	return sp, nil
+}
@@ -0,0 +1,34 @@
+package supplier
+
+import (
+	"service/foundation/money"
+	"time"
+)
+
+// Supplier is someone we buy stock from. Purchase orders to the supplier
+// are priced in its currency.
+type Supplier struct {
+	ID          string         `db:"supplier_id" json:"id"`
+	Name        string         `db:"name" json:"name"`
+	Email       string         `db:"email" json:"email"`
+	Phone       string         `db:"phone" json:"phone"`
+	Currency    money.Currency `db:"currency" json:"currency"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+// NewSupplier is what we require to buy from a supplier.
+type NewSupplier struct {
+	Name     string         `json:"name" validate:"required,max=100"`
+	Email    string         `json:"email" validate:"omitempty,email"`
+	Phone    string         `json:"phone" validate:"max=30"`
+	Currency money.Currency `json:"currency" validate:"required,currency"`
+}
+
+// UpdateSupplier changes the fields that are set. The currency is kept,
+// the purchase orders already sent are priced in it.
+type UpdateSupplier struct {
+	Name  *string `json:"name" validate:"omitempty,max=100"`
+	Email *string `json:"email" validate:"omitempty,email"`
+	Phone *string `json:"phone" validate:"omitempty,max=30"`
+}
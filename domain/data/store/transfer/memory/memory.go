@@ -0,0 +1,126 @@
+// Package memory provides a thread safe in memory implementation of the
+// transfer store with the same semantics as the postgres store. Stock is
+// moved in the memory product store it is given.
+package memory
+
+import (
+	"context"
+	"fmt"
+	productMemory "service/domain/data/store/product/memory"
+	"service/domain/data/store/transfer"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+)
+
+type Store struct {
+	mu        sync.Mutex
+	products  *productMemory.Store
+	transfers map[string]transfer.Transfer
+}
+
+func NewStore(products *productMemory.Store) *Store {
+	return &Store{
+		products:  products,
+		transfers: make(map[string]transfer.Transfer),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, t transfer.Transfer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.transfers[t.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+
+	if err := s.products.Take(ctx, t.Source(), t.Moves(t.FromWarehouseID), t.DateCreated); err != nil {
+		return err
+	}
+
+	s.transfers[t.ID] = clone(t)
+	return nil
+}
+
+func (s *Store) Receive(ctx context.Context, t transfer.Transfer) error {
+	return s.close(ctx, t, transfer.StatusReceived, t.ToWarehouseID)
+}
+
+func (s *Store) Cancel(ctx context.Context, t transfer.Transfer) error {
+	return s.close(ctx, t, transfer.StatusCancelled, t.FromWarehouseID)
+}
+
+func (s *Store) close(ctx context.Context, t transfer.Transfer, status string, warehouseID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.transfers[t.ID]
+	if !ok || cur.Status != transfer.StatusInTransit {
+		return fmt.Errorf("transfer %s: %w", t.ID, transfer.ErrNotInTransit)
+	}
+
+	if err := s.products.Put(ctx, cur.Source(), cur.Moves(warehouseID), t.DateUpdated); err != nil {
+		return err
+	}
+
+	cur.Status = status
+	cur.DateUpdated = t.DateUpdated
+	s.transfers[t.ID] = cur
+	return nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, transferID string) (transfer.Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.transfers[transferID]
+	if !ok {
+		return transfer.Transfer{}, database.ErrNotFound
+	}
+	return clone(t), nil
+}
+
+func (s *Store) Query(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]transfer.Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transfers []transfer.Transfer
+	for _, t := range s.transfers {
+		if status == "" || t.Status == status {
+			transfers = append(transfers, clone(t))
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		if !transfers[i].DateCreated.Equal(transfers[j].DateCreated) {
+			return transfers[i].DateCreated.After(transfers[j].DateCreated)
+		}
+		return transfers[i].ID < transfers[j].ID
+	})
+
+	start := (pageNumber - 1) * rowsPerPage
+	if start >= len(transfers) {
+		return []transfer.Transfer{}, nil
+	}
+
+	end := start + rowsPerPage
+	if end > len(transfers) {
+		end = len(transfers)
+	}
+	return transfers[start:end], nil
+}
+
+// clone makes sure callers never share the items with the stored transfer.
+func clone(t transfer.Transfer) transfer.Transfer {
+	items := make([]transfer.Item, len(t.Items))
+	for i, item := range t.Items {
+		item.TransferID = t.ID
+		if item.VariantID != nil {
+			id := *item.VariantID
+			item.VariantID = &id
+		}
+		items[i] = item
+	}
+	t.Items = items
+	return t
+}
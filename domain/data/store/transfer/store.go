@@ -0,0 +1,224 @@
+// Package transfer persists the transfers of stock between warehouses.
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+	"service/domain/data/store/product"
+	"service/domain/sys/database"
+)
+
+// ErrNotInTransit is returned when receiving or cancelling a transfer that
+// was already received or cancelled.
+var ErrNotInTransit = errors.New("transfer is not in transit")
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create ships the transfer, its items are taken out of the source
+// warehouse and the transfer is stored in one transaction. An item without
+// enough stock fails with product.ErrInsufficientStock and leaves
+// everything untouched.
+func (s Store) Create(ctx context.Context, t Transfer) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := product.TakeStock(ctx, tx, t.Source(), t.Moves(t.FromWarehouseID), t.DateCreated); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO transfers
+	(transfer_id, from_warehouse_id, to_warehouse_id, status, note, created_by, date_created, date_updated)
+	VALUES
+	(:transfer_id, :from_warehouse_id, :to_warehouse_id, :status, :note, :created_by, :date_created, :date_updated)`
+
+	if _, err := tx.NamedExecContext(ctx, q, t); err != nil {
+		return fmt.Errorf("inserting transfer %s %w", t.ID, err)
+	}
+
+	q = `INSERT INTO transfer_items
+	(transfer_id, line, product_id, variant_id, quantity)
+	VALUES
+	(:transfer_id, :line, :product_id, :variant_id, :quantity)`
+
+	for _, item := range t.Items {
+		item.TransferID = t.ID
+		if _, err := tx.NamedExecContext(ctx, q, item); err != nil {
+			return fmt.Errorf("inserting transfer item %s/%d %w", t.ID, item.Line, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// Receive puts the items of the transfer into the destination warehouse.
+func (s Store) Receive(ctx context.Context, t Transfer) error {
+	return s.close(ctx, t, StatusReceived, t.ToWarehouseID)
+}
+
+// Cancel puts the items of the transfer back into the source warehouse.
+func (s Store) Cancel(ctx context.Context, t Transfer) error {
+	return s.close(ctx, t, StatusCancelled, t.FromWarehouseID)
+}
+
+// close moves the transfer out of transit and puts its items into the
+// warehouse in one transaction. The status is checked in the statement
+// that changes it, a transfer can not be closed twice.
+func (s Store) close(ctx context.Context, t Transfer, status string, warehouseID string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	t.Status = status
+
+	q := `
+	UPDATE transfers
+	SET status = :status, date_updated = :date_updated
+	WHERE transfer_id = :transfer_id AND status = '` + StatusInTransit + `'`
+
+	res, err := tx.NamedExecContext(ctx, q, t)
+	if err != nil {
+		return fmt.Errorf("updating transfer %s %w", t.ID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("updating transfer %s %w", t.ID, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("transfer %s: %w", t.ID, ErrNotInTransit)
+	}
+
+	if err := product.PutStock(ctx, tx, t.Source(), t.Moves(warehouseID), t.DateUpdated); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// QueryByID returns the transfer with the id along with its items.
+func (s Store) QueryByID(ctx context.Context, transferID string) (Transfer, error) {
+	data := struct {
+		TransferID string `db:"transfer_id"`
+	}{
+		TransferID: transferID,
+	}
+
+	q := `SELECT * FROM transfers WHERE transfer_id = :transfer_id`
+
+	var t Transfer
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &t); err != nil {
+		if err == database.ErrNotFound {
+			return Transfer{}, database.ErrNotFound
+		}
+		return Transfer{}, fmt.Errorf("selecting transfer %s %w", transferID, err)
+	}
+
+	transfers := []Transfer{t}
+	if err := s.items(ctx, transfers); err != nil {
+		return Transfer{}, err
+	}
+	return transfers[0], nil
+}
+
+// Query returns a page of the transfers along with their items, latest
+// first. Only the transfers in the status are returned when it is set.
+func (s Store) Query(ctx context.Context, status string, pageNumber int, rowsPerPage int) ([]Transfer, error) {
+	data := struct {
+		Status      string `db:"status"`
+		Offset      int    `db:"offset"`
+		RowsPerPage int    `db:"rows_per_page"`
+	}{
+		Status:      status,
+		Offset:      (pageNumber - 1) * rowsPerPage,
+		RowsPerPage: rowsPerPage,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		transfers
+	WHERE
+		CAST(:status AS TEXT) = '' OR status = :status
+	ORDER BY
+		date_created DESC, transfer_id
+	OFFSET :offset ROWS FETCH NEXT :rows_per_page ROWS ONLY`
+
+	var transfers []Transfer
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &transfers); err != nil {
+		return nil, fmt.Errorf("selecting transfers %w", err)
+	}
+
+	if err := s.items(ctx, transfers); err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// items loads the items of every transfer in a single query.
+func (s Store) items(ctx context.Context, transfers []Transfer) error {
+	if len(transfers) == 0 {
+		return nil
+	}
+
+	ids := make(pq.StringArray, len(transfers))
+	for i, t := range transfers {
+		ids[i] = t.ID
+	}
+
+	data := struct {
+		TransferIDs pq.StringArray `db:"transfer_ids"`
+	}{
+		TransferIDs: ids,
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		transfer_items
+	WHERE
+		transfer_id = ANY(CAST(:transfer_ids AS UUID[]))
+	ORDER BY
+		transfer_id, line`
+
+	var items []Item
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, data, &items); err != nil {
+		return fmt.Errorf("selecting transfer items %w", err)
+	}
+
+	byTransfer := make(map[string][]Item, len(transfers))
+	for _, item := range items {
+		byTransfer[item.TransferID] = append(byTransfer[item.TransferID], item)
+	}
+
+	for i := range transfers {
+		transfers[i].Items = byTransfer[transfers[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package transfer
+
+import (
+	"service/domain/data/store/product"
+	"time"
+)
+
+// Set of states a transfer goes through. A transfer is in transit from the
+// moment it is shipped, the stock is in neither warehouse until it is
+// received at the destination or cancelled back into the source.
+const (
+	StatusInTransit = "in_transit"
+	StatusReceived  = "received"
+	StatusCancelled = "cancelled"
+)
+
+// Transfer moves stock from one warehouse to another. CreatedBy is whoever
+// shipped it.
+type Transfer struct {
+	ID              string    `db:"transfer_id" json:"id"`
+	FromWarehouseID string    `db:"from_warehouse_id" json:"from_warehouse_id"`
+	ToWarehouseID   string    `db:"to_warehouse_id" json:"to_warehouse_id"`
+	Status          string    `db:"status" json:"status"`
+	Note            string    `db:"note" json:"note"`
+	CreatedBy       string    `db:"created_by" json:"created_by"`
+	Items           []Item    `db:"-" json:"items"`
+	DateCreated     time.Time `db:"date_created" json:"date_created"`
+	DateUpdated     time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// Moves returns the stock of the items going in or out of the warehouse.
+func (t Transfer) Moves(warehouseID string) []product.StockMove {
+	moves := make([]product.StockMove, len(t.Items))
+	for i, item := range t.Items {
+		moves[i] = product.StockMove{
+			WarehouseID: warehouseID,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			Quantity:    item.Quantity,
+		}
+	}
+	return moves
+}
+
+// Source returns how the stock moved by the transfer is recorded in the
+// ledger.
+func (t Transfer) Source() product.Source {
+	return product.Source{Kind: product.KindTransfer, ReferenceID: t.ID, CreatedBy: t.CreatedBy}
+}
+
+// Item is a line of a transfer, a quantity of a product or of one of its
+// variants.
+type Item struct {
+	TransferID string  `db:"transfer_id" json:"-"`
+	Line       int     `db:"line" json:"line"`
+	ProductID  string  `db:"product_id" json:"product_id"`
+	VariantID  *string `db:"variant_id" json:"variant_id"`
+	Quantity   int     `db:"quantity" json:"quantity"`
+}
+
+// NewTransfer is what we require to ship stock between two warehouses.
+type NewTransfer struct {
+	FromWarehouseID string    `json:"from_warehouse_id" validate:"required,uuid"`
+	ToWarehouseID   string    `json:"to_warehouse_id" validate:"required,uuid,nefield=FromWarehouseID"`
+	Note            string    `json:"note" validate:"max=500"`
+	Items           []NewItem `json:"items" validate:"required,min=1,dive"`
+}
+
+// NewItem is a product, or one of its variants, and how many of it are
+// shipped.
+type NewItem struct {
+	ProductID string  `json:"product_id" validate:"required,uuid"`
+	VariantID *string `json:"variant_id" validate:"omitempty,uuid"`
+	Quantity  int     `json:"quantity" validate:"required,gte=1"`
+}
@@ -0,0 +1,177 @@
+package transfer_test
+
+import (
+	"context"
+	"errors"
+	"service/domain/core/transfer"
+	"service/domain/core/warehouse"
+	"service/domain/data/store/product"
+	productMemory "service/domain/data/store/product/memory"
+	transferStore "service/domain/data/store/transfer"
+	"service/domain/data/store/transfer/memory"
+	warehouseStore "service/domain/data/store/warehouse"
+	warehouseMemory "service/domain/data/store/warehouse/memory"
+	"service/domain/data/tests"
+	"service/domain/sys/validate"
+	"service/foundation/money"
+	"testing"
+	"time"
+)
+
+var dbContainer = tests.DBContainer{
+	Image: "postgres:14-alpine",
+	Port:  "5432",
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+// adminID is the seeded admin, who owns the products and ships the
+// transfers.
+const adminID = "5cf37266-3473-4006-984f-9325122678b7"
+
+type productStorer interface {
+	Create(ctx context.Context, p product.Product) error
+	QueryByID(ctx context.Context, productID string) (product.Product, error)
+	QueryLevels(ctx context.Context, productID string) ([]product.Level, error)
+}
+
+func TestMemory(t *testing.T) {
+	products := productMemory.NewStore()
+	transfers(t, memory.NewStore(products), warehouseMemory.NewStore(products), products)
+}
+
+func TestPostgres(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	transfers(t, transferStore.NewStore(logger, db), warehouseStore.NewStore(logger, db), product.NewStore(logger, db))
+}
+
+func transfers(t *testing.T, store transfer.Storer, warehouses warehouse.Storer, products productStorer) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	books := product.Product{ID: validate.GenerateUID(), Name: "Books", Cost: 50, Currency: money.USD, Quantity: 5, UserID: adminID, DateCreated: now, DateUpdated: now}
+	if err := products.Create(ctx, books); err != nil {
+		t.Fatalf("\t%s\t Should be able to create a product: %v", tests.Failed, err)
+	}
+
+	north := warehouseStore.Warehouse{ID: validate.GenerateUID(), Code: "NORTH-" + books.ID[:8], Name: "North", DateCreated: now, DateUpdated: now}
+	if err := warehouses.Create(ctx, north); err != nil {
+		t.Fatalf("\t%s\t Should be able to create a warehouse: %v", tests.Failed, err)
+	}
+
+	newTransfer := func(qty int) transferStore.Transfer {
+		tr := transferStore.Transfer{
+			ID:              validate.GenerateUID(),
+			FromWarehouseID: warehouseStore.MainID,
+			ToWarehouseID:   north.ID,
+			Status:          transferStore.StatusInTransit,
+			CreatedBy:       adminID,
+			DateCreated:     now,
+			DateUpdated:     now,
+		}
+		tr.Items = []transferStore.Item{
+			{TransferID: tr.ID, Line: 1, ProductID: books.ID, Quantity: qty},
+		}
+		return tr
+	}
+
+	// levels returns how many books the main and the north warehouses hold.
+	levels := func() (int, int) {
+		t.Helper()
+
+		ls, err := products.QueryLevels(ctx, books.ID)
+		if err != nil {
+			t.Fatalf("\t%s\t Should be able to query the levels: %v", tests.Failed, err)
+		}
+
+		var main, other int
+		for _, l := range ls {
+			switch l.WarehouseID {
+			case warehouseStore.MainID:
+				main = l.Quantity
+			case north.ID:
+				other = l.Quantity
+			}
+		}
+		return main, other
+	}
+
+	stock := func() int {
+		t.Helper()
+
+		p, err := products.QueryByID(ctx, books.ID)
+		if err != nil {
+			t.Fatalf("\t%s\t Should be able to query a product: %v", tests.Failed, err)
+		}
+		return p.Quantity
+	}
+
+	t.Log("Given the need to move stock between warehouses")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When shipping a transfer", testID)
+		{
+			shipped := newTransfer(3)
+			if err := store.Create(ctx, shipped); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to ship a transfer: %v", tests.Failed, testID, err)
+			}
+
+			if main, other := levels(); main != 2 || other != 0 || stock() != 2 {
+				t.Fatalf("\t%s\t Test %d Should hold the stock in transit, got %d %d %d", tests.Failed, testID, main, other, stock())
+			}
+			t.Logf("\t%s\t Test %d Should hold the stock in transit", tests.Succeeded, testID)
+
+			if err := store.Create(ctx, newTransfer(3)); !errors.Is(err, product.ErrInsufficientStock) {
+				t.Fatalf("\t%s\t Test %d Should refuse to ship more than the source holds, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse to ship more than the source holds", tests.Succeeded, testID)
+
+			shipped.DateUpdated = now.Add(time.Hour)
+			if err := store.Receive(ctx, shipped); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to receive a transfer: %v", tests.Failed, testID, err)
+			}
+
+			if main, other := levels(); main != 2 || other != 3 || stock() != 5 {
+				t.Fatalf("\t%s\t Test %d Should put the stock into the destination, got %d %d %d", tests.Failed, testID, main, other, stock())
+			}
+			t.Logf("\t%s\t Test %d Should put the stock into the destination", tests.Succeeded, testID)
+
+			if err := store.Cancel(ctx, shipped); !errors.Is(err, transferStore.ErrNotInTransit) {
+				t.Fatalf("\t%s\t Test %d Should refuse to close a transfer twice, got %v", tests.Failed, testID, err)
+			}
+			t.Logf("\t%s\t Test %d Should refuse to close a transfer twice", tests.Succeeded, testID)
+
+			got, err := store.QueryByID(ctx, shipped.ID)
+			if err != nil || got.Status != transferStore.StatusReceived || len(got.Items) != 1 || got.Items[0].Quantity != 3 {
+				t.Fatalf("\t%s\t Test %d Should read back the transfer, got %+v %v", tests.Failed, testID, got, err)
+			}
+			t.Logf("\t%s\t Test %d Should read back the transfer", tests.Succeeded, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When cancelling a transfer", testID)
+		{
+			shipped := newTransfer(2)
+			shipped.DateCreated = now.Add(time.Minute)
+			if err := store.Create(ctx, shipped); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to ship a transfer: %v", tests.Failed, testID, err)
+			}
+
+			inTransit, err := store.Query(ctx, transferStore.StatusInTransit, 1, 10)
+			if err != nil || len(inTransit) != 1 || inTransit[0].ID != shipped.ID {
+				t.Fatalf("\t%s\t Test %d Should list the transfers in transit, got %+v %v", tests.Failed, testID, inTransit, err)
+			}
+			t.Logf("\t%s\t Test %d Should list the transfers in transit", tests.Succeeded, testID)
+
+			if err := store.Cancel(ctx, shipped); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to cancel a transfer: %v", tests.Failed, testID, err)
+			}
+
+			if main, other := levels(); main != 2 || other != 3 || stock() != 5 {
+				t.Fatalf("\t%s\t Test %d Should put the stock back into the source, got %d %d %d", tests.Failed, testID, main, other, stock())
+			}
+			t.Logf("\t%s\t Test %d Should put the stock back into the source", tests.Succeeded, testID)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package memory provides a thread safe in memory implementation of the user
+// store with the same semantics as the postgres store. It is meant for tests
+// and local development where running postgres is not an option.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+	"service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu    sync.RWMutex
+	users map[string]user.User
+}
+
+func NewStore() *Store {
+	return &Store{
+		users: make(map[string]user.User),
+	}
+}
+
+func (s *Store) Create(ctx context.Context, nu user.NewUser, now time.Time) (user.User, error) {
+	if err := validate.Check(nu); err != nil {
+		return user.User{}, err
+	}
+
+	hashPass, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return user.User{}, fmt.Errorf("generate hash %w", err)
+	}
+
+	usr := user.User{
+		ID:           validate.GenerateUID(),
+		Name:         nu.Name,
+		Email:        nu.Email,
+		PasswordHash: hashPass,
+		Roles:        nu.Roles,
+		Enabled:      true,
+		DateCreated:  now,
+		DateUpdated:  now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(usr.Email, usr.ID) {
+		return user.User{}, user.ErrUniqueEmail
+	}
+
+	s.users[usr.ID] = clone(usr)
+	return usr, nil
+}
+
+func (s *Store) Update(ctx context.Context, claims auth.Claims, userID string, uu user.UpdateUser, now time.Time) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	if err := validate.Check(uu); err != nil {
+		return err
+	}
+
+	usr, err := s.QueryByID(ctx, claims, userID)
+	if err != nil {
+		return fmt.Errorf("inserting user %s - %w", userID, err)
+	}
+
+	if uu.Name != nil {
+		usr.Name = *uu.Name
+	}
+
+	if uu.Email != nil {
+		usr.Email = *uu.Email
+	}
+
+	if uu.Roles != nil {
+		usr.Roles = uu.Roles
+	}
+
+	if uu.Enabled != nil {
+		usr.Enabled = *uu.Enabled
+	}
+
+	if uu.Password != nil {
+		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("generating password hash - %w", err)
+		}
+		usr.PasswordHash = pw
+	}
+	usr.DateUpdated = now
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The user may have been deleted while we were not holding the lock,
+	// postgres would update zero rows.
+	if _, ok := s.users[userID]; !ok {
+		return nil
+	}
+
+	if s.emailTaken(usr.Email, usr.ID) {
+		return user.ErrUniqueEmail
+	}
+
+	s.users[userID] = clone(usr)
+	return nil
+}
+
+func (s *Store) Delete(ctx context.Context, claims auth.Claims, userID string) error {
+	if err := validate.CheckID(userID); err != nil {
+		return database.ErrInvalidID
+	}
+
+	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
+		return database.ErrForbidden
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, userID)
+	return nil
+}
+
+func (s *Store) Query(ctx context.Context, pageNumber int, rowsPerPage int) ([]user.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.users))
+	for id := range s.users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	offset := (pageNumber - 1) * rowsPerPage
+	if offset < 0 || rowsPerPage < 0 {
+		return nil, fmt.Errorf("selecting user: invalid page %d rows %d", pageNumber, rowsPerPage)
+	}
+
+	var users []user.User
+	for i := offset; i < len(ids) && i < offset+rowsPerPage; i++ {
+		users = append(users, clone(s.users[ids[i]]))
+	}
+	return users, nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, claims auth.Claims, userID string) (user.User, error) {
+	if err := validate.CheckID(userID); err != nil {
+		return user.User{}, database.ErrInvalidID
+	}
+
+	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != userID {
+		return user.User{}, database.ErrForbidden
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usr, ok := s.users[userID]
+	if !ok {
+		return user.User{}, fmt.Errorf("selecting user %s - %w", userID, database.ErrNotFound)
+	}
+	return clone(usr), nil
+}
+
+func (s *Store) QueryByEmail(ctx context.Context, claims auth.Claims, email string) (user.User, error) {
+	usr, ok := s.byEmail(email)
+	if !ok {
+		return user.User{}, fmt.Errorf("selecting user by email %s - %w", email, database.ErrNotFound)
+	}
+
+	if !claims.Authorized(auth.RoleAdmin) && claims.Subject != usr.ID {
+		return user.User{}, database.ErrForbidden
+	}
+
+	return usr, nil
+}
+
+func (s *Store) Authenticate(ctx context.Context, now time.Time, email, password string) (auth.Claims, error) {
+	usr, ok := s.byEmail(email)
+	if !ok {
+		user.CompareDummy(password)
+		return auth.Claims{}, database.ErrNotFound
+	}
+
+	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
+		return auth.Claims{}, database.ErrAuthenticationFailure
+	}
+
+	if !usr.Enabled {
+		return auth.Claims{}, database.ErrAuthenticationFailure
+	}
+
+	// The issuer and the lifetime of the token are left to auth.
+	claims := auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Subject:  usr.ID,
+			IssuedAt: now.Unix(),
+		},
+		Roles: usr.Roles,
+	}
+	return claims, nil
+}
+
+func (s *Store) byEmail(email string) (user.User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, usr := range s.users {
+		if usr.Email == email {
+			return clone(usr), true
+		}
+	}
+	return user.User{}, false
+}
+
+// emailTaken must be called with the lock held.
+func (s *Store) emailTaken(email string, exceptID string) bool {
+	for id, usr := range s.users {
+		if id != exceptID && usr.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+// clone makes sure callers never share slices with the stored user.
+func clone(usr user.User) user.User {
+	usr.Roles = append([]string(nil), usr.Roles...)
+	usr.PasswordHash = append([]byte(nil), usr.PasswordHash...)
+	return usr
+}
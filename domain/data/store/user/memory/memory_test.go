@@ -0,0 +1,11 @@
+package memory_test
+
+import (
+	"service/domain/data/store/user/memory"
+	"service/domain/data/store/user/usertest"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	usertest.Contract(t, memory.NewStore())
+}
@@ -0,0 +1,21 @@
+package user
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"sync"
+)
+
+var (
+	dummyOnce sync.Once
+	dummyHash []byte
+)
+
+// CompareDummy spends the same time checking password as checking it
+// against a real hash would. Stores call it for unknown emails so the
+// response time does not tell which emails have an account.
+func CompareDummy(password string) {
+	dummyOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
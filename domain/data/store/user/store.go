@@ -2,6 +2,7 @@ package user
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/jmoiron/sqlx"
@@ -13,6 +14,10 @@ import (
 	"time"
 )
 
+// ErrUniqueEmail is returned when a user is created or updated with an email
+// another user already holds.
+var ErrUniqueEmail = errors.New("email is not unique")
+
 type Store struct {
 	logger *zap.SugaredLogger
 	db     *sqlx.DB
@@ -40,16 +45,20 @@ func (s Store) Create(ctx context.Context, nu NewUser, now time.Time) (User, err
 		Email:        nu.Email,
 		PasswordHash: hashPass,
 		Roles:        nu.Roles,
+		Enabled:      true,
 		DateCreated:  now,
 		DateUpdated:  now,
 	}
 
 	q := `INSERT INTO users
-	(user_id, name, email, password_hash, roles, date_created, date_updated)
+	(user_id, name, email, password_hash, roles, enabled, date_created, date_updated)
 	VALUES
-	(:user_id, :name, :email, :password_hash, :roles, :date_created, :date_updated)`
+	(:user_id, :name, :email, :password_hash, :roles, :enabled, :date_created, :date_updated)`
 
 	if err := database.NamedExecContext(ctx, s.logger, s.db, q, usr); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return User{}, ErrUniqueEmail
+		}
 		return User{}, fmt.Errorf("inserting user %w", err)
 	}
 
@@ -62,6 +71,10 @@ func (s Store) Update(ctx context.Context, claims auth.Claims, userID string, uu
 		return database.ErrInvalidID
 	}
 
+	if err := validate.Check(uu); err != nil {
+		return err
+	}
+
 	usr, err := s.QueryByID(ctx, claims, userID)
 	if err != nil {
 		return fmt.Errorf("inserting user %s - %w", userID, err)
@@ -79,8 +92,12 @@ func (s Store) Update(ctx context.Context, claims auth.Claims, userID string, uu
 		usr.Roles = uu.Roles
 	}
 
+	if uu.Enabled != nil {
+		usr.Enabled = *uu.Enabled
+	}
+
 	if uu.Password != nil {
-		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.PasswordConfirm), bcrypt.DefaultCost)
+		pw, err := bcrypt.GenerateFromPassword([]byte(*uu.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return fmt.Errorf("generating password hash - %w", err)
 		}
@@ -91,15 +108,19 @@ func (s Store) Update(ctx context.Context, claims auth.Claims, userID string, uu
 	q := `UPDATE 
 		users 
 	SET
-		"name" = :name
-		"email" = :email 
-		"roles" = :roles
-		"password_hash" = :password_hash
-		"date_updated"" = :date_updated
+		"name" = :name,
+		"email" = :email,
+		"roles" = :roles,
+		"password_hash" = :password_hash,
+		"enabled" = :enabled,
+		"date_updated" = :date_updated
 	WHERE
 		user_id = :user_id`
 
 	if err := database.NamedExecContext(ctx, s.logger, s.db, q, usr); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueEmail
+		}
 		return fmt.Errorf("updating user %w", err)
 	}
 
@@ -240,21 +261,27 @@ func (s Store) Authenticate(ctx context.Context, now time.Time, email, password
 	var usr User
 	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &usr); err != nil {
 		if err == database.ErrNotFound {
+			CompareDummy(password)
 			return auth.Claims{}, database.ErrNotFound
 		}
 		return auth.Claims{}, fmt.Errorf("selecting user %q %w", email, err)
 	}
 
+	// The password is checked first so disabled accounts take as long to
+	// refuse as any other.
 	if err := bcrypt.CompareHashAndPassword(usr.PasswordHash, []byte(password)); err != nil {
-		return auth.Claims{}, err
+		return auth.Claims{}, database.ErrAuthenticationFailure
+	}
+
+	if !usr.Enabled {
+		return auth.Claims{}, database.ErrAuthenticationFailure
 	}
 
+	// The issuer and the lifetime of the token are left to auth.
 	claims := auth.Claims{
 		StandardClaims: jwt.StandardClaims{
-			Issuer:    "service project",
-			Subject:   usr.ID,
-			ExpiresAt: time.Now().Add(time.Hour).Unix(),
-			IssuedAt:  time.Now().UTC().Unix(),
+			Subject:  usr.ID,
+			IssuedAt: now.Unix(),
 		},
 		Roles: usr.Roles,
 	}
@@ -11,6 +11,7 @@ type User struct {
 	Email        string         `db:"email" json:"email"`
 	Roles        pq.StringArray `db:"roles" json:"roles"`
 	PasswordHash []byte         `db:"password_hash" json:"-"`
+	Enabled      bool           `db:"enabled" json:"enabled"`
 	DateCreated  time.Time      `db:"date_created" json:"date_created"`
 	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
 }
@@ -31,4 +32,21 @@ type UpdateUser struct {
 	Roles           []string `json:"roles"`
 	Password        *string  `json:"password"`
 	PasswordConfirm *string  `json:"password_confirm" validate:"omitempty,eqfield=Password"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+// UpdateProfile is what users may change about themselves. Roles and the
+// enabled flag are left out on purpose so nobody can escalate their own
+// privileges.
+type UpdateProfile struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email" validate:"omitempty,email"`
+}
+
+// ChangePassword replaces the password of the calling user, the current
+// password has to be provided again.
+type ChangePassword struct {
+	Current         string `json:"current_password" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
 }
@@ -1,10 +1,12 @@
-package user
+package user_test
 
 import (
 	"context"
 	"errors"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/go-cmp/cmp"
+	"service/domain/data/store/user"
+	"service/domain/data/store/user/usertest"
 	"service/domain/data/tests"
 	"service/domain/sys/auth"
 	"service/domain/sys/database"
@@ -15,7 +17,15 @@ import (
 var dbContainer = tests.DBContainer{
 	Image: "postgres:14-alpine",
 	Port:  "5432",
-	Args:  []string{"-e", "POSTGRES_PASS=postgres"},
+	Args:  []string{"-e", "POSTGRES_PASSWORD=postgres"},
+}
+
+// TestContract runs the shared store contract against postgres.
+func TestContract(t *testing.T) {
+	logger, db, fn := tests.NewUnit(t, dbContainer)
+	t.Cleanup(fn)
+
+	usertest.Contract(t, user.NewStore(logger, db))
 }
 
 //TestUser After Create query it to make sure it exists !
@@ -25,7 +35,7 @@ func TestUser(t *testing.T) {
 	logger, db, fn := tests.NewUnit(t, dbContainer)
 	t.Cleanup(fn)
 
-	store := NewStore(logger, db)
+	store := user.NewStore(logger, db)
 
 	t.Log("Given the need to work with user records")
 	{
@@ -35,7 +45,7 @@ func TestUser(t *testing.T) {
 			ctx := context.Background()
 			now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
 
-			nu := NewUser{
+			nu := user.NewUser{
 				Name:            "Omid h",
 				Email:           "omid.hosseini777@gmail.com",
 				Roles:           []string{auth.RoleAdmin},
@@ -71,7 +81,7 @@ func TestUser(t *testing.T) {
 			}
 			t.Logf("\t%s\t Test %d Should be able to match user %s", tests.Succeeded, testID, diff)
 
-			upd := UpdateUser{
+			upd := user.UpdateUser{
 				Name:  tests.StringPointer("nika"),
 				Email: tests.StringPointer("stalkeromid2142@gmail.com"),
 				Roles: []string{auth.RoleUser},
@@ -106,7 +116,7 @@ func TestUser(t *testing.T) {
 				t.Logf("\t\t Test %d Expected %s", testID, *upd.Name)
 				t.Logf("\t\t Test %d Got %s", testID, usrByMail.Name)
 			} else {
-				t.Errorf("\t%s\t Test %d :\t should be able to see updates to Name", tests.Succeeded, testID)
+				t.Logf("\t%s\t Test %d :\t should be able to see updates to Name", tests.Succeeded, testID)
 			}
 
 			if usrByMail.Email != *upd.Email {
@@ -114,7 +124,7 @@ func TestUser(t *testing.T) {
 				t.Logf("\t\t Test %d Expected %s", testID, *upd.Email)
 				t.Logf("\t\t Test %d Got %s", testID, usrByMail.Email)
 			} else {
-				t.Errorf("\t%s\t Test %d :\t should be able to see updates to Email", tests.Succeeded, testID)
+				t.Logf("\t%s\t Test %d :\t should be able to see updates to Email", tests.Succeeded, testID)
 			}
 
 			err = store.Delete(ctx, claims, usr.ID)
@@ -0,0 +1,225 @@
+// Package usertest holds the contract every user store must honour. The
+// postgres and memory stores run the same suite so they can not drift apart.
+package usertest
+
+import (
+	"context"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-cmp/cmp"
+	userCore "service/domain/core/user"
+	"service/domain/data/store/user"
+	"service/domain/data/tests"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/domain/sys/validate"
+	"testing"
+	"time"
+)
+
+// Contract runs the behavior shared by every user store against storer.
+// The store may already hold other users, none of the checks depend on
+// its size.
+func Contract(t *testing.T, storer userCore.Storer) {
+	ctx := context.Background()
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need for every user store to behave the same")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When creating users", testID)
+
+		nu := user.NewUser{
+			Name:            "Contract Gopher",
+			Email:           "contract-" + validate.GenerateUID() + "@example.com",
+			Roles:           []string{auth.RoleUser},
+			Password:        "gophers",
+			PasswordConfirm: "gophers",
+		}
+
+		usr, err := storer.Create(ctx, nu, now)
+		if err != nil {
+			t.Fatalf("\t%s\t Test %d Should be able to create a user: %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should be able to create a user", tests.Succeeded, testID)
+
+		if !usr.Enabled {
+			t.Fatalf("\t%s\t Test %d Should create enabled users", tests.Failed, testID)
+		}
+		t.Logf("\t%s\t Test %d Should create enabled users", tests.Succeeded, testID)
+
+		if _, err := storer.Create(ctx, nu, now); !errors.Is(err, user.ErrUniqueEmail) {
+			t.Fatalf("\t%s\t Test %d Should reject a duplicated email, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should reject a duplicated email", tests.Succeeded, testID)
+
+		invalid := nu
+		invalid.PasswordConfirm = "something else"
+		if _, err := storer.Create(ctx, invalid, now); !errors.As(err, new(validate.FieldErrors)) {
+			t.Fatalf("\t%s\t Test %d Should reject an invalid user, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should reject an invalid user", tests.Succeeded, testID)
+
+		testID++
+		t.Logf("\t Test %d \t When querying users", testID)
+
+		self := claimsFor(usr.ID, auth.RoleUser)
+		other := claimsFor(validate.GenerateUID(), auth.RoleUser)
+		admin := claimsFor(validate.GenerateUID(), auth.RoleAdmin)
+
+		saved, err := storer.QueryByID(ctx, self, usr.ID)
+		if err != nil {
+			t.Fatalf("\t%s\t Test %d Should be able to retrieve the user: %v", tests.Failed, testID, err)
+		}
+		if diff := cmp.Diff(usr, saved); diff != "" {
+			t.Fatalf("\t%s\t Test %d Should get back the same user:\n%s", tests.Failed, testID, diff)
+		}
+		t.Logf("\t%s\t Test %d Should get back the same user", tests.Succeeded, testID)
+
+		if _, err := storer.QueryByID(ctx, other, usr.ID); !errors.Is(err, database.ErrForbidden) {
+			t.Fatalf("\t%s\t Test %d Should forbid other users, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should forbid other users", tests.Succeeded, testID)
+
+		if _, err := storer.QueryByID(ctx, admin, "not-a-uuid"); !errors.Is(err, database.ErrInvalidID) {
+			t.Fatalf("\t%s\t Test %d Should reject an invalid id, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should reject an invalid id", tests.Succeeded, testID)
+
+		if _, err := storer.QueryByID(ctx, admin, validate.GenerateUID()); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("\t%s\t Test %d Should not find an unknown id, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should not find an unknown id", tests.Succeeded, testID)
+
+		if _, err := storer.QueryByEmail(ctx, other, usr.Email); !errors.Is(err, database.ErrForbidden) {
+			t.Fatalf("\t%s\t Test %d Should forbid other users by email, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should forbid other users by email", tests.Succeeded, testID)
+
+		users, err := storer.Query(ctx, 1, 1000)
+		if err != nil {
+			t.Fatalf("\t%s\t Test %d Should be able to query a page: %v", tests.Failed, testID, err)
+		}
+		if !contains(users, usr.ID) {
+			t.Fatalf("\t%s\t Test %d Should find the user in the page", tests.Failed, testID)
+		}
+		t.Logf("\t%s\t Test %d Should find the user in the page", tests.Succeeded, testID)
+
+		testID++
+		t.Logf("\t Test %d \t When updating users", testID)
+
+		email := "updated-" + validate.GenerateUID() + "@example.com"
+		name := "Updated Gopher"
+		upd := user.UpdateUser{
+			Name:  &name,
+			Email: &email,
+		}
+
+		if err := storer.Update(ctx, other, usr.ID, upd, now); !errors.Is(err, database.ErrForbidden) {
+			t.Fatalf("\t%s\t Test %d Should forbid other users to update, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should forbid other users to update", tests.Succeeded, testID)
+
+		if err := storer.Update(ctx, admin, usr.ID, upd, now); err != nil {
+			t.Fatalf("\t%s\t Test %d Should be able to update the user: %v", tests.Failed, testID, err)
+		}
+
+		byEmail, err := storer.QueryByEmail(ctx, admin, email)
+		if err != nil {
+			t.Fatalf("\t%s\t Test %d Should find the user by its new email: %v", tests.Failed, testID, err)
+		}
+		if byEmail.Name != name {
+			t.Fatalf("\t%s\t Test %d Should see the new name, got %q", tests.Failed, testID, byEmail.Name)
+		}
+		t.Logf("\t%s\t Test %d Should see the update", tests.Succeeded, testID)
+
+		second, err := storer.Create(ctx, user.NewUser{
+			Name:            "Second Gopher",
+			Email:           "second-" + validate.GenerateUID() + "@example.com",
+			Roles:           []string{auth.RoleUser},
+			Password:        "gophers",
+			PasswordConfirm: "gophers",
+		}, now)
+		if err != nil {
+			t.Fatalf("\t%s\t Test %d Should be able to create a second user: %v", tests.Failed, testID, err)
+		}
+
+		clash := user.UpdateUser{Email: &email}
+		if err := storer.Update(ctx, admin, second.ID, clash, now); !errors.Is(err, user.ErrUniqueEmail) {
+			t.Fatalf("\t%s\t Test %d Should reject taking another user's email, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should reject taking another user's email", tests.Succeeded, testID)
+
+		testID++
+		t.Logf("\t Test %d \t When authenticating users", testID)
+
+		claims, err := storer.Authenticate(ctx, now, email, "gophers")
+		if err != nil {
+			t.Fatalf("\t%s\t Test %d Should authenticate with the right password: %v", tests.Failed, testID, err)
+		}
+		if claims.Subject != usr.ID {
+			t.Fatalf("\t%s\t Test %d Should issue claims for the user, got subject %q", tests.Failed, testID, claims.Subject)
+		}
+		t.Logf("\t%s\t Test %d Should authenticate with the right password", tests.Succeeded, testID)
+
+		if _, err := storer.Authenticate(ctx, now, email, "wrong"); !errors.Is(err, database.ErrAuthenticationFailure) {
+			t.Fatalf("\t%s\t Test %d Should not authenticate with a wrong password, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should not authenticate with a wrong password", tests.Succeeded, testID)
+
+		if _, err := storer.Authenticate(ctx, now, "nobody-"+validate.GenerateUID()+"@example.com", "gophers"); !errors.Is(err, database.ErrNotFound) {
+			t.Fatalf("\t%s\t Test %d Should not find an unknown email, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should not find an unknown email", tests.Succeeded, testID)
+
+		disabled := false
+		if err := storer.Update(ctx, admin, usr.ID, user.UpdateUser{Enabled: &disabled}, now); err != nil {
+			t.Fatalf("\t%s\t Test %d Should be able to disable the user: %v", tests.Failed, testID, err)
+		}
+
+		if _, err := storer.Authenticate(ctx, now, email, "gophers"); !errors.Is(err, database.ErrAuthenticationFailure) {
+			t.Fatalf("\t%s\t Test %d Should not authenticate a disabled user, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should not authenticate a disabled user", tests.Succeeded, testID)
+
+		testID++
+		t.Logf("\t Test %d \t When deleting users", testID)
+
+		if err := storer.Delete(ctx, other, usr.ID); !errors.Is(err, database.ErrForbidden) {
+			t.Fatalf("\t%s\t Test %d Should forbid other users to delete, got %v", tests.Failed, testID, err)
+		}
+		t.Logf("\t%s\t Test %d Should forbid other users to delete", tests.Succeeded, testID)
+
+		for _, id := range []string{usr.ID, second.ID} {
+			if err := storer.Delete(ctx, admin, id); err != nil {
+				t.Fatalf("\t%s\t Test %d Should be able to delete the user: %v", tests.Failed, testID, err)
+			}
+
+			if _, err := storer.QueryByID(ctx, admin, id); !errors.Is(err, database.ErrNotFound) {
+				t.Fatalf("\t%s\t Test %d Should not find a deleted user, got %v", tests.Failed, testID, err)
+			}
+		}
+		t.Logf("\t%s\t Test %d Should be able to delete the users", tests.Succeeded, testID)
+	}
+}
+
+func claimsFor(subject string, roles ...string) auth.Claims {
+	return auth.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "service project",
+			Subject:   subject,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+			IssuedAt:  time.Now().UTC().Unix(),
+		},
+		Roles: roles,
+	}
+}
+
+func contains(users []user.User, id string) bool {
+	for _, usr := range users {
+		if usr.ID == id {
+			return true
+		}
+	}
+	return false
+}
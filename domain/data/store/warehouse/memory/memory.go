@@ -0,0 +1,144 @@
+// Package memory provides a thread safe in memory implementation of the
+// warehouse store with the same semantics as the postgres store. It starts
+// with the main warehouse like the database does after migration 2.7.
+package memory
+
+import (
+	"context"
+	productMemory "service/domain/data/store/product/memory"
+	"service/domain/data/store/warehouse"
+	"service/domain/sys/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Store struct {
+	mu         sync.Mutex
+	products   *productMemory.Store
+	warehouses map[string]warehouse.Warehouse
+}
+
+// NewStore constructs the store, the memory product store it is given
+// learns which warehouse is the default.
+func NewStore(products *productMemory.Store) *Store {
+	now := time.Date(2019, time.March, 24, 0, 0, 0, 0, time.UTC)
+
+	s := Store{
+		products:   products,
+		warehouses: make(map[string]warehouse.Warehouse),
+	}
+	s.warehouses[warehouse.MainID] = warehouse.Warehouse{
+		ID:          warehouse.MainID,
+		Code:        "MAIN",
+		Name:        "Main Warehouse",
+		IsDefault:   true,
+		DateCreated: now,
+		DateUpdated: now,
+	}
+
+	products.SetDefaultWarehouse(warehouse.MainID)
+	return &s
+}
+
+func (s *Store) Create(ctx context.Context, w warehouse.Warehouse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.warehouses[w.ID]; ok {
+		return database.ErrDuplicatedEntry
+	}
+	if s.codeTaken(w) {
+		return warehouse.ErrUniqueCode
+	}
+
+	s.warehouses[w.ID] = w
+	return nil
+}
+
+func (s *Store) Update(ctx context.Context, w warehouse.Warehouse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, ok := s.warehouses[w.ID]
+	if !ok {
+		return nil
+	}
+	if s.codeTaken(w) {
+		return warehouse.ErrUniqueCode
+	}
+
+	cur.Code = w.Code
+	cur.Name = w.Name
+	cur.DateUpdated = w.DateUpdated
+	s.warehouses[w.ID] = cur
+	return nil
+}
+
+func (s *Store) SetDefault(ctx context.Context, warehouseID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.warehouses[warehouseID]; !ok {
+		return nil
+	}
+
+	for id, w := range s.warehouses {
+		if w.IsDefault != (id == warehouseID) {
+			w.IsDefault = id == warehouseID
+			w.DateUpdated = now
+			s.warehouses[id] = w
+		}
+	}
+
+	s.products.SetDefaultWarehouse(warehouseID)
+	return nil
+}
+
+func (s *Store) Query(ctx context.Context) ([]warehouse.Warehouse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	whs := make([]warehouse.Warehouse, 0, len(s.warehouses))
+	for _, w := range s.warehouses {
+		whs = append(whs, w)
+	}
+
+	sort.Slice(whs, func(i, j int) bool {
+		return whs[i].Code < whs[j].Code
+	})
+	return whs, nil
+}
+
+func (s *Store) QueryByID(ctx context.Context, warehouseID string) (warehouse.Warehouse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w, ok := s.warehouses[warehouseID]
+	if !ok {
+		return warehouse.Warehouse{}, database.ErrNotFound
+	}
+	return w, nil
+}
+
+func (s *Store) QueryDefault(ctx context.Context) (warehouse.Warehouse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.warehouses {
+		if w.IsDefault {
+			return w, nil
+		}
+	}
+	return warehouse.Warehouse{}, database.ErrNotFound
+}
+
+// codeTaken reports whether another warehouse already has the code of w.
+func (s *Store) codeTaken(w warehouse.Warehouse) bool {
+	for _, other := range s.warehouses {
+		if other.ID != w.ID && other.Code == w.Code {
+			return true
+		}
+	}
+	return false
+}
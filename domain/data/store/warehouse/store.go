@@ -0,0 +1,149 @@
+// Package warehouse persists the warehouses stock is held in.
+package warehouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"service/domain/sys/database"
+	"time"
+)
+
+// ErrUniqueCode is returned when a warehouse is given a code another
+// warehouse already holds.
+var ErrUniqueCode = errors.New("warehouse code is not unique")
+
+type Store struct {
+	logger *zap.SugaredLogger
+	db     *sqlx.DB
+}
+
+func NewStore(log *zap.SugaredLogger, db *sqlx.DB) Store {
+	return Store{
+		logger: log,
+		db:     db,
+	}
+}
+
+// Create stores the warehouse. It is stored as it is given, SetDefault
+// makes it the default.
+func (s Store) Create(ctx context.Context, w Warehouse) error {
+	q := `INSERT INTO warehouses
+	(warehouse_id, code, name, is_default, date_created, date_updated)
+	VALUES
+	(:warehouse_id, :code, :name, :is_default, :date_created, :date_updated)`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, w); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueCode
+		}
+		return fmt.Errorf("inserting warehouse %w", err)
+	}
+	return nil
+}
+
+// Update replaces the code and name of the warehouse.
+func (s Store) Update(ctx context.Context, w Warehouse) error {
+	q := `
+	UPDATE warehouses
+	SET code = :code, name = :name, date_updated = :date_updated
+	WHERE warehouse_id = :warehouse_id`
+
+	if err := database.NamedExecContext(ctx, s.logger, s.db, q, w); err != nil {
+		if errors.Is(err, database.ErrDuplicatedEntry) {
+			return ErrUniqueCode
+		}
+		return fmt.Errorf("updating warehouse %s %w", w.ID, err)
+	}
+	return nil
+}
+
+// SetDefault makes the warehouse the default one in place of the current
+// default.
+func (s Store) SetDefault(ctx context.Context, warehouseID string, now time.Time) error {
+	data := struct {
+		WarehouseID string    `db:"warehouse_id"`
+		DateUpdated time.Time `db:"date_updated"`
+	}{
+		WarehouseID: warehouseID,
+		DateUpdated: now,
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %w", err)
+	}
+	defer tx.Rollback()
+
+	// The default is cleared before it is set, only one warehouse can be
+	// the default at any time.
+	q := `
+	UPDATE warehouses
+	SET is_default = FALSE, date_updated = :date_updated
+	WHERE is_default AND warehouse_id <> :warehouse_id`
+
+	if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("clearing default warehouse %w", err)
+	}
+
+	q = `
+	UPDATE warehouses
+	SET is_default = TRUE, date_updated = :date_updated
+	WHERE warehouse_id = :warehouse_id`
+
+	if _, err := tx.NamedExecContext(ctx, q, data); err != nil {
+		return fmt.Errorf("setting default warehouse %s %w", warehouseID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %w", err)
+	}
+	return nil
+}
+
+// Query returns every warehouse ordered by code.
+func (s Store) Query(ctx context.Context) ([]Warehouse, error) {
+	q := `SELECT * FROM warehouses ORDER BY code`
+
+	var whs []Warehouse
+	if err := database.NamedQuerySlice(ctx, s.logger, s.db, q, struct{}{}, &whs); err != nil {
+		return nil, fmt.Errorf("selecting warehouses %w", err)
+	}
+	return whs, nil
+}
+
+// QueryByID returns the warehouse with the id.
+func (s Store) QueryByID(ctx context.Context, warehouseID string) (Warehouse, error) {
+	data := struct {
+		WarehouseID string `db:"warehouse_id"`
+	}{
+		WarehouseID: warehouseID,
+	}
+
+	q := `SELECT * FROM warehouses WHERE warehouse_id = :warehouse_id`
+
+	var w Warehouse
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, data, &w); err != nil {
+		if err == database.ErrNotFound {
+			return Warehouse{}, database.ErrNotFound
+		}
+		return Warehouse{}, fmt.Errorf("selecting warehouse %s %w", warehouseID, err)
+	}
+	return w, nil
+}
+
+// QueryDefault returns the default warehouse.
+func (s Store) QueryDefault(ctx context.Context) (Warehouse, error) {
+	q := `SELECT * FROM warehouses WHERE is_default`
+
+	var w Warehouse
+	if err := database.NamedQueryStruct(ctx, s.logger, s.db, q, struct{}{}, &w); err != nil {
+		if err == database.ErrNotFound {
+			return Warehouse{}, database.ErrNotFound
+		}
+		return Warehouse{}, fmt.Errorf("selecting default warehouse %w", err)
+	}
+	return w, nil
+}
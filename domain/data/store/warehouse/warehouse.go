@@ -0,0 +1,34 @@
+package warehouse
+
+import "time"
+
+// MainID is the id of the main warehouse created by migration 2.7. It
+// holds the stock of every product from before there were warehouses, and
+// is the default warehouse until another one is made the default.
+const MainID = "0b7c3e4a-9d21-4f6e-8a35-c1d2e3f4a5b6"
+
+// Warehouse is a place we hold stock in. Exactly one warehouse is the
+// default, orders pick from it unless they name another.
+type Warehouse struct {
+	ID          string    `db:"warehouse_id" json:"id"`
+	Code        string    `db:"code" json:"code"`
+	Name        string    `db:"name" json:"name"`
+	IsDefault   bool      `db:"is_default" json:"is_default"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+// NewWarehouse is what we require to open a warehouse.
+type NewWarehouse struct {
+	Code      string `json:"code" validate:"required,max=20"`
+	Name      string `json:"name" validate:"required,max=100"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// UpdateWarehouse changes the fields that are set. A warehouse stops
+// being the default only by making another one the default.
+type UpdateWarehouse struct {
+	Code      *string `json:"code" validate:"omitempty,max=20"`
+	Name      *string `json:"name" validate:"omitempty,max=100"`
+	IsDefault *bool   `json:"is_default"`
+}
@@ -10,6 +10,7 @@ import (
 	"go.uber.org/zap"
 	"io"
 	"os"
+	"service/domain/data/doctor"
 	"service/domain/data/schema"
 	"service/domain/data/store/user"
 	"service/domain/sys/auth"
@@ -34,12 +35,12 @@ type DBContainer struct {
 
 func NewUnit(t *testing.T, dbc DBContainer) (*zap.SugaredLogger, *sqlx.DB, func()) {
 
+	c := docker.StartContainer(t, dbc.Image, dbc.Port, dbc.Args...)
+
 	r, w, _ := os.Pipe() // to have reader and writer ?
 	old := os.Stdout     // make copy of it
 	os.Stdout = w
 
-	c := docker.StartContainer(t, dbc.Image, dbc.Port, dbc.Args...)
-
 	db, err := database.Open(database.Config{
 		User:       "postgres",
 		Password:   "postgres",
@@ -61,6 +62,12 @@ func NewUnit(t *testing.T, dbc DBContainer) (*zap.SugaredLogger, *sqlx.DB, func(
 		t.Fatalf("Migrating error %v", err)
 	}
 
+	if drifts := checkSchema(ctx, db); len(drifts) != 0 {
+		docker.DumpContainerLogs(t, c.ID)
+		docker.StopContainer(t, c.ID)
+		t.Fatalf("schema drift %v", drifts)
+	}
+
 	if err := schema.Seed(ctx, db); err != nil {
 		docker.DumpContainerLogs(t, c.ID)
 		docker.StopContainer(t, c.ID)
@@ -137,6 +144,32 @@ func (test *Test) Token(email, pass string) string {
 	return token
 }
 
+// CheckSchema fails the test when the store models drifted from the tables
+// the migrations create.
+func CheckSchema(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, d := range checkSchema(ctx, db) {
+		t.Errorf("\t%s\tschema drift: %s", Failed, d)
+	}
+}
+
+func checkSchema(ctx context.Context, db *sqlx.DB) []string {
+	drifts, err := doctor.Check(ctx, db, doctor.Models)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var out []string
+	for _, d := range drifts {
+		out = append(out, d.String())
+	}
+	return out
+}
+
 // StringPointer some helper functions in tests
 func StringPointer(s string) *string {
 	return &s
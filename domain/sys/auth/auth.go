@@ -5,6 +5,28 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Set of error variables for authenticating requests.
+var (
+	// ErrPurpose is returned when a token is used for something else
+	// than what it was issued for.
+	ErrPurpose = errors.New("token was not issued for this purpose")
+
+	// ErrInvalidKey is returned for unknown, expired and revoked API keys
+	// alike.
+	ErrInvalidKey = errors.New("invalid or expired api key")
+
+	// ErrInvalidToken is wrapped by every reason a token is refused for.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Default values used when New is not given the matching option.
+const (
+	DefaultIssuer = "service project"
+	DefaultTTL    = time.Hour
 )
 
 type KeyLookup interface {
@@ -12,18 +34,56 @@ type KeyLookup interface {
 	PublicKey(kid string) (*rsa.PublicKey, error)
 }
 
+// Option changes how tokens are issued and validated.
+type Option func(a *Auth)
+
+// WithIssuer sets the issuer put in tokens and required from them.
+func WithIssuer(issuer string) Option {
+	return func(a *Auth) {
+		a.issuer = issuer
+	}
+}
+
+// WithAudience sets the audiences tokens are accepted for. Tokens are
+// issued for the first one. Without audiences the claim is not checked.
+func WithAudience(audiences ...string) Option {
+	return func(a *Auth) {
+		a.audiences = audiences
+	}
+}
+
+// WithTTL sets how long tokens are valid when the claims do not say.
+func WithTTL(ttl time.Duration) Option {
+	return func(a *Auth) {
+		a.ttl = ttl
+	}
+}
+
+// WithLeeway sets how much clock skew between services is tolerated when
+// checking the time claims.
+func WithLeeway(leeway time.Duration) Option {
+	return func(a *Auth) {
+		a.leeway = leeway
+	}
+}
+
 type Auth struct {
 	activeKID string
 	keyLookup KeyLookup
 	method    jwt.SigningMethod
 	keyFunc   func(t *jwt.Token) (any, error)
 	parser    jwt.Parser
+	issuer    string
+	audiences []string
+	ttl       time.Duration
+	leeway    time.Duration
+	now       func() time.Time
 }
 
-func New(activeKID string, lookup KeyLookup) (*Auth, error) {
+func New(activeKID string, lookup KeyLookup, opts ...Option) (*Auth, error) {
 	_, err := lookup.PrivateKey(activeKID)
 	if err != nil {
-		return nil, errors.New("active kid doesn't exist in store")
+		return nil, fmt.Errorf("active kid %q doesn't exist in store: %w", activeKID, err)
 	}
 
 	method := jwt.GetSigningMethod("RS256")
@@ -44,8 +104,11 @@ func New(activeKID string, lookup KeyLookup) (*Auth, error) {
 		return lookup.PublicKey(kidID)
 	}
 
+	// The time claims are checked by validate, the parser can not be
+	// given a leeway.
 	jwtParser := jwt.Parser{
-		ValidMethods: []string{"RS256"},
+		ValidMethods:         []string{"RS256"},
+		SkipClaimsValidation: true,
 	}
 
 	a := Auth{
@@ -54,36 +117,142 @@ func New(activeKID string, lookup KeyLookup) (*Auth, error) {
 		method:    method,
 		keyFunc:   keyFunc,
 		parser:    jwtParser,
+		issuer:    DefaultIssuer,
+		ttl:       DefaultTTL,
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&a)
+	}
+
+	if a.ttl <= 0 {
+		return nil, fmt.Errorf("token ttl must be positive, got %v", a.ttl)
+	}
+
+	if a.leeway < 0 {
+		return nil, fmt.Errorf("leeway can not be negative, got %v", a.leeway)
 	}
+
 	return &a, nil
 }
 
+// GenerateToken signs the claims. The issuer, audience, not before and
+// token id are always set here, the issue time and expiry only when the
+// claims leave them empty.
 func (a *Auth) GenerateToken(claims Claims) (string, error) {
+	if claims.IssuedAt == 0 {
+		claims.IssuedAt = a.now().Unix()
+	}
+
+	if claims.ExpiresAt == 0 {
+		claims.ExpiresAt = time.Unix(claims.IssuedAt, 0).Add(a.ttl).Unix()
+	}
+
+	claims.Issuer = a.issuer
+	claims.Audience = ""
+	if len(a.audiences) > 0 {
+		claims.Audience = a.audiences[0]
+	}
+	claims.NotBefore = claims.IssuedAt
+	claims.Id = uuid.NewString()
+
 	token := jwt.NewWithClaims(a.method, claims)
 	token.Header["kid"] = a.activeKID
 
 	privateKey, err := a.keyLookup.PrivateKey(a.activeKID)
 	if err != nil {
-		return "", errors.New("")
+		return "", fmt.Errorf("looking up private key %q: %w", a.activeKID, err)
 	}
 
 	str, err := token.SignedString(privateKey)
 	if err != nil {
-		return "", errors.New("")
+		return "", fmt.Errorf("signing token: %w", err)
 	}
 	return str, nil
 }
 
+// ValidateToken returns the claims of a token that authenticates its
+// holder, tokens issued for a purpose are refused.
 func (a *Auth) ValidateToken(tokenStr string) (Claims, error) {
+	claims, err := a.parse(tokenStr)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if claims.Purpose != "" {
+		return Claims{}, ErrPurpose
+	}
+	return claims, nil
+}
+
+// ValidatePurpose returns the claims of a token issued for the purpose.
+func (a *Auth) ValidatePurpose(tokenStr string, purpose string) (Claims, error) {
+	claims, err := a.parse(tokenStr)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if claims.Purpose != purpose {
+		return Claims{}, ErrPurpose
+	}
+	return claims, nil
+}
+
+func (a *Auth) parse(tokenStr string) (Claims, error) {
 	var claims Claims
 	token, err := a.parser.ParseWithClaims(tokenStr, &claims, a.keyFunc)
-
 	if err != nil {
-		return Claims{}, fmt.Errorf("%w", "whats this")
+		return Claims{}, fmt.Errorf("%w: parsing token: %v", ErrInvalidToken, err)
 	}
 
 	if !token.Valid {
-		return Claims{}, errors.New("")
+		return Claims{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	if err := a.validate(claims); err != nil {
+		return Claims{}, err
 	}
 	return claims, nil
 }
+
+// validate checks the registered claims, allowing for the leeway on the
+// time claims.
+func (a *Auth) validate(claims Claims) error {
+	now := a.now()
+
+	if claims.ExpiresAt == 0 {
+		return fmt.Errorf("%w: missing expiry", ErrInvalidToken)
+	}
+
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(a.leeway)) {
+		return fmt.Errorf("%w: expired at %v", ErrInvalidToken, time.Unix(claims.ExpiresAt, 0).UTC())
+	}
+
+	if claims.NotBefore != 0 && now.Add(a.leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return fmt.Errorf("%w: not valid before %v", ErrInvalidToken, time.Unix(claims.NotBefore, 0).UTC())
+	}
+
+	if claims.IssuedAt != 0 && now.Add(a.leeway).Before(time.Unix(claims.IssuedAt, 0)) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+
+	if a.issuer != "" && claims.Issuer != a.issuer {
+		return fmt.Errorf("%w: issuer %q is not trusted", ErrInvalidToken, claims.Issuer)
+	}
+
+	if len(a.audiences) > 0 {
+		found := false
+		for _, aud := range a.audiences {
+			if claims.Audience == aud {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: audience %q is not accepted", ErrInvalidToken, claims.Audience)
+		}
+	}
+
+	return nil
+}
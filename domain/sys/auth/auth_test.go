@@ -3,6 +3,7 @@ package auth
 import (
 	"crypto/rand"
 	"crypto/rsa"
+	"errors"
 	"github.com/golang-jwt/jwt/v4"
 	"testing"
 	"time"
@@ -35,7 +36,6 @@ func TestAuth(t *testing.T) {
 
 			claims := Claims{
 				StandardClaims: jwt.StandardClaims{
-					Issuer:    "service project",
 					Subject:   "ABCD",
 					ExpiresAt: time.Now().Add(7860 * time.Hour).Unix(),
 					IssuedAt:  time.Now().UTC().Unix(),
@@ -58,20 +58,135 @@ func TestAuth(t *testing.T) {
 			if exp, got := len(claims.Roles), len(parsedClaims.Roles); exp != got {
 				t.Logf("\t Test %d \t Exp %d", testID, exp)
 				t.Logf("\t Test %d \t Got %d", testID, got)
-				t.Fatalf("\t %s \t Test %d \t Failed", failure, err)
+				t.Fatalf("\t %s \t Test %d \t Failed", failure, testID)
 			}
 			t.Logf("\t %s \t Test %d \t Got Expected number of rows", success, testID)
 
 			if exp, got := claims.Roles[0], parsedClaims.Roles[0]; exp != got {
-				t.Logf("\t Test %d \t Exp %d", testID, exp)
-				t.Logf("\t Test %d \t Got %d", testID, got)
-				t.Fatalf("\t %s \t Test %d \t Failed", failure, err)
+				t.Logf("\t Test %d \t Exp %s", testID, exp)
+				t.Logf("\t Test %d \t Got %s", testID, got)
+				t.Fatalf("\t %s \t Test %d \t Failed", failure, testID)
 			}
 			t.Logf("\t %s \t Test %d \t Got Expected roles", success, testID)
 		}
 	}
 }
 
+func TestValidation(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t %s \t Failed while Creating Private key %v", failure, err)
+	}
+	ks := &testKeyStore{privateKey}
+
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	a, err := New("kid", ks, WithIssuer("sales-api"), WithAudience("sales", "reports"), WithTTL(10*time.Minute), WithLeeway(30*time.Second))
+	if err != nil {
+		t.Fatalf("\t %s \t Failed while Creating Authentication %v", failure, err)
+	}
+	a.now = clock
+
+	other, err := New("kid", ks, WithIssuer("someone-else"), WithAudience("reports"))
+	if err != nil {
+		t.Fatalf("\t %s \t Failed while Creating Authentication %v", failure, err)
+	}
+	other.now = clock
+
+	stranger, err := New("kid", ks, WithIssuer("sales-api"), WithAudience("billing"))
+	if err != nil {
+		t.Fatalf("\t %s \t Failed while Creating Authentication %v", failure, err)
+	}
+	stranger.now = clock
+
+	t.Log("Given the need to only accept tokens meant for us")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When generating a token", testID)
+		{
+			claims := Claims{Roles: []string{RoleUser}}
+			claims.Subject = "ABCD"
+
+			token, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to generate a token: %v", failure, testID, err)
+			}
+
+			got, err := a.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to validate the token: %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should be able to validate the token", success, testID)
+
+			if got.Issuer != "sales-api" || got.Audience != "sales" {
+				t.Fatalf("\t %s \t Test %d \t Should set the issuer and audience, got %q %q", failure, testID, got.Issuer, got.Audience)
+			}
+			t.Logf("\t %s \t Test %d \t Should set the issuer and audience", success, testID)
+
+			if got.IssuedAt != now.Unix() || got.NotBefore != now.Unix() || got.ExpiresAt != now.Add(10*time.Minute).Unix() {
+				t.Fatalf("\t %s \t Test %d \t Should set the time claims from the ttl, got %+v", failure, testID, got.StandardClaims)
+			}
+			t.Logf("\t %s \t Test %d \t Should set the time claims from the ttl", success, testID)
+
+			second, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to generate a token: %v", failure, testID, err)
+			}
+			again, err := a.ValidateToken(second)
+			if err != nil || got.Id == "" || got.Id == again.Id {
+				t.Fatalf("\t %s \t Test %d \t Should give every token its own id, got %q %q %v", failure, testID, got.Id, again.Id, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should give every token its own id", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When validating a token", testID)
+		{
+			claims := Claims{}
+			claims.Subject = "ABCD"
+
+			token, err := a.GenerateToken(claims)
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to generate a token: %v", failure, testID, err)
+			}
+
+			tests := []struct {
+				name  string
+				auth  *Auth
+				at    time.Time
+				valid bool
+			}{
+				{"within the leeway after expiry", a, now.Add(10*time.Minute + 20*time.Second), true},
+				{"past the leeway after expiry", a, now.Add(10*time.Minute + 40*time.Second), false},
+				{"within the leeway before issue", a, now.Add(-20 * time.Second), true},
+				{"past the leeway before issue", a, now.Add(-40 * time.Second), false},
+				{"from another issuer", other, now, false},
+				{"for another audience", stranger, now, false},
+			}
+
+			for _, tt := range tests {
+				now := tt.at
+				tt.auth.now = func() time.Time { return now }
+
+				_, err := tt.auth.ValidateToken(token)
+				if tt.valid && err != nil {
+					t.Fatalf("\t %s \t Test %d \t Should accept a token %s: %v", failure, testID, tt.name, err)
+				}
+				if !tt.valid && !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("\t %s \t Test %d \t Should refuse a token %s, got %v", failure, testID, tt.name, err)
+				}
+				t.Logf("\t %s \t Test %d \t Should handle a token %s", success, testID, tt.name)
+			}
+
+			if _, err := a.ValidateToken(token + "x"); !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse a tampered token, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse a tampered token", success, testID)
+		}
+	}
+}
+
 type testKeyStore struct {
 	pk *rsa.PrivateKey
 }
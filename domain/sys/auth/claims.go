@@ -11,10 +11,40 @@ const (
 	RoleUser  = "USER"
 )
 
+// Set of permissions the service checks. Roles grant them through the
+// role_permissions table, the names must match the permissions table.
+const (
+	PermUsersRead     = "users:read"
+	PermUsersWrite    = "users:write"
+	PermRolesRead     = "roles:read"
+	PermRolesWrite    = "roles:write"
+	PermProductsRead  = "products:read"
+	PermProductsWrite = "products:write"
+	PermSalesRead     = "sales:read"
+	PermSalesWrite    = "sales:write"
+	PermSalesRefund   = "sales:refund"
+	PermReportsRead   = "reports:read"
+
+	PermInventoryRead  = "inventory:read"
+	PermInventoryWrite = "inventory:write"
+
+	PermPromotionsRead  = "promotions:read"
+	PermPromotionsWrite = "promotions:write"
+)
+
+// Set of purposes a token can be restricted to. Such tokens only prove a
+// step of a login and are refused where a regular token is expected.
+const (
+	PurposeMFA       = "mfa"
+	PurposeMFAEnroll = "mfa_enroll"
+)
+
 // Claims represents the authorization claims transmitted via a JWT.
 type Claims struct {
 	jwt.StandardClaims
-	Roles []string `json:"roles"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions,omitempty"`
+	Purpose     string   `json:"purpose,omitempty"`
 }
 
 func (c Claims) Authorized(roles ...string) bool {
@@ -28,6 +58,23 @@ func (c Claims) Authorized(roles ...string) bool {
 	return false
 }
 
+// Permitted reports whether the claims hold every one of the permissions.
+func (c Claims) Permitted(perms ...string) bool {
+	for _, want := range perms {
+		found := false
+		for _, perm := range c.Permissions {
+			if perm == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 type ctxKey int
 
 const key ctxKey = 1
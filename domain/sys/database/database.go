@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
@@ -23,8 +24,12 @@ var (
 	ErrInvalidID             = errors.New("invalid id")
 	ErrAuthenticationFailure = errors.New("authentication failed")
 	ErrForbidden             = errors.New("forbidden")
+	ErrDuplicatedEntry       = errors.New("duplicated entry")
 )
 
+// uniqueViolation is the postgres error code for a unique constraint violation.
+const uniqueViolation = "23505"
+
 type Config struct {
 	User         string
 	Password     string
@@ -66,7 +71,7 @@ func Open(cfg Config) (*sqlx.DB, error) {
 func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 
 	for attempts := 1; ; attempts++ {
-		if db.Ping() == nil {
+		if db.PingContext(ctx) == nil {
 			break
 		}
 		time.Sleep(time.Duration(attempts) * 100 * time.Millisecond)
@@ -91,11 +96,21 @@ func NamedExecContext(ctx context.Context, logger *zap.SugaredLogger, db *sqlx.D
 	defer span.End()
 
 	if _, err := db.NamedExecContext(ctx, query, data); err != nil {
+		if IsDuplicated(err) {
+			return ErrDuplicatedEntry
+		}
 		return err
 	}
 	return nil
 }
 
+// IsDuplicated reports whether err is a unique constraint violation, for
+// statements run in a transaction rather than through NamedExecContext.
+func IsDuplicated(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
 func NamedQuerySlice(ctx context.Context, logger *zap.SugaredLogger, db *sqlx.DB, query string, data any, dest any) error {
 	q := queryString(query, data)
 	logger.Infow("database.NamedQuerySlice", "traceID", web.GetTraceID(ctx), "query", q)
@@ -113,6 +128,7 @@ func NamedQuerySlice(ctx context.Context, logger *zap.SugaredLogger, db *sqlx.DB
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	slice := val.Elem()
 	for rows.Next() {
@@ -123,7 +139,7 @@ func NamedQuerySlice(ctx context.Context, logger *zap.SugaredLogger, db *sqlx.DB
 		}
 		slice.Set(reflect.Append(slice, v.Elem()))
 	}
-	return nil
+	return rows.Err()
 }
 
 func NamedQueryStruct(ctx context.Context, logger *zap.SugaredLogger, db *sqlx.DB, query string, data any, dest any) error {
@@ -138,8 +154,12 @@ func NamedQueryStruct(ctx context.Context, logger *zap.SugaredLogger, db *sqlx.D
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
 	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
 		return ErrNotFound
 	}
 
@@ -7,6 +7,7 @@ import (
 	enTranslator "github.com/go-playground/validator/v10/translations/en"
 	"github.com/google/uuid"
 	"reflect"
+	"service/foundation/money"
 	"strings"
 )
 
@@ -27,6 +28,36 @@ func init() {
 		}
 		return name
 	})
+
+	// Money fields are validated through their currency, tags on structs
+	// are not run otherwise.
+	validate.RegisterCustomTypeFunc(func(v reflect.Value) any {
+		return v.Interface().(money.Money).Currency().String()
+	}, money.Money{})
+
+	validate.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return money.Currency(fl.Field().String()).Valid()
+	})
+
+	// samecurrency=Field makes sure amounts meant to be added or compared
+	// are of the same currency as the named sibling field.
+	validate.RegisterValidation("samecurrency", func(fl validator.FieldLevel) bool {
+		other := reflect.Indirect(fl.Parent()).FieldByName(fl.Param())
+		if !other.IsValid() {
+			return false
+		}
+
+		var cur string
+		switch v := other.Interface().(type) {
+		case money.Money:
+			cur = v.Currency().String()
+		case money.Currency:
+			cur = v.String()
+		default:
+			return false
+		}
+		return fl.Field().String() == cur
+	})
 }
 
 func Check(val any) error {
@@ -0,0 +1,55 @@
+package validate
+
+import (
+	"service/foundation/money"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+func TestMoney(t *testing.T) {
+	type discount struct {
+		Currency money.Currency `json:"currency" validate:"required,currency"`
+		Minimum  money.Money    `json:"minimum" validate:"required,samecurrency=Currency"`
+		Off      money.Money    `json:"off" validate:"required,samecurrency=Minimum"`
+	}
+
+	t.Log("Given the need to refuse amounts that can not be added up")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When checking currencies", testID)
+		{
+			ok := discount{
+				Currency: money.EUR,
+				Minimum:  money.New(5000, money.EUR),
+				Off:      money.New(500, money.EUR),
+			}
+			if err := Check(ok); err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should accept a single currency: %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should accept a single currency", success, testID)
+
+			mixed := ok
+			mixed.Off = money.New(500, money.USD)
+			if fields, ok := Check(mixed).(FieldErrors); !ok || len(fields) != 1 || fields[0].Field != "off" {
+				t.Fatalf("\t %s \t Test %d \t Should refuse mixed currencies, got %v", failure, testID, fields)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse mixed currencies", success, testID)
+
+			unknown := ok
+			unknown.Currency = "eur"
+			if fields, ok := Check(unknown).(FieldErrors); !ok || len(fields) != 2 {
+				t.Fatalf("\t %s \t Test %d \t Should refuse an unknown currency, got %v", failure, testID, fields)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse an unknown currency", success, testID)
+
+			if err := Check(discount{Currency: money.EUR}); err == nil {
+				t.Fatalf("\t %s \t Test %d \t Should require the amounts", failure, testID)
+			}
+			t.Logf("\t %s \t Test %d \t Should require the amounts", success, testID)
+		}
+	}
+}
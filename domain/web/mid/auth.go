@@ -9,14 +9,33 @@ import (
 	"service/domain/sys/validate"
 	"service/foundation/web"
 	"strings"
+	"time"
 )
 
-func Authenticate(a *auth.Auth) web.MiddlewareFunc {
+// KeyAuthenticator turns an API key into the claims of the client holding
+// it.
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string, now time.Time) (auth.Claims, error)
+}
+
+// Authenticate accepts a bearer token, or an API key in the X-API-Key
+// header when keys is set.
+func Authenticate(a *auth.Auth, keys KeyAuthenticator) web.MiddlewareFunc {
 
 	m := func(handler web.HandlerFunc) web.HandlerFunc {
 
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				claims, err := authenticateKey(ctx, keys, key)
+				if err != nil {
+					return err
+				}
+
+				ctx = auth.SetClaims(ctx, claims)
+				return handler(ctx, w, r)
+			}
+
 			authStr := r.Header.Get("authorization")
 
 			parts := strings.Split(authStr, " ")
@@ -41,6 +60,63 @@ func Authenticate(a *auth.Auth) web.MiddlewareFunc {
 
 }
 
+// authenticateKey validates an API key, failures other than a bad key are
+// left for the errors middleware to log.
+func authenticateKey(ctx context.Context, keys KeyAuthenticator, key string) (auth.Claims, error) {
+	if keys == nil {
+		err := errors.New("api keys are not accepted")
+		return auth.Claims{}, validate.NewRequestError(err, http.StatusUnauthorized)
+	}
+
+	v, err := web.GetValues(ctx)
+	if err != nil {
+		return auth.Claims{}, web.NewShutdownError("web values missing from content")
+	}
+
+	claims, err := keys.Authenticate(ctx, key, v.Now)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidKey) {
+			return auth.Claims{}, validate.NewRequestError(auth.ErrInvalidKey, http.StatusUnauthorized)
+		}
+		return auth.Claims{}, fmt.Errorf("authenticating api key: %w", err)
+	}
+	return claims, nil
+}
+
+// AuthenticatePurpose accepts regular tokens as well as tokens issued for
+// the purpose. It guards the few routes a login step leads to, like
+// enrolling a second factor before a regular token can be issued.
+func AuthenticatePurpose(a *auth.Auth, purpose string) web.MiddlewareFunc {
+
+	m := func(handler web.HandlerFunc) web.HandlerFunc {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			authStr := r.Header.Get("authorization")
+
+			parts := strings.Split(authStr, " ")
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				err := errors.New("expected authorization header format: bearer <token>")
+				return validate.NewRequestError(err, http.StatusUnauthorized)
+			}
+
+			claims, err := a.ValidateToken(parts[1])
+			if errors.Is(err, auth.ErrPurpose) {
+				claims, err = a.ValidatePurpose(parts[1], purpose)
+			}
+			if err != nil {
+				return validate.NewRequestError(err, http.StatusUnauthorized)
+			}
+
+			ctx = auth.SetClaims(ctx, claims)
+
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
+
 func Authorize(roles ...string) web.MiddlewareFunc {
 
 	m := func(handler web.HandlerFunc) web.HandlerFunc {
@@ -67,3 +143,29 @@ func Authorize(roles ...string) web.MiddlewareFunc {
 	return m
 
 }
+
+// RequirePermission lets the request through when the claims hold every
+// one of the permissions.
+func RequirePermission(perms ...string) web.MiddlewareFunc {
+
+	m := func(handler web.HandlerFunc) web.HandlerFunc {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			claims, err := auth.GetClaims(ctx)
+			if err != nil {
+				err := fmt.Errorf("you are not authorized for that action")
+				return validate.NewRequestError(err, http.StatusForbidden)
+			}
+
+			if !claims.Permitted(perms...) {
+				err := fmt.Errorf("you are not authorized for that action, missing permissions %v", perms)
+				return validate.NewRequestError(err, http.StatusForbidden)
+			}
+
+			return handler(ctx, w, r)
+		}
+		return h
+	}
+	return m
+}
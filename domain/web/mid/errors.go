@@ -37,12 +37,12 @@ func Errors(logger *zap.SugaredLogger) web.MiddlewareFunc {
 					status = http.StatusBadRequest
 				case *validate.RequestError:
 					er = validate.ErrorResponse{
-						Fields: act.Error(),
+						Error: act.Error(),
 					}
 					status = act.Status
 				default:
 					er = validate.ErrorResponse{
-						Fields: http.StatusText(http.StatusInternalServerError),
+						Error: http.StatusText(http.StatusInternalServerError),
 					}
 					status = http.StatusInternalServerError
 				}
@@ -13,7 +13,7 @@ func Metrics() web.MiddlewareFunc {
 
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
-			metrics.Set(ctx)
+			ctx = metrics.Set(ctx)
 
 			//Execute the Original One when tmp is called
 			err := handler(ctx, w, r)
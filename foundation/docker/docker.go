@@ -13,7 +13,14 @@ type Container struct {
 	Host string
 }
 
+// StartContainer runs the image and returns where to reach it. Tests that
+// need a container are skipped when docker is not installed, so the rest of
+// the suite still runs on machines and CI runners without it.
 func StartContainer(t *testing.T, image string, port string, args ...string) *Container {
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not available, skipping test that needs a container")
+	}
+
 	arg := []string{"run", "-P", "-d"}
 	arg = append(arg, args...)
 	arg = append(arg, image)
@@ -28,6 +35,14 @@ func StartContainer(t *testing.T, image string, port string, args ...string) *Co
 
 	id := out.String()[:12]
 
+	cmd = exec.Command("docker", "inspect", id)
+	out.Reset()
+	cmd.Stdout = &out
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("could not inspect container %s: %v", id, err)
+	}
+
 	var doc []map[string]any
 	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
 		t.Fatalf("could not decode json %v ", err)
@@ -95,10 +110,11 @@ func extractIPPort(t *testing.T, doc []map[string]any, port string) (string, str
 			t.Fatalf("could not get network ports tcp data")
 		}
 
-		hostIp = data["HostIp"].(string)
-		if hostIp != "::" {
+		ip := data["HostIp"].(string)
+		if ip != "::" {
+			hostIp = ip
 			hostPort = data["HostPort"].(string)
 		}
 	}
-	return hostPort, hostIp
+	return hostIp, hostPort
 }
@@ -0,0 +1,210 @@
+// Package health provides a registry of named dependency checks used to
+// answer readiness probes. Results are cached for a short period so a storm
+// of probes does not turn into a storm of queries against our dependencies.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Set of statuses reported for a single check and for the whole report.
+const (
+	StatusOK       = "ok"
+	StatusFailed   = "failed"
+	StatusTimeout  = "timeout"
+	StatusDegraded = "degraded"
+	StatusNotReady = "not ready"
+)
+
+// CheckFunc performs a single dependency check. It must honour ctx.
+type CheckFunc func(ctx context.Context) error
+
+// Check describes a named dependency check.
+// Critical checks make the service not ready when they fail, non critical
+// checks only degrade the report.
+type Check struct {
+	Name     string
+	Timeout  time.Duration
+	Critical bool
+	Fn       CheckFunc
+}
+
+// Result is the outcome of running a single check.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of running every registered check.
+type Report struct {
+	Status    string    `json:"status"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached"`
+	Checks    []Result  `json:"checks"`
+}
+
+// Ready reports whether every critical check passed.
+func (r Report) Ready() bool {
+	return r.Status != StatusNotReady
+}
+
+// DefaultTimeout is used for checks registered without a timeout.
+const DefaultTimeout = time.Second
+
+// Registry holds the registered checks and the last report.
+type Registry struct {
+	cacheTTL time.Duration
+
+	mu     sync.Mutex
+	checks []Check
+
+	runMu  sync.Mutex
+	last   Report
+	lastAt time.Time
+}
+
+// New constructs a registry that caches reports for cacheTTL.
+// A zero cacheTTL disables caching.
+func New(cacheTTL time.Duration) *Registry {
+	return &Registry{
+		cacheTTL: cacheTTL,
+	}
+}
+
+// Register adds a check to the registry. Registering a name twice replaces
+// the previous check.
+func (r *Registry) Register(c Check) error {
+	if c.Name == "" {
+		return errors.New("check name is required")
+	}
+
+	if c.Fn == nil {
+		return fmt.Errorf("check %q has no function", c.Name)
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.checks {
+		if r.checks[i].Name == c.Name {
+			r.checks[i] = c
+			return nil
+		}
+	}
+	r.checks = append(r.checks, c)
+	return nil
+}
+
+// Run executes every registered check concurrently and returns the report.
+// When a report younger than the cache ttl exists it is returned instead,
+// concurrent callers wait for a single in flight run.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.runMu.Lock()
+	defer r.runMu.Unlock()
+
+	if r.cacheTTL > 0 && !r.lastAt.IsZero() && time.Since(r.lastAt) < r.cacheTTL {
+		report := r.last
+		report.Cached = true
+		return report
+	}
+
+	r.mu.Lock()
+	checks := make([]Check, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.Unlock()
+
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for i, c := range checks {
+		go func(i int, c Check) {
+			defer wg.Done()
+			results[i] = run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	report := Report{
+		Status:    StatusOK,
+		CheckedAt: time.Now().UTC(),
+		Checks:    results,
+	}
+
+	for _, res := range results {
+		if res.Status == StatusOK {
+			continue
+		}
+		if res.Critical {
+			report.Status = StatusNotReady
+			break
+		}
+		report.Status = StatusDegraded
+	}
+
+	r.last = report
+	r.lastAt = time.Now()
+
+	return report
+}
+
+func run(ctx context.Context, c Check) (res Result) {
+	res = Result{
+		Name:     c.Name,
+		Status:   StatusOK,
+		Critical: c.Critical,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		res.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		// A misbehaving check must not take the debug server down.
+		defer func() {
+			if rc := recover(); rc != nil {
+				done <- fmt.Errorf("panic: %v", rc)
+			}
+		}()
+		done <- c.Fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		switch {
+		case err == nil:
+		case ctx.Err() != nil:
+			res.Status = StatusTimeout
+			res.Error = err.Error()
+		default:
+			res.Status = StatusFailed
+			res.Error = err.Error()
+		}
+	case <-ctx.Done():
+		res.Status = StatusTimeout
+		res.Error = ctx.Err().Error()
+	}
+
+	return res
+}
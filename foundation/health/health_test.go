@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+func TestRegistry(t *testing.T) {
+
+	t.Log("Given the need to report the readiness of our dependencies")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When a non critical check fails", testID)
+		{
+			reg := New(0)
+			mustRegister(t, reg, Check{Name: "database", Critical: true, Fn: pass})
+			mustRegister(t, reg, Check{Name: "zipkin", Fn: fail})
+
+			report := reg.Run(context.Background())
+			if report.Status != StatusDegraded || !report.Ready() {
+				t.Fatalf("\t %s \t Test %d \t Should be degraded but ready, got %q", failure, testID, report.Status)
+			}
+			t.Logf("\t %s \t Test %d \t Should be degraded but ready", success, testID)
+
+			if len(report.Checks) != 2 || report.Checks[1].Error == "" {
+				t.Fatalf("\t %s \t Test %d \t Should report the failing check, got %+v", failure, testID, report.Checks)
+			}
+			t.Logf("\t %s \t Test %d \t Should report the failing check", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When a critical check times out", testID)
+		{
+			reg := New(0)
+			mustRegister(t, reg, Check{Name: "database", Critical: true, Timeout: 10 * time.Millisecond, Fn: block})
+
+			report := reg.Run(context.Background())
+			if report.Ready() {
+				t.Fatalf("\t %s \t Test %d \t Should not be ready", failure, testID)
+			}
+			t.Logf("\t %s \t Test %d \t Should not be ready", success, testID)
+
+			if got := report.Checks[0].Status; got != StatusTimeout {
+				t.Fatalf("\t %s \t Test %d \t Should report a timeout, got %q", failure, testID, got)
+			}
+			t.Logf("\t %s \t Test %d \t Should report a timeout", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When probes arrive within the cache ttl", testID)
+		{
+			var calls int32
+			count := func(ctx context.Context) error {
+				atomic.AddInt32(&calls, 1)
+				return nil
+			}
+
+			reg := New(time.Minute)
+			mustRegister(t, reg, Check{Name: "database", Critical: true, Fn: count})
+
+			reg.Run(context.Background())
+			report := reg.Run(context.Background())
+
+			if got := atomic.LoadInt32(&calls); got != 1 {
+				t.Fatalf("\t %s \t Test %d \t Should run the check once, ran %d", failure, testID, got)
+			}
+			t.Logf("\t %s \t Test %d \t Should run the check once", success, testID)
+
+			if !report.Cached {
+				t.Fatalf("\t %s \t Test %d \t Should mark the report as cached", failure, testID)
+			}
+			t.Logf("\t %s \t Test %d \t Should mark the report as cached", success, testID)
+		}
+	}
+}
+
+func mustRegister(t *testing.T, reg *Registry, c Check) {
+	t.Helper()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("\t %s \t Should be able to register %q: %v", failure, c.Name, err)
+	}
+}
+
+func pass(ctx context.Context) error {
+	return nil
+}
+
+func fail(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func block(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -1,13 +1,17 @@
 package keystore
 
 import (
+	"bytes"
 	"crypto/rsa"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"io"
 	"io/fs"
+	"os"
 	"path"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -32,6 +36,12 @@ func NewMap(store map[string]*rsa.PrivateKey) *KeyStore {
 // NewFs constructs keystore based on set of pem files rooted inside
 // of a directory, the name of each pem file will be used as key id
 func NewFs(fsys fs.FS) (*KeyStore, error) {
+	return NewFsPassphrase(fsys, nil)
+}
+
+// NewFsPassphrase is NewFs for folders holding encrypted PKCS#8 keys, the
+// passphrase decrypts all of them. Plain keys are still accepted.
+func NewFsPassphrase(fsys fs.FS, passphrase []byte) (*KeyStore, error) {
 	ks := &KeyStore{
 		store: make(map[string]*rsa.PrivateKey),
 	}
@@ -60,9 +70,9 @@ func NewFs(fsys fs.FS) (*KeyStore, error) {
 			return fmt.Errorf("reading auth private key  %w", err)
 		}
 
-		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePem)
+		privateKey, err := ParsePEM(privatePem, passphrase)
 		if err != nil {
-			return fmt.Errorf("parsing pem file error %w", err)
+			return fmt.Errorf("parsing pem file %s: %w", fileName, err)
 		}
 
 		ks.store[strings.TrimSuffix(entry.Name(), ".pem")] = privateKey
@@ -75,6 +85,31 @@ func NewFs(fsys fs.FS) (*KeyStore, error) {
 	return ks, nil
 }
 
+// ParsePEM parses a private key, decrypting it with the passphrase when it
+// is an encrypted PKCS#8 key.
+func ParsePEM(data []byte, passphrase []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block != nil && block.Type == pemEncrypted {
+		return DecryptPKCS8(block, passphrase)
+	}
+	return jwt.ParseRSAPrivateKeyFromPEM(data)
+}
+
+// ReadPassphrase reads the passphrase kept in a file, dropping the line
+// ending editors and echo leave behind.
+func ReadPassphrase(fileName string) ([]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("reading passphrase: %w", err)
+	}
+
+	passphrase := bytes.TrimRight(data, "\r\n")
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase file %s is empty", fileName)
+	}
+	return passphrase, nil
+}
+
 func (ks *KeyStore) Add(key *rsa.PrivateKey, kid string) {
 	ks.mu.Lock()
 	defer ks.mu.Unlock()
@@ -83,14 +118,27 @@ func (ks *KeyStore) Add(key *rsa.PrivateKey, kid string) {
 }
 
 func (ks *KeyStore) Remove(kid string) {
-	ks.mu.RLocker()
-	defer ks.mu.RUnlock()
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
 
 	delete(ks.store, kid)
 }
 
+// KIDs returns the sorted key ids held by the store.
+func (ks *KeyStore) KIDs() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	kids := make([]string, 0, len(ks.store))
+	for kid := range ks.store {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+	return kids
+}
+
 func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
-	ks.mu.RLocker()
+	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
 	privateKey, ok := ks.store[kid]
@@ -101,7 +149,7 @@ func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
 }
 
 func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
-	ks.mu.RLocker()
+	ks.mu.RLock()
 	defer ks.mu.RUnlock()
 
 	privateKey, ok := ks.store[kid]
@@ -0,0 +1,108 @@
+package keystore
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+func TestEncryptedKeys(t *testing.T) {
+	passphrase := []byte("gophers")
+
+	t.Log("Given the need to keep private keys encrypted at rest")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When reading a key encrypted by openssl", testID)
+		{
+			data, err := os.ReadFile("testdata/encrypted.pem")
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to read the key: %v", failure, testID, err)
+			}
+
+			if _, err := ParsePEM(data, passphrase); err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should decrypt with the passphrase: %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should decrypt with the passphrase", success, testID)
+
+			if _, err := ParsePEM(data, []byte("rabbits")); !errors.Is(err, ErrPassphrase) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse the wrong passphrase, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse the wrong passphrase", success, testID)
+
+			if _, err := ParsePEM(data, nil); !errors.Is(err, ErrPassphrase) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse a missing passphrase, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse a missing passphrase", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When reading a folder of keys", testID)
+		{
+			encrypted, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to generate a key: %v", failure, testID, err)
+			}
+
+			plain, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to generate a key: %v", failure, testID, err)
+			}
+
+			block, err := EncryptPKCS8(encrypted, passphrase)
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to encrypt the key: %v", failure, testID, err)
+			}
+
+			fsys := fstest.MapFS{
+				"encrypted.pem": {Data: pem.EncodeToMemory(block)},
+				"plain.pem":     {Data: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(plain)})},
+			}
+
+			ks, err := NewFsPassphrase(fsys, passphrase)
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to read the folder: %v", failure, testID, err)
+			}
+
+			got, err := ks.PrivateKey("encrypted")
+			if err != nil || !got.Equal(encrypted) {
+				t.Fatalf("\t %s \t Test %d \t Should hold the encrypted key, got %v", failure, testID, err)
+			}
+			got, err = ks.PrivateKey("plain")
+			if err != nil || !got.Equal(plain) {
+				t.Fatalf("\t %s \t Test %d \t Should hold the plain key, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should hold both keys", success, testID)
+
+			if _, err := NewFs(fsys); !errors.Is(err, ErrPassphrase) {
+				t.Fatalf("\t %s \t Test %d \t Should fail without the passphrase, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should fail without the passphrase", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When reading the passphrase from a file", testID)
+		{
+			name := filepath.Join(t.TempDir(), "passphrase")
+			if err := os.WriteFile(name, []byte("gophers\n"), 0600); err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to write the file: %v", failure, testID, err)
+			}
+
+			got, err := ReadPassphrase(name)
+			if err != nil || string(got) != "gophers" {
+				t.Fatalf("\t %s \t Test %d \t Should drop the line ending, got %q %v", failure, testID, got, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should drop the line ending", success, testID)
+		}
+	}
+}
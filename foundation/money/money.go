@@ -0,0 +1,314 @@
+// Package money represents amounts of money exactly, as a whole number of
+// the minor unit of an ISO 4217 currency. Arithmetic never mixes
+// currencies and never loses a minor unit: what can not be split evenly
+// is either rounded half to even or handed out one unit at a time.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Set of error variables for money arithmetic.
+var (
+	ErrMismatch = errors.New("currencies do not match")
+	ErrOverflow = errors.New("amount out of range")
+)
+
+// Money is an amount in the minor unit of its currency, cents for USD.
+// The zero value has no currency and is not valid.
+type Money struct {
+	amount   int64
+	currency Currency
+}
+
+// New constructs an amount of minor units of the currency.
+func New(amount int64, currency Currency) Money {
+	return Money{amount: amount, currency: currency}
+}
+
+// Zero returns nothing of the currency.
+func Zero(currency Currency) Money {
+	return Money{currency: currency}
+}
+
+// Parse reads a decimal amount like "12.34" of the currency. Digits past
+// the minor unit are rounded half to even.
+func Parse(s string, currency Currency) (Money, error) {
+	if !currency.Valid() {
+		return Money{}, fmt.Errorf("currency %q: %w", currency, ErrUnknownCurrency)
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || strings.ContainsAny(s, "/eE") {
+		return Money{}, fmt.Errorf("amount %q is not a decimal number", s)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(currency.MinorUnits())), nil)
+	r.Mul(r, new(big.Rat).SetInt(scale))
+
+	amount, err := roundHalfEven(r.Num(), r.Denom())
+	if err != nil {
+		return Money{}, fmt.Errorf("amount %q: %w", s, err)
+	}
+	return Money{amount: amount, currency: currency}, nil
+}
+
+// Amount returns the number of minor units.
+func (m Money) Amount() int64 {
+	return m.amount
+}
+
+// Currency returns the currency of the amount.
+func (m Money) Currency() Currency {
+	return m.currency
+}
+
+// IsZero reports whether the amount is nothing, whatever its currency.
+func (m Money) IsZero() bool {
+	return m.amount == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.amount < 0
+}
+
+// Equal reports whether both are the same amount of the same currency.
+func (m Money) Equal(o Money) bool {
+	return m == o
+}
+
+// Cmp compares both amounts, returning -1, 0 or +1.
+func (m Money) Cmp(o Money) (int, error) {
+	if err := m.same(o); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.amount < o.amount:
+		return -1, nil
+	case m.amount > o.amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Add returns the sum of both amounts.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.same(o); err != nil {
+		return Money{}, err
+	}
+
+	if (o.amount > 0 && m.amount > math.MaxInt64-o.amount) || (o.amount < 0 && m.amount < math.MinInt64-o.amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{amount: m.amount + o.amount, currency: m.currency}, nil
+}
+
+// Sub returns the amount less o.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{amount: -o.amount, currency: o.currency})
+}
+
+// Neg returns the amount with the opposite sign.
+func (m Money) Neg() (Money, error) {
+	if m.amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return Money{amount: -m.amount, currency: m.currency}, nil
+}
+
+// Mul returns the amount n times, the price of n units.
+func (m Money) Mul(n int64) (Money, error) {
+	p := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(n))
+	if !p.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return Money{amount: p.Int64(), currency: m.currency}, nil
+}
+
+// MulRat returns the amount times num/den, rounded half to even. A 15%
+// share is MulRat(15, 100).
+func (m Money) MulRat(num int64, den int64) (Money, error) {
+	if den == 0 {
+		return Money{}, errors.New("division by zero")
+	}
+
+	p := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(num))
+	amount, err := roundHalfEven(p, big.NewInt(den))
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{amount: amount, currency: m.currency}, nil
+}
+
+// Allocate splits the amount in parts weighted by ratios. The parts always
+// add up to the amount: the minor units left over are handed out one at
+// a time to the parts in order, skipping those with a zero ratio.
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	var total int64
+	for _, r := range ratios {
+		if r < 0 {
+			return nil, errors.New("ratios must not be negative")
+		}
+		total += int64(r)
+	}
+
+	if total == 0 {
+		return nil, errors.New("ratios must add up to more than zero")
+	}
+
+	parts := make([]Money, len(ratios))
+	left := m.amount
+	for i, r := range ratios {
+		p := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(int64(r)))
+		p.Quo(p, big.NewInt(total))
+
+		parts[i] = Money{amount: p.Int64(), currency: m.currency}
+		left -= parts[i].amount
+	}
+
+	unit := int64(1)
+	if left < 0 {
+		unit = -1
+	}
+	for i := 0; left != 0; i = (i + 1) % len(parts) {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].amount += unit
+		left -= unit
+	}
+
+	return parts, nil
+}
+
+// String formats the amount in major units followed by the currency, like
+// "12.34 USD".
+func (m Money) String() string {
+	units := m.currency.MinorUnits()
+
+	sign := ""
+	amount := new(big.Int).SetInt64(m.amount)
+	if amount.Sign() < 0 {
+		sign = "-"
+		amount.Neg(amount)
+	}
+
+	digits := amount.String()
+	if units > 0 {
+		if len(digits) <= units {
+			digits = strings.Repeat("0", units-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-units] + "." + digits[len(digits)-units:]
+	}
+
+	return fmt.Sprintf("%s%s %s", sign, digits, m.currency)
+}
+
+// same makes sure both amounts are of the same currency.
+func (m Money) same(o Money) error {
+	if m.currency != o.currency {
+		return fmt.Errorf("%s and %s: %w", m.currency, o.currency, ErrMismatch)
+	}
+	return nil
+}
+
+// =============================================================================
+
+// document is how an amount is represented in JSON, the amount is in
+// minor units so no precision is lost in floating point.
+type document struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(document{Amount: m.amount, Currency: m.currency})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The currency
+// must be known.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if !doc.Currency.Valid() {
+		return fmt.Errorf("currency %q: %w", doc.Currency, ErrUnknownCurrency)
+	}
+
+	*m = Money{amount: doc.Amount, currency: doc.Currency}
+	return nil
+}
+
+// Value implements the driver.Valuer interface, an amount is stored in a
+// single text column the way String formats it.
+func (m Money) Value() (driver.Value, error) {
+	if !m.currency.Valid() {
+		return nil, fmt.Errorf("currency %q: %w", m.currency, ErrUnknownCurrency)
+	}
+	return m.String(), nil
+}
+
+// Scan implements the sql.Scanner interface.
+func (m *Money) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("can not scan %T into money", src)
+	}
+
+	amount, currency, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return fmt.Errorf("money %q has no currency", s)
+	}
+
+	v, err := Parse(amount, Currency(currency))
+	if err != nil {
+		return err
+	}
+
+	*m = v
+	return nil
+}
+
+// roundHalfEven divides num by den rounding to the nearest whole number,
+// and to the even one when exactly halfway.
+func roundHalfEven(num *big.Int, den *big.Int) (int64, error) {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	// The remainder has the sign of num, the quotient was truncated
+	// toward zero so rounding moves it away from zero.
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+
+	switch twice.Cmp(new(big.Int).Abs(den)) {
+	case 1:
+		q.Add(q, big.NewInt(int64(num.Sign()*den.Sign())))
+	case 0:
+		if q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(int64(num.Sign()*den.Sign())))
+		}
+	}
+
+	if !q.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return q.Int64(), nil
+}
@@ -0,0 +1,209 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+func TestRounding(t *testing.T) {
+	usd := New(1000, USD)
+
+	vectors := []struct {
+		num  int64
+		den  int64
+		want int64
+	}{
+		{1, 3, 333},
+		{2, 3, 667},
+		{1, 16, 62},
+		{3, 16, 188},
+		{-1, 16, -62},
+		{-3, 16, -188},
+		{5, 10000, 0},
+		{15, 10000, 2},
+		{25, 10000, 2},
+	}
+
+	t.Log("Given the need to round shares of an amount without bias")
+	{
+		for testID, v := range vectors {
+			t.Logf("\t Test %d \t When taking %d/%d of %s", testID, v.num, v.den, usd)
+			{
+				got, err := usd.MulRat(v.num, v.den)
+				if err != nil {
+					t.Fatalf("\t %s \t Test %d \t Should be able to take the share: %v", failure, testID, err)
+				}
+
+				if got.Amount() != v.want {
+					t.Fatalf("\t %s \t Test %d \t Should get %d, got %d", failure, testID, v.want, got.Amount())
+				}
+				t.Logf("\t %s \t Test %d \t Should get %d", success, testID, v.want)
+			}
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	t.Log("Given the need to do exact arithmetic on amounts")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When adding and multiplying", testID)
+		{
+			price, err := New(1999, USD).Mul(3)
+			if err != nil || price.Amount() != 5997 {
+				t.Fatalf("\t %s \t Test %d \t Should multiply the price, got %v %v", failure, testID, price, err)
+			}
+
+			total, err := price.Add(New(3, USD))
+			if err != nil || total.String() != "60.00 USD" {
+				t.Fatalf("\t %s \t Test %d \t Should add the amounts, got %v %v", failure, testID, total, err)
+			}
+
+			less, err := total.Sub(New(6001, USD))
+			if err != nil || less.String() != "-0.01 USD" {
+				t.Fatalf("\t %s \t Test %d \t Should subtract the amounts, got %v %v", failure, testID, less, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should add up to the minor unit", success, testID)
+
+			if _, err := New(100, USD).Add(New(100, EUR)); !errors.Is(err, ErrMismatch) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse to mix currencies, got %v", failure, testID, err)
+			}
+			if _, err := New(100, USD).Cmp(New(100, EUR)); !errors.Is(err, ErrMismatch) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse to compare currencies, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse to mix currencies", success, testID)
+
+			if _, err := New(math.MaxInt64, USD).Add(New(1, USD)); !errors.Is(err, ErrOverflow) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse to overflow, got %v", failure, testID, err)
+			}
+			if _, err := New(math.MaxInt64/2+1, USD).Mul(2); !errors.Is(err, ErrOverflow) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse to overflow, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse to overflow", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When allocating an amount", testID)
+		{
+			vectors := []struct {
+				amount int64
+				ratios []int
+				want   []int64
+			}{
+				{100, []int{1, 1, 1}, []int64{34, 33, 33}},
+				{5, []int{3, 7}, []int64{2, 3}},
+				{-100, []int{1, 1, 1}, []int64{-34, -33, -33}},
+				{10, []int{0, 1, 1, 1}, []int64{0, 4, 3, 3}},
+			}
+
+			for _, v := range vectors {
+				parts, err := New(v.amount, USD).Allocate(v.ratios...)
+				if err != nil {
+					t.Fatalf("\t %s \t Test %d \t Should be able to allocate: %v", failure, testID, err)
+				}
+
+				for i := range v.want {
+					if parts[i].Amount() != v.want[i] {
+						t.Fatalf("\t %s \t Test %d \t Should split %d by %v into %v, got %v", failure, testID, v.amount, v.ratios, v.want, parts)
+					}
+				}
+			}
+			t.Logf("\t %s \t Test %d \t Should never lose a minor unit", success, testID)
+
+			if _, err := New(100, USD).Allocate(0, 0); err == nil {
+				t.Fatalf("\t %s \t Test %d \t Should refuse ratios adding up to zero", failure, testID)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse ratios adding up to zero", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When parsing decimal amounts", testID)
+		{
+			vectors := []struct {
+				in       string
+				currency Currency
+				want     string
+			}{
+				{"12.34", USD, "12.34 USD"},
+				{"0.125", USD, "0.12 USD"},
+				{"0.135", USD, "0.14 USD"},
+				{"-7.5", EUR, "-7.50 EUR"},
+				{"1500", JPY, "1500 JPY"},
+				{"2.5", JPY, "2 JPY"},
+				{"1.2345", "KWD", "1.234 KWD"},
+			}
+
+			for _, v := range vectors {
+				got, err := Parse(v.in, v.currency)
+				if err != nil || got.String() != v.want {
+					t.Fatalf("\t %s \t Test %d \t Should read %q as %s, got %v %v", failure, testID, v.in, v.want, got, err)
+				}
+			}
+			t.Logf("\t %s \t Test %d \t Should round to the minor unit of the currency", success, testID)
+
+			if _, err := Parse("1.00", "XYZ"); !errors.Is(err, ErrUnknownCurrency) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse an unknown currency, got %v", failure, testID, err)
+			}
+			if _, err := Parse("1e3", USD); err == nil {
+				t.Fatalf("\t %s \t Test %d \t Should refuse an exponent", failure, testID)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse what is not an amount", success, testID)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	t.Log("Given the need to store and send amounts")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When encoding JSON", testID)
+		{
+			data, err := json.Marshal(New(1234, EUR))
+			if err != nil || string(data) != `{"amount":1234,"currency":"EUR"}` {
+				t.Fatalf("\t %s \t Test %d \t Should encode minor units, got %s %v", failure, testID, data, err)
+			}
+
+			var m Money
+			if err := json.Unmarshal(data, &m); err != nil || !m.Equal(New(1234, EUR)) {
+				t.Fatalf("\t %s \t Test %d \t Should decode what it encodes, got %v %v", failure, testID, m, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should round trip through JSON", success, testID)
+
+			if err := json.Unmarshal([]byte(`{"amount":1,"currency":"usd"}`), &m); !errors.Is(err, ErrUnknownCurrency) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse an unknown currency, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse an unknown currency", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When storing in a database", testID)
+		{
+			v, err := New(-5, JPY).Value()
+			if err != nil || v != "-5 JPY" {
+				t.Fatalf("\t %s \t Test %d \t Should store the amount as text, got %v %v", failure, testID, v, err)
+			}
+
+			var m Money
+			if err := m.Scan([]byte("-5 JPY")); err != nil || !m.Equal(New(-5, JPY)) {
+				t.Fatalf("\t %s \t Test %d \t Should read back what it stores, got %v %v", failure, testID, m, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should round trip through a column", success, testID)
+
+			var c Currency
+			if err := c.Scan("gbp"); err != nil || c != GBP {
+				t.Fatalf("\t %s \t Test %d \t Should read a currency, got %v %v", failure, testID, c, err)
+			}
+			if _, err := Currency("").Value(); !errors.Is(err, ErrUnknownCurrency) {
+				t.Fatalf("\t %s \t Test %d \t Should never store an unknown currency, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should only store known currencies", success, testID)
+		}
+	}
+}
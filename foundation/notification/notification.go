@@ -0,0 +1,247 @@
+// Package notification sends messages to people outside the service. Email
+// is the only channel for now, delivered over SMTP in production and kept
+// in memory or written to files in tests and local development.
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is an email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// =============================================================================
+
+// SMTPConfig is what SMTP needs to reach the mail server.
+type SMTPConfig struct {
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP delivers messages through a mail server. Credentials are only sent
+// when a username is set, net/smtp refuses plain auth without TLS unless
+// the server is on localhost.
+type SMTP struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+}
+
+// NewSMTP constructs a mailer for the server at cfg.Host, given as
+// host:port.
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	host, _, err := net.SplitHostPort(cfg.Host)
+	if err != nil {
+		return nil, fmt.Errorf("parsing smtp host %q: %w", cfg.Host, err)
+	}
+
+	if cfg.From == "" {
+		return nil, errors.New("smtp from address is required")
+	}
+
+	s := SMTP{
+		cfg: cfg,
+	}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	return &s, nil
+}
+
+// Send delivers the message. net/smtp does not take a context, the
+// message is dropped on the floor if ctx is already done.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(s.cfg.Host, s.auth, s.cfg.From, []string{msg.To}, format(s.cfg.From, msg)); err != nil {
+		return fmt.Errorf("sending mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// =============================================================================
+
+// Memory keeps every message it is asked to send.
+type Memory struct {
+	mu   sync.Mutex
+	msgs []Message
+}
+
+// NewMemory constructs an empty memory mailer.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Send records the message.
+func (m *Memory) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.msgs = append(m.msgs, msg)
+	return nil
+}
+
+// Messages returns the messages sent to the recipient, oldest first.
+func (m *Memory) Messages(to string) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var msgs []Message
+	for _, msg := range m.msgs {
+		if msg.To == to {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// Count returns how many messages were sent in total.
+func (m *Memory) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.msgs)
+}
+
+// =============================================================================
+
+// File writes every message as an .eml file into a folder, handy to read
+// the emails a local deployment sends.
+type File struct {
+	folder string
+	from   string
+}
+
+// NewFile constructs a mailer writing into folder, creating it if needed.
+func NewFile(folder string, from string) (*File, error) {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, fmt.Errorf("creating mail folder: %w", err)
+	}
+
+	f := File{
+		folder: folder,
+		from:   from,
+	}
+	return &f, nil
+}
+
+// Send writes the message to <unix nano>-<recipient>.eml.
+func (f *File) Send(ctx context.Context, msg Message) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), sanitize(msg.To))
+
+	if err := os.WriteFile(filepath.Join(f.folder, name), format(f.from, msg), 0644); err != nil {
+		return fmt.Errorf("writing mail to %s: %w", msg.To, err)
+	}
+	return nil
+}
+
+// =============================================================================
+
+// ErrQueueFull is returned when a message does not fit in the queue.
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Queue hands messages to another mailer in the background, so callers
+// do not wait on the mail server. Failures to deliver can only be logged
+// by the error func, the caller is long gone by then.
+type Queue struct {
+	mailer  Mailer
+	msgs    chan Message
+	onError func(msg Message, err error)
+}
+
+// NewQueue constructs a queue holding up to size messages for mailer.
+func NewQueue(mailer Mailer, size int, onError func(msg Message, err error)) *Queue {
+	return &Queue{
+		mailer:  mailer,
+		msgs:    make(chan Message, size),
+		onError: onError,
+	}
+}
+
+// Send queues the message, it never blocks.
+func (q *Queue) Send(ctx context.Context, msg Message) error {
+	select {
+	case q.msgs <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Run delivers the queued messages until ctx is done, then delivers the
+// ones already queued before returning.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case msg := <-q.msgs:
+					q.deliver(msg)
+				default:
+					return
+				}
+			}
+
+		case msg := <-q.msgs:
+			q.deliver(msg)
+		}
+	}
+}
+
+// deliver sends the message without a deadline of its own, ctx of Run is
+// already done when draining the queue.
+func (q *Queue) deliver(msg Message) {
+	if err := q.mailer.Send(context.Background(), msg); err != nil && q.onError != nil {
+		q.onError(msg, err)
+	}
+}
+
+// =============================================================================
+
+// format renders the message as a plain text email. Line breaks are
+// removed from header values so they can not inject headers.
+func format(from string, msg Message) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "").Replace
+
+	var b strings.Builder
+	b.WriteString("From: " + header(from) + "\r\n")
+	b.WriteString("To: " + header(msg.To) + "\r\n")
+	b.WriteString("Subject: " + header(msg.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '@':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,95 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+func TestMailers(t *testing.T) {
+	msg := Message{
+		To:      "gopher@example.com",
+		Subject: "Hello\r\nBcc: everyone@example.com",
+		Body:    "line one\nline two",
+	}
+
+	t.Log("Given the need to deliver emails without a mail server")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When using the memory mailer", testID)
+		{
+			m := NewMemory()
+			if err := m.Send(context.Background(), msg); err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to send: %v", failure, testID, err)
+			}
+
+			if got := m.Messages(msg.To); len(got) != 1 || got[0] != msg {
+				t.Fatalf("\t %s \t Test %d \t Should keep the message, got %+v", failure, testID, got)
+			}
+			t.Logf("\t %s \t Test %d \t Should keep the message", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When using the file mailer", testID)
+		{
+			dir := t.TempDir()
+			f, err := NewFile(filepath.Join(dir, "mail"), "service@example.com")
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to construct: %v", failure, testID, err)
+			}
+
+			if err := f.Send(context.Background(), msg); err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to send: %v", failure, testID, err)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "mail", "*.eml"))
+			if len(files) != 1 {
+				t.Fatalf("\t %s \t Test %d \t Should write one file, got %d", failure, testID, len(files))
+			}
+			t.Logf("\t %s \t Test %d \t Should write one file", success, testID)
+
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to read the file: %v", failure, testID, err)
+			}
+
+			if strings.Contains(string(data), "\r\nBcc:") {
+				t.Fatalf("\t %s \t Test %d \t Should not allow header injection:\n%s", failure, testID, data)
+			}
+			t.Logf("\t %s \t Test %d \t Should not allow header injection", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When queueing messages", testID)
+		{
+			m := NewMemory()
+			q := NewQueue(m, 1, nil)
+
+			if err := q.Send(context.Background(), msg); err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to queue: %v", failure, testID, err)
+			}
+
+			if err := q.Send(context.Background(), msg); !errors.Is(err, ErrQueueFull) {
+				t.Fatalf("\t %s \t Test %d \t Should refuse a message that does not fit, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse a message that does not fit", success, testID)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			q.Run(ctx)
+
+			if got := m.Messages(msg.To); len(got) != 1 {
+				t.Fatalf("\t %s \t Test %d \t Should deliver the queued message when stopping, got %d", failure, testID, len(got))
+			}
+			t.Logf("\t %s \t Test %d \t Should deliver the queued message when stopping", success, testID)
+		}
+	}
+}
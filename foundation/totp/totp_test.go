@@ -0,0 +1,92 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+// rfcSecret is the SHA1 key used by the test vectors in RFC 6238.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+
+	// The RFC lists eight digit codes, ours are their last six digits.
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	t.Log("Given the need to generate the same codes as authenticator apps")
+	{
+		for testID, v := range vectors {
+			t.Logf("\t Test %d \t When generating the code at %d", testID, v.unix)
+			{
+				got, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+				if err != nil {
+					t.Fatalf("\t %s \t Test %d \t Should be able to generate a code: %v", failure, testID, err)
+				}
+
+				if got != v.code {
+					t.Fatalf("\t %s \t Test %d \t Should get %s, got %s", failure, testID, v.code, got)
+				}
+				t.Logf("\t %s \t Test %d \t Should get %s", success, testID, v.code)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("\t %s \t Should be able to generate a secret: %v", failure, err)
+	}
+	now := time.Now()
+
+	t.Log("Given the need to accept codes from slightly drifted clocks")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When the code is one step old", testID)
+		{
+			code, _ := Code(secret, Step(now)-1)
+
+			step, ok := Validate(secret, code, now, 1)
+			if !ok || step != Step(now)-1 {
+				t.Fatalf("\t %s \t Test %d \t Should accept it on the previous step", failure, testID)
+			}
+			t.Logf("\t %s \t Test %d \t Should accept it on the previous step", success, testID)
+
+			if _, ok := Validate(secret, code, now, 0); ok {
+				t.Fatalf("\t %s \t Test %d \t Should refuse it without skew", failure, testID)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse it without skew", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When building the otpauth uri", testID)
+		{
+			u, err := url.Parse(URI("Sales", "admin@example.com", secret))
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should build a valid uri: %v", failure, testID, err)
+			}
+
+			if u.Scheme != "otpauth" || u.Host != "totp" || u.Query().Get("secret") != secret {
+				t.Fatalf("\t %s \t Test %d \t Should carry the secret, got %s", failure, testID, u)
+			}
+			t.Logf("\t %s \t Test %d \t Should carry the secret", success, testID)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package vault looks up signing keys kept in a secrets service speaking
+// the Vault KV version 2 API. Keys are cached so validating a token does
+// not cost a round trip, and the token used to reach the service can be
+// kept alive for as long as the process runs.
+package vault
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"service/foundation/keystore"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned for a key id the service does not know.
+var ErrNotFound = errors.New("key not found")
+
+// Field is the name of the field of the secret holding the PEM encoded
+// private key.
+const Field = "private_key"
+
+// renewRetry is how long KeepAlive waits after a failed renewal.
+const renewRetry = 5 * time.Second
+
+// Config is what Vault needs to reach the service. Keys are read from
+// <Address>/v1/<MountPath>/data/<KeysPath>/<kid>.
+type Config struct {
+	Address    string
+	Token      string
+	MountPath  string
+	KeysPath   string
+	Passphrase []byte
+	CacheTTL   time.Duration
+	Client     *http.Client
+}
+
+// Vault implements auth.KeyLookup on top of the KV API.
+type Vault struct {
+	cfg    Config
+	base   *url.URL
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.RWMutex
+	cache map[string]entry
+}
+
+type entry struct {
+	key     *rsa.PrivateKey
+	fetched time.Time
+}
+
+// New constructs a Vault. The mount defaults to "secret", keys are cached
+// for five minutes unless told otherwise.
+func New(cfg Config) (*Vault, error) {
+	base, err := url.Parse(cfg.Address)
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("vault address %q is not a url", cfg.Address)
+	}
+
+	if cfg.Token == "" {
+		return nil, errors.New("vault token is required")
+	}
+
+	if cfg.MountPath == "" {
+		cfg.MountPath = "secret"
+	}
+
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = 5 * time.Minute
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	v := Vault{
+		cfg:    cfg,
+		base:   base,
+		client: client,
+		now:    time.Now,
+		cache:  make(map[string]entry),
+	}
+	return &v, nil
+}
+
+// PrivateKey returns the key from the cache, fetching it when it is
+// missing or older than the cache ttl. When the service can not be
+// reached the cached key keeps being used, a key the service no longer
+// has is dropped.
+func (v *Vault) PrivateKey(kid string) (*rsa.PrivateKey, error) {
+	v.mu.RLock()
+	e, cached := v.cache[kid]
+	v.mu.RUnlock()
+
+	if cached && v.now().Sub(e.fetched) < v.cfg.CacheTTL {
+		return e.key, nil
+	}
+
+	key, err := v.fetch(context.Background(), kid)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			v.mu.Lock()
+			delete(v.cache, kid)
+			v.mu.Unlock()
+			return nil, err
+		}
+
+		if cached {
+			return e.key, nil
+		}
+		return nil, err
+	}
+
+	v.mu.Lock()
+	v.cache[kid] = entry{key: key, fetched: v.now()}
+	v.mu.Unlock()
+
+	return key, nil
+}
+
+// PublicKey returns the public half of PrivateKey.
+func (v *Vault) PublicKey(kid string) (*rsa.PublicKey, error) {
+	key, err := v.PrivateKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	return &key.PublicKey, nil
+}
+
+// Renew extends the lease of the token and returns how long it now lasts.
+// A token that can not be renewed returns a zero duration.
+func (v *Vault) Renew(ctx context.Context) (time.Duration, error) {
+	var resp struct {
+		Auth struct {
+			LeaseDuration int  `json:"lease_duration"`
+			Renewable     bool `json:"renewable"`
+		} `json:"auth"`
+	}
+	if err := v.do(ctx, http.MethodPost, "auth/token/renew-self", &resp); err != nil {
+		return 0, fmt.Errorf("renewing token: %w", err)
+	}
+
+	if !resp.Auth.Renewable {
+		return 0, nil
+	}
+	return time.Duration(resp.Auth.LeaseDuration) * time.Second, nil
+}
+
+// KeepAlive renews the token halfway through each lease until ctx is done
+// or the token can not be renewed anymore. Failures are handed to onError
+// and retried shortly after.
+func (v *Vault) KeepAlive(ctx context.Context, onError func(err error)) {
+	for {
+		wait := renewRetry
+
+		lease, err := v.Renew(ctx)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			onError(err)
+		case lease == 0:
+			return
+		default:
+			wait = lease / 2
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// fetch reads and parses the key from the service.
+func (v *Vault) fetch(ctx context.Context, kid string) (*rsa.PrivateKey, error) {
+	if kid == "" || strings.Contains(kid, "/") || kid == ".." {
+		return nil, fmt.Errorf("kid %q: %w", kid, ErrNotFound)
+	}
+
+	path := strings.Trim(v.cfg.MountPath, "/") + "/data/"
+	if p := strings.Trim(v.cfg.KeysPath, "/"); p != "" {
+		path += p + "/"
+	}
+	path += url.PathEscape(kid)
+
+	var resp struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := v.do(ctx, http.MethodGet, path, &resp); err != nil {
+		return nil, fmt.Errorf("kid %q: %w", kid, err)
+	}
+
+	pem, ok := resp.Data.Data[Field]
+	if !ok {
+		return nil, fmt.Errorf("kid %q: secret has no %s field", kid, Field)
+	}
+
+	key, err := keystore.ParsePEM([]byte(pem), v.cfg.Passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("kid %q: %w", kid, err)
+	}
+	return key, nil
+}
+
+// do sends a request to the API and decodes the response into dest.
+func (v *Vault) do(ctx context.Context, method string, path string, dest any) error {
+	u := v.base.JoinPath("v1", path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", v.cfg.Token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("calling vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body := io.LimitReader(resp.Body, 1024*1024)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		msg, _ := io.ReadAll(body)
+		return fmt.Errorf("vault answered %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(body).Decode(dest); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
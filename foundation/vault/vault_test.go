@@ -0,0 +1,208 @@
+package vault
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"service/foundation/keystore"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+const token = "s.gophers"
+
+// stub is a local stand in for the KV API holding PEM encoded keys.
+type stub struct {
+	mu    sync.Mutex
+	keys  map[string]string
+	reads int
+	down  bool
+}
+
+// count returns how many keys were read.
+func (s *stub) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reads
+}
+
+// set changes the stub while holding the lock.
+func (s *stub) set(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn()
+}
+
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != token {
+		http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+		return
+	}
+
+	if s.down {
+		http.Error(w, `{"errors":["sealed"]}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/auth/token/renew-self":
+		json.NewEncoder(w).Encode(map[string]any{
+			"auth": map[string]any{"lease_duration": 3600, "renewable": true},
+		})
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/data/sales-api/keys/"):
+		s.reads++
+		key, ok := s.keys[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/sales-api/keys/")]
+		if !ok {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{"data": map[string]string{Field: key}},
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestVault(t *testing.T) {
+	plain, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t %s \t Should be able to generate a key: %v", failure, err)
+	}
+
+	encrypted, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("\t %s \t Should be able to generate a key: %v", failure, err)
+	}
+
+	block, err := keystore.EncryptPKCS8(encrypted, []byte("gophers"))
+	if err != nil {
+		t.Fatalf("\t %s \t Should be able to encrypt a key: %v", failure, err)
+	}
+
+	s := stub{
+		keys: map[string]string{
+			"plain":     string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(plain)})),
+			"encrypted": string(pem.EncodeToMemory(block)),
+		},
+	}
+	srv := httptest.NewServer(&s)
+	defer srv.Close()
+
+	v, err := New(Config{
+		Address:    srv.URL,
+		Token:      token,
+		KeysPath:   "sales-api/keys",
+		Passphrase: []byte("gophers"),
+		CacheTTL:   time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("\t %s \t Should be able to construct vault: %v", failure, err)
+	}
+
+	now := time.Date(2023, time.August, 1, 0, 0, 0, 0, time.UTC)
+	v.now = func() time.Time { return now }
+
+	t.Log("Given the need to keep signing keys in a secrets service")
+	{
+		testID := 0
+		t.Logf("\t Test %d \t When looking up keys", testID)
+		{
+			got, err := v.PrivateKey("plain")
+			if err != nil || !got.Equal(plain) {
+				t.Fatalf("\t %s \t Test %d \t Should return the plain key, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should return the plain key", success, testID)
+
+			pub, err := v.PublicKey("encrypted")
+			if err != nil || !pub.Equal(&encrypted.PublicKey) {
+				t.Fatalf("\t %s \t Test %d \t Should decrypt the encrypted key, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should decrypt the encrypted key", success, testID)
+
+			if _, err := v.PrivateKey("missing"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("\t %s \t Test %d \t Should not find an unknown key, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should not find an unknown key", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When caching keys", testID)
+		{
+			reads := s.count()
+			for i := 0; i < 3; i++ {
+				if _, err := v.PublicKey("plain"); err != nil {
+					t.Fatalf("\t %s \t Test %d \t Should return the key: %v", failure, testID, err)
+				}
+			}
+			if s.count() != reads {
+				t.Fatalf("\t %s \t Test %d \t Should serve the key from the cache, got %d reads", failure, testID, s.count()-reads)
+			}
+			t.Logf("\t %s \t Test %d \t Should serve the key from the cache", success, testID)
+
+			now = now.Add(2 * time.Minute)
+			s.set(func() { s.down = true })
+
+			if _, err := v.PublicKey("plain"); err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should keep using the key while the service is down: %v", failure, testID, err)
+			}
+			if s.count() != reads {
+				t.Fatalf("\t %s \t Test %d \t Should not count a failed read, got %d reads", failure, testID, s.count()-reads)
+			}
+			t.Logf("\t %s \t Test %d \t Should keep using the key while the service is down", success, testID)
+
+			s.set(func() {
+				s.down = false
+				delete(s.keys, "plain")
+			})
+
+			if _, err := v.PublicKey("plain"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("\t %s \t Test %d \t Should drop a key the service no longer has, got %v", failure, testID, err)
+			}
+			if s.count() != reads+1 {
+				t.Fatalf("\t %s \t Test %d \t Should fetch the key once the cache is stale, got %d reads", failure, testID, s.count()-reads)
+			}
+			t.Logf("\t %s \t Test %d \t Should drop a key the service no longer has", success, testID)
+		}
+
+		testID++
+		t.Logf("\t Test %d \t When renewing the token", testID)
+		{
+			lease, err := v.Renew(context.Background())
+			if err != nil || lease != time.Hour {
+				t.Fatalf("\t %s \t Test %d \t Should renew the token, got %v %v", failure, testID, lease, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should renew the token", success, testID)
+
+			bad, err := New(Config{Address: srv.URL, Token: "s.rabbits"})
+			if err != nil {
+				t.Fatalf("\t %s \t Test %d \t Should be able to construct vault: %v", failure, testID, err)
+			}
+
+			if _, err := bad.Renew(context.Background()); err == nil || !strings.Contains(err.Error(), "403") {
+				t.Fatalf("\t %s \t Test %d \t Should refuse an unknown token, got %v", failure, testID, err)
+			}
+			t.Logf("\t %s \t Test %d \t Should refuse an unknown token", success, testID)
+		}
+	}
+}
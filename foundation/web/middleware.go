@@ -2,12 +2,14 @@ package web
 
 type MiddlewareFunc func(h HandlerFunc) HandlerFunc
 
+// wrapMiddlewares wraps h with the middlewares in reverse order so the
+// first middleware in the slice is the first one to execute.
 func wrapMiddlewares(mw []MiddlewareFunc, h HandlerFunc) HandlerFunc {
 
-	for i := 0; i < len(mw); i++ {
+	for i := len(mw) - 1; i >= 0; i-- {
 
 		if mw[i] != nil {
-			mw[i](h)
+			h = mw[i](h)
 		}
 	}
 	return h
@@ -2,8 +2,11 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/dimfeld/httptreemux"
+	"net"
 	"net/http"
+	"strings"
 )
 
 func Param(r *http.Request, key string) string {
@@ -19,3 +22,89 @@ func Decode(r *http.Request, val any) error {
 	}
 	return nil
 }
+
+// RemoteIP returns the address the request came from. Forwarding headers
+// are not trusted, they are set by the client, see Proxies.ClientIP for
+// services behind a proxy.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Proxies are the networks of the proxies in front of the service, the only
+// peers trusted to say who the client is.
+type Proxies []*net.IPNet
+
+// ParseProxies parses the addresses or CIDRs of the trusted proxies.
+func ParseProxies(cidrs []string) (Proxies, error) {
+	var p Proxies
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("parsing proxy %q: invalid address", cidr)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			p = append(p, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy %q: %w", cidr, err)
+		}
+		p = append(p, n)
+	}
+	return p, nil
+}
+
+// ClientIP returns the address of the client. When the request came from
+// a trusted proxy X-Forwarded-For is read from the right, every address a
+// trusted proxy added is skipped and the first one left is the client.
+// Whatever is further left was written by the client itself.
+func (p Proxies) ClientIP(r *http.Request) string {
+	addr := RemoteIP(r)
+	if len(p) == 0 {
+		return addr
+	}
+
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0 && p.trusts(addr); i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		addr = hop
+	}
+	return addr
+}
+
+func (p Proxies) trusts(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range p {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
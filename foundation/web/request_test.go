@@ -0,0 +1,53 @@
+package web
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failure = "\u2717"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("\t %s \t Should be able to parse the proxies: %v", failure, err)
+	}
+
+	tt := []struct {
+		name    string
+		proxies Proxies
+		remote  string
+		xff     []string
+		exp     string
+	}{
+		{"no proxies", nil, "10.0.0.1:1234", []string{"1.2.3.4"}, "10.0.0.1"},
+		{"untrusted peer", proxies, "8.8.8.8:1234", []string{"1.2.3.4"}, "8.8.8.8"},
+		{"one proxy", proxies, "10.0.0.1:1234", []string{"1.2.3.4"}, "1.2.3.4"},
+		{"chain of proxies", proxies, "10.0.0.1:1234", []string{"1.2.3.4, 192.168.1.1", "10.1.1.1"}, "1.2.3.4"},
+		{"spoofed by the client", proxies, "10.0.0.1:1234", []string{"6.6.6.6, 1.2.3.4"}, "1.2.3.4"},
+		{"garbage", proxies, "10.0.0.1:1234", []string{"1.2.3.4, nonsense"}, "10.0.0.1"},
+		{"no header", proxies, "10.0.0.1:1234", nil, "10.0.0.1"},
+	}
+
+	t.Log("Given the need to know who the client is behind a proxy")
+	{
+		for testID, tc := range tt {
+			t.Logf("\t Test %d \t When the request is %s", testID, tc.name)
+			{
+				r := httptest.NewRequest("GET", "/", nil)
+				r.RemoteAddr = tc.remote
+				for _, h := range tc.xff {
+					r.Header.Add("X-Forwarded-For", h)
+				}
+
+				if got := tc.proxies.ClientIP(r); got != tc.exp {
+					t.Fatalf("\t %s \t Test %d \t Should see the client as %s, got %s", failure, testID, tc.exp, got)
+				}
+				t.Logf("\t %s \t Test %d \t Should see the client as %s", success, testID, tc.exp)
+			}
+		}
+	}
+}
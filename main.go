@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ardanlabs/conf"
+	"github.com/jmoiron/sqlx"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/zipkin"
@@ -15,14 +16,25 @@ import (
 	"go.uber.org/zap"
 	defaultLog "log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"runtime"
 	"service/app/services/sales-api/handlers"
+	"service/domain/core/apikey"
+	"service/domain/core/inventory"
+	"service/domain/core/lockout"
+	"service/domain/core/mfa"
+	"service/domain/core/reset"
+	productStore "service/domain/data/store/product"
 	"service/domain/sys/auth"
 	"service/domain/sys/database"
+	"service/foundation/health"
 	"service/foundation/keystore"
 	"service/foundation/logger"
+	"service/foundation/notification"
+	"service/foundation/vault"
+	"service/foundation/web"
 	"syscall"
 	"time"
 )
@@ -41,22 +53,21 @@ func main() {
 	defer zapLogger.Sync()
 
 	ctx := context.Background()
-	run(ctx, zapLogger)
-
-	//Catching Signals from k8s or your deployment Environment
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
-	<-shutdown
+	if err := run(ctx, zapLogger); err != nil {
+		zapLogger.Errorw("startup", "ERROR", err)
+		zapLogger.Sync()
+		os.Exit(1)
+	}
 }
 
-func run(ctx context.Context, log *zap.SugaredLogger) {
+func run(ctx context.Context, log *zap.SugaredLogger) error {
 
 	// =================================== GOMAXPROC
 	//Sets the Correct Number For The Service
 	//based on what is available either by the machine or quotas
 
 	if _, err := maxprocs.Set(); err != nil {
-		defaultLog.Fatalf("%s", err.Error())
+		return fmt.Errorf("maxprocs: %w", err)
 	}
 	log.Infow("startup", "GOMAXPROCS", runtime.GOMAXPROCS(0))
 
@@ -69,10 +80,28 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 			WriteTimeout    time.Duration `conf:"default:10s"`
 			IdleTimeout     time.Duration `conf:"default:120s"`
 			ShutDownTimeout time.Duration `conf:"default:20s"`
+			TrustedProxies  []string      `conf:"help:addresses or CIDRs of the proxies allowed to set X-Forwarded-For separated by ';'"`
 		}
 		Auth struct {
-			KeysFolder string `conf:"default:/zarf/keys/"`
-			ActiveKID  string `conf:"default:private.pem"`
+			KeysFolder string        `conf:"default:zarf/keys/"`
+			ActiveKID  string        `conf:"default:private"`
+			Issuer     string        `conf:"default:service project"`
+			Audiences  []string      `conf:"help:accepted audiences separated by ';' the first one is issued"`
+			TTL        time.Duration `conf:"default:1h"`
+			Leeway     time.Duration `conf:"default:30s"`
+			// Encrypted keys are decrypted with the passphrase, read from
+			// the file when one is given.
+			Passphrase     string `conf:"mask"`
+			PassphraseFile string
+			// Keys are read from Vault instead of the keys folder when an
+			// address is set.
+			Vault struct {
+				Address   string
+				Token     string        `conf:"mask"`
+				MountPath string        `conf:"default:secret"`
+				KeysPath  string        `conf:"default:sales-api/keys"`
+				CacheTTL  time.Duration `conf:"default:5m"`
+			}
 		}
 		DB struct {
 			User         string `conf:"default:postgres"`
@@ -93,6 +122,43 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 			ServiceName string  `conf:"default:sales-api"`
 			Probability float64 `conf:"default:0.05"`
 		}
+		Mail struct {
+			Host     string `conf:"default:localhost:1025"`
+			Username string
+			Password string `conf:"mask"`
+			From     string `conf:"default:no-reply@sales.example.com"`
+			Folder   string `conf:"help:write emails into this folder instead of sending them"`
+			Queue    int    `conf:"default:100,help:how many emails can wait to be sent"`
+		}
+		Reset struct {
+			URL string        `conf:"default:http://localhost:3000/reset-password"`
+			TTL time.Duration `conf:"default:30m"`
+		}
+		Lockout struct {
+			Threshold   int           `conf:"default:5"`
+			IPThreshold int           `conf:"default:50"`
+			Window      time.Duration `conf:"default:15m"`
+			LockFor     time.Duration `conf:"default:15m"`
+			BaseDelay   time.Duration `conf:"default:250ms"`
+			MaxDelay    time.Duration `conf:"default:4s"`
+		}
+		MFA struct {
+			Issuer          string        `conf:"default:sales-api"`
+			RequireForAdmin bool          `conf:"default:false"`
+			ChallengeTTL    time.Duration `conf:"default:5m"`
+		}
+		APIKey struct {
+			RotationOverlap time.Duration `conf:"default:24h"`
+		}
+		Health struct {
+			CacheTTL     time.Duration `conf:"default:2s"`
+			CheckTimeout time.Duration `conf:"default:1s"`
+		}
+		Inventory struct {
+			AlertEmail    string        `conf:"help:mail low stock alerts to this address instead of logging them"`
+			CheckInterval time.Duration `conf:"default:5m"`
+			Queue         int           `conf:"default:100"`
+		}
 	}{
 		Version: conf.Version{
 			SVN:  build,
@@ -106,10 +172,9 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 	if err != nil {
 		if errors.Is(err, conf.ErrHelpWanted) {
 			fmt.Println(help)
-			return
+			return nil
 		}
-		fmt.Errorf("parse config error: %w", err)
-		return
+		return fmt.Errorf("parse config error: %w", err)
 	}
 
 	// =================================== App Starting
@@ -119,24 +184,62 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 
 	out, err := conf.String(&cfg)
 	if err != nil {
-		fmt.Errorf("config generation failed: %w", err)
-		return
+		return fmt.Errorf("config generation failed: %w", err)
 	}
 	log.Infow("startup", "config", out)
 
 	// =================================== Initialize Authentication Support
 	log.Infow("startup", "status", "initializing authentication support")
 
-	ks, err := keystore.NewFs(os.DirFS(cfg.Auth.KeysFolder))
-	if err != nil {
-		fmt.Errorf("error while reading keys: %w", err)
-		return
+	passphrase := []byte(cfg.Auth.Passphrase)
+	if cfg.Auth.PassphraseFile != "" {
+		passphrase, err = keystore.ReadPassphrase(cfg.Auth.PassphraseFile)
+		if err != nil {
+			return err
+		}
 	}
 
-	newAuth, err := auth.New(cfg.Auth.ActiveKID, ks)
+	var ks auth.KeyLookup
+	switch cfg.Auth.Vault.Address {
+	case "":
+		fsKeys, err := keystore.NewFsPassphrase(os.DirFS(cfg.Auth.KeysFolder), passphrase)
+		if err != nil {
+			return fmt.Errorf("error while reading keys: %w", err)
+		}
+		ks = fsKeys
+
+	default:
+		log.Infow("startup", "status", "reading keys from vault", "address", cfg.Auth.Vault.Address)
+
+		vaultKeys, err := vault.New(vault.Config{
+			Address:    cfg.Auth.Vault.Address,
+			Token:      cfg.Auth.Vault.Token,
+			MountPath:  cfg.Auth.Vault.MountPath,
+			KeysPath:   cfg.Auth.Vault.KeysPath,
+			Passphrase: passphrase,
+			CacheTTL:   cfg.Auth.Vault.CacheTTL,
+		})
+		if err != nil {
+			return fmt.Errorf("constructing vault: %w", err)
+		}
+
+		renewCtx, cancelRenew := context.WithCancel(ctx)
+		defer cancelRenew()
+
+		go vaultKeys.KeepAlive(renewCtx, func(err error) {
+			log.Errorw("vault", "status", "renewing token", "ERROR", err)
+		})
+		ks = vaultKeys
+	}
+
+	newAuth, err := auth.New(cfg.Auth.ActiveKID, ks,
+		auth.WithIssuer(cfg.Auth.Issuer),
+		auth.WithAudience(cfg.Auth.Audiences...),
+		auth.WithTTL(cfg.Auth.TTL),
+		auth.WithLeeway(cfg.Auth.Leeway),
+	)
 	if err != nil {
-		fmt.Errorf("constructing auth: %w", err)
-		return
+		return fmt.Errorf("constructing auth: %w", err)
 	}
 
 	// =================================== DB Support
@@ -152,8 +255,7 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 	}
 	db, err := database.Open(cfgDB)
 	if err != nil {
-		fmt.Errorf("connecting to db : %w", err)
-		return
+		return fmt.Errorf("connecting to db : %w", err)
 	}
 
 	defer func() {
@@ -170,8 +272,7 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 	)
 
 	if err != nil {
-		fmt.Errorf("starting traceing system : %w", err)
-		return
+		return fmt.Errorf("starting traceing system : %w", err)
 	}
 
 	defer func() {
@@ -179,10 +280,18 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 		traceProvider.Shutdown(context.Background())
 	}()
 
+	// =================================== Health Check Support
+	log.Infow("startup", "status", "initializing health checks")
+
+	checks, err := healthChecks(cfg.Health.CacheTTL, cfg.Health.CheckTimeout, db, ks, cfg.Auth.ActiveKID, cfg.Zipkin.ReporterURI)
+	if err != nil {
+		return fmt.Errorf("registering health checks: %w", err)
+	}
+
 	// =================================== Start Debug Service
 	log.Infow("startup", "status", "debug router started", "host", cfg.Web.DebugHost)
 
-	debugMux := handlers.DebugMux(build, log, db)
+	debugMux := handlers.DebugMux(build, log, checks)
 
 	go func() {
 		if err := http.ListenAndServe(cfg.Web.DebugHost, debugMux); err != nil {
@@ -190,20 +299,94 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 		}
 	}()
 
+	// =================================== Mail Support
+	log.Infow("startup", "status", "initializing mail support", "host", cfg.Mail.Host, "folder", cfg.Mail.Folder)
+
+	mailer, err := newMailer(cfg.Mail.Host, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.From, cfg.Mail.Folder)
+	if err != nil {
+		return fmt.Errorf("constructing mailer: %w", err)
+	}
+
+	// Requests only queue their emails, how long the mail server takes to
+	// answer must not show in the response time of the reset endpoint.
+	mailQueue := notification.NewQueue(mailer, cfg.Mail.Queue, func(msg notification.Message, err error) {
+		log.Errorw("mail", "status", "sending queued email", "subject", msg.Subject, "ERROR", err)
+	})
+
+	mailCtx, cancelMail := context.WithCancel(ctx)
+	defer cancelMail()
+
+	mailDone := make(chan struct{})
+	go func() {
+		defer close(mailDone)
+		mailQueue.Run(mailCtx)
+	}()
+
+	// =================================== Low Stock Support
+	log.Infow("startup", "status", "initializing low stock checker", "interval", cfg.Inventory.CheckInterval)
+
+	var notifier inventory.Notifier = inventory.NewLogNotifier(log)
+	if cfg.Inventory.AlertEmail != "" {
+		notifier = inventory.NewMailNotifier(mailer, cfg.Inventory.AlertEmail)
+	}
+
+	checker := inventory.NewChecker(log, productStore.NewStore(log, db), notifier, inventory.CheckerConfig{
+		Interval: cfg.Inventory.CheckInterval,
+		Queue:    cfg.Inventory.Queue,
+	})
+
+	checkCtx, cancelCheck := context.WithCancel(ctx)
+	defer cancelCheck()
+
+	checkDone := make(chan struct{})
+	go func() {
+		defer close(checkDone)
+		checker.Run(checkCtx)
+	}()
+
 	// -------------------------------------------------------------------------
 	// Start API Service
 
-	log.Info(ctx, "startup", "status", "initializing V1 API support")
+	log.Infow("startup", "status", "initializing V1 API support")
 
+	//Catching Signals from k8s or your deployment Environment
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 
+	proxies, err := web.ParseProxies(cfg.Web.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("parsing trusted proxies: %w", err)
+	}
+
 	cfgMux := handlers.APIMuxConfig{
 		Build:    build,
 		Shutdown: shutdown,
 		Log:      log,
 		Auth:     newAuth,
 		DB:       db,
+		Mailer:   mailQueue,
+		LowStock: checker,
+		Proxies:  proxies,
+		Reset: reset.Config{
+			TTL: cfg.Reset.TTL,
+			URL: cfg.Reset.URL,
+		},
+		Lockout: lockout.Config{
+			Threshold:   cfg.Lockout.Threshold,
+			IPThreshold: cfg.Lockout.IPThreshold,
+			Window:      cfg.Lockout.Window,
+			LockFor:     cfg.Lockout.LockFor,
+			BaseDelay:   cfg.Lockout.BaseDelay,
+			MaxDelay:    cfg.Lockout.MaxDelay,
+		},
+		MFA: mfa.Config{
+			Issuer:          cfg.MFA.Issuer,
+			RequireForAdmin: cfg.MFA.RequireForAdmin,
+			ChallengeTTL:    cfg.MFA.ChallengeTTL,
+		},
+		APIKey: apikey.Config{
+			RotationOverlap: cfg.APIKey.RotationOverlap,
+		},
 		//Tracer:   tracer,
 	}
 	apiMux := handlers.AppAPIMux(cfgMux) //, handlers.WithCORS("*"))
@@ -217,38 +400,122 @@ func run(ctx context.Context, log *zap.SugaredLogger) {
 		//	ErrorLog:     defaultLog.NewStdLogger(log, logger.LevelError),
 	}
 
-	//serverErrors := make(chan error, 1)
+	serverErrors := make(chan error, 1)
 
 	go func() {
-		log.Info(ctx, "startup", "status", "api router started", "host", api.Addr)
+		log.Infow("startup", "status", "api router started", "host", api.Addr)
 
-		//serverErrors <- api.ListenAndServe()
+		serverErrors <- api.ListenAndServe()
 	}()
 
-	/*
-		// -------------------------------------------------------------------------
-		// Shutdown
+	// -------------------------------------------------------------------------
+	// Shutdown
+
+	select {
+	case err := <-serverErrors:
+		return fmt.Errorf("server error: %w", err)
+
+	case sig := <-shutdown:
+		log.Infow("shutdown", "status", "shutdown started", "signal", sig)
+		defer log.Infow("shutdown", "status", "shutdown complete", "signal", sig)
 
-		select {
-		case err := <-serverErrors:
-			return fmt.Errorf("server error: %w", err)
+		ctx, cancel := context.WithTimeout(ctx, cfg.Web.ShutDownTimeout)
+		defer cancel()
 
-		case sig := <-shutdown:
-			log.Info(ctx, "shutdown", "status", "shutdown started", "signal", sig)
-			defer log.Info(ctx, "shutdown", "status", "shutdown complete", "signal", sig)
+		if err := api.Shutdown(ctx); err != nil {
+			api.Close()
+			return fmt.Errorf("could not stop server gracefully: %w", err)
+		}
+
+		// No sale can reach the checker anymore, let it finish the check
+		// it is on before the database goes away.
+		cancelCheck()
+		<-checkDone
 
-			ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
-			defer cancel()
+		// No request can queue an email anymore, send the ones left.
+		cancelMail()
+		<-mailDone
+	}
+
+	return nil
+}
 
-			if err := api.Shutdown(ctx); err != nil {
-				api.Close()
-				return fmt.Errorf("could not stop server gracefully: %w", err)
+// healthChecks registers the dependencies the readiness probe reports on.
+// The database and the signing key are critical, without them we can not
+// serve a single request. Zipkin going away only costs us traces.
+func healthChecks(cacheTTL time.Duration, timeout time.Duration, db *sqlx.DB, ks auth.KeyLookup, activeKID string, reporterURI string) (*health.Registry, error) {
+	reg := health.New(cacheTTL)
+
+	dbCheck := health.Check{
+		Name:     "database",
+		Timeout:  timeout,
+		Critical: true,
+		Fn: func(ctx context.Context) error {
+			return database.StatusCheck(ctx, db)
+		},
+	}
+
+	keyCheck := health.Check{
+		Name:     "keystore",
+		Timeout:  timeout,
+		Critical: true,
+		Fn: func(ctx context.Context) error {
+			if _, err := ks.PrivateKey(activeKID); err != nil {
+				return fmt.Errorf("active kid %q: %w", activeKID, err)
+			}
+			return nil
+		},
+	}
+
+	zipkinCheck := health.Check{
+		Name:    "zipkin",
+		Timeout: timeout,
+		Fn: func(ctx context.Context) error {
+			u, err := url.Parse(reporterURI)
+			if err != nil {
+				return fmt.Errorf("parsing reporter uri: %w", err)
 			}
+			u.Path = "/health"
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+			if err != nil {
+				return err
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("unexpected status %d", resp.StatusCode)
+			}
+			return nil
+		},
+	}
+
+	for _, c := range []health.Check{dbCheck, keyCheck, zipkinCheck} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
 		}
+	}
+	return reg, nil
+}
 
-		return nil */
+// newMailer sends emails through the SMTP server unless a folder is set,
+// local environments usually have no mail server to talk to.
+func newMailer(host string, username string, password string, from string, folder string) (notification.Mailer, error) {
+	if folder != "" {
+		return notification.NewFile(folder, from)
+	}
 
-	return
+	return notification.NewSMTP(notification.SMTPConfig{
+		Host:     host,
+		Username: username,
+		Password: password,
+		From:     from,
+	})
 }
 
 func startTracing(serviceName string, reporterURI string, probability float64) (*trace.TracerProvider, error) {
@@ -0,0 +1,17 @@
+// Package commands contains the functionality for the set of commands
+// currently supported by the admin tool.
+package commands
+
+import (
+	"errors"
+	"service/domain/sys/auth"
+)
+
+// ErrHelp provides context that help was given.
+var ErrHelp = errors.New("provided help")
+
+// adminClaims are used when the tool talks to the stores directly,
+// whoever runs the admin tool already owns the database.
+var adminClaims = auth.Claims{
+	Roles: []string{auth.RoleAdmin},
+}
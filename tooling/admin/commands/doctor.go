@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"service/domain/data/doctor"
+	"service/domain/sys/database"
+	"time"
+)
+
+// ErrDrift is returned when the database does not match the store models.
+var ErrDrift = errors.New("schema drift detected")
+
+// Doctor compares the tables in the database with the store models and
+// prints every difference found.
+func Doctor(cfg database.Config) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := database.StatusCheck(ctx, db); err != nil {
+		return fmt.Errorf("status check database: %w", err)
+	}
+
+	drifts, err := doctor.Check(ctx, db, doctor.Models)
+	if err != nil {
+		return fmt.Errorf("checking schema: %w", err)
+	}
+
+	if len(drifts) == 0 {
+		fmt.Println("no drift found")
+		return nil
+	}
+
+	for _, d := range drifts {
+		fmt.Println(d)
+	}
+	return fmt.Errorf("%w: %d problems", ErrDrift, len(drifts))
+}
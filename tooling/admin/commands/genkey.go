@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"service/domain/sys/validate"
+	"service/foundation/keystore"
+)
+
+// GenKey creates a new private key inside of the keys folder, the file is
+// named after a freshly generated key id. The public key is written to
+// stdout so it can be handed to whoever validates our tokens. With a
+// passphrase the key is written as encrypted PKCS#8.
+func GenKey(keysFolder string, passphrase []byte) error {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("generating key: %w", err)
+	}
+
+	kid := validate.GenerateUID()
+	name := filepath.Join(keysFolder, kid+".pem")
+
+	privateBlock := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}
+
+	if len(passphrase) > 0 {
+		privateBlock, err = keystore.EncryptPKCS8(privateKey, passphrase)
+		if err != nil {
+			return fmt.Errorf("encrypting private key: %w", err)
+		}
+	}
+
+	privateFile, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("creating private file: %w", err)
+	}
+	defer privateFile.Close()
+
+	if err := pem.Encode(privateFile, privateBlock); err != nil {
+		return fmt.Errorf("encoding to private file: %w", err)
+	}
+
+	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return fmt.Errorf("marshaling public key: %w", err)
+	}
+
+	publicBlock := pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: asn1Bytes,
+	}
+
+	fmt.Println("KID:", kid)
+	fmt.Println("private key written to", name)
+
+	if err := pem.Encode(os.Stdout, &publicBlock); err != nil {
+		return fmt.Errorf("encoding public key: %w", err)
+	}
+	return nil
+}
@@ -1,64 +1,80 @@
 package commands
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"os"
+	"service/domain/core/role"
+	"service/domain/core/user"
+	roleStore "service/domain/data/store/role"
+	userStore "service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"service/foundation/keystore"
+	"time"
 )
 
-var ErrHelp = errors.New("Help")
-
-/*
-func GenToken(logger *zap.SugaredLogger, cfg database.config, userID string, kid string) error {
-	if userID == '' || kid == '' {
+// GenToken generates a JWT for the specified user signed with the key kid.
+// The options must match the ones of the sales-api for it to accept the
+// token, the token expires after the TTL they set.
+func GenToken(log *zap.SugaredLogger, cfg database.Config, keysFolder string, passphrase []byte, opts []auth.Option, userID string, kid string) error {
+	if userID == "" || kid == "" {
 		fmt.Println("help: gentoken <user_id> <kid>")
 		return ErrHelp
 	}
-	db, err := database.open(cfg)
+
+	db, err := database.Open(cfg)
 	if err != nil {
-		return fmt.Errorf("connect db err: %w", err)
+		return fmt.Errorf("connect database: %w", err)
 	}
 	defer db.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	store := user.NewUser(log, db)
+	core := user.NewCore(log, userStore.NewStore(log, db))
 
-	claims := auth.Claims{
-		StandardClaims: jwt.StandardClaims{
-			Subject: userID,
-		},
-		Roles: []string{auth.RoleAdmin},
+	usr, err := core.QueryByID(ctx, adminClaims, userID)
+	if err != nil {
+		return fmt.Errorf("retrieve user: %w", err)
 	}
 
-	usr, err := store.QueryById(ctx, claims, userID)
+	// Routes check the permissions carried by the token, they are resolved
+	// from the roles the same way a login does.
+	perms, err := role.NewCore(log, roleStore.NewStore(log, db)).Permissions(ctx, usr.Roles)
 	if err != nil {
-		return fmt.Errorf("retrieve user error %w", err)
+		return fmt.Errorf("resolving permissions: %w", err)
 	}
 
-	keysFolder := "/zarf/keys/"
-	ks, err := keystore.NewFS(os.DirFS(keysFolder))
+	ks, err := keystore.NewFsPassphrase(os.DirFS(keysFolder), passphrase)
 	if err != nil {
-		return fmt.Errorf("reading key error %w", err)
+		return fmt.Errorf("reading keys: %w", err)
 	}
 
-	activeKID := "54bb2165-71e1-41a6-af3e-7da4a0e1e2c1"
-	a, err := auth.New(activeKID, ks)
+	a, err := auth.New(kid, ks, opts...)
 	if err != nil {
-		return fmt.Errorf("constructing auth error %w", err)
+		return fmt.Errorf("constructing auth: %w", err)
 	}
 
+	// Generating a token requires defining a set of claims. In this applications
+	// case, we only care about defining the subject and the user in question and
+	// the roles they have on the database along with their permissions. The
+	// issue time and expiry are left to auth.
 	claims := auth.Claims{
-		StandardClaim: jwt.StandardClaims{
-			Issuer:    "service project",
-			Subject:   usr.ID,
-			ExpiresAt: time.Now().Add(7860 * time.Hour).Unix(),
-			IssuedAt:  time.Now().UTC().Unix(),
+		StandardClaims: jwt.StandardClaims{
+			Subject: usr.ID,
 		},
-		Roles: usr.Roles,
+		Roles:       usr.Roles,
+		Permissions: perms,
 	}
 
-	token, err := a.GenerateKey(claims)
+	token, err := a.GenerateToken(claims)
 	if err != nil {
-		return fmt.Errorf("constructing auth error %w", err)
+		return fmt.Errorf("generating token: %w", err)
 	}
+
+	fmt.Printf("-----BEGIN TOKEN-----\n%s\n-----END TOKEN-----\n", token)
 	return nil
-} */
+}
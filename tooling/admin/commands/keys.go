@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"service/foundation/keystore"
+	"text/tabwriter"
+)
+
+// KeysList prints the key ids found in the keys folder along with the
+// fingerprint of their public key, marking the active one.
+func KeysList(keysFolder string, activeKID string, passphrase []byte) error {
+	ks, err := keystore.NewFsPassphrase(os.DirFS(keysFolder), passphrase)
+	if err != nil {
+		return fmt.Errorf("reading keys: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KID\tFINGERPRINT\tACTIVE")
+	for _, kid := range ks.KIDs() {
+		pub, err := ks.PublicKey(kid)
+		if err != nil {
+			return fmt.Errorf("public key %q: %w", kid, err)
+		}
+
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return fmt.Errorf("marshaling public key %q: %w", kid, err)
+		}
+
+		fmt.Fprintf(tw, "%s\tSHA256:%x\t%t\n", kid, sha256.Sum256(der), kid == activeKID)
+	}
+	return tw.Flush()
+}
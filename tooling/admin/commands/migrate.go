@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"service/domain/data/schema"
+	"service/domain/sys/database"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Migrate creates the schema in the database. With dryRun set the pending
+// migrations are printed instead of applied.
+func Migrate(cfg database.Config, dryRun bool) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if dryRun {
+		if err := database.StatusCheck(ctx, db); err != nil {
+			return fmt.Errorf("status check database: %w", err)
+		}
+
+		pending, err := schema.Pending(ctx, db)
+		if err != nil {
+			return fmt.Errorf("pending migrations: %w", err)
+		}
+
+		if len(pending) == 0 {
+			fmt.Println("no pending migrations")
+			return nil
+		}
+
+		for _, m := range pending {
+			fmt.Printf("-- Version: %v\n-- Description: %s\n%s\n", m.Version, m.Description, m.Up)
+		}
+		return nil
+	}
+
+	if err := schema.Migrate(ctx, db); err != nil {
+		return fmt.Errorf("migrate database: %w", err)
+	}
+
+	fmt.Println("migrations complete")
+	return nil
+}
+
+// MigrateStatus prints every migration with its status and checksums.
+func MigrateStatus(cfg database.Config) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := database.StatusCheck(ctx, db); err != nil {
+		return fmt.Errorf("status check database: %w", err)
+	}
+
+	statuses, err := schema.Status(ctx, db)
+	if err != nil {
+		return fmt.Errorf("migration status: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATUS\tCHECKSUM\tAPPLIED CHECKSUM\tAPPLIED AT\tDOWN\tDESCRIPTION")
+	for _, s := range statuses {
+		var appliedAt string
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%v\t%s\t%s\t%s\t%s\t%t\t%s\n", s.Version, s.Status, s.Checksum, s.AppliedChecksum, appliedAt, s.HasDown, s.Description)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, s := range statuses {
+		if s.Status == schema.StatusModified || s.Status == schema.StatusRemoved {
+			return schema.ErrMigrationModified
+		}
+	}
+	return nil
+}
+
+// MigrateDown rolls back the last applied migrations, one by default.
+// With dryRun set the down scripts are printed instead of executed.
+func MigrateDown(cfg database.Config, steps string, dryRun bool) error {
+	n := 1
+	if steps != "" {
+		v, err := strconv.Atoi(steps)
+		if err != nil || v < 1 {
+			fmt.Println("help: migrate down [steps] [--dry-run]")
+			return ErrHelp
+		}
+		n = v
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := database.StatusCheck(ctx, db); err != nil {
+		return fmt.Errorf("status check database: %w", err)
+	}
+
+	if dryRun {
+		plan, err := schema.RollbackPlan(ctx, db, n)
+		if err != nil {
+			return fmt.Errorf("rollback plan: %w", err)
+		}
+
+		for _, m := range plan {
+			fmt.Printf("-- Version: %v\n-- Description: %s\n%s\n", m.Version, m.Description, m.Down)
+		}
+		return nil
+	}
+
+	done, err := schema.Rollback(ctx, db, n)
+	for _, m := range done {
+		fmt.Printf("rolled back %v %s\n", m.Version, m.Description)
+	}
+	if err != nil {
+		return fmt.Errorf("rollback: %w", err)
+	}
+	return nil
+}
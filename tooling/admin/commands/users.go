@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"service/domain/core/user"
+	userStore "service/domain/data/store/user"
+	"service/domain/sys/auth"
+	"service/domain/sys/database"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// UserCreate adds a new user to the database. Roles are given as a comma
+// separated list and default to USER. The password is read from the first
+// line of stdin so it never shows up in the shell history or ps.
+func UserCreate(log *zap.SugaredLogger, cfg database.Config, name, email, roles string) error {
+	if name == "" || email == "" {
+		fmt.Println("help: users create <name> <email> [roles] < password")
+		return ErrHelp
+	}
+
+	password, err := readPassword(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("read password: %w", err)
+	}
+
+	if roles == "" {
+		roles = auth.RoleUser
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	core := user.NewCore(log, userStore.NewStore(log, db))
+
+	nu := userStore.NewUser{
+		Name:            name,
+		Email:           email,
+		Password:        password,
+		PasswordConfirm: password,
+		Roles:           strings.Split(roles, ","),
+	}
+
+	usr, err := core.Create(ctx, nu, time.Now())
+	if err != nil {
+		return fmt.Errorf("create user: %w", err)
+	}
+
+	fmt.Println("user id:", usr.ID)
+	return nil
+}
+
+// UserList prints a page of users. Page and rows default to 1 and 50.
+func UserList(log *zap.SugaredLogger, cfg database.Config, page, rows string) error {
+	pageNumber, rowsPerPage := 1, 50
+
+	if page != "" {
+		n, err := strconv.Atoi(page)
+		if err != nil {
+			fmt.Println("help: users list [page] [rows]")
+			return ErrHelp
+		}
+		pageNumber = n
+	}
+
+	if rows != "" {
+		n, err := strconv.Atoi(rows)
+		if err != nil {
+			fmt.Println("help: users list [page] [rows]")
+			return ErrHelp
+		}
+		rowsPerPage = n
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	core := user.NewCore(log, userStore.NewStore(log, db))
+
+	users, err := core.Query(ctx, pageNumber, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("query users: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tROLES\tENABLED")
+	for _, usr := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", usr.ID, usr.Name, usr.Email, strings.Join(usr.Roles, ","), usr.Enabled)
+	}
+	return tw.Flush()
+}
+
+// UserDisable stops the specified user from authenticating.
+func UserDisable(log *zap.SugaredLogger, cfg database.Config, userID string) error {
+	if userID == "" {
+		fmt.Println("help: users disable <user_id>")
+		return ErrHelp
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("connect database: %w", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	core := user.NewCore(log, userStore.NewStore(log, db))
+
+	enabled := false
+	upd := userStore.UpdateUser{
+		Enabled: &enabled,
+	}
+
+	if err := core.Update(ctx, adminClaims, userID, upd, time.Now()); err != nil {
+		return fmt.Errorf("disable user: %w", err)
+	}
+
+	fmt.Println("user disabled:", userID)
+	return nil
+}
+
+// readPassword reads the password from the first line of f, prompting for
+// it when f is a terminal.
+func readPassword(f *os.File) (string, error) {
+	if fi, err := f.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "password: ")
+	}
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password is empty")
+	}
+	return password, nil
+}
@@ -1,216 +1,215 @@
+// This program performs administrative tasks for the sales service.
 package main
 
 import (
-	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
-	"github.com/golang-jwt/jwt/v4"
-	"io"
+	"github.com/ardanlabs/conf"
+	"go.uber.org/zap"
 	"os"
-	"service/domain/data/schema"
+	"service/domain/sys/auth"
 	"service/domain/sys/database"
+	"service/foundation/keystore"
+	"service/foundation/logger"
+	"service/tooling/admin/commands"
+	"strings"
 	"time"
 )
 
-func main() {
-	err := genKey()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-}
+var build = "develop"
 
-func genKey() error {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return fmt.Errorf("error while generating key %w", err)
-	}
-
-	//%w verb used only for errors and used to wrap/unwrap value
-	privateFile, err := os.Create("private.pem")
-	if err != nil {
-		return fmt.Errorf("error while creating pem file %w", err)
-	}
-	defer privateFile.Close()
-
-	privateBlock := pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	}
-
-	if err := pem.Encode(privateFile, &privateBlock); err != nil {
-		return fmt.Errorf("error encoding to pem file %w", err)
-	}
-
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
-	if err != nil {
-		return fmt.Errorf("error marshaling public key %w", err)
-	}
+// Exit codes returned by the tool, usage errors are kept apart from
+// failures so scripts and init containers can tell them apart.
+const (
+	exitFailure = 1
+	exitUsage   = 2
+)
 
-	publicFile, err := os.Create("public.pem")
+func main() {
+	log, err := logger.New("ADMIN")
 	if err != nil {
-		return fmt.Errorf("error while creating pem file %w", err)
-	}
-	defer publicFile.Close()
-
-	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
-		Bytes: asn1Bytes,
+		fmt.Println("constructing logger:", err)
+		os.Exit(exitFailure)
 	}
+	defer log.Sync()
 
-	if err := pem.Encode(privateFile, &publicBlock); err != nil {
-		return fmt.Errorf("error encoding to pem public file %w", err)
+	if err := run(log); err != nil {
+		if errors.Is(err, commands.ErrHelp) {
+			os.Exit(exitUsage)
+		}
+		log.Errorw("admin", "ERROR", err)
+		log.Sync()
+		os.Exit(exitFailure)
 	}
-	return nil
 }
 
-// TODO test genToken by makefile
-func genToken() error {
-	keyName := "private.pem"
-	name := "zard/keys/" + keyName
-
-	file, err := os.Open(name)
-	if err != nil {
-		return err
-	}
-
-	privatePEM, err := io.ReadAll(io.LimitReader(file, 1024*1024))
-	if err != nil {
-		return fmt.Errorf("reading auth private key %w", err)
-	}
-
-	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
-	if err != nil {
-		return fmt.Errorf("parsing auth private key %w", err)
-	}
-
-	claims := struct {
-		//we embedded the type here, if we do not embed it
-		//and act as c style, our struct won't satisfy jwt.StandardClaims Interface
-		jwt.StandardClaims
-		Roles []string
+func run(log *zap.SugaredLogger) error {
+
+	// =================================== Configuration
+	// The DB and Auth sections mirror the sales-api so both binaries read the
+	// same SALES_* environment.
+
+	cfg := struct {
+		conf.Version
+		Args conf.Args
+		Auth struct {
+			KeysFolder     string        `conf:"default:zarf/keys/"`
+			ActiveKID      string        `conf:"default:private"`
+			Issuer         string        `conf:"default:service project"`
+			Audiences      []string      `conf:"help:accepted audiences separated by ';' the first one is issued"`
+			TTL            time.Duration `conf:"default:1h"`
+			Passphrase     string        `conf:"mask"`
+			PassphraseFile string
+		}
+		DB struct {
+			User         string `conf:"default:postgres"`
+			Password     string `conf:"default:postgres,mask"`
+			Host         string `conf:"default:localhost"`
+			Name         string `conf:"default:postgres"`
+			MaxIdleConns int    `conf:"default:0"`
+			MaxOpenConns int    `conf:"default:0"`
+			DisableTLS   bool   `conf:"default:true"`
+		}
 	}{
-		StandardClaims: jwt.StandardClaims{
-			Issuer:    "service project",
-			Subject:   "123456789",
-			ExpiresAt: time.Now().Add(7860 * time.Hour).Unix(),
-			IssuedAt:  time.Now().UTC().Unix(),
+		Version: conf.Version{
+			SVN:  build,
+			Desc: "Copy Right Stuff",
 		},
-		Roles: []string{"ADMIN"},
 	}
 
-	method := jwt.GetSigningMethod("RS256")
-	token := jwt.NewWithClaims(method, claims)
-	token.Header["kid"] = keyName
-
-	tokenStr, err := token.SignedString(privateKey)
+	const prefix = "SALES"
+	help, err := conf.ParseOSArgs(prefix, &cfg)
 	if err != nil {
-		return err
+		if errors.Is(err, conf.ErrHelpWanted) {
+			fmt.Println(help)
+			printUsage()
+			return nil
+		}
+		return fmt.Errorf("parsing config: %w", err)
 	}
 
-	fmt.Println("TOKEN", tokenStr)
-
-	asn1Bytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	out, err := conf.String(&cfg)
 	if err != nil {
-		return fmt.Errorf("error marshaling public key %w", err)
+		return fmt.Errorf("generating config for output: %w", err)
 	}
+	log.Infow("startup", "config", out)
 
-	publicBlock := pem.Block{
-		Type:  "RSA PUBLIC KEY",
-		Bytes: asn1Bytes,
+	dbConfig := database.Config{
+		User:         cfg.DB.User,
+		Password:     cfg.DB.Password,
+		Host:         cfg.DB.Host,
+		Name:         cfg.DB.Name,
+		MaxIdleConns: cfg.DB.MaxIdleConns,
+		MaxOpenConns: cfg.DB.MaxOpenConns,
+		DisableTLS:   cfg.DB.DisableTLS,
 	}
 
-	if err := pem.Encode(os.Stdout, &publicBlock); err != nil {
-		return fmt.Errorf("error encoding to pem public file %w", err)
+	passphrase := []byte(cfg.Auth.Passphrase)
+	if cfg.Auth.PassphraseFile != "" {
+		passphrase, err = keystore.ReadPassphrase(cfg.Auth.PassphraseFile)
+		if err != nil {
+			return err
+		}
 	}
 
-	parser := jwt.Parser{
-		ValidMethods: []string{"RS256"},
-	}
-	//jwt.NewParser()
-	var ParsedClaims struct {
-		jwt.StandardClaims
-		Roles []string
+	// Tokens from gentoken must pass the checks of the sales-api, they are
+	// issued with the same settings.
+	authOpts := []auth.Option{
+		auth.WithIssuer(cfg.Auth.Issuer),
+		auth.WithAudience(cfg.Auth.Audiences...),
+		auth.WithTTL(cfg.Auth.TTL),
 	}
 
-	keyFunc := func(t *jwt.Token) (any, error) {
-		kid, ok := t.Header["kid"]
-		if !ok {
-			return nil, errors.New("missing key id in header")
-		}
-		kidID, ok := kid.(string)
-		if !ok {
-			return nil, errors.New("key id must be string")
+	return processCommands(cfg.Args, log, dbConfig, cfg.Auth.KeysFolder, cfg.Auth.ActiveKID, passphrase, authOpts)
+}
+
+// processCommands handles the execution of the commands specified on
+// the command line.
+func processCommands(args conf.Args, log *zap.SugaredLogger, dbConfig database.Config, keysFolder string, activeKID string, passphrase []byte, authOpts []auth.Option) error {
+
+	// Flags given after the command are left in args by conf.
+	dryRun := hasFlag(args, "--dry-run")
+	args = positional(args)
+
+	switch args.Num(0) {
+	case "genkey":
+		return commands.GenKey(keysFolder, passphrase)
+
+	case "gentoken":
+		return commands.GenToken(log, dbConfig, keysFolder, passphrase, authOpts, args.Num(1), args.Num(2))
+
+	case "migrate":
+		switch args.Num(1) {
+		case "":
+			return commands.Migrate(dbConfig, dryRun)
+		case "status":
+			return commands.MigrateStatus(dbConfig)
+		case "down":
+			return commands.MigrateDown(dbConfig, args.Num(2), dryRun)
 		}
-		fmt.Println("kid ", kidID)
-		return &privateKey.PublicKey, nil
-	}
 
-	parsedToken, err := parser.ParseWithClaims(tokenStr, &ParsedClaims, keyFunc)
-	if err != nil {
-		return fmt.Errorf("parsing token %w", err)
-	}
+	case "seed":
+		return commands.Seed(dbConfig)
 
-	if !parsedToken.Valid {
-		return fmt.Errorf("token is not valid %w", err)
-	}
+	case "db":
+		switch args.Num(1) {
+		case "doctor":
+			return commands.Doctor(dbConfig)
+		}
 
-	fmt.Println("TOKEN", tokenStr)
-	return nil
-}
+	case "users":
+		switch args.Num(1) {
+		case "create":
+			return commands.UserCreate(log, dbConfig, args.Num(2), args.Num(3), args.Num(4))
+		case "list":
+			return commands.UserList(log, dbConfig, args.Num(2), args.Num(3))
+		case "disable":
+			return commands.UserDisable(log, dbConfig, args.Num(2))
+		}
 
-func seed() error {
-	cfg := database.Config{
-		User:         "postgres",
-		Password:     "postgres",
-		Host:         "localhost",
-		Name:         "postgres",
-		MaxIdleConns: 0,
-		MaxOpenConns: 0,
-		DisableTLS:   true,
-	}
-	db, err := database.Open(cfg)
-	if err != nil {
-		return fmt.Errorf("connect database: %w", err)
+	case "keys":
+		switch args.Num(1) {
+		case "list":
+			return commands.KeysList(keysFolder, activeKID, passphrase)
+		}
 	}
-	defer db.Close()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	printUsage()
+	return commands.ErrHelp
+}
 
-	if err := schema.Seed(ctx, db); err != nil {
-		return fmt.Errorf("seed database: %w", err)
+func hasFlag(args conf.Args, flag string) bool {
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
 	}
-	fmt.Println("seed data complete")
-	return nil
+	return false
 }
 
-func migrate() error {
-	cfg := database.Config{
-		User:         "postgres",
-		Password:     "postgres",
-		Host:         "localhost",
-		Name:         "postgres",
-		MaxIdleConns: 0,
-		MaxOpenConns: 0,
-		DisableTLS:   true,
-	}
-	db, err := database.Open(cfg)
-	if err != nil {
-		return fmt.Errorf("connect database: %w", err)
+func positional(args conf.Args) conf.Args {
+	var pos conf.Args
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			pos = append(pos, arg)
+		}
 	}
-	defer db.Close()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	return pos
+}
 
-	if err := schema.Migrate(ctx, db); err != nil {
-		return fmt.Errorf("seed database: %w", err)
-	}
-	fmt.Println("migrate complete")
-	return seed()
+func printUsage() {
+	fmt.Println(`Commands:
+  genkey                                       create a new private key in the keys folder, encrypted
+                                               when a passphrase is configured
+  gentoken <user_id> <kid>                     generate a token for a user signed with kid, valid for
+                                               the configured TTL
+  migrate [--dry-run]                          apply the schema migrations
+  migrate status                               show applied and pending migrations
+  migrate down [steps] [--dry-run]             roll back the last applied migrations
+  seed                                         load the seed data
+  db doctor                                    compare the tables with the store models
+  users create <name> <email> [roles]          add a user, the password is read from stdin
+  users list [page] [rows]
+  users disable <user_id>
+  keys list                                    list the keys in the keys folder`)
 }